@@ -1,42 +1,207 @@
 package bootstrap
 
 import (
+	"flag"
 	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
 	"github.com/carvalhorr/protoc-gen-mock/stub"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"os"
 	"strings"
+	"time"
 )
 
 // BootstrapServers starts the gRPC server with the mock services added by serviceRegisterCallback.
 // The REST server for the stub API management is also started.
+// The configuration file (see Config.LoadFile), the environment variables (see Config.LoadEnv) and command line flags are parsed to allow overriding the configuration (see Config.RegisterFlags).
 // Parameters:
 // - tmpPath : temporary path to store temporary files
 // - restPort : the port where the REST server will be started
 // - grpcPort : the port where the gRPC server will be started
 // - serviceRegisterCallback : a function called when the grpc server is ready so that the mock services can be registered
 func BootstrapServers(tmpPath string, restPort uint, grpcPort uint, serviceRegisterCallback func(stubsStore stub.StubsMatcher) grpchandler.MockService) {
+	BootstrapServersWithConfig(loadConfig(tmpPath, restPort, grpcPort), serviceRegisterCallback)
+}
+
+// BootstrapServiceSets starts the servers like BootstrapServers for several sets of mock services, each with its own stubs
+// and REST API under /sets/<name>. The REST API of the first set is also served without prefix. The sets are configured
+// in the serviceSets of the configuration file.
+func BootstrapServiceSets(tmpPath string, restPort uint, grpcPort uint, sets []ServiceSet) {
+	BootstrapServiceSetsWithConfig(loadConfig(tmpPath, restPort, grpcPort), sets)
+}
+
+// loadConfig returns the default configuration overridden by the configuration file, the environment variables and the flags.
+func loadConfig(tmpPath string, restPort uint, grpcPort uint) Config {
+	config := Config{
+		TmpPath:                   tmpPath,
+		RestPort:                  restPort,
+		GrpcPort:                  grpcPort,
+		ForwardIdleTimeout:        5 * time.Minute,
+		ShutdownGracePeriod:       30 * time.Second,
+		CorrelationIDKey:          "x-request-id",
+		UnmatchedCallsWindow:      time.Minute,
+		MetricsLabels:             []string{grpchandler.MetricsLabelMethod, grpchandler.MetricsLabelCode},
+		MetricsMaxSeries:          1000,
+		MatchCacheSize:            stub.DefaultMatchCacheSize,
+		MaxContentDepth:           stub.DefaultMaxContentDepth,
+		ParallelMatchingThreshold: stub.DefaultParallelMatchingThreshold,
+		AccessLog:                 "stdout",
+		TLS:                       TLSConfig{ReloadInterval: 10 * time.Second},
+	}
+	if path := configFile(os.Args[1:]); path != "" {
+		if err := config.LoadFile(path); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := config.LoadEnv(); err != nil {
+		log.Fatal(err)
+	}
+	config.RegisterFlags(flag.CommandLine)
+	if !flag.Parsed() {
+		flag.Parse()
+	}
+	return config
+}
+
+// BootstrapServersWithConfig starts the gRPC and REST servers using the configuration provided. Flags are not parsed.
+func BootstrapServersWithConfig(config Config, serviceRegisterCallback func(stubsStore stub.StubsMatcher) grpchandler.MockService) {
+	BootstrapServiceSetsWithConfig(config, []ServiceSet{{Register: serviceRegisterCallback}})
+}
+
+// BootstrapServiceSetsWithConfig starts the gRPC and REST servers of the service sets using the configuration provided.
+// Flags are not parsed.
+func BootstrapServiceSetsWithConfig(config Config, sets []ServiceSet) {
 	setupLogrus()
 
-	errorsEngine, err := stub.NewCustomErrorEngine(tmpPath)
+	errorsEngine, err := stub.NewCustomErrorEngine(config.TmpPath)
 	if err != nil {
 		panic(err)
 	}
 	stub.SetErrorEngine(errorsEngine)
+	stub.SetMaxContentDepth(config.MaxContentDepth)
+	for name, matcher := range config.RequestMatchers {
+		stub.RegisterRequestMatcher(name, matcher)
+	}
+	for name, generator := range config.ResponseGenerators {
+		stub.RegisterResponseGenerator(name, generator)
+	}
 
-	stubsStore := stub.NewInMemoryStubsStore()
-	stubsMatcher := stub.NewStubsMatcher(stubsStore)
-
-	recordingsStore := stub.NewRecordingsStore()
+	var recordingsStore stub.RecordingsStore = stub.NewRecordingsStore()
+	var spillingRecordings *stub.SpillingRecordingsStore
+	if config.RecordingsMemoryBudget > 0 {
+		if spillingRecordings, err = stub.NewSpillingRecordingsStore(config.TmpPath, config.RecordingsMemoryBudget); err != nil {
+			log.Fatalf("Failed to create the recordings store: %v", err)
+		}
+		config.ShutdownHooks = append(config.ShutdownHooks, func() {
+			if err := spillingRecordings.Close(); err != nil {
+				log.Errorf("Failed to remove the recordings: %v", err)
+			}
+		})
+		recordingsStore = spillingRecordings
+	}
 
-	service := serviceRegisterCallback(stubsMatcher)
-	log.Info("Supported methods: ", strings.Join(service.GetSupportedMethods(), "  |  "))
-	stubsExamples := service.GetPayloadExamples()
+	mountedSets, err := mountServiceSets(config, sets, recordingsStore)
+	if err != nil {
+		log.Fatalf("Invalid service sets: %v", err)
+	}
+	logBanner(createServerInfo(config, mountedSets))
+	for _, set := range mountedSets {
+		if set.Name != "" {
+			log.Infof("Service set %s", set.Name)
+		}
+		log.Info("Supported methods: ", strings.Join(set.service.GetSupportedMethods(), "  |  "))
+		if err := loadStubFiles(set.config.Stubs, set.service, set.stubsStore, config.StrictStartup, config.RejectShadowedStubs); err != nil {
+			log.Fatalf("Failed to load the stubs: %v", err)
+		}
+	}
+	service := combinedService(mountedSets)
+	// the first set is the one served by the REST API without prefix
+	stubsStore := mountedSets[0].stubsStore
 
 	grpchandler.SetSupportedMockService(service)
 	grpchandler.SetRecordingsStore(recordingsStore)
+	grpchandler.SetStubsStore(stubsStore)
+	grpchandler.SetProxyFallback(config.ProxyFallback)
+	grpchandler.SetForwardConnectionIdleTimeout(config.ForwardIdleTimeout)
+	if config.UnmatchedCallsThreshold > 0 {
+		grpchandler.SetUnmatchedCallsThreshold(config.UnmatchedCallsThreshold, config.UnmatchedCallsWindow)
+	}
+	if config.ReportFile != "" {
+		config.ShutdownHooks = append(config.ShutdownHooks, func() {
+			if err := writeReport(config.ReportFile, mountedSets); err != nil {
+				log.Errorf("Failed to write the report: %v", err)
+			}
+		})
+	}
+	if config.OTLPEndpoint != "" {
+		shutdownTracing, err := startTracing(config.OTLPEndpoint)
+		if err != nil {
+			log.Fatalf("Failed to start tracing: %v", err)
+		}
+		config.ShutdownHooks = append(config.ShutdownHooks, shutdownTracing)
+	}
+
+	if len(config.ResponseLimits) > 0 {
+		responseLimits, err := grpchandler.NewResponseLimits(config.ResponseLimits)
+		if err != nil {
+			log.Fatalf("Invalid response limits configuration: %v", err)
+		}
+		config.UnaryInterceptors = append([]grpc.UnaryServerInterceptor{responseLimits.UnaryInterceptor}, config.UnaryInterceptors...)
+		config.StreamInterceptors = append([]grpc.StreamServerInterceptor{responseLimits.StreamInterceptor}, config.StreamInterceptors...)
+	}
+	if config.MaxConcurrentCalls > 0 {
+		concurrencyLimit, err := grpchandler.NewConcurrencyLimit(config.MaxConcurrentCalls, config.MaxConcurrentCallsQueueTime, config.MaxConcurrentCallsPerService)
+		if err != nil {
+			log.Fatalf("Invalid concurrency limit configuration: %v", err)
+		}
+		config.UnaryInterceptors = append([]grpc.UnaryServerInterceptor{concurrencyLimit.UnaryInterceptor}, config.UnaryInterceptors...)
+		config.StreamInterceptors = append([]grpc.StreamServerInterceptor{concurrencyLimit.StreamInterceptor}, config.StreamInterceptors...)
+	}
+	// runs before the concurrency limit so that the calls over the rate do not take a slot
+	if config.RateLimit > 0 {
+		rateLimit, err := grpchandler.NewRateLimit(config.RateLimit, config.RateLimitBurst, config.RateLimitKey)
+		if err != nil {
+			log.Fatalf("Invalid rate limit configuration: %v", err)
+		}
+		config.UnaryInterceptors = append([]grpc.UnaryServerInterceptor{rateLimit.UnaryInterceptor}, config.UnaryInterceptors...)
+		config.StreamInterceptors = append([]grpc.StreamServerInterceptor{rateLimit.StreamInterceptor}, config.StreamInterceptors...)
+	}
+
+	var metrics *grpchandler.Metrics
+	if config.Metrics {
+		if metrics, err = grpchandler.NewMetrics(config.MetricsLabels, config.MetricsMaxSeries); err != nil {
+			log.Fatalf("Invalid metrics configuration: %v", err)
+		}
+		if spillingRecordings != nil {
+			metrics.SetRecordingsStats(spillingRecordings.Stats)
+		}
+		// added before the interceptors of the configuration so that the calls they reject are counted
+		config.UnaryInterceptors = append([]grpc.UnaryServerInterceptor{metrics.UnaryInterceptor}, config.UnaryInterceptors...)
+		config.StreamInterceptors = append([]grpc.StreamServerInterceptor{metrics.StreamInterceptor}, config.StreamInterceptors...)
+	}
 
-	go StartRESTServer(restPort, CreateRESTControllers(stubsExamples, stubsStore, recordingsStore, service))
-	StarGRPCServer(grpcPort, service)
+	createGRPCServer(config, service)
+	for _, set := range mountedSets {
+		if set.config.RestListen != "" {
+			go startRESTServer(set.config.RestListen, set.restHandler)
+		}
+	}
+	restHandler := createServiceSetsRESTHandler(mountedSets)
+	if metrics != nil {
+		restHandler = withMetrics(restHandler, metrics)
+	}
+	if config.Pprof {
+		restHandler = withDiagnostics(restHandler, stubsStore)
+	}
+	if config.GrpcWeb {
+		restHandler = wrapGrpcWeb(restHandler)
+	}
+	if config.SinglePort {
+		serveGRPC(config, restHandler)
+		return
+	}
+	go startRESTServer(config.restAddress(), restHandler)
+	serveGRPC(config, nil)
 }
 
 func setupLogrus() {
@@ -0,0 +1,45 @@
+package bootstrap
+
+import (
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	_ "google.golang.org/grpc/encoding/gzip" // registers the gzip compressor
+	"io"
+)
+
+// registerCompressors makes the compressors available to decompress requests and to compress the responses of the clients using them.
+func registerCompressors(compressors []encoding.Compressor) {
+	for _, compressor := range compressors {
+		encoding.RegisterCompressor(compressor)
+	}
+}
+
+// createSendCompressor returns the compressor used for all the responses, whether the client compressed the request or not.
+func createSendCompressor(name string) (grpc.Compressor, error) {
+	compressor := encoding.GetCompressor(name)
+	if compressor == nil {
+		return nil, fmt.Errorf("compressor '%s' is not registered", name)
+	}
+	return sendCompressor{compressor}, nil
+}
+
+// sendCompressor adapts a registered compressor to the compressor interface used by the server to force the compression.
+type sendCompressor struct {
+	compressor encoding.Compressor
+}
+
+func (c sendCompressor) Do(w io.Writer, p []byte) error {
+	writer, err := c.compressor.Compress(w)
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(p); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+func (c sendCompressor) Type() string {
+	return c.compressor.Name()
+}
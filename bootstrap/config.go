@@ -0,0 +1,316 @@
+package bootstrap
+
+import (
+	"flag"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the settings used to start the mock servers.
+type Config struct {
+	// Temporary path to store temporary files
+	TmpPath string `yaml:"tmpPath"`
+	// The port where the REST server will be started
+	RestPort uint `yaml:"restPort"`
+	// The port where the gRPC server will be started
+	GrpcPort uint `yaml:"grpcPort"`
+	// Host or IP the servers bind to when listening on RestPort and GrpcPort, e.g. 127.0.0.1 to only accept local connections. All interfaces when empty.
+	BindAddress string `yaml:"bindAddress"`
+	// Address the REST server listens on instead of RestPort: host:port, tcp://host:port or unix:///path/to/socket
+	RestListen string `yaml:"restListen"`
+	// Serve the REST API on the same port as the gRPC server instead of RestPort
+	SinglePort bool `yaml:"singlePort"`
+	// Serve gRPC-Web (and gRPC-Web text) requests on the REST port so that browsers can call the mock services directly
+	GrpcWeb bool `yaml:"grpcWeb"`
+	// Addresses the gRPC server listens on instead of GrpcPort: host:port, tcp://host:port or unix:///path/to/socket
+	GrpcListen []string `yaml:"grpcListen"`
+	// Address of a real server where requests without a matching stub are forwarded to. Disabled when empty.
+	ProxyFallback string `yaml:"proxyFallback"`
+	// For how long connections to the servers requests are forwarded to are kept open without being used. Zero keeps them open.
+	ForwardIdleTimeout time.Duration `yaml:"forwardIdleTimeout"`
+	// TLS settings of the gRPC server. The server uses plaintext when no certificate is provided.
+	TLS TLSConfig `yaml:"tls"`
+	// The gRPC reflection service is registered unless disabled so that tools like grpcurl can discover the mock services.
+	DisableReflection bool `yaml:"disableReflection"`
+	// Register the channelz service so that the connections, streams and sockets of the server can be inspected, e.g. with grpcdebug
+	Channelz bool `yaml:"channelz"`
+	// Serve the metrics of the gRPC calls in the Prometheus text format under /metrics on the REST port
+	Metrics bool `yaml:"metrics"`
+	// Labels of the metrics: method, stub_id and code. The number of series is limited to MetricsMaxSeries, unlimited when zero.
+	MetricsLabels    []string `yaml:"metricsLabels"`
+	MetricsMaxSeries int      `yaml:"metricsMaxSeries"`
+	// Serve the pprof profiles under /debug/pprof/ and the runtime stats under /debug/vars on the REST port
+	Pprof bool `yaml:"pprof"`
+	// How long the in-flight calls are allowed to finish when the server is stopped. Zero waits indefinitely.
+	ShutdownGracePeriod time.Duration `yaml:"shutdownGracePeriod"`
+	// Functions called once the servers are stopped, e.g. to flush data kept by the interceptors
+	ShutdownHooks []func() `yaml:"-"`
+	// Interceptors added to the gRPC server, e.g. to validate credentials or extract the tenant. They are called in the order provided.
+	UnaryInterceptors  []grpc.UnaryServerInterceptor  `yaml:"-"`
+	StreamInterceptors []grpc.StreamServerInterceptor `yaml:"-"`
+	// Matchers and response generators selected by name by the stubs whose matching type or response type is custom
+	RequestMatchers    map[string]stub.RequestMatcher    `yaml:"-"`
+	ResponseGenerators map[string]stub.ResponseGenerator `yaml:"-"`
+	// Maximum size in bytes of the messages received and sent by the gRPC server. The gRPC defaults are used when zero.
+	MaxRecvMsgSize int `yaml:"maxRecvMsgSize"`
+	MaxSendMsgSize int `yaml:"maxSendMsgSize"`
+	// Keepalive settings of the gRPC server. The gRPC defaults are used when zero.
+	Keepalive KeepaliveConfig `yaml:"keepalive"`
+	// Compressors registered in addition to gzip. Requests compressed by the client are answered using the same compressor.
+	Compressors []encoding.Compressor `yaml:"-"`
+	// Number of calls without a matching stub in UnmatchedCallsWindow above which an alert is logged and shown in /readyz. Disabled when zero.
+	UnmatchedCallsThreshold int           `yaml:"unmatchedCallsThreshold"`
+	UnmatchedCallsWindow    time.Duration `yaml:"unmatchedCallsWindow"`
+	// File the JUnit XML report of the requests without a matching stub and the stubs never matched is written to when the
+	// server stops. Disabled when empty. The report is also served under /verifications/report.
+	ReportFile string `yaml:"reportFile"`
+	// Calls per second allowed for each method, or for each method and value of RateLimitKey, before the calls fail with
+	// RESOURCE_EXHAUSTED. Up to RateLimitBurst calls are allowed at once. Disabled when zero.
+	RateLimit      float64 `yaml:"rateLimit"`
+	RateLimitBurst int     `yaml:"rateLimitBurst"`
+	RateLimitKey   string  `yaml:"rateLimitKey"`
+	// Maximum number of calls in flight per method, or per service with MaxConcurrentCallsPerService. The calls over the
+	// limit wait for up to MaxConcurrentCallsQueueTime and then fail with UNAVAILABLE. Unlimited when zero.
+	MaxConcurrentCalls           int           `yaml:"maxConcurrentCalls"`
+	MaxConcurrentCallsQueueTime  time.Duration `yaml:"maxConcurrentCallsQueueTime"`
+	MaxConcurrentCallsPerService bool          `yaml:"maxConcurrentCallsPerService"`
+	// Limits of the size and of the number of messages of the responses of the methods or services, only set in the
+	// configuration file. See grpchandler.ResponseLimit.
+	ResponseLimits []grpchandler.ResponseLimit `yaml:"responseLimits"`
+	// Endpoint of the OTLP collector the traces of the gRPC calls are exported to, e.g. http://localhost:4317. Disabled when empty.
+	OTLPEndpoint string `yaml:"otlpEndpoint"`
+	// Metadata key of the ID correlating the calls across systems, e.g. x-request-id. It is logged in the access log and
+	// propagated to the forwarded calls, and generated when the client does not send it. Disabled when empty.
+	CorrelationIDKey string `yaml:"correlationIdKey"`
+	// Where the access log, one JSON line per gRPC call, is written: stdout, stderr or the path of a file. Disabled when empty.
+	AccessLog string `yaml:"accessLog"`
+	// Maximum size in bytes of the request and response bodies in the access log. The bodies are not logged when zero.
+	AccessLogBodySize int `yaml:"accessLogBodySize"`
+	// Values replaced in the bodies and error messages of the access log
+	AccessLogRedaction stub.RecordingRedaction `yaml:"accessLogRedaction"`
+	// Name of the compressor used for all the responses, e.g. gzip. Responses are only compressed when the client compressed the request if empty.
+	SendCompression string `yaml:"sendCompression"`
+	// Files of stubs in JSON, or directories with .json files of stubs, loaded at startup. A file contains a stub or a list of stubs.
+	Stubs []string `yaml:"stubs"`
+	// Settings of the service sets served with BootstrapServiceSets, by name
+	ServiceSets []ServiceSetConfig `yaml:"serviceSets"`
+	// How the requests are compared with the stubs: json, the default, compares their JSON objects, and proto their messages,
+	// with the protobuf semantics for the presence of the fields, enums, 64 bit integers and bytes. See stub.WithProtoMatching.
+	MatchingEngine string `yaml:"matchingEngine"`
+	// Number of different calls whose matching stub is kept, so that the repeated calls are not compared with the stubs
+	// again. Disabled when zero. See stub.WithMatchCache.
+	MatchCacheSize int `yaml:"matchCacheSize"`
+	// Number of stubs of a method from which the calls are compared with them in parallel. Disabled when zero. See
+	// stub.WithParallelMatching.
+	ParallelMatchingThreshold int `yaml:"parallelMatchingThreshold"`
+	// Maximum nesting of the messages of the content of the stubs, e.g. the levels of a tree. The stubs nested deeper are
+	// rejected. DefaultMaxContentDepth when zero. See stub.SetMaxContentDepth.
+	MaxContentDepth int `yaml:"maxContentDepth"`
+	// Size in bytes of the responses of the recordings kept in memory. Above it the responses of the oldest recordings are
+	// written to files in TmpPath. The recordings are all kept in memory when zero. The stubs, including the ones replaying
+	// the recordings, are always kept in memory. See stub.SpillingRecordingsStore.
+	RecordingsMemoryBudget int64 `yaml:"recordingsMemoryBudget"`
+	// Reject the stubs added with the REST API or loaded from the Stubs files that shadow or are shadowed by another stub,
+	// instead of returning or logging a warning. See stub.FindShadowing.
+	RejectShadowedStubs bool `yaml:"rejectShadowedStubs"`
+	// Stop the server on startup when any of the Stubs can't be loaded, listing them, instead of skipping them. The shadowed
+	// stubs are only rejected with RejectShadowedStubs.
+	StrictStartup bool `yaml:"strictStartup"`
+	// Configuration file in YAML or JSON the settings are loaded from by BootstrapServers, see LoadFile
+	ConfigFile string `yaml:"-"`
+}
+
+// KeepaliveConfig holds the keepalive parameters and the enforcement policy of the gRPC server.
+type KeepaliveConfig struct {
+	// After a duration of this time without activity the server pings the client to see if the transport is still alive
+	Time time.Duration `yaml:"time"`
+	// How long the server waits for the ping ack before closing the connection
+	Timeout time.Duration `yaml:"timeout"`
+	// Maximum age of a connection before it is gracefully closed. Connections are not closed when zero.
+	MaxConnectionAge time.Duration `yaml:"maxConnectionAge"`
+	// Minimum time clients should wait between pings. Clients pinging more often are disconnected.
+	MinTime time.Duration `yaml:"minTime"`
+	// Whether clients are allowed to ping when there are no active streams
+	PermitWithoutStream bool `yaml:"permitWithoutStream"`
+}
+
+// RegisterFlags binds the configuration to command line flags. The current values are used as defaults.
+func (c *Config) RegisterFlags(flags *flag.FlagSet) {
+	flags.StringVar(&c.ConfigFile, "config", c.ConfigFile, "configuration file in YAML or JSON, loaded before the environment variables and the flags")
+	flags.Var((*stringsFlag)(&c.Stubs), "stubs", "file of stubs in JSON, or directory with .json files of stubs, loaded at startup. Can be repeated")
+	flags.BoolVar(&c.StrictStartup, "strict-startup", c.StrictStartup, "exit on startup when any of the stubs loaded with --stubs is invalid instead of skipping it")
+	flags.BoolVar(&c.RejectShadowedStubs, "reject-shadowed-stubs", c.RejectShadowedStubs, "reject the stubs added with the REST API or loaded with --stubs that shadow or are shadowed by another stub instead of returning or logging a warning")
+	flags.StringVar(&c.MatchingEngine, "matching-engine", c.MatchingEngine, "how the requests are compared with the stubs: json | proto (default json)")
+	flags.Int64Var(&c.RecordingsMemoryBudget, "recordings-memory-budget", c.RecordingsMemoryBudget, "size in bytes of the responses of the recordings kept in memory, the other ones are written to disk (unlimited when 0)")
+	flags.IntVar(&c.MaxContentDepth, "max-content-depth", c.MaxContentDepth, "maximum nesting of the messages of the content of the stubs, deeper stubs are rejected")
+	flags.IntVar(&c.MatchCacheSize, "match-cache-size", c.MatchCacheSize, "number of different calls whose matching stub is kept until the stubs of their method change (disabled when 0)")
+	flags.IntVar(&c.ParallelMatchingThreshold, "parallel-matching-threshold", c.ParallelMatchingThreshold, "number of stubs of a method from which the calls are compared with them in parallel (disabled when 0)")
+	flags.UintVar(&c.GrpcPort, "grpc-port", c.GrpcPort, "port of the gRPC server (0 picks a free port)")
+	flags.UintVar(&c.RestPort, "rest-port", c.RestPort, "port of the REST server (0 picks a free port)")
+	flags.StringVar(&c.BindAddress, "bind-address", c.BindAddress, "host or IP the servers bind to, e.g. 127.0.0.1 (default all interfaces)")
+	flags.StringVar(&c.RestListen, "rest-listen", c.RestListen, "address the REST server listens on instead of the REST port: host:port, tcp://host:port or unix:///path/to/socket")
+	flags.Var((*stringsFlag)(&c.GrpcListen), "grpc-listen", "address the gRPC server listens on instead of the gRPC port: host:port, tcp://host:port or unix:///path/to/socket. Can be repeated")
+	flags.BoolVar(&c.SinglePort, "single-port", c.SinglePort, "serve the REST API on the same port as the gRPC server")
+	flags.BoolVar(&c.GrpcWeb, "grpc-web", c.GrpcWeb, "serve gRPC-Web requests on the REST port")
+	flags.StringVar(&c.ProxyFallback, "proxy-fallback", c.ProxyFallback, "address of a real server where requests without a matching stub are forwarded to")
+	flags.DurationVar(&c.ForwardIdleTimeout, "forward-idle-timeout", c.ForwardIdleTimeout, "for how long connections to the servers requests are forwarded to are kept open without being used (0 keeps them open)")
+	flags.StringVar(&c.TLS.CertFile, "tls-cert", c.TLS.CertFile, "certificate file used to serve the gRPC mock services over TLS")
+	flags.StringVar(&c.TLS.KeyFile, "tls-key", c.TLS.KeyFile, "private key file of the TLS certificate")
+	flags.StringVar(&c.TLS.ClientCAFile, "tls-client-ca", c.TLS.ClientCAFile, "CA file used to verify client certificates (enables mTLS)")
+	flags.StringVar(&c.TLS.ClientAuth, "tls-client-auth", c.TLS.ClientAuth, "whether clients must present a certificate when --tls-client-ca is set: require | optional (default require)")
+	flags.DurationVar(&c.TLS.ReloadInterval, "tls-reload-interval", c.TLS.ReloadInterval, "how often the TLS certificate and key files are checked for changes to reload them (disabled when 0)")
+	flags.IntVar(&c.MaxRecvMsgSize, "max-recv-msg-size", c.MaxRecvMsgSize, "maximum size in bytes of the messages received by the gRPC server (default 4MB)")
+	flags.IntVar(&c.MaxSendMsgSize, "max-send-msg-size", c.MaxSendMsgSize, "maximum size in bytes of the messages sent by the gRPC server")
+	flags.DurationVar(&c.Keepalive.Time, "keepalive-time", c.Keepalive.Time, "time without activity after which the gRPC server pings the client")
+	flags.DurationVar(&c.Keepalive.Timeout, "keepalive-timeout", c.Keepalive.Timeout, "how long the gRPC server waits for the keepalive ping ack")
+	flags.DurationVar(&c.Keepalive.MaxConnectionAge, "keepalive-max-connection-age", c.Keepalive.MaxConnectionAge, "maximum age of a connection before it is gracefully closed")
+	flags.DurationVar(&c.Keepalive.MinTime, "keepalive-min-time", c.Keepalive.MinTime, "minimum time clients should wait between keepalive pings")
+	flags.BoolVar(&c.Keepalive.PermitWithoutStream, "keepalive-permit-without-stream", c.Keepalive.PermitWithoutStream, "allow clients to send keepalive pings when there are no active streams")
+	flags.StringVar(&c.SendCompression, "send-compression", c.SendCompression, "compress all the responses of the gRPC server with the compressor provided, e.g. gzip")
+	flags.DurationVar(&c.ShutdownGracePeriod, "shutdown-grace-period", c.ShutdownGracePeriod, "how long the in-flight calls are allowed to finish when the server is stopped (0 waits indefinitely)")
+	flags.BoolVar(&c.DisableReflection, "disable-reflection", c.DisableReflection, "do not register the gRPC reflection service")
+	flags.BoolVar(&c.Channelz, "channelz", c.Channelz, "register the channelz service to inspect the connections of the gRPC server")
+	flags.StringVar(&c.CorrelationIDKey, "correlation-id-key", c.CorrelationIDKey, "metadata key of the correlation ID logged and propagated to the forwarded calls (disabled when empty)")
+	flags.StringVar(&c.AccessLog, "access-log", c.AccessLog, "where the access log of the gRPC calls is written: stdout, stderr or a file path (disabled when empty)")
+	flags.IntVar(&c.AccessLogBodySize, "access-log-body-size", c.AccessLogBodySize, "maximum size in bytes of the request and response bodies in the access log (bodies are not logged when 0)")
+	flags.Var((*stringsFlag)(&c.AccessLogRedaction.Fields), "access-log-redact-field", "dot separated path of a field redacted from the bodies in the access log, e.g. user.password. Can be repeated")
+	flags.Var((*stringsFlag)(&c.AccessLogRedaction.Patterns), "access-log-redact-pattern", "regular expression of the values redacted from the bodies and errors in the access log. Can be repeated")
+	flags.IntVar(&c.UnmatchedCallsThreshold, "unmatched-calls-threshold", c.UnmatchedCallsThreshold, "number of calls without a matching stub in the window above which an alert is raised (disabled when 0)")
+	flags.DurationVar(&c.UnmatchedCallsWindow, "unmatched-calls-window", c.UnmatchedCallsWindow, "window of the unmatched calls threshold")
+	flags.StringVar(&c.ReportFile, "report-file", c.ReportFile, "file the JUnit XML report of the unmatched requests and the unused stubs is written to when the server stops")
+	flags.Float64Var(&c.RateLimit, "rate-limit", c.RateLimit, "calls per second allowed for each method before the calls fail with RESOURCE_EXHAUSTED (disabled when 0)")
+	flags.IntVar(&c.RateLimitBurst, "rate-limit-burst", c.RateLimitBurst, "number of calls allowed at once by the rate limit")
+	flags.StringVar(&c.RateLimitKey, "rate-limit-key", c.RateLimitKey, "metadata key, e.g. x-api-key, whose values are rate limited separately")
+	flags.IntVar(&c.MaxConcurrentCalls, "max-concurrent-calls", c.MaxConcurrentCalls, "maximum number of calls in flight per method before the calls fail with UNAVAILABLE (unlimited when 0)")
+	flags.DurationVar(&c.MaxConcurrentCallsQueueTime, "max-concurrent-calls-queue-time", c.MaxConcurrentCallsQueueTime, "how long the calls over the maximum of concurrent calls wait before failing (0 fails right away)")
+	flags.BoolVar(&c.MaxConcurrentCallsPerService, "max-concurrent-calls-per-service", c.MaxConcurrentCallsPerService, "apply the maximum of concurrent calls per service instead of per method")
+	flags.BoolVar(&c.Metrics, "metrics", c.Metrics, "serve the metrics of the gRPC calls in the Prometheus text format under /metrics on the REST port")
+	flags.Var((*commaSeparatedFlag)(&c.MetricsLabels), "metrics-labels", "comma separated labels of the metrics: method, stub_id and code")
+	flags.IntVar(&c.MetricsMaxSeries, "metrics-max-series", c.MetricsMaxSeries, "maximum number of series of the metrics. Further label values are aggregated as \"other\" (0 is unlimited)")
+	flags.BoolVar(&c.Pprof, "pprof", c.Pprof, "serve the pprof profiles under /debug/pprof/ and the runtime stats under /debug/vars on the REST port")
+	flags.StringVar(&c.OTLPEndpoint, "otlp-endpoint", c.OTLPEndpoint, "endpoint of the OTLP collector the traces are exported to, e.g. http://localhost:4317 (tracing is disabled when empty)")
+}
+
+// LoadEnv overrides the configuration with the environment variables that are set. Every flag (see RegisterFlags) can be
+// set with the variable named after it with the MOCK_ prefix, in upper case and with underscores, e.g. MOCK_GRPC_PORT for
+// --grpc-port. The flags that can be repeated take a comma separated list. Empty values are ignored, except for the
+// settings that are strings, e.g. MOCK_ACCESS_LOG= disables the access log. OTEL_EXPORTER_OTLP_ENDPOINT is also read.
+func (c *Config) LoadEnv() error {
+	if value, ok := os.LookupEnv("OTEL_EXPORTER_OTLP_ENDPOINT"); ok {
+		c.OTLPEndpoint = value
+	}
+	flags := flag.NewFlagSet("env", flag.ContinueOnError)
+	c.RegisterFlags(flags)
+	var err error
+	flags.VisitAll(func(f *flag.Flag) {
+		name := envVariable(f.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok || err != nil || (value == "" && !isStringFlag(f)) {
+			return
+		}
+		if repeated, ok := f.Value.(*stringsFlag); ok {
+			*repeated = nil
+			for _, v := range strings.Split(value, ",") {
+				repeated.Set(v)
+			}
+			return
+		}
+		if setErr := f.Value.Set(value); setErr != nil {
+			err = fmt.Errorf("invalid value '%s' for %s", value, name)
+		}
+	})
+	return err
+}
+
+// envVariable returns the name of the environment variable of the flag.
+func envVariable(flagName string) string {
+	return "MOCK_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+func isStringFlag(f *flag.Flag) bool {
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return false
+	}
+	_, ok = getter.Get().(string)
+	return ok
+}
+
+// matcherOptions returns the options of the stubs matchers for the MatchingEngine, the MatchCacheSize and the
+// ParallelMatchingThreshold.
+func (c Config) matcherOptions() ([]stub.MatcherOption, error) {
+	if c.MatchCacheSize < 0 {
+		return nil, fmt.Errorf("invalid matchCacheSize %d", c.MatchCacheSize)
+	}
+	if c.ParallelMatchingThreshold < 0 {
+		return nil, fmt.Errorf("invalid parallelMatchingThreshold %d", c.ParallelMatchingThreshold)
+	}
+	options := []stub.MatcherOption{stub.WithMatchCache(c.MatchCacheSize), stub.WithParallelMatching(c.ParallelMatchingThreshold)}
+	switch c.MatchingEngine {
+	case "", "json":
+		return options, nil
+	case "proto":
+		return append(options, stub.WithProtoMatching()), nil
+	}
+	return nil, fmt.Errorf("invalid matchingEngine %s. Use json or proto", c.MatchingEngine)
+}
+
+// grpcAddresses returns the addresses the gRPC server listens on.
+func (c Config) grpcAddresses() []string {
+	if len(c.GrpcListen) > 0 {
+		return c.GrpcListen
+	}
+	return []string{net.JoinHostPort(c.bindHost(), strconv.Itoa(int(c.GrpcPort)))}
+}
+
+// restAddress returns the address the REST server listens on.
+func (c Config) restAddress() string {
+	if c.RestListen != "" {
+		return c.RestListen
+	}
+	return net.JoinHostPort(c.bindHost(), strconv.Itoa(int(c.RestPort)))
+}
+
+func (c Config) bindHost() string {
+	if c.BindAddress == "" {
+		return "0.0.0.0"
+	}
+	return c.BindAddress
+}
+
+// stringsFlag is a flag that can be repeated to provide several values
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// commaSeparatedFlag is a flag with a list of values separated by commas that replaces the default values
+type commaSeparatedFlag []string
+
+func (s *commaSeparatedFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *commaSeparatedFlag) Set(value string) error {
+	*s = make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*s = append(*s, v)
+		}
+	}
+	return nil
+}
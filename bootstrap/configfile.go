@@ -0,0 +1,102 @@
+package bootstrap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// LoadFile overrides the configuration with the settings of the file, in YAML or JSON, e.g.
+//
+//	grpcPort: 10010
+//	tls:
+//	  certFile: /certs/server.pem
+//	  keyFile: /certs/server.key
+//	stubs: [/stubs]
+//
+// The names of the settings are the ones of the fields of Config in lower camel case. Settings that are not in the file keep
+// their values. Unknown settings and invalid values are reported as errors.
+func (c *Config) LoadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read the configuration file: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+	if err := c.validate(); err != nil {
+		return fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+	return nil
+}
+
+// validate checks the values that would otherwise only fail once the servers are started.
+func (c Config) validate() error {
+	for name, port := range map[string]uint{"grpcPort": c.GrpcPort, "restPort": c.RestPort} {
+		if port > 65535 {
+			return fmt.Errorf("invalid %s %d", name, port)
+		}
+	}
+	if err := c.TLS.validate(); err != nil {
+		return err
+	}
+	if err := c.AccessLogRedaction.Validate(); err != nil {
+		return fmt.Errorf("invalid access log redaction: %w", err)
+	}
+	if _, err := grpchandler.NewMetrics(c.MetricsLabels, c.MetricsMaxSeries); err != nil {
+		return err
+	}
+	if _, err := c.matcherOptions(); err != nil {
+		return err
+	}
+	if c.RateLimit < 0 {
+		return fmt.Errorf("invalid rateLimit %g", c.RateLimit)
+	}
+	if c.MaxContentDepth < 0 {
+		return fmt.Errorf("invalid maxContentDepth %d", c.MaxContentDepth)
+	}
+	if c.RecordingsMemoryBudget < 0 {
+		return fmt.Errorf("invalid recordingsMemoryBudget %d", c.RecordingsMemoryBudget)
+	}
+	if c.MaxConcurrentCalls < 0 {
+		return fmt.Errorf("invalid maxConcurrentCalls %d", c.MaxConcurrentCalls)
+	}
+	if _, err := grpchandler.NewResponseLimits(c.ResponseLimits); err != nil {
+		return err
+	}
+	serviceSets := make(map[string]bool)
+	for _, set := range c.ServiceSets {
+		if serviceSets[set.Name] {
+			return fmt.Errorf("service set %s is defined twice", set.Name)
+		}
+		serviceSets[set.Name] = true
+	}
+	return nil
+}
+
+// configFile returns the configuration file set with the config flag in the arguments, or else with MOCK_CONFIG. The file
+// is loaded before the flags are parsed so that the flags take precedence over it.
+func configFile(args []string) string {
+	for i, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		switch {
+		case arg == "--":
+			return os.Getenv("MOCK_CONFIG")
+		case !strings.HasPrefix(arg, "-"):
+			continue
+		case strings.HasPrefix(name, "config="):
+			return strings.TrimPrefix(name, "config=")
+		case name == "config" && i+1 < len(args):
+			return args[i+1]
+		}
+	}
+	return os.Getenv("MOCK_CONFIG")
+}
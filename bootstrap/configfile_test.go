@@ -0,0 +1,92 @@
+package bootstrap
+
+import (
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestConfig_LoadFile_YAML(t *testing.T) {
+	path := writeTestFile(t, t.TempDir(), "mock.yaml", `
+grpcPort: 20010
+shutdownGracePeriod: 5s
+tls:
+  certFile: /certs/server.pem
+  keyFile: /certs/server.key
+  reloadInterval: 30s
+keepalive:
+  time: 1m
+accessLogRedaction:
+  fields: [user.password]
+stubs:
+  - /stubs
+responseLimits:
+  - method: greeter.Greeter
+    maxSize: 1024
+    action: truncate
+`)
+	config := Config{RestPort: 1068, GrpcPort: 10010, ShutdownGracePeriod: 30 * time.Second}
+	assert.Nil(t, config.LoadFile(path))
+	assert.Equal(t, uint(20010), config.GrpcPort)
+	assert.Equal(t, uint(1068), config.RestPort)
+	assert.Equal(t, 5*time.Second, config.ShutdownGracePeriod)
+	assert.Equal(t, "/certs/server.pem", config.TLS.CertFile)
+	assert.Equal(t, "/certs/server.key", config.TLS.KeyFile)
+	assert.Equal(t, 30*time.Second, config.TLS.ReloadInterval)
+	assert.Equal(t, time.Minute, config.Keepalive.Time)
+	assert.Equal(t, []string{"user.password"}, config.AccessLogRedaction.Fields)
+	assert.Equal(t, []string{"/stubs"}, config.Stubs)
+	assert.Equal(t, []grpchandler.ResponseLimit{{Method: "greeter.Greeter", MaxSize: 1024, Action: "truncate"}}, config.ResponseLimits)
+}
+
+func TestConfig_LoadFile_JSON(t *testing.T) {
+	path := writeTestFile(t, t.TempDir(), "mock.json", `{"restPort": 2068, "metrics": true, "metricsLabels": ["method"]}`)
+	config := Config{}
+	assert.Nil(t, config.LoadFile(path))
+	assert.Equal(t, uint(2068), config.RestPort)
+	assert.True(t, config.Metrics)
+	assert.Equal(t, []string{"method"}, config.MetricsLabels)
+}
+
+func TestConfig_LoadFile_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	config := Config{}
+	assert.Contains(t, config.LoadFile(writeTestFile(t, dir, "unknown.yaml", "grpcport: 1")).Error(), "field grpcport not found")
+	assert.Contains(t, config.LoadFile(writeTestFile(t, dir, "type.yaml", "grpcPort: ten")).Error(), "cannot unmarshal")
+	assert.EqualError(t, config.LoadFile(writeTestFile(t, dir, "tls.yaml", "tls: {certFile: server.pem}")),
+		"invalid configuration file "+filepath.Join(dir, "tls.yaml")+": TLS certificate and key must be provided together")
+	assert.EqualError(t, (&Config{}).LoadFile(writeTestFile(t, dir, "matching.yaml", "matchingEngine: xml")),
+		"invalid configuration file "+filepath.Join(dir, "matching.yaml")+": invalid matchingEngine xml. Use json or proto")
+	assert.EqualError(t, (&Config{}).LoadFile(writeTestFile(t, dir, "cache.yaml", "matchCacheSize: -1")),
+		"invalid configuration file "+filepath.Join(dir, "cache.yaml")+": invalid matchCacheSize -1")
+	assert.EqualError(t, (&Config{}).LoadFile(writeTestFile(t, dir, "parallel.yaml", "parallelMatchingThreshold: -1")),
+		"invalid configuration file "+filepath.Join(dir, "parallel.yaml")+": invalid parallelMatchingThreshold -1")
+	assert.EqualError(t, (&Config{}).LoadFile(writeTestFile(t, dir, "recordings.yaml", "recordingsMemoryBudget: -1")),
+		"invalid configuration file "+filepath.Join(dir, "recordings.yaml")+": invalid recordingsMemoryBudget -1")
+	assert.EqualError(t, (&Config{}).LoadFile(writeTestFile(t, dir, "depth.yaml", "maxContentDepth: -1")),
+		"invalid configuration file "+filepath.Join(dir, "depth.yaml")+": invalid maxContentDepth -1")
+	assert.EqualError(t, (&Config{}).LoadFile(writeTestFile(t, dir, "limits.yaml", "responseLimits: [{method: greeter.Greeter, action: drop}]")),
+		"invalid configuration file "+filepath.Join(dir, "limits.yaml")+": invalid response limit action 'drop' for greeter.Greeter. Use reject or truncate")
+	assert.Error(t, config.LoadFile(filepath.Join(dir, "missing.yaml")))
+	assert.Nil(t, (&Config{}).LoadFile(writeTestFile(t, dir, "empty.yaml", "")))
+}
+
+func TestConfigFile(t *testing.T) {
+	assert.Equal(t, "mock.yaml", configFile([]string{"--grpc-port", "1", "--config", "mock.yaml"}))
+	assert.Equal(t, "mock.yaml", configFile([]string{"-config=mock.yaml"}))
+	assert.Equal(t, "", configFile([]string{"--", "--config", "mock.yaml"}))
+
+	os.Setenv("MOCK_CONFIG", "env.yaml")
+	defer os.Unsetenv("MOCK_CONFIG")
+	assert.Equal(t, "env.yaml", configFile([]string{"--grpc-port", "1"}))
+}
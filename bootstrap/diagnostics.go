@@ -0,0 +1,45 @@
+package bootstrap
+
+import (
+	"expvar"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+)
+
+// withDiagnostics serves the pprof profiles under /debug/pprof/ and the runtime stats under /debug/vars in addition to the
+// REST API, so that long-running servers can be profiled in place.
+func withDiagnostics(handler http.Handler, stubsStore stub.StubsStore) http.Handler {
+	publishVar("goroutines", func() interface{} {
+		return runtime.NumGoroutine()
+	})
+	publishVar("stubs", func() interface{} {
+		return len(stubsStore.GetAllStubs())
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.Handle("/", handler)
+	return mux
+}
+
+// withMetrics serves the metrics of the gRPC calls under /metrics in addition to the REST API.
+func withMetrics(handler http.Handler, metrics *grpchandler.Metrics) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	mux.Handle("/", handler)
+	return mux
+}
+
+// publishVar publishes the variable unless it already is, as expvar does not allow replacing them.
+func publishVar(name string, f func() interface{}) {
+	if expvar.Get(name) == nil {
+		expvar.Publish(name, expvar.Func(f))
+	}
+}
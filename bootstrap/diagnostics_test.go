@@ -0,0 +1,57 @@
+package bootstrap
+
+import (
+	"encoding/json"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithDiagnostics(t *testing.T) {
+	stubsStore := stub.NewInMemoryStubsStore()
+	assert.Nil(t, stubsStore.Add(&stub.Stub{FullMethod: "/pkg.Service/Method", Request: &stub.StubRequest{Match: "exact", Content: `{}`}}))
+	restHandler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusTeapot)
+	})
+	handler := withDiagnostics(restHandler, stubsStore)
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	assert.Equal(t, http.StatusOK, response.Code)
+	vars := make(map[string]interface{})
+	assert.Nil(t, json.Unmarshal(response.Body.Bytes(), &vars))
+	assert.Equal(t, float64(1), vars["stubs"])
+	assert.Contains(t, vars, "goroutines")
+	assert.Contains(t, vars, "memstats")
+
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "heap")
+
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/stubs", nil))
+	assert.Equal(t, http.StatusTeapot, response.Code)
+}
+
+func TestWithMetrics(t *testing.T) {
+	metrics, err := grpchandler.NewMetrics([]string{grpchandler.MetricsLabelMethod}, 0)
+	assert.Nil(t, err)
+	restHandler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusTeapot)
+	})
+	handler := withMetrics(restHandler, metrics)
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "text/plain; version=0.0.4", response.Header().Get("Content-Type"))
+	assert.Contains(t, response.Body.String(), "# TYPE mock_grpc_calls_total counter")
+
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/stubs", nil))
+	assert.Equal(t, http.StatusTeapot, response.Code)
+}
@@ -1,66 +1,317 @@
 package bootstrap
 
 import (
+	"context"
 	"fmt"
 	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"google.golang.org/grpc"
+	channelz "google.golang.org/grpc/channelz/service"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
+	"io"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 )
 
 // var server grpc_server.GrpcServer
 var server *grpc.Server
-var listener net.Listener
+var listeners []net.Listener
+var httpServers []*http.Server
+var httpServersMutex sync.Mutex
 
 // Start the server for the previously registered services
 func StarGRPCServer(port uint, service grpchandler.MockService) {
+	StartGRPCServerWithConfig(Config{GrpcPort: port}, service)
+}
+
+// StartGRPCServerWithConfig starts the server for the previously registered services using the configuration provided.
+func StartGRPCServerWithConfig(config Config, service grpchandler.MockService) {
+	createGRPCServer(config, service)
+	serveGRPC(config, nil)
+}
+
+// createGRPCServer creates the gRPC server and registers the services without starting to listen.
+func createGRPCServer(config Config, service grpchandler.MockService) {
+	options, err := createServerOptions(config)
+	if err != nil {
+		log.Fatalf("Invalid gRPC server configuration: %v", err)
+	}
 
-	server = grpc.NewServer()
+	server = grpc.NewServer(options...)
 	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
-	reflection.Register(server)
+	if !config.DisableReflection {
+		reflection.Register(server)
+	}
+	if config.Channelz {
+		channelz.RegisterChannelzServiceToServer(server)
+	}
 
 	service.Register(server)
+}
 
-	var err error
-	addr := fmt.Sprintf("0.0.0.0:%d", port)
-	listener, err = net.Listen("tcp", addr)
-
-	if err != nil {
-		log.Fatalf("Failed to listen: %v", err)
+// serveGRPC starts listening with the gRPC server previously created and waits for the termination.
+// When restHandler is provided, the REST API is served on the same listeners and requests are routed by content type.
+func serveGRPC(config Config, restHandler http.Handler) {
+	for _, address := range config.grpcAddresses() {
+		listener, err := listen(address)
+		if err != nil {
+			log.Fatalf("Failed to listen on %s: %v", address, err)
+		}
+		listeners = append(listeners, listener)
+		address = listenerAddress(address, listener)
+		if config.TLS.Enabled() {
+			log.Infof("gRPC Server listening on: %s (TLS)", address)
+		} else {
+			log.Infof("gRPC Server listening on: %s", address)
+		}
+		if restHandler == nil {
+			go serv(listener)
+			continue
+		}
+		log.Infof("REST Server listening on: %s", address)
+		httpServer, err := createMultiplexServer(config, restHandler)
+		if err != nil {
+			log.Fatalf("Failed to create the server for %s: %v", address, err)
+		}
+		addHTTPServer(httpServer)
+		go servHTTP(httpServer, listener, config.TLS.Enabled())
 	}
-	log.Infof("gRPC Server listening on port: %d", port)
-	go serv(listener)
 
-	if err != nil {
-		log.Fatalf("GRPC server failed to start %+v", err)
-	}
-	AwaitTermination(func() {
+	awaitTermination(config.ShutdownGracePeriod, func() {
 		log.Warn("Shutting down the server")
+		grpchandler.CloseForwardConnections()
+		for _, hook := range config.ShutdownHooks {
+			hook()
+		}
 	})
 }
 
+// createAccessLog opens the destination of the access log. Files are appended to.
+func createAccessLog(config Config) (*grpchandler.AccessLog, error) {
+	if err := config.AccessLogRedaction.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid access log redaction: %w", err)
+	}
+	var out io.Writer
+	switch config.AccessLog {
+	case "stdout":
+		out = os.Stdout
+	case "stderr":
+		out = os.Stderr
+	default:
+		file, err := os.OpenFile(config.AccessLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("could not open the access log: %w", err)
+		}
+		out = file
+	}
+	return grpchandler.NewAccessLog(out, config.AccessLogBodySize, &config.AccessLogRedaction), nil
+}
+
+func createServerOptions(config Config) ([]grpc.ServerOption, error) {
+	options := make([]grpc.ServerOption, 0)
+	if config.TLS.Enabled() {
+		tlsConfig, err := createServerTLSConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	if config.OTLPEndpoint != "" {
+		options = append(options, grpc.StatsHandler(otelgrpc.NewServerHandler()))
+	}
+	if config.CorrelationIDKey != "" {
+		// added first so that the ID is in the context of the access log and of the other interceptors
+		correlationID := grpchandler.NewCorrelationID(config.CorrelationIDKey)
+		options = append(options, grpc.ChainUnaryInterceptor(correlationID.UnaryInterceptor), grpc.ChainStreamInterceptor(correlationID.StreamInterceptor))
+	}
+	if config.AccessLog != "" {
+		accessLog, err := createAccessLog(config)
+		if err != nil {
+			return nil, err
+		}
+		// added before the interceptors of the configuration so that the calls they reject are logged
+		options = append(options, grpc.ChainUnaryInterceptor(accessLog.UnaryInterceptor), grpc.ChainStreamInterceptor(accessLog.StreamInterceptor))
+	}
+	if len(config.UnaryInterceptors) > 0 {
+		options = append(options, grpc.ChainUnaryInterceptor(config.UnaryInterceptors...))
+	}
+	if len(config.StreamInterceptors) > 0 {
+		options = append(options, grpc.ChainStreamInterceptor(config.StreamInterceptors...))
+	}
+	if config.MaxRecvMsgSize > 0 {
+		options = append(options, grpc.MaxRecvMsgSize(config.MaxRecvMsgSize))
+	}
+	if config.MaxSendMsgSize > 0 {
+		options = append(options, grpc.MaxSendMsgSize(config.MaxSendMsgSize))
+	}
+	registerCompressors(config.Compressors)
+	if config.SendCompression != "" {
+		compressor, err := createSendCompressor(config.SendCompression)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, grpc.RPCCompressor(compressor))
+	}
+	k := config.Keepalive
+	if k.Time > 0 || k.Timeout > 0 || k.MaxConnectionAge > 0 {
+		options = append(options, grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:             k.Time,
+			Timeout:          k.Timeout,
+			MaxConnectionAge: k.MaxConnectionAge,
+		}))
+	}
+	if k.MinTime > 0 || k.PermitWithoutStream {
+		options = append(options, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             k.MinTime,
+			PermitWithoutStream: k.PermitWithoutStream,
+		}))
+	}
+	return options, nil
+}
+
 func AwaitTermination(shutdownHook func()) {
+	awaitTermination(0, shutdownHook)
+}
+
+// awaitTermination waits for SIGINT or SIGTERM and stops the servers, waiting for the in-flight calls to finish
+// for up to gracePeriod (zero waits indefinitely).
+func awaitTermination(gracePeriod time.Duration, shutdownHook func()) {
 	interruptSignal := make(chan os.Signal, 1)
 	signal.Notify(interruptSignal, syscall.SIGINT, syscall.SIGTERM)
 	<-interruptSignal
-	cleanup()
+	cleanup(gracePeriod)
 	if shutdownHook != nil {
 		shutdownHook()
 	}
+	log.Info("End of Program")
 }
 
-func cleanup() {
+func cleanup(gracePeriod time.Duration) {
+	ctx := context.Background()
+	if gracePeriod > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, gracePeriod)
+		defer cancel()
+	}
 	log.Info("Stopping the server")
-	server.GracefulStop()
-	log.Info("Closing the listener")
-	listener.Close()
-	log.Info("End of Program")
+	for _, httpServer := range getHTTPServers() {
+		if err := httpServer.Shutdown(ctx); err != nil {
+			log.Warnf("HTTP server not stopped gracefully: %v", err)
+		}
+	}
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Warnf("In-flight calls not finished after %s. Forcing the server to stop", gracePeriod)
+		server.Stop()
+	}
+	log.Info("Closing the listeners")
+	for _, listener := range listeners {
+		listener.Close()
+	}
+}
+
+func addHTTPServer(httpServer *http.Server) {
+	httpServersMutex.Lock()
+	defer httpServersMutex.Unlock()
+
+	httpServers = append(httpServers, httpServer)
+}
+
+func getHTTPServers() []*http.Server {
+	httpServersMutex.Lock()
+	defer httpServersMutex.Unlock()
+
+	return append([]*http.Server{}, httpServers...)
+}
+
+// listen creates the listener for the address, which is either host:port, tcp://host:port or unix:///path/to/socket.
+// A socket file left behind by a previous run is removed.
+func listen(address string) (net.Listener, error) {
+	network, addr := parseListenAddress(address)
+	if network == "unix" {
+		if info, err := os.Stat(addr); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(addr)
+		}
+	}
+	return net.Listen(network, addr)
+}
+
+// listenerAddress returns the address for the logs, with the port picked by the system when the port requested was 0.
+func listenerAddress(address string, listener net.Listener) string {
+	network, addr := parseListenAddress(address)
+	if _, port, err := net.SplitHostPort(addr); network == "unix" || err != nil || port != "0" {
+		return address
+	}
+	return listener.Addr().String()
+}
+
+func parseListenAddress(address string) (network, addr string) {
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		return "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "unix:"):
+		return "unix", strings.TrimPrefix(address, "unix:")
+	case strings.HasPrefix(address, "tcp://"):
+		return "tcp", strings.TrimPrefix(address, "tcp://")
+	}
+	return "tcp", address
+}
+
+// createMultiplexServer creates an HTTP server that sends the gRPC requests to the gRPC server and everything else to the REST API.
+// HTTP/2 without TLS (h2c) is supported so that plaintext gRPC clients can connect.
+func createMultiplexServer(config Config, restHandler http.Handler) (*http.Server, error) {
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		contentType := request.Header.Get("Content-Type")
+		if request.ProtoMajor == 2 && strings.HasPrefix(contentType, "application/grpc") && !strings.HasPrefix(contentType, "application/grpc-web") {
+			server.ServeHTTP(writer, request)
+			return
+		}
+		restHandler.ServeHTTP(writer, request)
+	})
+	httpServer := &http.Server{Handler: h2c.NewHandler(handler, &http2.Server{})}
+	if config.TLS.Enabled() {
+		tlsConfig, err := createServerTLSConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+		httpServer.TLSConfig = tlsConfig
+		if err := http2.ConfigureServer(httpServer, nil); err != nil {
+			return nil, err
+		}
+	}
+	return httpServer, nil
+}
+
+func servHTTP(httpServer *http.Server, listener net.Listener, useTLS bool) {
+	var err error
+	if useTLS {
+		err = httpServer.ServeTLS(listener, "", "")
+	} else {
+		err = httpServer.Serve(listener)
+	}
+	if err != nil && err != http.ErrServerClosed {
+		log.Errorf("failed to serve: %v", err)
+	}
 }
 
 func serv(listener net.Listener) {
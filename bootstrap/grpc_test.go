@@ -0,0 +1,125 @@
+package bootstrap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseListenAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		network string
+		addr    string
+	}{
+		{"127.0.0.1:10010", "tcp", "127.0.0.1:10010"},
+		{"tcp://0.0.0.0:10010", "tcp", "0.0.0.0:10010"},
+		{"unix:///tmp/mock.sock", "unix", "/tmp/mock.sock"},
+		{"unix:mock.sock", "unix", "mock.sock"},
+	}
+	for _, test := range tests {
+		network, addr := parseListenAddress(test.address)
+		assert.Equal(t, test.network, network, test.address)
+		assert.Equal(t, test.addr, addr, test.address)
+	}
+}
+
+func TestCreateSendCompressor(t *testing.T) {
+	compressor, err := createSendCompressor("gzip")
+	assert.Nil(t, err)
+	assert.Equal(t, "gzip", compressor.Type())
+
+	buffer := new(bytes.Buffer)
+	assert.Nil(t, compressor.Do(buffer, []byte("hello")))
+	reader, err := gzip.NewReader(buffer)
+	assert.Nil(t, err)
+	data, _ := ioutil.ReadAll(reader)
+	assert.Equal(t, "hello", string(data))
+
+	_, err = createSendCompressor("unknown")
+	assert.EqualError(t, err, "compressor 'unknown' is not registered")
+}
+
+func TestConfig_Addresses(t *testing.T) {
+	config := Config{RestPort: 1068, GrpcPort: 10010}
+	assert.Equal(t, []string{"0.0.0.0:10010"}, config.grpcAddresses())
+	assert.Equal(t, "0.0.0.0:1068", config.restAddress())
+
+	config.BindAddress = "127.0.0.1"
+	assert.Equal(t, []string{"127.0.0.1:10010"}, config.grpcAddresses())
+	assert.Equal(t, "127.0.0.1:1068", config.restAddress())
+
+	config.GrpcListen = []string{"unix:///tmp/mock.sock"}
+	config.RestListen = "localhost:8080"
+	assert.Equal(t, []string{"unix:///tmp/mock.sock"}, config.grpcAddresses())
+	assert.Equal(t, "localhost:8080", config.restAddress())
+}
+
+func TestConfig_LoadEnv(t *testing.T) {
+	os.Setenv("MOCK_GRPC_PORT", "20010")
+	os.Setenv("MOCK_BIND_ADDRESS", "127.0.0.1")
+	os.Setenv("MOCK_GRPC_LISTEN", "127.0.0.1:1,unix:///tmp/mock.sock")
+	defer os.Unsetenv("MOCK_GRPC_PORT")
+	defer os.Unsetenv("MOCK_BIND_ADDRESS")
+	os.Setenv("MOCK_ACCESS_LOG", "/tmp/access.log")
+	defer os.Unsetenv("MOCK_GRPC_LISTEN")
+	defer os.Unsetenv("MOCK_ACCESS_LOG")
+
+	config := Config{RestPort: 1068, GrpcPort: 10010}
+	assert.Nil(t, config.LoadEnv())
+	assert.Equal(t, uint(20010), config.GrpcPort)
+	assert.Equal(t, uint(1068), config.RestPort)
+	assert.Equal(t, "127.0.0.1", config.BindAddress)
+	assert.Equal(t, []string{"127.0.0.1:1", "unix:///tmp/mock.sock"}, config.GrpcListen)
+	assert.Equal(t, "/tmp/access.log", config.AccessLog)
+
+	os.Setenv("MOCK_REST_PORT", "rest")
+	defer os.Unsetenv("MOCK_REST_PORT")
+	assert.EqualError(t, config.LoadEnv(), "invalid value 'rest' for MOCK_REST_PORT")
+}
+
+func TestConfig_LoadEnv_AllSettings(t *testing.T) {
+	env := map[string]string{
+		"MOCK_SHUTDOWN_GRACE_PERIOD":       "10s",
+		"MOCK_METRICS":                     "true",
+		"MOCK_METRICS_LABELS":              "method,stub_id",
+		"MOCK_ACCESS_LOG_REDACT_FIELD":     "password,user.token",
+		"MOCK_UNMATCHED_CALLS_THRESHOLD":   "",
+		"MOCK_CORRELATION_ID_KEY":          "x-trace",
+		"MOCK_PARALLEL_MATCHING_THRESHOLD": "64",
+		"OTEL_EXPORTER_OTLP_ENDPOINT":      "localhost:4317",
+	}
+	for name, value := range env {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	config := Config{ShutdownGracePeriod: 30 * time.Second, UnmatchedCallsThreshold: 5, AccessLogRedaction: stub.RecordingRedaction{Fields: []string{"secret"}}}
+	assert.Nil(t, config.LoadEnv())
+	assert.Equal(t, 10*time.Second, config.ShutdownGracePeriod)
+	assert.True(t, config.Metrics)
+	assert.Equal(t, []string{"method", "stub_id"}, config.MetricsLabels)
+	assert.Equal(t, []string{"password", "user.token"}, config.AccessLogRedaction.Fields)
+	assert.Equal(t, 5, config.UnmatchedCallsThreshold)
+	assert.Equal(t, "x-trace", config.CorrelationIDKey)
+	assert.Equal(t, "localhost:4317", config.OTLPEndpoint)
+	assert.Equal(t, 64, config.ParallelMatchingThreshold)
+
+	os.Setenv("MOCK_METRICS", "maybe")
+	assert.EqualError(t, config.LoadEnv(), "invalid value 'maybe' for MOCK_METRICS")
+}
+
+func TestConfig_RegisterFlags_MetricsLabels(t *testing.T) {
+	config := Config{MetricsLabels: []string{"method", "code"}}
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	config.RegisterFlags(flags)
+	assert.Nil(t, flags.Parse([]string{"--metrics", "--metrics-labels", "method, stub_id"}))
+	assert.True(t, config.Metrics)
+	assert.Equal(t, []string{"method", "stub_id"}, config.MetricsLabels)
+}
@@ -0,0 +1,24 @@
+package bootstrap
+
+import (
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
+	"net/http"
+)
+
+// wrapGrpcWeb serves the gRPC-Web requests, including the CORS pre-flight requests, with the gRPC server and
+// everything else with the handler. Requests from any origin are accepted since the mock is meant for testing.
+func wrapGrpcWeb(handler http.Handler) http.Handler {
+	grpcWeb := grpcweb.WrapServer(server,
+		grpcweb.WithOriginFunc(func(origin string) bool {
+			return true
+		}),
+		grpcweb.WithAllowedRequestHeaders([]string{"*"}),
+	)
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if grpcWeb.IsGrpcWebRequest(request) || grpcWeb.IsAcceptableGrpcCorsRequest(request) {
+			grpcWeb.ServeHTTP(writer, request)
+			return
+		}
+		handler.ServeHTTP(writer, request)
+	})
+}
@@ -0,0 +1,116 @@
+package bootstrap
+
+import (
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/restcontrollers"
+	log "github.com/sirupsen/logrus"
+	"runtime/debug"
+	"strings"
+)
+
+const modulePath = "github.com/carvalhorr/protoc-gen-mock"
+
+// createServerInfo describes the mock services of the sets, grouped by gRPC service, and the features enabled in the
+// configuration. It is shown in /admin/info and in the startup banner.
+func createServerInfo(config Config, sets []*mountedServiceSet) restcontrollers.ServerInfo {
+	return restcontrollers.ServerInfo{Version: serverVersion(), Services: servicesInfo(sets), Features: features(config, len(sets))}
+}
+
+// servicesInfo returns the gRPC services of the sets with their methods and how they were generated, when known.
+func servicesInfo(sets []*mountedServiceSet) []restcontrollers.ServiceInfo {
+	services := []restcontrollers.ServiceInfo{}
+	for _, set := range sets {
+		generation := make(map[string]grpchandler.GenerationInfo)
+		for _, g := range grpchandler.GetGenerationInfo(set.service) {
+			generation[g.Service] = g
+		}
+		indexes := make(map[string]int)
+		for _, method := range set.service.GetSupportedMethods() {
+			name := strings.TrimPrefix(method[:strings.LastIndex(method, "/")], "/")
+			i, ok := indexes[name]
+			if !ok {
+				i = len(services)
+				indexes[name] = i
+				serviceInfo := restcontrollers.ServiceInfo{Name: name, ServiceSet: set.Name}
+				if g, ok := generation[name]; ok {
+					serviceInfo.Generation = &g
+				}
+				services = append(services, serviceInfo)
+			}
+			services[i].Methods = append(services[i].Methods, method)
+		}
+	}
+	return services
+}
+
+// logBanner logs the version of the server, the number of services and methods mocked and the features enabled.
+func logBanner(info restcontrollers.ServerInfo) {
+	methods := 0
+	for _, service := range info.Services {
+		methods += len(service.Methods)
+	}
+	features := "none"
+	if len(info.Features) > 0 {
+		features = strings.Join(info.Features, ", ")
+	}
+	log.Infof("protoc-gen-mock %s: %d services, %d methods, features: %s", info.Version, len(info.Services), methods, features)
+}
+
+// serverVersion returns the version of protoc-gen-mock the server was built with.
+func serverVersion() string {
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if buildInfo.Main.Path == modulePath {
+		return buildInfo.Main.Version
+	}
+	for _, dep := range buildInfo.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
+// features returns the optional features enabled in the configuration.
+func features(config Config, serviceSets int) []string {
+	enabled := []string{}
+	add := func(feature string, on bool) {
+		if on {
+			enabled = append(enabled, feature)
+		}
+	}
+	add("tls", config.TLS.Enabled())
+	add("mtls", config.TLS.Enabled() && config.TLS.ClientCAFile != "")
+	add("single-port", config.SinglePort)
+	add("grpc-web", config.GrpcWeb)
+	add("reflection", !config.DisableReflection)
+	add("channelz", config.Channelz)
+	add("metrics", config.Metrics)
+	add("pprof", config.Pprof)
+	add("tracing", config.OTLPEndpoint != "")
+	add("access-log", config.AccessLog != "")
+	add("correlation-id", config.CorrelationIDKey != "")
+	add("proxy-fallback", config.ProxyFallback != "" || hasSetProxyFallback(config))
+	add("unmatched-calls-alert", config.UnmatchedCallsThreshold > 0)
+	add("rate-limit", config.RateLimit > 0)
+	add("concurrency-limit", config.MaxConcurrentCalls > 0)
+	add("response-limits", len(config.ResponseLimits) > 0)
+	add("strict-startup", config.StrictStartup)
+	add("reject-shadowed-stubs", config.RejectShadowedStubs)
+	add("service-sets", serviceSets > 1)
+	return enabled
+}
+
+func hasSetProxyFallback(config Config) bool {
+	for _, set := range config.ServiceSets {
+		if set.ProxyFallback != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package bootstrap
+
+import (
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/restcontrollers"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type fakeGeneratedService struct {
+	fakeMockService
+	methods []string
+}
+
+func (f fakeGeneratedService) GetSupportedMethods() []string {
+	return f.methods
+}
+
+func (f fakeGeneratedService) GetGenerationInfo() []grpchandler.GenerationInfo {
+	return []grpchandler.GenerationInfo{{Service: "orders.Orders", PluginVersion: "v1.2.3", ProtoFile: "orders.proto", ProtoFileHash: "abc"}}
+}
+
+func TestCreateServerInfo(t *testing.T) {
+	sets := []*mountedServiceSet{
+		{ServiceSet: ServiceSet{Name: "orders"}, service: fakeGeneratedService{methods: []string{"/orders.Orders/Get", "/orders.Orders/List", "/orders.Items/Get"}}},
+		{ServiceSet: ServiceSet{Name: "users"}, service: fakeSetService{method: "/users.Users/Get"}},
+	}
+	config := Config{Metrics: true, DisableReflection: true, ServiceSets: []ServiceSetConfig{{Name: "users", ProxyFallback: "users.staging:443"}}}
+
+	info := createServerInfo(config, sets)
+	assert.NotEmpty(t, info.Version)
+	assert.Equal(t, []restcontrollers.ServiceInfo{
+		{
+			Name:       "orders.Orders",
+			ServiceSet: "orders",
+			Methods:    []string{"/orders.Orders/Get", "/orders.Orders/List"},
+			Generation: &grpchandler.GenerationInfo{Service: "orders.Orders", PluginVersion: "v1.2.3", ProtoFile: "orders.proto", ProtoFileHash: "abc"},
+		},
+		{Name: "orders.Items", ServiceSet: "orders", Methods: []string{"/orders.Items/Get"}},
+		{Name: "users.Users", ServiceSet: "users", Methods: []string{"/users.Users/Get"}},
+	}, info.Services)
+	assert.Equal(t, []string{"metrics", "proxy-fallback", "service-sets"}, info.Features)
+}
+
+func TestFeatures_Defaults(t *testing.T) {
+	assert.Equal(t, []string{"reflection", "correlation-id"}, features(Config{CorrelationIDKey: "x-request-id"}, 1))
+	assert.Equal(t, []string{"tls", "mtls", "reflection"}, features(Config{TLS: TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", ClientCAFile: "ca.pem"}}, 1))
+}
@@ -0,0 +1,26 @@
+package bootstrap
+
+import (
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+)
+
+// writeReport writes the JUnit report of the unmatched requests and the stubs of all the sets to the file.
+func writeReport(path string, sets []*mountedServiceSet) error {
+	stores := make([]stub.StubsStore, 0, len(sets))
+	for _, set := range sets {
+		stores = append(stores, set.stubsStore)
+	}
+	report := stub.NewReport(grpchandler.GetUnmatchedRequests(), stores...)
+	data, err := report.JUnit()
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	log.Infof("Report written to %s: %d unmatched requests and %d unused stubs", path, len(report.UnmatchedRequests), len(report.UnusedStubs()))
+	return nil
+}
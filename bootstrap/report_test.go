@@ -0,0 +1,29 @@
+package bootstrap
+
+import (
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteReport(t *testing.T) {
+	grpchandler.ResetUnmatchedRequests()
+	orders, users := stub.NewInMemoryStubsStore(), stub.NewInMemoryStubsStore()
+	orders.Add(&stub.Stub{FullMethod: "/orders.Orders/Get", Type: "mock", Request: &stub.StubRequest{Match: "exact", Content: `{"id":"1"}`}})
+	users.Add(&stub.Stub{FullMethod: "/users.Users/Get", Type: "mock", Request: &stub.StubRequest{Match: "exact", Content: `{"id":"2"}`}})
+	users.RecordMatch(users.GetAllStubs()[0])
+	sets := []*mountedServiceSet{{stubsStore: orders}, {stubsStore: users}}
+	path := filepath.Join(t.TempDir(), "report.xml")
+
+	assert.Nil(t, writeReport(path, sets))
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `<testsuites name="protoc-gen-mock" tests="2" failures="1">`)
+	assert.Contains(t, string(data), `<testcase classname="orders.Orders" name="Get {&#34;id&#34;:&#34;1&#34;}">`)
+	assert.Contains(t, string(data), `<testcase classname="users.Users" name="Get {&#34;id&#34;:&#34;2&#34;}"></testcase>`)
+
+	assert.NotNil(t, writeReport(filepath.Join(t.TempDir(), "missing", "report.xml"), sets))
+}
@@ -11,8 +11,28 @@ import (
 )
 
 func StartRESTServer(port uint, controllers []restcontrollers.RESTController) {
-	log.Infof("REST Server listening on port: %d", port)
+	startRESTServer(fmt.Sprintf(":%d", port), CreateRESTRouter(controllers))
+}
+
+func startRESTServer(address string, handler http.Handler) {
+	listener, err := listen(address)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", address, err)
+	}
+	log.Infof("REST Server listening on: %s", listenerAddress(address, listener))
+
+	restServer := &http.Server{
+		Handler: handler,
+	}
+	// registered so that it is stopped gracefully with the gRPC server
+	addHTTPServer(restServer)
+	if err := restServer.Serve(listener); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
 
+// CreateRESTRouter creates the handler serving the REST API of the controllers.
+func CreateRESTRouter(controllers []restcontrollers.RESTController) http.Handler {
 	r := mux.NewRouter()
 	for _, controller := range controllers {
 		api := r.PathPrefix(controller.GetPath()).Subrouter()
@@ -20,24 +40,75 @@ func StartRESTServer(port uint, controllers []restcontrollers.RESTController) {
 			api.HandleFunc(handler.Path, handler.Handler).Methods(handler.Methods...)
 		}
 	}
-
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), r))
+	return r
 }
 
+// maxAuditEntries is the number of changes to the stubs kept in the audit log.
+const maxAuditEntries = 1000
+
 func CreateRESTControllers(
 	stubExamples []stub.Stub,
 	stubsStore stub.StubsStore,
 	recordingsStore stub.RecordingsStore,
 	service grpchandler.MockService) []restcontrollers.RESTController {
+	info := restcontrollers.ServerInfo{
+		Version:  serverVersion(),
+		Services: servicesInfo([]*mountedServiceSet{{service: service}}),
+		Features: []string{},
+	}
+	return createRESTControllers(stubExamples, stubsStore, stub.NewStubsMatcher(stubsStore), recordingsStore, service, info, false)
+}
+
+// createRESTControllers creates the controllers of the REST API, with the description of the server shown in /admin/info.
+// The transcoded calls are matched by stubsMatcher, as the gRPC calls. The shadowed stubs are rejected when
+// rejectShadowedStubs is true.
+func createRESTControllers(
+	stubExamples []stub.Stub,
+	stubsStore stub.StubsStore,
+	stubsMatcher stub.StubsMatcher,
+	recordingsStore stub.RecordingsStore,
+	service grpchandler.MockService,
+	info restcontrollers.ServerInfo,
+	rejectShadowedStubs bool) []restcontrollers.RESTController {
+	auditLog := stub.NewAuditLog(maxAuditEntries)
 	return []restcontrollers.RESTController{
 		restcontrollers.ExamplesController{StubExamples: stubExamples},
 		restcontrollers.StubsController{
-			StubsStore:   stubsStore,
-			StubExamples: stubExamples,
-			Service:      service,
+			StubsStore:          stubsStore,
+			StubExamples:        stubExamples,
+			Service:             service,
+			AuditLog:            auditLog,
+			RejectShadowedStubs: rejectShadowedStubs,
 		},
 		restcontrollers.RecordingsController{
 			RecordingsStore: recordingsStore,
 		},
+		restcontrollers.VerificationsController{
+			StubsStore: stubsStore,
+			Service:    service,
+			AuditLog:   auditLog,
+		},
+		restcontrollers.ScenariosController{
+			StubsStore: stubsStore,
+		},
+		restcontrollers.AuditController{
+			AuditLog: auditLog,
+		},
+		restcontrollers.ReadinessController{
+			UnmatchedCalls: grpchandler.GetUnmatchedCallsAlert(),
+		},
+		restcontrollers.UpstreamsController{
+			Upstreams: grpchandler.GetUpstreamsHealth(),
+		},
+		restcontrollers.AdminController{
+			Latency: grpchandler.GetLatencyStats(),
+			Chaos:   grpchandler.GetChaos(),
+			Info:    info,
+		},
+		// registered last so that the REST API takes precedence over the transcoded endpoints
+		restcontrollers.TranscodingController{
+			StubsMatcher: stubsMatcher,
+			Service:      service,
+		},
 	}
 }
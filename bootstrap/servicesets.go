@@ -0,0 +1,122 @@
+package bootstrap
+
+import (
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/gorilla/mux"
+	"net/http"
+	"regexp"
+)
+
+// ServiceSet is a group of mock services, e.g. the ones generated for an API, with its own stubs. Several sets can be served
+// by the same mock server with BootstrapServiceSets.
+type ServiceSet struct {
+	// Name of the set, used in the path of its REST API, /sets/<name>, and to find its settings in Config.ServiceSets
+	Name string
+	// Called when the gRPC server is ready so that the mock services of the set can be registered
+	Register func(stubsMatcher stub.StubsMatcher) grpchandler.MockService
+}
+
+// ServiceSetConfig holds the settings of a service set. Only the REST API can be served on an address of the set: the gRPC
+// services of all the sets are served on the addresses of the server, Config.GrpcPort or Config.GrpcListen, with its
+// settings, e.g. its TLS and its interceptors.
+type ServiceSetConfig struct {
+	Name string `yaml:"name"`
+	// Address the REST API of the set is also served on, e.g. :1069 or unix:///tmp/orders.sock
+	RestListen string `yaml:"restListen"`
+	// Address of a real server where the calls of the set without a matching stub are forwarded to, instead of
+	// Config.ProxyFallback. It is the only behaviour of the calls that can be set per set.
+	ProxyFallback string `yaml:"proxyFallback"`
+	// Files of stubs in JSON, or directories with .json files of stubs, loaded in the set at startup
+	Stubs []string `yaml:"stubs"`
+}
+
+var serviceSetName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// mountedServiceSet is a service set with its mock services registered and its own stubs store.
+type mountedServiceSet struct {
+	ServiceSet
+	config       ServiceSetConfig
+	service      grpchandler.MockService
+	stubsStore   stub.StubsStore
+	stubsMatcher stub.StubsMatcher
+	restHandler  http.Handler
+}
+
+// mountServiceSets registers the mock services of the sets, each with its own stubs store. The stubs of the configuration,
+// Config.Stubs, are loaded in the first set, which is the one served by the REST API without the /sets/<name> prefix.
+func mountServiceSets(config Config, sets []ServiceSet, recordingsStore stub.RecordingsStore) ([]*mountedServiceSet, error) {
+	if len(sets) == 0 {
+		return nil, fmt.Errorf("no service sets")
+	}
+	configs := make(map[string]ServiceSetConfig)
+	for _, setConfig := range config.ServiceSets {
+		configs[setConfig.Name] = setConfig
+	}
+	names := make(map[string]bool)
+	for _, set := range sets {
+		if len(sets) > 1 && !serviceSetName.MatchString(set.Name) {
+			return nil, fmt.Errorf("invalid service set name '%s'. Use letters, digits, '.', '_' and '-'", set.Name)
+		}
+		if names[set.Name] {
+			return nil, fmt.Errorf("service set %s is defined twice", set.Name)
+		}
+		names[set.Name] = true
+	}
+	for name := range configs {
+		if !names[name] {
+			return nil, fmt.Errorf("unknown service set %s in the configuration", name)
+		}
+	}
+
+	matcherOptions, err := config.matcherOptions()
+	if err != nil {
+		return nil, err
+	}
+	mounted := make([]*mountedServiceSet, 0, len(sets))
+	for i, set := range sets {
+		m := &mountedServiceSet{ServiceSet: set, config: configs[set.Name], stubsStore: stub.NewInMemoryStubsStore()}
+		m.stubsMatcher = stub.NewStubsMatcher(m.stubsStore, matcherOptions...)
+		m.service = set.Register(m.stubsMatcher)
+		if i == 0 {
+			m.config.Stubs = append(append([]string{}, config.Stubs...), m.config.Stubs...)
+		}
+		if err := grpchandler.AddServiceSet(set.Name, m.service, m.stubsStore, m.config.ProxyFallback); err != nil {
+			return nil, err
+		}
+		mounted = append(mounted, m)
+	}
+	// the REST API of every set describes all the sets
+	info := createServerInfo(config, mounted)
+	for _, m := range mounted {
+		m.restHandler = CreateRESTRouter(createRESTControllers(m.service.GetPayloadExamples(), m.stubsStore, m.stubsMatcher, recordingsStore, m.service, info, config.RejectShadowedStubs))
+	}
+	return mounted, nil
+}
+
+// createServiceSetsRESTHandler serves the REST API of each set under /sets/<name>, and the one of the first set without prefix.
+func createServiceSetsRESTHandler(sets []*mountedServiceSet) http.Handler {
+	if len(sets) == 1 {
+		return sets[0].restHandler
+	}
+	router := mux.NewRouter()
+	for _, set := range sets {
+		prefix := "/sets/" + set.Name
+		router.PathPrefix(prefix + "/").Handler(http.StripPrefix(prefix, set.restHandler))
+	}
+	router.PathPrefix("/").Handler(sets[0].restHandler)
+	return router
+}
+
+// combinedService returns the mock services of all the sets, registered in the same gRPC server.
+func combinedService(sets []*mountedServiceSet) grpchandler.MockService {
+	if len(sets) == 1 {
+		return sets[0].service
+	}
+	services := make([]grpchandler.MockService, 0, len(sets))
+	for _, set := range sets {
+		services = append(services, set.service)
+	}
+	return grpchandler.NewCompositeMockService(services)
+}
@@ -0,0 +1,94 @@
+package bootstrap
+
+import (
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeSetService struct {
+	fakeMockService
+	method string
+}
+
+func (f fakeSetService) GetSupportedMethods() []string {
+	return []string{f.method}
+}
+
+func (f fakeSetService) GetPayloadExamples() []stub.Stub {
+	return []stub.Stub{}
+}
+
+func (f fakeSetService) GetRequestInstance(methodName string) proto.Message {
+	if methodName != f.method {
+		return nil
+	}
+	return new(wrapperspb.StringValue)
+}
+
+func (f fakeSetService) GetStubsValidator() stub.StubsValidator {
+	return f
+}
+
+func testServiceSet(name, method string) ServiceSet {
+	return ServiceSet{Name: name, Register: func(stubsMatcher stub.StubsMatcher) grpchandler.MockService {
+		return fakeSetService{method: method}
+	}}
+}
+
+func TestMountServiceSets(t *testing.T) {
+	dir := t.TempDir()
+	ordersStubs := writeTestFile(t, dir, "orders.json", `{"fullMethod": "/orders.Orders/Get", "type": "mock",
+		"request": {"match": "exact", "content": "1"}, "response": {"type": "success", "content": "order 1"}}`)
+	usersStubs := writeTestFile(t, dir, "users.json", `{"fullMethod": "/users.Users/Get", "type": "mock",
+		"request": {"match": "exact", "content": "John"}, "response": {"type": "success", "content": "user John"}}`)
+	config := Config{
+		Stubs:       []string{ordersStubs},
+		ServiceSets: []ServiceSetConfig{{Name: "users", Stubs: []string{usersStubs}, ProxyFallback: "users.staging:443"}},
+	}
+
+	sets, err := mountServiceSets(config, []ServiceSet{testServiceSet("orders", "/orders.Orders/Get"), testServiceSet("users", "/users.Users/Get")}, stub.NewRecordingsStore())
+	assert.Nil(t, err)
+	if !assert.Equal(t, 2, len(sets)) {
+		return
+	}
+	assert.Equal(t, []string{ordersStubs}, sets[0].config.Stubs)
+	assert.Equal(t, []string{usersStubs}, sets[1].config.Stubs)
+	assert.Equal(t, "users.staging:443", sets[1].config.ProxyFallback)
+	for _, set := range sets {
+		assert.Nil(t, loadStubFiles(set.config.Stubs, set.service, set.stubsStore, true, false))
+		assert.Equal(t, 1, len(set.stubsStore.GetAllStubs()))
+	}
+	assert.Equal(t, []string{"/orders.Orders/Get", "/users.Users/Get"}, combinedService(sets).GetSupportedMethods())
+
+	handler := createServiceSetsRESTHandler(sets)
+	for path, method := range map[string]string{"/stubs": "/orders.Orders/Get", "/sets/orders/stubs": "/orders.Orders/Get", "/sets/users/stubs": "/users.Users/Get"} {
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, 200, response.Code, path)
+		assert.Contains(t, response.Body.String(), method, path)
+	}
+}
+
+func TestMountServiceSets_Errors(t *testing.T) {
+	recordingsStore := stub.NewRecordingsStore()
+	_, err := mountServiceSets(Config{}, nil, recordingsStore)
+	assert.EqualError(t, err, "no service sets")
+
+	_, err = mountServiceSets(Config{}, []ServiceSet{testServiceSet("a/b", "/a.A/Get"), testServiceSet("c", "/c.C/Get")}, recordingsStore)
+	assert.EqualError(t, err, "invalid service set name 'a/b'. Use letters, digits, '.', '_' and '-'")
+
+	_, err = mountServiceSets(Config{}, []ServiceSet{testServiceSet("a", "/a.A/Get"), testServiceSet("a", "/c.C/Get")}, recordingsStore)
+	assert.EqualError(t, err, "service set a is defined twice")
+
+	_, err = mountServiceSets(Config{ServiceSets: []ServiceSetConfig{{Name: "b"}}}, []ServiceSet{testServiceSet("a", "/a.A/Get")}, recordingsStore)
+	assert.EqualError(t, err, "unknown service set b in the configuration")
+
+	_, err = mountServiceSets(Config{}, []ServiceSet{testServiceSet("first", "/same.Same/Get"), testServiceSet("second", "/same.Same/Get")}, recordingsStore)
+	assert.EqualError(t, err, "method /same.Same/Get is in the service sets first and second")
+}
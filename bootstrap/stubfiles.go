@@ -0,0 +1,192 @@
+package bootstrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// AddStub validates and adds the stub to the store as the REST API does. The stubs shadowing or shadowed by another stub
+// are rejected when rejectShadowed is true, and logged otherwise.
+func AddStub(service grpchandler.MockService, stubsStore stub.StubsStore, newStub *stub.Stub, rejectShadowed bool) error {
+	return addStub(service, stubsStore, newStub, rejectShadowed)
+}
+
+// addStub validates and adds the stub as the REST API does. The stubs shadowing or shadowed by another stub are rejected
+// when rejectShadowed is true, and logged otherwise.
+func addStub(service grpchandler.MockService, stubsStore stub.StubsStore, newStub *stub.Stub, rejectShadowed bool) error {
+	if service.GetRequestInstance(newStub.FullMethod) == nil {
+		return fmt.Errorf("method %s is not supported", newStub.FullMethod)
+	}
+	if isValid, errorMessages := service.GetStubsValidator().IsValid(newStub); !isValid {
+		return fmt.Errorf("invalid stub: %s", strings.Join(errorMessages, " "))
+	}
+	if err := cleanStub(service, newStub); err != nil {
+		return err
+	}
+	if stubsStore.Exists(newStub) {
+		return fmt.Errorf("stub already exists")
+	}
+	if warnings := stub.FindShadowing(stubsStore, newStub); len(warnings) > 0 {
+		if rejectShadowed {
+			return fmt.Errorf("stub shadowed: %s", strings.Join(warnings, "; "))
+		}
+		for _, warning := range warnings {
+			log.Warnf("Stub %s -> %s: %s", newStub.FullMethod, newStub.Request.String(), warning)
+		}
+	}
+	return stubsStore.Add(newStub)
+}
+
+// cleanStub formats the content as the messages are marshalled so that it can be compared with the incoming requests.
+func cleanStub(service grpchandler.MockService, newStub *stub.Stub) (err error) {
+	original := newStub.Request.Content
+	if newStub.Request.Content, err = cleanJsonContent(original, service.GetRequestInstance(newStub.FullMethod)); err != nil {
+		return err
+	}
+	if newStub.Request.EmptyArrays == "empty" {
+		descriptor := service.GetRequestInstance(newStub.FullMethod).(proto.Message).ProtoReflect().Descriptor()
+		if newStub.Request.Content, err = stub.RestoreEmptyArrays(original, newStub.Request.Content, descriptor); err != nil {
+			return err
+		}
+	}
+	// the content with templates is only valid once rendered for a call
+	if newStub.Type == "mock" && newStub.Response.Type == "success" && !newStub.Response.Content.HasTemplates() {
+		newStub.Response.Content, err = cleanJsonContent(newStub.Response.Content, service.GetResponseInstance(newStub.FullMethod))
+	}
+	return err
+}
+
+// LoadStubFiles adds the stubs of the JSON files, or of the .json files of the directories, to the store. The error
+// returned lists the stubs that are not valid, or shadowed when rejectShadowed is true, the others are still added.
+func LoadStubFiles(service grpchandler.MockService, stubsStore stub.StubsStore, rejectShadowed bool, paths ...string) error {
+	return loadStubFiles(paths, service, stubsStore, true, rejectShadowed)
+}
+
+// loadStubFiles adds the stubs of the files, and of the .json files of the directories, in alphabetical order. A file
+// contains either a stub or a list of stubs. The stubs that can't be added, including the shadowed ones when
+// rejectShadowed is true, are logged and skipped. When strict is true all the stubs are still checked, and an error
+// listing the ones that can't be added is returned.
+func loadStubFiles(paths []string, service grpchandler.MockService, stubsStore stub.StubsStore, strict, rejectShadowed bool) error {
+	invalidStubs := make([]string, 0)
+	for _, path := range paths {
+		files, err := stubFiles(path)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			stubs, err := readStubFile(file)
+			if err != nil {
+				return err
+			}
+			for i, s := range stubs {
+				if err := addStub(service, stubsStore, s, rejectShadowed); err != nil {
+					log.Warnf("Stub %d of %s not loaded: %s", i+1, file, err)
+					invalidStubs = append(invalidStubs, fmt.Sprintf("stub %d of %s: %s", i+1, file, err))
+				}
+			}
+			log.Infof("Loaded the stubs of %s", file)
+		}
+	}
+	if strict && len(invalidStubs) > 0 {
+		return fmt.Errorf("%d stubs could not be loaded:\n  %s", len(invalidStubs), strings.Join(invalidStubs, "\n  "))
+	}
+	return nil
+}
+
+// VerifyStub checks how many times the stub of the store for the request was matched. The request content is formatted
+// as the one of the stubs added.
+func VerifyStub(service grpchandler.MockService, stubsStore stub.StubsStore, verification stub.StubVerification) (stub.StubVerificationResult, error) {
+	if verification.Request == nil {
+		return stub.StubVerificationResult{}, fmt.Errorf("request can't be empty")
+	}
+	request := *verification.Request
+	content, err := cleanJsonContent(request.Content, service.GetRequestInstance(verification.FullMethod))
+	if err != nil {
+		return stub.StubVerificationResult{}, err
+	}
+	request.Content = content
+	verification.Request = &request
+	return verification.Verify(stubsStore.GetMatchCount(&stub.Stub{FullMethod: verification.FullMethod, Request: &request})), nil
+}
+
+func cleanJsonContent(content stub.JsonString, instance interface{}) (stub.JsonString, error) {
+	message, ok := instance.(proto.Message)
+	if !ok {
+		return content, fmt.Errorf("method not supported")
+	}
+	if err := protojson.Unmarshal([]byte(content), message); err != nil {
+		return content, fmt.Errorf("invalid content: %w", err)
+	}
+	data, err := protojson.Marshal(message)
+	return stub.JsonString(data), err
+}
+
+// ReadStubFiles returns the stubs of the JSON file, or of the .json files of the directory, in the formats loaded on
+// startup: a stub or an array of stubs per file.
+func ReadStubFiles(path string) ([]*stub.Stub, error) {
+	files, err := stubFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	stubs := make([]*stub.Stub, 0)
+	for _, file := range files {
+		fileStubs, err := readStubFile(file)
+		if err != nil {
+			return nil, err
+		}
+		stubs = append(stubs, fileStubs...)
+	}
+	return stubs, nil
+}
+
+func stubFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the stubs: %w", err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	files := make([]string, 0)
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.HasSuffix(info.Name(), ".json") {
+			files = append(files, file)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read the stubs: %w", err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func readStubFile(file string) ([]*stub.Stub, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the stubs: %w", err)
+	}
+	stubs := make([]*stub.Stub, 0)
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(data, &stubs)
+	} else {
+		s := new(stub.Stub)
+		err = json.Unmarshal(data, s)
+		stubs = append(stubs, s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read the stubs of %s: %w", file, err)
+	}
+	return stubs, nil
+}
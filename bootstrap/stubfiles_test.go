@@ -0,0 +1,147 @@
+package bootstrap
+
+import (
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testMethod = "/pkg.Service/Method"
+
+type fakeMockService struct {
+	grpchandler.MockService
+}
+
+func (fakeMockService) Register(s *grpc.Server) {}
+
+func (fakeMockService) GetRequestInstance(methodName string) proto.Message {
+	if methodName != testMethod {
+		return nil
+	}
+	return new(wrapperspb.StringValue)
+}
+
+func (fakeMockService) GetResponseInstance(methodName string) proto.Message {
+	return new(wrapperspb.StringValue)
+}
+
+func (s fakeMockService) GetStubsValidator() stub.StubsValidator {
+	return s
+}
+
+func (fakeMockService) IsValid(s *stub.Stub) (bool, []string) {
+	return s.IsValid()
+}
+
+func TestLoadStubFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "more"), 0755))
+	writeTestFile(t, dir, "a.json", `{"fullMethod": "/pkg.Service/Method", "type": "mock", "request": {"match": "exact", "content": "John"},
+		"response": {"type": "success", "content": "Hello John"}}`)
+	writeTestFile(t, filepath.Join(dir, "more"), "b.json", `[
+		{"fullMethod": "/pkg.Service/Method", "type": "mock", "request": {"match": "exact", "content": "Mary"}, "response": {"type": "success", "content": "Hello Mary"}},
+		{"fullMethod": "/pkg.Service/Unknown", "request": {"match": "exact", "content": "Mary"}, "response": {"type": "success", "content": "Hello"}}
+	]`)
+	writeTestFile(t, dir, "README.md", "not a stub")
+	stubsStore := stub.NewInMemoryStubsStore()
+
+	assert.Nil(t, loadStubFiles([]string{dir}, fakeMockService{}, stubsStore, false, false))
+	stubs := stubsStore.GetAllStubs()
+	if assert.Equal(t, 2, len(stubs)) {
+		assert.Equal(t, stub.JsonString(`"John"`), stubs[0].Request.Content)
+		assert.Equal(t, stub.JsonString(`"Mary"`), stubs[1].Request.Content)
+	}
+}
+
+func TestLoadStubFiles_Errors(t *testing.T) {
+	dir := t.TempDir()
+	stubsStore := stub.NewInMemoryStubsStore()
+	assert.Error(t, loadStubFiles([]string{filepath.Join(dir, "missing.json")}, fakeMockService{}, stubsStore, false, false))
+	assert.Error(t, loadStubFiles([]string{writeTestFile(t, dir, "invalid.json", "{")}, fakeMockService{}, stubsStore, false, false))
+}
+
+func TestLoadStubFiles_Strict(t *testing.T) {
+	dir := t.TempDir()
+	file := writeTestFile(t, dir, "stubs.json", `[
+		{"fullMethod": "/pkg.Service/Method", "type": "mock", "request": {"match": "exact", "content": "Mary"}, "response": {"type": "success", "content": "Hello Mary"}},
+		{"fullMethod": "/pkg.Service/Unknown", "type": "mock", "request": {"match": "exact", "content": "Mary"}, "response": {"type": "success", "content": "Hello"}},
+		{"fullMethod": "/pkg.Service/Method", "type": "mock", "request": {"match": "exact", "content": "Mary"}, "response": {"type": "success", "content": "Hello"}}
+	]`)
+
+	err := loadStubFiles([]string{file}, fakeMockService{}, stub.NewInMemoryStubsStore(), true, false)
+	assert.EqualError(t, err, "2 stubs could not be loaded:\n"+
+		"  stub 2 of "+file+": method /pkg.Service/Unknown is not supported\n"+
+		"  stub 3 of "+file+": stub already exists")
+
+	assert.Nil(t, loadStubFiles([]string{file}, fakeMockService{}, stub.NewInMemoryStubsStore(), false, false))
+}
+
+func TestLoadStubFiles_Shadowed(t *testing.T) {
+	dir := t.TempDir()
+	file := writeTestFile(t, dir, "stubs.json", `[
+		{"fullMethod": "/pkg.Service/Method", "type": "mock", "request": {"match": "exact", "content": "Mary"}, "response": {"type": "success", "content": "Hello Mary"}},
+		{"fullMethod": "/pkg.Service/Method", "type": "mock", "request": {"match": "exact", "content": "Mary", "metadata": {"tenant": ["a"]}}, "response": {"type": "success", "content": "Hello"}}
+	]`)
+
+	err := loadStubFiles([]string{file}, fakeMockService{}, stub.NewInMemoryStubsStore(), true, true)
+	assert.EqualError(t, err, "1 stubs could not be loaded:\n"+
+		"  stub 2 of "+file+": stub shadowed: the stub is never matched: the stub /pkg.Service/Method -> \"Mary\" matches all its calls first")
+
+	stubsStore := stub.NewInMemoryStubsStore()
+	assert.Nil(t, loadStubFiles([]string{file}, fakeMockService{}, stubsStore, false, true))
+	assert.Equal(t, 1, len(stubsStore.GetAllStubs()))
+
+	stubsStore = stub.NewInMemoryStubsStore()
+	assert.Nil(t, loadStubFiles([]string{file}, fakeMockService{}, stubsStore, true, false))
+	assert.Equal(t, 2, len(stubsStore.GetAllStubs()))
+}
+
+// int64MockService responds to the calls with an Int64Value.
+type int64MockService struct {
+	fakeMockService
+}
+
+func (int64MockService) GetResponseInstance(methodName string) proto.Message {
+	return new(wrapperspb.Int64Value)
+}
+
+func (s int64MockService) GetStubsValidator() stub.StubsValidator {
+	return s
+}
+
+func TestAddStub_TemplateInNumberField(t *testing.T) {
+	stubsStore := stub.NewInMemoryStubsStore()
+	s := &stub.Stub{FullMethod: testMethod, Type: "mock",
+		Request:  &stub.StubRequest{Match: "exact", Content: `"John"`},
+		Response: &stub.StubResponse{Type: "success", Content: "\"{{counter `calls`}}\""}}
+
+	assert.Nil(t, AddStub(int64MockService{}, stubsStore, s, false))
+	assert.Equal(t, stub.JsonString("\"{{counter `calls`}}\""), s.Response.Content)
+
+	invalid := &stub.Stub{FullMethod: testMethod, Type: "mock",
+		Request:  &stub.StubRequest{Match: "exact", Content: `"Mary"`},
+		Response: &stub.StubResponse{Type: "success", Content: `"many"`}}
+	assert.Error(t, AddStub(int64MockService{}, stubsStore, invalid, false))
+}
+
+func TestReadStubFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "a.json", `{"fullMethod": "/pkg.Service/Method", "request": {"match": "exact", "content": "John"}}`)
+	writeTestFile(t, dir, "b.json", `[{"fullMethod": "/pkg.Service/Method", "request": {"match": "exact", "content": "Mary"}},
+		{"fullMethod": "/pkg.Service/Other", "request": {"match": "partial", "content": {}}}]`)
+
+	stubs, err := ReadStubFiles(dir)
+	assert.Nil(t, err)
+	if assert.Equal(t, 3, len(stubs)) {
+		assert.Equal(t, stub.JsonString(`"John"`), stubs[0].Request.Content)
+		assert.Equal(t, "/pkg.Service/Other", stubs[2].FullMethod)
+	}
+	_, err = ReadStubFiles(writeTestFile(t, dir, "invalid.json", "{"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,152 @@
+package bootstrap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSConfig holds the settings used to serve the mock services over TLS.
+type TLSConfig struct {
+	// Certificate and private key presented by the server. TLS is disabled when empty.
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	// CA used to verify the certificates presented by the clients. Client certificates are not verified when empty.
+	ClientCAFile string `yaml:"clientCAFile"`
+	// require | optional - whether clients must present a certificate when ClientCAFile is set. Defaults to require.
+	ClientAuth string `yaml:"clientAuth"`
+	// How often the certificate and key files are checked for changes, so that rotated certificates are used without a
+	// restart. They are not reloaded when zero.
+	ReloadInterval time.Duration `yaml:"reloadInterval"`
+}
+
+// Enabled returns true when a server certificate was provided.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+func (c TLSConfig) validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("TLS certificate and key must be provided together")
+	}
+	if c.ClientCAFile != "" && !c.Enabled() {
+		return fmt.Errorf("TLS client CA requires the server certificate and key")
+	}
+	if c.ClientAuth != "" && c.ClientAuth != "require" && c.ClientAuth != "optional" {
+		return fmt.Errorf("TLS client auth can only be either 'require' or 'optional'")
+	}
+	if c.ReloadInterval < 0 {
+		return fmt.Errorf("TLS reload interval can't be negative")
+	}
+	return nil
+}
+
+func createServerTLSConfig(c TLSConfig) (*tls.Config, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{}
+	if c.ReloadInterval > 0 {
+		reloader, err := newCertificateReloader(c.CertFile, c.KeyFile, c.ReloadInterval)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.GetCertificate = reloader.GetCertificate
+	} else {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load server certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if c.ClientCAFile != "" {
+		caCert, err := ioutil.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read client CA file: %w", err)
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("could not parse client CA file %s", c.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = certPool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		if c.ClientAuth == "optional" {
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	return tlsConfig, nil
+}
+
+// certificateReloader serves the certificate of the files and loads it again when the files change, e.g. when
+// cert-manager rotates the certificate of a Kubernetes secret. The files are checked at most once per interval, during
+// the handshakes.
+type certificateReloader struct {
+	certFile, keyFile string
+	interval          time.Duration
+	now               func() time.Time
+	cert              *tls.Certificate
+	modTimes          [2]time.Time
+	checkedAt         time.Time
+	mutex             sync.Mutex
+}
+
+func newCertificateReloader(certFile, keyFile string, interval time.Duration) (*certificateReloader, error) {
+	r := &certificateReloader{certFile: certFile, keyFile: keyFile, interval: interval, now: time.Now}
+	modTimes, err := r.modTimesOfFiles()
+	if err != nil {
+		return nil, fmt.Errorf("could not load server certificate: %w", err)
+	}
+	if err := r.load(modTimes); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the certificate of the files, loading it again first if the files changed. The certificate
+// loaded before is kept when the new one can't be loaded, e.g. when only one of the files was updated so far.
+func (r *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if now := r.now(); now.Sub(r.checkedAt) >= r.interval {
+		r.checkedAt = now
+		modTimes, err := r.modTimesOfFiles()
+		if err != nil {
+			log.Warnf("Failed to check the TLS certificate for changes: %v", err)
+		} else if modTimes != r.modTimes {
+			if err := r.load(modTimes); err != nil {
+				log.Warnf("Failed to reload the TLS certificate, keeping the current one: %v", err)
+			} else {
+				log.Infof("Reloaded the TLS certificate %s", r.certFile)
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+func (r *certificateReloader) load(modTimes [2]time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("could not load server certificate: %w", err)
+	}
+	r.cert, r.modTimes, r.checkedAt = &cert, modTimes, r.now()
+	return nil
+}
+
+// modTimesOfFiles returns when the files were last modified. Symbolic links are followed, so that the certificates of
+// the Kubernetes secrets, replaced by swapping a link to a new directory, are also reloaded.
+func (r *certificateReloader) modTimesOfFiles() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+	for i, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
@@ -0,0 +1,110 @@
+package bootstrap
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCertificate writes a self-signed certificate with the common name and its key to the files.
+func writeTestCertificate(t *testing.T, certFile, keyFile, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+}
+
+func commonName(t *testing.T, r *certificateReloader) string {
+	cert, err := r.GetCertificate(nil)
+	assert.Nil(t, err)
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.Nil(t, err)
+	return parsed.Subject.CommonName
+}
+
+func TestCertificateReloader_GetCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key")
+	writeTestCertificate(t, certFile, keyFile, "first")
+	r, err := newCertificateReloader(certFile, keyFile, 10*time.Second)
+	assert.Nil(t, err)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	assert.Equal(t, "first", commonName(t, r))
+
+	writeTestCertificate(t, certFile, keyFile, "second")
+	modified := time.Now().Add(time.Minute)
+	assert.Nil(t, os.Chtimes(certFile, modified, modified))
+	assert.Equal(t, "first", commonName(t, r), "checked at most once per interval")
+	now = now.Add(10 * time.Second)
+	assert.Equal(t, "second", commonName(t, r))
+
+	// a certificate that can't be loaded is ignored
+	assert.Nil(t, ioutil.WriteFile(keyFile, []byte("not a key"), 0600))
+	now = now.Add(10 * time.Second)
+	assert.Equal(t, "second", commonName(t, r))
+}
+
+func TestCertificateReloader_Symlink(t *testing.T) {
+	// the files of the Kubernetes secrets are links to a directory that is replaced when the secret changes
+	dir := t.TempDir()
+	for _, version := range []string{"v1", "v2"} {
+		assert.Nil(t, os.Mkdir(filepath.Join(dir, version), 0700))
+		writeTestCertificate(t, filepath.Join(dir, version, "server.pem"), filepath.Join(dir, version, "server.key"), version)
+	}
+	modified := time.Now().Add(time.Minute)
+	assert.Nil(t, os.Chtimes(filepath.Join(dir, "v2", "server.pem"), modified, modified))
+	assert.Nil(t, os.Symlink("v1", filepath.Join(dir, "data")))
+	certFile, keyFile := filepath.Join(dir, "data", "server.pem"), filepath.Join(dir, "data", "server.key")
+	r, err := newCertificateReloader(certFile, keyFile, time.Second)
+	assert.Nil(t, err)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	assert.Equal(t, "v1", commonName(t, r))
+
+	assert.Nil(t, os.Remove(filepath.Join(dir, "data")))
+	assert.Nil(t, os.Symlink("v2", filepath.Join(dir, "data")))
+	now = now.Add(time.Second)
+	assert.Equal(t, "v2", commonName(t, r))
+}
+
+func TestCreateServerTLSConfig_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key")
+	writeTestCertificate(t, certFile, keyFile, "server")
+
+	tlsConfig, err := createServerTLSConfig(TLSConfig{CertFile: certFile, KeyFile: keyFile, ReloadInterval: time.Second})
+	assert.Nil(t, err)
+	assert.Empty(t, tlsConfig.Certificates)
+	assert.NotNil(t, tlsConfig.GetCertificate)
+
+	tlsConfig, err = createServerTLSConfig(TLSConfig{CertFile: certFile, KeyFile: keyFile})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(tlsConfig.Certificates))
+	assert.Nil(t, tlsConfig.GetCertificate)
+
+	_, err = createServerTLSConfig(TLSConfig{CertFile: certFile, KeyFile: keyFile, ReloadInterval: -time.Second})
+	assert.EqualError(t, err, "TLS reload interval can't be negative")
+	_, err = createServerTLSConfig(TLSConfig{CertFile: filepath.Join(dir, "missing.pem"), KeyFile: keyFile, ReloadInterval: time.Second})
+	assert.Error(t, err)
+}
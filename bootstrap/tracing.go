@@ -0,0 +1,53 @@
+package bootstrap
+
+import (
+	"context"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+	"strings"
+	"time"
+)
+
+const tracingShutdownTimeout = 5 * time.Second
+
+// startTracing exports the spans of the gRPC calls to the OTLP collector at the endpoint, e.g. http://localhost:4317.
+// The trace context received from the clients is propagated to the calls forwarded to real servers.
+// The standard OTEL_ environment variables, e.g. OTEL_SERVICE_NAME or OTEL_EXPORTER_OTLP_HEADERS, are also applied.
+// It returns the function sending the pending spans, called when the servers are stopped.
+func startTracing(endpoint string) (func(), error) {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	ctx := context.Background()
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", "protoc-gen-mock")),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK())
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	grpchandler.SetForwardDialOptions(grpc.WithStatsHandler(otelgrpc.NewClientHandler()))
+	log.Infof("Exporting traces to %s", endpoint)
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			log.Errorf("Failed to export the pending traces: %v", err)
+		}
+	}, nil
+}
@@ -0,0 +1,318 @@
+// Command protoc-gen-mock-ctl manages the stubs and the settings of a running mock server through its REST API.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/bootstrap"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/carvalhorr/protoc-gen-mock/stubclient"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `Usage: protoc-gen-mock-ctl [--server URL] <command> [arguments]
+
+Commands:
+  push <file|dir>...   add the stubs of the JSON files, replacing the existing ones for the same request
+  pull <dir>           write the stubs of the server in the directory, one file per method
+  list                 list the stubs. --method only lists the ones of a method, --json prints them in JSON
+  tail                 print the calls recorded by the server as they arrive, until interrupted
+  reset                delete all the stubs and the counts of the verifications
+  chaos [on|off]       show, enable or disable the errors and delays injected in the calls
+  scenarios [name state]
+                       show the states of the scenarios of the templates, or change the state of one
+
+The server defaults to $MOCK_CTL_SERVER or http://localhost:1068.
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stdout); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("protoc-gen-mock-ctl", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(flags.Output(), usage) }
+	server := flags.String("server", defaultServer(), "base URL of the REST API of the mock server")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	client := stubclient.New(*server)
+	command, commandArgs := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "push":
+		return push(ctx, client, commandArgs, out)
+	case "pull":
+		return pull(ctx, client, commandArgs, out)
+	case "list":
+		return list(ctx, client, commandArgs, out)
+	case "tail":
+		return tail(ctx, client, commandArgs, out)
+	case "reset":
+		if err := client.Reset(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "stubs and verifications reset")
+		return nil
+	case "chaos":
+		return chaos(ctx, client, commandArgs, out)
+	case "scenarios":
+		return scenarios(ctx, client, commandArgs, out)
+	default:
+		return fmt.Errorf("unknown command '%s'. Run protoc-gen-mock-ctl --help for the commands", command)
+	}
+}
+
+func defaultServer() string {
+	if server := os.Getenv("MOCK_CTL_SERVER"); server != "" {
+		return server
+	}
+	return "http://localhost:1068"
+}
+
+// push adds the stubs of the files, updating the ones that already exist.
+func push(ctx context.Context, client *stubclient.Client, paths []string, out io.Writer) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("push requires the files or directories of the stubs")
+	}
+	added, updated, failed := 0, 0, 0
+	for _, path := range paths {
+		stubs, err := bootstrap.ReadStubFiles(path)
+		if err != nil {
+			return err
+		}
+		for _, s := range stubs {
+			err := client.CreateStub(ctx, s)
+			var clientErr *stubclient.Error
+			if errors.As(err, &clientErr) && clientErr.StatusCode == http.StatusConflict {
+				if err = client.UpdateStub(ctx, s); err == nil {
+					updated++
+					continue
+				}
+			}
+			if err != nil {
+				failed++
+				fmt.Fprintf(out, "stub for %s not pushed: %s\n", s.FullMethod, err)
+				continue
+			}
+			added++
+		}
+	}
+	fmt.Fprintf(out, "%d stubs added, %d updated\n", added, updated)
+	if failed > 0 {
+		return fmt.Errorf("%d stubs could not be pushed", failed)
+	}
+	return nil
+}
+
+// pull writes the stubs of the server in the directory, in a file per method named after it, e.g. pkg.Service_Method.json.
+func pull(ctx context.Context, client *stubclient.Client, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("pull requires the directory to write the stubs to")
+	}
+	stubs, err := client.ListStubs(ctx, "")
+	if err != nil {
+		return err
+	}
+	byMethod := make(map[string][]*stub.Stub)
+	for _, s := range stubs {
+		byMethod[s.FullMethod] = append(byMethod[s.FullMethod], s)
+	}
+	if err := os.MkdirAll(args[0], 0755); err != nil {
+		return err
+	}
+	for method, methodStubs := range byMethod {
+		data, err := json.MarshalIndent(methodStubs, "", "  ")
+		if err != nil {
+			return err
+		}
+		file := filepath.Join(args[0], strings.ReplaceAll(strings.TrimPrefix(method, "/"), "/", "_")+".json")
+		if err := ioutil.WriteFile(file, append(data, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(out, "%d stubs of %d methods written to %s\n", len(stubs), len(byMethod), args[0])
+	return nil
+}
+
+func list(ctx context.Context, client *stubclient.Client, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	method := flags.String("method", "", "only list the stubs of the method, e.g. /pkg.Service/Method")
+	asJSON := flags.Bool("json", false, "print the stubs in JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	stubs, err := client.ListStubs(ctx, *method)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		data, err := json.MarshalIndent(stubs, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+		return nil
+	}
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "METHOD\tTYPE\tMATCH\tREQUEST")
+	for _, s := range stubs {
+		match, content := "", ""
+		if s.Request != nil {
+			match, content = s.Request.Match, string(s.Request.Content)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", s.FullMethod, s.Type, match, content)
+	}
+	return writer.Flush()
+}
+
+// tail prints the new recordings, a JSON object per line, until the context is done.
+func tail(ctx context.Context, client *stubclient.Client, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("tail", flag.ContinueOnError)
+	interval := flags.Duration("interval", time.Second, "how often the recordings are checked")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	printed := 0
+	for {
+		page, err := client.RecordingsPage(ctx, printed, 0)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if page.Total < printed {
+			// the recordings were cleared, e.g. by a restart of the server
+			if page, err = client.RecordingsPage(ctx, 0, 0); err != nil {
+				return err
+			}
+			printed = 0
+		}
+		for _, recording := range page.Recordings {
+			data, err := json.Marshal(recording)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, string(data))
+		}
+		printed += len(page.Recordings)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+func chaos(ctx context.Context, client *stubclient.Client, args []string, out io.Writer) error {
+	action := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+	flags := flag.NewFlagSet("chaos", flag.ContinueOnError)
+	percentage := flags.Float64("percentage", 10, "percentage of the calls affected, from 0 to 100")
+	codes := flags.String("codes", "", "comma separated status codes of the errors injected, e.g. 14,4")
+	maxDelay := flags.String("max-delay", "", "maximum delay injected, e.g. 2s")
+	methods := flags.String("methods", "", "comma separated full methods affected. All the methods when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var config grpchandler.ChaosConfig
+	var err error
+	switch action {
+	case "":
+		config, err = client.Chaos(ctx)
+	case "on":
+		config = grpchandler.ChaosConfig{Enabled: true, Percentage: *percentage, MaxDelay: *maxDelay, Methods: splitList(*methods)}
+		for _, code := range splitList(*codes) {
+			value, parseErr := strconv.ParseUint(code, 10, 32)
+			if parseErr != nil {
+				return fmt.Errorf("invalid status code '%s'", code)
+			}
+			config.ErrorCodes = append(config.ErrorCodes, uint32(value))
+		}
+		config, err = client.SetChaos(ctx, config)
+	case "off":
+		if err = client.DisableChaos(ctx); err == nil {
+			config, err = client.Chaos(ctx)
+		}
+	default:
+		return fmt.Errorf("unknown chaos action '%s'. Use on or off", action)
+	}
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, string(data))
+	return nil
+}
+
+// scenarios prints the states of the scenarios of the templates, or changes the state of one when its name and state are
+// given.
+func scenarios(ctx context.Context, client *stubclient.Client, args []string, out io.Writer) error {
+	switch len(args) {
+	case 0:
+		states, err := client.Scenarios(ctx)
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(states))
+		for name := range states {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		for _, name := range names {
+			fmt.Fprintf(writer, "%s\t%s\n", name, states[name])
+		}
+		return writer.Flush()
+	case 2:
+		if err := client.SetScenario(ctx, args[0], args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "scenario %s changed to %s\n", args[0], args[1])
+		return nil
+	default:
+		return fmt.Errorf("scenarios requires no arguments, or the name and the state of a scenario")
+	}
+}
+
+func splitList(value string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const helloStub = `{"fullMethod":"/greeter.Greeter/Hello","type":"mock","request":{"match":"exact","content":{"name":"John"},"metadata":null},"response":{"type":"success","content":{"greeting":"Hello"},"error":null},"forward":null}`
+
+// newTestServer returns a server responding to each "METHOD /path" with the handler registered for it, and recording them.
+func newTestServer(t *testing.T, handlers map[string]func(writer http.ResponseWriter)) (string, *[]string) {
+	requests := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		key := request.Method + " " + request.URL.Path
+		requests = append(requests, key)
+		if handler, ok := handlers[key]; ok {
+			handler(writer)
+			return
+		}
+		writer.Write([]byte("OK"))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, &requests
+}
+
+func respond(code int, body string) func(writer http.ResponseWriter) {
+	return func(writer http.ResponseWriter) {
+		writer.WriteHeader(code)
+		writer.Write([]byte(body))
+	}
+}
+
+func TestRun_Push(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "hello.json"), []byte("["+helloStub+","+helloStub+"]"), 0644))
+	conflicts := 0
+	url, requests := newTestServer(t, map[string]func(writer http.ResponseWriter){
+		"POST /stubs": func(writer http.ResponseWriter) {
+			if conflicts++; conflicts == 2 {
+				respond(http.StatusConflict, "stub already exists")(writer)
+			}
+		},
+	})
+	out := &bytes.Buffer{}
+
+	assert.Nil(t, run(context.Background(), []string{"--server", url, "push", dir}, out))
+	assert.Equal(t, "1 stubs added, 1 updated\n", out.String())
+	assert.Equal(t, []string{"POST /stubs", "POST /stubs", "PUT /stubs"}, *requests)
+
+	assert.EqualError(t, run(context.Background(), []string{"--server", url, "push"}, out), "push requires the files or directories of the stubs")
+}
+
+func TestRun_PullAndList(t *testing.T) {
+	url, _ := newTestServer(t, map[string]func(writer http.ResponseWriter){
+		"GET /stubs": respond(http.StatusOK, "["+helloStub+"]"),
+	})
+	dir := filepath.Join(t.TempDir(), "stubs")
+	out := &bytes.Buffer{}
+
+	assert.Nil(t, run(context.Background(), []string{"--server", url, "pull", dir}, out))
+	assert.Equal(t, "1 stubs of 1 methods written to "+dir+"\n", out.String())
+	data, err := ioutil.ReadFile(filepath.Join(dir, "greeter.Greeter_Hello.json"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"fullMethod": "/greeter.Greeter/Hello"`)
+
+	out.Reset()
+	assert.Nil(t, run(context.Background(), []string{"--server", url, "list"}, out))
+	assert.Equal(t, "METHOD                  TYPE  MATCH  REQUEST\n/greeter.Greeter/Hello  mock  exact  {\"name\":\"John\"}\n", out.String())
+}
+
+func TestRun_Tail(t *testing.T) {
+	calls := 0
+	url, _ := newTestServer(t, map[string]func(writer http.ResponseWriter){
+		"GET /recordings/page": func(writer http.ResponseWriter) {
+			// the recordings after the ones printed
+			switch calls++; calls {
+			case 1, 2:
+				writer.Write([]byte(fmt.Sprintf(`{"recordings":[%s],"total":%d}`, helloStub, calls)))
+			default:
+				writer.Write([]byte(`{"recordings":[],"total":2}`))
+			}
+		},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	out := &bytes.Buffer{}
+
+	assert.Nil(t, run(ctx, []string{"--server", url, "tail", "--interval", "10ms"}, out))
+	assert.Equal(t, helloStub+"\n"+helloStub+"\n", out.String())
+}
+
+func TestRun_Chaos(t *testing.T) {
+	url, requests := newTestServer(t, map[string]func(writer http.ResponseWriter){
+		"PUT /admin/chaos": respond(http.StatusOK, `{"enabled":true,"percentage":50,"errorCodes":[14,4]}`),
+		"GET /admin/chaos": respond(http.StatusOK, `{"enabled":false,"percentage":0}`),
+	})
+	out := &bytes.Buffer{}
+
+	assert.Nil(t, run(context.Background(), []string{"--server", url, "chaos", "on", "--percentage", "50", "--codes", "14,4"}, out))
+	assert.Nil(t, run(context.Background(), []string{"--server", url, "chaos", "off"}, out))
+	assert.Equal(t, `{"enabled":true,"percentage":50,"errorCodes":[14,4]}`+"\n"+`{"enabled":false,"percentage":0}`+"\n", out.String())
+	assert.Equal(t, []string{"PUT /admin/chaos", "DELETE /admin/chaos", "GET /admin/chaos"}, *requests)
+
+	assert.EqualError(t, run(context.Background(), []string{"--server", url, "chaos", "on", "--codes", "x"}, out), "invalid status code 'x'")
+}
+
+func TestRun_Scenarios(t *testing.T) {
+	url, requests := newTestServer(t, map[string]func(writer http.ResponseWriter){
+		"PUT /scenarios/checkout": respond(http.StatusOK, `{"name":"checkout","state":"paid"}`),
+		"GET /scenarios":          respond(http.StatusOK, `{"signup":"started","checkout":"paid"}`),
+	})
+	out := &bytes.Buffer{}
+
+	assert.Nil(t, run(context.Background(), []string{"--server", url, "scenarios", "checkout", "paid"}, out))
+	assert.Nil(t, run(context.Background(), []string{"--server", url, "scenarios"}, out))
+	assert.Equal(t, "scenario checkout changed to paid\ncheckout  paid\nsignup    started\n", out.String())
+	assert.Equal(t, []string{"PUT /scenarios/checkout", "GET /scenarios"}, *requests)
+
+	assert.EqualError(t, run(context.Background(), []string{"--server", url, "scenarios", "checkout"}, out),
+		"scenarios requires no arguments, or the name and the state of a scenario")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/options"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"path"
+	"strings"
+)
+
+const (
+	methodSkipped = "skipped" // no mock is generated and the method returns UNIMPLEMENTED
+	methodPartial = "partial" // the method is mocked but some of its features are not supported
+)
+
+// generationReport lists the methods the mocks could not be fully generated for, with the reasons.
+type generationReport struct {
+	Methods []methodDiagnostic `json:"methods"`
+}
+
+type methodDiagnostic struct {
+	Method  string   `json:"method"`
+	Status  string   `json:"status"`
+	Reasons []string `json:"reasons"`
+	// Excluded is true when the method is left out on purpose with the parameters or the proto options.
+	Excluded bool `json:"excluded"`
+}
+
+// createGenerationReport checks the methods of the services in the files, in the order of the files.
+func createGenerationReport(files []*protogen.File, filter generationFilter) generationReport {
+	report := generationReport{Methods: make([]methodDiagnostic, 0)}
+	for _, file := range files {
+		for _, service := range file.Services {
+			for _, method := range service.Methods {
+				if diagnostic, ok := diagnoseMethod(service, method, filter); ok {
+					report.Methods = append(report.Methods, diagnostic)
+				}
+			}
+		}
+	}
+	return report
+}
+
+// diagnoseMethod returns false for the methods that are fully supported.
+func diagnoseMethod(service *protogen.Service, method *protogen.Method, filter generationFilter) (methodDiagnostic, bool) {
+	diagnostic := methodDiagnostic{Method: fmt.Sprintf("/%s/%s", service.Desc.FullName(), method.GoName), Status: methodSkipped, Excluded: true}
+	switch {
+	case isSkipped(service.Desc.Options(), options.E_SkipService):
+		diagnostic.Reasons = []string{"the service has the mock.skip_service option"}
+	case !filter.includesService(service):
+		diagnostic.Reasons = []string{"the service is not in the services parameter"}
+	case isSkipped(method.Desc.Options(), options.E_Skip):
+		diagnostic.Reasons = []string{"the method has the mock.skip option"}
+	case !filter.includesMethod(method):
+		diagnostic.Reasons = []string{"the method is in the exclude_methods parameter"}
+	}
+	if len(diagnostic.Reasons) > 0 {
+		return diagnostic, true
+	}
+	diagnostic.Status, diagnostic.Excluded = methodPartial, false
+	rule, _ := proto.GetExtension(method.Desc.Options(), annotations.E_Http).(*annotations.HttpRule)
+	if method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer() {
+		diagnostic.Reasons = append(diagnostic.Reasons,
+			"streaming methods only support stubs of type forward or passthrough",
+			"no typed stub builder or example stub is generated for streaming methods")
+		if rule != nil {
+			diagnostic.Reasons = append(diagnostic.Reasons, "the google.api.http annotation is ignored as streaming methods are not transcoded")
+		}
+		return diagnostic, true
+	}
+	if rule != nil {
+		for _, r := range append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...) {
+			if _, p := getHTTPRulePattern(r); p == "" {
+				diagnostic.Reasons = append(diagnostic.Reasons, "a google.api.http binding without a path is ignored")
+				break
+			}
+		}
+	}
+	return diagnostic, len(diagnostic.Reasons) > 0
+}
+
+func isSkipped(opts proto.Message, option protoreflect.ExtensionType) bool {
+	skip, _ := proto.GetExtension(opts, option).(bool)
+	return skip
+}
+
+// GenerateReportFile generates mock_report.json, in the root of the module with the module parameter.
+func GenerateReportFile(gen *protogen.Plugin, report generationReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not create the generation report: %w", err)
+	}
+	g := gen.NewGeneratedFile(path.Join(moduleParam(gen), "mock_report.json"), protogen.GoImportPath("main"))
+	g.P(string(data))
+	return nil
+}
+
+// unsupportedError returns an error listing the methods that are not fully supported, or nil when there is none.
+// The methods excluded on purpose are not errors.
+func (r generationReport) unsupportedError() error {
+	lines := make([]string, 0)
+	for _, diagnostic := range r.Methods {
+		if !diagnostic.Excluded {
+			lines = append(lines, fmt.Sprintf("%s: %s", diagnostic.Method, strings.Join(diagnostic.Reasons, "; ")))
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return fmt.Errorf("the mocks of some methods are not fully supported:\n%s", strings.Join(lines, "\n"))
+}
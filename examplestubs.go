@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"google.golang.org/protobuf/compiler/protogen"
+	"path"
+)
+
+// GenerateExampleStubFiles generates, for each method, a JSON file with an example stub that can be sent to POST /stubs.
+// The request and response have all their fields populated with sample values. Streaming methods are skipped as they can't be mocked.
+func GenerateExampleStubFiles(gen *protogen.Plugin, file *protogen.File, filter generationFilter) error {
+	m := mockServicesGenerator{
+		gen:    gen,
+		file:   file,
+		filter: filter,
+	}
+	for _, service := range m.services() {
+		for _, method := range m.methods(service) {
+			if method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer() {
+				continue
+			}
+			data, err := createExampleStub(service, method)
+			if err != nil {
+				return fmt.Errorf("could not create the example stub of %s: %w", method.Desc.FullName(), err)
+			}
+			filename := path.Join(path.Dir(file.GeneratedFilenamePrefix), "stubs", string(service.Desc.FullName()), method.GoName+".json")
+			g := gen.NewGeneratedFile(filename, file.GoImportPath)
+			g.P(string(data))
+		}
+	}
+	return nil
+}
+
+func createExampleStub(service *protogen.Service, method *protogen.Method) ([]byte, error) {
+	request, err := stub.CreateStubContent(method.Input.Desc)
+	if err != nil {
+		return nil, err
+	}
+	response, err := stub.CreateStubContent(method.Output.Desc)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(&stub.Stub{
+		FullMethod: fmt.Sprintf("/%s/%s", service.Desc.FullName(), method.GoName),
+		Type:       "mock",
+		Request: &stub.StubRequest{
+			Match:    "exact",
+			Content:  stub.JsonString(request),
+			Metadata: make(map[string][]string),
+		},
+		Response: &stub.StubResponse{
+			Type:    "success",
+			Content: stub.JsonString(response),
+		},
+	}, "", "  ")
+}
@@ -0,0 +1,164 @@
+package grpchandler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"sync"
+	"time"
+)
+
+// AccessLog writes one JSON line per gRPC call with the method, the peer, the stub matched, the status and the duration.
+// Add its interceptors to the gRPC server to enable it.
+type AccessLog struct {
+	out io.Writer
+	// maximum size in bytes of the request and response bodies logged. The bodies are not logged when zero.
+	maxBodySize int
+	redaction   *stub.RecordingRedaction
+	mutex       sync.Mutex
+}
+
+// AccessLogEntry is the line written for each call. The correlation ID is only logged when the CorrelationID interceptors are
+// added before the ones of the access log.
+type AccessLogEntry struct {
+	Time          time.Time `json:"time"`
+	Method        string    `json:"method"`
+	Peer          string    `json:"peer,omitempty"`
+	CorrelationID string    `json:"correlationId,omitempty"`
+	Matched       bool      `json:"matched"`
+	StubID        string    `json:"stubId,omitempty"`
+	StubType      string    `json:"stubType,omitempty"`
+	StubName      string    `json:"stubName,omitempty"`
+	Status        string    `json:"status"`
+	Error         string    `json:"error,omitempty"`
+	DurationMs    float64   `json:"durationMs"`
+	// bodies in protojson, truncated to the maximum size. Only logged for unary calls.
+	Request  string `json:"request,omitempty"`
+	Response string `json:"response,omitempty"`
+}
+
+// NewAccessLog creates the access log writing to out. The bodies are truncated to maxBodySize bytes and not logged when
+// it is zero. The redaction, optional, is applied to the bodies and the error messages before they are written.
+func NewAccessLog(out io.Writer, maxBodySize int, redaction *stub.RecordingRedaction) *AccessLog {
+	return &AccessLog{out: out, maxBodySize: maxBodySize, redaction: redaction}
+}
+
+type callStubKey struct{}
+
+// callStub keeps the stub matched by the call so that it is logged once the call ends.
+type callStub struct {
+	stub *stub.Stub
+}
+
+// withCallStub returns the context keeping the stub matched by the call, reusing the one of the outer interceptors.
+func withCallStub(ctx context.Context) (context.Context, *callStub) {
+	if c, ok := ctx.Value(callStubKey{}).(*callStub); ok {
+		return ctx, c
+	}
+	c := new(callStub)
+	return context.WithValue(ctx, callStubKey{}, c), c
+}
+
+// recordMatchedStub stores the stub matched in the context of the call created by the interceptors.
+func recordMatchedStub(ctx context.Context, s *stub.Stub) {
+	if c, ok := ctx.Value(callStubKey{}).(*callStub); ok {
+		c.stub = s
+	}
+}
+
+// UnaryInterceptor logs the unary calls.
+func (a *AccessLog) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	ctx, c := withCallStub(ctx)
+	resp, err := handler(ctx, req)
+	entry := a.createEntry(ctx, info.FullMethod, c.stub, err, start)
+	if a.maxBodySize > 0 {
+		entry.Request = a.body(req)
+		if err == nil {
+			entry.Response = a.body(resp)
+		}
+	}
+	a.write(entry)
+	return resp, err
+}
+
+// StreamInterceptor logs the streaming calls, without the bodies.
+func (a *AccessLog) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx, c := withCallStub(ss.Context())
+	err := handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+	a.write(a.createEntry(ctx, info.FullMethod, c.stub, err, start))
+	return err
+}
+
+// contextServerStream replaces the context of the stream, e.g. to add values to it in the interceptors.
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (a *AccessLog) createEntry(ctx context.Context, fullMethod string, s *stub.Stub, err error, start time.Time) AccessLogEntry {
+	entry := AccessLogEntry{
+		Time:          start.UTC(),
+		Method:        fullMethod,
+		Matched:       s != nil,
+		Status:        status.Code(err).String(),
+		DurationMs:    float64(time.Since(start).Microseconds()) / 1000,
+		CorrelationID: CorrelationIDFromContext(ctx),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		entry.Peer = p.Addr.String()
+	}
+	if s != nil {
+		entry.StubID, entry.StubType, entry.StubName = s.ID(), string(s.Type), s.Name
+	}
+	if err != nil {
+		entry.Error = a.redaction.RedactString(status.Convert(err).Message())
+	}
+	return entry
+}
+
+// body returns the message in protojson, redacted and truncated to the maximum size.
+func (a *AccessLog) body(message interface{}) string {
+	m, ok := message.(proto.Message)
+	if !ok || m == nil {
+		return ""
+	}
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		return ""
+	}
+	content, err := a.redaction.RedactContent(stub.JsonString(data))
+	if err != nil {
+		// the body is not logged rather than risking logging sensitive values
+		return ""
+	}
+	if len(content) > a.maxBodySize {
+		return string(content[:a.maxBodySize]) + "..."
+	}
+	return string(content)
+}
+
+func (a *AccessLog) write(entry AccessLogEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Errorf("Failed to marshal the access log entry of %s. Error: %s", entry.Method, err)
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if _, err := a.out.Write(append(data, '\n')); err != nil {
+		log.Errorf("Failed to write the access log. Error: %s", err)
+	}
+}
@@ -0,0 +1,93 @@
+package grpchandler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"testing"
+)
+
+func readAccessLogEntry(t *testing.T, out *bytes.Buffer) AccessLogEntry {
+	entry := AccessLogEntry{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, byte('\n'), out.Bytes()[out.Len()-1])
+	out.Reset()
+	return entry
+}
+
+func TestAccessLog_UnaryInterceptor_MatchedStub(t *testing.T) {
+	out := new(bytes.Buffer)
+	accessLog := NewAccessLog(out, 0, nil)
+	s := &stub.Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &stub.StubRequest{Match: "exact", Content: `{}`}}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000}})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		recordMatchedStub(ctx, s)
+		return &errdetails.ErrorInfo{Reason: "reason"}, nil
+	}
+
+	_, err := accessLog.UnaryInterceptor(ctx, &errdetails.ErrorInfo{}, &grpc.UnaryServerInfo{FullMethod: s.FullMethod}, handler)
+	assert.Nil(t, err)
+	entry := readAccessLogEntry(t, out)
+	assert.Equal(t, "/pkg.Service/Method", entry.Method)
+	assert.Equal(t, "127.0.0.1:5000", entry.Peer)
+	assert.True(t, entry.Matched)
+	assert.Equal(t, s.ID(), entry.StubID)
+	assert.Equal(t, "mock", entry.StubType)
+	assert.Equal(t, "OK", entry.Status)
+	assert.Empty(t, entry.Request)
+	assert.Empty(t, entry.Response)
+}
+
+func TestAccessLog_UnaryInterceptor_BodiesRedactedAndTruncated(t *testing.T) {
+	out := new(bytes.Buffer)
+	accessLog := NewAccessLog(out, 20, &stub.RecordingRedaction{Fields: []string{"reason"}, Patterns: []string{`[a-z]+@example\.com`}})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "unknown user john@example.com")
+	}
+
+	_, err := accessLog.UnaryInterceptor(context.Background(), &errdetails.ErrorInfo{Reason: "secret", Domain: "a-very-long-domain.com"},
+		&grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Method"}, handler)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	entry := readAccessLogEntry(t, out)
+	assert.False(t, entry.Matched)
+	assert.Empty(t, entry.StubID)
+	assert.Equal(t, "NotFound", entry.Status)
+	assert.Equal(t, "unknown user [REDACTED]", entry.Error)
+	assert.Equal(t, `{"domain":"a-very-lo...`, entry.Request)
+	assert.Empty(t, entry.Response)
+}
+
+type accessLogTestStream struct {
+	grpc.ServerStream
+}
+
+func (accessLogTestStream) Context() context.Context {
+	return context.Background()
+}
+
+func TestAccessLog_StreamInterceptor(t *testing.T) {
+	out := new(bytes.Buffer)
+	accessLog := NewAccessLog(out, 100, nil)
+	s := &stub.Stub{FullMethod: "/pkg.Service/Stream", Type: "forward", Request: &stub.StubRequest{Match: "partial", Content: `{}`}}
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		recordMatchedStub(stream.Context(), s)
+		return status.Error(codes.Unavailable, "connection refused")
+	}
+
+	err := accessLog.StreamInterceptor(nil, accessLogTestStream{}, &grpc.StreamServerInfo{FullMethod: s.FullMethod}, handler)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	entry := readAccessLogEntry(t, out)
+	assert.Equal(t, "/pkg.Service/Stream", entry.Method)
+	assert.Equal(t, s.ID(), entry.StubID)
+	assert.Equal(t, "forward", entry.StubType)
+	assert.Equal(t, "Unavailable", entry.Status)
+	assert.Equal(t, "connection refused", entry.Error)
+}
@@ -0,0 +1,113 @@
+package grpchandler
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ChaosConfig injects errors and delays in a percentage of the calls, whether they match a stub or not, e.g. to test the
+// resilience of the clients without changing the stubs.
+type ChaosConfig struct {
+	Enabled bool `json:"enabled"`
+	// Percentage of the calls affected, from 0 to 100
+	Percentage float64 `json:"percentage"`
+	// Full methods affected, e.g. /pkg.Service/Method. All the methods are affected when empty.
+	Methods []string `json:"methods,omitempty"`
+	// Status codes of the errors injected, picked randomly
+	ErrorCodes []uint32 `json:"errorCodes,omitempty"`
+	// Maximum delay injected, e.g. 2s. The delay of each call is random up to this value and the client deadline is respected.
+	MaxDelay string `json:"maxDelay,omitempty"`
+}
+
+// Chaos holds the chaos configuration applied to the calls.
+type Chaos interface {
+	Get() ChaosConfig
+	// Set replaces the configuration. Without error codes nor delay, the calls affected fail with UNAVAILABLE.
+	Set(config ChaosConfig) error
+}
+
+// GetChaos returns the chaos configuration of the mock server, disabled until set.
+func GetChaos() Chaos {
+	return chaos
+}
+
+var chaosInjected = expvar.NewInt("chaosInjected")
+
+var chaos = &chaosRegistry{random: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+type chaosRegistry struct {
+	config   ChaosConfig
+	methods  map[string]bool
+	maxDelay time.Duration
+	random   *rand.Rand
+	mutex    sync.Mutex
+}
+
+func (c *chaosRegistry) Get() ChaosConfig {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.config
+}
+
+func (c *chaosRegistry) Set(config ChaosConfig) error {
+	if config.Percentage < 0 || config.Percentage > 100 {
+		return fmt.Errorf("invalid percentage %g. It must be between 0 and 100", config.Percentage)
+	}
+	for _, code := range config.ErrorCodes {
+		if code == uint32(codes.OK) || code > uint32(codes.Unauthenticated) {
+			return fmt.Errorf("invalid error code %d. It must be between 1 and 16", code)
+		}
+	}
+	var maxDelay time.Duration
+	if config.MaxDelay != "" {
+		var err error
+		if maxDelay, err = time.ParseDuration(config.MaxDelay); err != nil || maxDelay < 0 {
+			return fmt.Errorf("invalid max delay '%s'", config.MaxDelay)
+		}
+	}
+	if len(config.ErrorCodes) == 0 && maxDelay == 0 {
+		config.ErrorCodes = []uint32{uint32(codes.Unavailable)}
+	}
+	methods := make(map[string]bool)
+	for _, method := range config.Methods {
+		methods[method] = true
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.config, c.methods, c.maxDelay = config, methods, maxDelay
+	return nil
+}
+
+// inject applies the chaos to the call when it is picked: it either waits a random delay or returns an error, randomly
+// when both are configured.
+func (c *chaosRegistry) inject(ctx context.Context, fullMethod string) error {
+	c.mutex.Lock()
+	if !c.config.Enabled || (len(c.methods) > 0 && !c.methods[fullMethod]) || c.random.Float64()*100 >= c.config.Percentage {
+		c.mutex.Unlock()
+		return nil
+	}
+	var delay time.Duration
+	var code codes.Code
+	if c.maxDelay > 0 && (len(c.config.ErrorCodes) == 0 || c.random.Intn(2) == 0) {
+		delay = time.Duration(c.random.Int63n(int64(c.maxDelay) + 1))
+	} else {
+		code = codes.Code(c.config.ErrorCodes[c.random.Intn(len(c.config.ErrorCodes))])
+	}
+	c.mutex.Unlock()
+
+	chaosInjected.Add(1)
+	if code != codes.OK {
+		log.Debugf("Chaos: injecting %s in %s", code, fullMethod)
+		return status.Errorf(code, "chaos: injected %s", code)
+	}
+	log.Debugf("Chaos: injecting a delay of %s in %s", delay, fullMethod)
+	return waitDelay(ctx, delay)
+}
@@ -0,0 +1,71 @@
+package grpchandler
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func newTestChaos() *chaosRegistry {
+	return &chaosRegistry{random: rand.New(rand.NewSource(1))}
+}
+
+func TestChaos_Set_Invalid(t *testing.T) {
+	c := newTestChaos()
+	assert.EqualError(t, c.Set(ChaosConfig{Percentage: 101}), "invalid percentage 101. It must be between 0 and 100")
+	assert.EqualError(t, c.Set(ChaosConfig{Percentage: 10, ErrorCodes: []uint32{0}}), "invalid error code 0. It must be between 1 and 16")
+	assert.EqualError(t, c.Set(ChaosConfig{Percentage: 10, MaxDelay: "soon"}), "invalid max delay 'soon'")
+	assert.False(t, c.Get().Enabled)
+}
+
+func TestChaos_Inject_Errors(t *testing.T) {
+	c := newTestChaos()
+	assert.Nil(t, c.Set(ChaosConfig{Enabled: true, Percentage: 100}))
+	assert.Equal(t, []uint32{uint32(codes.Unavailable)}, c.Get().ErrorCodes)
+
+	err := c.inject(context.Background(), "/pkg.Service/Method")
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, "chaos: injected Unavailable", status.Convert(err).Message())
+
+	assert.Nil(t, c.Set(ChaosConfig{Enabled: false, Percentage: 100}))
+	assert.Nil(t, c.inject(context.Background(), "/pkg.Service/Method"))
+}
+
+func TestChaos_Inject_Percentage(t *testing.T) {
+	c := newTestChaos()
+	assert.Nil(t, c.Set(ChaosConfig{Enabled: true, Percentage: 30, ErrorCodes: []uint32{uint32(codes.Internal)}}))
+	failed := 0
+	for i := 0; i < 1000; i++ {
+		if c.inject(context.Background(), "/pkg.Service/Method") != nil {
+			failed++
+		}
+	}
+	assert.InDelta(t, 300, failed, 60)
+}
+
+func TestChaos_Inject_Methods(t *testing.T) {
+	c := newTestChaos()
+	assert.Nil(t, c.Set(ChaosConfig{Enabled: true, Percentage: 100, Methods: []string{"/pkg.Service/Method"}}))
+	assert.Error(t, c.inject(context.Background(), "/pkg.Service/Method"))
+	assert.Nil(t, c.inject(context.Background(), "/pkg.Service/Other"))
+}
+
+func TestChaos_Inject_Delay(t *testing.T) {
+	c := newTestChaos()
+	assert.Nil(t, c.Set(ChaosConfig{Enabled: true, Percentage: 100, MaxDelay: "20ms"}))
+	assert.Empty(t, c.Get().ErrorCodes)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, c.inject(context.Background(), "/pkg.Service/Method"))
+	}
+	assert.True(t, time.Since(start) < time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Nil(t, c.Set(ChaosConfig{Enabled: true, Percentage: 100, MaxDelay: "1h"}))
+	assert.Equal(t, codes.Canceled, status.Code(c.inject(ctx, "/pkg.Service/Method")))
+}
@@ -0,0 +1,86 @@
+package grpchandler
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConcurrencyLimit simulates the backpressure of a real server by limiting the calls in flight per method, or per service.
+// The calls over the limit wait for up to the queue time for a call to end and then fail with UNAVAILABLE. Add its
+// interceptors to the gRPC server to enable it.
+type ConcurrencyLimit struct {
+	maxCalls   int
+	queueTime  time.Duration
+	perService bool
+	slots      map[string]chan struct{}
+	mutex      sync.Mutex
+}
+
+// NewConcurrencyLimit creates the limit of maxCalls calls in flight per method, or per service when perService is true.
+// The calls over the limit are rejected right away when queueTime is zero.
+func NewConcurrencyLimit(maxCalls int, queueTime time.Duration, perService bool) (*ConcurrencyLimit, error) {
+	if maxCalls <= 0 {
+		return nil, fmt.Errorf("invalid maximum of concurrent calls %d. It must be greater than 0", maxCalls)
+	}
+	return &ConcurrencyLimit{maxCalls: maxCalls, queueTime: queueTime, perService: perService, slots: make(map[string]chan struct{})}, nil
+}
+
+// UnaryInterceptor limits the unary calls in flight.
+func (c *ConcurrencyLimit) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	release, err := c.acquire(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return handler(ctx, req)
+}
+
+// StreamInterceptor limits the streaming calls in flight. A stream is in flight until it ends.
+func (c *ConcurrencyLimit) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	release, err := c.acquire(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return handler(srv, ss)
+}
+
+// acquire takes a slot of the method, waiting for up to the queue time, and returns the function releasing it.
+func (c *ConcurrencyLimit) acquire(ctx context.Context, fullMethod string) (func(), error) {
+	key := fullMethod
+	if c.perService {
+		key = fullMethod[:strings.LastIndex(fullMethod, "/")+1]
+	}
+	c.mutex.Lock()
+	slots, ok := c.slots[key]
+	if !ok {
+		slots = make(chan struct{}, c.maxCalls)
+		c.slots[key] = slots
+	}
+	c.mutex.Unlock()
+
+	release := func() { <-slots }
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if c.queueTime > 0 {
+		timer := time.NewTimer(c.queueTime)
+		defer timer.Stop()
+		select {
+		case slots <- struct{}{}:
+			return release, nil
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case <-timer.C:
+		}
+	}
+	return nil, status.Errorf(codes.Unavailable, "maximum of %d concurrent calls reached for %s", c.maxCalls, strings.TrimSuffix(key, "/"))
+}
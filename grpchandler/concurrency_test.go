@@ -0,0 +1,87 @@
+package grpchandler
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+// startBlockedCall starts a call holding its slot until the channel returned is closed.
+func startBlockedCall(c *ConcurrencyLimit, fullMethod string) chan struct{} {
+	started, done := make(chan struct{}), make(chan struct{})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		close(started)
+		<-done
+		return nil, nil
+	}
+	go c.UnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: fullMethod}, handler)
+	<-started
+	return done
+}
+
+func callWithConcurrencyLimit(c *ConcurrencyLimit, ctx context.Context, fullMethod string) error {
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	_, err := c.UnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: fullMethod}, handler)
+	return err
+}
+
+func TestNewConcurrencyLimit_Invalid(t *testing.T) {
+	_, err := NewConcurrencyLimit(0, 0, false)
+	assert.EqualError(t, err, "invalid maximum of concurrent calls 0. It must be greater than 0")
+}
+
+func TestConcurrencyLimit_Reject(t *testing.T) {
+	c, err := NewConcurrencyLimit(1, 0, false)
+	assert.Nil(t, err)
+	done := startBlockedCall(c, "/pkg.Service/Method")
+
+	err = callWithConcurrencyLimit(c, context.Background(), "/pkg.Service/Method")
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, "maximum of 1 concurrent calls reached for /pkg.Service/Method", status.Convert(err).Message())
+	assert.Nil(t, callWithConcurrencyLimit(c, context.Background(), "/pkg.Service/Other"))
+
+	close(done)
+	assert.Eventually(t, func() bool {
+		return callWithConcurrencyLimit(c, context.Background(), "/pkg.Service/Method") == nil
+	}, time.Second, time.Millisecond)
+}
+
+func TestConcurrencyLimit_PerService(t *testing.T) {
+	c, err := NewConcurrencyLimit(1, 0, true)
+	assert.Nil(t, err)
+	done := startBlockedCall(c, "/pkg.Service/Method")
+	defer close(done)
+
+	err = callWithConcurrencyLimit(c, context.Background(), "/pkg.Service/Other")
+	assert.Equal(t, "maximum of 1 concurrent calls reached for /pkg.Service", status.Convert(err).Message())
+	assert.Nil(t, callWithConcurrencyLimit(c, context.Background(), "/pkg.Another/Method"))
+}
+
+func TestConcurrencyLimit_Queue(t *testing.T) {
+	c, err := NewConcurrencyLimit(1, time.Second, false)
+	assert.Nil(t, err)
+	done := startBlockedCall(c, "/pkg.Service/Method")
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(done)
+	}()
+	assert.Nil(t, callWithConcurrencyLimit(c, context.Background(), "/pkg.Service/Method"))
+
+	c, err = NewConcurrencyLimit(1, 20*time.Millisecond, false)
+	assert.Nil(t, err)
+	done = startBlockedCall(c, "/pkg.Service/Method")
+	defer close(done)
+	start := time.Now()
+	assert.Equal(t, codes.Unavailable, status.Code(callWithConcurrencyLimit(c, context.Background(), "/pkg.Service/Method")))
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(callWithConcurrencyLimit(c, ctx, "/pkg.Service/Method")))
+}
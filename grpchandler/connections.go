@@ -0,0 +1,114 @@
+package grpchandler
+
+import (
+	"encoding/json"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"sync"
+	"time"
+)
+
+const defaultConnectionIdleTimeout = 5 * time.Minute
+
+var connections = &connectionsPool{
+	connections: make(map[string]*pooledConnection, 0),
+	idleTimeout: defaultConnectionIdleTimeout,
+}
+
+// SetForwardConnectionIdleTimeout sets for how long a connection to a server requests are forwarded to is kept open without being used.
+// A timeout of zero keeps the connections open until the connections pool is closed.
+func SetForwardConnectionIdleTimeout(timeout time.Duration) {
+	connections.mutex.Lock()
+	defer connections.mutex.Unlock()
+
+	connections.idleTimeout = timeout
+}
+
+// CloseForwardConnections closes all the connections to the servers requests are forwarded to.
+func CloseForwardConnections() {
+	connections.closeAll()
+}
+
+// connectionsPool reuses the connections to the servers requests are forwarded to.
+// Connections are keyed by address and TLS settings.
+type connectionsPool struct {
+	connections map[string]*pooledConnection
+	idleTimeout time.Duration
+	janitorOnce sync.Once
+	mutex       sync.Mutex
+}
+
+type pooledConnection struct {
+	conn     *grpc.ClientConn
+	inUse    int
+	lastUsed time.Time
+}
+
+// get returns a connection to the address. release must be called once the connection is no longer used by the caller.
+func (p *connectionsPool) get(address string, forward *stub.StubForward) (conn *grpc.ClientConn, release func(), err error) {
+	p.janitorOnce.Do(func() {
+		go p.janitor()
+	})
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	key := connectionKey(address, forward)
+	pooled, ok := p.connections[key]
+	if !ok {
+		conn, err := createConnection(address, forward)
+		if err != nil {
+			return nil, nil, err
+		}
+		pooled = &pooledConnection{conn: conn}
+		p.connections[key] = pooled
+	}
+	pooled.inUse++
+	pooled.lastUsed = time.Now()
+	return pooled.conn, func() {
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+
+		pooled.inUse--
+		pooled.lastUsed = time.Now()
+	}, nil
+}
+
+func (p *connectionsPool) janitor() {
+	for {
+		time.Sleep(time.Second)
+		p.closeIdle(time.Now())
+	}
+}
+
+func (p *connectionsPool) closeIdle(now time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.idleTimeout == 0 {
+		return
+	}
+	for key, pooled := range p.connections {
+		if pooled.inUse == 0 && now.Sub(pooled.lastUsed) > p.idleTimeout {
+			log.Debugf("Closing idle connection to %s", pooled.conn.Target())
+			pooled.conn.Close()
+			delete(p.connections, key)
+		}
+	}
+}
+
+func (p *connectionsPool) closeAll() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for key, pooled := range p.connections {
+		pooled.conn.Close()
+		delete(p.connections, key)
+	}
+}
+
+func connectionKey(address string, forward *stub.StubForward) string {
+	tlsSettings, _ := json.Marshal(forward.TLS)
+	return address + "|" + string(tlsSettings)
+}
@@ -0,0 +1,45 @@
+package grpchandler
+
+import (
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestConnectionsPool_ReusesConnections(t *testing.T) {
+	pool := &connectionsPool{connections: make(map[string]*pooledConnection), idleTimeout: time.Minute}
+	defer pool.closeAll()
+	forward := &stub.StubForward{ServerAddress: "localhost:50010"}
+
+	conn1, release1, err := pool.get("localhost:50010", forward)
+	assert.Nil(t, err)
+	conn2, release2, err := pool.get("localhost:50010", forward)
+	assert.Nil(t, err)
+	conn3, release3, err := pool.get("localhost:50010", &stub.StubForward{TLS: &stub.StubForwardTLS{InsecureSkipVerify: true}})
+	assert.Nil(t, err)
+	release1()
+	release2()
+	release3()
+
+	assert.Same(t, conn1, conn2)
+	assert.NotSame(t, conn1, conn3)
+	assert.Equal(t, 2, len(pool.connections))
+}
+
+func TestConnectionsPool_CloseIdle(t *testing.T) {
+	pool := &connectionsPool{connections: make(map[string]*pooledConnection), idleTimeout: time.Minute}
+	defer pool.closeAll()
+	forward := &stub.StubForward{}
+
+	_, release, _ := pool.get("localhost:50010", forward)
+	_, _, _ = pool.get("localhost:50011", forward)
+	release()
+
+	pool.closeIdle(time.Now().Add(2 * time.Minute))
+	assert.Equal(t, 1, len(pool.connections))
+
+	pool.idleTimeout = 0
+	pool.closeIdle(time.Now().Add(time.Hour))
+	assert.Equal(t, 1, len(pool.connections))
+}
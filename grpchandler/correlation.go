@@ -0,0 +1,70 @@
+package grpchandler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"strings"
+)
+
+// CorrelationID reads the ID correlating the calls across systems from the metadata of the calls, generating one when
+// the client did not send it. The ID is sent back to the client in the header and, as it is added to the metadata of the
+// call, propagated to the servers the call is forwarded to.
+type CorrelationID struct {
+	key string
+}
+
+// NewCorrelationID creates the interceptors reading the ID from the metadata key, e.g. x-request-id.
+func NewCorrelationID(key string) *CorrelationID {
+	return &CorrelationID{key: strings.ToLower(key)}
+}
+
+type correlationIDKey struct{}
+
+// CorrelationIDFromContext returns the correlation ID of the call, or an empty string when the interceptors are not added.
+func CorrelationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// UnaryInterceptor adds the correlation ID to the unary calls. The ID is set in the header once the call is handled.
+func (c *CorrelationID) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, header := withPendingHeader(ctx)
+	ctx, id := c.withCorrelationID(ctx)
+	header.add(metadata.Pairs(c.key, id))
+	defer header.flush(ctx)
+	return handler(ctx, req)
+}
+
+// StreamInterceptor adds the correlation ID to the streaming calls.
+func (c *CorrelationID) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, id := c.withCorrelationID(ss.Context())
+	if err := grpc.SetHeader(ctx, metadata.Pairs(c.key, id)); err != nil {
+		log.Debugf("Could not send the correlation ID %s in the header. Error: %s", id, err)
+	}
+	return handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+}
+
+func (c *CorrelationID) withCorrelationID(ctx context.Context) (context.Context, string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	md = md.Copy()
+	id := ""
+	if values := md.Get(c.key); len(values) > 0 && values[0] != "" {
+		id = values[0]
+	} else {
+		id = generateCorrelationID()
+		md.Set(c.key, id)
+	}
+	return context.WithValue(metadata.NewIncomingContext(ctx, md), correlationIDKey{}, id), id
+}
+
+func generateCorrelationID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		log.Errorf("Failed to generate a correlation ID. Error: %s", err)
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,60 @@
+package grpchandler
+
+import (
+	"bytes"
+	"context"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"testing"
+)
+
+func TestCorrelationID_UnaryInterceptor_PropagatesID(t *testing.T) {
+	correlationID := NewCorrelationID("X-Request-Id")
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "abc"))
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		assert.Equal(t, "abc", CorrelationIDFromContext(ctx))
+		md, _ := metadata.FromOutgoingContext(createForwardContext(ctx, &stub.StubForward{}))
+		assert.Equal(t, []string{"abc"}, md.Get("x-request-id"))
+		return nil, nil
+	}
+
+	_, err := correlationID.UnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Method"}, handler)
+	assert.Nil(t, err)
+}
+
+func TestCorrelationID_UnaryInterceptor_GeneratesID(t *testing.T) {
+	correlationID := NewCorrelationID("x-request-id")
+	incoming := metadata.Pairs("authorization", "token")
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		id := CorrelationIDFromContext(ctx)
+		assert.Equal(t, 32, len(id))
+		md, _ := metadata.FromIncomingContext(ctx)
+		assert.Equal(t, []string{id}, md.Get("x-request-id"))
+		assert.Equal(t, []string{"token"}, md.Get("authorization"))
+		return nil, nil
+	}
+
+	_, err := correlationID.UnaryInterceptor(metadata.NewIncomingContext(context.Background(), incoming), nil, &grpc.UnaryServerInfo{}, handler)
+	assert.Nil(t, err)
+	assert.Empty(t, incoming.Get("x-request-id"))
+}
+
+func TestCorrelationID_LoggedInAccessLog(t *testing.T) {
+	out := new(bytes.Buffer)
+	accessLog := NewAccessLog(out, 0, nil)
+	correlationID := NewCorrelationID("x-request-id")
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "abc"))
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return new(errdetails.ErrorInfo), nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Method"}
+
+	_, err := correlationID.UnaryInterceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return accessLog.UnaryInterceptor(ctx, req, info, handler)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", readAccessLogEntry(t, out).CorrelationID)
+}
@@ -0,0 +1,53 @@
+package grpchandler
+
+import (
+	"context"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"time"
+)
+
+// waitDelay blocks for the delay or until the call is cancelled or its deadline expires, whichever happens first.
+// The error returned has the status matching the reason the context ended (DEADLINE_EXCEEDED or CANCELLED).
+func waitDelay(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// throttle waits for the time the message takes to be sent at the bandwidth, e.g. 256KB/s, to simulate a slow network.
+func throttle(ctx context.Context, message interface{}, bandwidth string) error {
+	bytesPerSecond, err := stub.ParseBandwidth(bandwidth)
+	m, ok := message.(proto.Message)
+	if err != nil || !ok {
+		return nil
+	}
+	return waitDelay(ctx, time.Duration(float64(proto.Size(m))/float64(bytesPerSecond)*float64(time.Second)))
+}
+
+// applyResponseDelay waits before the mock response is returned.
+// When the stub must exceed the deadline the call is held until the client deadline expires, after the delay if there is no deadline.
+func applyResponseDelay(ctx context.Context, response *stub.StubResponse) error {
+	delay, _ := time.ParseDuration(response.Delay)
+	if err := waitDelay(ctx, delay); err != nil {
+		return err
+	}
+	if !response.ExceedDeadline {
+		return nil
+	}
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		return status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	}
+	<-ctx.Done()
+	return status.FromContextError(ctx.Err()).Err()
+}
@@ -0,0 +1,63 @@
+package grpchandler
+
+import (
+	"context"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+	"time"
+)
+
+func TestApplyResponseDelay_WaitsForDelay(t *testing.T) {
+	start := time.Now()
+	err := applyResponseDelay(context.Background(), &stub.StubResponse{Delay: "20ms"})
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+}
+
+func TestApplyResponseDelay_AbortsWhenDeadlineExpires(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := applyResponseDelay(ctx, &stub.StubResponse{Delay: "5s"})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestApplyResponseDelay_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := applyResponseDelay(ctx, &stub.StubResponse{Delay: "5s"})
+	assert.Equal(t, codes.Canceled, status.Code(err))
+}
+
+func TestApplyResponseDelay_ExceedDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := applyResponseDelay(ctx, &stub.StubResponse{ExceedDeadline: true})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+}
+
+func TestApplyResponseDelay_ExceedDeadlineWithoutDeadline(t *testing.T) {
+	err := applyResponseDelay(context.Background(), &stub.StubResponse{ExceedDeadline: true})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestThrottle(t *testing.T) {
+	// 1002 bytes sent at 50KB/s take about 20ms
+	message := wrapperspb.String(string(make([]byte, 1000)))
+	start := time.Now()
+	assert.Nil(t, throttle(context.Background(), message, "50KB/s"))
+	assert.True(t, time.Since(start) >= 19*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(throttle(ctx, message, "10B/s")))
+	assert.True(t, time.Since(start) < time.Second)
+}
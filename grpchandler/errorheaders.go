@@ -0,0 +1,103 @@
+package grpchandler
+
+import (
+	"context"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"sync"
+)
+
+// pendingHeader is the metadata added to the header of a unary call by the interceptors, e.g. the correlation ID. It is
+// set once the handler returns, so that the mock error responses can send the header first or not at all, see
+// stub.StubResponse.ErrorHeaders.
+type pendingHeader struct {
+	mutex sync.Mutex
+	md    metadata.MD
+	// the metadata was already sent by the handler
+	sent bool
+}
+
+type pendingHeaderKey struct{}
+
+// withPendingHeader returns the context holding the pending header of the call, adding one when there is none.
+func withPendingHeader(ctx context.Context) (context.Context, *pendingHeader) {
+	if header, ok := ctx.Value(pendingHeaderKey{}).(*pendingHeader); ok {
+		return ctx, header
+	}
+	header := &pendingHeader{md: metadata.MD{}}
+	return context.WithValue(ctx, pendingHeaderKey{}, header), header
+}
+
+func (h *pendingHeader) add(md metadata.MD) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.md = metadata.Join(h.md, md)
+}
+
+// take returns the metadata to send, or nil when it was already sent.
+func (h *pendingHeader) take() metadata.MD {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.sent {
+		return nil
+	}
+	h.sent = true
+	return h.md
+}
+
+// flush sets the header of the call with the pending metadata, unless the handler already sent it.
+func (h *pendingHeader) flush(ctx context.Context) {
+	md := h.take()
+	if len(md) == 0 {
+		return
+	}
+	if err := grpc.SetHeader(ctx, md); err != nil {
+		log.Debugf("Could not send the metadata %v in the header. Error: %s", md, err)
+	}
+}
+
+// sendErrorHeaders shapes the error response of a mock stub on the wire: with trailers-only the status is sent alone,
+// with the pending metadata in the trailer instead of the header, and with headers-first the header is sent before the
+// status.
+func sendErrorHeaders(ctx context.Context, errorHeaders string) {
+	md := metadata.MD{}
+	if header, ok := ctx.Value(pendingHeaderKey{}).(*pendingHeader); ok {
+		md = metadata.Join(md, header.take())
+	}
+	var err error
+	switch errorHeaders {
+	case "trailers-only":
+		if len(md) > 0 {
+			err = grpc.SetTrailer(ctx, md)
+		}
+	case "headers-first":
+		err = grpc.SendHeader(ctx, md)
+	}
+	if err != nil {
+		log.Errorf("Failed to send the error response %s. Error: %s", errorHeaders, err)
+	}
+}
+
+// setResponseMetadata sets the header and the trailer of the response of the mock stub. The header is added to the
+// pending header of the call when there is one, so that it is sent as the error response requires, and to the trailer
+// of the trailers-only error responses.
+func setResponseMetadata(ctx context.Context, s *stub.Stub, requestJson string) {
+	header, trailer := stub.ResponseMetadata(ctx, s, requestJson)
+	if s.Response.Type == "error" && s.Response.ErrorHeaders == "trailers-only" {
+		header, trailer = nil, metadata.Join(header, trailer)
+	}
+	if len(header) > 0 {
+		if pending, ok := ctx.Value(pendingHeaderKey{}).(*pendingHeader); ok {
+			pending.add(header)
+		} else if err := grpc.SetHeader(ctx, header); err != nil {
+			log.Errorf("Failed to set the header of the response for %s. Error: %s", s.FullMethod, err)
+		}
+	}
+	if len(trailer) > 0 {
+		if err := grpc.SetTrailer(ctx, trailer); err != nil {
+			log.Errorf("Failed to set the trailer of the response for %s. Error: %s", s.FullMethod, err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package grpchandler
+
+import (
+	"context"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"net"
+	"testing"
+)
+
+// startErrorHeadersServer serves the mock of /pkg.Service/Method, with StringValue messages, with the correlation ID
+// interceptor, and returns the connection to it.
+func startErrorHeadersServer(t *testing.T, stubsMatcher stub.StubsMatcher) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.UnaryInterceptor(NewCorrelationID("x-request-id").UnaryInterceptor))
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "pkg.Service",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Method",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := new(wrapperspb.StringValue)
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Method"}
+				return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+					return MockHandler(ctx, stubsMatcher, info.FullMethod, req, new(wrapperspb.StringValue))
+				})
+			},
+		}},
+	}, struct{}{})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return listener.Dial()
+	}), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestMockHandler_ErrorHeaders(t *testing.T) {
+	store := stub.NewInMemoryStubsStore()
+	for _, name := range []string{"default", "trailers-only", "headers-first"} {
+		s := &stub.Stub{
+			FullMethod: "/pkg.Service/Method",
+			Type:       "mock",
+			Request:    &stub.StubRequest{Match: "exact", Content: stub.JsonString(`"` + name + `"`)},
+			Response:   &stub.StubResponse{Type: "error", Error: &stub.ErrorResponse{Code: uint32(codes.NotFound), Message: name}},
+		}
+		if name != "default" {
+			s.Response.ErrorHeaders = name
+		}
+		assert.Nil(t, store.Add(s))
+	}
+	conn := startErrorHeadersServer(t, stub.NewStubsMatcher(store))
+	call := func(name string) (header, trailer metadata.MD) {
+		err := conn.Invoke(context.Background(), "/pkg.Service/Method", wrapperspb.String(name), new(wrapperspb.StringValue), grpc.Header(&header), grpc.Trailer(&trailer))
+		assert.Equal(t, codes.NotFound, status.Code(err), name)
+		return header, trailer
+	}
+
+	// the correlation ID is set in the header
+	header, trailer := call("default")
+	assert.Equal(t, 1, len(header.Get("x-request-id")))
+	assert.Empty(t, trailer.Get("x-request-id"))
+
+	header, trailer = call("trailers-only")
+	assert.Nil(t, header)
+	assert.Equal(t, 1, len(trailer.Get("x-request-id")))
+
+	header, trailer = call("headers-first")
+	assert.Equal(t, 1, len(header.Get("x-request-id")))
+	assert.Empty(t, trailer.Get("x-request-id"))
+}
+
+func TestMockHandler_ResponseMetadata(t *testing.T) {
+	store := stub.NewInMemoryStubsStore()
+	assert.Nil(t, store.Add(&stub.Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &stub.StubRequest{Match: "exact", Content: `"John"`},
+		Response: &stub.StubResponse{
+			Type:     "success",
+			Content:  `"Hello"`,
+			Headers:  map[string][]string{"x-echo-id": {`{{.Metadata "x-request-id"}}`}},
+			Trailers: map[string][]string{"x-user": {"{{.Field ``}}"}},
+		},
+	}))
+	assert.Nil(t, store.Add(&stub.Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &stub.StubRequest{Match: "exact", Content: `"Mary"`},
+		Response: &stub.StubResponse{
+			Type:         "error",
+			Error:        &stub.ErrorResponse{Code: uint32(codes.NotFound), Message: "{{.Field ``}} not found ({{.Metadata `x-request-id`}})"},
+			Headers:      map[string][]string{"x-echo-id": {`{{.Metadata "x-request-id"}}`}},
+			ErrorHeaders: "trailers-only",
+		},
+	}))
+	conn := startErrorHeadersServer(t, stub.NewStubsMatcher(store))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "abc")
+
+	var header, trailer metadata.MD
+	err := conn.Invoke(ctx, "/pkg.Service/Method", wrapperspb.String("John"), new(wrapperspb.StringValue), grpc.Header(&header), grpc.Trailer(&trailer))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"abc"}, header.Get("x-echo-id"))
+	assert.Equal(t, []string{"abc"}, header.Get("x-request-id"))
+	assert.Equal(t, []string{"John"}, trailer.Get("x-user"))
+
+	header, trailer = nil, nil
+	err = conn.Invoke(ctx, "/pkg.Service/Method", wrapperspb.String("Mary"), new(wrapperspb.StringValue), grpc.Header(&header), grpc.Trailer(&trailer))
+	assert.Equal(t, "Mary not found (abc)", status.Convert(err).Message())
+	assert.Nil(t, header)
+	assert.Equal(t, []string{"abc"}, trailer.Get("x-echo-id"))
+}
@@ -2,18 +2,33 @@ package grpchandler
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"github.com/carvalhorr/protoc-gen-mock/stub"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
 )
 
 var supportedMockService MockService
 var recordingsStore stub.RecordingsStore
+var stubsStore stub.StubsStore
+var proxyFallback string
+var recordingFilter *stub.RecordingFilter
+var recordingFilterMutex sync.RWMutex
+var recordingRedaction *stub.RecordingRedaction
+var recordingRedactionMutex sync.RWMutex
 
 func SetSupportedMockService(service MockService) {
 	supportedMockService = service
@@ -23,32 +38,282 @@ func SetRecordingsStore(store stub.RecordingsStore) {
 	recordingsStore = store
 }
 
+func SetStubsStore(store stub.StubsStore) {
+	stubsStore = store
+}
+
+// SetRecordingFilter sets the filter that controls which forwarded calls are recorded. A nil filter records all calls.
+func SetRecordingFilter(filter *stub.RecordingFilter) {
+	recordingFilterMutex.Lock()
+	defer recordingFilterMutex.Unlock()
+
+	recordingFilter = filter
+}
+
+func GetRecordingFilter() *stub.RecordingFilter {
+	recordingFilterMutex.RLock()
+	defer recordingFilterMutex.RUnlock()
+
+	return recordingFilter
+}
+
+// SetRecordingRedaction sets the rules used to redact sensitive values from the forwarded calls before they are recorded.
+// A nil redaction records the calls verbatim.
+func SetRecordingRedaction(redaction *stub.RecordingRedaction) {
+	recordingRedactionMutex.Lock()
+	defer recordingRedactionMutex.Unlock()
+
+	recordingRedaction = redaction
+}
+
+func GetRecordingRedaction() *stub.RecordingRedaction {
+	recordingRedactionMutex.RLock()
+	defer recordingRedactionMutex.RUnlock()
+
+	return recordingRedaction
+}
+
+// SetProxyFallback sets the address where requests without a matching stub are forwarded to. An empty address disables it.
+func SetProxyFallback(address string) {
+	proxyFallback = address
+}
+
 func forwardAndRecord(s *stub.Stub, ctx context.Context, fullMethod string, req, resp interface{}) (_ interface{}, err error) {
-	if s.Type != "forward" {
-		return nil, status.Error(codes.Internal, "Attempt to cal forward for a stub that is not of type 'forward'")
+	if !s.IsForwarding() {
+		return nil, status.Error(codes.Internal, "Attempt to cal forward for a stub that is not of type 'forward' or 'passthrough'")
 	}
-	log.Infof("Forwarding to %s (%s -> %s)", s.Forward.ServerAddress, fullMethod, s.Request.String())
-	conn := createConnection(s.Forward)
-	defer conn.Close()
-
-	resp, err = supportedMockService.ForwardRequest(conn, ctx, fullMethod, req)
-	log.Infof("Got forward response %s and error %s", toProtoJson(resp), errToString(err))
-	if s.Forward.Record {
+	resp, err = forwardWithRetry(s, ctx, fullMethod, req)
+	if s.Forward.Record || s.Type == "passthrough" {
 		log.Infof("Recording is active for stub %s -> %s", fullMethod, s.Request.String())
 		recordRequestAndResponse(ctx, fullMethod, req, resp, err)
 	}
+	dropped := false
+	if s.Forward.Transform != nil {
+		resp, dropped, err = transformResponse(ctx, s.Forward.Transform, resp, err)
+	}
+	if s.Forward.Record && s.Forward.Replay != "" && !dropped && answeredByServer(ctx, err) {
+		replayResponse(s, fullMethod, req, resp, err)
+	}
 	return resp, err
 }
 
-func createConnection(forward *stub.StubForward) *grpc.ClientConn {
+// answeredByServer tells whether the forwarded call was answered by the server, with a response or an error, instead of
+// failed before reaching it, e.g. because the connection couldn't be created or the call was cancelled.
+func answeredByServer(ctx context.Context, err error) bool {
+	var connErr *connectionError
+	return err == nil || (!errors.As(err, &connErr) && ctx.Err() == nil)
+}
 
-	options := make([]grpc.DialOption, 0)
-	options = append(options, grpc.WithInsecure()) // TODO deal with security
-	conn, err := grpc.Dial(forward.ServerAddress, options...)
+// transformResponse applies the transformation to the response received from the server.
+// The delay is applied first, then the response is either replaced by the error or has its fields overridden, and finally
+// sent at the bandwidth. dropped tells that the response of the server was dropped, because the transformation failed or
+// replaced it with an error, so that it is not replayed.
+func transformResponse(ctx context.Context, transform *stub.StubForwardTransform, resp interface{}, err error) (_ interface{}, dropped bool, _ error) {
+	if transform.Delay != "" {
+		delay, _ := time.ParseDuration(transform.Delay)
+		if err := waitDelay(ctx, delay); err != nil {
+			return nil, true, err
+		}
+	}
+	if transform.Error != nil {
+		return nil, true, stub.GetErrorResponse(transform.Error)
+	}
 	if err != nil {
-		log.Errorf("Failed to create connection to %s", forward.ServerAddress)
+		return resp, false, err
+	}
+	if transform.Content != "" {
+		merged, mergeErr := toProtoJson(resp).Merge(transform.Content)
+		if mergeErr == nil {
+			mergeErr = protojson.Unmarshal([]byte(merged), resp.(proto.Message))
+		}
+		if mergeErr != nil {
+			log.Errorf("Failed to transform forward response. Error: %s", mergeErr)
+			return nil, true, status.Error(codes.Internal, "Failed to transform forward response")
+		}
 	}
-	return conn
+	if transform.Bandwidth != "" {
+		if err := throttle(ctx, resp, transform.Bandwidth); err != nil {
+			return nil, true, err
+		}
+	}
+	return resp, false, nil
+}
+
+func forwardToProxyFallback(ctx context.Context, fullMethod, requestJson string, req, resp interface{}) (_ interface{}, err error) {
+	return forwardAndRecord(createProxyFallbackStub(fullMethod, requestJson), ctx, fullMethod, req, resp)
+}
+
+func createProxyFallbackStub(fullMethod, requestJson string) *stub.Stub {
+	return &stub.Stub{
+		FullMethod: fullMethod,
+		Type:       "forward",
+		Request: &stub.StubRequest{
+			Match:   "exact",
+			Content: stub.JsonString(requestJson),
+		},
+		Forward: &stub.StubForward{
+			ServerAddress: proxyFallbackFor(fullMethod),
+		},
+	}
+}
+
+// forwardWithRetry forwards the request until it succeeds, fails with a non retryable status or the attempts are exhausted.
+func forwardWithRetry(s *stub.Stub, ctx context.Context, fullMethod string, req interface{}) (resp interface{}, err error) {
+	retry := s.Forward.Retry
+	if retry == nil {
+		retry = &stub.StubForwardRetry{Attempts: 1}
+	}
+	backoff, _ := time.ParseDuration(retry.Backoff)
+	for attempt := uint32(1); ; attempt++ {
+		resp, err = forwardToTargets(s, ctx, fullMethod, req)
+		if attempt >= retry.Attempts || !isRetryable(retry, err) {
+			return resp, err
+		}
+		log.Infof("Retrying forward of %s (attempt %d of %d)", fullMethod, attempt+1, retry.Attempts)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+func isRetryable(retry *stub.StubForwardRetry, err error) bool {
+	if err == nil {
+		return false
+	}
+	code := status.Code(err)
+	if len(retry.RetryableCodes) == 0 {
+		return code == codes.Unavailable
+	}
+	for _, retryableCode := range retry.RetryableCodes {
+		if codes.Code(retryableCode) == code {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardToTargets tries the servers in the order defined by the forward balancing until one of them is available.
+func forwardToTargets(s *stub.Stub, ctx context.Context, fullMethod string, req interface{}) (resp interface{}, err error) {
+	for _, address := range upstreams.targets(s.Forward) {
+		log.Debugf("Forwarding to %s (%s -> %s)", address, fullMethod, s.Request.String())
+		resp, err = forwardToAddress(address, s.Forward, ctx, fullMethod, req)
+		log.Debugf("Got forward response %s and error %s", toProtoJson(resp), errToString(err))
+		upstreams.record(address, err)
+		if !isUpstreamFailure(err) {
+			break
+		}
+	}
+	return resp, err
+}
+
+func forwardToAddress(address string, forward *stub.StubForward, ctx context.Context, fullMethod string, req interface{}) (interface{}, error) {
+	conn, release, err := connections.get(address, forward)
+	if err != nil {
+		log.Errorf("Failed to create connection to %s. Error: %s", address, err)
+		return nil, &connectionError{address: address}
+	}
+	defer release()
+
+	forwardCtx := createForwardContext(ctx, forward)
+	if forward.Timeout != "" {
+		timeout, _ := time.ParseDuration(forward.Timeout)
+		var cancel context.CancelFunc
+		forwardCtx, cancel = context.WithTimeout(forwardCtx, timeout)
+		defer cancel()
+	}
+	return supportedMockService.ForwardRequest(conn, forwardCtx, fullMethod, req)
+}
+
+// connectionError is the error of the calls that couldn't be forwarded because the connection to the server couldn't be
+// created, e.g. when its TLS files can't be read. It fails the call with Internal.
+type connectionError struct {
+	address string
+}
+
+func (e *connectionError) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+func (e *connectionError) GRPCStatus() *status.Status {
+	return status.New(codes.Internal, fmt.Sprintf("Failed to create connection to %s", e.address))
+}
+
+func createConnection(address string, forward *stub.StubForward) (*grpc.ClientConn, error) {
+	options := append(make([]grpc.DialOption, 0), forwardDialOptions...)
+	if forward.TLS == nil {
+		options = append(options, grpc.WithInsecure())
+	} else {
+		tlsConfig, err := createTLSConfig(forward.TLS)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	}
+	return grpc.Dial(address, options...)
+}
+
+func createTLSConfig(forwardTLS *stub.StubForwardTLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         forwardTLS.ServerName,
+		InsecureSkipVerify: forwardTLS.InsecureSkipVerify,
+	}
+	if forwardTLS.CAFile != "" {
+		caCert, err := ioutil.ReadFile(forwardTLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA file: %w", err)
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("could not parse CA file %s", forwardTLS.CAFile)
+		}
+		tlsConfig.RootCAs = certPool
+	}
+	if forwardTLS.CertFile != "" || forwardTLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(forwardTLS.CertFile, forwardTLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// createForwardContext creates the context used to call the server requests are forwarded to.
+// The metadata received from the client is sent along with the request after applying the manipulations defined in the forward stub.
+func createForwardContext(ctx context.Context, forward *stub.StubForward) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return metadata.NewOutgoingContext(ctx, forwardMetadata(md, forward))
+}
+
+func forwardMetadata(incoming metadata.MD, forward *stub.StubForward) metadata.MD {
+	md := metadata.MD{}
+	for key, values := range incoming {
+		if isReservedMetadataKey(key) {
+			continue
+		}
+		md[key] = append([]string{}, values...)
+	}
+	for _, key := range forward.RemoveMetadata {
+		delete(md, strings.ToLower(key))
+	}
+	for key, values := range forward.OverrideMetadata {
+		md.Set(key, values...)
+	}
+	for key, values := range forward.AddMetadata {
+		md.Append(key, values...)
+	}
+	return md
+}
+
+// Metadata set by the gRPC transport of the incoming call that must not be sent to the server requests are forwarded to.
+func isReservedMetadataKey(key string) bool {
+	switch key {
+	case "content-type", "user-agent", "te":
+		return true
+	}
+	return strings.HasPrefix(key, ":")
 }
 
 func recordRequestAndResponse(ctx context.Context, fullMethod string, req, resp interface{}, err error) {
@@ -67,12 +332,54 @@ func recordRequestAndResponse(ctx context.Context, fullMethod string, req, resp
 		},
 		Forward: nil,
 	}
+	addRecording(s)
+}
+
+func addRecording(s *stub.Stub) {
+	if !GetRecordingFilter().Allows(s) {
+		log.Debugf("Recording of %s -> %s skipped by the recording filter", s.FullMethod, s.Request.String())
+		return
+	}
+	// a recording that can't be redacted is not stored to avoid leaking sensitive data
+	if redactErr := GetRecordingRedaction().Redact(s); redactErr != nil {
+		log.Errorf("Failed to redact recording of %s. Error: %s", s.FullMethod, redactErr)
+		return
+	}
 	addErr := recordingsStore.Add(s)
 	if addErr != nil {
 		log.Errorf("Failed to record forwarding result. Error: %s", addErr)
 	}
 }
 
+// replayResponse adds the response returned to the client as a mock stub so that subsequent identical calls are not forwarded.
+// The request content is matched according to the replay type of the forward stub and
+// the metadata used to match the forward stub is kept.
+func replayResponse(forwardStub *stub.Stub, fullMethod string, req, resp interface{}, err error) {
+	s := &stub.Stub{
+		FullMethod: fullMethod,
+		Type:       "mock",
+		Request: &stub.StubRequest{
+			Match:    forwardStub.Forward.Replay,
+			Content:  toProtoJson(req),
+			Metadata: forwardStub.Request.Metadata,
+		},
+		Response: &stub.StubResponse{
+			Type:    getResponseType(resp, err),
+			Content: toProtoJson(resp),
+			Error:   mapError(err),
+		},
+	}
+	store := stubsStoreFor(fullMethod)
+	if store.Exists(s) {
+		return
+	}
+	log.Infof("Adding replay stub for %s -> %s", s.FullMethod, s.Request.String())
+	addErr := store.Add(s)
+	if addErr != nil {
+		log.Errorf("Failed to add replay stub. Error: %s", addErr)
+	}
+}
+
 func getMetadata(ctx context.Context) map[string][]string {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
@@ -88,8 +395,13 @@ func getResponseType(resp interface{}, err error) string {
 	return "success"
 }
 
+// toProtoJson marshals the message to JSON. The response of a failed call may be nil, which is marshaled as empty.
 func toProtoJson(instance interface{}) stub.JsonString {
-	bytes, err := protojson.Marshal(instance.(proto.Message))
+	message, ok := instance.(proto.Message)
+	if !ok {
+		return ""
+	}
+	bytes, err := protojson.Marshal(message)
 	if err != nil {
 		log.Errorf("Failed to marshal to JSON.")
 	}
@@ -103,7 +415,39 @@ func errToString(err error) string {
 	return err.Error()
 }
 
+// mapError returns the error of a recording. Its details are given by the full name of their message, resolved from the
+// messages linked in the mock server, e.g. google.rpc.RetryInfo. The details of other messages can't be read, so they are
+// left out.
 func mapError(err error) *stub.ErrorResponse {
-	// TODO Map error response when forwarding for recording
-	return nil
+	if err == nil {
+		return nil
+	}
+	st := status.Convert(err)
+	return &stub.ErrorResponse{
+		Code:    uint32(st.Code()),
+		Message: st.Message(),
+		Details: mapErrorDetails(st),
+	}
+}
+
+// mapErrorDetails returns the details of the status, the first one setting the spec of the details and the next ones
+// overriding it when their message is different.
+func mapErrorDetails(st *status.Status) *stub.ErrorDetails {
+	var details *stub.ErrorDetails
+	for _, detail := range st.Proto().GetDetails() {
+		message, err := detail.UnmarshalNew()
+		if err != nil {
+			log.Warnf("Error details %s left out of the recording. Error: %s", detail.GetTypeUrl(), err)
+			continue
+		}
+		value := stub.ErrorDetailsValue{Value: toProtoJson(message)}
+		spec := &stub.ErrorDetailsSpec{Type: string(message.ProtoReflect().Descriptor().FullName())}
+		if details == nil {
+			details = &stub.ErrorDetails{Spec: spec}
+		} else if details.Spec.Type != spec.Type {
+			value.SpecOverride = spec
+		}
+		details.Values = append(details.Values, value)
+	}
+	return details
 }
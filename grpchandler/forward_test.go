@@ -0,0 +1,335 @@
+package grpchandler
+
+import (
+	"context"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"testing"
+	"time"
+)
+
+func TestCreateTLSConfig_ServerNameAndInsecureSkipVerify(t *testing.T) {
+	tlsConfig, err := createTLSConfig(&stub.StubForwardTLS{
+		ServerName:         "greeter.internal",
+		InsecureSkipVerify: true,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "greeter.internal", tlsConfig.ServerName)
+	assert.True(t, tlsConfig.InsecureSkipVerify)
+	assert.Nil(t, tlsConfig.RootCAs)
+	assert.Empty(t, tlsConfig.Certificates)
+}
+
+func TestCreateTLSConfig_CAFileNotFound(t *testing.T) {
+	_, err := createTLSConfig(&stub.StubForwardTLS{
+		CAFile: "does-not-exist.pem",
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "could not read CA file")
+}
+
+func TestCreateTLSConfig_ClientCertificateNotFound(t *testing.T) {
+	_, err := createTLSConfig(&stub.StubForwardTLS{
+		CertFile: "does-not-exist.crt",
+		KeyFile:  "does-not-exist.key",
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "could not load client certificate")
+}
+
+func TestForwardMetadata(t *testing.T) {
+	incoming := metadata.MD{
+		":authority":   []string{"localhost:10010"},
+		"content-type": []string{"application/grpc"},
+		"user-agent":   []string{"grpc-go/1.35.0"},
+		"x-client-id":  []string{"client-1"},
+		"x-tenant":     []string{"tenant-1"},
+		"x-trace":      []string{"trace-1"},
+	}
+	md := forwardMetadata(incoming, &stub.StubForward{
+		RemoveMetadata:   []string{"X-Client-Id"},
+		OverrideMetadata: map[string][]string{"x-tenant": {"tenant-2"}},
+		AddMetadata:      map[string][]string{"Authorization": {"Bearer token"}, "x-trace": {"trace-2"}},
+	})
+	assert.Equal(t, metadata.MD{
+		"authorization": []string{"Bearer token"},
+		"x-tenant":      []string{"tenant-2"},
+		"x-trace":       []string{"trace-1", "trace-2"},
+	}, md)
+	assert.Equal(t, []string{"tenant-1"}, incoming.Get("x-tenant"))
+}
+
+func TestTransformResponse_OverridesContent(t *testing.T) {
+	resp := &descriptorpb.FileDescriptorProto{Name: proto.String("greeter.proto"), Package: proto.String("greeter")}
+	transformed, dropped, err := transformResponse(context.Background(), &stub.StubForwardTransform{
+		Content: `{"package": ""}`,
+	}, resp, nil)
+	assert.Nil(t, err)
+	assert.False(t, dropped)
+	assert.Equal(t, "greeter.proto", transformed.(*descriptorpb.FileDescriptorProto).GetName())
+	assert.Equal(t, "", transformed.(*descriptorpb.FileDescriptorProto).GetPackage())
+}
+
+func TestTransformResponse_ReplacesStatus(t *testing.T) {
+	resp := &descriptorpb.FileDescriptorProto{Name: proto.String("greeter.proto")}
+	transformed, dropped, err := transformResponse(context.Background(), &stub.StubForwardTransform{
+		Error: &stub.ErrorResponse{Code: uint32(codes.NotFound), Message: "not found"},
+	}, resp, nil)
+	assert.Nil(t, transformed)
+	assert.True(t, dropped)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "not found", status.Convert(err).Message())
+}
+
+func TestTransformResponse_DelayRespectsContextDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, dropped, err := transformResponse(ctx, &stub.StubForwardTransform{Delay: "1s"}, &descriptorpb.FileDescriptorProto{}, nil)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.True(t, dropped)
+}
+
+func TestTransformResponse_KeepsServerError(t *testing.T) {
+	transformed, dropped, err := transformResponse(context.Background(), &stub.StubForwardTransform{
+		Content: `{"package": ""}`,
+	}, (*descriptorpb.FileDescriptorProto)(nil), status.Error(codes.NotFound, "not found"))
+	assert.Equal(t, (*descriptorpb.FileDescriptorProto)(nil), transformed)
+	assert.False(t, dropped)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+type fakeForwardService struct {
+	MockService
+	errors []error
+	calls  int
+}
+
+func (f *fakeForwardService) ForwardRequest(conn grpc.ClientConnInterface, ctx context.Context, methodName string, req interface{}) (interface{}, error) {
+	err := f.errors[f.calls]
+	f.calls++
+	return &descriptorpb.FileDescriptorProto{}, err
+}
+
+func TestForwardWithRetry_RetriesRetryableCodes(t *testing.T) {
+	service := &fakeForwardService{errors: []error{
+		status.Error(codes.ResourceExhausted, "slow down"),
+		status.Error(codes.Unavailable, "unavailable"),
+		nil,
+	}}
+	SetSupportedMockService(service)
+	s := &stub.Stub{
+		Request: &stub.StubRequest{},
+		Forward: &stub.StubForward{
+			ServerAddress: "localhost:50010",
+			Retry: &stub.StubForwardRetry{
+				Attempts:       3,
+				Backoff:        "1ms",
+				RetryableCodes: []uint32{uint32(codes.ResourceExhausted), uint32(codes.Unavailable)},
+			},
+		},
+	}
+
+	_, err := forwardWithRetry(s, context.Background(), "/pkg.Service/Method", &descriptorpb.FileDescriptorProto{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, service.calls)
+}
+
+func TestForwardWithRetry_DoesNotRetryOtherCodes(t *testing.T) {
+	service := &fakeForwardService{errors: []error{
+		status.Error(codes.NotFound, "not found"),
+		nil,
+	}}
+	SetSupportedMockService(service)
+	s := &stub.Stub{
+		Request: &stub.StubRequest{},
+		Forward: &stub.StubForward{
+			ServerAddress: "localhost:50010",
+			Retry:         &stub.StubForwardRetry{Attempts: 3},
+		},
+	}
+
+	_, err := forwardWithRetry(s, context.Background(), "/pkg.Service/Method", &descriptorpb.FileDescriptorProto{})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, 1, service.calls)
+}
+
+func TestForwardAndRecord_PassthroughAlwaysRecords(t *testing.T) {
+	SetSupportedMockService(&fakeForwardService{errors: []error{nil}})
+	store := stub.NewRecordingsStore()
+	SetRecordingsStore(store)
+	s := &stub.Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "passthrough",
+		Request:    &stub.StubRequest{Match: "partial", Content: "{}"},
+		Forward:    &stub.StubForward{ServerAddress: "localhost:50010"},
+	}
+
+	_, err := forwardAndRecord(s, context.Background(), "/pkg.Service/Method", &descriptorpb.FileDescriptorProto{}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(store.GetAllStubs()))
+	assert.Equal(t, stub.StubType("mock"), store.GetAllStubs()[0].Type)
+}
+
+func TestForwardAndRecord_ConnectionFailure(t *testing.T) {
+	SetSupportedMockService(&fakeForwardService{errors: []error{nil}})
+	s := &stub.Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "forward",
+		Request:    &stub.StubRequest{Match: "partial", Content: "{}"},
+		Forward:    &stub.StubForward{ServerAddress: "localhost:50011", TLS: &stub.StubForwardTLS{CAFile: "does-not-exist.pem"}},
+	}
+
+	resp, err := forwardAndRecord(s, context.Background(), "/pkg.Service/Method", &descriptorpb.FileDescriptorProto{}, nil)
+	assert.Nil(t, resp)
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, "Failed to create connection to localhost:50011", status.Convert(err).Message())
+}
+
+func TestForwardAndRecord_RecordsCancelledRetry(t *testing.T) {
+	SetSupportedMockService(&fakeForwardService{errors: []error{status.Error(codes.Unavailable, "unavailable"), nil}})
+	store := stub.NewRecordingsStore()
+	SetRecordingsStore(store)
+	s := &stub.Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "forward",
+		Request:    &stub.StubRequest{Match: "partial", Content: "{}"},
+		Forward: &stub.StubForward{
+			ServerAddress: "localhost:50010",
+			Record:        true,
+			Retry:         &stub.StubForwardRetry{Attempts: 2, Backoff: "1s"},
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	resp, err := forwardAndRecord(s, ctx, "/pkg.Service/Method", &descriptorpb.FileDescriptorProto{}, nil)
+	assert.Nil(t, resp)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Equal(t, 1, len(store.GetAllStubs()))
+	assert.Equal(t, "error", store.GetAllStubs()[0].Response.Type)
+	assert.Equal(t, uint32(codes.DeadlineExceeded), store.GetAllStubs()[0].Response.Error.Code)
+}
+
+func TestForwardAndRecord_TransformErrorNotReplayed(t *testing.T) {
+	SetSupportedMockService(&fakeForwardService{errors: []error{nil}})
+	SetRecordingsStore(stub.NewRecordingsStore())
+	store := stub.NewInMemoryStubsStore()
+	SetStubsStore(store)
+	s := &stub.Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "forward",
+		Request:    &stub.StubRequest{Match: "partial", Content: "{}"},
+		Forward: &stub.StubForward{
+			ServerAddress: "localhost:50010",
+			Record:        true,
+			Replay:        "exact",
+			Transform:     &stub.StubForwardTransform{Error: &stub.ErrorResponse{Code: uint32(codes.NotFound), Message: "not found"}},
+		},
+	}
+
+	resp, err := forwardAndRecord(s, context.Background(), "/pkg.Service/Method", &descriptorpb.FileDescriptorProto{}, nil)
+	assert.Nil(t, resp)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Empty(t, store.GetAllStubs())
+}
+
+func TestForwardAndRecord_ReplaysServerErrors(t *testing.T) {
+	SetSupportedMockService(&fakeForwardService{errors: []error{status.Error(codes.NotFound, "not found")}})
+	SetRecordingsStore(stub.NewRecordingsStore())
+	store := stub.NewInMemoryStubsStore()
+	SetStubsStore(store)
+	s := &stub.Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "forward",
+		Request:    &stub.StubRequest{Match: "partial", Content: "{}"},
+		Forward:    &stub.StubForward{ServerAddress: "localhost:50010", Record: true, Replay: "exact"},
+	}
+
+	_, err := forwardAndRecord(s, context.Background(), "/pkg.Service/Method", &descriptorpb.FileDescriptorProto{}, nil)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	if assert.Equal(t, 1, len(store.GetAllStubs())) {
+		assert.Equal(t, uint32(codes.NotFound), store.GetAllStubs()[0].Response.Error.Code)
+	}
+
+	// the calls that didn't reach the server are not replayed
+	s.Forward.TLS = &stub.StubForwardTLS{CAFile: "does-not-exist.pem"}
+	s.Forward.ServerAddress = "localhost:50014"
+	_, err = forwardAndRecord(s, context.Background(), "/pkg.Service/Method", &descriptorpb.FileDescriptorProto{Name: proto.String("other")}, nil)
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, 1, len(store.GetAllStubs()))
+}
+
+func TestForwardToTargets_FailsOverConnectionFailures(t *testing.T) {
+	service := &fakeForwardService{errors: []error{nil}}
+	SetSupportedMockService(service)
+	s := &stub.Stub{
+		Request: &stub.StubRequest{},
+		Forward: &stub.StubForward{
+			ServerAddresses: []string{"localhost:50012", "localhost:50013"},
+			TLS:             &stub.StubForwardTLS{CAFile: "does-not-exist.pem"},
+		},
+	}
+	// the connection to the second server is already open, so only the first one fails to read the TLS files
+	conn, err := grpc.Dial("localhost:50013", grpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+	connections.mutex.Lock()
+	connections.connections[connectionKey("localhost:50013", s.Forward)] = &pooledConnection{conn: conn}
+	connections.mutex.Unlock()
+
+	_, err = forwardToTargets(s, context.Background(), "/pkg.Service/Method", &descriptorpb.FileDescriptorProto{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, service.calls)
+	assert.False(t, upstreamHealth("localhost:50012").Healthy)
+	assert.True(t, upstreamHealth("localhost:50013").Healthy)
+}
+
+func upstreamHealth(address string) UpstreamHealth {
+	for _, health := range upstreams.GetAll() {
+		if health.Address == address {
+			return health
+		}
+	}
+	return UpstreamHealth{}
+}
+
+func TestMapError_Details(t *testing.T) {
+	st, err := status.New(codes.Unavailable, "try later").WithDetails(
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Second)},
+		&errdetails.ErrorInfo{Reason: "OVERLOADED"},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(2 * time.Second)})
+	assert.Nil(t, err)
+	withUnknown := st.Proto()
+	withUnknown.Details = append(withUnknown.Details, &anypb.Any{TypeUrl: "type.googleapis.com/pkg.Unknown"})
+
+	mapped := mapError(status.ErrorProto(withUnknown))
+	assert.Equal(t, uint32(codes.Unavailable), mapped.Code)
+	assert.Equal(t, "try later", mapped.Message)
+	assert.Equal(t, &stub.ErrorDetailsSpec{Type: "google.rpc.RetryInfo"}, mapped.Details.Spec)
+	if assert.Equal(t, 3, len(mapped.Details.Values)) {
+		assert.Nil(t, mapped.Details.Values[0].SpecOverride)
+		assert.Equal(t, &stub.ErrorDetailsSpec{Type: "google.rpc.ErrorInfo"}, mapped.Details.Values[1].SpecOverride)
+		assert.Nil(t, mapped.Details.Values[2].SpecOverride)
+	}
+
+	// the error of the recording is the one of the server, without the details that can't be read
+	replayed := status.Convert(stub.GetErrorResponse(mapped))
+	assert.Equal(t, codes.Unavailable, replayed.Code())
+	if assert.Equal(t, 3, len(replayed.Details())) {
+		for i, detail := range st.Details() {
+			assert.True(t, proto.Equal(detail.(proto.Message), replayed.Details()[i].(proto.Message)))
+		}
+	}
+
+	assert.Nil(t, mapError(status.Error(codes.NotFound, "not found")).Details)
+	assert.Nil(t, mapError(nil))
+}
@@ -0,0 +1,27 @@
+package grpchandler
+
+// GenerationInfo describes how the mock service was generated, so that the API implemented by a running mock server can
+// be confirmed.
+type GenerationInfo struct {
+	Service       string `json:"service"`
+	PluginVersion string `json:"pluginVersion"`
+	// Version of protoc. Empty when the code was generated with a compiler that doesn't send it, e.g. buf.
+	ProtocVersion string `json:"protocVersion,omitempty"`
+	ProtoFile     string `json:"protoFile"`
+	// SHA-256 of the descriptor of the proto file, in hex
+	ProtoFileHash string `json:"protoFileHash"`
+}
+
+// GenerationInfoProvider is implemented by the mock services generated with the versions of the plugin recording it.
+type GenerationInfoProvider interface {
+	GetGenerationInfo() []GenerationInfo
+}
+
+// GetGenerationInfo returns how the services were generated or nil if it isn't known.
+func GetGenerationInfo(service MockService) []GenerationInfo {
+	provider, ok := service.(GenerationInfoProvider)
+	if !ok {
+		return nil
+	}
+	return provider.GetGenerationInfo()
+}
@@ -7,24 +7,57 @@ import (
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
+	"time"
 )
 
 // MockInterceptor intercepts the gRPC calls for the registered services return canned responses previously loaded through the REST API.
 var MockHandler = func(ctx context.Context, stubsMatcher stub.StubsMatcher, fullMethod string, req interface{}, resp interface{}) (_ interface{}, err error) {
+	defer latencies.record(fullMethod, time.Now())
+	if err := chaos.inject(ctx, fullMethod); err != nil {
+		return nil, err
+	}
 	paramsJson, err := getRequestInJSON(req)
 	if err != nil {
 		logError(fullMethod, paramsJson, err)
 		return nil, err
 	}
 	s := stubsMatcher.Match(ctx, fullMethod, paramsJson)
+	annotateSpan(ctx, s)
+	recordMatchedStub(ctx, s)
+	if s == nil {
+		unmatchedCalls.record(fullMethod, paramsJson)
+	}
+	if s == nil && proxyFallbackFor(fullMethod) != "" {
+		return forwardToProxyFallback(ctx, fullMethod, paramsJson, req, resp)
+	}
 	if s == nil {
-		log.Infof("NO mock response found for %s --> %s", fullMethod, paramsJson)
 		return nil, fmt.Errorf("no response found")
 	}
-	if s.Type == "forward" {
+	if s.IsForwarding() {
 		return forwardAndRecord(s, ctx, fullMethod, req, resp)
 	}
-	return stub.GetResponse(s, paramsJson, resp)
+	if s.Response.Delay != "" || s.Response.ExceedDeadline {
+		if err := applyResponseDelay(ctx, s.Response); err != nil {
+			return nil, err
+		}
+	}
+	// the templates of the response share their state with the other stubs of the store of the matcher
+	ctx = stub.WithTemplateState(ctx, stubsMatcher)
+	setResponseMetadata(ctx, s, paramsJson)
+	if s.Response.Type == "remote" {
+		resp, err = remoteResponse(ctx, s, paramsJson, resp)
+	} else {
+		resp, err = stub.GetCallResponse(ctx, s, paramsJson, resp)
+	}
+	if err != nil && s.Response.Type == "error" && s.Response.ErrorHeaders != "" {
+		sendErrorHeaders(ctx, s.Response.ErrorHeaders)
+	}
+	if err == nil && s.Response.Bandwidth != "" {
+		if err := throttle(ctx, resp, s.Response.Bandwidth); err != nil {
+			return nil, err
+		}
+	}
+	return resp, err
 }
 
 func logError(fullMethod, paramsJSON string, err error) {
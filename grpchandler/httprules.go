@@ -0,0 +1,24 @@
+package grpchandler
+
+// HTTPRule maps an HTTP method and path template to a gRPC method as defined by the google.api.http annotation.
+type HTTPRule struct {
+	FullMethod   string
+	Method       string // GET | PUT | POST | DELETE | PATCH or the kind of a custom pattern
+	Path         string // path template, e.g. /v1/{name=shelves/*}/books/{book_id}
+	Body         string // request field the HTTP body is mapped to. '*' for the whole request and empty when there is no body.
+	ResponseBody string // response field used as the HTTP body. Empty for the whole response.
+}
+
+// HTTPRulesProvider is implemented by the mock services of the proto services with google.api.http annotations.
+type HTTPRulesProvider interface {
+	GetHTTPRules() []HTTPRule
+}
+
+// GetHTTPRules returns the HTTP rules of the service or nil if it doesn't have any.
+func GetHTTPRules(service MockService) []HTTPRule {
+	provider, ok := service.(HTTPRulesProvider)
+	if !ok {
+		return nil
+	}
+	return provider.GetHTTPRules()
+}
@@ -0,0 +1,118 @@
+package grpchandler
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencySamples is the number of the most recent durations of each method the percentiles are calculated from.
+const latencySamples = 1024
+
+// MethodLatency is the time taken to serve the calls of a method, including the delays of the stubs and the forwarded calls.
+type MethodLatency struct {
+	Method string `json:"method"`
+	// Number of calls served since the start or the last reset
+	Count uint64 `json:"count"`
+	// Percentiles of the most recent calls, in milliseconds
+	P50Ms float64 `json:"p50Ms"`
+	P95Ms float64 `json:"p95Ms"`
+	P99Ms float64 `json:"p99Ms"`
+	// Longest call since the start or the last reset, in milliseconds
+	MaxMs float64 `json:"maxMs"`
+}
+
+type LatencyStats interface {
+	GetAll() []MethodLatency
+	Reset()
+}
+
+// GetLatencyStats returns the latency of the calls served for each method.
+func GetLatencyStats() LatencyStats {
+	return latencies
+}
+
+var latencies = &latencyRegistry{methods: make(map[string]*methodSamples)}
+
+type latencyRegistry struct {
+	methods map[string]*methodSamples
+	mutex   sync.Mutex
+}
+
+// methodSamples keeps the most recent durations in a ring buffer.
+type methodSamples struct {
+	durations []time.Duration
+	next      int
+	count     uint64
+	max       time.Duration
+}
+
+func (l *latencyRegistry) record(fullMethod string, start time.Time) {
+	duration := time.Since(start)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	samples, ok := l.methods[fullMethod]
+	if !ok {
+		samples = &methodSamples{durations: make([]time.Duration, 0, latencySamples)}
+		l.methods[fullMethod] = samples
+	}
+	if len(samples.durations) < latencySamples {
+		samples.durations = append(samples.durations, duration)
+	} else {
+		samples.durations[samples.next] = duration
+		samples.next = (samples.next + 1) % latencySamples
+	}
+	samples.count++
+	if duration > samples.max {
+		samples.max = duration
+	}
+}
+
+func (l *latencyRegistry) GetAll() []MethodLatency {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	all := make([]MethodLatency, 0, len(l.methods))
+	for method, samples := range l.methods {
+		sorted := append([]time.Duration(nil), samples.durations...)
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i] < sorted[j]
+		})
+		all = append(all, MethodLatency{
+			Method: method,
+			Count:  samples.count,
+			P50Ms:  toMilliseconds(percentile(sorted, 50)),
+			P95Ms:  toMilliseconds(percentile(sorted, 95)),
+			P99Ms:  toMilliseconds(percentile(sorted, 99)),
+			MaxMs:  toMilliseconds(samples.max),
+		})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Method < all[j].Method
+	})
+	return all
+}
+
+func (l *latencyRegistry) Reset() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.methods = make(map[string]*methodSamples)
+}
+
+// percentile uses the nearest-rank method on the sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func toMilliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
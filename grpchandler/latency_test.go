@@ -0,0 +1,53 @@
+package grpchandler
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLatencyRegistry_GetAll(t *testing.T) {
+	registry := &latencyRegistry{methods: make(map[string]*methodSamples)}
+	now := time.Now()
+	for i := 1; i <= 100; i++ {
+		registry.record("/pkg.Service/Method", now.Add(-time.Duration(i)*time.Millisecond))
+	}
+	registry.record("/pkg.Service/Another", now)
+
+	all := registry.GetAll()
+	if assert.Equal(t, 2, len(all)) {
+		assert.Equal(t, "/pkg.Service/Another", all[0].Method)
+		latency := all[1]
+		assert.Equal(t, uint64(100), latency.Count)
+		assert.InDelta(t, 50, latency.P50Ms, 5)
+		assert.InDelta(t, 95, latency.P95Ms, 5)
+		assert.InDelta(t, 99, latency.P99Ms, 5)
+		assert.True(t, latency.MaxMs >= 100)
+	}
+
+	registry.Reset()
+	assert.Empty(t, registry.GetAll())
+}
+
+func TestLatencyRegistry_KeepsRecentSamples(t *testing.T) {
+	registry := &latencyRegistry{methods: make(map[string]*methodSamples)}
+	now := time.Now()
+	for i := 0; i < latencySamples; i++ {
+		registry.record("/pkg.Service/Method", now.Add(-time.Second))
+	}
+	for i := 0; i < latencySamples; i++ {
+		registry.record("/pkg.Service/Method", now)
+	}
+
+	latency := registry.GetAll()[0]
+	assert.Equal(t, uint64(2*latencySamples), latency.Count)
+	assert.True(t, latency.P99Ms < 1000)
+	assert.True(t, latency.MaxMs >= 1000)
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4}
+	assert.Equal(t, time.Duration(2), percentile(sorted, 50))
+	assert.Equal(t, time.Duration(4), percentile(sorted, 99))
+	assert.Equal(t, time.Duration(0), percentile(nil, 50))
+}
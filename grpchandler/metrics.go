@@ -0,0 +1,186 @@
+package grpchandler
+
+import (
+	"context"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MetricsLabelMethod = "method"
+	MetricsLabelStubID = "stub_id"
+	MetricsLabelCode   = "code"
+	// value of all the labels of the calls recorded once the maximum number of series is reached
+	metricsOverflowValue = "other"
+)
+
+// Metrics counts the gRPC calls and their duration in the Prometheus text format. The labels are configurable, and the
+// number of series is limited so that services with many methods or stubs do not overload the monitoring system.
+type Metrics struct {
+	labels    []string
+	maxSeries int
+	series    map[string]*callSeries
+	// calls recorded in the overflow series because the maximum number of series was reached
+	dropped uint64
+	// memory used by the responses of the recordings, see SetRecordingsStats
+	recordingsStats func() stub.SpillStats
+	mutex           sync.Mutex
+}
+
+type callSeries struct {
+	values   []string
+	count    uint64
+	duration time.Duration
+}
+
+// NewMetrics creates the metrics with the labels, any of method, stub_id and code, in the order provided. Once there are
+// maxSeries series the calls of new label values are recorded in a series with all the labels set to "other". There is no
+// limit when maxSeries is zero.
+func NewMetrics(labels []string, maxSeries int) (*Metrics, error) {
+	for _, label := range labels {
+		switch label {
+		case MetricsLabelMethod, MetricsLabelStubID, MetricsLabelCode:
+		default:
+			return nil, fmt.Errorf("invalid metrics label '%s'. Use method, stub_id or code", label)
+		}
+	}
+	return &Metrics{labels: labels, maxSeries: maxSeries, series: make(map[string]*callSeries)}, nil
+}
+
+// SetRecordingsStats adds the metrics of the memory used by the responses of the recordings, e.g. the Stats of a
+// stub.SpillingRecordingsStore.
+func (m *Metrics) SetRecordingsStats(stats func() stub.SpillStats) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.recordingsStats = stats
+}
+
+// UnaryInterceptor records the unary calls.
+func (m *Metrics) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	ctx, c := withCallStub(ctx)
+	resp, err := handler(ctx, req)
+	m.record(info.FullMethod, c, err, time.Since(start))
+	return resp, err
+}
+
+// StreamInterceptor records the streaming calls.
+func (m *Metrics) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx, c := withCallStub(ss.Context())
+	err := handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+	m.record(info.FullMethod, c, err, time.Since(start))
+	return err
+}
+
+func (m *Metrics) record(fullMethod string, c *callStub, err error, duration time.Duration) {
+	values := make([]string, 0, len(m.labels))
+	for _, label := range m.labels {
+		switch label {
+		case MetricsLabelMethod:
+			values = append(values, fullMethod)
+		case MetricsLabelStubID:
+			if c.stub != nil {
+				values = append(values, c.stub.ID())
+			} else {
+				values = append(values, "")
+			}
+		case MetricsLabelCode:
+			values = append(values, status.Code(err).String())
+		}
+	}
+	key := strings.Join(values, "\x00")
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	series, ok := m.series[key]
+	if !ok && m.maxSeries > 0 && len(m.series) >= m.maxSeries {
+		m.dropped++
+		for i := range values {
+			values[i] = metricsOverflowValue
+		}
+		key = strings.Join(values, "\x00")
+		series, ok = m.series[key]
+	}
+	if !ok {
+		series = &callSeries{values: values}
+		m.series[key] = series
+	}
+	series.count++
+	series.duration += duration
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(writer)
+}
+
+// WriteTo writes the metrics in the Prometheus text format, with the series sorted by labels.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mutex.Lock()
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	calls := new(strings.Builder)
+	durations := new(strings.Builder)
+	for _, key := range keys {
+		series := m.series[key]
+		labels := m.formatLabels(series.values)
+		fmt.Fprintf(calls, "mock_grpc_calls_total%s %d\n", labels, series.count)
+		fmt.Fprintf(durations, "mock_grpc_call_duration_seconds_sum%s %g\n", labels, series.duration.Seconds())
+		fmt.Fprintf(durations, "mock_grpc_call_duration_seconds_count%s %d\n", labels, series.count)
+	}
+	dropped := m.dropped
+	recordingsStats := m.recordingsStats
+	m.mutex.Unlock()
+
+	out := new(strings.Builder)
+	out.WriteString("# HELP mock_grpc_calls_total Number of gRPC calls served by the mock server.\n")
+	out.WriteString("# TYPE mock_grpc_calls_total counter\n")
+	out.WriteString(calls.String())
+	out.WriteString("# HELP mock_grpc_call_duration_seconds Time taken to serve the gRPC calls.\n")
+	out.WriteString("# TYPE mock_grpc_call_duration_seconds summary\n")
+	out.WriteString(durations.String())
+	out.WriteString("# HELP mock_metrics_overflow_calls_total Number of calls recorded with the \"other\" labels because of the series limit.\n")
+	out.WriteString("# TYPE mock_metrics_overflow_calls_total counter\n")
+	fmt.Fprintf(out, "mock_metrics_overflow_calls_total %d\n", dropped)
+	if recordingsStats != nil {
+		stats := recordingsStats()
+		out.WriteString("# HELP mock_recordings_resident_bytes Size of the responses of the recordings kept in memory.\n")
+		out.WriteString("# TYPE mock_recordings_resident_bytes gauge\n")
+		fmt.Fprintf(out, "mock_recordings_resident_bytes %d\n", stats.ResidentBytes)
+		out.WriteString("# HELP mock_recordings_spilled Number of recordings whose response is written to disk.\n")
+		out.WriteString("# TYPE mock_recordings_spilled gauge\n")
+		fmt.Fprintf(out, "mock_recordings_spilled %d\n", stats.Spilled)
+		out.WriteString("# HELP mock_recordings_evictions_total Number of responses of recordings written to disk to stay in the memory budget.\n")
+		out.WriteString("# TYPE mock_recordings_evictions_total counter\n")
+		fmt.Fprintf(out, "mock_recordings_evictions_total %d\n", stats.Evictions)
+	}
+	n, err := io.WriteString(w, out.String())
+	return int64(n), err
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (m *Metrics) formatLabels(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(values))
+	for i, value := range values {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, m.labels[i], labelValueEscaper.Replace(value)))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,93 @@
+package grpchandler
+
+import (
+	"bytes"
+	"context"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"testing"
+)
+
+func callWithMetrics(m *Metrics, fullMethod string, s *stub.Stub, err error) {
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		recordMatchedStub(ctx, s)
+		return nil, err
+	}
+	m.UnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: fullMethod}, handler)
+}
+
+func seriesLines(t *testing.T, m *Metrics, prefix string) []string {
+	out := new(bytes.Buffer)
+	_, err := m.WriteTo(out)
+	assert.Nil(t, err)
+	lines := make([]string, 0)
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.HasPrefix(line, prefix) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestMetrics_Labels(t *testing.T) {
+	m, err := NewMetrics([]string{MetricsLabelMethod, MetricsLabelStubID, MetricsLabelCode}, 0)
+	assert.Nil(t, err)
+	s := &stub.Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &stub.StubRequest{Match: "exact", Content: `{}`}}
+	callWithMetrics(m, "/pkg.Service/Method", s, nil)
+	callWithMetrics(m, "/pkg.Service/Method", s, nil)
+	callWithMetrics(m, "/pkg.Service/Method", nil, status.Error(codes.Unknown, "no response found"))
+
+	assert.Equal(t, []string{
+		`mock_grpc_calls_total{method="/pkg.Service/Method",stub_id="",code="Unknown"} 1`,
+		`mock_grpc_calls_total{method="/pkg.Service/Method",stub_id="` + s.ID() + `",code="OK"} 2`,
+	}, seriesLines(t, m, "mock_grpc_calls_total"))
+	assert.Equal(t, 2, len(seriesLines(t, m, "mock_grpc_call_duration_seconds_count")))
+}
+
+func TestMetrics_NoLabels(t *testing.T) {
+	m, err := NewMetrics(nil, 0)
+	assert.Nil(t, err)
+	callWithMetrics(m, "/pkg.Service/Method", nil, nil)
+	callWithMetrics(m, "/pkg.Service/Other", nil, nil)
+
+	assert.Equal(t, []string{"mock_grpc_calls_total 2"}, seriesLines(t, m, "mock_grpc_calls_total"))
+}
+
+func TestMetrics_MaxSeries(t *testing.T) {
+	m, err := NewMetrics([]string{MetricsLabelMethod}, 2)
+	assert.Nil(t, err)
+	for _, method := range []string{"/pkg.Service/A", "/pkg.Service/B", "/pkg.Service/C", "/pkg.Service/D", "/pkg.Service/A"} {
+		callWithMetrics(m, method, nil, nil)
+	}
+
+	assert.Equal(t, []string{
+		`mock_grpc_calls_total{method="/pkg.Service/A"} 2`,
+		`mock_grpc_calls_total{method="/pkg.Service/B"} 1`,
+		`mock_grpc_calls_total{method="other"} 2`,
+	}, seriesLines(t, m, "mock_grpc_calls_total"))
+	assert.Equal(t, []string{"mock_metrics_overflow_calls_total 2"}, seriesLines(t, m, "mock_metrics_overflow_calls_total"))
+}
+
+func TestNewMetrics_InvalidLabel(t *testing.T) {
+	_, err := NewMetrics([]string{"peer"}, 0)
+	assert.EqualError(t, err, "invalid metrics label 'peer'. Use method, stub_id or code")
+}
+
+func TestMetrics_RecordingsStats(t *testing.T) {
+	m, err := NewMetrics(nil, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, seriesLines(t, m, "mock_recordings"))
+
+	m.SetRecordingsStats(func() stub.SpillStats {
+		return stub.SpillStats{ResidentBytes: 2048, Spilled: 3, Evictions: 4}
+	})
+	assert.Equal(t, []string{
+		"mock_recordings_resident_bytes 2048",
+		"mock_recordings_spilled 3",
+		"mock_recordings_evictions_total 4",
+	}, seriesLines(t, m, "mock_recordings"))
+}
@@ -45,6 +45,22 @@ func (c compositeMockService) GetSupportedMethods() []string {
 	return methods
 }
 
+func (c compositeMockService) GetHTTPRules() []HTTPRule {
+	rules := make([]HTTPRule, 0)
+	for _, mockService := range c.mockServices {
+		rules = append(rules, GetHTTPRules(mockService)...)
+	}
+	return rules
+}
+
+func (c compositeMockService) GetGenerationInfo() []GenerationInfo {
+	infos := make([]GenerationInfo, 0)
+	for _, mockService := range c.mockServices {
+		infos = append(infos, GetGenerationInfo(mockService)...)
+	}
+	return infos
+}
+
 func (c compositeMockService) GetPayloadExamples() []stub.Stub {
 	examples := make([]stub.Stub, 0)
 	for _, mockService := range c.mockServices {
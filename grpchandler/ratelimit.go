@@ -0,0 +1,104 @@
+package grpchandler
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit simulates the throttling of a real server with a token bucket per method, or per method and value of a
+// metadata key, e.g. the API key of the client. The calls exceeding the rate fail with RESOURCE_EXHAUSTED and a RetryInfo
+// detail with the time until the next call is allowed. Add its interceptors to the gRPC server to enable it.
+type RateLimit struct {
+	// calls per second
+	rate float64
+	// calls allowed at once after a period without calls
+	burst float64
+	// metadata key the buckets are split by, in addition to the method
+	key     string
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+	mutex   sync.Mutex
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimit creates the rate limit of rate calls per second with bursts of up to burst calls, at least one. The buckets
+// are per method, and per value of the metadata key too when it is not empty.
+func NewRateLimit(rate float64, burst int, key string) (*RateLimit, error) {
+	if rate <= 0 {
+		return nil, fmt.Errorf("invalid rate limit %g. It must be greater than 0", rate)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimit{
+		rate:    rate,
+		burst:   float64(burst),
+		key:     strings.ToLower(key),
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}, nil
+}
+
+// UnaryInterceptor rejects the unary calls exceeding the rate.
+func (r *RateLimit) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := r.take(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor rejects the streaming calls exceeding the rate. Each stream counts as one call.
+func (r *RateLimit) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := r.take(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// take removes a token from the bucket of the call, or returns the error of the call when the bucket is empty.
+func (r *RateLimit) take(ctx context.Context, fullMethod string) error {
+	bucketKey := fullMethod
+	if r.key != "" {
+		md, _ := metadata.FromIncomingContext(ctx)
+		bucketKey += "\x00" + strings.Join(md.Get(r.key), ",")
+	}
+
+	r.mutex.Lock()
+	now := r.now()
+	bucket, ok := r.buckets[bucketKey]
+	if !ok {
+		bucket = &tokenBucket{tokens: r.burst, last: now}
+		r.buckets[bucketKey] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.last).Seconds() * r.rate
+	if bucket.tokens > r.burst {
+		bucket.tokens = r.burst
+	}
+	bucket.last = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		r.mutex.Unlock()
+		return nil
+	}
+	retryDelay := time.Duration((1 - bucket.tokens) / r.rate * float64(time.Second))
+	r.mutex.Unlock()
+
+	st := status.New(codes.ResourceExhausted, fmt.Sprintf("rate limit of %g calls per second exceeded for %s", r.rate, fullMethod))
+	if withDetails, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryDelay)}); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
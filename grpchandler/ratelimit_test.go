@@ -0,0 +1,64 @@
+package grpchandler
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func callWithRateLimit(r *RateLimit, ctx context.Context, fullMethod string) error {
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	_, err := r.UnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: fullMethod}, handler)
+	return err
+}
+
+func TestNewRateLimit_InvalidRate(t *testing.T) {
+	_, err := NewRateLimit(0, 1, "")
+	assert.EqualError(t, err, "invalid rate limit 0. It must be greater than 0")
+}
+
+func TestRateLimit_UnaryInterceptor(t *testing.T) {
+	r, err := NewRateLimit(2, 2, "")
+	assert.Nil(t, err)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	assert.Nil(t, callWithRateLimit(r, ctx, "/pkg.Service/Method"))
+	assert.Nil(t, callWithRateLimit(r, ctx, "/pkg.Service/Method"))
+	err = callWithRateLimit(r, ctx, "/pkg.Service/Method")
+	st := status.Convert(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	assert.Equal(t, "rate limit of 2 calls per second exceeded for /pkg.Service/Method", st.Message())
+	assert.Equal(t, 1, len(st.Details()))
+	retryInfo, ok := st.Details()[0].(*errdetails.RetryInfo)
+	assert.True(t, ok)
+	assert.Equal(t, 500*time.Millisecond, retryInfo.RetryDelay.AsDuration())
+
+	// the buckets are per method
+	assert.Nil(t, callWithRateLimit(r, ctx, "/pkg.Service/Other"))
+
+	now = now.Add(500 * time.Millisecond)
+	assert.Nil(t, callWithRateLimit(r, ctx, "/pkg.Service/Method"))
+	assert.Error(t, callWithRateLimit(r, ctx, "/pkg.Service/Method"))
+}
+
+func TestRateLimit_MetadataKey(t *testing.T) {
+	r, err := NewRateLimit(1, 0, "X-Api-Key")
+	assert.Nil(t, err)
+	clientA := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "a"))
+	clientB := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "b"))
+
+	assert.Nil(t, callWithRateLimit(r, clientA, "/pkg.Service/Method"))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(callWithRateLimit(r, clientA, "/pkg.Service/Method")))
+	assert.Nil(t, callWithRateLimit(r, clientB, "/pkg.Service/Method"))
+	assert.Nil(t, callWithRateLimit(r, context.Background(), "/pkg.Service/Method"))
+}
@@ -0,0 +1,106 @@
+package grpchandler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"io"
+	"net/http"
+)
+
+// maxRemoteResponseSize is the maximum size of the content returned by the HTTP remote responders.
+const maxRemoteResponseSize = 64 << 20
+
+// remoteCall is the call sent to the remote responders, see stub.StubRemote.
+type remoteCall struct {
+	FullMethod string              `json:"fullMethod"`
+	Request    json.RawMessage     `json:"request"`
+	Metadata   map[string][]string `json:"metadata,omitempty"`
+}
+
+// remoteResponse loads in resp the response of the remote responder of the stub, or its fallback when the responder
+// fails.
+func remoteResponse(ctx context.Context, s *stub.Stub, requestJson string, resp interface{}) (interface{}, error) {
+	remote := s.Response.Remote
+	content, err := callRemoteResponder(ctx, s, requestJson)
+	if err == nil {
+		err = protojson.Unmarshal(content, resp.(proto.Message))
+	}
+	if err == nil {
+		log.Infof("Found REMOTE response for %s", s.FullMethod)
+		return resp, nil
+	}
+	log.Errorf("The remote responder of the stub for %s failed. Error: %s", s.FullMethod, err)
+	fallback := remote.Fallback
+	switch {
+	case fallback == nil:
+		return nil, status.Errorf(codes.Unavailable, "the remote responder of the stub for %s failed: %v", s.FullMethod, err)
+	case fallback.Type == "error":
+		return nil, stub.GetErrorResponse(fallback.Error)
+	}
+	if err := protojson.Unmarshal([]byte(fallback.Content), resp.(proto.Message)); err != nil {
+		logError(s.FullMethod, requestJson, err)
+		return nil, fmt.Errorf("could not unmarshal response")
+	}
+	return resp, nil
+}
+
+// callRemoteResponder sends the call to the HTTP or gRPC endpoint of the stub and returns the content of the response in
+// JSON.
+func callRemoteResponder(ctx context.Context, s *stub.Stub, requestJson string) ([]byte, error) {
+	remote := s.Response.Remote
+	md, _ := metadata.FromIncomingContext(ctx)
+	call, err := json.Marshal(remoteCall{FullMethod: s.FullMethod, Request: json.RawMessage(requestJson), Metadata: md})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, remote.RemoteTimeout())
+	defer cancel()
+	if remote.Url != "" {
+		return callHttpResponder(ctx, remote.Url, call)
+	}
+	return callGrpcResponder(ctx, remote, call)
+}
+
+func callHttpResponder(ctx context.Context, url string, call []byte) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(call))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, fmt.Errorf("%s returned the status %d", url, response.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, maxRemoteResponseSize))
+}
+
+func callGrpcResponder(ctx context.Context, remote *stub.StubRemote, call []byte) ([]byte, error) {
+	request := &structpb.Value{}
+	if err := protojson.Unmarshal(call, request); err != nil {
+		return nil, err
+	}
+	conn, release, err := connections.get(remote.Address, &stub.StubForward{TLS: remote.TLS})
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	response := &structpb.Value{}
+	if err := conn.Invoke(ctx, remote.Method, request, response); err != nil {
+		return nil, err
+	}
+	return protojson.Marshal(response)
+}
@@ -0,0 +1,113 @@
+package grpchandler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func remoteStub(remote *stub.StubRemote) *stub.Stub {
+	return &stub.Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &stub.StubRequest{Match: "partial", Content: "{}"},
+		Response:   &stub.StubResponse{Type: "remote", Remote: remote},
+	}
+}
+
+func TestRemoteResponse_Http(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var call remoteCall
+		if err := json.NewDecoder(r.Body).Decode(&call); err != nil || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var request string
+		_ = json.Unmarshal(call.Request, &request)
+		if request == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(call.FullMethod + " " + request + " " + call.Metadata["tenant"][0])
+	}))
+	defer server.Close()
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("tenant", "a"))
+	s := remoteStub(&stub.StubRemote{Url: server.URL})
+
+	resp, err := remoteResponse(ctx, s, `"hello"`, new(wrapperspb.StringValue))
+	assert.Nil(t, err)
+	assert.Equal(t, "/pkg.Service/Method hello a", resp.(*wrapperspb.StringValue).Value)
+
+	_, err = remoteResponse(ctx, s, `"fail"`, new(wrapperspb.StringValue))
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	s.Response.Remote.Fallback = &stub.StubResponse{Type: "success", Content: `"fallback"`}
+	resp, err = remoteResponse(ctx, s, `"fail"`, new(wrapperspb.StringValue))
+	assert.Nil(t, err)
+	assert.Equal(t, "fallback", resp.(*wrapperspb.StringValue).Value)
+
+	s.Response.Remote.Fallback = &stub.StubResponse{Type: "error", Error: &stub.ErrorResponse{Code: uint32(codes.NotFound), Message: "not found"}}
+	_, err = remoteResponse(ctx, s, `"fail"`, new(wrapperspb.StringValue))
+	assert.EqualError(t, err, "rpc error: code = NotFound desc = not found")
+}
+
+func TestRemoteResponse_HttpTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+	}))
+	defer server.Close()
+	s := remoteStub(&stub.StubRemote{Url: server.URL, Timeout: "10ms", Fallback: &stub.StubResponse{Type: "success", Content: `"late"`}})
+
+	resp, err := remoteResponse(context.Background(), s, `"hello"`, new(wrapperspb.StringValue))
+	assert.Nil(t, err)
+	assert.Equal(t, "late", resp.(*wrapperspb.StringValue).Value)
+}
+
+func TestRemoteResponse_Grpc(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "responder.Responder",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Respond",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				call := new(structpb.Value)
+				if err := dec(call); err != nil {
+					return nil, err
+				}
+				fields := call.GetStructValue().GetFields()
+				return structpb.NewStringValue(fields["fullMethod"].GetStringValue() + " " + fields["request"].GetStringValue()), nil
+			},
+		}},
+	}, struct{}{})
+	go server.Serve(listener)
+	defer server.Stop()
+	s := remoteStub(&stub.StubRemote{Address: listener.Addr().String(), Method: "/responder.Responder/Respond"})
+
+	resp, err := remoteResponse(context.Background(), s, `"hello"`, new(wrapperspb.StringValue))
+	assert.Nil(t, err)
+	assert.Equal(t, "/pkg.Service/Method hello", resp.(*wrapperspb.StringValue).Value)
+
+	s.Response.Remote.Method = "/responder.Responder/Unknown"
+	_, err = remoteResponse(context.Background(), s, `"hello"`, new(wrapperspb.StringValue))
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
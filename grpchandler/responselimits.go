@@ -0,0 +1,170 @@
+package grpchandler
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"strings"
+)
+
+// ResponseLimit limits the size of the responses of a method and the number of messages of its server streams, e.g. to
+// test how the clients handle the responses that are too large without writing large stubs.
+type ResponseLimit struct {
+	// Full method, e.g. /pkg.Service/Method, or service, e.g. pkg.Service, limited
+	Method string `yaml:"method"`
+	// Maximum size in bytes of each message of the responses. Unlimited when zero.
+	MaxSize int `yaml:"maxSize"`
+	// Maximum number of messages of the server streams. Unlimited when zero.
+	MaxMessages int `yaml:"maxMessages"`
+	// What happens to the responses over the limits: reject, the default, fails the call with RESOURCE_EXHAUSTED and
+	// truncate sends them cut to the limits. See truncateMessage.
+	Action string `yaml:"action"` // reject | truncate
+}
+
+var responsesLimited = expvar.NewInt("responsesLimited")
+
+// ResponseLimits applies the limits of the responses of the methods. Add its interceptors to the gRPC server to enable it.
+type ResponseLimits struct {
+	// limits by full method or service
+	limits map[string]ResponseLimit
+}
+
+// NewResponseLimits creates the limits of the responses. The limit of a method takes precedence over the one of its
+// service.
+func NewResponseLimits(limits []ResponseLimit) (*ResponseLimits, error) {
+	r := &ResponseLimits{limits: make(map[string]ResponseLimit)}
+	for _, limit := range limits {
+		if limit.Method == "" {
+			return nil, fmt.Errorf("the method of a response limit is mandatory")
+		}
+		if _, ok := r.limits[limit.Method]; ok {
+			return nil, fmt.Errorf("more than one response limit for %s", limit.Method)
+		}
+		if limit.MaxSize < 0 || limit.MaxMessages < 0 {
+			return nil, fmt.Errorf("invalid response limit for %s. The limits can't be negative", limit.Method)
+		}
+		if limit.Action != "" && limit.Action != "reject" && limit.Action != "truncate" {
+			return nil, fmt.Errorf("invalid response limit action '%s' for %s. Use reject or truncate", limit.Action, limit.Method)
+		}
+		r.limits[limit.Method] = limit
+	}
+	return r, nil
+}
+
+// UnaryInterceptor applies the limit of the size to the responses of the unary calls.
+func (r *ResponseLimits) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	limit, ok := r.get(info.FullMethod)
+	if err != nil || !ok {
+		return resp, err
+	}
+	return limit.limitSize(info.FullMethod, resp)
+}
+
+// StreamInterceptor applies the limits to the messages sent in the streaming calls.
+func (r *ResponseLimits) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	limit, ok := r.get(info.FullMethod)
+	if !ok {
+		return handler(srv, ss)
+	}
+	limited := &limitedServerStream{ServerStream: ss, limit: limit, fullMethod: info.FullMethod}
+	err := handler(srv, limited)
+	if limited.err != nil {
+		return limited.err
+	}
+	return err
+}
+
+func (r *ResponseLimits) get(fullMethod string) (ResponseLimit, bool) {
+	if limit, ok := r.limits[fullMethod]; ok {
+		return limit, true
+	}
+	service := strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(service, "/"); i >= 0 {
+		service = service[:i]
+	}
+	limit, ok := r.limits[service]
+	return limit, ok
+}
+
+// limitSize returns the response, truncated when it is larger than the limit, or the error of the call.
+func (l ResponseLimit) limitSize(fullMethod string, resp interface{}) (interface{}, error) {
+	message, ok := resp.(proto.Message)
+	if !ok || l.MaxSize == 0 {
+		return resp, nil
+	}
+	size := proto.Size(message)
+	if size <= l.MaxSize {
+		return resp, nil
+	}
+	responsesLimited.Add(1)
+	if l.Action != "truncate" {
+		return nil, status.Errorf(codes.ResourceExhausted, "the response of %s is %d bytes, larger than the limit of %d bytes", fullMethod, size, l.MaxSize)
+	}
+	log.Debugf("Truncating the response of %s from %d to %d bytes", fullMethod, size, l.MaxSize)
+	return truncateMessage(message, l.MaxSize)
+}
+
+// truncateMessage returns the message with the fields that don't fit in maxSize bytes removed. The fields are kept in
+// the order of the wire format, where each item of a repeated field of messages is a field, so the message remains valid
+// with the first items of its lists.
+func truncateMessage(message proto.Message, maxSize int) (proto.Message, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	end := 0
+	for end < len(data) {
+		_, _, n := protowire.ConsumeField(data[end:])
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		if end+n > maxSize {
+			break
+		}
+		end += n
+	}
+	truncated := message.ProtoReflect().New().Interface()
+	if err := proto.Unmarshal(data[:end], truncated); err != nil {
+		return nil, err
+	}
+	return truncated, nil
+}
+
+// limitedServerStream applies the limits to the messages sent. The error of a rejected message is kept so that it ends
+// the call, whatever the handler does with it.
+type limitedServerStream struct {
+	grpc.ServerStream
+	limit      ResponseLimit
+	fullMethod string
+	sent       int
+	err        error
+}
+
+func (s *limitedServerStream) SendMsg(m interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.limit.MaxMessages > 0 && s.sent >= s.limit.MaxMessages {
+		responsesLimited.Add(1)
+		if s.limit.Action == "truncate" {
+			log.Debugf("Dropping the message %d of the stream of %s", s.sent+1, s.fullMethod)
+			return nil
+		}
+		s.err = status.Errorf(codes.ResourceExhausted, "the stream of %s has more than the limit of %d messages", s.fullMethod, s.limit.MaxMessages)
+		return s.err
+	}
+	m, err := s.limit.limitSize(s.fullMethod, m)
+	if err != nil {
+		s.err = err
+		return err
+	}
+	s.sent++
+	return s.ServerStream.SendMsg(m)
+}
@@ -0,0 +1,108 @@
+package grpchandler
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+)
+
+func quotaFailure(violations int) *errdetails.QuotaFailure {
+	quota := &errdetails.QuotaFailure{}
+	for i := 0; i < violations; i++ {
+		quota.Violations = append(quota.Violations, &errdetails.QuotaFailure_Violation{Subject: "project:1234", Description: "too many calls"})
+	}
+	return quota
+}
+
+func callWithResponseLimits(r *ResponseLimits, fullMethod string, resp interface{}) (interface{}, error) {
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return resp, nil
+	}
+	return r.UnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: fullMethod}, handler)
+}
+
+func TestNewResponseLimits_Invalid(t *testing.T) {
+	_, err := NewResponseLimits([]ResponseLimit{{MaxSize: 10}})
+	assert.EqualError(t, err, "the method of a response limit is mandatory")
+	_, err = NewResponseLimits([]ResponseLimit{{Method: "pkg.Service", MaxSize: 10}, {Method: "pkg.Service", MaxMessages: 1}})
+	assert.EqualError(t, err, "more than one response limit for pkg.Service")
+	_, err = NewResponseLimits([]ResponseLimit{{Method: "pkg.Service", MaxSize: -1}})
+	assert.EqualError(t, err, "invalid response limit for pkg.Service. The limits can't be negative")
+	_, err = NewResponseLimits([]ResponseLimit{{Method: "pkg.Service", Action: "drop"}})
+	assert.EqualError(t, err, "invalid response limit action 'drop' for pkg.Service. Use reject or truncate")
+}
+
+func TestResponseLimits_UnaryInterceptor(t *testing.T) {
+	r, err := NewResponseLimits([]ResponseLimit{
+		{Method: "pkg.Service", MaxSize: 100},
+		{Method: "/pkg.Service/Truncated", MaxSize: 100, Action: "truncate"},
+	})
+	assert.Nil(t, err)
+	small, large := quotaFailure(2), quotaFailure(10)
+
+	resp, err := callWithResponseLimits(r, "/pkg.Service/Method", small)
+	assert.Nil(t, err)
+	assert.Equal(t, small, resp)
+
+	_, err = callWithResponseLimits(r, "/pkg.Service/Method", large)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, "the response of /pkg.Service/Method is 320 bytes, larger than the limit of 100 bytes", status.Convert(err).Message())
+
+	resp, err = callWithResponseLimits(r, "/pkg.Service/Truncated", large)
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(quotaFailure(3), resp.(proto.Message)))
+
+	// other services are not limited
+	resp, err = callWithResponseLimits(r, "/other.Service/Method", large)
+	assert.Nil(t, err)
+	assert.Equal(t, large, resp)
+}
+
+func TestTruncateMessage_FieldLargerThanTheLimit(t *testing.T) {
+	truncated, err := truncateMessage(wrapperspb.String("a long value"), 5)
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(wrapperspb.String(""), truncated))
+}
+
+type responseLimitsTestStream struct {
+	grpc.ServerStream
+	sent []interface{}
+}
+
+func (s *responseLimitsTestStream) SendMsg(m interface{}) error {
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+func TestResponseLimits_StreamInterceptor(t *testing.T) {
+	r, err := NewResponseLimits([]ResponseLimit{
+		{Method: "/pkg.Service/Rejected", MaxMessages: 2},
+		{Method: "/pkg.Service/Truncated", MaxMessages: 2, MaxSize: 100, Action: "truncate"},
+	})
+	assert.Nil(t, err)
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		for i := 0; i < 3; i++ {
+			// the handler ignores the errors of the messages
+			_ = stream.SendMsg(quotaFailure(10))
+		}
+		return nil
+	}
+
+	stream := &responseLimitsTestStream{}
+	err = r.StreamInterceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: "/pkg.Service/Rejected"}, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, "the stream of /pkg.Service/Rejected has more than the limit of 2 messages", status.Convert(err).Message())
+	assert.Equal(t, 2, len(stream.sent))
+
+	stream = &responseLimitsTestStream{}
+	err = r.StreamInterceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: "/pkg.Service/Truncated"}, handler)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(stream.sent))
+	assert.True(t, proto.Equal(quotaFailure(3), stream.sent[0].(proto.Message)))
+}
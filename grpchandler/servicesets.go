@@ -0,0 +1,62 @@
+package grpchandler
+
+import (
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"sync"
+)
+
+// serviceSet holds the state of a group of mock services that is not shared with the other groups.
+type serviceSet struct {
+	name          string
+	stubsStore    stub.StubsStore
+	proxyFallback string
+}
+
+var serviceSets = &serviceSetsRegistry{methods: make(map[string]*serviceSet)}
+
+type serviceSetsRegistry struct {
+	methods map[string]*serviceSet
+	mutex   sync.RWMutex
+}
+
+// AddServiceSet registers the mock services of a set with its own stubs store, where the replayed calls are added, and
+// its own proxy fallback, used instead of the one set with SetProxyFallback when not empty. A method can only be in one set.
+// Adding a set again replaces it.
+func AddServiceSet(name string, service MockService, store stub.StubsStore, proxyFallback string) error {
+	serviceSets.mutex.Lock()
+	defer serviceSets.mutex.Unlock()
+
+	for _, method := range service.GetSupportedMethods() {
+		if set, ok := serviceSets.methods[method]; ok && set.name != name {
+			return fmt.Errorf("method %s is in the service sets %s and %s", method, set.name, name)
+		}
+	}
+	set := &serviceSet{name: name, stubsStore: store, proxyFallback: proxyFallback}
+	for _, method := range service.GetSupportedMethods() {
+		serviceSets.methods[method] = set
+	}
+	return nil
+}
+
+func (r *serviceSetsRegistry) get(fullMethod string) *serviceSet {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.methods[fullMethod]
+}
+
+// stubsStoreFor returns the stubs store of the set of the method.
+func stubsStoreFor(fullMethod string) stub.StubsStore {
+	if set := serviceSets.get(fullMethod); set != nil && set.stubsStore != nil {
+		return set.stubsStore
+	}
+	return stubsStore
+}
+
+// proxyFallbackFor returns the address the calls of the method without a matching stub are forwarded to.
+func proxyFallbackFor(fullMethod string) string {
+	if set := serviceSets.get(fullMethod); set != nil && set.proxyFallback != "" {
+		return set.proxyFallback
+	}
+	return proxyFallback
+}
@@ -0,0 +1,39 @@
+package grpchandler
+
+import (
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type fakeMethodsService struct {
+	MockService
+	methods []string
+}
+
+func (f fakeMethodsService) GetSupportedMethods() []string {
+	return f.methods
+}
+
+func TestAddServiceSet(t *testing.T) {
+	defer func(registry *serviceSetsRegistry, store stub.StubsStore) {
+		serviceSets, stubsStore = registry, store
+		SetProxyFallback("")
+	}(serviceSets, stubsStore)
+	serviceSets = &serviceSetsRegistry{methods: make(map[string]*serviceSet)}
+	stubsStore = stub.NewInMemoryStubsStore()
+	SetProxyFallback("localhost:50010")
+	ordersStore := stub.NewInMemoryStubsStore()
+
+	assert.Nil(t, AddServiceSet("orders", fakeMethodsService{methods: []string{"/orders.Orders/Get"}}, ordersStore, "orders.staging:443"))
+	assert.Nil(t, AddServiceSet("users", fakeMethodsService{methods: []string{"/users.Users/Get"}}, stub.NewInMemoryStubsStore(), ""))
+	assert.Nil(t, AddServiceSet("orders", fakeMethodsService{methods: []string{"/orders.Orders/Get"}}, ordersStore, "orders.staging:443"))
+	assert.EqualError(t, AddServiceSet("more", fakeMethodsService{methods: []string{"/more.More/Get", "/orders.Orders/Get"}}, stub.NewInMemoryStubsStore(), ""),
+		"method /orders.Orders/Get is in the service sets orders and more")
+
+	assert.Equal(t, "orders.staging:443", proxyFallbackFor("/orders.Orders/Get"))
+	assert.Equal(t, "localhost:50010", proxyFallbackFor("/users.Users/Get"))
+	assert.Equal(t, "localhost:50010", proxyFallbackFor("/more.More/Get"))
+	assert.Equal(t, ordersStore, stubsStoreFor("/orders.Orders/Get"))
+	assert.Equal(t, stubsStore, stubsStoreFor("/more.More/Get"))
+}
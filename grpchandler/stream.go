@@ -0,0 +1,208 @@
+package grpchandler
+
+import (
+	"context"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"sync"
+	"time"
+)
+
+// MockStreamHandler handles the streaming gRPC calls for the registered services.
+// The first message sent by the client is used to find the stub. Only stubs of type 'forward' or 'passthrough' are supported for streaming calls.
+var MockStreamHandler = func(stream grpc.ServerStream, stubsMatcher stub.StubsMatcher, fullMethod string, desc *grpc.StreamDesc) error {
+	defer latencies.record(fullMethod, time.Now())
+	ctx := stream.Context()
+	if err := chaos.inject(ctx, fullMethod); err != nil {
+		return err
+	}
+	firstReq := supportedMockService.GetRequestInstance(fullMethod)
+	paramsJson := "{}"
+	if err := stream.RecvMsg(firstReq); err == io.EOF {
+		firstReq = nil
+	} else if err != nil {
+		return err
+	} else if paramsJson, err = getRequestInJSON(firstReq); err != nil {
+		logError(fullMethod, paramsJson, err)
+		return err
+	}
+	s := stubsMatcher.Match(ctx, fullMethod, paramsJson)
+	annotateSpan(ctx, s)
+	recordMatchedStub(ctx, s)
+	if s == nil {
+		unmatchedCalls.record(fullMethod, paramsJson)
+	}
+	if s == nil && proxyFallbackFor(fullMethod) != "" {
+		s = createProxyFallbackStub(fullMethod, paramsJson)
+	}
+	if s == nil {
+		return fmt.Errorf("no response found")
+	}
+	if !s.IsForwarding() {
+		return fmt.Errorf("mock not implemented for streaming")
+	}
+	return forwardStream(s, stream, fullMethod, desc, firstReq)
+}
+
+// forwardStream proxies the streaming call to the server defined in the forward stub, sending the messages in both directions
+// as they arrive. Headers and trailers received from the server are sent to the client.
+func forwardStream(s *stub.Stub, serverStream grpc.ServerStream, fullMethod string, desc *grpc.StreamDesc, firstReq interface{}) error {
+	ctx := serverStream.Context()
+	forwardCtx, cancel := context.WithCancel(createForwardContext(ctx, s.Forward))
+	defer cancel()
+	if s.Forward.Timeout != "" {
+		timeout, _ := time.ParseDuration(s.Forward.Timeout)
+		forwardCtx, cancel = context.WithTimeout(forwardCtx, timeout)
+		defer cancel()
+	}
+
+	var clientStream grpc.ClientStream
+	var err error
+	for _, address := range upstreams.targets(s.Forward) {
+		log.Debugf("Forwarding stream to %s (%s -> %s)", address, fullMethod, s.Request.String())
+		conn, release, connErr := connections.get(address, s.Forward)
+		if connErr != nil {
+			log.Errorf("Failed to create connection to %s. Error: %s", address, connErr)
+			err = &connectionError{address: address}
+			upstreams.record(address, err)
+			continue
+		}
+		defer release()
+		clientStream, err = conn.NewStream(forwardCtx, desc, fullMethod)
+		upstreams.record(address, err)
+		if !isUpstreamFailure(err) {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if clientStream == nil {
+		return status.Error(codes.Unavailable, "no server available to forward the stream to")
+	}
+
+	recording := &streamRecording{}
+	go func() {
+		sendErr := forwardClientMessages(serverStream, clientStream, fullMethod, firstReq, recording)
+		if sendErr != nil && sendErr != io.EOF {
+			log.Errorf("Failed to forward client messages for %s. Error: %s", fullMethod, sendErr)
+			cancel()
+		}
+	}()
+
+	header, headerErr := clientStream.Header()
+	if headerErr == nil && len(header) > 0 {
+		if err := serverStream.SendHeader(header); err != nil {
+			return err
+		}
+	}
+	var upstreamErr error
+	for {
+		resp := supportedMockService.GetResponseInstance(fullMethod)
+		recvErr := clientStream.RecvMsg(resp)
+		if recvErr == io.EOF {
+			break
+		}
+		if recvErr != nil {
+			upstreamErr = recvErr
+			break
+		}
+		recording.addResponse(resp)
+		if transform := s.Forward.Transform; transform != nil && transform.Bandwidth != "" {
+			if err := throttle(ctx, resp, transform.Bandwidth); err != nil {
+				return err
+			}
+		}
+		if err := serverStream.SendMsg(resp); err != nil {
+			return err
+		}
+	}
+	serverStream.SetTrailer(clientStream.Trailer())
+	log.Debugf("Got forward stream end for %s with error %s", fullMethod, errToString(upstreamErr))
+
+	if s.Forward.Record || s.Type == "passthrough" {
+		log.Infof("Recording is active for stub %s -> %s", fullMethod, s.Request.String())
+		addRecording(recording.toStub(ctx, fullMethod, desc, upstreamErr))
+	}
+	return upstreamErr
+}
+
+// forwardClientMessages sends the messages received from the client to the server until the client closes its side of the stream.
+func forwardClientMessages(serverStream grpc.ServerStream, clientStream grpc.ClientStream, fullMethod string, firstReq interface{}, recording *streamRecording) error {
+	req := firstReq
+	for req != nil {
+		recording.addRequest(req)
+		if err := clientStream.SendMsg(req); err != nil {
+			return err
+		}
+		next := supportedMockService.GetRequestInstance(fullMethod)
+		if err := serverStream.RecvMsg(next); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		req = next
+	}
+	return clientStream.CloseSend()
+}
+
+// streamRecording keeps the messages exchanged in a forwarded streaming call.
+type streamRecording struct {
+	requests  []stub.JsonString
+	responses []stub.JsonString
+	mutex     sync.Mutex
+}
+
+func (r *streamRecording) addRequest(req interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.requests = append(r.requests, toProtoJson(req))
+}
+
+func (r *streamRecording) addResponse(resp interface{}) {
+	if resp == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.responses = append(r.responses, toProtoJson(resp))
+}
+
+func (r *streamRecording) toStub(ctx context.Context, fullMethod string, desc *grpc.StreamDesc, err error) *stub.Stub {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	s := &stub.Stub{
+		FullMethod: fullMethod,
+		Type:       "mock",
+		Request: &stub.StubRequest{
+			Match:    "exact",
+			Content:  stub.JsonString("{}"),
+			Metadata: getMetadata(ctx),
+		},
+		Response: &stub.StubResponse{
+			Type:    getResponseType(nil, err),
+			Content: stub.JsonString("{}"),
+			Error:   mapError(err),
+		},
+	}
+	if len(r.requests) > 0 {
+		s.Request.Content = r.requests[0]
+	}
+	if desc.ClientStreams {
+		s.Request.Stream = append([]stub.JsonString{}, r.requests...)
+	}
+	if desc.ServerStreams {
+		s.Response.Stream = append([]stub.JsonString{}, r.responses...)
+	} else if len(r.responses) > 0 {
+		s.Response.Content = r.responses[0]
+	}
+	return s
+}
@@ -0,0 +1,46 @@
+package grpchandler
+
+import (
+	"context"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestStreamRecording_ServerStreaming(t *testing.T) {
+	recording := &streamRecording{
+		requests:  []stub.JsonString{`{"name":"bob"}`},
+		responses: []stub.JsonString{`{"greeting":"1"}`, `{"greeting":"2"}`},
+	}
+	s := recording.toStub(context.Background(), "/test.Service/ServerStream", &grpc.StreamDesc{ServerStreams: true}, nil)
+	assert.Equal(t, stub.StubType("mock"), s.Type)
+	assert.Equal(t, stub.JsonString(`{"name":"bob"}`), s.Request.Content)
+	assert.Empty(t, s.Request.Stream)
+	assert.Equal(t, "success", s.Response.Type)
+	assert.Equal(t, stub.JsonString("{}"), s.Response.Content)
+	assert.Equal(t, []stub.JsonString{`{"greeting":"1"}`, `{"greeting":"2"}`}, s.Response.Stream)
+}
+
+func TestStreamRecording_ClientStreaming(t *testing.T) {
+	recording := &streamRecording{
+		requests:  []stub.JsonString{`{"name":"a"}`, `{"name":"b"}`},
+		responses: []stub.JsonString{`{"greeting":"a;b"}`},
+	}
+	s := recording.toStub(context.Background(), "/test.Service/ClientStream", &grpc.StreamDesc{ClientStreams: true}, nil)
+	assert.Equal(t, stub.JsonString(`{"name":"a"}`), s.Request.Content)
+	assert.Equal(t, []stub.JsonString{`{"name":"a"}`, `{"name":"b"}`}, s.Request.Stream)
+	assert.Equal(t, stub.JsonString(`{"greeting":"a;b"}`), s.Response.Content)
+	assert.Empty(t, s.Response.Stream)
+}
+
+func TestStreamRecording_Error(t *testing.T) {
+	recording := &streamRecording{}
+	s := recording.toStub(context.Background(), "/test.Service/Bidi", &grpc.StreamDesc{ClientStreams: true, ServerStreams: true}, status.Error(codes.NotFound, "not found"))
+	assert.Equal(t, stub.JsonString("{}"), s.Request.Content)
+	assert.Equal(t, "error", s.Response.Type)
+	assert.Equal(t, uint32(codes.NotFound), s.Response.Error.Code)
+	assert.Equal(t, "not found", s.Response.Error.Message)
+}
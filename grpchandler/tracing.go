@@ -0,0 +1,40 @@
+package grpchandler
+
+import (
+	"context"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+var forwardDialOptions []grpc.DialOption
+
+// SetForwardDialOptions sets options added to the connections to the servers requests are forwarded to, e.g. the stats
+// handler propagating the trace context. Must be called before the first call is forwarded.
+func SetForwardDialOptions(options ...grpc.DialOption) {
+	forwardDialOptions = options
+}
+
+// annotateSpan records the stub matched by the call in the span of the call, created by the server when tracing is enabled.
+func annotateSpan(ctx context.Context, s *stub.Stub) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	if s == nil {
+		span.SetAttributes(attribute.Bool("mock.stub.matched", false))
+		return
+	}
+	span.SetAttributes(
+		attribute.Bool("mock.stub.matched", true),
+		attribute.String("mock.stub.id", s.ID()),
+		attribute.String("mock.stub.type", string(s.Type)),
+	)
+	if s.Name != "" {
+		span.SetAttributes(attribute.String("mock.stub.name", s.Name))
+	}
+	if len(s.Tags) > 0 {
+		span.SetAttributes(attribute.StringSlice("mock.stub.tags", s.Tags))
+	}
+}
@@ -0,0 +1,35 @@
+package grpchandler
+
+import (
+	"context"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+func TestAnnotateSpan_RecordsMatchedStub(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	s := &stub.Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &stub.StubRequest{Match: "exact", Content: `{"name":"John"}`}}
+
+	ctx, span := tracer.Start(context.Background(), "matched")
+	annotateSpan(ctx, s)
+	span.End()
+	ctx, span = tracer.Start(context.Background(), "unmatched")
+	annotateSpan(ctx, nil)
+	span.End()
+	annotateSpan(context.Background(), s)
+
+	spans := recorder.Ended()
+	if assert.Equal(t, 2, len(spans)) {
+		assert.ElementsMatch(t, []attribute.KeyValue{
+			attribute.Bool("mock.stub.matched", true),
+			attribute.String("mock.stub.id", s.ID()),
+			attribute.String("mock.stub.type", "mock"),
+		}, spans[0].Attributes())
+		assert.Equal(t, []attribute.KeyValue{attribute.Bool("mock.stub.matched", false)}, spans[1].Attributes())
+	}
+}
@@ -0,0 +1,165 @@
+package grpchandler
+
+import (
+	"expvar"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Counters published in the runtime stats, served under /debug/vars with the pprof option of the server.
+var (
+	unmatchedCallsVar  = expvar.NewInt("unmatchedCalls")
+	unmatchedAlertsVar = expvar.NewInt("unmatchedCallsAlerts")
+)
+
+// UnmatchedCallsStatus tells whether the number of calls without a matching stub in the window is above the threshold.
+type UnmatchedCallsStatus struct {
+	Alerting bool `json:"alerting"`
+	// Number of unmatched calls in the window
+	Count     int    `json:"count"`
+	Threshold int    `json:"threshold"`
+	Window    string `json:"window"`
+	// Methods of the unmatched calls in the window
+	Methods []string `json:"methods"`
+}
+
+type UnmatchedCallsAlert interface {
+	GetStatus() UnmatchedCallsStatus
+}
+
+// GetUnmatchedCallsAlert returns the status of the calls without a matching stub.
+func GetUnmatchedCallsAlert() UnmatchedCallsAlert {
+	return unmatchedCalls
+}
+
+// SetUnmatchedCallsThreshold sets the number of calls without a matching stub in the window above which an alert is raised.
+// Zero disables the alert. The window defaults to a minute.
+func SetUnmatchedCallsThreshold(threshold int, window time.Duration) {
+	if window <= 0 {
+		window = time.Minute
+	}
+	unmatchedCalls.mutex.Lock()
+	defer unmatchedCalls.mutex.Unlock()
+
+	unmatchedCalls.threshold, unmatchedCalls.window = threshold, window
+}
+
+var unmatchedCalls = &unmatchedCallsRegistry{window: time.Minute, now: stub.Now}
+
+type unmatchedCall struct {
+	time   time.Time
+	method string
+}
+
+type unmatchedCallsRegistry struct {
+	threshold int
+	window    time.Duration
+	// unmatched calls in the window, oldest first
+	calls    []unmatchedCall
+	alerting bool
+	// last requests without a matching stub, oldest first, regardless of the window
+	requests []stub.UnmatchedRequest
+	now      func() time.Time
+	mutex    sync.Mutex
+}
+
+// maxUnmatchedRequests is the number of requests without a matching stub kept for the report. The oldest are dropped.
+const maxUnmatchedRequests = 1000
+
+// GetUnmatchedRequests returns the last requests received without a matching stub, oldest first.
+func GetUnmatchedRequests() []stub.UnmatchedRequest {
+	unmatchedCalls.mutex.Lock()
+	defer unmatchedCalls.mutex.Unlock()
+
+	return append(make([]stub.UnmatchedRequest, 0, len(unmatchedCalls.requests)), unmatchedCalls.requests...)
+}
+
+// ResetUnmatchedRequests forgets the requests without a matching stub received for the methods, or for all the methods
+// when none is provided.
+func ResetUnmatchedRequests(fullMethods ...string) {
+	unmatchedCalls.mutex.Lock()
+	defer unmatchedCalls.mutex.Unlock()
+
+	if len(fullMethods) == 0 {
+		unmatchedCalls.requests = nil
+		return
+	}
+	reset := make(map[string]bool)
+	for _, method := range fullMethods {
+		reset[method] = true
+	}
+	kept := make([]stub.UnmatchedRequest, 0, len(unmatchedCalls.requests))
+	for _, request := range unmatchedCalls.requests {
+		if !reset[request.FullMethod] {
+			kept = append(kept, request)
+		}
+	}
+	unmatchedCalls.requests = kept
+}
+
+func (u *unmatchedCallsRegistry) record(fullMethod string, request string) {
+	unmatchedCallsVar.Add(1)
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if len(u.requests) == maxUnmatchedRequests {
+		u.requests = u.requests[1:]
+	}
+	u.requests = append(u.requests, stub.UnmatchedRequest{FullMethod: fullMethod, Content: stub.JsonString(request), Time: u.now()})
+	if u.threshold <= 0 {
+		return
+	}
+	u.calls = append(u.calls, unmatchedCall{time: u.now(), method: fullMethod})
+	u.update()
+}
+
+func (u *unmatchedCallsRegistry) GetStatus() UnmatchedCallsStatus {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	u.update()
+	return UnmatchedCallsStatus{
+		Alerting:  u.alerting,
+		Count:     len(u.calls),
+		Threshold: u.threshold,
+		Window:    u.window.String(),
+		Methods:   u.methods(),
+	}
+}
+
+// update removes the calls out of the window and logs when the alert is raised or cleared.
+func (u *unmatchedCallsRegistry) update() {
+	start := u.now().Add(-u.window)
+	i := 0
+	for i < len(u.calls) && u.calls[i].time.Before(start) {
+		i++
+	}
+	u.calls = u.calls[i:]
+	alerting := u.threshold > 0 && len(u.calls) > u.threshold
+	switch {
+	case alerting && !u.alerting:
+		unmatchedAlertsVar.Add(1)
+		log.WithFields(log.Fields{"methods": strings.Join(u.methods(), ",")}).
+			Warnf("More than %d calls without a matching stub in the last %s", u.threshold, u.window)
+	case !alerting && u.alerting:
+		log.Infof("Calls without a matching stub back to %d or less in the last %s", u.threshold, u.window)
+	}
+	u.alerting = alerting
+}
+
+func (u *unmatchedCallsRegistry) methods() []string {
+	unique := make(map[string]bool)
+	methods := make([]string, 0)
+	for _, call := range u.calls {
+		if !unique[call.method] {
+			unique[call.method] = true
+			methods = append(methods, call.method)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
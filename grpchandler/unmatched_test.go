@@ -0,0 +1,60 @@
+package grpchandler
+
+import (
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestUnmatchedCallsRegistry_Alert(t *testing.T) {
+	now := time.Now()
+	registry := &unmatchedCallsRegistry{threshold: 2, window: time.Minute, now: func() time.Time { return now }}
+	registry.record("/pkg.Service/Method", "{}")
+	registry.record("/pkg.Service/Other", "{}")
+	assert.False(t, registry.GetStatus().Alerting)
+
+	registry.record("/pkg.Service/Method", "{}")
+	assert.Equal(t, UnmatchedCallsStatus{
+		Alerting:  true,
+		Count:     3,
+		Threshold: 2,
+		Window:    "1m0s",
+		Methods:   []string{"/pkg.Service/Method", "/pkg.Service/Other"},
+	}, registry.GetStatus())
+
+	now = now.Add(time.Minute + time.Second)
+	status := registry.GetStatus()
+	assert.False(t, status.Alerting)
+	assert.Equal(t, 0, status.Count)
+	assert.Empty(t, status.Methods)
+}
+
+func TestUnmatchedCallsRegistry_Disabled(t *testing.T) {
+	registry := &unmatchedCallsRegistry{window: time.Minute, now: time.Now}
+	for i := 0; i < 10; i++ {
+		registry.record("/pkg.Service/Method", "{}")
+	}
+	status := registry.GetStatus()
+	assert.False(t, status.Alerting)
+	assert.Equal(t, 0, status.Count)
+}
+
+func TestUnmatchedRequests(t *testing.T) {
+	defer func(registry *unmatchedCallsRegistry) { unmatchedCalls = registry }(unmatchedCalls)
+	now := time.Now()
+	unmatchedCalls = &unmatchedCallsRegistry{window: time.Minute, now: func() time.Time { return now }}
+	for i := 0; i < maxUnmatchedRequests; i++ {
+		unmatchedCalls.record("/pkg.Service/Method", `{"id":1}`)
+	}
+	unmatchedCalls.record("/pkg.Service/Other", `{"id":2}`)
+
+	requests := GetUnmatchedRequests()
+	assert.Equal(t, maxUnmatchedRequests, len(requests))
+	assert.Equal(t, stub.UnmatchedRequest{FullMethod: "/pkg.Service/Other", Content: `{"id":2}`, Time: now}, requests[len(requests)-1])
+
+	ResetUnmatchedRequests("/pkg.Service/Method")
+	assert.Equal(t, 1, len(GetUnmatchedRequests()))
+	ResetUnmatchedRequests()
+	assert.Empty(t, GetUnmatchedRequests())
+}
@@ -0,0 +1,106 @@
+package grpchandler
+
+import (
+	"errors"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UpstreamHealth is the health of a server requests are forwarded to, based on the results of the forwarded calls.
+type UpstreamHealth struct {
+	Address     string    `json:"address"`
+	Healthy     bool      `json:"healthy"`
+	Successes   uint64    `json:"successes"`
+	Failures    uint64    `json:"failures"`
+	LastError   string    `json:"lastError"`
+	LastChecked time.Time `json:"lastChecked"`
+}
+
+type UpstreamsHealth interface {
+	GetAll() []UpstreamHealth
+}
+
+// GetUpstreamsHealth returns the health of all the servers requests were forwarded to.
+func GetUpstreamsHealth() UpstreamsHealth {
+	return upstreams
+}
+
+var upstreams = &upstreamsRegistry{
+	health:     make(map[string]*UpstreamHealth, 0),
+	roundRobin: make(map[string]int, 0),
+}
+
+type upstreamsRegistry struct {
+	health map[string]*UpstreamHealth
+	// Next position to start from for each list of addresses using round robin
+	roundRobin map[string]int
+	mutex      sync.Mutex
+}
+
+func (u *upstreamsRegistry) GetAll() []UpstreamHealth {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	all := make([]UpstreamHealth, 0)
+	for _, health := range u.health {
+		all = append(all, *health)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Address < all[j].Address
+	})
+	return all
+}
+
+// record updates the health of the server based on the error returned by the forwarded call.
+// Only the failures of the server, see isUpstreamFailure, mark it as unhealthy since any other result means the server
+// answered the call.
+func (u *upstreamsRegistry) record(address string, err error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	health, ok := u.health[address]
+	if !ok {
+		health = &UpstreamHealth{Address: address}
+		u.health[address] = health
+	}
+	health.LastChecked = time.Now()
+	if isUpstreamFailure(err) {
+		health.Healthy = false
+		health.Failures++
+		health.LastError = err.Error()
+		return
+	}
+	health.Healthy = true
+	health.Successes++
+}
+
+// isUpstreamFailure tells whether the server couldn't be reached, because it is unavailable or the connection to it
+// couldn't be created, in which case the next server is tried.
+func isUpstreamFailure(err error) bool {
+	var connErr *connectionError
+	return status.Code(err) == codes.Unavailable || errors.As(err, &connErr)
+}
+
+// targets returns the addresses in the order they must be tried.
+// Failover always starts from the first address. Round robin starts from the next address on each call.
+func (u *upstreamsRegistry) targets(forward *stub.StubForward) []string {
+	addresses := forward.Addresses()
+	if forward.Balancing != "roundrobin" || len(addresses) < 2 {
+		return addresses
+	}
+
+	u.mutex.Lock()
+	key := strings.Join(addresses, ",")
+	start := u.roundRobin[key] % len(addresses)
+	u.roundRobin[key] = start + 1
+	u.mutex.Unlock()
+
+	ordered := make([]string, 0, len(addresses))
+	ordered = append(ordered, addresses[start:]...)
+	return append(ordered, addresses[:start]...)
+}
@@ -0,0 +1,52 @@
+package grpchandler
+
+import (
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestUpstreamsRegistry_Targets_Failover(t *testing.T) {
+	registry := &upstreamsRegistry{health: make(map[string]*UpstreamHealth), roundRobin: make(map[string]int)}
+	forward := &stub.StubForward{ServerAddress: "a:1", ServerAddresses: []string{"b:1", "c:1"}}
+
+	assert.Equal(t, []string{"a:1", "b:1", "c:1"}, registry.targets(forward))
+	assert.Equal(t, []string{"a:1", "b:1", "c:1"}, registry.targets(forward))
+}
+
+func TestUpstreamsRegistry_Targets_RoundRobin(t *testing.T) {
+	registry := &upstreamsRegistry{health: make(map[string]*UpstreamHealth), roundRobin: make(map[string]int)}
+	forward := &stub.StubForward{ServerAddresses: []string{"a:1", "b:1", "c:1"}, Balancing: "roundrobin"}
+
+	assert.Equal(t, []string{"a:1", "b:1", "c:1"}, registry.targets(forward))
+	assert.Equal(t, []string{"b:1", "c:1", "a:1"}, registry.targets(forward))
+	assert.Equal(t, []string{"c:1", "a:1", "b:1"}, registry.targets(forward))
+	assert.Equal(t, []string{"a:1", "b:1", "c:1"}, registry.targets(forward))
+}
+
+func TestUpstreamsRegistry_Record(t *testing.T) {
+	registry := &upstreamsRegistry{health: make(map[string]*UpstreamHealth), roundRobin: make(map[string]int)}
+
+	registry.record("b:1", status.Error(codes.Unavailable, "connection refused"))
+	registry.record("a:1", nil)
+	registry.record("a:1", status.Error(codes.NotFound, "not found"))
+	registry.record("b:1", fmt.Errorf("unknown"))
+	registry.record("c:1", &connectionError{address: "c:1"})
+
+	all := registry.GetAll()
+	assert.Equal(t, 3, len(all))
+	assert.Equal(t, "a:1", all[0].Address)
+	assert.True(t, all[0].Healthy)
+	assert.Equal(t, uint64(2), all[0].Successes)
+	assert.Equal(t, "b:1", all[1].Address)
+	assert.True(t, all[1].Healthy)
+	assert.Equal(t, uint64(1), all[1].Failures)
+	assert.Equal(t, "rpc error: code = Unavailable desc = connection refused", all[1].LastError)
+	assert.Equal(t, "c:1", all[2].Address)
+	assert.False(t, all[2].Healthy)
+	assert.Equal(t, uint64(1), all[2].Failures)
+	assert.Equal(t, "rpc error: code = Internal desc = Failed to create connection to c:1", all[2].LastError)
+}
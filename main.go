@@ -1,10 +1,18 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"flag"
 	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/options"
+	"google.golang.org/genproto/googleapis/api/annotations"
 	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -17,17 +25,40 @@ const (
 	grpcHandlerPackage = protogen.GoImportPath("github.com/carvalhorr/protoc-gen-mock/grpchandler")
 	stubPackage        = protogen.GoImportPath("github.com/carvalhorr/protoc-gen-mock/stub")
 	remotePackage      = protogen.GoImportPath("github.com/carvalhorr/protoc-gen-mock/remote")
+	bootstrapPackage   = protogen.GoImportPath("github.com/carvalhorr/protoc-gen-mock/bootstrap")
+	mocktestPackage    = protogen.GoImportPath("github.com/carvalhorr/protoc-gen-mock/mocktest")
+	stubgenPackage     = protogen.GoImportPath("github.com/carvalhorr/protoc-gen-mock/stubgen")
+	testingPackage     = protogen.GoImportPath("testing")
 	codesPackage       = protogen.GoImportPath("google.golang.org/grpc/codes")
 	statusPackage      = protogen.GoImportPath("google.golang.org/grpc/status")
 	deprecationComment = "// Deprecated: Do not use."
 )
 
+// version of the plugin written in the generated files. Set when building a release with -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
 func main() {
+	if len(os.Args) == 2 && os.Args[1] == "--version" {
+		fmt.Fprintf(os.Stdout, "protoc-gen-mock %s\n", version)
+		os.Exit(0)
+	}
 	var (
 		flags flag.FlagSet
 		//plugins      = flags.String("plugins", "", "list of plugins to enable (supported values: grpc)")
-		importPrefix = flags.String("import_prefix", "", "prefix to prepend to import paths")
+		importPrefix      = flags.String("import_prefix", "", "prefix to prepend to import paths")
+		stubBuilders      = flags.Bool("stub_builders", false, "generate a package with typed stub builders for each service")
+		testServers       = flags.Bool("test_servers", false, "generate a file with functions starting the mock services in the test process")
+		exampleStubs      = flags.Bool("example_stubs", false, "generate a JSON file with an example stub for each method")
+		stubSchema        = flags.Bool("stub_schema", false, "generate a JSON Schema of the stubs for each service")
+		generateMain      = flags.Bool("generate_main", false, "generate cmd/main.go starting a mock server with all the services")
+		stubgen           = flags.Bool("stubgen", false, "generate cmd/stubgen/main.go with a command writing stubs of the methods")
+		tsClient          = flags.Bool("ts_client", false, "generate mock-admin-client.ts with a TypeScript client of the REST API typed with the methods")
+		report            = flags.Bool("report", false, "generate mock_report.json listing the methods that are not fully supported with the reasons")
+		failOnUnsupported = flags.Bool("fail_on_unsupported", false, "fail the generation when a method that is not excluded is not fully supported")
+		filter            = generationFilter{services: namesFlag{}, excludedMethods: namesFlag{}}
 	)
+	flags.Var(filter.services, "services", "comma separated full names of the services to generate the mocks for, e.g. pkg.Foo,pkg.Bar (default all)")
+	flags.Var(filter.excludedMethods, "exclude_methods", "comma separated full names of the methods that are not mocked, e.g. pkg.Foo.Bar")
 	importRewriteFunc := func(importPath protogen.GoImportPath) protogen.GoImportPath {
 		switch importPath {
 		case "context", "fmt", "math":
@@ -39,47 +70,205 @@ func main() {
 		return importPath
 	}
 	protogen.Options{
-		ParamFunc:         flags.Set,
+		ParamFunc:         listParamFunc(&flags, "services", "exclude_methods"),
 		ImportRewriteFunc: importRewriteFunc,
 	}.Run(func(gen *protogen.Plugin) error {
+		// the descriptors are only read through protoreflect, which resolves the presence of the fields from the syntax or the features
+		gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL | pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS)
+		gen.SupportedEditionsMinimum = descriptorpb.Edition_EDITION_PROTO2
+		gen.SupportedEditionsMaximum = descriptorpb.Edition_EDITION_2023
+		if err := filter.validate(gen.Files); err != nil {
+			return err
+		}
+		mockFiles := make([]*protogen.File, 0)
 		for _, f := range gen.Files {
-			GenerateFile(gen, f)
+			if GenerateFile(gen, f, filter) != nil {
+				mockFiles = append(mockFiles, f)
+			}
+			if *stubBuilders {
+				GenerateStubBuilderFiles(gen, f, filter)
+			}
+			if *testServers {
+				GenerateTestServerFile(gen, f, filter)
+			}
+			if *exampleStubs {
+				if err := GenerateExampleStubFiles(gen, f, filter); err != nil {
+					return err
+				}
+			}
+			if *stubSchema {
+				if err := GenerateStubSchemaFiles(gen, f, filter); err != nil {
+					return err
+				}
+			}
+		}
+		if *generateMain {
+			GenerateMainFile(gen, mockFiles, filter)
+		}
+		if *stubgen {
+			GenerateStubgenFile(gen, mockFiles, filter)
+		}
+		if *tsClient {
+			GenerateTSClientFile(gen, mockFiles, filter)
+		}
+		if *report || *failOnUnsupported {
+			generationReport := createGenerationReport(gen.Files, filter)
+			if *failOnUnsupported {
+				if err := generationReport.unsupportedError(); err != nil {
+					return err
+				}
+			}
+			if *report {
+				return GenerateReportFile(gen, generationReport)
+			}
 		}
 		return nil
 	})
 }
 
 // GenerateFile generates a _grpc.pb.go file containing gRPC service definitions.
-func GenerateFile(gen *protogen.Plugin, file *protogen.File) *protogen.GeneratedFile {
-	if len(file.Services) == 0 {
+func GenerateFile(gen *protogen.Plugin, file *protogen.File, filter generationFilter) *protogen.GeneratedFile {
+	mockGenerator := mockServicesGenerator{
+		gen:    gen,
+		file:   file,
+		filter: filter,
+	}
+	if len(mockGenerator.services()) == 0 {
 		return nil
 	}
 	filename := file.GeneratedFilenamePrefix + ".mock.pb.go"
-	g := gen.NewGeneratedFile(filename, file.GoImportPath)
-	mockGenerator := mockServicesGenerator{
-		gen:  gen,
-		file: file,
-		g:    g,
-	}
+	mockGenerator.g = gen.NewGeneratedFile(filename, file.GoImportPath)
 	mockGenerator.genHeader(string(file.GoPackageName))
 	mockGenerator.GenerateFileContent()
-	return g
+	return mockGenerator.g
 }
 
 type mockServicesGenerator struct {
-	gen  *protogen.Plugin
-	file *protogen.File
-	g    *protogen.GeneratedFile
+	gen    *protogen.Plugin
+	file   *protogen.File
+	g      *protogen.GeneratedFile
+	filter generationFilter
+}
+
+// generationFilter selects the services and methods the mocks are generated for.
+type generationFilter struct {
+	services        namesFlag // full names of the services. All the services are generated when empty.
+	excludedMethods namesFlag // full names of the methods, e.g. pkg.Service.Method
+}
+
+// namesFlag is a flag holding comma separated full names. Method names in the gRPC format (/pkg.Service/Method) are also accepted.
+type namesFlag map[string]bool
+
+func (n namesFlag) String() string {
+	return strings.Join(n.sorted(), ",")
+}
+
+// sorted returns the names in a stable order.
+func (n namesFlag) sorted() []string {
+	names := make([]string, 0, len(n))
+	for name := range n {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (n namesFlag) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		n[strings.ReplaceAll(strings.TrimPrefix(name, "/"), "/", ".")] = true
+	}
+	return nil
+}
+
+// listParamFunc sets the plugin parameters as flags. protoc splits the parameters on commas, so the names of a list
+// (services=pkg.Foo,pkg.Bar) after the first one arrive as parameters without a value and are added to the list parameter before them.
+func listParamFunc(flags *flag.FlagSet, listNames ...string) func(name, value string) error {
+	lastList := ""
+	return func(name, value string) error {
+		if value == "" && lastList != "" && flags.Lookup(name) == nil {
+			return flags.Set(lastList, name)
+		}
+		lastList = ""
+		for _, listName := range listNames {
+			if name == listName {
+				lastList = name
+			}
+		}
+		return flags.Set(name, value)
+	}
+}
+
+// validate fails when a service or method in the parameters is not found, so that typos don't silently skip the mocks.
+func (f generationFilter) validate(files []*protogen.File) error {
+	found := make(map[string]bool)
+	for _, file := range files {
+		for _, service := range file.Services {
+			found[string(service.Desc.FullName())] = true
+			for _, method := range service.Methods {
+				found[string(method.Desc.FullName())] = true
+			}
+		}
+	}
+	for _, name := range f.services.sorted() {
+		if !found[name] {
+			return fmt.Errorf("service '%s' in the services parameter not found", name)
+		}
+	}
+	for _, name := range f.excludedMethods.sorted() {
+		if !found[name] {
+			return fmt.Errorf("method '%s' in the exclude_methods parameter not found", name)
+		}
+	}
+	return nil
+}
+
+// includesService returns false for the services not in the services parameter or marked with option (mock.skip_service) = true.
+func (f generationFilter) includesService(service *protogen.Service) bool {
+	if isSkipped(service.Desc.Options(), options.E_SkipService) {
+		return false
+	}
+	return len(f.services) == 0 || f.services[string(service.Desc.FullName())]
+}
+
+// includesMethod returns false for the methods in the exclude_methods parameter or marked with option (mock.skip) = true.
+func (f generationFilter) includesMethod(method *protogen.Method) bool {
+	if isSkipped(method.Desc.Options(), options.E_Skip) {
+		return false
+	}
+	return !f.excludedMethods[string(method.Desc.FullName())]
 }
 
 // GenerateFileContent generates the gRPC service definitions, excluding the package statement.
 func (m mockServicesGenerator) GenerateFileContent() {
-	if len(m.file.Services) == 0 {
-		return
+	for _, service := range m.services() {
+		m.genService(service)
 	}
+}
+
+// services returns the services of the file the mocks are generated for.
+func (m mockServicesGenerator) services() []*protogen.Service {
+	services := make([]*protogen.Service, 0, len(m.file.Services))
 	for _, service := range m.file.Services {
-		m.genService(service)
+		if m.filter.includesService(service) {
+			services = append(services, service)
+		}
+	}
+	return services
+}
+
+// methods returns the methods of the service that are mocked. The excluded methods are not registered and return UNIMPLEMENTED.
+func (m mockServicesGenerator) methods(service *protogen.Service) []*protogen.Method {
+	methods := make([]*protogen.Method, 0, len(service.Methods))
+	for _, method := range service.Methods {
+		if m.filter.includesMethod(method) {
+			methods = append(methods, method)
+		}
 	}
+	return methods
 }
 
 func (m mockServicesGenerator) genService(service *protogen.Service) {
@@ -93,21 +282,32 @@ func (m mockServicesGenerator) genService(service *protogen.Service) {
 	m.genGetStubsValidator(service)
 	m.genIsValid(service)
 	m.genForwardRequest(service)
+	m.genGetHTTPRules(service)
+	m.genGetGenerationInfo(service)
 	m.genRemoteClient(service)
 	m.genMockServiceDescriptor(service)
-	for _, method := range service.Methods {
+	for _, method := range m.methods(service) {
 		methodHandlerName := m.getMethodHandlerName(service, method)
 		m.genMockMethodHandler(service, method, methodHandlerName)
 	}
 }
 
 func (m mockServicesGenerator) genHeader(packageName string) {
-	m.g.P("// Code generated by protoc-gen-mock. DO NOT EDIT.")
+	m.genGeneratedComment()
 	m.g.P()
 	m.g.P("package ", packageName)
 	m.g.P()
 }
 
+// genGeneratedComment generates the comment marking the file as generated. Only the plugin version is included so that
+// the output is the same wherever it is generated.
+func (m mockServicesGenerator) genGeneratedComment() {
+	m.g.P("// Code generated by protoc-gen-mock. DO NOT EDIT.")
+	m.g.P("// versions:")
+	m.g.P("// \tprotoc-gen-mock ", version)
+	m.g.P("// source: ", m.file.Desc.Path())
+}
+
 func (m mockServicesGenerator) genMockServiceConstructor(service *protogen.Service) {
 	serviceName := m.getMockServiceName(service)
 	m.g.P("func New", serviceName, "(stubsMatcher ", stubPackage.Ident("StubsMatcher"), ") ", grpcHandlerPackage.Ident("MockService"), "{")
@@ -147,7 +347,7 @@ func (m mockServicesGenerator) genMockServiceRegistrationFunction(service *proto
 func (m mockServicesGenerator) genGetSupportedMethodsFunction(service *protogen.Service) {
 	m.g.P("func (mock *", unexport(m.getMockServiceName(service)), ") GetSupportedMethods() []string {")
 	m.g.P("return []string{")
-	for _, method := range service.Methods {
+	for _, method := range m.methods(service) {
 		m.g.P(m.getFullMethodName(service, method), ",")
 	}
 	m.g.P("}")
@@ -158,7 +358,7 @@ func (m mockServicesGenerator) genGetSupportedMethodsFunction(service *protogen.
 func (m mockServicesGenerator) genGetPayloadExamplesFunction(service *protogen.Service) {
 	m.g.P("func (mock *", unexport(m.getMockServiceName(service)), ") GetPayloadExamples() []", stubPackage.Ident("Stub"), "{")
 	m.g.P("return []", stubPackage.Ident("Stub"), "{")
-	for _, method := range service.Methods {
+	for _, method := range m.methods(service) {
 		m.g.P("{")
 		m.g.P("FullMethod: ", m.getFullMethodName(service, method), ",")
 		m.g.P("Type: ", strconv.Quote("mock | forward"), ",")
@@ -185,7 +385,7 @@ func (m mockServicesGenerator) genGetPayloadExamplesFunction(service *protogen.S
 func (m mockServicesGenerator) genGetRequestInstance(service *protogen.Service) {
 	m.g.P("func (mock *", unexport(m.getMockServiceName(service)), ") GetRequestInstance(methodName string) ", protoPackage.Ident("Message"), " {")
 	m.g.P("switch methodName {")
-	for _, method := range service.Methods {
+	for _, method := range m.methods(service) {
 		m.g.P("case ", m.getFullMethodName(service, method), ":")
 		m.g.P("return new(", method.Input.GoIdent, ")")
 	}
@@ -198,7 +398,7 @@ func (m mockServicesGenerator) genGetRequestInstance(service *protogen.Service)
 func (m mockServicesGenerator) genGetResponseInstance(service *protogen.Service) {
 	m.g.P("func (mock *", unexport(m.getMockServiceName(service)), ") GetResponseInstance(methodName string) ", protoPackage.Ident("Message"), "{")
 	m.g.P("switch methodName {")
-	for _, method := range service.Methods {
+	for _, method := range m.methods(service) {
 		m.g.P("case ", m.getFullMethodName(service, method), ":")
 		m.g.P("return new(", method.Output.GoIdent, ")")
 	}
@@ -213,7 +413,7 @@ func (m mockServicesGenerator) genMockServiceDescriptor(service *protogen.Servic
 	m.g.P("ServiceName: ", strconv.Quote(string(service.Desc.FullName())), ",")
 	m.g.P("HandlerType: (*", m.getMockServerBaseInterfaceName(service), ")(nil),")
 	m.g.P("Methods: []", grpcPackage.Ident("MethodDesc"), "{")
-	for _, method := range service.Methods {
+	for _, method := range m.methods(service) {
 		methodHandlerName := m.getMethodHandlerName(service, method)
 		if method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer() {
 			continue // skip if it is streaming
@@ -225,7 +425,7 @@ func (m mockServicesGenerator) genMockServiceDescriptor(service *protogen.Servic
 	}
 	m.g.P("},")
 	m.g.P("Streams: []", grpcPackage.Ident("StreamDesc"), "{")
-	for _, method := range service.Methods {
+	for _, method := range m.methods(service) {
 		methodHandlerName := m.getMethodHandlerName(service, method)
 		if !method.Desc.IsStreamingClient() && !method.Desc.IsStreamingServer() {
 			continue
@@ -255,14 +455,30 @@ func (m mockServicesGenerator) genMockMethodHandler(service *protogen.Service, m
 		m.g.P("out := new(", method.Output.GoIdent, ")")
 		m.g.P("fullMethod := ", m.getFullMethodName(service, method))
 		m.g.P("stubsMatcher := (srv).(*", unexport(m.getMockServiceName(service)), ").StubsMatcher")
+		m.g.P("if interceptor == nil {")
 		m.g.P("return ", grpcHandlerPackage.Ident("MockHandler"), "(ctx, stubsMatcher, fullMethod, in, out)")
 		m.g.P("}")
+		m.g.P("info := &", grpcPackage.Ident("UnaryServerInfo"), "{")
+		m.g.P("Server: srv,")
+		m.g.P("FullMethod: fullMethod,")
+		m.g.P("}")
+		m.g.P("handler := func(ctx ", contextPackage.Ident("Context"), ", req interface{}) (interface{}, error) {")
+		m.g.P("return ", grpcHandlerPackage.Ident("MockHandler"), "(ctx, stubsMatcher, fullMethod, req, out)")
+		m.g.P("}")
+		m.g.P("return interceptor(ctx, in, info, handler)")
+		m.g.P("}")
 		m.g.P()
 		return
 	}
 	m.g.P("func ", hname, "(srv interface{}, stream ", grpcPackage.Ident("ServerStream"), ") error {")
-	m.g.P("// Mock not implemented for streaming")
-	m.g.P("return ", fmtPackage.Ident("Errorf"), "(\"mock not implemented for streaming\")")
+	m.g.P("fullMethod := ", m.getFullMethodName(service, method))
+	m.g.P("stubsMatcher := (srv).(*", unexport(m.getMockServiceName(service)), ").StubsMatcher")
+	m.g.P("desc := &", grpcPackage.Ident("StreamDesc"), "{")
+	m.g.P("StreamName: ", strconv.Quote(string(method.Desc.Name())), ",")
+	m.g.P("ServerStreams: ", method.Desc.IsStreamingServer(), ",")
+	m.g.P("ClientStreams: ", method.Desc.IsStreamingClient(), ",")
+	m.g.P("}")
+	m.g.P("return ", grpcHandlerPackage.Ident("MockStreamHandler"), "(stream, stubsMatcher, fullMethod, desc)")
 	m.g.P("}")
 	m.g.P()
 }
@@ -278,7 +494,7 @@ func (m mockServicesGenerator) genIsValid(service *protogen.Service) {
 
 	m.g.P("func(mock *", unexport(m.getMockServiceName(service)), ") IsValid(s *", stubPackage.Ident("Stub"), ") (isValid bool, errorMessages []string) {")
 	m.g.P("switch s.FullMethod {")
-	for _, method := range service.Methods {
+	for _, method := range m.methods(service) {
 		m.g.P("case ", m.getFullMethodName(service, method), ":")
 		m.g.P("req := new(", method.Input.GoIdent, ")")
 		m.g.P("resp := new(", method.Output.GoIdent, ")")
@@ -295,9 +511,22 @@ func (m mockServicesGenerator) genIsValid(service *protogen.Service) {
 func (m mockServicesGenerator) genForwardRequest(service *protogen.Service) {
 
 	m.g.P("func(mock *", unexport(m.getMockServiceName(service)), ") ForwardRequest(conn grpc.ClientConnInterface, ctx context.Context, methodName string, req interface{}) (interface{}, error) {")
+	unaryMethods := make([]*protogen.Method, 0)
+	for _, method := range m.methods(service) {
+		if method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer() {
+			continue // streaming calls are forwarded by the stream handler
+		}
+		unaryMethods = append(unaryMethods, method)
+	}
+	if len(unaryMethods) == 0 {
+		m.g.P("return nil, nil")
+		m.g.P("}")
+		m.g.P("")
+		return
+	}
 	m.g.P("client := New", service.Desc.Name(), "Client(conn)")
 	m.g.P("switch methodName {")
-	for _, method := range service.Methods {
+	for _, method := range unaryMethods {
 		m.g.P("case ", m.getFullMethodName(service, method), ":")
 		m.g.P("return client.", method.GoName, "(ctx, req.(*", method.Input.GoIdent, "))")
 	}
@@ -308,9 +537,105 @@ func (m mockServicesGenerator) genForwardRequest(service *protogen.Service) {
 
 }
 
+// genGetHTTPRules generates the HTTP rules of the methods with google.api.http annotations so that the requests
+// can be transcoded by the mock server. Nothing is generated when there are no annotations.
+func (m mockServicesGenerator) genGetHTTPRules(service *protogen.Service) {
+	type httpRule struct {
+		method *protogen.Method
+		rule   *annotations.HttpRule
+	}
+	rules := make([]httpRule, 0)
+	for _, method := range m.methods(service) {
+		if method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer() {
+			continue
+		}
+		rule, ok := proto.GetExtension(method.Desc.Options(), annotations.E_Http).(*annotations.HttpRule)
+		if !ok || rule == nil {
+			continue
+		}
+		rules = append(rules, httpRule{method: method, rule: rule})
+		for _, binding := range rule.GetAdditionalBindings() {
+			rules = append(rules, httpRule{method: method, rule: binding})
+		}
+	}
+	if len(rules) == 0 {
+		return
+	}
+	m.g.P("func(mock *", unexport(m.getMockServiceName(service)), ") GetHTTPRules() []", grpcHandlerPackage.Ident("HTTPRule"), " {")
+	m.g.P("return []", grpcHandlerPackage.Ident("HTTPRule"), "{")
+	for _, r := range rules {
+		httpMethod, path := getHTTPRulePattern(r.rule)
+		if path == "" {
+			continue
+		}
+		m.g.P("{")
+		m.g.P("FullMethod: ", m.getFullMethodName(service, r.method), ",")
+		m.g.P("Method: ", strconv.Quote(httpMethod), ",")
+		m.g.P("Path: ", strconv.Quote(path), ",")
+		m.g.P("Body: ", strconv.Quote(r.rule.GetBody()), ",")
+		m.g.P("ResponseBody: ", strconv.Quote(r.rule.GetResponseBody()), ",")
+		m.g.P("},")
+	}
+	m.g.P("}")
+	m.g.P("}")
+	m.g.P("")
+}
+
+// genGetGenerationInfo generates the versions the mock service was generated with and the hash of the descriptor of the
+// proto file, returned by the /admin/info endpoint of the mock server.
+func (m mockServicesGenerator) genGetGenerationInfo(service *protogen.Service) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(m.file.Proto)
+	if err != nil {
+		m.gen.Error(err)
+		return
+	}
+	hash := sha256.Sum256(data)
+	m.g.P("func(mock *", unexport(m.getMockServiceName(service)), ") GetGenerationInfo() []", grpcHandlerPackage.Ident("GenerationInfo"), " {")
+	m.g.P("return []", grpcHandlerPackage.Ident("GenerationInfo"), "{{")
+	m.g.P("Service: ", strconv.Quote(string(service.Desc.FullName())), ",")
+	m.g.P("PluginVersion: ", strconv.Quote(version), ",")
+	m.g.P("ProtocVersion: ", strconv.Quote(compilerVersion(m.gen)), ",")
+	m.g.P("ProtoFile: ", strconv.Quote(m.file.Desc.Path()), ",")
+	m.g.P("ProtoFileHash: ", strconv.Quote(hex.EncodeToString(hash[:])), ",")
+	m.g.P("}}")
+	m.g.P("}")
+	m.g.P("")
+}
+
+// compilerVersion returns the version of protoc, or an empty string when the compiler didn't send it.
+func compilerVersion(gen *protogen.Plugin) string {
+	v := gen.Request.GetCompilerVersion()
+	if v == nil {
+		return ""
+	}
+	version := fmt.Sprintf("%d.%d.%d", v.GetMajor(), v.GetMinor(), v.GetPatch())
+	if v.GetSuffix() != "" {
+		version += "-" + v.GetSuffix()
+	}
+	return version
+}
+
+func getHTTPRulePattern(rule *annotations.HttpRule) (method, path string) {
+	switch pattern := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		return "GET", pattern.Get
+	case *annotations.HttpRule_Put:
+		return "PUT", pattern.Put
+	case *annotations.HttpRule_Post:
+		return "POST", pattern.Post
+	case *annotations.HttpRule_Delete:
+		return "DELETE", pattern.Delete
+	case *annotations.HttpRule_Patch:
+		return "PATCH", pattern.Patch
+	case *annotations.HttpRule_Custom:
+		return pattern.Custom.GetKind(), pattern.Custom.GetPath()
+	}
+	return "", ""
+}
+
 func (m mockServicesGenerator) genRemoteClient(service *protogen.Service) {
 	m.genRemoteMockClient(service)
-	for _, method := range service.Methods {
+	for _, method := range m.methods(service) {
 		m.genRemoteCalls(service, method)
 	}
 }
@@ -340,6 +665,18 @@ func (m mockServicesGenerator) genRemoteCalls(service *protogen.Service, method
 	m.g.P("func (c ", callName, ") Error(code ", codesPackage.Ident("Code"), ", message string) error {")
 	m.g.P("return c.client.remoteMockClient.AddStub(", methodFullName, ", c.ctx, c.req, nil, ", statusPackage.Ident("New"), "(code, message))")
 	m.g.P("}")
+	m.g.P("")
+	m.g.P("func (c ", callName, ") Verify(times int) error {")
+	m.g.P("return c.client.remoteMockClient.VerifyStub(", methodFullName, ", c.ctx, c.req, times, false)")
+	m.g.P("}")
+	m.g.P("")
+	m.g.P("func (c ", callName, ") VerifyAtLeast(times int) error {")
+	m.g.P("return c.client.remoteMockClient.VerifyStub(", methodFullName, ", c.ctx, c.req, times, true)")
+	m.g.P("}")
+	m.g.P("")
+	m.g.P("func (c ", callName, ") MatchCount() (int, error) {")
+	m.g.P("return c.client.remoteMockClient.GetMatchCount(", methodFullName, ", c.ctx, c.req)")
+	m.g.P("}")
 
 	m.g.P("")
 }
@@ -352,6 +689,14 @@ func (m mockServicesGenerator) genRemoteMockClientClear(service *protogen.Servic
 	m.g.P("")
 }
 
+func (m mockServicesGenerator) genRemoteMockClientGetUnmatchedStubs(service *protogen.Service) {
+	remoteMockClientName := m.getRemoteMockClientName(service)
+	m.g.P("func (c ", remoteMockClientName, ") GetUnmatchedStubs() ([]*", stubPackage.Ident("Stub"), ", error) {")
+	m.g.P("return c.remoteMockClient.GetUnmatchedStubs()")
+	m.g.P("}")
+	m.g.P("")
+}
+
 func (m mockServicesGenerator) genRemoteMockClient(service *protogen.Service) {
 	remoteMockClientName := m.getRemoteMockClientName(service)
 	m.g.P("func New" + remoteMockClientName + "(")
@@ -373,6 +718,7 @@ func (m mockServicesGenerator) genRemoteMockClient(service *protogen.Service) {
 	m.g.P("}")
 	m.g.P("")
 	m.genRemoteMockClientClear(service)
+	m.genRemoteMockClientGetUnmatchedStubs(service)
 }
 
 func (m mockServicesGenerator) getFullMethodName(service *protogen.Service, method *protogen.Method) string {
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+	"path"
+	"strings"
+)
+
+// GenerateMainFile generates cmd/main.go with a mock server for all the services the mocks were generated for. With the
+// module parameter the file is generated in the cmd directory of the module, next to the other generated files. The ports
+// and the rest of the configuration can be changed with the flags and environment variables of bootstrap.BootstrapServers.
+func GenerateMainFile(gen *protogen.Plugin, files []*protogen.File, filter generationFilter) {
+	if len(files) == 0 {
+		return
+	}
+	g := gen.NewGeneratedFile(path.Join(moduleParam(gen), "cmd", "main.go"), protogen.GoImportPath("main"))
+	genCommandHeader(g, "main starts the mock server of the generated services.")
+	g.P("func main() {")
+	g.P(bootstrapPackage.Ident("BootstrapServers"), "(\"./tmp/\", 1068, 10010, registerMockServices)")
+	g.P("}")
+	g.P()
+	g.P("func registerMockServices(stubsMatcher ", stubPackage.Ident("StubsMatcher"), ") ", grpcHandlerPackage.Ident("MockService"), " {")
+	genCompositeMockService(gen, g, files, filter, "stubsMatcher")
+	g.P("}")
+}
+
+// GenerateStubgenFile generates cmd/stubgen/main.go with the stubgen command scaffolding the stubs of the methods of all the
+// services the mocks were generated for, e.g. stubgen new my.pkg.OrderService/GetOrder --error NOT_FOUND.
+func GenerateStubgenFile(gen *protogen.Plugin, files []*protogen.File, filter generationFilter) {
+	if len(files) == 0 {
+		return
+	}
+	g := gen.NewGeneratedFile(path.Join(moduleParam(gen), "cmd", "stubgen", "main.go"), protogen.GoImportPath("main"))
+	genCommandHeader(g, "stubgen writes stubs of the generated services prefilled from their descriptors.")
+	g.P("func main() {")
+	g.P(stubgenPackage.Ident("Main"), "(mockServices())")
+	g.P("}")
+	g.P()
+	g.P("func mockServices() ", grpcHandlerPackage.Ident("MockService"), " {")
+	genCompositeMockService(gen, g, files, filter, "nil")
+	g.P("}")
+}
+
+func genCommandHeader(g *protogen.GeneratedFile, doc string) {
+	g.P("// Code generated by protoc-gen-mock. DO NOT EDIT.")
+	g.P("// versions:")
+	g.P("// \tprotoc-gen-mock ", version)
+	g.P()
+	g.P("// Command ", doc)
+	g.P("package main")
+	g.P()
+}
+
+// genCompositeMockService generates the return of a mock service with all the services, created with the stubs matcher provided.
+func genCompositeMockService(gen *protogen.Plugin, g *protogen.GeneratedFile, files []*protogen.File, filter generationFilter, stubsMatcher string) {
+	m := mockServicesGenerator{gen: gen, g: g, filter: filter}
+	g.P("return ", grpcHandlerPackage.Ident("NewCompositeMockService"), "([]", grpcHandlerPackage.Ident("MockService"), "{")
+	for _, file := range files {
+		m.file = file
+		for _, service := range m.services() {
+			g.P(file.GoImportPath.Ident("New"+m.getMockServiceName(service)), "(", stubsMatcher, "),")
+		}
+	}
+	g.P("})")
+}
+
+// moduleParam returns the value of the module parameter. It is handled by protogen, which strips the module from the names of
+// the generated files and fails for the files outside of it, so it is not passed to the ParamFunc.
+func moduleParam(gen *protogen.Plugin) string {
+	for _, param := range strings.Split(gen.Request.GetParameter(), ",") {
+		if strings.HasPrefix(param, "module=") {
+			return strings.TrimPrefix(param, "module=")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,171 @@
+// Package mockassert checks the calls received by the mock server in tests, with the verification API. When a check
+// fails, the stubs of the method are shown with the differences between their requests and the one expected, so that
+// near misses, e.g. a field with a different value, are easy to spot:
+//
+//	client := stubclient.New("http://localhost:1068")
+//	...
+//	mockassert.Called(t, client, "/greeter.Greeter/Hello", mockassert.Exact(&greeter.Request{Name: "John"}))
+package mockassert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/pmezard/go-difflib/difflib"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"sort"
+	"strings"
+)
+
+// maxNearMisses is the number of stubs shown when a check fails.
+const maxNearMisses = 3
+
+// TestingT is implemented by *testing.T. It is the same interface used by the assertions of testify.
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// Verifier calls the verification API of the mock server. It is implemented by *stubclient.Client.
+type Verifier interface {
+	Verify(ctx context.Context, verification stub.StubVerification) (stub.StubVerificationResult, error)
+	StubsUsage(ctx context.Context, fullMethod string) ([]stub.StubUsage, error)
+}
+
+// Exact matches the stub for the request with exactly the fields of the message.
+func Exact(message proto.Message) *stub.StubRequest {
+	return &stub.StubRequest{Match: "exact", Content: toJSON(message)}
+}
+
+// Partial matches the stub for the request with the fields set in the message.
+func Partial(message proto.Message) *stub.StubRequest {
+	return &stub.StubRequest{Match: "partial", Content: toJSON(message)}
+}
+
+func toJSON(message proto.Message) stub.JsonString {
+	data, err := protojson.Marshal(message)
+	if err != nil {
+		return stub.JsonString(fmt.Sprintf("%q", err.Error()))
+	}
+	// protojson randomly adds spaces to its output, the stubs are compared compacted
+	buffer := new(bytes.Buffer)
+	if err := json.Compact(buffer, data); err != nil {
+		return stub.JsonString(data)
+	}
+	return stub.JsonString(buffer.String())
+}
+
+// Called asserts that the stub for the request was matched at least once.
+func Called(t TestingT, client Verifier, fullMethod string, request *stub.StubRequest) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	return check(t, client, stub.StubVerification{FullMethod: fullMethod, Request: request, Times: 1, AtLeast: true})
+}
+
+// CalledTimes asserts that the stub for the request was matched exactly times.
+func CalledTimes(t TestingT, client Verifier, fullMethod string, request *stub.StubRequest, times int) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	return check(t, client, stub.StubVerification{FullMethod: fullMethod, Request: request, Times: times})
+}
+
+// NotCalled asserts that the stub for the request was never matched.
+func NotCalled(t TestingT, client Verifier, fullMethod string, request *stub.StubRequest) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	return check(t, client, stub.StubVerification{FullMethod: fullMethod, Request: request, Times: 0})
+}
+
+func check(t TestingT, client Verifier, verification stub.StubVerification) bool {
+	ctx := context.Background()
+	result, err := client.Verify(ctx, verification)
+	if err != nil {
+		t.Errorf("could not verify the calls of %s: %s", verification.FullMethod, err)
+		return false
+	}
+	if result.Verified {
+		return true
+	}
+	message := result.Message
+	if verification.Times > 0 || verification.AtLeast {
+		message += "\n" + nearMisses(ctx, client, verification)
+	}
+	t.Errorf("%s", message)
+	return false
+}
+
+type nearMiss struct {
+	usage       stub.StubUsage
+	diff        string
+	differences int
+}
+
+// nearMisses describes the stubs of the method closest to the request of the verification.
+func nearMisses(ctx context.Context, client Verifier, verification stub.StubVerification) string {
+	usage, err := client.StubsUsage(ctx, verification.FullMethod)
+	if err != nil {
+		return fmt.Sprintf("could not get the stubs of %s: %s", verification.FullMethod, err)
+	}
+	if len(usage) == 0 {
+		return fmt.Sprintf("there are no stubs for %s", verification.FullMethod)
+	}
+	if request := verification.Request; request != nil && request.Content != "" {
+		var content interface{}
+		if err := json.Unmarshal([]byte(request.Content), &content); err != nil {
+			return fmt.Sprintf("the request content is not valid JSON and can't match any stub: %s", err)
+		}
+	}
+	expected := indent(verification.Request)
+	misses := make([]nearMiss, 0, len(usage))
+	for _, u := range usage {
+		diff, differences := diffLines(expected, indent(u.Stub.Request))
+		misses = append(misses, nearMiss{usage: u, diff: diff, differences: differences})
+	}
+	sort.SliceStable(misses, func(i, j int) bool {
+		return misses[i].differences < misses[j].differences
+	})
+	if len(misses) > maxNearMisses {
+		misses = misses[:maxNearMisses]
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "closest stubs of %s:", verification.FullMethod)
+	for _, miss := range misses {
+		fmt.Fprintf(&b, "\n\nstub matched %d time(s):\n%s", miss.usage.MatchCount, miss.diff)
+	}
+	return b.String()
+}
+
+func indent(request *stub.StubRequest) string {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err.Error()
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return string(data)
+	}
+	return out.String()
+}
+
+// diffLines returns the unified diff of the requests and the number of lines that differ.
+func diffLines(expected, actual string) (string, int) {
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(expected + "\n"),
+		B:        difflib.SplitLines(actual + "\n"),
+		FromFile: "Expected",
+		ToFile:   "Stub",
+		Context:  2,
+	})
+	differences := 0
+	for _, line := range strings.Split(diff, "\n") {
+		if (strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++")) || (strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "---")) {
+			differences++
+		}
+	}
+	return strings.TrimSuffix(diff, "\n"), differences
+}
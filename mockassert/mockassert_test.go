@@ -0,0 +1,128 @@
+package mockassert
+
+import (
+	"context"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
+	"testing"
+)
+
+const helloMethod = "/greeter.Greeter/Hello"
+
+// fakeVerifier verifies the calls with the match counts of the stubs.
+type fakeVerifier struct {
+	usage []stub.StubUsage
+	err   error
+}
+
+func (f fakeVerifier) Verify(ctx context.Context, verification stub.StubVerification) (stub.StubVerificationResult, error) {
+	if f.err != nil {
+		return stub.StubVerificationResult{}, f.err
+	}
+	count := 0
+	for _, u := range f.usage {
+		if u.Stub.Request.String() == verification.Request.String() {
+			count = u.MatchCount
+		}
+	}
+	return verification.Verify(count), nil
+}
+
+func (f fakeVerifier) StubsUsage(ctx context.Context, fullMethod string) ([]stub.StubUsage, error) {
+	return f.usage, nil
+}
+
+type recordingT struct {
+	failures []string
+}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func request(name string) *stub.StubRequest {
+	message, _ := structpb.NewStruct(map[string]interface{}{"name": name, "language": "en"})
+	return Exact(message)
+}
+
+func usage(name string, matchCount int) stub.StubUsage {
+	return stub.StubUsage{Stub: &stub.Stub{FullMethod: helloMethod, Request: request(name)}, MatchCount: matchCount}
+}
+
+func TestExactAndPartial(t *testing.T) {
+	message, _ := structpb.NewStruct(map[string]interface{}{"name": "John"})
+	assert.Equal(t, &stub.StubRequest{Match: "exact", Content: `{"name":"John"}`}, Exact(message))
+	assert.Equal(t, &stub.StubRequest{Match: "partial", Content: `{"name":"John"}`}, Partial(message))
+}
+
+func TestCalled(t *testing.T) {
+	client := fakeVerifier{usage: []stub.StubUsage{usage("John", 2), usage("Mary", 0)}}
+	r := &recordingT{}
+
+	assert.True(t, Called(r, client, helloMethod, request("John")))
+	assert.True(t, CalledTimes(r, client, helloMethod, request("John"), 2))
+	assert.True(t, NotCalled(r, client, helloMethod, request("Mary")))
+	assert.Empty(t, r.failures)
+
+	assert.False(t, NotCalled(r, client, helloMethod, request("John")))
+	assert.Equal(t, []string{`stub /greeter.Greeter/Hello -> {"match":"exact","content":"{\"language\":\"en\",\"name\":\"John\"}","metadata":null} ` +
+		`expected to be matched exactly 0 time(s) but was matched 2 time(s)`}, r.failures)
+}
+
+func TestCalled_NearMisses(t *testing.T) {
+	client := fakeVerifier{usage: []stub.StubUsage{usage("Mary", 0), usage("Jon", 3)}}
+	client.usage[0].Stub.Request.Match = "partial"
+	r := &recordingT{}
+
+	assert.False(t, Called(r, client, helloMethod, request("John")))
+	assert.Equal(t, []string{`stub /greeter.Greeter/Hello -> {"match":"exact","content":"{\"language\":\"en\",\"name\":\"John\"}","metadata":null} ` +
+		`expected to be matched at least 1 time(s) but was matched 0 time(s)
+closest stubs of /greeter.Greeter/Hello:
+
+stub matched 3 time(s):
+--- Expected
++++ Stub
+@@ -3,5 +3,5 @@
+   "content": {
+     "language": "en",
+-    "name": "John"
++    "name": "Jon"
+   },
+   "metadata": null
+
+stub matched 0 time(s):
+--- Expected
++++ Stub
+@@ -1,7 +1,7 @@
+ {
+-  "match": "exact",
++  "match": "partial",
+   "content": {
+     "language": "en",
+-    "name": "John"
++    "name": "Mary"
+   },
+   "metadata": null`}, r.failures)
+}
+
+func TestCalled_InvalidContent(t *testing.T) {
+	client := fakeVerifier{usage: []stub.StubUsage{usage("John", 0)}}
+	r := &recordingT{}
+
+	assert.False(t, Called(r, client, helloMethod, &stub.StubRequest{Match: "exact", Content: `{"name":"John"`}))
+	assert.Equal(t, 1, len(r.failures))
+	assert.Contains(t, r.failures[0], "\nthe request content is not valid JSON and can't match any stub: unexpected end of JSON input")
+}
+
+func TestCalled_Errors(t *testing.T) {
+	r := &recordingT{}
+	assert.False(t, Called(r, fakeVerifier{}, helloMethod, request("John")))
+	assert.False(t, Called(r, fakeVerifier{err: fmt.Errorf("status 400: Request can't be empty.")}, helloMethod, nil))
+	assert.Equal(t, []string{
+		`stub /greeter.Greeter/Hello -> {"match":"exact","content":"{\"language\":\"en\",\"name\":\"John\"}","metadata":null} ` +
+			"expected to be matched at least 1 time(s) but was matched 0 time(s)\nthere are no stubs for /greeter.Greeter/Hello",
+		"could not verify the calls of /greeter.Greeter/Hello: status 400: Request can't be empty.",
+	}, r.failures)
+}
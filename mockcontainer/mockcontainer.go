@@ -0,0 +1,197 @@
+// Package mockcontainer runs the image of a mock server generated with protoc-gen-mock in tests with testcontainers:
+//
+//	mock, err := mockcontainer.Run(ctx, "registry.example.com/greeter-mock:latest", mockcontainer.WithStubs("testdata/stubs"))
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	t.Cleanup(func() { mock.Terminate(context.Background()) })
+//	conn, err := mock.Dial(ctx)
+//
+// The mock server in the image must read its configuration with bootstrap.BootstrapServers, so that the ports and the
+// stubs are set with the MOCK_* environment variables.
+package mockcontainer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// GRPCPort is the port of the mock services in the container.
+	GRPCPort = nat.Port("10010/tcp")
+	// RESTPort is the port of the REST API in the container.
+	RESTPort = nat.Port("1068/tcp")
+	// stubsDir is where the stubs of WithStubs are copied to in the container.
+	stubsDir = "/stubs"
+	// startupTimeout is how long the mock server has to be ready after the container started.
+	startupTimeout = time.Minute
+)
+
+// Container is a running mock server.
+type Container struct {
+	testcontainers.Container
+}
+
+// Run starts a container of the image with the mock server and waits until both the gRPC server and the REST API are ready.
+// The options customize the container, e.g. testcontainers.WithEnv to configure the mock server with MOCK_* variables.
+func Run(ctx context.Context, image string, opts ...testcontainers.ContainerCustomizer) (*Container, error) {
+	req := testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        image,
+			ExposedPorts: []string{string(GRPCPort), string(RESTPort)},
+			Env: map[string]string{
+				"MOCK_GRPC_PORT": GRPCPort.Port(),
+				"MOCK_REST_PORT": RESTPort.Port(),
+			},
+			WaitingFor: wait.ForAll(
+				wait.ForListeningPort(GRPCPort),
+				wait.ForHTTP("/readyz").WithPort(RESTPort),
+			).WithDeadline(startupTimeout),
+		},
+		Started: true,
+	}
+	for _, opt := range opts {
+		if err := opt.Customize(&req); err != nil {
+			return nil, err
+		}
+	}
+	container, err := testcontainers.GenericContainer(ctx, req)
+	var mock *Container
+	if container != nil {
+		mock = &Container{Container: container}
+	}
+	if err != nil {
+		return mock, fmt.Errorf("could not start the mock server: %w", err)
+	}
+	return mock, nil
+}
+
+// WithStubs loads the stubs of the JSON files, or of the .json files of the directories, when the mock server starts.
+// The mock server fails to start if any of them is not valid.
+func WithStubs(paths ...string) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		for _, p := range paths {
+			files, err := stubFiles(p)
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				req.Files = append(req.Files, testcontainers.ContainerFile{
+					HostFilePath:      file,
+					ContainerFilePath: path.Join(stubsDir, fmt.Sprintf("%03d-%s", len(req.Files), filepath.Base(file))),
+					FileMode:          0644,
+				})
+			}
+		}
+		if req.Env == nil {
+			req.Env = make(map[string]string)
+		}
+		req.Env["MOCK_STUBS"] = stubsDir
+		req.Env["MOCK_STRICT_STARTUP"] = "true"
+		return nil
+	}
+}
+
+// stubFiles returns the file, or the .json files of the directory.
+func stubFiles(p string) ([]string, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the stubs: %w", err)
+	}
+	if !info.IsDir() {
+		return []string{p}, nil
+	}
+	entries, err := ioutil.ReadDir(p)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the stubs: %w", err)
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			files = append(files, filepath.Join(p, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// GRPCAddress returns the host:port the mock services can be called on from the tests.
+func (c *Container) GRPCAddress(ctx context.Context) (string, error) {
+	return c.address(ctx, GRPCPort)
+}
+
+// RESTURL returns the base URL of the REST API, e.g. http://localhost:32768.
+func (c *Container) RESTURL(ctx context.Context) (string, error) {
+	address, err := c.address(ctx, RESTPort)
+	if err != nil {
+		return "", err
+	}
+	return "http://" + address, nil
+}
+
+// Dial connects to the mock services. The connection uses plaintext unless other credentials are in the options.
+func (c *Container) Dial(ctx context.Context, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	address, err := c.GRPCAddress(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return grpc.DialContext(ctx, address, append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)...)
+}
+
+// AddStub adds the stub, in the JSON format of the REST API.
+func (c *Container) AddStub(ctx context.Context, stub []byte) error {
+	return c.call(ctx, http.MethodPost, "/stubs", stub)
+}
+
+// DeleteStubs deletes all the stubs, so that the container can be reused by the next test.
+func (c *Container) DeleteStubs(ctx context.Context) error {
+	return c.call(ctx, http.MethodDelete, "/stubs", nil)
+}
+
+func (c *Container) address(ctx context.Context, port nat.Port) (string, error) {
+	host, err := c.Host(ctx)
+	if err != nil {
+		return "", err
+	}
+	mapped, err := c.MappedPort(ctx, port)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, mapped.Port()), nil
+}
+
+// call sends the request to the REST API and returns the error in the response when it fails.
+func (c *Container) call(ctx context.Context, method, path string, body []byte) error {
+	url, err := c.RESTURL(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s failed with status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
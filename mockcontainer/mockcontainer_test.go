@@ -0,0 +1,83 @@
+package mockcontainer
+
+import (
+	"context"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+	"github.com/testcontainers/testcontainers-go"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestWithStubs(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "hello.json", "{}")
+	writeTestFile(t, dir, "notes.txt", "")
+	other := writeTestFile(t, t.TempDir(), "hello.json", "[]")
+
+	req := &testcontainers.GenericContainerRequest{}
+	assert.Nil(t, WithStubs(dir, other).Customize(req))
+	assert.Equal(t, []testcontainers.ContainerFile{
+		{HostFilePath: filepath.Join(dir, "hello.json"), ContainerFilePath: "/stubs/000-hello.json", FileMode: 0644},
+		{HostFilePath: other, ContainerFilePath: "/stubs/001-hello.json", FileMode: 0644},
+	}, req.Files)
+	assert.Equal(t, map[string]string{"MOCK_STUBS": "/stubs", "MOCK_STRICT_STARTUP": "true"}, req.Env)
+
+	assert.Error(t, WithStubs(filepath.Join(dir, "missing")).Customize(req))
+}
+
+// fakeContainer maps the REST port to a test server.
+type fakeContainer struct {
+	testcontainers.Container
+	address string
+}
+
+func (f fakeContainer) Host(ctx context.Context) (string, error) {
+	host, _, err := net.SplitHostPort(f.address)
+	return host, err
+}
+
+func (f fakeContainer) MappedPort(ctx context.Context, port nat.Port) (nat.Port, error) {
+	_, mapped, err := net.SplitHostPort(f.address)
+	return nat.Port(mapped + "/tcp"), err
+}
+
+func TestContainer_Stubs(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+		requests = append(requests, request.Method+" "+request.URL.Path+" "+string(body))
+		if len(body) > 0 && string(body) != `{"fullMethod": "/greeter.Greeter/Hello"}` {
+			http.Error(writer, `{"error": "invalid stub"}`, http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	mock := &Container{Container: fakeContainer{address: server.Listener.Addr().String()}}
+	ctx := context.Background()
+
+	url, err := mock.RESTURL(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, server.URL, url)
+	address, err := mock.GRPCAddress(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, server.Listener.Addr().String(), address)
+
+	assert.Nil(t, mock.AddStub(ctx, []byte(`{"fullMethod": "/greeter.Greeter/Hello"}`)))
+	assert.EqualError(t, mock.AddStub(ctx, []byte(`{}`)), `POST /stubs failed with status 400: {"error": "invalid stub"}`)
+	assert.Nil(t, mock.DeleteStubs(ctx))
+	assert.Equal(t, []string{
+		`POST /stubs {"fullMethod": "/greeter.Greeter/Hello"}`,
+		`POST /stubs {}`,
+		`DELETE /stubs `,
+	}, requests)
+}
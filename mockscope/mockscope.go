@@ -0,0 +1,240 @@
+// Package mockscope isolates the stubs of each test in a mock server shared by tests running in parallel. The stubs
+// of a scope only match the calls with its ID in the x-mock-scope metadata, which the interceptors of the scope add to
+// the calls of the clients, and they are deleted when the test finishes:
+//
+//	func TestHello(t *testing.T) {
+//		t.Parallel()
+//		scope := mockscope.New(t, stubclient.New("http://localhost:1068"))
+//		scope.Stub(helloJohn)
+//		conn, _ := grpc.Dial("localhost:10010", append(scope.DialOptions(), grpc.WithInsecure())...)
+//		...
+//		mockassert.Called(t, scope, "/greeter.Greeter/Hello", mockassert.Exact(&greeter.Request{Name: "John"}))
+//	}
+package mockscope
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/carvalhorr/protoc-gen-mock/stubclient"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+// MetadataKey is the key of the metadata with the ID of the scope.
+const MetadataKey = "x-mock-scope"
+
+var invalidIDCharacters = regexp.MustCompile(`[^A-Za-z0-9._/-]+`)
+
+// Scope holds the stubs added by a test.
+type Scope struct {
+	// ID is the value of the scope metadata, unique to the test
+	ID     string
+	t      testing.TB
+	client *stubclient.Client
+	stubs  []*stub.Stub
+	// stubs added with StubInOrder by method and request, and the number of steps of the sequence
+	ordered map[string]*stub.Stub
+	steps   int
+	mutex   sync.Mutex
+}
+
+// New creates the scope of the test with the stubs of the mock server managed by the client. The stubs added to the
+// scope are deleted when the test finishes.
+func New(t testing.TB, client *stubclient.Client) *Scope {
+	t.Helper()
+	s := &Scope{ID: newID(t.Name()), t: t, client: client}
+	t.Cleanup(s.deleteStubs)
+	return s
+}
+
+// newID returns the name of the test followed by a random suffix, so that the scopes of tests with the same name run
+// against the same mock server, e.g. from different packages, don't collide.
+func newID(name string) string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return invalidIDCharacters.ReplaceAllString(name, "_") + "-" + hex.EncodeToString(suffix)
+}
+
+// Stub adds the stubs to the mock server, only matching the calls of the scope. The test fails if any of them can't be
+// added. The stubs provided are not modified.
+func (s *Scope) Stub(stubs ...*stub.Stub) {
+	s.t.Helper()
+	for _, newStub := range stubs {
+		scoped := s.scopedStub(newStub)
+		if err := s.client.CreateStub(context.Background(), scoped); err != nil {
+			s.t.Fatalf("could not add the stub for %s: %s", newStub.FullMethod, err)
+		}
+		s.mutex.Lock()
+		s.stubs = append(s.stubs, scoped)
+		s.mutex.Unlock()
+	}
+}
+
+// StubInOrder adds the stubs as a sequence, named after the scope ID, that the calls of the scope must match in the order
+// provided. A call out of order fails with FAILED_PRECONDITION and is reported by AssertInOrder. The same stub can be
+// provided several times, e.g. for a call expected before and after another one, and calling StubInOrder again adds
+// steps to the end of the sequence.
+func (s *Scope) StubInOrder(stubs ...*stub.Stub) {
+	s.t.Helper()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ordered == nil {
+		s.ordered = make(map[string]*stub.Stub)
+	}
+	for _, newStub := range stubs {
+		s.steps++
+		scoped := s.scopedStub(newStub)
+		key := scoped.FullMethod + " " + scoped.Request.String()
+		if existing, ok := s.ordered[key]; ok {
+			existing.Sequence.Steps = append(existing.Sequence.Steps, s.steps)
+			if err := s.client.UpdateStub(context.Background(), existing); err != nil {
+				s.t.Fatalf("could not add step %d of the sequence for %s: %s", s.steps, newStub.FullMethod, err)
+			}
+			continue
+		}
+		scoped.Sequence = &stub.StubSequence{Name: s.ID, Steps: []int{s.steps}}
+		if err := s.client.CreateStub(context.Background(), scoped); err != nil {
+			s.t.Fatalf("could not add the stub for %s: %s", newStub.FullMethod, err)
+		}
+		s.ordered[key] = scoped
+		s.stubs = append(s.stubs, scoped)
+	}
+}
+
+// AssertInOrder checks that the calls of the scope matched all the stubs added with StubInOrder, in order, and reports
+// the calls out of order otherwise.
+func (s *Scope) AssertInOrder() bool {
+	s.t.Helper()
+	sequences, err := s.client.Sequences(context.Background(), s.ID)
+	if err != nil {
+		s.t.Errorf("could not verify the order of the calls of the scope %s: %s", s.ID, err)
+		return false
+	}
+	if len(sequences) == 0 {
+		s.t.Errorf("there are no stubs in order in the scope %s", s.ID)
+		return false
+	}
+	if sequences[0].Verified {
+		return true
+	}
+	message := fmt.Sprintf("%d of the %d calls expected in order were made", sequences[0].Completed, sequences[0].Steps)
+	for _, violation := range sequences[0].Violations {
+		message += "\n" + violation
+	}
+	s.t.Errorf("%s", message)
+	return false
+}
+
+func (s *Scope) scopedStub(original *stub.Stub) *stub.Stub {
+	scoped := *original
+	request := stub.StubRequest{Match: "partial", Content: stub.JsonString("{}")}
+	if original.Request != nil {
+		request = *original.Request
+	}
+	request.Metadata = s.scopedMetadata(request.Metadata)
+	scoped.Request = &request
+	return &scoped
+}
+
+func (s *Scope) scopedMetadata(original map[string][]string) map[string][]string {
+	md := make(map[string][]string, len(original)+1)
+	for key, values := range original {
+		md[key] = values
+	}
+	md[MetadataKey] = []string{s.ID}
+	return md
+}
+
+// Context returns the context of a call in the scope, e.g. for the clients shared by the tests.
+func (s *Scope) Context(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, MetadataKey, s.ID)
+}
+
+// UnaryClientInterceptor adds the scope metadata to the unary calls.
+func (s *Scope) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(s.Context(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor adds the scope metadata to the streaming calls.
+func (s *Scope) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(s.Context(ctx), desc, cc, method, opts...)
+	}
+}
+
+// DialOptions returns the options adding the interceptors of the scope to a client connection.
+func (s *Scope) DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(s.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(s.StreamClientInterceptor()),
+	}
+}
+
+// Verify checks how many times the stub of the scope for the request was matched. With StubsUsage, it makes the scope
+// usable with mockassert.
+func (s *Scope) Verify(ctx context.Context, verification stub.StubVerification) (stub.StubVerificationResult, error) {
+	if verification.Request != nil {
+		request := *verification.Request
+		request.Metadata = s.scopedMetadata(request.Metadata)
+		verification.Request = &request
+	}
+	return s.client.Verify(ctx, verification)
+}
+
+// StubsUsage returns how many times the stubs of the scope for the method were matched.
+func (s *Scope) StubsUsage(ctx context.Context, fullMethod string) ([]stub.StubUsage, error) {
+	usage, err := s.client.StubsUsage(ctx, fullMethod)
+	if err != nil {
+		return nil, err
+	}
+	scoped := make([]stub.StubUsage, 0, len(usage))
+	for _, u := range usage {
+		if u.Stub != nil && u.Stub.Request != nil && s.inScope(u.Stub.Request.Metadata) {
+			scoped = append(scoped, u)
+		}
+	}
+	return scoped, nil
+}
+
+func (s *Scope) inScope(md map[string][]string) bool {
+	values := md[MetadataKey]
+	return len(values) == 1 && values[0] == s.ID
+}
+
+// deleteStubs deletes the stubs of the scope from the mock server. They are deleted as listed by the server, since it
+// stores their content formatted.
+func (s *Scope) deleteStubs() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ctx := context.Background()
+	methods := make(map[string]bool)
+	for _, scoped := range s.stubs {
+		if methods[scoped.FullMethod] {
+			continue
+		}
+		methods[scoped.FullMethod] = true
+		stubs, err := s.client.ListStubs(ctx, scoped.FullMethod)
+		if err != nil {
+			s.t.Errorf("could not delete the stubs for %s of the scope %s: %s", scoped.FullMethod, s.ID, err)
+			continue
+		}
+		for _, listed := range stubs {
+			if listed.Request == nil || !s.inScope(listed.Request.Metadata) {
+				continue
+			}
+			if err := s.client.DeleteStub(ctx, listed); err != nil {
+				s.t.Errorf("could not delete the stub for %s of the scope %s: %s", scoped.FullMethod, s.ID, err)
+			}
+		}
+	}
+	s.stubs = nil
+	s.ordered = nil
+}
@@ -0,0 +1,177 @@
+package mockscope
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/carvalhorr/protoc-gen-mock/stubclient"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const helloMethod = "/greeter.Greeter/Hello"
+
+// newTestServer serves the stubs and verifications endpoints of the REST API with the store.
+func newTestServer(t *testing.T, store stub.StubsStore) *stubclient.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		var response interface{} = "OK"
+		switch request.Method + " " + request.URL.Path {
+		case "POST /stubs":
+			s := new(stub.Stub)
+			json.NewDecoder(request.Body).Decode(s)
+			store.Add(s)
+		case "PUT /stubs":
+			s := new(stub.Stub)
+			json.NewDecoder(request.Body).Decode(s)
+			store.Update(s)
+		case "GET /verifications/sequences":
+			sequences := make([]stub.SequenceStatus, 0)
+			for _, sequence := range store.(stub.SequenceTracker).GetSequences() {
+				if sequence.Name == request.URL.Query().Get("name") {
+					sequences = append(sequences, sequence)
+				}
+			}
+			response = sequences
+		case "GET /stubs":
+			response = store.GetStubsForMethod(request.URL.Query().Get("method"))
+		case "DELETE /stubs":
+			s := new(stub.Stub)
+			json.NewDecoder(request.Body).Decode(s)
+			store.Delete(s)
+		case "POST /verifications":
+			v := new(stub.StubVerification)
+			json.NewDecoder(request.Body).Decode(v)
+			response = v.Verify(store.GetMatchCount(&stub.Stub{FullMethod: v.FullMethod, Request: v.Request}))
+		case "GET /verifications":
+			usage := make([]stub.StubUsage, 0)
+			for _, s := range store.GetStubsForMethod(request.URL.Query().Get("method")) {
+				usage = append(usage, stub.StubUsage{Stub: s, MatchCount: store.GetMatchCount(s)})
+			}
+			response = usage
+		}
+		json.NewEncoder(writer).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return stubclient.New(server.URL)
+}
+
+func helloStub(name string) *stub.Stub {
+	return &stub.Stub{
+		FullMethod: helloMethod,
+		Type:       "mock",
+		Request:    &stub.StubRequest{Match: "exact", Content: stub.JsonString(`{"name":"` + name + `"}`), Metadata: map[string][]string{"tenant": {"a"}}},
+		Response:   &stub.StubResponse{Type: "success", Content: `{"greeting":"Hello"}`},
+	}
+}
+
+func TestScope_Stub(t *testing.T) {
+	store := stub.NewInMemoryStubsStore()
+	client := newTestServer(t, store)
+	original := helloStub("John")
+	var id string
+
+	t.Run("test", func(t *testing.T) {
+		scope := New(t, client)
+		id = scope.ID
+		assert.True(t, strings.HasPrefix(scope.ID, "TestScope_Stub/test-"), scope.ID)
+		scope.Stub(original)
+		other := New(t, client)
+		other.Stub(helloStub("John"))
+		assert.NotEqual(t, scope.ID, other.ID)
+
+		stubs := store.GetStubsForMethod(helloMethod)
+		assert.Equal(t, 2, len(stubs))
+		assert.Equal(t, map[string][]string{"tenant": {"a"}}, original.Request.Metadata, "the stubs provided are not modified")
+
+		scoped := helloStub("John")
+		scoped.Request.Metadata[MetadataKey] = []string{scope.ID}
+		assert.True(t, store.Exists(scoped))
+		store.RecordMatch(scoped)
+
+		result, err := scope.Verify(context.Background(), stub.StubVerification{FullMethod: helloMethod, Request: helloStub("John").Request, Times: 1})
+		assert.Nil(t, err)
+		assert.True(t, result.Verified, result.Message)
+		result, err = other.Verify(context.Background(), stub.StubVerification{FullMethod: helloMethod, Request: helloStub("John").Request, Times: 1})
+		assert.Nil(t, err)
+		assert.False(t, result.Verified)
+
+		usage, err := scope.StubsUsage(context.Background(), helloMethod)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(usage))
+		assert.Equal(t, 1, usage[0].MatchCount)
+	})
+
+	assert.Empty(t, store.GetStubsForMethod(helloMethod), "the stubs of the scopes are deleted when the test finishes")
+	assert.NotEmpty(t, id)
+}
+
+// recordingTB records the errors of a test instead of failing it.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestScope_StubInOrder(t *testing.T) {
+	store := stub.NewInMemoryStubsStore()
+	client := newTestServer(t, store)
+	matcher := stub.NewStubsMatcher(store)
+	r := &recordingTB{TB: t}
+	scope := New(r, client)
+	scope.StubInOrder(helloStub("John"), helloStub("Mary"))
+	scope.StubInOrder(helloStub("John"))
+
+	stubs := store.GetStubsForMethod(helloMethod)
+	assert.Equal(t, 2, len(stubs))
+	assert.Equal(t, &stub.StubSequence{Name: scope.ID, Steps: []int{1, 3}}, stubs[0].Sequence)
+	assert.Equal(t, &stub.StubSequence{Name: scope.ID, Steps: []int{2}}, stubs[1].Sequence)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("tenant", "a", MetadataKey, scope.ID))
+	assert.Equal(t, "success", matcher.Match(ctx, helloMethod, `{"name":"John"}`).Response.Type)
+	assert.Equal(t, "error", matcher.Match(ctx, helloMethod, `{"name":"John"}`).Response.Type)
+	assert.False(t, scope.AssertInOrder())
+	assert.Equal(t, 1, len(r.errors))
+	assert.True(t, strings.HasPrefix(r.errors[0], "1 of the 3 calls expected in order were made\ncall to /greeter.Greeter/Hello"), r.errors[0])
+
+	store.ResetMatchCounts()
+	for _, name := range []string{"John", "Mary", "John"} {
+		assert.Equal(t, "success", matcher.Match(ctx, helloMethod, `{"name":"`+name+`"}`).Response.Type)
+	}
+	assert.True(t, scope.AssertInOrder())
+}
+
+func TestScope_Interceptors(t *testing.T) {
+	scope := &Scope{ID: "test-1"}
+	var outgoing metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		outgoing, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "tenant", "a")
+	assert.Nil(t, scope.UnaryClientInterceptor()(ctx, helloMethod, nil, nil, nil, invoker))
+	assert.Equal(t, metadata.MD{"tenant": {"a"}, MetadataKey: {"test-1"}}, outgoing)
+
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		outgoing, _ = metadata.FromOutgoingContext(ctx)
+		return nil, nil
+	}
+	_, err := scope.StreamClientInterceptor()(context.Background(), &grpc.StreamDesc{}, nil, helloMethod, streamer)
+	assert.Nil(t, err)
+	assert.Equal(t, metadata.MD{MetadataKey: {"test-1"}}, outgoing)
+	assert.Equal(t, 2, len(scope.DialOptions()))
+}
+
+func TestNewID(t *testing.T) {
+	id := newID("TestHello/with spaces and ünicode")
+	assert.Regexp(t, `^TestHello/with_spaces_and_nicode-[0-9a-f]{8}$`, id)
+	assert.NotEqual(t, id, newID("TestHello/with spaces and ünicode"))
+}
@@ -0,0 +1,190 @@
+// Package mocksnapshot compares the calls recorded by the mock server with golden files, for snapshot style contract
+// tests. The first run writes the golden file, and the next ones fail with the differences when the calls change:
+//
+//	func TestCheckout(t *testing.T) {
+//		client := stubclient.New("http://localhost:1068")
+//		... // calls to the services recorded by the mock server
+//		mocksnapshot.MatchRecordings(t, client, "testdata/checkout.golden.json",
+//			mocksnapshot.WithRedaction(&stub.RecordingRedaction{Metadata: []string{"authorization"}}))
+//	}
+//
+// Run the tests with MOCK_UPDATE_SNAPSHOTS=1 to write the golden files again with the current calls. The golden files use
+// the format of the stub files, so they can also be loaded as stubs.
+package mocksnapshot
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/carvalhorr/protoc-gen-mock/mockscope"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/carvalhorr/protoc-gen-mock/stubclient"
+	"github.com/pmezard/go-difflib/difflib"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// UpdateEnv is the environment variable that, when set to 1, writes the golden files with the recordings instead of
+// comparing them.
+const UpdateEnv = "MOCK_UPDATE_SNAPSHOTS"
+
+// Option changes how the recordings are compared with the golden file.
+type Option func(*options)
+
+type options struct {
+	filter      func(recording *stub.Stub) bool
+	redaction   *stub.RecordingRedaction
+	normalizers []func(recording *stub.Stub)
+	unordered   bool
+}
+
+// WithFilter only compares the recordings for which filter returns true, e.g. the ones of a method.
+func WithFilter(filter func(recording *stub.Stub) bool) Option {
+	return func(o *options) {
+		o.filter = filter
+	}
+}
+
+// WithScope only compares the recordings of the calls made in the scope of mockscope.
+func WithScope(scope *mockscope.Scope) Option {
+	return WithFilter(func(recording *stub.Stub) bool {
+		if recording.Request == nil {
+			return false
+		}
+		values := recording.Request.Metadata[mockscope.MetadataKey]
+		return len(values) == 1 && values[0] == scope.ID
+	})
+}
+
+// WithRedaction replaces the sensitive values of the recordings, as the redaction of the mock server does, before they
+// are written to the golden file.
+func WithRedaction(redaction *stub.RecordingRedaction) Option {
+	return func(o *options) {
+		o.redaction = redaction
+	}
+}
+
+// WithNormalizer changes the recordings before they are compared, e.g. to replace the values that change on each run,
+// like timestamps or generated IDs. The normalizers run after the redaction, in the order provided.
+func WithNormalizer(normalizer func(recording *stub.Stub)) Option {
+	return func(o *options) {
+		o.normalizers = append(o.normalizers, normalizer)
+	}
+}
+
+// Unordered sorts the recordings by method and request, for calls made concurrently.
+func Unordered() Option {
+	return func(o *options) {
+		o.unordered = true
+	}
+}
+
+// MatchRecordings compares the calls recorded by the mock server with the golden file.
+func MatchRecordings(t testing.TB, client *stubclient.Client, goldenFile string, opts ...Option) bool {
+	t.Helper()
+	recordings, err := client.ExportRecordings(context.Background())
+	if err != nil {
+		t.Errorf("could not get the recordings: %s", err)
+		return false
+	}
+	return Match(t, recordings, goldenFile, opts...)
+}
+
+// Match compares the recordings with the golden file, writing it when it doesn't exist or UpdateEnv is set. The test
+// fails with the differences when they don't match. The recordings provided are not modified.
+func Match(t testing.TB, recordings []*stub.Stub, goldenFile string, opts ...Option) bool {
+	t.Helper()
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	actual, err := snapshot(recordings, o)
+	if err != nil {
+		t.Errorf("could not create the snapshot of the recordings: %s", err)
+		return false
+	}
+
+	expected, err := ioutil.ReadFile(goldenFile)
+	if os.IsNotExist(err) || os.Getenv(UpdateEnv) == "1" {
+		if err := writeGoldenFile(goldenFile, actual); err != nil {
+			t.Errorf("could not write the golden file: %s", err)
+			return false
+		}
+		t.Logf("golden file %s written with %d recordings", goldenFile, len(recordings))
+		return true
+	}
+	if err != nil {
+		t.Errorf("could not read the golden file: %s", err)
+		return false
+	}
+	if string(expected) == string(actual) {
+		return true
+	}
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(expected)),
+		B:        difflib.SplitLines(string(actual)),
+		FromFile: goldenFile,
+		ToFile:   "recordings",
+		Context:  3,
+	})
+	t.Errorf("the recordings don't match the golden file. Run with %s=1 to update it:\n%s", UpdateEnv, diff)
+	return false
+}
+
+// snapshot returns the recordings, filtered and normalized, in the format of the golden files.
+func snapshot(recordings []*stub.Stub, o *options) ([]byte, error) {
+	// copied so that the normalizers don't change the recordings provided
+	data, err := json.Marshal(recordings)
+	if err != nil {
+		return nil, err
+	}
+	copied := make([]*stub.Stub, 0, len(recordings))
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil, err
+	}
+
+	selected := make([]*stub.Stub, 0, len(copied))
+	for _, recording := range copied {
+		if o.filter != nil && !o.filter(recording) {
+			continue
+		}
+		if err := o.redaction.Redact(recording); err != nil {
+			return nil, err
+		}
+		removeScope(recording)
+		for _, normalize := range o.normalizers {
+			normalize(recording)
+		}
+		selected = append(selected, recording)
+	}
+	if o.unordered {
+		sort.SliceStable(selected, func(i, j int) bool {
+			if selected[i].FullMethod != selected[j].FullMethod {
+				return selected[i].FullMethod < selected[j].FullMethod
+			}
+			return selected[i].Request.String() < selected[j].Request.String()
+		})
+	}
+	data, err = json.MarshalIndent(selected, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// removeScope removes the metadata of the mockscope scopes, which changes on each run.
+func removeScope(recording *stub.Stub) {
+	if recording.Request == nil {
+		return
+	}
+	delete(recording.Request.Metadata, mockscope.MetadataKey)
+}
+
+func writeGoldenFile(goldenFile string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(goldenFile), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(goldenFile, data, 0644)
+}
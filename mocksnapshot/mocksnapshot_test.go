@@ -0,0 +1,96 @@
+package mocksnapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/mockscope"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// recordingT records the failures and logs of the test instead of reporting them.
+type recordingT struct {
+	testing.TB
+	failures []string
+	logs     []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingT) Logf(format string, args ...interface{}) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+func recording(method, name string, metadata map[string][]string) *stub.Stub {
+	return &stub.Stub{
+		FullMethod: method,
+		Type:       "mock",
+		Request:    &stub.StubRequest{Match: "exact", Content: stub.JsonString(`{"name":"` + name + `"}`), Metadata: metadata},
+		Response:   &stub.StubResponse{Type: "success", Content: stub.JsonString(`{"greeting":"Hello ` + name + `"}`)},
+	}
+}
+
+func TestMatch(t *testing.T) {
+	goldenFile := filepath.Join(t.TempDir(), "testdata", "hello.golden.json")
+	recordings := []*stub.Stub{recording("/greeter.Greeter/Hello", "John", map[string][]string{"authorization": {"Bearer 123"}})}
+	redaction := WithRedaction(&stub.RecordingRedaction{Metadata: []string{"authorization"}})
+	r := &recordingT{TB: t}
+
+	assert.True(t, Match(r, recordings, goldenFile, redaction))
+	assert.Equal(t, []string{"golden file " + goldenFile + " written with 1 recordings"}, r.logs)
+	data, err := ioutil.ReadFile(goldenFile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"authorization": [
+          "[REDACTED]"
+        ]`)
+	assert.Equal(t, []string{"Bearer 123"}, recordings[0].Request.Metadata["authorization"], "the recordings provided are not modified")
+
+	assert.True(t, Match(r, recordings, goldenFile, redaction))
+	assert.Empty(t, r.failures)
+
+	changed := []*stub.Stub{recording("/greeter.Greeter/Hello", "Jon", map[string][]string{"authorization": {"Bearer 456"}})}
+	assert.False(t, Match(r, changed, goldenFile, redaction))
+	assert.Equal(t, 1, len(r.failures))
+	assert.Contains(t, r.failures[0], "the recordings don't match the golden file. Run with MOCK_UPDATE_SNAPSHOTS=1 to update it:\n--- "+goldenFile+"\n+++ recordings\n")
+	assert.Contains(t, r.failures[0], `       "content": {
+-        "name": "John"
++        "name": "Jon"
+       },`)
+
+	t.Setenv(UpdateEnv, "1")
+	assert.True(t, Match(r, changed, goldenFile, redaction))
+	t.Setenv(UpdateEnv, "")
+	assert.True(t, Match(r, changed, goldenFile, redaction))
+}
+
+func TestMatch_FilterAndNormalize(t *testing.T) {
+	scope := &mockscope.Scope{ID: "TestCheckout-1234"}
+	recordings := []*stub.Stub{
+		recording("/greeter.Greeter/Hello", "Mary", map[string][]string{mockscope.MetadataKey: {scope.ID}}),
+		recording("/greeter.Greeter/Bye", "John", map[string][]string{mockscope.MetadataKey: {scope.ID}}),
+		recording("/greeter.Greeter/Hello", "John", map[string][]string{mockscope.MetadataKey: {scope.ID}, "request-id": {"abc"}}),
+		recording("/greeter.Greeter/Hello", "Other", map[string][]string{mockscope.MetadataKey: {"TestOther-5678"}}),
+	}
+	goldenFile := filepath.Join(t.TempDir(), "hello.golden.json")
+	r := &recordingT{TB: t}
+
+	assert.True(t, Match(r, recordings, goldenFile, WithScope(scope), Unordered(), WithNormalizer(func(recording *stub.Stub) {
+		delete(recording.Request.Metadata, "request-id")
+	})))
+	data, err := ioutil.ReadFile(goldenFile)
+	assert.Nil(t, err)
+	snapshot := make([]*stub.Stub, 0)
+	assert.Nil(t, json.Unmarshal(data, &snapshot))
+	assert.Equal(t, []string{"/greeter.Greeter/Bye", "/greeter.Greeter/Hello", "/greeter.Greeter/Hello"},
+		[]string{snapshot[0].FullMethod, snapshot[1].FullMethod, snapshot[2].FullMethod})
+	assert.Equal(t, stub.JsonString(`{"name":"John"}`), snapshot[1].Request.Content)
+	assert.Equal(t, map[string][]string{}, snapshot[1].Request.Metadata, "the scope and the normalized metadata are removed")
+	assert.Equal(t, stub.JsonString(`{"name":"Mary"}`), snapshot[2].Request.Content)
+}
@@ -0,0 +1,133 @@
+// Package mocktest runs the mock services generated by protoc-gen-mock in the test process, so that they can be used in
+// unit tests without Docker or separate processes:
+//
+//	func TestHello(t *testing.T) {
+//		mock := mocktest.Start(t, mocktest.WithService(greeter.NewGreeterMockService), mocktest.WithStubs(helloJohn))
+//		client := greeter.NewGreeterClient(mock.ClientConn())
+//		...
+//		mock.AssertCalled(helloJohnCall)
+//	}
+//
+// The gRPC server listens on an in-memory connection and is stopped when the test finishes. The mock services share the
+// package level state of grpchandler, so only one server should run at a time: don't use it in parallel tests.
+package mocktest
+
+import (
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"google.golang.org/grpc"
+	"testing"
+)
+
+// Option configures the server started by Start.
+type Option func(*options)
+
+type options struct {
+	services      []func(stubsMatcher stub.StubsMatcher) grpchandler.MockService
+	stubs         []*stub.Stub
+	stubFiles     []string
+	serverOptions []grpc.ServerOption
+	// see TestServer.RejectShadowedStubs
+	rejectShadowed bool
+}
+
+// WithService adds a mock service, e.g. greeter.NewGreeterMockService. At least one is required.
+func WithService(serviceRegisterCallback func(stubsMatcher stub.StubsMatcher) grpchandler.MockService) Option {
+	return func(o *options) {
+		o.services = append(o.services, serviceRegisterCallback)
+	}
+}
+
+// WithStubs adds the stubs when the server starts.
+func WithStubs(stubs ...*stub.Stub) Option {
+	return func(o *options) {
+		o.stubs = append(o.stubs, stubs...)
+	}
+}
+
+// WithStubFiles adds the stubs of the JSON files, or of the .json files of the directories, when the server starts.
+func WithStubFiles(paths ...string) Option {
+	return func(o *options) {
+		o.stubFiles = append(o.stubFiles, paths...)
+	}
+}
+
+// WithRejectShadowedStubs fails the stubs, and the stub files, that shadow or are shadowed by another stub instead of
+// logging them.
+func WithRejectShadowedStubs() Option {
+	return func(o *options) {
+		o.rejectShadowed = true
+	}
+}
+
+// WithServerOptions creates the gRPC server with the options, e.g. interceptors.
+func WithServerOptions(serverOptions ...grpc.ServerOption) Option {
+	return func(o *options) {
+		o.serverOptions = append(o.serverOptions, serverOptions...)
+	}
+}
+
+// Server is a mock server running in the test process. Its methods add stubs and verify how many times they were
+// matched, failing the test when they can't.
+type Server struct {
+	*TestServer
+	t testing.TB
+}
+
+// Start starts the mock services with the options. The test fails if they can't be started or a stub can't be added.
+// The server is stopped when the test finishes.
+func Start(t testing.TB, opts ...Option) *Server {
+	t.Helper()
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if len(o.services) == 0 {
+		t.Fatalf("no mock service to start. Add one with mocktest.WithService")
+	}
+	register := func(stubsMatcher stub.StubsMatcher) grpchandler.MockService {
+		if len(o.services) == 1 {
+			return o.services[0](stubsMatcher)
+		}
+		services := make([]grpchandler.MockService, 0, len(o.services))
+		for _, service := range o.services {
+			services = append(services, service(stubsMatcher))
+		}
+		return grpchandler.NewCompositeMockService(services)
+	}
+	s := &Server{TestServer: NewTestServerWithOptions(t, register, o.serverOptions...), t: t}
+	s.RejectShadowedStubs = o.rejectShadowed
+	if len(o.stubFiles) > 0 {
+		if err := s.LoadStubFiles(o.stubFiles...); err != nil {
+			t.Fatalf("could not load the stubs: %s", err)
+		}
+	}
+	s.Stub(o.stubs...)
+	return s
+}
+
+// Stub adds the stubs, failing the test if any of them is not valid.
+func (s *Server) Stub(stubs ...*stub.Stub) {
+	s.t.Helper()
+	for _, newStub := range stubs {
+		if err := s.AddStub(newStub); err != nil {
+			s.t.Fatalf("could not add the stub for %s: %s", newStub.FullMethod, err)
+		}
+	}
+}
+
+// AssertCalled checks how many times the stub for the request was matched, failing the test with the reason when it
+// doesn't match the verification. It returns whether the verification passed.
+func (s *Server) AssertCalled(verification stub.StubVerification) bool {
+	s.t.Helper()
+	result, err := s.Verify(verification)
+	if err != nil {
+		s.t.Errorf("could not verify the calls of %s: %s", verification.FullMethod, err)
+		return false
+	}
+	if !result.Verified {
+		s.t.Errorf("%s", result.Message)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package mocktest
+
+import (
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	helloMethod = "/greeter.Greeter/Hello"
+	byeMethod   = "/greeter.Farewell/Bye"
+)
+
+type fakeMockService struct {
+	grpchandler.MockService
+	method string
+}
+
+func (fakeMockService) Register(s *grpc.Server) {}
+
+func (f fakeMockService) GetSupportedMethods() []string {
+	return []string{f.method}
+}
+
+func (f fakeMockService) GetRequestInstance(methodName string) proto.Message {
+	if methodName != f.method {
+		return nil
+	}
+	return new(wrapperspb.StringValue)
+}
+
+func (fakeMockService) GetResponseInstance(methodName string) proto.Message {
+	return new(wrapperspb.StringValue)
+}
+
+func (f fakeMockService) GetStubsValidator() stub.StubsValidator {
+	return f
+}
+
+func (fakeMockService) IsValid(s *stub.Stub) (bool, []string) {
+	return s.IsValid()
+}
+
+func newFakeMockService(method string) func(stubsMatcher stub.StubsMatcher) grpchandler.MockService {
+	return func(stubsMatcher stub.StubsMatcher) grpchandler.MockService {
+		return fakeMockService{method: method}
+	}
+}
+
+// recordingT records the failures of the test instead of failing it.
+type recordingT struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingT) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+}
+
+func helloStub(name string) *stub.Stub {
+	s, _ := stub.NewStubBuilder(helloMethod).WithRequest(wrapperspb.String(name)).RespondWith(wrapperspb.String("Hello " + name)).Build()
+	return s
+}
+
+func TestStart(t *testing.T) {
+	dir := t.TempDir()
+	stubsFile := filepath.Join(dir, "stubs.json")
+	assert.Nil(t, ioutil.WriteFile(stubsFile, []byte(`{"fullMethod": "/greeter.Farewell/Bye", "type": "mock",
+		"request": {"match": "exact", "content": "John"}, "response": {"type": "success", "content": "Bye John"}}`), 0644))
+
+	mock := Start(t, WithService(newFakeMockService(helloMethod)), WithService(newFakeMockService(byeMethod)),
+		WithStubs(helloStub("John")), WithStubFiles(stubsFile))
+	assert.NotNil(t, mock.ClientConn())
+	assert.Equal(t, 2, len(mock.StubsStore.GetAllStubs()))
+
+	mock.Stub(helloStub("Mary"))
+	matched := helloStub("Mary")
+	mock.StubsStore.RecordMatch(matched)
+	assert.True(t, mock.AssertCalled(stub.StubVerification{
+		FullMethod: helloMethod,
+		Request:    &stub.StubRequest{Match: "exact", Content: `"Mary"`},
+		Times:      1,
+	}))
+}
+
+func TestServer_Failures(t *testing.T) {
+	recorder := &recordingT{TB: t}
+	mock := Start(recorder, WithService(newFakeMockService(helloMethod)))
+
+	mock.Stub(helloStub("John"), helloStub("John"))
+	assert.False(t, mock.AssertCalled(stub.StubVerification{
+		FullMethod: helloMethod,
+		Request:    &stub.StubRequest{Match: "exact", Content: `"John"`},
+		Times:      1,
+	}))
+	assert.False(t, mock.AssertCalled(stub.StubVerification{FullMethod: helloMethod}))
+	assert.Equal(t, []string{
+		"could not add the stub for /greeter.Greeter/Hello: stub already exists",
+		`stub /greeter.Greeter/Hello -> {"match":"exact","content":"\"John\"","metadata":null} expected to be matched exactly 1 time(s) but was matched 0 time(s)`,
+		"could not verify the calls of /greeter.Greeter/Hello: request can't be empty",
+	}, recorder.failures)
+}
+
+func TestStart_WithRejectShadowedStubs(t *testing.T) {
+	recorder := &recordingT{TB: t}
+	mock := Start(recorder, WithService(newFakeMockService(helloMethod)), WithRejectShadowedStubs())
+
+	tenantStub := helloStub("John")
+	tenantStub.Request.Metadata = map[string][]string{"tenant": {"a"}}
+	mock.Stub(helloStub("John"), tenantStub)
+	assert.Equal(t, 1, len(mock.StubsStore.GetAllStubs()))
+	if assert.Equal(t, 1, len(recorder.failures)) {
+		assert.Contains(t, recorder.failures[0], "stub shadowed: the stub is never matched")
+	}
+}
+
+func TestStart_WithoutService(t *testing.T) {
+	recorder := &recordingT{TB: t}
+	Start(recorder)
+	assert.Equal(t, "no mock service to start. Add one with mocktest.WithService", recorder.failures[0])
+}
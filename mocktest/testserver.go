@@ -0,0 +1,97 @@
+package mocktest
+
+import (
+	"context"
+	"github.com/carvalhorr/protoc-gen-mock/bootstrap"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"testing"
+)
+
+const testServerBufferSize = 1024 * 1024
+
+// TestServer runs the mock services in the test process. The gRPC server listens on an in-memory connection, so no ports are used.
+// The handlers share the package level state of grpchandler, so only one test server should run at a time.
+type TestServer struct {
+	StubsStore      stub.StubsStore
+	RecordingsStore stub.RecordingsStore
+	// Reject the stubs that shadow or are shadowed by another stub instead of logging them, see stub.FindShadowing
+	RejectShadowedStubs bool
+	service             grpchandler.MockService
+	server              *grpc.Server
+	listener            *bufconn.Listener
+	conn                *grpc.ClientConn
+}
+
+// NewTestServer starts the mock services added by serviceRegisterCallback. The server is stopped when the test finishes.
+func NewTestServer(t testing.TB, serviceRegisterCallback func(stubsMatcher stub.StubsMatcher) grpchandler.MockService) *TestServer {
+	t.Helper()
+	return NewTestServerWithOptions(t, serviceRegisterCallback)
+}
+
+// NewTestServerWithOptions starts the mock services added by serviceRegisterCallback in a gRPC server created with the
+// options, e.g. interceptors. The server is stopped when the test finishes.
+func NewTestServerWithOptions(t testing.TB, serviceRegisterCallback func(stubsMatcher stub.StubsMatcher) grpchandler.MockService, options ...grpc.ServerOption) *TestServer {
+	t.Helper()
+	s := &TestServer{
+		StubsStore:      stub.NewInMemoryStubsStore(),
+		RecordingsStore: stub.NewRecordingsStore(),
+		listener:        bufconn.Listen(testServerBufferSize),
+		server:          grpc.NewServer(options...),
+	}
+	s.service = serviceRegisterCallback(stub.NewStubsMatcher(s.StubsStore))
+	grpchandler.SetSupportedMockService(s.service)
+	grpchandler.SetRecordingsStore(s.RecordingsStore)
+	grpchandler.SetStubsStore(s.StubsStore)
+	s.service.Register(s.server)
+	go s.server.Serve(s.listener)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return s.listener.Dial()
+		}),
+		grpc.WithInsecure())
+	if err != nil {
+		s.server.Stop()
+		t.Fatalf("could not connect to the test server: %s", err)
+	}
+	s.conn = conn
+	t.Cleanup(s.Close)
+	return s
+}
+
+// ClientConn returns the connection to the test server used to create the clients of the mocked services.
+func (s *TestServer) ClientConn() *grpc.ClientConn {
+	return s.conn
+}
+
+// AddStub validates and adds the stub as the REST API does.
+func (s *TestServer) AddStub(newStub *stub.Stub) error {
+	return bootstrap.AddStub(s.service, s.StubsStore, newStub, s.RejectShadowedStubs)
+}
+
+// LoadStubFiles adds the stubs of the JSON files, or of the .json files of the directories. The error returned lists the
+// stubs that are not valid, or shadowed with RejectShadowedStubs, the others are still added.
+func (s *TestServer) LoadStubFiles(paths ...string) error {
+	return bootstrap.LoadStubFiles(s.service, s.StubsStore, s.RejectShadowedStubs, paths...)
+}
+
+// Reset deletes all the stubs so that the server can be reused by the next test.
+func (s *TestServer) Reset() {
+	s.StubsStore.DeleteAll()
+	s.StubsStore.ResetMatchCounts()
+}
+
+// Verify checks how many times the stub for the request was matched.
+func (s *TestServer) Verify(verification stub.StubVerification) (stub.StubVerificationResult, error) {
+	return bootstrap.VerifyStub(s.service, s.StubsStore, verification)
+}
+
+// Close stops the server and closes the client connection. It is called automatically when the test finishes.
+func (s *TestServer) Close() {
+	s.conn.Close()
+	s.server.Stop()
+}
@@ -0,0 +1,35 @@
+package mocktest
+
+import (
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+)
+
+func TestTestServer_AddStubAndVerify(t *testing.T) {
+	ts := NewTestServer(t, newFakeMockService(helloMethod))
+	assert.NotNil(t, ts.ClientConn())
+
+	s, _ := stub.NewStubBuilder(helloMethod).WithRequest(wrapperspb.String("John")).RespondWith(wrapperspb.String("Hello")).Build()
+	assert.Nil(t, ts.AddStub(s))
+	assert.EqualError(t, ts.AddStub(s), "stub already exists")
+	ts.StubsStore.RecordMatch(s)
+
+	result, err := ts.Verify(stub.StubVerification{
+		FullMethod: helloMethod,
+		Request:    &stub.StubRequest{Match: "exact", Content: `"John"`},
+		Times:      1,
+	})
+	assert.Nil(t, err)
+	assert.True(t, result.Verified, result.Message)
+
+	ts.Reset()
+	assert.Empty(t, ts.StubsStore.GetAllStubs())
+}
+
+func TestTestServer_AddStubNotSupported(t *testing.T) {
+	ts := NewTestServer(t, newFakeMockService(helloMethod))
+	s, _ := stub.NewStubBuilder("/pkg.Service/Other").RespondWith(wrapperspb.String("Hello")).Build()
+	assert.EqualError(t, ts.AddStub(s), "method /pkg.Service/Other is not supported")
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	httputils "github.com/carvalhorr/goutils/http"
 	"github.com/carvalhorr/protoc-gen-mock/stub"
@@ -25,6 +26,22 @@ type MockServerClient interface {
 	) error
 
 	DeleteAllStubs() error
+
+	GetMatchCount(
+		fullMethod string,
+		ctx context.Context,
+		req proto.Message,
+	) (int, error)
+
+	VerifyStub(
+		fullMethod string,
+		ctx context.Context,
+		req proto.Message,
+		times int,
+		atLeast bool,
+	) error
+
+	GetUnmatchedStubs() ([]*stub.Stub, error)
 }
 
 func New(
@@ -55,17 +72,12 @@ func (c *client) AddStub(
 	resp proto.Message,
 	error *status.Status,
 ) error {
-	reqJson := toJsonString(req)
 	respJson := toJsonString(resp)
 	errResp := toErrorResponse(error)
 	s := &stub.Stub{
 		FullMethod: fullMethod,
 		Type:       "mock",
-		Request: &stub.StubRequest{
-			Match:    "exact",
-			Content:  reqJson,
-			Metadata: getMetadata(ctx),
-		},
+		Request:    newStubRequest(ctx, req),
 		Response: &stub.StubResponse{
 			Type:    getResponseType(resp, error),
 			Content: respJson,
@@ -102,6 +114,89 @@ func (c *client) DeleteAllStubs() error {
 	return fmt.Errorf("error: status %s", resp.Status)
 }
 
+func (c *client) GetMatchCount(
+	fullMethod string,
+	ctx context.Context,
+	req proto.Message,
+) (int, error) {
+	result, err := c.verify(stub.StubVerification{
+		FullMethod: fullMethod,
+		Request:    newStubRequest(ctx, req),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.MatchCount, nil
+}
+
+func (c *client) VerifyStub(
+	fullMethod string,
+	ctx context.Context,
+	req proto.Message,
+	times int,
+	atLeast bool,
+) error {
+	result, err := c.verify(stub.StubVerification{
+		FullMethod: fullMethod,
+		Request:    newStubRequest(ctx, req),
+		Times:      times,
+		AtLeast:    atLeast,
+	})
+	if err != nil {
+		return err
+	}
+	if !result.Verified {
+		return errors.New(result.Message)
+	}
+	return nil
+}
+
+func (c *client) verify(verification stub.StubVerification) (*stub.StubVerificationResult, error) {
+	b, err := json.Marshal(verification)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.HttpClient.Post(fmt.Sprintf("http://%s:%d/verifications", c.host, c.port), "application/json", bytes.NewBuffer(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != 200 {
+		return nil, fmt.Errorf("error: status %s", r.Status)
+	}
+	result := new(stub.StubVerificationResult)
+	err = json.NewDecoder(r.Body).Decode(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *client) GetUnmatchedStubs() ([]*stub.Stub, error) {
+	r, err := c.HttpClient.Get(fmt.Sprintf("http://%s:%d/verifications/unmatched", c.host, c.port))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != 200 {
+		return nil, fmt.Errorf("error: status %s", r.Status)
+	}
+	stubs := make([]*stub.Stub, 0)
+	err = json.NewDecoder(r.Body).Decode(&stubs)
+	if err != nil {
+		return nil, err
+	}
+	return stubs, nil
+}
+
+func newStubRequest(ctx context.Context, req proto.Message) *stub.StubRequest {
+	return &stub.StubRequest{
+		Match:    "exact",
+		Content:  toJsonString(req),
+		Metadata: getMetadata(ctx),
+	}
+}
+
 func getMetadata(ctx context.Context) map[string][]string {
 	md, ok := metadata.FromOutgoingContext(ctx)
 	if !ok {
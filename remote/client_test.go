@@ -126,3 +126,64 @@ func TestClient_AddStub_HttpError(t *testing.T) {
 	err := client.AddStub("", context.Background(), &Request{}, nil, status.New(codes.AlreadyExists, "error"))
 	assert.EqualError(t, err, "http error")
 }
+
+func TestClient_VerifyStub_Success(t *testing.T) {
+	mockHttpClient := new(httputils.MockClient)
+	mockHttpClient.On("Post",
+		mock.Anything, mock.Anything, mock.Anything).Return(&http.Response{
+		Status:     "OK",
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(strings.NewReader(`{"verified":true,"matchCount":1,"message":""}`)),
+	}, nil)
+	client := &client{
+		HttpClient: mockHttpClient,
+	}
+	err := client.VerifyStub("", context.Background(), &Request{}, 1, false)
+	assert.Nil(t, err)
+}
+
+func TestClient_VerifyStub_NotVerified(t *testing.T) {
+	mockHttpClient := new(httputils.MockClient)
+	mockHttpClient.On("Post",
+		mock.Anything, mock.Anything, mock.Anything).Return(&http.Response{
+		Status:     "OK",
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(strings.NewReader(`{"verified":false,"matchCount":0,"message":"not matched"}`)),
+	}, nil)
+	client := &client{
+		HttpClient: mockHttpClient,
+	}
+	err := client.VerifyStub("", context.Background(), &Request{}, 1, true)
+	assert.EqualError(t, err, "not matched")
+}
+
+func TestClient_GetMatchCount_StatusNot200(t *testing.T) {
+	mockHttpClient := new(httputils.MockClient)
+	mockHttpClient.On("Post",
+		mock.Anything, mock.Anything, mock.Anything).Return(&http.Response{
+		Status:     "404 Not Found",
+		StatusCode: 404,
+		Body:       ioutil.NopCloser(strings.NewReader("Stub not found")),
+	}, nil)
+	client := &client{
+		HttpClient: mockHttpClient,
+	}
+	_, err := client.GetMatchCount("", context.Background(), &Request{})
+	assert.EqualError(t, err, "error: status 404 Not Found")
+}
+
+func TestClient_GetUnmatchedStubs_Success(t *testing.T) {
+	mockHttpClient := new(httputils.MockClient)
+	mockHttpClient.On("Get", mock.Anything).Return(&http.Response{
+		Status:     "OK",
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(strings.NewReader(`[{"fullMethod":"/pkg.Service/Method","type":"mock"}]`)),
+	}, nil)
+	client := &client{
+		HttpClient: mockHttpClient,
+	}
+	stubs, err := client.GetUnmatchedStubs()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(stubs))
+	assert.Equal(t, "/pkg.Service/Method", stubs[0].FullMethod)
+}
@@ -0,0 +1,248 @@
+package restcontrollers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+)
+
+// AdminController changes the settings of the running mock server, e.g. the log level, so that it does not need to be restarted.
+type AdminController struct {
+	Latency grpchandler.LatencyStats
+	Chaos   grpchandler.Chaos
+	Info    ServerInfo
+}
+
+// ServerInfo describes the running mock server: its version, the mock services with their methods and the features enabled.
+type ServerInfo struct {
+	Version  string        `json:"version"`
+	Services []ServiceInfo `json:"services"`
+	Features []string      `json:"features"`
+}
+
+// ServiceInfo is a mock service served and how it was generated, when known.
+type ServiceInfo struct {
+	Name       string                      `json:"name"`
+	ServiceSet string                      `json:"serviceSet,omitempty"`
+	Methods    []string                    `json:"methods"`
+	Generation *grpchandler.GenerationInfo `json:"generation,omitempty"`
+}
+
+// LogLevel is the body of the requests and responses of the log level endpoints.
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+func (c AdminController) GetHandlers() []RESTHandler {
+	return []RESTHandler{
+		{
+			Name:    "GetLogLevel",
+			Path:    "/loglevel",
+			Methods: []string{http.MethodGet},
+			Handler: c.getLogLevelHandler,
+		},
+		{
+			Name:    "SetLogLevel",
+			Path:    "/loglevel",
+			Methods: []string{http.MethodPut},
+			Handler: c.setLogLevelHandler,
+		},
+		{
+			Name:    "GetLatency",
+			Path:    "/latency",
+			Methods: []string{http.MethodGet},
+			Handler: c.getLatencyHandler,
+		},
+		{
+			Name:    "ResetLatency",
+			Path:    "/latency",
+			Methods: []string{http.MethodDelete},
+			Handler: c.resetLatencyHandler,
+		},
+		{
+			Name:    "GetInfo",
+			Path:    "/info",
+			Methods: []string{http.MethodGet},
+			Handler: c.getInfoHandler,
+		},
+		{
+			Name:    "GetChaos",
+			Path:    "/chaos",
+			Methods: []string{http.MethodGet},
+			Handler: c.getChaosHandler,
+		},
+		{
+			Name:    "SetChaos",
+			Path:    "/chaos",
+			Methods: []string{http.MethodPut},
+			Handler: c.setChaosHandler,
+		},
+		{
+			Name:    "DisableChaos",
+			Path:    "/chaos",
+			Methods: []string{http.MethodDelete},
+			Handler: c.disableChaosHandler,
+		},
+		{
+			Name:    "GetClock",
+			Path:    "/clock",
+			Methods: []string{http.MethodGet},
+			Handler: c.getClockHandler,
+		},
+		{
+			Name:    "SetClock",
+			Path:    "/clock",
+			Methods: []string{http.MethodPut},
+			Handler: c.setClockHandler,
+		},
+		{
+			Name:    "ResetClock",
+			Path:    "/clock",
+			Methods: []string{http.MethodDelete},
+			Handler: c.resetClockHandler,
+		},
+	}
+}
+
+func (c AdminController) GetPath() string {
+	return "/admin"
+}
+
+func (c AdminController) getLogLevelHandler(writer http.ResponseWriter, request *http.Request) {
+	writeErr := writeResponse(writer, LogLevel{Level: log.GetLevel().String()})
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
+
+func (c AdminController) setLogLevelHandler(writer http.ResponseWriter, request *http.Request) {
+	bodyData, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, "could not read log level in payload")
+		return
+	}
+	defer request.Body.Close()
+
+	logLevel := new(LogLevel)
+	if err := json.Unmarshal(bodyData, logLevel); err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, "could not read log level in payload")
+		return
+	}
+	level, err := log.ParseLevel(logLevel.Level)
+	if err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, fmt.Sprintf("invalid log level '%s'. Use one of panic, fatal, error, warn, info, debug or trace", logLevel.Level))
+		return
+	}
+	log.Infof("REST: changing the log level from %s to %s", log.GetLevel(), level)
+	log.SetLevel(level)
+
+	writeErr := writeResponse(writer, LogLevel{Level: level.String()})
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
+
+func (c AdminController) getLatencyHandler(writer http.ResponseWriter, request *http.Request) {
+	log.Info("REST: received call to get latency")
+
+	writeErr := writeResponse(writer, c.Latency.GetAll())
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
+
+func (c AdminController) resetLatencyHandler(writer http.ResponseWriter, request *http.Request) {
+	log.Info("REST: received call to reset latency")
+
+	c.Latency.Reset()
+	writeSuccessResponse(writer)
+}
+
+func (c AdminController) getChaosHandler(writer http.ResponseWriter, request *http.Request) {
+	writeErr := writeResponse(writer, c.Chaos.Get())
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
+
+func (c AdminController) setChaosHandler(writer http.ResponseWriter, request *http.Request) {
+	bodyData, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, "could not read chaos configuration in payload")
+		return
+	}
+	defer request.Body.Close()
+
+	config := grpchandler.ChaosConfig{}
+	if err := json.Unmarshal(bodyData, &config); err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, "could not read chaos configuration in payload")
+		return
+	}
+	if err := c.Chaos.Set(config); err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Warnf("REST: chaos configuration changed to %s", bodyData)
+
+	writeErr := writeResponse(writer, c.Chaos.Get())
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
+
+func (c AdminController) disableChaosHandler(writer http.ResponseWriter, request *http.Request) {
+	log.Warn("REST: chaos disabled")
+
+	c.Chaos.Set(grpchandler.ChaosConfig{})
+	writeSuccessResponse(writer)
+}
+
+func (c AdminController) getClockHandler(writer http.ResponseWriter, request *http.Request) {
+	writeErr := writeResponse(writer, stub.GetClock())
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
+
+func (c AdminController) setClockHandler(writer http.ResponseWriter, request *http.Request) {
+	bodyData, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, "could not read clock in payload")
+		return
+	}
+	defer request.Body.Close()
+
+	config := stub.ClockConfig{}
+	if err := json.Unmarshal(bodyData, &config); err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, "could not read clock in payload")
+		return
+	}
+	if err := stub.SetClock(config); err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Infof("REST: clock changed to %s", bodyData)
+
+	writeErr := writeResponse(writer, stub.GetClock())
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
+
+func (c AdminController) resetClockHandler(writer http.ResponseWriter, request *http.Request) {
+	log.Info("REST: clock reset to the real time")
+
+	stub.ResetClock()
+	writeSuccessResponse(writer)
+}
+
+func (c AdminController) getInfoHandler(writer http.ResponseWriter, request *http.Request) {
+	writeErr := writeResponse(writer, c.Info)
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
@@ -0,0 +1,184 @@
+package restcontrollers
+
+import (
+	"errors"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminController_GetPath(t *testing.T) {
+	assert.Equal(t, "/admin", AdminController{}.GetPath())
+}
+
+func TestAdminController_GetHandlers(t *testing.T) {
+	ctrl := AdminController{}
+
+	assert.Equal(t, 11, len(ctrl.GetHandlers()))
+	assert.Equal(t, "/loglevel", findHandler(ctrl.GetHandlers(), "GetLogLevel").Path)
+	assert.Equal(t, []string{http.MethodGet}, findHandler(ctrl.GetHandlers(), "GetLogLevel").Methods)
+	assert.Equal(t, "/loglevel", findHandler(ctrl.GetHandlers(), "SetLogLevel").Path)
+	assert.Equal(t, []string{http.MethodPut}, findHandler(ctrl.GetHandlers(), "SetLogLevel").Methods)
+	assert.Equal(t, "/latency", findHandler(ctrl.GetHandlers(), "GetLatency").Path)
+	assert.Equal(t, []string{http.MethodDelete}, findHandler(ctrl.GetHandlers(), "ResetLatency").Methods)
+	assert.Equal(t, "/info", findHandler(ctrl.GetHandlers(), "GetInfo").Path)
+	assert.Equal(t, []string{http.MethodGet}, findHandler(ctrl.GetHandlers(), "GetInfo").Methods)
+	assert.Equal(t, "/chaos", findHandler(ctrl.GetHandlers(), "SetChaos").Path)
+	assert.Equal(t, []string{http.MethodPut}, findHandler(ctrl.GetHandlers(), "SetChaos").Methods)
+	assert.Equal(t, []string{http.MethodDelete}, findHandler(ctrl.GetHandlers(), "DisableChaos").Methods)
+	assert.Equal(t, "/clock", findHandler(ctrl.GetHandlers(), "SetClock").Path)
+	assert.Equal(t, []string{http.MethodPut}, findHandler(ctrl.GetHandlers(), "SetClock").Methods)
+	assert.Equal(t, []string{http.MethodDelete}, findHandler(ctrl.GetHandlers(), "ResetClock").Methods)
+}
+
+func TestAdminController_clockHandlers(t *testing.T) {
+	defer stub.ResetClock()
+	ctrl := AdminController{}
+
+	response := httptest.NewRecorder()
+	findHandler(ctrl.GetHandlers(), "SetClock").Handler(response, httptest.NewRequest(http.MethodPut, "/admin/clock", strings.NewReader(`{"time":"2024-01-31T23:59:00Z"}`)))
+	assert.Equal(t, 200, response.Code)
+	assert.Equal(t, `{"now":"2024-01-31T23:59:00Z","frozen":true}`, response.Body.String())
+
+	response = httptest.NewRecorder()
+	findHandler(ctrl.GetHandlers(), "GetClock").Handler(response, httptest.NewRequest(http.MethodGet, "/admin/clock", nil))
+	assert.Equal(t, 200, response.Code)
+	assert.Equal(t, `{"now":"2024-01-31T23:59:00Z","frozen":true}`, response.Body.String())
+
+	response = httptest.NewRecorder()
+	findHandler(ctrl.GetHandlers(), "SetClock").Handler(response, httptest.NewRequest(http.MethodPut, "/admin/clock", strings.NewReader(`{"offset":"tomorrow"}`)))
+	assert.Equal(t, 400, response.Code)
+	assert.Equal(t, "the clock offset 'tomorrow' is not a valid duration", response.Body.String())
+
+	response = httptest.NewRecorder()
+	findHandler(ctrl.GetHandlers(), "ResetClock").Handler(response, httptest.NewRequest(http.MethodDelete, "/admin/clock", nil))
+	assert.Equal(t, 200, response.Code)
+	assert.False(t, stub.GetClock().Frozen)
+}
+
+func TestAdminController_logLevelHandlers(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+	log.SetLevel(log.InfoLevel)
+	ctrl := AdminController{}
+
+	response := httptest.NewRecorder()
+	findHandler(ctrl.GetHandlers(), "GetLogLevel").Handler(response, httptest.NewRequest(http.MethodGet, "/admin/loglevel", nil))
+	assert.Equal(t, 200, response.Code)
+	assert.Equal(t, `{"level":"info"}`, response.Body.String())
+
+	response = httptest.NewRecorder()
+	findHandler(ctrl.GetHandlers(), "SetLogLevel").Handler(response, httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(`{"level":"DEBUG"}`)))
+	assert.Equal(t, 200, response.Code)
+	assert.Equal(t, `{"level":"debug"}`, response.Body.String())
+	assert.Equal(t, log.DebugLevel, log.GetLevel())
+}
+
+func TestAdminController_setLogLevelHandler_Invalid(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+	log.SetLevel(log.InfoLevel)
+	ctrl := AdminController{}
+
+	response := httptest.NewRecorder()
+	findHandler(ctrl.GetHandlers(), "SetLogLevel").Handler(response, httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(`{"level":"verbose"}`)))
+	assert.Equal(t, 400, response.Code)
+	assert.Equal(t, "invalid log level 'verbose'. Use one of panic, fatal, error, warn, info, debug or trace", response.Body.String())
+
+	response = httptest.NewRecorder()
+	findHandler(ctrl.GetHandlers(), "SetLogLevel").Handler(response, httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(`debug`)))
+	assert.Equal(t, 400, response.Code)
+	assert.Equal(t, log.InfoLevel, log.GetLevel())
+}
+
+type fakeLatencyStats struct {
+	reset bool
+}
+
+func (f *fakeLatencyStats) GetAll() []grpchandler.MethodLatency {
+	return []grpchandler.MethodLatency{{Method: "method1", Count: 2, P50Ms: 1, P95Ms: 2, P99Ms: 2, MaxMs: 2.5}}
+}
+
+func (f *fakeLatencyStats) Reset() {
+	f.reset = true
+}
+
+func TestAdminController_latencyHandlers(t *testing.T) {
+	latency := new(fakeLatencyStats)
+	ctrl := AdminController{Latency: latency}
+
+	response := httptest.NewRecorder()
+	findHandler(ctrl.GetHandlers(), "GetLatency").Handler(response, httptest.NewRequest(http.MethodGet, "/admin/latency", nil))
+	assert.Equal(t, 200, response.Code)
+	assert.Equal(t, `[{"method":"method1","count":2,"p50Ms":1,"p95Ms":2,"p99Ms":2,"maxMs":2.5}]`, response.Body.String())
+
+	response = httptest.NewRecorder()
+	findHandler(ctrl.GetHandlers(), "ResetLatency").Handler(response, httptest.NewRequest(http.MethodDelete, "/admin/latency", nil))
+	assert.Equal(t, 200, response.Code)
+	assert.True(t, latency.reset)
+}
+
+type fakeChaos struct {
+	config grpchandler.ChaosConfig
+}
+
+func (f *fakeChaos) Get() grpchandler.ChaosConfig {
+	return f.config
+}
+
+func (f *fakeChaos) Set(config grpchandler.ChaosConfig) error {
+	if config.Percentage > 100 {
+		return errors.New("invalid percentage")
+	}
+	f.config = config
+	return nil
+}
+
+func TestAdminController_chaosHandlers(t *testing.T) {
+	chaos := new(fakeChaos)
+	ctrl := AdminController{Chaos: chaos}
+
+	response := httptest.NewRecorder()
+	findHandler(ctrl.GetHandlers(), "SetChaos").Handler(response, httptest.NewRequest(http.MethodPut, "/admin/chaos",
+		strings.NewReader(`{"enabled":true,"percentage":10,"methods":["/pkg.Service/Method"],"errorCodes":[14]}`)))
+	assert.Equal(t, 200, response.Code)
+	assert.Equal(t, `{"enabled":true,"percentage":10,"methods":["/pkg.Service/Method"],"errorCodes":[14]}`, response.Body.String())
+
+	response = httptest.NewRecorder()
+	findHandler(ctrl.GetHandlers(), "GetChaos").Handler(response, httptest.NewRequest(http.MethodGet, "/admin/chaos", nil))
+	assert.Equal(t, 200, response.Code)
+	assert.Equal(t, `{"enabled":true,"percentage":10,"methods":["/pkg.Service/Method"],"errorCodes":[14]}`, response.Body.String())
+
+	response = httptest.NewRecorder()
+	findHandler(ctrl.GetHandlers(), "SetChaos").Handler(response, httptest.NewRequest(http.MethodPut, "/admin/chaos", strings.NewReader(`{"percentage":200}`)))
+	assert.Equal(t, 400, response.Code)
+	assert.Equal(t, "invalid percentage", response.Body.String())
+	assert.True(t, chaos.config.Enabled)
+
+	response = httptest.NewRecorder()
+	findHandler(ctrl.GetHandlers(), "DisableChaos").Handler(response, httptest.NewRequest(http.MethodDelete, "/admin/chaos", nil))
+	assert.Equal(t, 200, response.Code)
+	assert.False(t, chaos.config.Enabled)
+}
+
+func TestAdminController_getInfoHandler(t *testing.T) {
+	ctrl := AdminController{Info: ServerInfo{
+		Version: "v1.2.3",
+		Services: []ServiceInfo{{
+			Name:       "pkg.Service",
+			Methods:    []string{"/pkg.Service/Method"},
+			Generation: &grpchandler.GenerationInfo{Service: "pkg.Service", PluginVersion: "v1.2.3", ProtoFile: "pkg/service.proto", ProtoFileHash: "abc"},
+		}},
+		Features: []string{"metrics"},
+	}}
+
+	response := httptest.NewRecorder()
+	findHandler(ctrl.GetHandlers(), "GetInfo").Handler(response, httptest.NewRequest(http.MethodGet, "/admin/info", nil))
+	assert.Equal(t, 200, response.Code)
+	assert.Equal(t, `{"version":"v1.2.3","services":[{"name":"pkg.Service","methods":["/pkg.Service/Method"],`+
+		`"generation":{"service":"pkg.Service","pluginVersion":"v1.2.3","protoFile":"pkg/service.proto","protoFileHash":"abc"}}],"features":["metrics"]}`,
+		response.Body.String())
+}
@@ -0,0 +1,67 @@
+package restcontrollers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+)
+
+const (
+	headerCaller = "X-Mock-Caller"
+	headerAPIKey = "X-Api-Key"
+)
+
+type AuditController struct {
+	AuditLog stub.AuditLog
+}
+
+func (c AuditController) GetHandlers() []RESTHandler {
+	return []RESTHandler{
+		{
+			Name:    "GetAuditEntries",
+			Path:    "",
+			Methods: []string{http.MethodGet},
+			Handler: c.getAuditEntriesHandler,
+		},
+	}
+}
+
+func (c AuditController) GetPath() string {
+	return "/audit"
+}
+
+func (c AuditController) getAuditEntriesHandler(writer http.ResponseWriter, request *http.Request) {
+	log.Info("REST: received call to get audit entries")
+
+	writeErr := writeResponse(writer, c.AuditLog.GetEntries(getQueryParam(request, requestParamMethod)))
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
+
+// recordAudit adds the entry to the audit log, when there is one, with the caller of the request.
+func recordAudit(auditLog stub.AuditLog, request *http.Request, entry stub.AuditEntry) {
+	if auditLog == nil {
+		return
+	}
+	entry.Caller = auditCaller(request)
+	auditLog.Record(entry)
+}
+
+// auditCaller identifies who made the request: the X-Mock-Caller header, the user of the basic authentication, a fingerprint
+// of the X-Api-Key header so that the key itself is not exposed, or else the address of the client.
+func auditCaller(request *http.Request) string {
+	if caller := request.Header.Get(headerCaller); caller != "" {
+		return caller
+	}
+	if user, _, ok := request.BasicAuth(); ok && user != "" {
+		return user
+	}
+	if apiKey := request.Header.Get(headerAPIKey); apiKey != "" {
+		hash := sha256.Sum256([]byte(apiKey))
+		return "api-key:" + hex.EncodeToString(hash[:4])
+	}
+	return request.RemoteAddr
+}
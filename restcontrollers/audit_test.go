@@ -0,0 +1,54 @@
+package restcontrollers
+
+import (
+	"encoding/json"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuditController_GetPath(t *testing.T) {
+	assert.Equal(t, "/audit", AuditController{}.GetPath())
+}
+
+func TestAuditController_getAuditEntriesHandler(t *testing.T) {
+	stubsStore := createStubsStoreWithMatches(1)
+	auditLog := stub.NewAuditLog(0)
+	stubsCtrl := StubsController{StubsStore: stubsStore, AuditLog: auditLog}
+	verificationsCtrl := VerificationsController{StubsStore: stubsStore, AuditLog: auditLog}
+
+	request := httptest.NewRequest(http.MethodDelete, "/verifications", nil)
+	request.Header.Set("X-Mock-Caller", "checkout-tests")
+	findHandler(verificationsCtrl.GetHandlers(), "ResetVerifications").Handler(httptest.NewRecorder(), request)
+	request = httptest.NewRequest(http.MethodDelete, "/stubs", nil)
+	request.SetBasicAuth("john", "secret")
+	findHandler(stubsCtrl.GetHandlers(), "DeleteStub").Handler(httptest.NewRecorder(), request)
+
+	response := httptest.NewRecorder()
+	findHandler(AuditController{AuditLog: auditLog}.GetHandlers(), "GetAuditEntries").Handler(response, httptest.NewRequest(http.MethodGet, "/audit", nil))
+	assert.Equal(t, 200, response.Code)
+	entries := make([]stub.AuditEntry, 0)
+	assert.Nil(t, json.Unmarshal(response.Body.Bytes(), &entries))
+	if assert.Equal(t, 2, len(entries)) {
+		assert.Equal(t, stub.AuditResetVerifications, entries[0].Operation)
+		assert.Equal(t, "checkout-tests", entries[0].Caller)
+		assert.Equal(t, stub.AuditDeleteAll, entries[1].Operation)
+		assert.Equal(t, "john", entries[1].Caller)
+		assert.Equal(t, 1, len(entries[1].Before))
+		assert.Equal(t, "method1", entries[1].Before[0].FullMethod)
+	}
+}
+
+func TestAuditCaller(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/audit", nil)
+	request.RemoteAddr = "10.0.0.1:4000"
+	assert.Equal(t, "10.0.0.1:4000", auditCaller(request))
+
+	request.Header.Set("X-Api-Key", "key")
+	assert.Equal(t, "api-key:2c70e12b", auditCaller(request))
+
+	request.Header.Set("X-Mock-Caller", "checkout-tests")
+	assert.Equal(t, "checkout-tests", auditCaller(request))
+}
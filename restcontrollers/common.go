@@ -29,7 +29,6 @@ func getQueryParam(request *http.Request, paramName string) string {
 
 func writeResponse(writer http.ResponseWriter, respponse interface{}) error {
 	return writeResponseWithCode(writer, respponse, http.StatusOK)
-	return nil
 }
 
 func writeResponseWithCode(writer http.ResponseWriter, respponse interface{}, code int) error {
@@ -0,0 +1,47 @@
+package restcontrollers
+
+import (
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"net/http"
+)
+
+// ReadinessController tells that the server is ready, with details of its state. The details do not change the status code
+// so that an alert does not take the server out of service.
+type ReadinessController struct {
+	UnmatchedCalls grpchandler.UnmatchedCallsAlert
+}
+
+type Readiness struct {
+	Status  string           `json:"status"`
+	Details ReadinessDetails `json:"details"`
+}
+
+type ReadinessDetails struct {
+	UnmatchedCalls grpchandler.UnmatchedCallsStatus `json:"unmatchedCalls"`
+}
+
+func (c ReadinessController) GetHandlers() []RESTHandler {
+	return []RESTHandler{
+		{
+			Name:    "GetReadiness",
+			Path:    "",
+			Methods: []string{http.MethodGet},
+			Handler: c.getReadinessHandler,
+		},
+	}
+}
+
+func (c ReadinessController) GetPath() string {
+	return "/readyz"
+}
+
+func (c ReadinessController) getReadinessHandler(writer http.ResponseWriter, request *http.Request) {
+	readiness := Readiness{
+		Status:  "ready",
+		Details: ReadinessDetails{UnmatchedCalls: c.UnmatchedCalls.GetStatus()},
+	}
+	writeErr := writeResponse(writer, readiness)
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
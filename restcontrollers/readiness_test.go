@@ -0,0 +1,36 @@
+package restcontrollers
+
+import (
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeUnmatchedCallsAlert struct {
+	status grpchandler.UnmatchedCallsStatus
+}
+
+func (f fakeUnmatchedCallsAlert) GetStatus() grpchandler.UnmatchedCallsStatus {
+	return f.status
+}
+
+func TestReadinessController_GetPath(t *testing.T) {
+	assert.Equal(t, "/readyz", ReadinessController{}.GetPath())
+}
+
+func TestReadinessController_getReadinessHandler(t *testing.T) {
+	ctrl := ReadinessController{UnmatchedCalls: fakeUnmatchedCallsAlert{status: grpchandler.UnmatchedCallsStatus{
+		Alerting:  true,
+		Count:     11,
+		Threshold: 10,
+		Window:    "1m0s",
+		Methods:   []string{"method1"},
+	}}}
+
+	response := httptest.NewRecorder()
+	findHandler(ctrl.GetHandlers(), "GetReadiness").Handler(response, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, 200, response.Code)
+	assert.Equal(t, `{"status":"ready","details":{"unmatchedCalls":{"alerting":true,"count":11,"threshold":10,"window":"1m0s","methods":["method1"]}}}`, response.Body.String())
+}
@@ -1,9 +1,19 @@
 package restcontrollers
 
 import (
+	"encoding/json"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
 	"github.com/carvalhorr/protoc-gen-mock/stub"
 	log "github.com/sirupsen/logrus"
+	"io/ioutil"
 	"net/http"
+	"strconv"
+)
+
+const (
+	requestParamOffset = "offset"
+	requestParamLimit  = "limit"
 )
 
 type RecordingsController struct {
@@ -18,6 +28,36 @@ func (c RecordingsController) GetHandlers() []RESTHandler {
 			Methods: []string{http.MethodGet},
 			Handler: c.getRecordingsHandler,
 		},
+		{
+			Name:    "GetRecordingsPage",
+			Path:    "/page",
+			Methods: []string{http.MethodGet},
+			Handler: c.getRecordingsPageHandler,
+		},
+		{
+			Name:    "GetRecordingFilter",
+			Path:    "/filter",
+			Methods: []string{http.MethodGet},
+			Handler: c.getRecordingFilterHandler,
+		},
+		{
+			Name:    "SetRecordingFilter",
+			Path:    "/filter",
+			Methods: []string{http.MethodPut},
+			Handler: c.setRecordingFilterHandler,
+		},
+		{
+			Name:    "GetRecordingRedaction",
+			Path:    "/redaction",
+			Methods: []string{http.MethodGet},
+			Handler: c.getRecordingRedactionHandler,
+		},
+		{
+			Name:    "SetRecordingRedaction",
+			Path:    "/redaction",
+			Methods: []string{http.MethodPut},
+			Handler: c.setRecordingRedactionHandler,
+		},
 	}
 }
 
@@ -34,3 +74,109 @@ func (c RecordingsController) getRecordingsHandler(writer http.ResponseWriter, r
 		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
 	}
 }
+
+// getRecordingsPageHandler returns the recordings after the first offset ones, up to limit, in the order they were added,
+// so that the new recordings can be followed without reading all of them again.
+func (c RecordingsController) getRecordingsPageHandler(writer http.ResponseWriter, request *http.Request) {
+	log.Info("REST: received call to get a page of recordings")
+
+	offset, err := getIntQueryParam(request, requestParamOffset)
+	if err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := getIntQueryParam(request, requestParamLimit)
+	if err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeErr := writeResponse(writer, stub.GetRecordingsPage(c.RecordingsStore, offset, limit))
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
+
+// getIntQueryParam returns the value of the query parameter, a positive integer, or zero when it is not set.
+func getIntQueryParam(request *http.Request, paramName string) (int, error) {
+	value := getQueryParam(request, paramName)
+	if value == emptyString {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid %s '%s'", paramName, value)
+	}
+	return i, nil
+}
+
+func (c RecordingsController) getRecordingFilterHandler(writer http.ResponseWriter, request *http.Request) {
+	log.Info("REST: received call to get recording filter")
+
+	filter := grpchandler.GetRecordingFilter()
+	if filter == nil {
+		filter = &stub.RecordingFilter{}
+	}
+	writeErr := writeResponse(writer, filter)
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
+
+func (c RecordingsController) setRecordingFilterHandler(writer http.ResponseWriter, request *http.Request) {
+	bodyData, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, "could not read recording filter in payload")
+		return
+	}
+	defer request.Body.Close()
+	log.WithFields(log.Fields{"filter": string(bodyData)}).
+		Info("REST: received call to set recording filter")
+
+	filter := new(stub.RecordingFilter)
+	if err := json.Unmarshal(bodyData, filter); err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, "could not read recording filter in payload")
+		return
+	}
+	if err := filter.Validate(); err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, fmt.Sprintf("invalid recording filter: %s", err.Error()))
+		return
+	}
+	grpchandler.SetRecordingFilter(filter)
+	writeSuccessResponse(writer)
+}
+
+func (c RecordingsController) getRecordingRedactionHandler(writer http.ResponseWriter, request *http.Request) {
+	log.Info("REST: received call to get recording redaction")
+
+	redaction := grpchandler.GetRecordingRedaction()
+	if redaction == nil {
+		redaction = &stub.RecordingRedaction{}
+	}
+	writeErr := writeResponse(writer, redaction)
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
+
+func (c RecordingsController) setRecordingRedactionHandler(writer http.ResponseWriter, request *http.Request) {
+	bodyData, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, "could not read recording redaction in payload")
+		return
+	}
+	defer request.Body.Close()
+	log.WithFields(log.Fields{"redaction": string(bodyData)}).
+		Info("REST: received call to set recording redaction")
+
+	redaction := new(stub.RecordingRedaction)
+	if err := json.Unmarshal(bodyData, redaction); err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, "could not read recording redaction in payload")
+		return
+	}
+	if err := redaction.Validate(); err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, fmt.Sprintf("invalid recording redaction: %s", err.Error()))
+		return
+	}
+	grpchandler.SetRecordingRedaction(redaction)
+	writeSuccessResponse(writer)
+}
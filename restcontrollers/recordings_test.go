@@ -0,0 +1,43 @@
+package restcontrollers
+
+import (
+	"encoding/json"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecordingsController_getRecordingsPageHandler(t *testing.T) {
+	store := stub.NewRecordingsStore()
+	for _, name := range []string{"c", "a", "b"} {
+		assert.Nil(t, store.Add(&stub.Stub{FullMethod: "/pkg.Service/Method", Type: "mock",
+			Request:  &stub.StubRequest{Match: "exact", Content: stub.JsonString(`{"name":"` + name + `"}`)},
+			Response: &stub.StubResponse{Type: "success", Content: `{}`}}))
+	}
+	ctrl := RecordingsController{RecordingsStore: store}
+	getPage := func(target string) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		findHandler(ctrl.GetHandlers(), "GetRecordingsPage").Handler(response, httptest.NewRequest(http.MethodGet, target, nil))
+		return response
+	}
+
+	response := getPage("/recordings/page?offset=1&limit=1")
+	assert.Equal(t, 200, response.Code)
+	page := stub.RecordingsPage{}
+	assert.Nil(t, json.Unmarshal(response.Body.Bytes(), &page))
+	assert.Equal(t, 3, page.Total)
+	if assert.Equal(t, 1, len(page.Recordings)) {
+		assert.Equal(t, stub.JsonString(`{"name":"a"}`), page.Recordings[0].Request.Content)
+	}
+
+	page = stub.RecordingsPage{}
+	assert.Nil(t, json.Unmarshal(getPage("/recordings/page").Body.Bytes(), &page))
+	assert.Equal(t, 3, len(page.Recordings))
+
+	response = getPage("/recordings/page?offset=-1")
+	assert.Equal(t, 400, response.Code)
+	assert.Contains(t, response.Body.String(), "invalid offset '-1'")
+	assert.Equal(t, 400, getPage("/recordings/page?limit=x").Code)
+}
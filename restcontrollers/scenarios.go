@@ -0,0 +1,79 @@
+package restcontrollers
+
+import (
+	"encoding/json"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+)
+
+// ScenariosController shows and changes the states of the scenarios of the templates of the stubs, e.g. to start a test
+// from a step of a flow. The states are reset with the verifications.
+type ScenariosController struct {
+	StubsStore stub.StubsStore
+}
+
+// Scenario is the body of the requests and responses of the endpoint changing the state of a scenario.
+type Scenario struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+func (c ScenariosController) GetHandlers() []RESTHandler {
+	return []RESTHandler{
+		{
+			Name:    "GetScenarios",
+			Path:    "",
+			Methods: []string{http.MethodGet},
+			Handler: c.getScenariosHandler,
+		},
+		{
+			Name:    "SetScenario",
+			Path:    "/{name}",
+			Methods: []string{http.MethodPut},
+			Handler: c.setScenarioHandler,
+		},
+	}
+}
+
+func (c ScenariosController) GetPath() string {
+	return "/scenarios"
+}
+
+func (c ScenariosController) getScenariosHandler(writer http.ResponseWriter, request *http.Request) {
+	log.Info("REST: received call to get scenarios")
+
+	writeErr := writeResponse(writer, stub.Scenarios(c.StubsStore))
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
+
+func (c ScenariosController) setScenarioHandler(writer http.ResponseWriter, request *http.Request) {
+	bodyData, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, "could not read scenario in payload")
+		return
+	}
+	defer request.Body.Close()
+
+	scenario := Scenario{}
+	if err := json.Unmarshal(bodyData, &scenario); err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, "could not read scenario in payload")
+		return
+	}
+	scenario.Name = mux.Vars(request)["name"]
+	if scenario.State == emptyString {
+		writeErrorResponse(writer, http.StatusBadRequest, "the state of the scenario is required")
+		return
+	}
+	log.Infof("REST: scenario %s changed to %s", scenario.Name, scenario.State)
+	stub.SetScenario(c.StubsStore, scenario.Name, scenario.State)
+
+	writeErr := writeResponse(writer, scenario)
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
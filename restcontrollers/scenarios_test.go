@@ -0,0 +1,40 @@
+package restcontrollers
+
+import (
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestScenariosController_GetPath(t *testing.T) {
+	assert.Equal(t, "/scenarios", ScenariosController{}.GetPath())
+}
+
+func TestScenariosController_setScenarioHandler(t *testing.T) {
+	store := stub.NewInMemoryStubsStore()
+	ctrl := ScenariosController{StubsStore: store}
+	setScenario := func(body string) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		request := mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/scenarios/checkout", strings.NewReader(body)), map[string]string{"name": "checkout"})
+		findHandler(ctrl.GetHandlers(), "SetScenario").Handler(response, request)
+		return response
+	}
+
+	response := setScenario(`{"state":"paid"}`)
+	assert.Equal(t, 200, response.Code)
+	assert.Equal(t, `{"name":"checkout","state":"paid"}`, response.Body.String())
+	assert.Equal(t, map[string]string{"checkout": "paid"}, stub.Scenarios(store))
+
+	response = httptest.NewRecorder()
+	findHandler(ctrl.GetHandlers(), "GetScenarios").Handler(response, httptest.NewRequest(http.MethodGet, "/scenarios", nil))
+	assert.Equal(t, 200, response.Code)
+	assert.Equal(t, `{"checkout":"paid"}`, response.Body.String())
+
+	assert.Equal(t, 400, setScenario(`{}`).Code)
+	assert.Equal(t, 400, setScenario(`{"state":`).Code)
+	assert.Equal(t, map[string]string{"checkout": "paid"}, stub.Scenarios(store))
+}
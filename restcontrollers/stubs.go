@@ -12,6 +12,8 @@ import (
 	"google.golang.org/protobuf/proto"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -25,6 +27,11 @@ type StubsController struct {
 	StubsStore   stub.StubsStore
 	StubExamples []stub.Stub
 	Service      grpchandler.MockService
+	// Optional. Records the changes made to the stubs.
+	AuditLog stub.AuditLog
+	// Reject the stubs shadowing or shadowed by another stub, see stub.FindShadowing. They are only reported in the
+	// Warning header of the response otherwise.
+	RejectShadowedStubs bool
 }
 
 func (c StubsController) GetHandlers() []RESTHandler {
@@ -69,7 +76,7 @@ func (c StubsController) getStubsHandler(writer http.ResponseWriter, request *ht
 		return
 	}
 
-	stubs := c.getStubsFromStore(method)
+	stubs := stubFilter(request).Filter(c.getStubsFromStore(method))
 	writeErr := writeResponse(writer, stubs)
 	if writeErr != nil {
 		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
@@ -99,24 +106,36 @@ func (c StubsController) addStubsHandler(writer http.ResponseWriter, request *ht
 		return
 	}
 
+	if !c.checkShadowing(writer, s) {
+		return
+	}
+
 	addErr := c.StubsStore.Add(s)
 	if addErr != nil {
 		log.Errorf("Failed to add stub %s -> %s. Error %s", s.FullMethod, s.Request.String(), addErr.Error())
 		writeErrorResponse(writer, http.StatusInternalServerError, "Failed to add stub.")
 		return
 	}
+	recordAudit(c.AuditLog, request, stub.AuditEntry{Operation: stub.AuditCreate, Method: s.FullMethod, After: []*stub.Stub{s}})
 	writeSuccessResponse(writer)
 }
 
 // 1. Make sure the request and response can be marshalled to the respective proto.Messages by unmarshalling it to the respective type
 // 2. Marshal it back to JSON to remove extra spaces or formatting so that we can use this cleaned up JSON for comparison to check if the stub already exists
 func (c StubsController) cleanRequestResponse(s *stub.Stub) error {
-	marshaledRequest, errReqClean := cleanJson(s.Request.Content, c.Service.GetRequestInstance(s.FullMethod))
+	requestInstance := c.Service.GetRequestInstance(s.FullMethod)
+	marshaledRequest, errReqClean := cleanJson(s.Request.Content, requestInstance)
 	if errReqClean != nil {
 		return errReqClean
 	}
+	if s.Request.EmptyArrays == "empty" {
+		if marshaledRequest, errReqClean = stub.RestoreEmptyArrays(s.Request.Content, marshaledRequest, requestInstance.(proto.Message).ProtoReflect().Descriptor()); errReqClean != nil {
+			return errReqClean
+		}
+	}
 	s.Request.Content = marshaledRequest
-	if s.Type == "mock" {
+	// the content with templates is only valid once rendered for a call
+	if s.Type == "mock" && !s.Response.Content.HasTemplates() {
 		marshalledResponse, errRespClean := cleanJson(s.Response.Content, c.Service.GetResponseInstance(s.FullMethod))
 		if errRespClean != nil {
 			return errRespClean
@@ -164,12 +183,18 @@ func (c StubsController) updateStubsHandler(writer http.ResponseWriter, request
 		return
 	}
 
+	if !c.checkShadowing(writer, s) {
+		return
+	}
+
+	before := c.StubsStore.GetStubsMapForMethod(s.FullMethod)[s.Request.String()]
 	updateErr := c.StubsStore.Update(s)
 	if updateErr != nil {
 		log.Errorf("Failed to update stub %s -> %s. Error %s", s.FullMethod, s.Request.String(), updateErr.Error())
 		writeErrorResponse(writer, http.StatusInternalServerError, "Failed to update stub.")
 		return
 	}
+	recordAudit(c.AuditLog, request, stub.AuditEntry{Operation: stub.AuditUpdate, Method: s.FullMethod, Before: []*stub.Stub{before}, After: []*stub.Stub{s}})
 	writeSuccessResponse(writer)
 }
 
@@ -179,34 +204,41 @@ func (c StubsController) deleteStubsHandler(writer http.ResponseWriter, request
 		writeErrorResponse(writer, http.StatusBadRequest, fmt.Sprintf("Can't delete stubs. Unsupported method: %s", method))
 	}
 
-	stub, err := readStubFromRequestBody(request)
+	s, err := readStubFromRequestBody(request)
 	if err != nil {
 		writeErrorResponse(writer, http.StatusBadRequest, fmt.Sprintf("call to delete stub failed with error: %s", err.Error()))
 		return
 	}
-	log.WithFields(log.Fields{"stub": toJSON(stub), "method": method}).
+	log.WithFields(log.Fields{"stub": toJSON(s), "method": method}).
 		Info("REST: received call to delete stubs")
 
 	switch {
 	case method != emptyString:
+		deleted := c.StubsStore.GetStubsForMethod(method)
 		c.StubsStore.DeleteAllForMethod(method)
-	case stub != nil:
-		if !c.isMethodSupported(stub.FullMethod) {
-			writeErrorResponse(writer, http.StatusBadRequest, fmt.Sprintf("Method %s is not supported", stub.FullMethod))
+		recordAudit(c.AuditLog, request, stub.AuditEntry{Operation: stub.AuditDelete, Method: method, Before: deleted})
+	case s != nil:
+		if !c.isMethodSupported(s.FullMethod) {
+			writeErrorResponse(writer, http.StatusBadRequest, fmt.Sprintf("Method %s is not supported", s.FullMethod))
 			return
 		}
 
-		if !c.StubsStore.Exists(stub) {
+		if !c.StubsStore.Exists(s) {
 			writeErrorResponse(writer, http.StatusNotFound, "Stub not found")
 			return
 		}
-		deleteErr := c.StubsStore.Delete(stub)
+		deleted := c.StubsStore.GetStubsMapForMethod(s.FullMethod)[s.Request.String()]
+		deleteErr := c.StubsStore.Delete(s)
 		if deleteErr != nil {
-			log.Errorf("Failed to delete stub %s -> %s. Error %s", stub.FullMethod, stub.Request.String(), deleteErr.Error())
+			log.Errorf("Failed to delete stub %s -> %s. Error %s", s.FullMethod, s.Request.String(), deleteErr.Error())
 			writeErrorResponse(writer, http.StatusInternalServerError, "Failed to delete stub.")
+			return
 		}
+		recordAudit(c.AuditLog, request, stub.AuditEntry{Operation: stub.AuditDelete, Method: s.FullMethod, Before: []*stub.Stub{deleted}})
 	default:
+		deleted := c.StubsStore.GetAllStubs()
 		c.StubsStore.DeleteAll()
+		recordAudit(c.AuditLog, request, stub.AuditEntry{Operation: stub.AuditDeleteAll, Before: deleted})
 	}
 
 	writeSuccessResponse(writer)
@@ -229,6 +261,11 @@ func (c StubsController) getStubsFromStore(method string) []*stub.Stub {
 	return c.StubsStore.GetStubsForMethod(method)
 }
 
+// stubFilter returns the filter of the stubs by the ?tag= parameters, all required, and the text of the ?q= parameter.
+func stubFilter(request *http.Request) stub.StubFilter {
+	return stub.StubFilter{Tags: request.URL.Query()["tag"], Query: getQueryParam(request, "q")}
+}
+
 func (c StubsController) isStubValid(s *stub.Stub) (isValid bool, errorMessages []string) {
 	if isValid, errorMessages := c.Service.GetStubsValidator().IsValid(s); !isValid {
 		return isValid, errorMessages
@@ -252,7 +289,7 @@ func readStubFromRequestBody(request *http.Request) (*stub.Stub, error) {
 	unmarshalErr := json.Unmarshal(bodyData, stub)
 	if unmarshalErr != nil {
 		log.Errorf("Unexpected error while reading stub from the request. Error %s", unmarshalErr.Error())
-		return nil, fmt.Errorf("could not read stubs in payload")
+		return nil, fmt.Errorf("could not read stubs in payload: %v", unmarshalErr)
 	}
 
 	return stub, nil
@@ -263,6 +300,24 @@ func toJSON(p interface{}) string {
 	return string(str)
 }
 
+// checkShadowing rejects the stub when it shadows or is shadowed by another stub and RejectShadowedStubs is set, or
+// else adds the conflicts to the Warning header of the response.
+func (c StubsController) checkShadowing(writer http.ResponseWriter, s *stub.Stub) bool {
+	warnings := stub.FindShadowing(c.StubsStore, s)
+	if len(warnings) == 0 {
+		return true
+	}
+	if c.RejectShadowedStubs {
+		writeErrorResponse(writer, http.StatusConflict, fmt.Sprintf("Stub shadowed: %s", strings.Join(warnings, "; ")))
+		return false
+	}
+	for _, warning := range warnings {
+		log.Warnf("Stub %s -> %s: %s", s.FullMethod, s.Request.String(), warning)
+		writer.Header().Add("Warning", "299 - "+strconv.Quote(warning))
+	}
+	return true
+}
+
 func (c StubsController) isValid(writer http.ResponseWriter, s *stub.Stub) bool {
 	isValid, errorMessages := c.isStubValid(s)
 	if !isValid {
@@ -276,12 +331,13 @@ func (c StubsController) isValid(writer http.ResponseWriter, s *stub.Stub) bool
 
 	errCleaning := c.cleanRequestResponse(s)
 	if errCleaning != nil {
-		log.Errorf("Error validating request / response", errCleaning)
+		log.Errorf("Error validating request / response: %s", errCleaning)
 		writeErrorResponse(writer, http.StatusInternalServerError, "Failed to update stub.")
 		return false
 	}
 
-	if s.Type != "mock" {
+	// the custom and remote responses are only created for the calls
+	if s.Type != "mock" || s.Response.Type == "custom" || s.Response.Type == "remote" {
 		return true
 	}
 	instance, createResponseErr := stub.GetResponse(s, string(s.Request.Content), c.Service.GetResponseInstance(s.FullMethod))
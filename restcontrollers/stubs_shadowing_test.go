@@ -0,0 +1,71 @@
+package restcontrollers
+
+import (
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeStringService struct {
+	grpchandler.MockService
+}
+
+func (fakeStringService) GetSupportedMethods() []string {
+	return []string{"/pkg.Service/Method"}
+}
+
+func (fakeStringService) GetRequestInstance(methodName string) proto.Message {
+	return new(wrapperspb.StringValue)
+}
+
+func (fakeStringService) GetResponseInstance(methodName string) proto.Message {
+	return new(wrapperspb.StringValue)
+}
+
+func (s fakeStringService) GetStubsValidator() stub.StubsValidator {
+	return s
+}
+
+func (fakeStringService) IsValid(s *stub.Stub) (bool, []string) {
+	return s.IsValid()
+}
+
+func addTestStub(ctrl StubsController, body string) *httptest.ResponseRecorder {
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/stubs", strings.NewReader(body))
+	findHandler(ctrl.GetHandlers(), "AddStub").Handler(response, request)
+	return response
+}
+
+const (
+	generalStub  = `{"fullMethod": "/pkg.Service/Method", "type": "mock", "request": {"match": "exact", "content": "Mary"}, "response": {"type": "success", "content": "Hello"}}`
+	shadowedStub = `{"fullMethod": "/pkg.Service/Method", "type": "mock", "request": {"match": "exact", "content": "Mary", "metadata": {"tenant": ["a"]}}, "response": {"type": "success", "content": "Hello tenant"}}`
+)
+
+func TestStubsController_addStubHandler_Shadowed(t *testing.T) {
+	stubsStore := stub.NewInMemoryStubsStore()
+	ctrl := StubsController{StubsStore: stubsStore, Service: fakeStringService{}}
+
+	assert.Equal(t, http.StatusOK, addTestStub(ctrl, generalStub).Code)
+	response := addTestStub(ctrl, shadowedStub)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, []string{`299 - "the stub is never matched: the stub /pkg.Service/Method -> \"Mary\" matches all its calls first"`}, response.Header().Values("Warning"))
+	assert.Equal(t, 2, len(stubsStore.GetAllStubs()))
+}
+
+func TestStubsController_addStubHandler_RejectShadowed(t *testing.T) {
+	stubsStore := stub.NewInMemoryStubsStore()
+	ctrl := StubsController{StubsStore: stubsStore, Service: fakeStringService{}, RejectShadowedStubs: true}
+
+	assert.Equal(t, http.StatusOK, addTestStub(ctrl, generalStub).Code)
+	response := addTestStub(ctrl, shadowedStub)
+	assert.Equal(t, http.StatusConflict, response.Code)
+	assert.Equal(t, `Stub shadowed: the stub is never matched: the stub /pkg.Service/Method -> "Mary" matches all its calls first`, response.Body.String())
+	assert.Equal(t, 1, len(stubsStore.GetAllStubs()))
+}
@@ -0,0 +1,353 @@
+package restcontrollers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TranscodingController serves the HTTP/JSON endpoints defined by the google.api.http annotations of the mock services.
+// The requests are transcoded to the mocked gRPC methods, so they are answered by the same stubs.
+type TranscodingController struct {
+	StubsMatcher stub.StubsMatcher
+	Service      grpchandler.MockService
+}
+
+func (c TranscodingController) GetHandlers() []RESTHandler {
+	handlers := make([]RESTHandler, 0)
+	for _, rule := range grpchandler.GetHTTPRules(c.Service) {
+		path, fieldPaths, err := convertPathTemplate(rule.Path)
+		if err != nil {
+			log.Errorf("Ignoring HTTP rule %s %s for %s. Error: %s", rule.Method, rule.Path, rule.FullMethod, err)
+			continue
+		}
+		handlers = append(handlers, RESTHandler{
+			Name:    rule.FullMethod,
+			Path:    path,
+			Methods: []string{rule.Method},
+			Handler: c.createTranscodingHandler(rule, fieldPaths),
+		})
+	}
+	return handlers
+}
+
+func (c TranscodingController) GetPath() string {
+	return ""
+}
+
+func (c TranscodingController) createTranscodingHandler(rule grpchandler.HTTPRule, fieldPaths map[string]string) func(writer http.ResponseWriter, request *http.Request) {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		log.Infof("REST: received call to %s %s transcoded to %s", request.Method, request.URL.Path, rule.FullMethod)
+
+		req, err := c.createRequest(rule, fieldPaths, request)
+		if err != nil {
+			writeStatusResponse(writer, status.New(codes.InvalidArgument, err.Error()))
+			return
+		}
+		md := metadata.MD{}
+		for key, values := range request.Header {
+			md.Append(strings.ToLower(key), values...)
+		}
+		ctx := metadata.NewIncomingContext(request.Context(), md)
+		resp, err := grpchandler.MockHandler(ctx, c.StubsMatcher, rule.FullMethod, req, c.Service.GetResponseInstance(rule.FullMethod))
+		if err != nil {
+			writeStatusResponse(writer, status.Convert(err))
+			return
+		}
+		body, err := getResponseBody(rule, resp.(proto.Message))
+		if err != nil {
+			writeStatusResponse(writer, status.New(codes.Internal, err.Error()))
+			return
+		}
+		writer.Header().Add(contentType, contentTypeApplicationJson)
+		writer.WriteHeader(http.StatusOK)
+		if _, writeErr := writer.Write(body); writeErr != nil {
+			log.Errorf("Error writing http response: Error %s", writeErr.Error())
+		}
+	}
+}
+
+// createRequest creates the gRPC request from the HTTP body, the path variables and the query parameters.
+func (c TranscodingController) createRequest(rule grpchandler.HTTPRule, fieldPaths map[string]string, request *http.Request) (proto.Message, error) {
+	req := c.Service.GetRequestInstance(rule.FullMethod).(proto.Message)
+	if rule.Body != "" {
+		body, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the request body")
+		}
+		defer request.Body.Close()
+		if len(body) > 0 {
+			if rule.Body != "*" {
+				body = []byte(fmt.Sprintf("{%q:%s}", rule.Body, body))
+			}
+			if err := protojson.Unmarshal(body, req); err != nil {
+				return nil, fmt.Errorf("could not read the request body: %s", err)
+			}
+		}
+	}
+	setFields := make(map[string]bool, 0)
+	for variable, value := range mux.Vars(request) {
+		fieldPath, ok := fieldPaths[variable]
+		if !ok {
+			continue
+		}
+		if err := setField(req.ProtoReflect(), strings.Split(fieldPath, "."), []string{value}); err != nil {
+			return nil, err
+		}
+		setFields[fieldPath] = true
+	}
+	if rule.Body == "*" {
+		return req, nil
+	}
+	for fieldPath, values := range request.URL.Query() {
+		if setFields[fieldPath] || (rule.Body != "" && strings.Split(fieldPath, ".")[0] == rule.Body) {
+			continue
+		}
+		if err := setField(req.ProtoReflect(), strings.Split(fieldPath, "."), values); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+func setField(message protoreflect.Message, path []string, values []string) error {
+	field := findField(message.Descriptor(), path[0])
+	if field == nil {
+		return fmt.Errorf("unknown field '%s' in %s", path[0], message.Descriptor().FullName())
+	}
+	if len(path) > 1 {
+		if field.Kind() != protoreflect.MessageKind || field.IsList() || field.IsMap() {
+			return fmt.Errorf("field '%s' in %s is not a message", path[0], message.Descriptor().FullName())
+		}
+		return setField(message.Mutable(field).Message(), path[1:], values)
+	}
+	if field.IsMap() || (field.Kind() == protoreflect.MessageKind || field.Kind() == protoreflect.GroupKind) {
+		return fmt.Errorf("field '%s' in %s can't be set from a parameter", path[0], message.Descriptor().FullName())
+	}
+	if field.IsList() {
+		list := message.Mutable(field).List()
+		for _, value := range values {
+			v, err := parseFieldValue(field, value)
+			if err != nil {
+				return err
+			}
+			list.Append(v)
+		}
+		return nil
+	}
+	v, err := parseFieldValue(field, values[len(values)-1])
+	if err != nil {
+		return err
+	}
+	message.Set(field, v)
+	return nil
+}
+
+// findField finds the field by its proto or JSON name.
+func findField(descriptor protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if field := descriptor.Fields().ByName(protoreflect.Name(name)); field != nil {
+		return field
+	}
+	return descriptor.Fields().ByJSONName(name)
+}
+
+func parseFieldValue(field protoreflect.FieldDescriptor, value string) (protoreflect.Value, error) {
+	invalid := fmt.Errorf("invalid value '%s' for field '%s'", value, field.Name())
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.BytesKind:
+		// base64 with the standard or the URL alphabet, with or without padding
+		unpadded := strings.TrimRight(value, "=")
+		data, err := base64.RawStdEncoding.DecodeString(unpadded)
+		if err != nil {
+			if data, err = base64.RawURLEncoding.DecodeString(unpadded); err != nil {
+				return protoreflect.Value{}, invalid
+			}
+		}
+		return protoreflect.ValueOfBytes(data), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfBool(b), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		i, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfInt32(int32(i)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfInt64(i), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		i, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfUint32(uint32(i)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		i, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfUint64(i), nil
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfFloat32(float32(f)), nil
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+	case protoreflect.EnumKind:
+		if enumValue := field.Enum().Values().ByName(protoreflect.Name(value)); enumValue != nil {
+			return protoreflect.ValueOfEnum(enumValue.Number()), nil
+		}
+		i, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(i)), nil
+	}
+	return protoreflect.Value{}, invalid
+}
+
+func getResponseBody(rule grpchandler.HTTPRule, resp proto.Message) ([]byte, error) {
+	if rule.ResponseBody == "" {
+		return protojson.Marshal(resp)
+	}
+	data, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage, 0)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	field := findField(resp.ProtoReflect().Descriptor(), rule.ResponseBody)
+	if field == nil {
+		return nil, fmt.Errorf("unknown response field '%s'", rule.ResponseBody)
+	}
+	return fields[field.JSONName()], nil
+}
+
+// writeStatusResponse writes the gRPC status as JSON using the HTTP status code corresponding to the gRPC code.
+func writeStatusResponse(writer http.ResponseWriter, st *status.Status) {
+	data, err := protojson.Marshal(st.Proto())
+	if err != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, st.Message())
+		return
+	}
+	writer.Header().Add(contentType, contentTypeApplicationJson)
+	writer.WriteHeader(httpStatusFromCode(st.Code()))
+	if _, writeErr := writer.Write(data); writeErr != nil {
+		log.Errorf("Error writing http response: Error %s", writeErr.Error())
+	}
+}
+
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+var pathTemplateVariable = regexp.MustCompile(`\{([^}=]+)(=([^}]*))?\}`)
+
+// convertPathTemplate converts a google.api.http path template to a gorilla/mux path.
+// The variables are renamed since mux doesn't allow dots in the names, so the field path of each variable is returned.
+// e.g. /v1/{name=shelves/*}/books/{book.id} -> /v1/{v0:shelves/[^/]+}/books/{v1:[^/]+}
+func convertPathTemplate(template string) (path string, fieldPaths map[string]string, err error) {
+	if !strings.HasPrefix(template, "/") {
+		return "", nil, fmt.Errorf("path template must start with '/'")
+	}
+	fieldPaths = make(map[string]string, 0)
+	wildcards := 0
+	var builder strings.Builder
+	last := 0
+	for _, match := range pathTemplateVariable.FindAllStringSubmatchIndex(template, -1) {
+		builder.WriteString(convertLiteralSegments(template[last:match[0]], &wildcards))
+		name := fmt.Sprintf("v%d", len(fieldPaths))
+		fieldPaths[name] = template[match[2]:match[3]]
+		pattern := "[^/]+"
+		if match[6] >= 0 {
+			pattern = segmentsToRegexp(template[match[6]:match[7]])
+		}
+		builder.WriteString("{" + name + ":" + pattern + "}")
+		last = match[1]
+	}
+	builder.WriteString(convertLiteralSegments(template[last:], &wildcards))
+	return builder.String(), fieldPaths, nil
+}
+
+// convertLiteralSegments converts the wildcards outside variables to unnamed mux variables.
+func convertLiteralSegments(segments string, wildcards *int) string {
+	parts := strings.Split(segments, "/")
+	for i, part := range parts {
+		if part == "*" || part == "**" {
+			parts[i] = fmt.Sprintf("{w%d:%s}", *wildcards, segmentsToRegexp(part))
+			*wildcards++
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func segmentsToRegexp(segments string) string {
+	parts := strings.Split(segments, "/")
+	for i, part := range parts {
+		switch part {
+		case "*":
+			parts[i] = "[^/]+"
+		case "**":
+			parts[i] = ".+"
+		default:
+			parts[i] = regexp.QuoteMeta(part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,280 @@
+package restcontrollers
+
+import (
+	"context"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/golang/protobuf/proto"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	_ "google.golang.org/protobuf/types/known/typepb"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestConvertPathTemplate(t *testing.T) {
+	tests := []struct {
+		template   string
+		path       string
+		fieldPaths map[string]string
+	}{
+		{"/v1/hello", "/v1/hello", map[string]string{}},
+		{"/v1/hello/{name}", "/v1/hello/{v0:[^/]+}", map[string]string{"v0": "name"}},
+		{"/v1/{name=shelves/*}/books/{book.id}", "/v1/{v0:shelves/[^/]+}/books/{v1:[^/]+}", map[string]string{"v0": "name", "v1": "book.id"}},
+		{"/v1/{name=files/**}:download", "/v1/{v0:files/.+}:download", map[string]string{"v0": "name"}},
+		{"/v1/*/items", "/v1/{w0:[^/]+}/items", map[string]string{}},
+	}
+	for _, test := range tests {
+		path, fieldPaths, err := convertPathTemplate(test.template)
+		assert.Nil(t, err, test.template)
+		assert.Equal(t, test.path, path, test.template)
+		assert.Equal(t, test.fieldPaths, fieldPaths, test.template)
+	}
+}
+
+func TestConvertPathTemplate_Invalid(t *testing.T) {
+	_, _, err := convertPathTemplate("v1/hello")
+	assert.EqualError(t, err, "path template must start with '/'")
+}
+
+func TestHttpStatusFromCode(t *testing.T) {
+	assert.Equal(t, http.StatusOK, httpStatusFromCode(codes.OK))
+	assert.Equal(t, http.StatusNotFound, httpStatusFromCode(codes.NotFound))
+	assert.Equal(t, http.StatusBadRequest, httpStatusFromCode(codes.InvalidArgument))
+	assert.Equal(t, http.StatusInternalServerError, httpStatusFromCode(codes.Unknown))
+}
+
+const transcodingMethod = "/pkg.transcoding.Service/Method"
+
+var (
+	transcodingFileOnce sync.Once
+	transcodingFile     protoreflect.FileDescriptor
+)
+
+// transcodingMessages returns the request and the response of transcodingMethod, with nested, repeated, enum and bytes
+// fields set from the HTTP requests.
+func transcodingMessages(t *testing.T) (request, response protoreflect.MessageDescriptor) {
+	transcodingFileOnce.Do(func() {
+		field := func(name string, number int32, fieldType descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+			return &descriptorpb.FieldDescriptorProto{Name: proto.String(name), JsonName: proto.String(name), Number: proto.Int32(number),
+				Type: fieldType.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()}
+		}
+		repeated := func(field *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+			field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+			return field
+		}
+		filter := field("filter", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+		filter.TypeName = proto.String(".pkg.transcoding.Filter")
+		syntax := field("syntax", 4, descriptorpb.FieldDescriptorProto_TYPE_ENUM)
+		syntax.TypeName = proto.String(".google.protobuf.Syntax")
+		responseFilter := field("filter", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+		responseFilter.TypeName = proto.String(".pkg.transcoding.Filter")
+		file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+			Name:       proto.String("transcoding.proto"),
+			Package:    proto.String("pkg.transcoding"),
+			Syntax:     proto.String("proto3"),
+			Dependency: []string{"google/protobuf/type.proto"},
+			MessageType: []*descriptorpb.DescriptorProto{
+				{
+					Name: proto.String("Filter"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("status", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+						repeated(field("tags", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING)),
+					},
+				},
+				{
+					Name: proto.String("Request"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING), filter,
+						repeated(field("ids", 3, descriptorpb.FieldDescriptorProto_TYPE_INT64)), syntax,
+						field("data", 5, descriptorpb.FieldDescriptorProto_TYPE_BYTES),
+					},
+				},
+				{
+					Name:  proto.String("Response"),
+					Field: []*descriptorpb.FieldDescriptorProto{responseFilter, field("id", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING)},
+				},
+			},
+		}, protoregistry.GlobalFiles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		transcodingFile = file
+	})
+	return transcodingFile.Messages().ByName("Request"), transcodingFile.Messages().ByName("Response")
+}
+
+type fakeTranscodingService struct {
+	grpchandler.MockService
+	request, response protoreflect.MessageDescriptor
+	rules             []grpchandler.HTTPRule
+}
+
+func (s fakeTranscodingService) GetRequestInstance(methodName string) proto.Message {
+	return dynamicpb.NewMessage(s.request)
+}
+
+func (s fakeTranscodingService) GetResponseInstance(methodName string) proto.Message {
+	return dynamicpb.NewMessage(s.response)
+}
+
+func (s fakeTranscodingService) GetHTTPRules() []grpchandler.HTTPRule {
+	return s.rules
+}
+
+// fakeRequestsMatcher records the requests of the calls and matches them to its stub.
+type fakeRequestsMatcher struct {
+	stub     *stub.Stub
+	requests []string
+}
+
+func (m *fakeRequestsMatcher) Match(ctx context.Context, fullMethod, requestJson string) *stub.Stub {
+	m.requests = append(m.requests, requestJson)
+	return m.stub
+}
+
+// serveTranscoding serves the request with the handlers of the controller, routed as by the REST server.
+func serveTranscoding(ctrl TranscodingController, request *http.Request) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	for _, handler := range ctrl.GetHandlers() {
+		router.HandleFunc(handler.Path, handler.Handler).Methods(handler.Methods...)
+	}
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func TestTranscodingController_createRequest(t *testing.T) {
+	requestDescriptor, responseDescriptor := transcodingMessages(t)
+	matcher := &fakeRequestsMatcher{stub: &stub.Stub{FullMethod: transcodingMethod, Type: "mock",
+		Response: &stub.StubResponse{Type: "success", Content: `{"id":"1"}`}}}
+	ctrl := TranscodingController{StubsMatcher: matcher, Service: fakeTranscodingService{
+		request:  requestDescriptor,
+		response: responseDescriptor,
+		rules: []grpchandler.HTTPRule{
+			{FullMethod: transcodingMethod, Method: http.MethodPost, Path: "/v1/{name=requests/*}", Body: "*"},
+			{FullMethod: transcodingMethod, Method: http.MethodPatch, Path: "/v1/{name=requests/*}/filter", Body: "filter"},
+			{FullMethod: transcodingMethod, Method: http.MethodGet, Path: "/v1/statuses/{filter.status}"},
+		},
+	}}
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		expected string
+	}{
+		{
+			name:     "the whole request in the body, without the query parameters",
+			method:   http.MethodPost,
+			target:   "/v1/requests/1?filter.status=ignored",
+			body:     `{"name":"ignored","ids":["1"],"filter":{"status":"active"}}`,
+			expected: `{"name":"requests/1","ids":["1"],"filter":{"status":"active"}}`,
+		},
+		{
+			name:     "a field in the body, without the query parameters of the field",
+			method:   http.MethodPatch,
+			target:   "/v1/requests/1/filter?filter.tags=ignored&ids=1&ids=2",
+			body:     `{"status":"active"}`,
+			expected: `{"name":"requests/1","ids":["1","2"],"filter":{"status":"active"}}`,
+		},
+		{
+			name:     "a path variable in a nested field and repeated, enum and bytes query parameters",
+			method:   http.MethodGet,
+			target:   "/v1/statuses/active?filter.tags=a&filter.tags=b&ids=3&syntax=SYNTAX_PROTO3&data=aGk%3D",
+			expected: `{"filter":{"status":"active","tags":["a","b"]},"ids":["3"],"syntax":"SYNTAX_PROTO3","data":"aGk="}`,
+		},
+		{
+			name:     "an enum by number and unpadded bytes with the URL alphabet",
+			method:   http.MethodGet,
+			target:   "/v1/statuses/active?syntax=1&data=-_8",
+			expected: `{"filter":{"status":"active"},"syntax":"SYNTAX_PROTO3","data":"+/8="}`,
+		},
+	}
+	for _, test := range tests {
+		matcher.requests = nil
+		response := serveTranscoding(ctrl, httptest.NewRequest(test.method, test.target, strings.NewReader(test.body)))
+		assert.Equal(t, http.StatusOK, response.Code, test.name)
+		assert.JSONEq(t, `{"id":"1"}`, response.Body.String(), test.name)
+		if assert.Equal(t, 1, len(matcher.requests), test.name) {
+			assert.JSONEq(t, test.expected, matcher.requests[0], test.name)
+		}
+	}
+}
+
+func TestTranscodingController_createRequest_Invalid(t *testing.T) {
+	requestDescriptor, responseDescriptor := transcodingMessages(t)
+	matcher := &fakeRequestsMatcher{}
+	ctrl := TranscodingController{StubsMatcher: matcher, Service: fakeTranscodingService{
+		request:  requestDescriptor,
+		response: responseDescriptor,
+		rules: []grpchandler.HTTPRule{
+			{FullMethod: transcodingMethod, Method: http.MethodGet, Path: "/v1/statuses/{filter.status}"},
+			{FullMethod: transcodingMethod, Method: http.MethodPost, Path: "/v1/requests", Body: "*"},
+		},
+	}}
+	tests := []struct {
+		method  string
+		target  string
+		body    string
+		message string
+	}{
+		{http.MethodGet, "/v1/statuses/active?ids=x", "", "invalid value 'x' for field 'ids'"},
+		{http.MethodGet, "/v1/statuses/active?syntax=SYNTAX_UNKNOWN", "", "invalid value 'SYNTAX_UNKNOWN' for field 'syntax'"},
+		{http.MethodGet, "/v1/statuses/active?unknown=1", "", "unknown field 'unknown' in pkg.transcoding.Request"},
+		{http.MethodGet, "/v1/statuses/active?filter=1", "", "field 'filter' in pkg.transcoding.Request can't be set from a parameter"},
+		{http.MethodGet, "/v1/statuses/active?name.id=1", "", "field 'name' in pkg.transcoding.Request is not a message"},
+	}
+	for _, test := range tests {
+		response := serveTranscoding(ctrl, httptest.NewRequest(test.method, test.target, strings.NewReader(test.body)))
+		assert.Equal(t, http.StatusBadRequest, response.Code, test.target)
+		assert.Contains(t, response.Body.String(), test.message, test.target)
+	}
+
+	response := serveTranscoding(ctrl, httptest.NewRequest(http.MethodPost, "/v1/requests", strings.NewReader(`{"ids":`)))
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.Contains(t, response.Body.String(), "could not read the request body")
+	assert.Empty(t, matcher.requests)
+}
+
+func TestTranscodingController_getResponseBody(t *testing.T) {
+	requestDescriptor, responseDescriptor := transcodingMessages(t)
+	matcher := &fakeRequestsMatcher{stub: &stub.Stub{FullMethod: transcodingMethod, Type: "mock",
+		Response: &stub.StubResponse{Type: "success", Content: `{"id":"1","filter":{"status":"active"}}`}}}
+	ctrl := TranscodingController{StubsMatcher: matcher, Service: fakeTranscodingService{
+		request:  requestDescriptor,
+		response: responseDescriptor,
+		rules: []grpchandler.HTTPRule{
+			{FullMethod: transcodingMethod, Method: http.MethodGet, Path: "/v1/requests/{name}"},
+			{FullMethod: transcodingMethod, Method: http.MethodGet, Path: "/v1/requests/{name}/filter", ResponseBody: "filter"},
+			{FullMethod: transcodingMethod, Method: http.MethodGet, Path: "/v1/requests/{name}/id", ResponseBody: "id"},
+		},
+	}}
+
+	response := serveTranscoding(ctrl, httptest.NewRequest(http.MethodGet, "/v1/requests/1", nil))
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"id":"1","filter":{"status":"active"}}`, response.Body.String())
+
+	// the field of the response body is written with its unpopulated fields
+	response = serveTranscoding(ctrl, httptest.NewRequest(http.MethodGet, "/v1/requests/1/filter", nil))
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"status":"active","tags":[]}`, response.Body.String())
+
+	response = serveTranscoding(ctrl, httptest.NewRequest(http.MethodGet, "/v1/requests/1/id", nil))
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `"1"`, response.Body.String())
+
+	matcher.stub = nil
+	response = serveTranscoding(ctrl, httptest.NewRequest(http.MethodGet, "/v1/requests/1/filter", nil))
+	assert.Equal(t, http.StatusInternalServerError, response.Code)
+	assert.Contains(t, response.Body.String(), "no response found")
+}
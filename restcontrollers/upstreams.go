@@ -0,0 +1,35 @@
+package restcontrollers
+
+import (
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+)
+
+type UpstreamsController struct {
+	Upstreams grpchandler.UpstreamsHealth
+}
+
+func (c UpstreamsController) GetHandlers() []RESTHandler {
+	return []RESTHandler{
+		{
+			Name:    "GetUpstreams",
+			Path:    "",
+			Methods: []string{http.MethodGet},
+			Handler: c.getUpstreamsHandler,
+		},
+	}
+}
+
+func (c UpstreamsController) GetPath() string {
+	return "/upstreams"
+}
+
+func (c UpstreamsController) getUpstreamsHandler(writer http.ResponseWriter, request *http.Request) {
+	log.Info("REST: received call to get upstreams health")
+
+	writeErr := writeResponse(writer, c.Upstreams.GetAll())
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
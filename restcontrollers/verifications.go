@@ -0,0 +1,235 @@
+package restcontrollers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+)
+
+type VerificationsController struct {
+	StubsStore stub.StubsStore
+	Service    grpchandler.MockService
+	// Optional. Records the resets of the verifications.
+	AuditLog stub.AuditLog
+}
+
+func (c VerificationsController) GetHandlers() []RESTHandler {
+	return []RESTHandler{
+		{
+			Name:    "GetStubsUsage",
+			Path:    "",
+			Methods: []string{http.MethodGet},
+			Handler: c.getStubsUsageHandler,
+		},
+		{
+			Name:    "GetUnmatchedStubs",
+			Path:    "/unmatched",
+			Methods: []string{http.MethodGet},
+			Handler: c.getUnmatchedStubsHandler,
+		},
+		{
+			Name:    "GetSequences",
+			Path:    "/sequences",
+			Methods: []string{http.MethodGet},
+			Handler: c.getSequencesHandler,
+		},
+		{
+			Name:    "GetReport",
+			Path:    "/report",
+			Methods: []string{http.MethodGet},
+			Handler: c.getReportHandler,
+		},
+		{
+			Name:    "VerifyStub",
+			Path:    "",
+			Methods: []string{http.MethodPost},
+			Handler: c.verifyStubHandler,
+		},
+		{
+			Name:    "ResetVerifications",
+			Path:    "",
+			Methods: []string{http.MethodDelete},
+			Handler: c.resetVerificationsHandler,
+		},
+	}
+}
+
+func (c VerificationsController) GetPath() string {
+	return "/verifications"
+}
+
+func (c VerificationsController) getStubsUsageHandler(writer http.ResponseWriter, request *http.Request) {
+	log.Info("REST: received call to get stubs usage")
+
+	method := getQueryParam(request, requestParamMethod)
+	var stubs []*stub.Stub
+	if method == emptyString {
+		stubs = c.StubsStore.GetAllStubs()
+	} else {
+		stubs = c.StubsStore.GetStubsForMethod(method)
+	}
+	stubs = stubFilter(request).Filter(stubs)
+
+	usage := make([]stub.StubUsage, 0)
+	for _, s := range stubs {
+		usage = append(usage, stub.StubUsage{
+			Stub:       s,
+			MatchCount: c.StubsStore.GetMatchCount(s),
+		})
+	}
+	writeErr := writeResponse(writer, usage)
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
+
+func (c VerificationsController) getUnmatchedStubsHandler(writer http.ResponseWriter, request *http.Request) {
+	log.Info("REST: received call to get unmatched stubs")
+
+	writeErr := writeResponse(writer, c.StubsStore.GetUnmatchedStubs())
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
+
+// getSequencesHandler returns the status of the sequences of the stubs, or of the one named in ?name=.
+func (c VerificationsController) getSequencesHandler(writer http.ResponseWriter, request *http.Request) {
+	log.Info("REST: received call to get the status of the sequences")
+
+	sequences := make([]stub.SequenceStatus, 0)
+	if tracker, ok := c.StubsStore.(stub.SequenceTracker); ok {
+		name := getQueryParam(request, "name")
+		for _, sequence := range tracker.GetSequences() {
+			if name == emptyString || sequence.Name == name {
+				sequences = append(sequences, sequence)
+			}
+		}
+	}
+	writeErr := writeResponse(writer, sequences)
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
+
+// getReportHandler returns the report of the unmatched requests and the unused stubs in the JUnit XML format, or in JSON
+// with ?format=json.
+func (c VerificationsController) getReportHandler(writer http.ResponseWriter, request *http.Request) {
+	log.Info("REST: received call to get the report of the unmatched requests and unused stubs")
+
+	report := stub.NewReport(c.unmatchedRequests(), c.StubsStore)
+	switch getQueryParam(request, "format") {
+	case "json":
+		writeErr := writeResponse(writer, report)
+		if writeErr != nil {
+			writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+		}
+	case emptyString, "junit":
+		data, err := report.JUnit()
+		if err != nil {
+			writeErrorResponse(writer, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writer.Header().Add(contentType, "application/xml")
+		writer.Write(data)
+	default:
+		writeErrorResponse(writer, http.StatusBadRequest, "Invalid format. Use junit or json.")
+	}
+}
+
+// unmatchedRequests returns the requests without a matching stub received for the methods of the service.
+func (c VerificationsController) unmatchedRequests() []stub.UnmatchedRequest {
+	requests := grpchandler.GetUnmatchedRequests()
+	if c.Service == nil {
+		return requests
+	}
+	methods := make(map[string]bool)
+	for _, method := range c.Service.GetSupportedMethods() {
+		methods[method] = true
+	}
+	filtered := make([]stub.UnmatchedRequest, 0, len(requests))
+	for _, r := range requests {
+		if methods[r.FullMethod] {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+func (c VerificationsController) verifyStubHandler(writer http.ResponseWriter, request *http.Request) {
+	verification, err := readVerificationFromRequestBody(request)
+	if err != nil {
+		writeErrorResponse(writer, http.StatusBadRequest, fmt.Sprintf("call to verify stub failed with error: %s", err.Error()))
+		return
+	}
+	log.WithFields(log.Fields{"verification": toJSON(verification)}).
+		Info("REST: received call to verify stub")
+
+	if verification.Request == nil {
+		writeErrorResponse(writer, http.StatusBadRequest, "Request can't be empty.")
+		return
+	}
+
+	if c.Service != nil {
+		instance := c.Service.GetRequestInstance(verification.FullMethod)
+		if instance == nil {
+			writeErrorResponse(writer, http.StatusBadRequest, fmt.Sprintf("Method %s is not supported", verification.FullMethod))
+			return
+		}
+		cleanedRequest, cleanErr := cleanJson(verification.Request.Content, instance)
+		if cleanErr != nil {
+			writeErrorResponse(writer, http.StatusBadRequest, fmt.Sprintf("call to verify stub failed with error: %s", cleanErr.Error()))
+			return
+		}
+		verification.Request.Content = cleanedRequest
+	}
+
+	s := &stub.Stub{
+		FullMethod: verification.FullMethod,
+		Request:    verification.Request,
+	}
+	if !c.StubsStore.Exists(s) {
+		writeErrorResponse(writer, http.StatusNotFound, "Stub not found")
+		return
+	}
+
+	result := verification.Verify(c.StubsStore.GetMatchCount(s))
+	writeErr := writeResponse(writer, result)
+	if writeErr != nil {
+		writeErrorResponse(writer, http.StatusInternalServerError, writeErr.Error())
+	}
+}
+
+func (c VerificationsController) resetVerificationsHandler(writer http.ResponseWriter, request *http.Request) {
+	log.Info("REST: received call to reset verifications")
+
+	c.StubsStore.ResetMatchCounts()
+	if c.Service != nil {
+		grpchandler.ResetUnmatchedRequests(c.Service.GetSupportedMethods()...)
+	} else {
+		grpchandler.ResetUnmatchedRequests()
+	}
+	recordAudit(c.AuditLog, request, stub.AuditEntry{Operation: stub.AuditResetVerifications})
+	writeSuccessResponse(writer)
+}
+
+func readVerificationFromRequestBody(request *http.Request) (*stub.StubVerification, error) {
+	bodyData, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		log.Errorf("Unexpected error while reading verification from the request. Error %s", err.Error())
+		return nil, fmt.Errorf("could not read verification in payload")
+	}
+	defer request.Body.Close()
+
+	verification := new(stub.StubVerification)
+	unmarshalErr := json.Unmarshal(bodyData, verification)
+	if unmarshalErr != nil {
+		log.Errorf("Unexpected error while reading verification from the request. Error %s", unmarshalErr.Error())
+		return nil, fmt.Errorf("could not read verification in payload")
+	}
+
+	return verification, nil
+}
@@ -0,0 +1,178 @@
+package restcontrollers
+
+import (
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVerificationsController_GetPath(t *testing.T) {
+	ctrl := VerificationsController{}
+
+	assert.Equal(t, "/verifications", ctrl.GetPath())
+}
+
+func TestVerificationsController_GetHandlers(t *testing.T) {
+	ctrl := VerificationsController{}
+
+	assert.Equal(t, 6, len(ctrl.GetHandlers()))
+	assert.Equal(t, "/unmatched", findHandler(ctrl.GetHandlers(), "GetUnmatchedStubs").Path)
+	assert.Equal(t, "/report", findHandler(ctrl.GetHandlers(), "GetReport").Path)
+	validateHandler(t, findHandler(ctrl.GetHandlers(), "GetStubsUsage"), http.MethodGet)
+	validateHandler(t, findHandler(ctrl.GetHandlers(), "VerifyStub"), http.MethodPost)
+	validateHandler(t, findHandler(ctrl.GetHandlers(), "ResetVerifications"), http.MethodDelete)
+}
+
+func TestVerificationsController_verifyStubHandler(t *testing.T) {
+	stubsStore := createStubsStoreWithMatches(2)
+	ctrl := VerificationsController{
+		StubsStore: stubsStore,
+	}
+
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/verifications", strings.NewReader(`{
+    "fullMethod": "method1",
+    "request": {"match": "exact", "content": {"name":"Rodrigo"}},
+    "times": 2
+}`))
+	findHandler(ctrl.GetHandlers(), "VerifyStub").Handler(response, request)
+	assert.Equal(t, 200, response.Code)
+	assert.Equal(t, `{"verified":true,"matchCount":2,"message":""}`, response.Body.String())
+}
+
+func TestVerificationsController_verifyStubHandler_NotVerified(t *testing.T) {
+	stubsStore := createStubsStoreWithMatches(1)
+	ctrl := VerificationsController{
+		StubsStore: stubsStore,
+	}
+
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/verifications", strings.NewReader(`{
+    "fullMethod": "method1",
+    "request": {"match": "exact", "content": {"name":"Rodrigo"}},
+    "times": 2,
+    "atLeast": true
+}`))
+	findHandler(ctrl.GetHandlers(), "VerifyStub").Handler(response, request)
+	assert.Equal(t, 200, response.Code)
+	assert.Contains(t, response.Body.String(), `"verified":false,"matchCount":1`)
+	assert.Contains(t, response.Body.String(), "expected to be matched at least 2 time(s) but was matched 1 time(s)")
+}
+
+func TestVerificationsController_verifyStubHandler_StubNotFound(t *testing.T) {
+	stubsStore := createStubsStoreWithMatches(1)
+	ctrl := VerificationsController{
+		StubsStore: stubsStore,
+	}
+
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/verifications", strings.NewReader(`{
+    "fullMethod": "method1",
+    "request": {"match": "exact", "content": {"name":"John"}},
+    "times": 1
+}`))
+	findHandler(ctrl.GetHandlers(), "VerifyStub").Handler(response, request)
+	assert.Equal(t, 404, response.Code)
+	assert.Equal(t, "Stub not found", response.Body.String())
+}
+
+func TestVerificationsController_getUnmatchedStubsHandler(t *testing.T) {
+	stubsStore := createStubsStoreWithMatches(0)
+	ctrl := VerificationsController{
+		StubsStore: stubsStore,
+	}
+
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/verifications/unmatched", nil)
+	findHandler(ctrl.GetHandlers(), "GetUnmatchedStubs").Handler(response, request)
+	assert.Equal(t, 200, response.Code)
+	assert.Contains(t, response.Body.String(), `"fullMethod":"method1"`)
+
+	stubsStore.RecordMatch(stubsStore.GetAllStubs()[0])
+	response = httptest.NewRecorder()
+	findHandler(ctrl.GetHandlers(), "GetUnmatchedStubs").Handler(response, request)
+	assert.Equal(t, "[]", response.Body.String())
+}
+
+func TestVerificationsController_resetVerificationsHandler(t *testing.T) {
+	stubsStore := createStubsStoreWithMatches(3)
+	ctrl := VerificationsController{
+		StubsStore: stubsStore,
+	}
+
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodDelete, "/verifications", nil)
+	findHandler(ctrl.GetHandlers(), "ResetVerifications").Handler(response, request)
+	assert.Equal(t, 200, response.Code)
+	assert.Equal(t, 0, stubsStore.GetMatchCount(stubsStore.GetAllStubs()[0]))
+}
+
+func TestVerificationsController_getSequencesHandler(t *testing.T) {
+	stubsStore := stub.NewInMemoryStubsStore()
+	for i, name := range []string{"checkout", "refund"} {
+		stubsStore.Add(&stub.Stub{
+			FullMethod: "method1",
+			Type:       "mock",
+			Request:    &stub.StubRequest{Match: "exact", Content: stub.JsonString(fmt.Sprintf(`{"id":%d}`, i))},
+			Sequence:   &stub.StubSequence{Name: name, Steps: []int{1}},
+		})
+	}
+	ctrl := VerificationsController{
+		StubsStore: stubsStore,
+	}
+
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/verifications/sequences?name=refund", nil)
+	findHandler(ctrl.GetHandlers(), "GetSequences").Handler(response, request)
+	assert.Equal(t, 200, response.Code)
+	assert.Equal(t, `[{"name":"refund","steps":1,"completed":0,"violations":[],"verified":false}]`, response.Body.String())
+}
+
+func TestVerificationsController_getReportHandler(t *testing.T) {
+	ctrl := VerificationsController{
+		StubsStore: createStubsStoreWithMatches(0),
+	}
+
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/verifications/report", nil)
+	findHandler(ctrl.GetHandlers(), "GetReport").Handler(response, request)
+	assert.Equal(t, 200, response.Code)
+	assert.Equal(t, "application/xml", response.Header().Get("Content-Type"))
+	assert.Contains(t, response.Body.String(), `<testsuite name="stubs" tests="1" failures="1">`)
+
+	response = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodGet, "/verifications/report?format=json", nil)
+	findHandler(ctrl.GetHandlers(), "GetReport").Handler(response, request)
+	assert.Equal(t, 200, response.Code)
+	assert.Contains(t, response.Body.String(), `"stubs":[{"stub":{"fullMethod":"method1"`)
+
+	response = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodGet, "/verifications/report?format=html", nil)
+	findHandler(ctrl.GetHandlers(), "GetReport").Handler(response, request)
+	assert.Equal(t, 400, response.Code)
+}
+
+func createStubsStoreWithMatches(matches int) stub.StubsStore {
+	stubsStore := stub.NewInMemoryStubsStore()
+	s := &stub.Stub{
+		FullMethod: "method1",
+		Type:       "mock",
+		Request: &stub.StubRequest{
+			Match:   "exact",
+			Content: `{"name":"Rodrigo"}`,
+		},
+		Response: &stub.StubResponse{
+			Type:    "success",
+			Content: `{"name":"Rodrigo de Carvalho"}`,
+		},
+	}
+	stubsStore.Add(s)
+	for i := 0; i < matches; i++ {
+		stubsStore.RecordMatch(s)
+	}
+	return stubsStore
+}
@@ -0,0 +1,68 @@
+package stub
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	AuditCreate             = "create"
+	AuditUpdate             = "update"
+	AuditDelete             = "delete"
+	AuditDeleteAll          = "delete_all"
+	AuditResetVerifications = "reset_verifications"
+)
+
+// AuditEntry records a change to the stubs: who made it, when, and the stubs before and after the change.
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	Caller    string    `json:"caller"`
+	// Method the operation applies to. Empty when it applies to all the methods.
+	Method string  `json:"method,omitempty"`
+	Before []*Stub `json:"before,omitempty"`
+	After  []*Stub `json:"after,omitempty"`
+}
+
+type AuditLog interface {
+	Record(entry AuditEntry)
+	// GetEntries returns the entries of the method, or all the entries when the method is empty, oldest first.
+	GetEntries(method string) []AuditEntry
+}
+
+// NewAuditLog creates an audit log kept in memory. Only the last maxEntries entries are kept.
+func NewAuditLog(maxEntries int) AuditLog {
+	return &inMemoryAuditLog{maxEntries: maxEntries}
+}
+
+type inMemoryAuditLog struct {
+	entries    []AuditEntry
+	maxEntries int
+	mutex      sync.RWMutex
+}
+
+func (a *inMemoryAuditLog) Record(entry AuditEntry) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if entry.Time.IsZero() {
+		entry.Time = Now().UTC()
+	}
+	a.entries = append(a.entries, entry)
+	if a.maxEntries > 0 && len(a.entries) > a.maxEntries {
+		a.entries = append([]AuditEntry(nil), a.entries[len(a.entries)-a.maxEntries:]...)
+	}
+}
+
+func (a *inMemoryAuditLog) GetEntries(method string) []AuditEntry {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	entries := make([]AuditEntry, 0, len(a.entries))
+	for _, entry := range a.entries {
+		if method == "" || entry.Method == method || entry.Method == "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
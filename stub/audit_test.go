@@ -0,0 +1,36 @@
+package stub
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAuditLog_GetEntries(t *testing.T) {
+	auditLog := NewAuditLog(0)
+	s := &Stub{FullMethod: "/pkg.Service/Method", Request: &StubRequest{Match: "exact", Content: `{}`}}
+	auditLog.Record(AuditEntry{Operation: AuditCreate, Caller: "ci", Method: s.FullMethod, After: []*Stub{s}})
+	auditLog.Record(AuditEntry{Operation: AuditCreate, Caller: "ci", Method: "/pkg.Service/Other"})
+	auditLog.Record(AuditEntry{Operation: AuditDeleteAll, Caller: "john"})
+
+	entries := auditLog.GetEntries("")
+	assert.Equal(t, 3, len(entries))
+	assert.False(t, entries[0].Time.IsZero())
+	assert.Equal(t, []*Stub{s}, entries[0].After)
+
+	entries = auditLog.GetEntries(s.FullMethod)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, AuditCreate, entries[0].Operation)
+	assert.Equal(t, AuditDeleteAll, entries[1].Operation)
+}
+
+func TestAuditLog_Record_KeepsLastEntries(t *testing.T) {
+	auditLog := NewAuditLog(2)
+	for _, caller := range []string{"a", "b", "c"} {
+		auditLog.Record(AuditEntry{Operation: AuditResetVerifications, Caller: caller})
+	}
+
+	entries := auditLog.GetEntries("")
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "b", entries[0].Caller)
+	assert.Equal(t, "c", entries[1].Caller)
+}
@@ -0,0 +1,37 @@
+package stub
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var bandwidthUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseBandwidth returns the bytes per second of the bandwidth, written as a number of B, KB, MB or GB (multiples of 1024)
+// per second, e.g. 256KB/s. The /s suffix is optional.
+func ParseBandwidth(bandwidth string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSuffix(strings.TrimSpace(bandwidth), "/s"))
+	for _, unit := range bandwidthUnits {
+		if !strings.HasSuffix(value, unit.suffix) {
+			continue
+		}
+		number, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), 64)
+		if err != nil || number <= 0 {
+			break
+		}
+		if bytes := int64(number * float64(unit.bytes)); bytes > 0 {
+			return bytes, nil
+		}
+		break
+	}
+	return 0, fmt.Errorf("invalid bandwidth '%s'. Use a number of B, KB, MB or GB per second, e.g. 256KB/s", bandwidth)
+}
@@ -0,0 +1,29 @@
+package stub
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	tests := []struct {
+		bandwidth string
+		bytes     int64
+	}{
+		{"256KB/s", 256 * 1024},
+		{"1.5mb", 1536 * 1024},
+		{"100B/s", 100},
+		{"1GB/s", 1 << 30},
+	}
+	for _, test := range tests {
+		bytes, err := ParseBandwidth(test.bandwidth)
+		assert.Nil(t, err, test.bandwidth)
+		assert.Equal(t, test.bytes, bytes, test.bandwidth)
+	}
+	for _, bandwidth := range []string{"", "256", "0KB/s", "-1MB", "fastKB", "0.1B"} {
+		_, err := ParseBandwidth(bandwidth)
+		assert.Error(t, err, bandwidth)
+	}
+	_, err := ParseBandwidth("fast")
+	assert.EqualError(t, err, "invalid bandwidth 'fast'. Use a number of B, KB, MB or GB per second, e.g. 256KB/s")
+}
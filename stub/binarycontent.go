@@ -0,0 +1,82 @@
+package stub
+
+import (
+	"encoding/base64"
+	"fmt"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// isValidBinaryContent checks the binary content of the request and the response, which replaces their JSON content.
+func (stub *Stub) isValidBinaryContent() (isValid bool, errMsgs []string) {
+	if stub.Request != nil && stub.Request.ContentBinary != "" {
+		if stub.Request.Content != "" {
+			errMsgs = append(errMsgs, "Request content and contentBinary can't both be set.")
+		}
+		if _, err := decodeBase64(stub.Request.ContentBinary); err != nil {
+			errMsgs = append(errMsgs, fmt.Sprintf("Request contentBinary is not valid base64: %v.", err))
+		}
+	}
+	if stub.Response != nil && stub.Response.ContentBinary != "" {
+		if stub.Response.Type != "success" {
+			errMsgs = append(errMsgs, "Response contentBinary can only be set when the response type is 'success'.")
+		}
+		if stub.Response.Content != "" {
+			errMsgs = append(errMsgs, "Response content and contentBinary can't both be set.")
+		}
+		if _, err := decodeBase64(stub.Response.ContentBinary); err != nil {
+			errMsgs = append(errMsgs, fmt.Sprintf("Response contentBinary is not valid base64: %v.", err))
+		}
+	}
+	return len(errMsgs) == 0, errMsgs
+}
+
+// convertBinaryContent replaces the binary content of the request and the response with their JSON form, so that the
+// stub is matched, stored and returned by the REST API as the stubs written in JSON.
+func (stub *Stub) convertBinaryContent(request, response protoreflect.MessageDescriptor) (errMsgs []string) {
+	if stub.Request.ContentBinary != "" {
+		content, err := binaryToJson(stub.Request.ContentBinary, request)
+		if err != nil {
+			errMsgs = append(errMsgs, fmt.Sprintf("request.contentBinary: not a valid %s: %v", request.FullName(), err))
+		} else {
+			stub.Request.Content, stub.Request.ContentBinary = content, ""
+		}
+	}
+	if stub.Response != nil && stub.Response.ContentBinary != "" {
+		content, err := binaryToJson(stub.Response.ContentBinary, response)
+		if err != nil {
+			errMsgs = append(errMsgs, fmt.Sprintf("response.contentBinary: not a valid %s: %v", response.FullName(), err))
+		} else {
+			stub.Response.Content, stub.Response.ContentBinary = content, ""
+		}
+	}
+	return errMsgs
+}
+
+func binaryToJson(contentBinary string, t protoreflect.MessageDescriptor) (JsonString, error) {
+	data, err := decodeBase64(contentBinary)
+	if err != nil {
+		return "", err
+	}
+	message := dynamicpb.NewMessage(t)
+	if err := proto.Unmarshal(data, message); err != nil {
+		return "", err
+	}
+	content, err := protojson.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+	return JsonString(content), nil
+}
+
+// decodeBase64 decodes base64 with the standard or the URL alphabet, padded or not.
+func decodeBase64(s string) (data []byte, err error) {
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if data, err = encoding.DecodeString(s); err == nil {
+			return data, nil
+		}
+	}
+	return nil, err
+}
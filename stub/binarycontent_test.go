@@ -0,0 +1,69 @@
+package stub
+
+import (
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+)
+
+func binaryContent(t *testing.T, message proto.Message) string {
+	data, err := proto.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestIsStubValid_BinaryContent(t *testing.T) {
+	request, response := new(errdetails.ErrorInfo).ProtoReflect().Descriptor(), new(wrapperspb.StringValue).ProtoReflect().Descriptor()
+	s := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &StubRequest{Match: "exact", ContentBinary: binaryContent(t, &errdetails.ErrorInfo{Reason: "QUOTA", Metadata: map[string]string{"a": "b"}})},
+		Response:   &StubResponse{Type: "success", ContentBinary: binaryContent(t, wrapperspb.String("John"))},
+	}
+
+	isValid, errMsgs := IsStubValid(s, request, response)
+	assert.True(t, isValid)
+	assert.Empty(t, errMsgs)
+	assert.JSONEq(t, `{"reason":"QUOTA","metadata":{"a":"b"}}`, s.Request.Content.String())
+	assert.Equal(t, "", s.Request.ContentBinary)
+	assert.Equal(t, JsonString(`"John"`), s.Response.Content)
+	assert.Equal(t, "", s.Response.ContentBinary)
+
+	s.Request.Content, s.Request.ContentBinary = "", "CgRKb2hu_w"
+	isValid, errMsgs = IsStubValid(s, request, response)
+	assert.False(t, isValid)
+	assert.Equal(t, 1, len(errMsgs))
+	assert.Contains(t, errMsgs[0], "request.contentBinary: not a valid google.rpc.ErrorInfo: ")
+}
+
+func TestStub_IsValid_BinaryContent(t *testing.T) {
+	newStub := func(request *StubRequest, response *StubResponse) *Stub {
+		return &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: request, Response: response}
+	}
+	tests := []struct {
+		name   string
+		stub   *Stub
+		errors []string
+	}{
+		{"binary request and response", newStub(&StubRequest{Match: "exact", ContentBinary: "CgRKb2hu"}, &StubResponse{Type: "success", ContentBinary: "CgRKb2hu"}), nil},
+		{"url alphabet without padding", newStub(&StubRequest{Match: "exact", ContentBinary: "_-8"}, &StubResponse{Type: "success", Content: "{}"}), nil},
+		{"content and binary content", newStub(&StubRequest{Match: "exact", Content: "{}", ContentBinary: "CgRKb2hu"}, &StubResponse{Type: "success", Content: "{}"}),
+			[]string{"Request content and contentBinary can't both be set."}},
+		{"not base64", newStub(&StubRequest{Match: "exact", ContentBinary: "not base64!"}, &StubResponse{Type: "success", Content: "{}"}),
+			[]string{"Request contentBinary is not valid base64: illegal base64 data at input byte 3."}},
+		{"binary content of an error", newStub(&StubRequest{Match: "exact", Content: "{}"}, &StubResponse{Type: "error", Error: &ErrorResponse{Code: 5}, ContentBinary: "CgRKb2hu"}),
+			[]string{"Response contentBinary can only be set when the response type is 'success'."}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			isValid, errors := test.stub.IsValid()
+			assert.Equal(t, len(test.errors) == 0, isValid)
+			assert.Equal(t, test.errors, errors)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package stub
+
+import (
+	"encoding/json"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"time"
+)
+
+// StubBuilder creates stubs from the request and response messages so that the JSON content doesn't have to be written by hand.
+// It is used by the typed builders generated with the stub_builders parameter.
+type StubBuilder struct {
+	stub *Stub
+	err  error
+}
+
+// NewStubBuilder starts a mock stub for the method that matches any request.
+func NewStubBuilder(fullMethod string) *StubBuilder {
+	return &StubBuilder{
+		stub: &Stub{
+			FullMethod: fullMethod,
+			Type:       "mock",
+			Request: &StubRequest{
+				Match:   "partial",
+				Content: JsonString("{}"),
+			},
+		},
+	}
+}
+
+// MatchMode is how the request of a stub is compared with the incoming requests.
+type MatchMode string
+
+const (
+	// Exact matches the requests equal to the one of the stub.
+	Exact MatchMode = "exact"
+	// Partial matches the requests containing the fields set in the one of the stub.
+	Partial MatchMode = "partial"
+)
+
+// For starts a mock stub for the method that matches any request. It is the same as NewStubBuilder, to be read as a
+// sentence with WhenProto and ReturnProto:
+//
+//	s, err := stub.For("/pkg.Service/Get").WhenProto(req, stub.Partial).ReturnProto(resp).Build()
+func For(fullMethod string) *StubBuilder {
+	return NewStubBuilder(fullMethod)
+}
+
+// WhenProto only matches the requests compared with the message as the mode tells.
+func (b *StubBuilder) WhenProto(request proto.Message, mode MatchMode) *StubBuilder {
+	if mode != Exact && mode != Partial && b.err == nil {
+		b.err = fmt.Errorf("invalid match mode '%s' for the stub of %s", mode, b.stub.FullMethod)
+	}
+	b.stub.Request.Match = string(mode)
+	b.stub.Request.Content = b.toJson(request)
+	return b
+}
+
+// ReturnProto responds with the message. It is the same as RespondWith.
+func (b *StubBuilder) ReturnProto(response proto.Message) *StubBuilder {
+	return b.RespondWith(response)
+}
+
+// ReturnError responds with the error. It is the same as RespondWithError.
+func (b *StubBuilder) ReturnError(code codes.Code, message string) *StubBuilder {
+	return b.RespondWithError(code, message)
+}
+
+// WithRequest only matches requests equal to the one provided.
+func (b *StubBuilder) WithRequest(request proto.Message) *StubBuilder {
+	b.stub.Request.Match = "exact"
+	b.stub.Request.Content = b.toJson(request)
+	return b
+}
+
+// WithPartialRequest matches the requests containing the fields set in the one provided.
+func (b *StubBuilder) WithPartialRequest(request proto.Message) *StubBuilder {
+	b.stub.Request.Match = "partial"
+	b.stub.Request.Content = b.toJson(request)
+	return b
+}
+
+// WithMetadata only matches requests with the metadata provided.
+func (b *StubBuilder) WithMetadata(key string, values ...string) *StubBuilder {
+	if b.stub.Request.Metadata == nil {
+		b.stub.Request.Metadata = make(map[string][]string)
+	}
+	b.stub.Request.Metadata[key] = append(b.stub.Request.Metadata[key], values...)
+	return b
+}
+
+func (b *StubBuilder) RespondWith(response proto.Message) *StubBuilder {
+	b.response().Type = "success"
+	b.response().Content = b.toJson(response)
+	b.response().Error = nil
+	return b
+}
+
+func (b *StubBuilder) RespondWithError(code codes.Code, message string) *StubBuilder {
+	b.response().Type = "error"
+	b.response().Content = ""
+	b.response().Error = &ErrorResponse{Code: uint32(code), Message: message}
+	return b
+}
+
+// WithDelay waits before responding. The client deadline is respected.
+func (b *StubBuilder) WithDelay(delay time.Duration) *StubBuilder {
+	b.response().Delay = delay.String()
+	return b
+}
+
+// WithBandwidth sends the response at the rate of bytesPerSecond to simulate a slow network.
+func (b *StubBuilder) WithBandwidth(bytesPerSecond int64) *StubBuilder {
+	b.response().Bandwidth = fmt.Sprintf("%dB/s", bytesPerSecond)
+	return b
+}
+
+// Build returns the stub or the first error found while building it.
+func (b *StubBuilder) Build() (*Stub, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.stub.Response == nil {
+		return nil, fmt.Errorf("the response of the stub for %s was not provided", b.stub.FullMethod)
+	}
+	return b.stub, nil
+}
+
+// MustBuild returns the stub and panics if it can't be built, e.g. to declare the stubs of the tests in variables.
+func (b *StubBuilder) MustBuild() *Stub {
+	s, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// JSON returns the stub in the format accepted by the REST API.
+func (b *StubBuilder) JSON() ([]byte, error) {
+	s, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(s)
+}
+
+func (b *StubBuilder) response() *StubResponse {
+	if b.stub.Response == nil {
+		b.stub.Response = &StubResponse{}
+	}
+	return b.stub.Response
+}
+
+func (b *StubBuilder) toJson(message proto.Message) JsonString {
+	data, err := protojson.Marshal(message)
+	if err != nil && b.err == nil {
+		b.err = fmt.Errorf("could not marshal %T for the stub of %s: %w", message, b.stub.FullMethod, err)
+	}
+	if len(data) == 0 {
+		return JsonString("{}")
+	}
+	return JsonString(data)
+}
@@ -0,0 +1,71 @@
+package stub
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+	"time"
+)
+
+func TestStubBuilder_RespondWith(t *testing.T) {
+	s, err := NewStubBuilder("/pkg.Service/Method").
+		WithRequest(wrapperspb.String("John")).
+		WithMetadata("tenant", "a").
+		RespondWith(wrapperspb.String("Hello, John")).
+		WithDelay(200 * time.Millisecond).
+		WithBandwidth(1024).
+		Build()
+	assert.Nil(t, err)
+	assert.Equal(t, StubType("mock"), s.Type)
+	assert.Equal(t, "exact", s.Request.Match)
+	assert.Equal(t, JsonString(`"John"`), s.Request.Content)
+	assert.Equal(t, []string{"a"}, s.Request.Metadata["tenant"])
+	assert.Equal(t, "success", s.Response.Type)
+	assert.Equal(t, JsonString(`"Hello, John"`), s.Response.Content)
+	assert.Equal(t, "200ms", s.Response.Delay)
+	assert.Equal(t, "1024B/s", s.Response.Bandwidth)
+	isValid, errMsgs := s.IsValid()
+	assert.True(t, isValid, errMsgs)
+}
+
+func TestStubBuilder_RespondWithError(t *testing.T) {
+	data, err := NewStubBuilder("/pkg.Service/Method").
+		RespondWithError(codes.NotFound, "not found").
+		JSON()
+	assert.Nil(t, err)
+	s := &Stub{}
+	assert.Nil(t, json.Unmarshal(data, s))
+	assert.Equal(t, "partial", s.Request.Match)
+	assert.Equal(t, JsonString("{}"), s.Request.Content)
+	assert.Equal(t, "error", s.Response.Type)
+	assert.Equal(t, &ErrorResponse{Code: uint32(codes.NotFound), Message: "not found"}, s.Response.Error)
+}
+
+func TestStubBuilder_MissingResponse(t *testing.T) {
+	_, err := NewStubBuilder("/pkg.Service/Method").Build()
+	assert.EqualError(t, err, "the response of the stub for /pkg.Service/Method was not provided")
+}
+
+func TestStubBuilder_ProtoDSL(t *testing.T) {
+	s, err := For("/pkg.Service/Method").
+		WhenProto(wrapperspb.String("John"), Partial).
+		ReturnProto(wrapperspb.String("Hello, John")).
+		Build()
+	assert.Nil(t, err)
+	assert.Equal(t, &StubRequest{Match: "partial", Content: `"John"`}, s.Request)
+	assert.Equal(t, &StubResponse{Type: "success", Content: `"Hello, John"`}, s.Response)
+
+	s = For("/pkg.Service/Method").WhenProto(wrapperspb.String("John"), Exact).ReturnError(codes.NotFound, "not found").MustBuild()
+	assert.Equal(t, "exact", s.Request.Match)
+	assert.Equal(t, &ErrorResponse{Code: uint32(codes.NotFound), Message: "not found"}, s.Response.Error)
+}
+
+func TestStubBuilder_ProtoDSLErrors(t *testing.T) {
+	_, err := For("/pkg.Service/Method").WhenProto(wrapperspb.String("John"), "regex").ReturnProto(wrapperspb.String("Hello")).Build()
+	assert.EqualError(t, err, "invalid match mode 'regex' for the stub of /pkg.Service/Method")
+	assert.PanicsWithError(t, "the response of the stub for /pkg.Service/Method was not provided", func() {
+		For("/pkg.Service/Method").MustBuild()
+	})
+}
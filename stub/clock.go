@@ -0,0 +1,106 @@
+package stub
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ClockConfig changes the virtual clock used by the templates and to timestamp the calls recorded, so that the
+// responses with times are deterministic in the tests. Either Time or Offset can be set, none to use the real time.
+type ClockConfig struct {
+	// Time the clock is frozen at
+	Time *time.Time `json:"time,omitempty"`
+	// Offset added to the real time, e.g. -24h
+	Offset string `json:"offset,omitempty"`
+}
+
+// ClockState is the time of the virtual clock and how it was set.
+type ClockState struct {
+	Now    time.Time `json:"now"`
+	Frozen bool      `json:"frozen"`
+	Offset string    `json:"offset,omitempty"`
+}
+
+var clock = struct {
+	frozen *time.Time
+	offset time.Duration
+	mutex  sync.RWMutex
+}{}
+
+// Now returns the time of the virtual clock, the real time unless it was changed with SetClock.
+func Now() time.Time {
+	clock.mutex.RLock()
+	defer clock.mutex.RUnlock()
+	if clock.frozen != nil {
+		return *clock.frozen
+	}
+	return time.Now().Add(clock.offset)
+}
+
+// SetClock freezes the virtual clock or shifts it from the real time.
+func SetClock(config ClockConfig) error {
+	if config.Time != nil && config.Offset != "" {
+		return fmt.Errorf("the clock can have either a time or an offset")
+	}
+	var offset time.Duration
+	if config.Offset != "" {
+		var err error
+		if offset, err = time.ParseDuration(config.Offset); err != nil {
+			return fmt.Errorf("the clock offset '%s' is not a valid duration", config.Offset)
+		}
+	}
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.frozen = nil
+	if config.Time != nil {
+		frozen := config.Time.UTC()
+		clock.frozen = &frozen
+	}
+	clock.offset = offset
+	return nil
+}
+
+// GetClock returns the state of the virtual clock.
+func GetClock() ClockState {
+	state := ClockState{Now: Now().UTC()}
+	clock.mutex.RLock()
+	defer clock.mutex.RUnlock()
+	state.Frozen = clock.frozen != nil
+	if clock.offset != 0 {
+		state.Offset = clock.offset.String()
+	}
+	return state
+}
+
+// ResetClock sets the virtual clock back to the real time.
+func ResetClock() {
+	_ = SetClock(ClockConfig{})
+}
+
+// templateNow returns the time of the virtual clock in RFC 3339, the format of google.protobuf.Timestamp in JSON, or
+// in the layout given, e.g. {{now "2006-01-02"}}.
+func templateNow(layout ...string) string {
+	return formatTime(Now(), layout)
+}
+
+// templateNowAdd returns the time of the virtual clock plus the duration, e.g. {{nowAdd "-1h"}} for an hour ago.
+func templateNowAdd(duration string, layout ...string) (string, error) {
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return "", fmt.Errorf("nowAdd: '%s' is not a valid duration", duration)
+	}
+	return formatTime(Now().Add(d), layout), nil
+}
+
+// templateNowUnix returns the time of the virtual clock in seconds since the Unix epoch.
+func templateNowUnix() int64 {
+	return Now().Unix()
+}
+
+func formatTime(t time.Time, layout []string) string {
+	if len(layout) > 0 {
+		return t.UTC().Format(layout[0])
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,59 @@
+package stub
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSetClock(t *testing.T) {
+	defer ResetClock()
+	frozen := time.Date(2024, 1, 31, 23, 59, 0, 0, time.UTC)
+
+	assert.Nil(t, SetClock(ClockConfig{Time: &frozen}))
+	assert.Equal(t, frozen, Now())
+	assert.Equal(t, ClockState{Now: frozen, Frozen: true}, GetClock())
+
+	assert.Nil(t, SetClock(ClockConfig{Offset: "-24h"}))
+	assert.WithinDuration(t, time.Now().Add(-24*time.Hour), Now(), time.Minute)
+	assert.Equal(t, "-24h0m0s", GetClock().Offset)
+	assert.False(t, GetClock().Frozen)
+
+	assert.EqualError(t, SetClock(ClockConfig{Offset: "yesterday"}), "the clock offset 'yesterday' is not a valid duration")
+	assert.EqualError(t, SetClock(ClockConfig{Time: &frozen, Offset: "1h"}), "the clock can have either a time or an offset")
+
+	ResetClock()
+	assert.WithinDuration(t, time.Now(), Now(), time.Minute)
+	assert.Equal(t, "", GetClock().Offset)
+}
+
+func TestRenderTemplate_Clock(t *testing.T) {
+	defer ResetClock()
+	frozen := time.Date(2024, 1, 31, 23, 59, 0, 0, time.UTC)
+	assert.Nil(t, SetClock(ClockConfig{Time: &frozen}))
+	render := func(text string) string {
+		rendered, err := renderTemplate(context.Background(), text, `{}`)
+		assert.Nil(t, err, text)
+		return rendered
+	}
+
+	assert.Equal(t, "2024-01-31T23:59:00Z", render(`{{now}}`))
+	assert.Equal(t, "2024-01-31", render(`{{now "2006-01-02"}}`))
+	assert.Equal(t, "2024-02-01T00:59:00Z", render(`{{nowAdd "1h"}}`))
+	assert.Equal(t, "30/01/2024", render(`{{nowAdd "-24h" "02/01/2006"}}`))
+	assert.Equal(t, "1706745540", render(`{{nowUnix}}`))
+
+	_, err := renderTemplate(context.Background(), `{{nowAdd "soon"}}`, `{}`)
+	assert.NotNil(t, err)
+}
+
+func TestAuditLog_RecordClock(t *testing.T) {
+	defer ResetClock()
+	frozen := time.Date(2024, 1, 31, 23, 59, 0, 0, time.UTC)
+	assert.Nil(t, SetClock(ClockConfig{Time: &frozen}))
+
+	auditLog := NewAuditLog(0)
+	auditLog.Record(AuditEntry{Operation: AuditCreate})
+	assert.Equal(t, frozen, auditLog.GetEntries("")[0].Time)
+}
@@ -0,0 +1,165 @@
+package stub
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+)
+
+// StubCustom selects the RequestMatcher or the ResponseGenerator registered with the name, for the stubs whose request
+// matching type or response type is custom.
+type StubCustom struct {
+	Name string `json:"name"`
+	// Any JSON value, passed as is to the matcher or the generator, e.g. {"minAmount": 100}
+	Options JsonString `json:"options,omitempty"`
+}
+
+// CustomCall is the call a custom matcher compares with a stub, or a custom generator responds to.
+type CustomCall struct {
+	FullMethod string
+	// request of the call in JSON
+	Request JsonString
+	// options of the stub, see StubCustom
+	Options JsonString
+}
+
+// RequestMatcher matches the calls with the stubs whose request matching type is custom, when comparing the content is
+// not enough. The metadata of the call is in the incoming context. The metadata of the stub is compared before.
+// The calls of the methods with custom stubs are not cached, see WithMatchCache, so the matcher can depend on state.
+type RequestMatcher interface {
+	Matches(ctx context.Context, call CustomCall) bool
+}
+
+// RequestMatcherFunc is a function used as a RequestMatcher.
+type RequestMatcherFunc func(ctx context.Context, call CustomCall) bool
+
+func (f RequestMatcherFunc) Matches(ctx context.Context, call CustomCall) bool {
+	return f(ctx, call)
+}
+
+// ResponseGenerator creates the responses of the stubs whose response type is custom. It returns the content of the
+// response in JSON, or the error the call fails with, e.g. one created with the status package.
+type ResponseGenerator interface {
+	Generate(ctx context.Context, call CustomCall) (JsonString, error)
+}
+
+// ResponseGeneratorFunc is a function used as a ResponseGenerator.
+type ResponseGeneratorFunc func(ctx context.Context, call CustomCall) (JsonString, error)
+
+func (f ResponseGeneratorFunc) Generate(ctx context.Context, call CustomCall) (JsonString, error) {
+	return f(ctx, call)
+}
+
+var customHooks = struct {
+	matchers   map[string]RequestMatcher
+	generators map[string]ResponseGenerator
+	mutex      sync.RWMutex
+}{matchers: make(map[string]RequestMatcher), generators: make(map[string]ResponseGenerator)}
+
+// RegisterRequestMatcher makes the matcher available to the stubs by its name. A matcher registered with the same name
+// is replaced.
+func RegisterRequestMatcher(name string, matcher RequestMatcher) {
+	customHooks.mutex.Lock()
+	defer customHooks.mutex.Unlock()
+	customHooks.matchers[name] = matcher
+}
+
+// RegisterResponseGenerator makes the generator available to the stubs by its name. A generator registered with the
+// same name is replaced.
+func RegisterResponseGenerator(name string, generator ResponseGenerator) {
+	customHooks.mutex.Lock()
+	defer customHooks.mutex.Unlock()
+	customHooks.generators[name] = generator
+}
+
+func requestMatcher(name string) (RequestMatcher, bool) {
+	customHooks.mutex.RLock()
+	defer customHooks.mutex.RUnlock()
+	matcher, ok := customHooks.matchers[name]
+	return matcher, ok
+}
+
+func responseGenerator(name string) (ResponseGenerator, bool) {
+	customHooks.mutex.RLock()
+	defer customHooks.mutex.RUnlock()
+	generator, ok := customHooks.generators[name]
+	return generator, ok
+}
+
+// matchCustom compares the call with the candidate using the matcher of the stub. The stubs whose matcher is not
+// registered never match.
+func matchCustom(ctx context.Context, candidate matchCandidate, requestJson string) bool {
+	custom := candidate.stub.Request.Custom
+	if custom == nil || !matchMetadata(ctx, candidate.metadata) {
+		return false
+	}
+	matcher, ok := requestMatcher(custom.Name)
+	if !ok {
+		log.Errorf("The request matcher %s of the stub for %s is not registered", custom.Name, candidate.stub.FullMethod)
+		return false
+	}
+	return matcher.Matches(ctx, CustomCall{FullMethod: candidate.stub.FullMethod, Request: JsonString(requestJson), Options: custom.Options})
+}
+
+// customResponse loads in resp the response created by the generator of the stub for the call.
+func customResponse(ctx context.Context, stub *Stub, requestJson string, resp interface{}) (interface{}, error) {
+	custom := stub.Response.Custom
+	generator, ok := responseGenerator(custom.Name)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "the response generator %s of the stub for %s is not registered", custom.Name, stub.FullMethod)
+	}
+	content, err := generator.Generate(ctx, CustomCall{FullMethod: stub.FullMethod, Request: JsonString(requestJson), Options: custom.Options})
+	if err != nil {
+		return nil, err
+	}
+	if resp, err = jsonToResponse(content.String(), resp); err != nil {
+		log.WithFields(log.Fields{"Error": err.Error()}).
+			Errorf("Error handling request %s --> %s", stub.FullMethod, requestJson)
+		return nil, fmt.Errorf("could not unmarshal response")
+	}
+	log.Infof("Found CUSTOM response for %s", stub.FullMethod)
+	return resp, nil
+}
+
+func (stub *Stub) isValidCustom() (isValid bool, errMsgs []string) {
+	if stub.Request != nil {
+		switch custom := stub.Request.Custom; {
+		case stub.Request.Match != "custom" && custom != nil:
+			errMsgs = append(errMsgs, "Request custom matcher can only be set when the matching type is 'custom'.")
+		case stub.Request.Match == "custom" && (custom == nil || custom.Name == ""):
+			errMsgs = append(errMsgs, "Request custom matcher is mandatory when the matching type is 'custom'.")
+		case custom != nil:
+			if _, ok := requestMatcher(custom.Name); !ok {
+				errMsgs = append(errMsgs, fmt.Sprintf("Request custom matcher '%s' is not registered.", custom.Name))
+			}
+			errMsgs = append(errMsgs, custom.optionsErrors("Request custom matcher")...)
+		}
+	}
+	if stub.Type == "mock" && stub.Response != nil {
+		switch custom := stub.Response.Custom; {
+		case stub.Response.Type != "custom" && custom != nil:
+			errMsgs = append(errMsgs, "Response custom generator can only be set when the response type is 'custom'.")
+		case stub.Response.Type == "custom" && (custom == nil || custom.Name == ""):
+			errMsgs = append(errMsgs, "Response custom generator is mandatory when the response type is 'custom'.")
+		case custom != nil:
+			if _, ok := responseGenerator(custom.Name); !ok {
+				errMsgs = append(errMsgs, fmt.Sprintf("Response custom generator '%s' is not registered.", custom.Name))
+			}
+			errMsgs = append(errMsgs, custom.optionsErrors("Response custom generator")...)
+		}
+	}
+	return len(errMsgs) == 0, errMsgs
+}
+
+func (c *StubCustom) optionsErrors(name string) []string {
+	if c.Options == "" {
+		return nil
+	}
+	if _, err := parseJson(c.Options.String()); err != nil {
+		return []string{fmt.Sprintf("%s options are not valid JSON: %v.", name, err)}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package stub
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+)
+
+// minAgeMatcher matches the requests whose age is at least the minAge of the options of the stub.
+func minAgeMatcher(calls *int) RequestMatcher {
+	return RequestMatcherFunc(func(ctx context.Context, call CustomCall) bool {
+		*calls++
+		var request struct{ Age int }
+		var options struct{ MinAge int }
+		_ = json.Unmarshal([]byte(call.Request), &request)
+		_ = json.Unmarshal([]byte(call.Options), &options)
+		return request.Age >= options.MinAge
+	})
+}
+
+func TestStubsMatcher_Match_CustomMatcher(t *testing.T) {
+	calls := 0
+	RegisterRequestMatcher("test-min-age", minAgeMatcher(&calls))
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("tenant", "a"))
+	s := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "custom", Content: `{}`,
+		Metadata: map[string][]string{"tenant": {"a"}}, Custom: &StubCustom{Name: "test-min-age", Options: `{"minAge":18}`}}}
+	assert.Nil(t, store.Add(s))
+
+	assert.Equal(t, s, matcher.Match(ctx, "/pkg.Service/Method", `{"age":20}`))
+	assert.Nil(t, matcher.Match(ctx, "/pkg.Service/Method", `{"age":10}`))
+	assert.Nil(t, matcher.Match(context.Background(), "/pkg.Service/Method", `{"age":20}`))
+	// the calls of the methods with custom stubs are not cached
+	assert.Equal(t, s, matcher.Match(ctx, "/pkg.Service/Method", `{"age":20}`))
+	assert.Equal(t, 3, calls)
+}
+
+func TestStubsMatcher_Match_CustomMatcherNotRegistered(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store)
+	s := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "custom", Content: `{}`,
+		Custom: &StubCustom{Name: "test-unknown"}}}
+	assert.Nil(t, store.Add(s))
+
+	assert.Nil(t, matcher.Match(context.Background(), "/pkg.Service/Method", `{"age":20}`))
+}
+
+func TestStubsMatcher_Match_CustomMatcherProto(t *testing.T) {
+	registerFieldMaskFile(t)
+	calls := 0
+	RegisterRequestMatcher("test-min-age", minAgeMatcher(&calls))
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store, WithProtoMatching())
+	custom := &Stub{FullMethod: fieldMaskMethod, Type: "mock", Request: &StubRequest{Match: "custom", Content: `{}`,
+		Custom: &StubCustom{Name: "test-min-age", Options: `{"minAge":18}`}}}
+	assert.Nil(t, store.Add(custom))
+
+	assert.Equal(t, custom, matcher.Match(context.Background(), fieldMaskMethod, `{"name":"Mary","age":30}`))
+	assert.Nil(t, matcher.Match(context.Background(), fieldMaskMethod, `{"name":"Mary","age":10}`))
+}
+
+func TestGetCallResponse_CustomGenerator(t *testing.T) {
+	RegisterResponseGenerator("test-echo", ResponseGeneratorFunc(func(ctx context.Context, call CustomCall) (JsonString, error) {
+		if call.Request == `"fail"` {
+			return "", status.Error(codes.FailedPrecondition, "failed")
+		}
+		return JsonString(`"` + call.FullMethod + " " + call.Options.String() + `"`), nil
+	}))
+	s := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "partial", Content: `{}`},
+		Response: &StubResponse{Type: "custom", Custom: &StubCustom{Name: "test-echo", Options: `1`}}}
+
+	resp, err := GetCallResponse(context.Background(), s, `"hello"`, &wrapperspb.StringValue{})
+	assert.Nil(t, err)
+	assert.Equal(t, "/pkg.Service/Method 1", resp.(*wrapperspb.StringValue).Value)
+
+	_, err = GetCallResponse(context.Background(), s, `"fail"`, &wrapperspb.StringValue{})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	s.Response.Custom.Name = "test-unknown"
+	_, err = GetCallResponse(context.Background(), s, `"hello"`, &wrapperspb.StringValue{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestStub_IsValid_Custom(t *testing.T) {
+	RegisterRequestMatcher("test-valid", RequestMatcherFunc(func(context.Context, CustomCall) bool { return true }))
+	RegisterResponseGenerator("test-valid", ResponseGeneratorFunc(func(context.Context, CustomCall) (JsonString, error) { return "{}", nil }))
+	newStub := func(request *StubRequest, response *StubResponse) *Stub {
+		request.Content = "{}"
+		return &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: request, Response: response}
+	}
+	success := &StubResponse{Type: "success", Content: "{}"}
+	tests := []struct {
+		name   string
+		stub   *Stub
+		errors []string
+	}{
+		{"custom matcher and generator", newStub(&StubRequest{Match: "custom", Custom: &StubCustom{Name: "test-valid", Options: `{"a":1}`}},
+			&StubResponse{Type: "custom", Custom: &StubCustom{Name: "test-valid"}}), nil},
+		{"missing matcher", newStub(&StubRequest{Match: "custom"}, success),
+			[]string{"Request custom matcher is mandatory when the matching type is 'custom'."}},
+		{"matcher not registered", newStub(&StubRequest{Match: "custom", Custom: &StubCustom{Name: "test-unknown"}}, success),
+			[]string{"Request custom matcher 'test-unknown' is not registered."}},
+		{"matcher of a partial stub", newStub(&StubRequest{Match: "partial", Custom: &StubCustom{Name: "test-valid"}}, success),
+			[]string{"Request custom matcher can only be set when the matching type is 'custom'."}},
+		{"invalid options", newStub(&StubRequest{Match: "custom", Custom: &StubCustom{Name: "test-valid", Options: `{`}}, success),
+			[]string{"Request custom matcher options are not valid JSON: unexpected EOF."}},
+		{"missing generator", newStub(&StubRequest{Match: "partial"}, &StubResponse{Type: "custom"}),
+			[]string{"Response custom generator is mandatory when the response type is 'custom'."}},
+		{"generator not registered", newStub(&StubRequest{Match: "partial"}, &StubResponse{Type: "custom", Custom: &StubCustom{Name: "test-unknown"}}),
+			[]string{"Response custom generator 'test-unknown' is not registered."}},
+		{"generator of a success response", newStub(&StubRequest{Match: "partial"}, &StubResponse{Type: "success", Content: "{}", Custom: &StubCustom{Name: "test-valid"}}),
+			[]string{"Response custom generator can only be set when the response type is 'custom'."}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			isValid, errors := test.stub.IsValid()
+			assert.Equal(t, len(test.errors) == 0, isValid)
+			assert.Equal(t, test.errors, errors)
+		})
+	}
+}
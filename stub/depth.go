@@ -0,0 +1,48 @@
+package stub
+
+import "sync/atomic"
+
+// DefaultMaxContentDepth is the maximum nesting of the messages of the content of the stubs by default. It is far above
+// the nesting of most messages while keeping the comparisons of self-referential messages, e.g. trees, bounded.
+const DefaultMaxContentDepth = 100
+
+var maxContentDepth int32 = DefaultMaxContentDepth
+
+// SetMaxContentDepth changes the maximum nesting of the messages of the content of the stubs, the top-level message being
+// at depth 1. The stubs nested deeper are rejected and the requests are not compared with the stubs beyond it. Zero or
+// less restores DefaultMaxContentDepth.
+func SetMaxContentDepth(depth int) {
+	if depth <= 0 {
+		depth = DefaultMaxContentDepth
+	}
+	atomic.StoreInt32(&maxContentDepth, int32(depth))
+}
+
+// MaxContentDepth returns the maximum nesting of the messages of the content of the stubs.
+func MaxContentDepth() int {
+	return int(atomic.LoadInt32(&maxContentDepth))
+}
+
+// isContentDepthExceeded tells whether the JSON value has objects nested deeper than the maximum depth. depth is the
+// depth of the object containing the value, 0 for the content itself. The arrays are not a level, as the repeated fields
+// are in the message containing them.
+func isContentDepthExceeded(value interface{}, depth int) bool {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if depth >= MaxContentDepth() {
+			return true
+		}
+		for _, field := range value {
+			if isContentDepthExceeded(field, depth+1) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if isContentDepthExceeded(item, depth) {
+				return true
+			}
+		}
+	}
+	return false
+}
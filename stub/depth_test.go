@@ -0,0 +1,100 @@
+package stub
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"testing"
+)
+
+// newTestNodeDescriptor creates the descriptor of a self-referential message: message Node { string name = 1; Node child = 2; repeated Node children = 3; }
+func newTestNodeDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	child := newTestField("child", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, false)
+	child.TypeName = proto.String(".depth.Node")
+	children := newTestField("children", 3, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, false)
+	children.TypeName, children.Label = proto.String(".depth.Node"), descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("depth_test.proto"),
+		Package: proto.String("depth"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name:  proto.String("Node"),
+			Field: []*descriptorpb.FieldDescriptorProto{newTestField("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, false), child, children},
+		}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file.Messages().Get(0)
+}
+
+func TestStub_IsValid_ContentDepth(t *testing.T) {
+	defer SetMaxContentDepth(0)
+	SetMaxContentDepth(3)
+	newStub := func(request, response JsonString) *Stub {
+		return &Stub{FullMethod: "/depth.Service/Method", Type: "mock", Request: &StubRequest{Match: "partial", Content: request}, Response: &StubResponse{Type: "success", Content: response}}
+	}
+
+	isValid, errMsgs := newStub(`{"child":{"child":{"name":"a"}}}`, `{"children":[{"children":[{"name":"a"}]}]}`).IsValid()
+	assert.True(t, isValid)
+	assert.Empty(t, errMsgs)
+
+	isValid, errMsgs = newStub(`{"child":{"child":{"child":{}}}}`, `{"children":[{"children":[{"child":{}}]}]}`).IsValid()
+	assert.False(t, isValid)
+	assert.Equal(t, []string{
+		"Request content is nested deeper than the maximum depth of 3.",
+		"Response content is nested deeper than the maximum depth of 3.",
+	}, errMsgs)
+}
+
+func TestJsonString_Matches_ContentDepth(t *testing.T) {
+	defer SetMaxContentDepth(0)
+	SetMaxContentDepth(2)
+	request := JsonString(`{"child":{"name":"a","child":{"child":{"name":"b"}}}}`)
+
+	content := JsonString(`{"child":{"name":"a"}}`)
+	assert.True(t, content.Matches(request))
+	content = `{"child":{"child":{"child":{"name":"b"}}}}`
+	assert.False(t, content.Matches(request))
+	assert.False(t, request.Equals(request))
+
+	SetMaxContentDepth(0)
+	assert.True(t, content.Matches(request))
+	assert.True(t, request.Equals(request))
+}
+
+func TestProtoMessageMatches_ContentDepth(t *testing.T) {
+	defer SetMaxContentDepth(0)
+	SetMaxContentDepth(2)
+	descriptor := newTestNodeDescriptor(t)
+	request, err := unmarshalProto(`{"child":{"name":"a","child":{"child":{"name":"b"}}}}`, descriptor)
+	assert.Nil(t, err)
+
+	content, err := unmarshalProto(`{"child":{"name":"a"}}`, descriptor)
+	assert.Nil(t, err)
+	assert.True(t, protoMessageMatches(content, request, 1))
+	content, err = unmarshalProto(`{"children":[{"child":{"name":"b"}}]}`, descriptor)
+	assert.Nil(t, err)
+	assert.False(t, protoMessageMatches(content, content, 1))
+}
+
+func TestCreateStubContent_ContentDepth(t *testing.T) {
+	defer SetMaxContentDepth(0)
+	descriptor := newTestNodeDescriptor(t)
+
+	content, err := CreateStubContent(descriptor)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"name":"name","child":{"name":"","child":null,"children":[]},"children":[{"name":"","child":null,"children":[]}]}`, content)
+
+	SetMaxContentDepth(1)
+	content, err = CreateStubContent(descriptor)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"name":"name","child":null,"children":[]}`, content)
+	var parsed interface{}
+	assert.Nil(t, json.Unmarshal([]byte(content), &parsed))
+	assert.False(t, isContentDepthExceeded(parsed, 0))
+}
@@ -0,0 +1,199 @@
+package stub
+
+import (
+	"encoding/json"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+// emptyArray replaces the empty arrays in the content of the partial stubs whose empty arrays match the calls without
+// items, see StubRequest.EmptyArrays. It matches a missing field or an empty array.
+type emptyArray struct{}
+
+func (emptyArray) MarshalJSON() ([]byte, error) {
+	return []byte("[]"), nil
+}
+
+// prepareEmptyArrays removes the fields of the objects of the JSON value set to an empty array, as protojson never writes
+// them, so that an empty array is the same as a missing field whatever the client sent. The fields are replaced by
+// emptyArray instead when mark is true.
+func prepareEmptyArrays(value interface{}, mark bool) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if items, ok := field.([]interface{}); ok && len(items) == 0 {
+				if mark {
+					value[key] = emptyArray{}
+				} else {
+					delete(value, key)
+				}
+				continue
+			}
+			value[key] = prepareEmptyArrays(field, mark)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = prepareEmptyArrays(item, mark)
+		}
+	}
+	return value
+}
+
+// emptyArraysCovered tells whether the empty arrays of the content of a partial stub are also empty arrays in the content
+// of other, so that other only matches the calls without items for them too. It is false when an array of the content
+// contains empty arrays, as its items are matched in any order.
+func emptyArraysCovered(content, other interface{}) bool {
+	object, ok := content.(map[string]interface{})
+	if !ok {
+		return true
+	}
+	otherObject, _ := other.(map[string]interface{})
+	for key, value := range object {
+		switch value := value.(type) {
+		case emptyArray:
+			if _, ok := otherObject[key].(emptyArray); !ok {
+				return false
+			}
+		case map[string]interface{}:
+			if !emptyArraysCovered(value, otherObject[key]) {
+				return false
+			}
+		case []interface{}:
+			if hasEmptyArrays(value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func hasEmptyArrays(value interface{}) bool {
+	switch value := value.(type) {
+	case emptyArray:
+		return true
+	case map[string]interface{}:
+		for _, field := range value {
+			if hasEmptyArrays(field) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if hasEmptyArrays(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// protoEmptyArraysMatch tells whether the repeated fields of the message are empty where the content of the stub has an
+// empty array. The items of the repeated fields are in order, as the proto matching compares them.
+func protoEmptyArraysMatch(content interface{}, message protoreflect.Message) bool {
+	object, ok := content.(map[string]interface{})
+	if !ok {
+		return true
+	}
+	for key, value := range object {
+		field := fieldByJsonName(message.Descriptor(), key)
+		if field == nil || field.IsMap() {
+			continue
+		}
+		switch value := value.(type) {
+		case emptyArray:
+			if field.IsList() && message.Get(field).List().Len() > 0 {
+				return false
+			}
+		case map[string]interface{}:
+			if field.Message() != nil && !field.IsList() && !isWellKnownType(field.Message()) &&
+				!protoEmptyArraysMatch(value, message.Get(field).Message()) {
+				return false
+			}
+		case []interface{}:
+			if !field.IsList() || field.Message() == nil || isWellKnownType(field.Message()) {
+				continue
+			}
+			list := message.Get(field).List()
+			for i := 0; i < len(value) && i < list.Len(); i++ {
+				if !protoEmptyArraysMatch(value[i], list.Get(i).Message()) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// RestoreEmptyArrays adds back to the cleaned content of a request the empty arrays of its original content, removed when
+// it was formatted as protojson marshals the messages, so that a stub whose empty arrays match the calls without items
+// keeps them. The fields of the original content are found in the message with their JSON name or their name in the
+// proto file. The cleaned content is returned as is when the original content has no empty arrays.
+func RestoreEmptyArrays(original, cleaned JsonString, descriptor protoreflect.MessageDescriptor) (JsonString, error) {
+	originalContent, err := parseJson(original.String())
+	if err != nil {
+		return cleaned, err
+	}
+	if !hasEmptyArrays(prepareEmptyArrays(originalContent, true)) {
+		return cleaned, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(cleaned.String()))
+	decoder.UseNumber()
+	cleanedContent := make(map[string]interface{})
+	if err := decoder.Decode(&cleanedContent); err != nil {
+		return cleaned, err
+	}
+	restoreEmptyArrays(originalContent, cleanedContent, descriptor)
+	data, err := json.Marshal(cleanedContent)
+	if err != nil {
+		return cleaned, err
+	}
+	return JsonString(data), nil
+}
+
+func restoreEmptyArrays(original interface{}, cleaned map[string]interface{}, descriptor protoreflect.MessageDescriptor) {
+	object, ok := original.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for key, value := range object {
+		field := fieldByJsonName(descriptor, key)
+		if field == nil || field.IsMap() {
+			continue
+		}
+		name := field.JSONName()
+		switch value := value.(type) {
+		case emptyArray:
+			if field.IsList() {
+				cleaned[name] = make([]interface{}, 0)
+			}
+		case map[string]interface{}:
+			if field.Message() == nil || field.IsList() || isWellKnownType(field.Message()) {
+				continue
+			}
+			if cleanedValue, ok := cleaned[name].(map[string]interface{}); ok {
+				restoreEmptyArrays(value, cleanedValue, field.Message())
+			}
+		case []interface{}:
+			if !field.IsList() || field.Message() == nil || isWellKnownType(field.Message()) {
+				continue
+			}
+			cleanedItems, ok := cleaned[name].([]interface{})
+			if !ok || len(cleanedItems) != len(value) {
+				continue
+			}
+			for i, item := range value {
+				if cleanedItem, ok := cleanedItems[i].(map[string]interface{}); ok {
+					restoreEmptyArrays(item, cleanedItem, field.Message())
+				}
+			}
+		}
+	}
+}
+
+// fieldByJsonName returns the field with the JSON name or the name in the proto file, as protojson reads them.
+func fieldByJsonName(descriptor protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if field := descriptor.Fields().ByJSONName(name); field != nil {
+		return field
+	}
+	return descriptor.Fields().ByName(protoreflect.Name(name))
+}
@@ -0,0 +1,89 @@
+package stub
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"testing"
+)
+
+func TestJsonString_Equals_EmptyArrays(t *testing.T) {
+	str1 := JsonString(`{"name":"John","ids":[],"items":[{"tags":[]}]}`)
+	assert.True(t, str1.Equals(`{"name":"John","items":[{}]}`))
+	assert.True(t, str1.Matches(`{"name":"John","ids":[1],"items":[{"tags":["a"]}]}`))
+	str2 := JsonString(`{"name":"John"}`)
+	assert.True(t, str2.Equals(`{"name":"John","ids":[]}`))
+}
+
+func TestStubsMatcher_Match_EmptyArrays(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store)
+	ctx := context.Background()
+	exact := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "exact", Content: `{"name":"John","ids":[]}`}}
+	absent := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "partial", Content: `{"name":"Jane","ids":[]}`}}
+	empty := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "partial", Content: `{"name":"Joe","ids":[],"items":[{"tags":[]}]}`, EmptyArrays: "empty"}}
+	for _, s := range []*Stub{exact, absent, empty} {
+		assert.Nil(t, store.Add(s))
+	}
+
+	// an empty array is the same as a missing field, in the stubs and in the calls
+	assert.Equal(t, exact, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"John"}`))
+	assert.Equal(t, exact, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"John","ids":[]}`))
+	assert.Nil(t, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"John","ids":[1]}`))
+	assert.Equal(t, absent, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"Jane","ids":[1]}`))
+	assert.Equal(t, absent, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"Jane"}`))
+
+	// the empty arrays of the stub only match the calls without items
+	assert.Equal(t, empty, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"Joe","items":[{"name":"a"}]}`))
+	assert.Equal(t, empty, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"Joe","ids":[],"items":[{"tags":[]}]}`))
+	assert.Nil(t, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"Joe","ids":[1],"items":[{}]}`))
+	assert.Nil(t, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"Joe","items":[{"tags":["a"]}]}`))
+}
+
+func TestStubsMatcher_Match_ProtoMatchingEmptyArrays(t *testing.T) {
+	registerProtoMatchingFile(t)
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store, WithProtoMatching())
+	ctx := context.Background()
+	absent := &Stub{FullMethod: protoMatchingMethod, Type: "mock", Request: &StubRequest{Match: "partial", Content: `{"total":"1","ids":[]}`}}
+	empty := &Stub{FullMethod: protoMatchingMethod, Type: "mock", Request: &StubRequest{Match: "partial", Content: `{"total":"2","child":{"ids":[]}}`, EmptyArrays: "empty"}}
+	assert.Nil(t, store.Add(absent))
+	assert.Nil(t, store.Add(empty))
+
+	assert.Equal(t, absent, matcher.Match(ctx, protoMatchingMethod, `{"total":"1","ids":[1]}`))
+	assert.Equal(t, empty, matcher.Match(ctx, protoMatchingMethod, `{"total":"2","child":{"total":"3"}}`))
+	assert.Nil(t, matcher.Match(ctx, protoMatchingMethod, `{"total":"2","child":{"ids":[1]}}`))
+}
+
+func TestRestoreEmptyArrays(t *testing.T) {
+	registerProtoMatchingFile(t)
+	descriptor, err := protoregistry.GlobalFiles.FindDescriptorByName("pkg.protomatching.Request")
+	assert.Nil(t, err)
+	messageDescriptor := descriptor.(protoreflect.MessageDescriptor)
+
+	restored, err := RestoreEmptyArrays(`{"ids":[],"child":{"ids":[],"total":1},"labels":{}}`, `{"child":{"total":"1"}}`, messageDescriptor)
+	assert.Nil(t, err)
+	assert.Equal(t, JsonString(`{"child":{"ids":[],"total":"1"},"ids":[]}`), restored)
+
+	restored, err = RestoreEmptyArrays(`{"total":1}`, `{"total":"1"}`, messageDescriptor)
+	assert.Nil(t, err)
+	assert.Equal(t, JsonString(`{"total":"1"}`), restored)
+}
+
+func TestStub_IsValid_EmptyArrays(t *testing.T) {
+	s := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &StubRequest{Match: "partial", Content: `{"ids":[]}`, EmptyArrays: "empty"},
+		Response:   &StubResponse{Type: "success", Content: `{}`},
+	}
+	isValid, errMsgs := s.IsValid()
+	assert.True(t, isValid)
+	assert.Empty(t, errMsgs)
+
+	s.Request.EmptyArrays = "missing"
+	isValid, errMsgs = s.IsValid()
+	assert.False(t, isValid)
+	assert.Contains(t, errMsgs, "Request empty arrays can only be either 'absent' or 'empty'.")
+}
@@ -70,7 +70,8 @@ func (e *customErrorEngine) createErrorType(spec *ErrorDetailsSpec) (customErr *
 }
 
 func generateHash(spec *ErrorDetailsSpec) string {
-	str := spec.Import + spec.Type
+	// separated so that different imports and types don't produce the same hash
+	str := getKey(spec)
 	h := sha1.New()
 	h.Write([]byte(str))
 	bs := h.Sum(nil)
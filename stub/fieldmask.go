@@ -0,0 +1,220 @@
+package stub
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+// StubFieldMask restricts the fields of the request compared with the stub to the ones of a google.protobuf.FieldMask,
+// e.g. to stub the update calls by the fields they update. The mask is either in the stub or in a field of the calls.
+type StubFieldMask struct {
+	// Fields compared, separated by commas as in the JSON form of a FieldMask, e.g. name,address.city
+	Paths string `json:"paths,omitempty"`
+	// Field of the request holding the FieldMask of the fields compared, e.g. updateMask. The field itself is also
+	// compared, and all the fields are when the call has no mask.
+	Field string `json:"field,omitempty"`
+}
+
+const fieldMaskType = "google.protobuf.FieldMask"
+
+// fieldMask is the tree of the fields selected by a FieldMask, by JSON name. A field without subfields is selected with
+// all its subfields.
+type fieldMask map[string]fieldMask
+
+// newFieldMask returns the mask of the paths, written with the JSON or the proto names of the fields.
+func newFieldMask(paths []string) fieldMask {
+	mask := make(fieldMask)
+	for _, path := range paths {
+		if path = strings.TrimSpace(path); path != "" {
+			mask.add(path)
+		}
+	}
+	if len(mask) == 0 {
+		return nil
+	}
+	return mask
+}
+
+func (f fieldMask) add(path string) {
+	node := f
+	segments := strings.Split(path, ".")
+	for i, segment := range segments {
+		name := jsonCamelCase(segment)
+		child, ok := node[name]
+		if ok && len(child) == 0 {
+			// the field is already selected with all its subfields
+			return
+		}
+		if i == len(segments)-1 {
+			node[name] = fieldMask{}
+			return
+		}
+		if !ok {
+			child = make(fieldMask)
+			node[name] = child
+		}
+		node = child
+	}
+}
+
+// applyJson returns a copy of the JSON value with only the fields of the mask. The values that are not objects are
+// returned as is.
+func (f fieldMask) applyJson(value interface{}) interface{} {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	masked := make(map[string]interface{}, len(f))
+	for name, child := range f {
+		field, ok := object[name]
+		if !ok {
+			continue
+		}
+		if len(child) > 0 {
+			field = child.applyJson(field)
+		}
+		masked[name] = field
+	}
+	return masked
+}
+
+// applyProto returns a copy of the message with only the fields of the mask set.
+func (f fieldMask) applyProto(message protoreflect.Message) protoreflect.Message {
+	masked := proto.Clone(message.Interface()).ProtoReflect()
+	f.clearProto(masked)
+	return masked
+}
+
+func (f fieldMask) clearProto(message protoreflect.Message) {
+	cleared := make([]protoreflect.FieldDescriptor, 0)
+	message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		child, ok := f[field.JSONName()]
+		switch {
+		case !ok:
+			cleared = append(cleared, field)
+		case len(child) > 0 && field.Message() != nil && !field.IsList() && !field.IsMap():
+			child.clearProto(value.Message())
+		}
+		return true
+	})
+	for _, field := range cleared {
+		message.Clear(field)
+	}
+}
+
+// jsonFieldMask returns the mask of the field of the request parsed as JSON, or nil when the request has no mask.
+func jsonFieldMask(request interface{}, field string) fieldMask {
+	object, ok := request.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	field = jsonCamelCase(field)
+	paths, ok := object[field].(string)
+	if !ok {
+		return nil
+	}
+	mask := newFieldMask(strings.Split(paths, ","))
+	if mask != nil {
+		mask.add(field)
+	}
+	return mask
+}
+
+// protoFieldMask returns the mask of the field of the request message, or nil when the request has no mask.
+func protoFieldMask(request protoreflect.Message, field string) fieldMask {
+	descriptor := fieldByJsonName(request.Descriptor(), field)
+	if descriptor == nil || descriptor.Message() == nil || descriptor.Message().FullName() != fieldMaskType || !request.Has(descriptor) {
+		return nil
+	}
+	mask := request.Get(descriptor).Message()
+	list := mask.Get(mask.Descriptor().Fields().ByName("paths")).List()
+	paths := make([]string, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		paths = append(paths, list.Get(i).String())
+	}
+	masked := newFieldMask(paths)
+	if masked != nil {
+		masked.add(descriptor.JSONName())
+	}
+	return masked
+}
+
+// requestFieldMask returns the mask of the fields of the request compared with the candidate, or nil when all the fields
+// are compared.
+func (c matchCandidate) requestFieldMask(request interface{}) fieldMask {
+	if c.fieldMask != nil || c.stub.Request.FieldMask == nil {
+		return c.fieldMask
+	}
+	return jsonFieldMask(request, c.stub.Request.FieldMask.Field)
+}
+
+// protoRequestFieldMask is requestFieldMask for the request parsed in its message.
+func (c matchCandidate) protoRequestFieldMask(request protoreflect.Message) fieldMask {
+	if c.fieldMask != nil || c.stub.Request.FieldMask == nil {
+		return c.fieldMask
+	}
+	return protoFieldMask(request, c.stub.Request.FieldMask.Field)
+}
+
+// jsonCamelCase returns the JSON name of a field from its proto name, e.g. updateMask for update_mask, as protojson
+// converts the paths of a FieldMask. JSON names are returned as is.
+func jsonCamelCase(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, c := range name {
+		if c == '_' {
+			upper = true
+			continue
+		}
+		if upper && 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (stub *Stub) isValidFieldMask() (isValid bool, errMsgs []string) {
+	if stub.Request == nil || stub.Request.FieldMask == nil {
+		return true, nil
+	}
+	if (stub.Request.FieldMask.Paths == "") == (stub.Request.FieldMask.Field == "") {
+		errMsgs = append(errMsgs, "Request field mask must have either paths or field.")
+	}
+	return len(errMsgs) == 0, errMsgs
+}
+
+// fieldMaskErrors checks that the paths and the field of the mask of the stub are fields of the request message.
+func (m *StubFieldMask) fieldMaskErrors(t protoreflect.MessageDescriptor) (errorMessages []string) {
+	if m.Field != "" {
+		field := fieldByJsonName(t, jsonCamelCase(m.Field))
+		if field == nil || field.Message() == nil || field.Message().FullName() != fieldMaskType {
+			errorMessages = append(errorMessages, fmt.Sprintf("Field '%s' of request.fieldMask.field is not a %s.", m.Field, fieldMaskType))
+		}
+	}
+	if m.Paths == "" {
+		return errorMessages
+	}
+	for _, path := range strings.Split(m.Paths, ",") {
+		path = strings.TrimSpace(path)
+		descriptor := t
+		for _, segment := range strings.Split(path, ".") {
+			var field protoreflect.FieldDescriptor
+			if descriptor != nil {
+				field = fieldByJsonName(descriptor, jsonCamelCase(segment))
+			}
+			if field == nil {
+				errorMessages = append(errorMessages, fmt.Sprintf("Path '%s' of request.fieldMask.paths does not exist.", path))
+				break
+			}
+			descriptor = nil
+			if field.Message() != nil && !field.IsList() && !field.IsMap() {
+				descriptor = field.Message()
+			}
+		}
+	}
+	return errorMessages
+}
@@ -0,0 +1,143 @@
+package stub
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	_ "google.golang.org/protobuf/types/known/fieldmaskpb"
+	"sync"
+	"testing"
+)
+
+const fieldMaskMethod = "/pkg.fieldmask.Service/Update"
+
+var registerFieldMaskService sync.Once
+
+// registerFieldMaskFile registers a service whose request has a field mask, and returns the descriptor of the request.
+func registerFieldMaskFile(t *testing.T) protoreflect.MessageDescriptor {
+	registerFieldMaskService.Do(func() {
+		child := newTestMessageField("child", 3, ".pkg.fieldmask.Request")
+		updateMask := newTestMessageField("update_mask", 4, ".google.protobuf.FieldMask")
+		updateMask.JsonName = proto.String("updateMask")
+		file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+			Name:       proto.String("fieldmask.proto"),
+			Package:    proto.String("pkg.fieldmask"),
+			Syntax:     proto.String("proto3"),
+			Dependency: []string{"google/protobuf/field_mask.proto"},
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("Request"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					newTestField("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, false),
+					newTestField("age", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, false),
+					child, updateMask,
+				},
+			}},
+			Service: []*descriptorpb.ServiceDescriptorProto{{
+				Name: proto.String("Service"),
+				Method: []*descriptorpb.MethodDescriptorProto{{
+					Name:       proto.String("Update"),
+					InputType:  proto.String(".pkg.fieldmask.Request"),
+					OutputType: proto.String(".pkg.fieldmask.Request"),
+				}},
+			}},
+		}, protoregistry.GlobalFiles)
+		if err == nil {
+			err = protoregistry.GlobalFiles.RegisterFile(file)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	descriptor, err := protoregistry.GlobalFiles.FindDescriptorByName("pkg.fieldmask.Request")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return descriptor.(protoreflect.MessageDescriptor)
+}
+
+func TestStubsMatcher_Match_FieldMaskPaths(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store)
+	ctx := context.Background()
+	s := &Stub{FullMethod: fieldMaskMethod, Type: "mock", Request: &StubRequest{Match: "exact",
+		Content: `{"name":"John","age":2,"child":{"name":"Mary","age":1}}`, FieldMask: &StubFieldMask{Paths: "name, child.name"}}}
+	assert.Nil(t, store.Add(s))
+
+	assert.Equal(t, s, matcher.Match(ctx, fieldMaskMethod, `{"name":"John","age":5,"child":{"name":"Mary"},"updateMask":"age"}`))
+	assert.Nil(t, matcher.Match(ctx, fieldMaskMethod, `{"name":"John","age":2}`))
+	assert.Nil(t, matcher.Match(ctx, fieldMaskMethod, `{"name":"John","age":2,"child":{"name":"Jane","age":1}}`))
+}
+
+func TestStubsMatcher_Match_FieldMaskOfTheRequest(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store)
+	ctx := context.Background()
+	exact := &Stub{FullMethod: fieldMaskMethod, Type: "mock", Request: &StubRequest{Match: "exact",
+		Content: `{"name":"John","age":2,"updateMask":"name"}`, FieldMask: &StubFieldMask{Field: "update_mask"}}}
+	partial := &Stub{FullMethod: fieldMaskMethod, Type: "mock", Request: &StubRequest{Match: "partial",
+		Content: `{"name":"Jane","child":{"age":1}}`, FieldMask: &StubFieldMask{Field: "updateMask"}}}
+	assert.Nil(t, store.Add(exact))
+	assert.Nil(t, store.Add(partial))
+
+	// only the fields of the mask of the call, and the mask, are compared
+	assert.Equal(t, exact, matcher.Match(ctx, fieldMaskMethod, `{"name":"John","age":5,"updateMask":"name"}`))
+	assert.Nil(t, matcher.Match(ctx, fieldMaskMethod, `{"name":"John","age":2,"updateMask":"name,age"}`))
+	assert.Equal(t, partial, matcher.Match(ctx, fieldMaskMethod, `{"name":"Jane","child":{"name":"Mary","age":1},"updateMask":"child.age,name"}`))
+	assert.Equal(t, partial, matcher.Match(ctx, fieldMaskMethod, `{"name":"Jane","child":{"age":2},"updateMask":"name"}`))
+	assert.Nil(t, matcher.Match(ctx, fieldMaskMethod, `{"name":"Jane","child":{"age":2},"updateMask":"name,child"}`))
+
+	// all the fields are compared without mask
+	assert.Equal(t, partial, matcher.Match(ctx, fieldMaskMethod, `{"name":"Jane","child":{"age":1}}`))
+	assert.Nil(t, matcher.Match(ctx, fieldMaskMethod, `{"name":"Jane"}`))
+}
+
+func TestStubsMatcher_Match_ProtoMatchingFieldMask(t *testing.T) {
+	registerFieldMaskFile(t)
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store, WithProtoMatching())
+	ctx := context.Background()
+	fromRequest := &Stub{FullMethod: fieldMaskMethod, Type: "mock", Request: &StubRequest{Match: "exact",
+		Content: `{"name":"John","age":2,"updateMask":"name"}`, FieldMask: &StubFieldMask{Field: "update_mask"}}}
+	fromStub := &Stub{FullMethod: fieldMaskMethod, Type: "mock", Request: &StubRequest{Match: "partial",
+		Content: `{"name":"Jane","child":{"name":"Mary","age":1}}`, FieldMask: &StubFieldMask{Paths: "child.age"}}}
+	assert.Nil(t, store.Add(fromRequest))
+	assert.Nil(t, store.Add(fromStub))
+
+	assert.Equal(t, fromRequest, matcher.Match(ctx, fieldMaskMethod, `{"name":"John","age":5,"updateMask":"name"}`))
+	assert.Equal(t, fromStub, matcher.Match(ctx, fieldMaskMethod, `{"name":"Joe","child":{"age":1}}`))
+	assert.Nil(t, matcher.Match(ctx, fieldMaskMethod, `{"name":"Jane","child":{"name":"Mary","age":2}}`))
+}
+
+func TestIsStubValid_FieldMask(t *testing.T) {
+	descriptor := registerFieldMaskFile(t)
+	s := &Stub{
+		FullMethod: fieldMaskMethod,
+		Type:       "mock",
+		Request:    &StubRequest{Match: "exact", Content: `{"name":"John"}`, FieldMask: &StubFieldMask{Paths: "name,child.age"}},
+		Response:   &StubResponse{Type: "success", Content: `{}`},
+	}
+	isValid, errMsgs := IsStubValid(s, descriptor, descriptor)
+	assert.True(t, isValid, errMsgs)
+
+	s.Request.FieldMask = &StubFieldMask{Paths: "nmae,child.age.value"}
+	isValid, errMsgs = IsStubValid(s, descriptor, descriptor)
+	assert.False(t, isValid)
+	assert.Equal(t, []string{
+		"Path 'nmae' of request.fieldMask.paths does not exist.",
+		"Path 'child.age.value' of request.fieldMask.paths does not exist.",
+	}, errMsgs)
+
+	s.Request.FieldMask = &StubFieldMask{Field: "name"}
+	isValid, errMsgs = IsStubValid(s, descriptor, descriptor)
+	assert.False(t, isValid)
+	assert.Equal(t, []string{"Field 'name' of request.fieldMask.field is not a google.protobuf.FieldMask."}, errMsgs)
+
+	s.Request.FieldMask = &StubFieldMask{Paths: "name", Field: "updateMask"}
+	isValid, errMsgs = s.IsValid()
+	assert.False(t, isValid)
+	assert.Contains(t, errMsgs, "Request field mask must have either paths or field.")
+}
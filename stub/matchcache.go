@@ -0,0 +1,126 @@
+package stub
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"google.golang.org/grpc/metadata"
+	"sort"
+	"sync"
+)
+
+// DefaultMatchCacheSize is the number of calls whose matching stub is kept by the stubs matchers, see WithMatchCache.
+const DefaultMatchCacheSize = 1000
+
+// WithMatchCache keeps the stub matched by the last size different calls, by method, request and metadata, so that the
+// calls repeated, e.g. in soak tests, are not compared with the stubs again. The stubs matched for a method are discarded
+// when its stubs change. The cache is disabled when size is 0.
+func WithMatchCache(size int) MatcherOption {
+	return func(m *stubsMatcher) {
+		m.resolutions = nil
+		if size > 0 {
+			m.resolutions = &matchCache{size: size, entries: make(map[matchKey]*list.Element), order: list.New()}
+		}
+	}
+}
+
+// resolve returns the stub matching the call, found with match or else the one cached for the same call, and records
+// the match. The calls of the methods with custom stubs are always matched again, as their matchers can depend on state.
+func (m *stubsMatcher) resolve(ctx context.Context, fullMethod string, candidates *methodCandidates, request []byte, match func() *Stub) *Stub {
+	var stub *Stub
+	if m.resolutions != nil && request != nil && !candidates.custom {
+		key := newMatchKey(ctx, fullMethod, candidates, request)
+		var found bool
+		if stub, found = m.resolutions.get(key, candidates); !found {
+			stub = match()
+			m.resolutions.add(key, candidates, stub)
+		}
+	} else {
+		stub = match()
+	}
+	if stub == nil {
+		return nil
+	}
+	return m.recordMatch(stub)
+}
+
+// matchKey identifies the calls matching the same stub: the request in canonical form and the values of the metadata
+// keys of the stubs of the method are hashed.
+type matchKey struct {
+	fullMethod string
+	request    [sha256.Size]byte
+	metadata   [sha256.Size]byte
+}
+
+func newMatchKey(ctx context.Context, fullMethod string, candidates *methodCandidates, request []byte) matchKey {
+	key := matchKey{fullMethod: fullMethod, request: sha256.Sum256(request)}
+	if len(candidates.metadataKeys) > 0 {
+		md, _ := metadata.FromIncomingContext(ctx)
+		hash := sha256.New()
+		for _, name := range candidates.metadataKeys {
+			values := append([]string{}, md.Get(name)...)
+			sort.Strings(values)
+			hash.Write([]byte(name))
+			for _, value := range values {
+				hash.Write([]byte{0})
+				hash.Write([]byte(value))
+			}
+			hash.Write([]byte{1})
+		}
+		copy(key.metadata[:], hash.Sum(nil))
+	}
+	return key
+}
+
+// matchCache is a LRU cache of the stubs matched by the calls, nil when no stub matched.
+type matchCache struct {
+	size    int
+	entries map[matchKey]*list.Element
+	// most recently used first
+	order *list.List
+	mutex sync.Mutex
+}
+
+type matchResolution struct {
+	key matchKey
+	// candidates of the method when the stub was matched, replaced when the stubs of the method change
+	candidates *methodCandidates
+	stub       *Stub
+}
+
+// get returns the stub matched by the call, unless it was matched before the stubs of the method changed.
+func (c *matchCache) get(key matchKey, candidates *methodCandidates) (*Stub, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	resolution := element.Value.(*matchResolution)
+	if resolution.candidates != candidates {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return resolution.stub, true
+}
+
+func (c *matchCache) add(key matchKey, candidates *methodCandidates, stub *Stub) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	resolution := &matchResolution{key: key, candidates: candidates, stub: stub}
+	if element, ok := c.entries[key]; ok {
+		element.Value = resolution
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(resolution)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*matchResolution).key)
+	}
+}
@@ -0,0 +1,96 @@
+package stub
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+	"testing"
+)
+
+func TestStubsMatcher_Match_Cached(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store).(*stubsMatcher)
+	ctx := context.Background()
+	partial := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "partial", Content: `{"name":"John"}`}}
+	assert.Nil(t, store.Add(partial))
+
+	assert.Equal(t, partial, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"John","age":2}`))
+	assert.Equal(t, partial, matcher.Match(ctx, "/pkg.Service/Method", `{"age":2, "name":"John"}`))
+	assert.Nil(t, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"Mary"}`))
+	assert.Nil(t, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"Mary"}`))
+	assert.Equal(t, 2, store.GetMatchCount(partial))
+	assert.Equal(t, 2, matcher.resolutions.order.Len())
+
+	// the stubs matched are discarded when the stubs of the method change
+	exact := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "exact", Content: `{"name":"John","age":2}`}}
+	assert.Nil(t, store.Add(exact))
+	assert.Equal(t, exact, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"John","age":2}`))
+	mary := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "partial", Content: `{"name":"Mary"}`}}
+	assert.Nil(t, store.Add(mary))
+	assert.Equal(t, mary, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"Mary"}`))
+	assert.Nil(t, store.Delete(mary))
+	assert.Nil(t, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"Mary"}`))
+}
+
+func TestStubsMatcher_Match_CachedByMetadata(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store).(*stubsMatcher)
+	s := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{
+		Match:    "partial",
+		Content:  `{}`,
+		Metadata: map[string][]string{"tenant": {"a"}},
+	}}
+	assert.Nil(t, store.Add(s))
+
+	for i, id := range []string{"1", "2", "3"} {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("tenant", "a", "x-request-id", id))
+		assert.Equal(t, s, matcher.Match(ctx, "/pkg.Service/Method", `{}`))
+		assert.Equal(t, i+1, store.GetMatchCount(s))
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("tenant", "b"))
+	assert.Nil(t, matcher.Match(ctx, "/pkg.Service/Method", `{}`))
+	assert.Nil(t, matcher.Match(context.Background(), "/pkg.Service/Method", `{}`))
+	// the metadata keys not compared by the stubs are not part of the calls cached
+	assert.Equal(t, 3, matcher.resolutions.order.Len())
+}
+
+func TestStubsMatcher_Match_CacheEvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store, WithMatchCache(2)).(*stubsMatcher)
+	ctx := context.Background()
+	assert.Nil(t, store.Add(&Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "partial", Content: `{}`}}))
+
+	matcher.Match(ctx, "/pkg.Service/Method", `{"id":1}`)
+	matcher.Match(ctx, "/pkg.Service/Method", `{"id":2}`)
+	matcher.Match(ctx, "/pkg.Service/Method", `{"id":1}`)
+	matcher.Match(ctx, "/pkg.Service/Method", `{"id":3}`)
+
+	assert.Equal(t, 2, matcher.resolutions.order.Len())
+	candidates := store.(candidatesIndex).matchCandidates("/pkg.Service/Method")
+	for id, cached := range map[string]bool{`{"id":1}`: true, `{"id":2}`: false, `{"id":3}`: true} {
+		_, found := matcher.resolutions.get(newMatchKey(ctx, "/pkg.Service/Method", candidates, []byte(id)), candidates)
+		assert.Equal(t, cached, found, id)
+	}
+	assert.Nil(t, NewStubsMatcher(store, WithMatchCache(0)).(*stubsMatcher).resolutions)
+}
+
+// BenchmarkStubsMatcher_MatchCached repeats a call matching one of 10000 partial stubs of the same method.
+func BenchmarkStubsMatcher_MatchCached(b *testing.B) {
+	store := NewInMemoryStubsStore()
+	for id := 0; id < 10000; id++ {
+		store.Add(&Stub{
+			FullMethod: "/pkg.Service/Method",
+			Type:       "mock",
+			Request:    &StubRequest{Match: "partial", Content: JsonString(fmt.Sprintf(`{"id":%d,"filter":{"active":true}}`, id))},
+		})
+	}
+	matcher := NewStubsMatcher(store)
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if matcher.Match(ctx, "/pkg.Service/Method", `{"id":9999,"filter":{"active":true},"page":2}`) == nil {
+			b.Fatal("no stub matched")
+		}
+	}
+}
@@ -2,9 +2,15 @@ package stub
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"io"
+	"math"
 	"sort"
 	"strings"
+	"sync"
 )
 
 // Search and match stubs in the StubsStore
@@ -13,39 +19,434 @@ type StubsMatcher interface {
 }
 
 // Creates new stubs matcher
-func NewStubsMatcher(store StubsStore) StubsMatcher {
-	return &stubsMatcher{
-		StubsStore: store,
+func NewStubsMatcher(store StubsStore, options ...MatcherOption) StubsMatcher {
+	matcher := &stubsMatcher{
+		StubsStore:        store,
+		parallelThreshold: DefaultParallelMatchingThreshold,
+		workers:           newMatchWorkers(),
 	}
+	WithMatchCache(DefaultMatchCacheSize)(matcher)
+	for _, option := range options {
+		option(matcher)
+	}
+	// the stubs matched can only be cached when the store tells when the stubs of a method change
+	if _, ok := store.(candidatesIndex); !ok {
+		matcher.resolutions = nil
+	}
+	return matcher
 }
 
+// MatcherOption changes how the stubs matcher compares the calls with the stubs.
+type MatcherOption func(*stubsMatcher)
+
 type stubsMatcher struct {
 	StubsStore StubsStore
+	// compare the requests as messages of the request type of their method, see WithProtoMatching
+	protoMatching bool
+	// request descriptors by method, cached for the proto matching
+	descriptors sync.Map
+	// stubs matched by the last calls, see WithMatchCache
+	resolutions *matchCache
+	// number of stubs of a method from which they are compared with the calls by the workers, see WithParallelMatching
+	parallelThreshold int
+	workers           chan struct{}
 }
 
-// Returns the Stub in the StubsStore that matches the method and requestJSON provided OR nil if no stub is found
-func (m *stubsMatcher) Match(ctx context.Context, fullMethod, requestJson string) *Stub {
-	stubsForMethod := m.StubsStore.GetStubsMapForMethod(fullMethod)
-	if stubsForMethod == nil {
-		return nil
+// matchCandidate is a stub with the content and the metadata of its request parsed, so that they are not parsed again
+// for each call.
+type matchCandidate struct {
+	stub *Stub
+	// request of the stub, which the candidates are sorted by
+	request string
+	content interface{}
+	// content in canonical form for the exact stubs, see canonicalJson
+	canonical string
+	// values of each metadata key, trimmed and sorted
+	metadata map[string][]string
+	// content parsed in the request message of the method on the first call, for the proto matching
+	message *protoContent
+	// fields compared when the stub has the paths of a field mask, see StubFieldMask
+	fieldMask fieldMask
+}
+
+// newMatchCandidate parses the request of the stub, or returns an error when its content is not valid JSON.
+func newMatchCandidate(stub *Stub) (matchCandidate, error) {
+	content, err := parseJson(stub.Request.Content.String())
+	if err != nil {
+		return matchCandidate{}, fmt.Errorf("invalid request content of the stub for %s: %v", stub.FullMethod, err)
 	}
-	for _, stub := range stubsForMethod {
-		switch stub.Request.Match {
-		case "exact":
-			if stub.Request.Content.Equals(JsonString(requestJson)) && matchMetadata(ctx, stub) {
-				return stub
+	markEmptyArrays := stub.Request.Match == "partial" && stub.Request.EmptyArrays == "empty"
+	content = prepareEmptyArrays(content, markEmptyArrays)
+	candidate := matchCandidate{stub: stub, request: stub.Request.String(), content: content, message: &protoContent{}}
+	if stub.Request.FieldMask != nil && stub.Request.FieldMask.Paths != "" {
+		candidate.fieldMask = newFieldMask(strings.Split(stub.Request.FieldMask.Paths, ","))
+		candidate.content = candidate.fieldMask.applyJson(content)
+	}
+	// the exact stubs with a field mask are not equal to the whole request
+	if stub.Request.Match == "exact" && stub.Request.FieldMask == nil {
+		candidate.canonical, _ = canonicalJson(content)
+	}
+	if len(stub.Request.Metadata) > 0 {
+		candidate.metadata = make(map[string][]string, len(stub.Request.Metadata))
+		for key, values := range stub.Request.Metadata {
+			trimmed := make([]string, 0, len(values))
+			for _, value := range values {
+				trimmed = append(trimmed, strings.TrimSpace(value))
 			}
-		case "partial":
-			if stub.Request.Content.Matches(JsonString(requestJson)) && matchMetadata(ctx, stub) {
-				return stub
+			sort.Strings(trimmed)
+			candidate.metadata[key] = trimmed
+		}
+	}
+	return candidate, nil
+}
+
+// methodCandidates are the stubs of a method, sorted by request, with the exact ones also indexed by the canonical form
+// of their content so that most calls are matched with a lookup instead of comparing them with every stub.
+type methodCandidates struct {
+	sorted []matchCandidate
+	exact  map[string][]matchCandidate
+	// metadata keys compared by the stubs, sorted
+	metadataKeys []string
+	// some stubs are compared by a custom matcher, see RequestMatcher
+	custom bool
+	// number of stubs comparing each metadata key and of custom stubs, to update metadataKeys and custom
+	metadataCounts map[string]int
+	customCount    int
+}
+
+func newMethodCandidates(sorted []matchCandidate) *methodCandidates {
+	candidates := &methodCandidates{
+		sorted:         make([]matchCandidate, 0, len(sorted)),
+		exact:          make(map[string][]matchCandidate),
+		metadataCounts: make(map[string]int),
+	}
+	for _, candidate := range sorted {
+		candidates.set(candidate)
+	}
+	return candidates
+}
+
+// set adds the candidate in the order of the requests, or replaces the one with the same request. The candidates are
+// changed in place, so they must not be read by the calls being matched, see clone.
+func (c *methodCandidates) set(candidate matchCandidate) {
+	i := sort.Search(len(c.sorted), func(i int) bool { return c.sorted[i].request >= candidate.request })
+	if i < len(c.sorted) && c.sorted[i].request == candidate.request {
+		c.unindex(c.sorted[i])
+		c.sorted[i] = candidate
+	} else {
+		c.sorted = append(c.sorted, matchCandidate{})
+		copy(c.sorted[i+1:], c.sorted[i:])
+		c.sorted[i] = candidate
+	}
+	c.index(candidate)
+}
+
+// remove removes the candidate of the request, if any.
+func (c *methodCandidates) remove(request string) {
+	i := sort.Search(len(c.sorted), func(i int) bool { return c.sorted[i].request >= request })
+	if i == len(c.sorted) || c.sorted[i].request != request {
+		return
+	}
+	c.unindex(c.sorted[i])
+	c.sorted = append(c.sorted[:i], c.sorted[i+1:]...)
+}
+
+// index adds the candidate to the exact stubs, the metadata keys and the custom stubs. The lists of the exact stubs are
+// replaced instead of changed, so that clone can share them.
+func (c *methodCandidates) index(candidate matchCandidate) {
+	if candidate.canonical != "" {
+		exact := c.exact[candidate.canonical]
+		i := sort.Search(len(exact), func(i int) bool { return exact[i].request >= candidate.request })
+		updated := make([]matchCandidate, 0, len(exact)+1)
+		updated = append(append(append(updated, exact[:i]...), candidate), exact[i:]...)
+		c.exact[candidate.canonical] = updated
+	}
+	if candidate.stub.Request.Match == "custom" {
+		c.customCount++
+	}
+	keysChanged := false
+	for key := range candidate.metadata {
+		c.metadataCounts[key]++
+		keysChanged = keysChanged || c.metadataCounts[key] == 1
+	}
+	c.updateKeys(keysChanged)
+}
+
+// unindex removes the candidate from the exact stubs, the metadata keys and the custom stubs.
+func (c *methodCandidates) unindex(candidate matchCandidate) {
+	if candidate.canonical != "" {
+		exact := c.exact[candidate.canonical]
+		updated := make([]matchCandidate, 0, len(exact))
+		for _, other := range exact {
+			if other.request != candidate.request {
+				updated = append(updated, other)
 			}
 		}
+		if len(updated) == 0 {
+			delete(c.exact, candidate.canonical)
+		} else {
+			c.exact[candidate.canonical] = updated
+		}
+	}
+	if candidate.stub.Request.Match == "custom" {
+		c.customCount--
+	}
+	keysChanged := false
+	for key := range candidate.metadata {
+		if c.metadataCounts[key]--; c.metadataCounts[key] == 0 {
+			delete(c.metadataCounts, key)
+			keysChanged = true
+		}
+	}
+	c.updateKeys(keysChanged)
+}
+
+func (c *methodCandidates) updateKeys(keysChanged bool) {
+	c.custom = c.customCount > 0
+	if !keysChanged {
+		return
+	}
+	keys := make([]string, 0, len(c.metadataCounts))
+	for key := range c.metadataCounts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	c.metadataKeys = keys
+}
+
+// clone returns a copy of the candidates that is not changed by set and remove, for the calls being matched.
+func (c *methodCandidates) clone() *methodCandidates {
+	exact := make(map[string][]matchCandidate, len(c.exact))
+	for canonical, candidates := range c.exact {
+		exact[canonical] = candidates
+	}
+	sorted := make([]matchCandidate, len(c.sorted))
+	copy(sorted, c.sorted)
+	return &methodCandidates{sorted: sorted, exact: exact, metadataKeys: c.metadataKeys, custom: c.custom}
+}
+
+// candidatesIndex is implemented by the stores keeping the stubs of each method sorted and parsed, so that a call is
+// only compared with the stubs of its method without parsing them.
+type candidatesIndex interface {
+	// matchCandidates returns a snapshot of the candidates of the method, read without locking the store.
+	matchCandidates(fullMethod string) *methodCandidates
+	// visitCandidates calls visit with the current candidates of the method, with the store locked so that they are not
+	// changed meanwhile. They may be nil.
+	visitCandidates(fullMethod string, visit func(*methodCandidates))
+}
+
+// Returns the Stub in the StubsStore that matches the method and requestJSON provided OR nil if no stub is found.
+// Mock stubs take precedence over forward and passthrough stubs so that replayed recordings are served locally, and exact
+// stubs with the same content as the request over the other stubs.
+func (m *stubsMatcher) Match(ctx context.Context, fullMethod, requestJson string) *Stub {
+	candidates := m.candidates(fullMethod)
+	if candidates == nil || len(candidates.sorted) == 0 {
+		return nil
+	}
+	if m.protoMatching {
+		if descriptor := m.requestDescriptor(fullMethod); descriptor != nil {
+			return m.matchProto(ctx, fullMethod, candidates, descriptor, requestJson)
+		}
+	}
+	request, err := parseJson(requestJson)
+	if err != nil {
+		return invalidRequestStub(fullMethod, err)
+	}
+	request = prepareEmptyArrays(request, false)
+	canonical, err := canonicalJson(request)
+	if err != nil {
+		return invalidRequestStub(fullMethod, err)
+	}
+	return m.resolve(ctx, fullMethod, candidates, []byte(canonical), func() *Stub {
+		return m.matchJson(ctx, candidates, request, canonical)
+	})
+}
+
+// matchJson returns the stub matching the request parsed as JSON, looking up the exact stubs by the canonical form of the
+// request first.
+func (m *stubsMatcher) matchJson(ctx context.Context, candidates *methodCandidates, request interface{}, canonical string) *Stub {
+	var forwardStub *Stub
+	for _, candidate := range candidates.exact[canonical] {
+		if !matchMetadata(ctx, candidate.metadata) {
+			continue
+		}
+		if candidate.stub.IsForwarding() {
+			forwardStub = candidate.stub
+			continue
+		}
+		return candidate.stub
+	}
+	// the exact stubs are compared again since the items of their arrays can be in any order
+	return m.evaluate(candidates.sorted, forwardStub, func(candidate matchCandidate) bool {
+		if candidate.stub.Request.Match == "custom" {
+			return matchCustom(ctx, candidate, canonical)
+		}
+		return matchCandidateStub(ctx, candidate, request)
+	})
+}
+
+// firstMatch returns the first candidate matching the call, or else the last forwarding one matching it or forwardStub.
+func firstMatch(candidates []matchCandidate, forwardStub *Stub, matches func(matchCandidate) bool) *Stub {
+	for _, candidate := range candidates {
+		if !matches(candidate) {
+			continue
+		}
+		if candidate.stub.IsForwarding() {
+			forwardStub = candidate.stub
+			continue
+		}
+		return candidate.stub
+	}
+	return forwardStub
+}
+
+// candidates returns the stubs of the method in a stable order, so that the same stub is matched when several match the
+// request.
+func (m *stubsMatcher) candidates(fullMethod string) *methodCandidates {
+	return storeCandidates(m.StubsStore, fullMethod)
+}
+
+// storeCandidates returns the candidates of the method from the snapshot of the store, or parses its stubs when the
+// store doesn't keep them parsed.
+func storeCandidates(store StubsStore, fullMethod string) *methodCandidates {
+	if index, ok := store.(candidatesIndex); ok {
+		return index.matchCandidates(fullMethod)
+	}
+	return parseCandidates(store, fullMethod)
+}
+
+// parseCandidates parses the stubs of the method of a store that doesn't keep them parsed.
+func parseCandidates(store StubsStore, fullMethod string) *methodCandidates {
+	stubsForMethod := store.GetStubsMapForMethod(fullMethod)
+	requests := make([]string, 0, len(stubsForMethod))
+	for request := range stubsForMethod {
+		requests = append(requests, request)
+	}
+	sort.Strings(requests)
+	candidates := make([]matchCandidate, 0, len(requests))
+	for _, request := range requests {
+		candidate, err := newMatchCandidate(stubsForMethod[request])
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, candidate)
+	}
+	return newMethodCandidates(candidates)
+}
+
+// invalidRequestStub returns the stub failing a call whose request could not be parsed, so that the client gets the error
+// instead of the call silently not matching any stub.
+func invalidRequestStub(fullMethod string, err error) *Stub {
+	return &Stub{
+		FullMethod: fullMethod,
+		Type:       "mock",
+		Request:    &StubRequest{Match: "exact", Content: "{}"},
+		Response: &StubResponse{
+			Type:  "error",
+			Error: &ErrorResponse{Code: uint32(codes.InvalidArgument), Message: fmt.Sprintf("the request to %s is not valid JSON: %v", fullMethod, err)},
+		},
+	}
+}
+
+// recordMatch counts the match of the stub, unless it is out of the order of its sequence. The stub returned then fails
+// the call with FAILED_PRECONDITION.
+func (m *stubsMatcher) recordMatch(stub *Stub) *Stub {
+	if tracker, ok := m.StubsStore.(SequenceTracker); ok && stub.Sequence != nil {
+		if err := tracker.ConsumeStep(stub); err != nil {
+			return outOfOrderStub(stub, err)
+		}
+	}
+	m.StubsStore.RecordMatch(stub)
+	return stub
+}
+
+func matchCandidateStub(ctx context.Context, candidate matchCandidate, request interface{}) bool {
+	content := candidate.content
+	if mask := candidate.requestFieldMask(request); mask != nil {
+		content, request = mask.applyJson(content), mask.applyJson(request)
+	}
+	switch candidate.stub.Request.Match {
+	case "exact":
+		return jsonValuesMatch(content, request, true) && matchMetadata(ctx, candidate.metadata)
+	case "partial":
+		return jsonValuesMatch(content, request, false) && matchMetadata(ctx, candidate.metadata)
+	}
+	return false
+}
+
+// parseJson parses any JSON value. Empty content is an empty object, as it is marshaled. The numbers are int64 when they
+// are integers, whatever their notation, e.g. 1.0 or 1e2, and float64 otherwise.
+func parseJson(data string) (interface{}, error) {
+	if data == "" {
+		return make(map[string]interface{}), nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid data after the JSON value")
+	}
+	return normalizeNumbers(value), nil
+}
+
+// normalizeNumbers replaces the json.Number values in the JSON value.
+func normalizeNumbers(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			value[key] = normalizeNumbers(field)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalizeNumbers(item)
+		}
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, err := value.Float64()
+		if err != nil {
+			return value.String()
+		}
+		if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			return int64(f)
+		}
+		return f
 	}
-	return nil
+	return value
 }
 
-func matchMetadata(ctx context.Context, stub *Stub) bool {
-	if len(stub.Request.Metadata) == 0 {
+// canonicalJson returns the JSON value with the fields of its objects sorted and without spaces, so that equal objects
+// have the same canonical form whatever the order of their fields.
+func canonicalJson(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// jsonValuesMatch compares the content of a stub with the one of a request. Objects are compared field by field, see
+// jsonStringMatches, and an empty object matches any request partially. The other values, e.g. the ones of the wrapper
+// types like google.protobuf.StringValue, must be equal.
+func jsonValuesMatch(content, request interface{}, mustBeEqual bool) bool {
+	object, isObject := content.(map[string]interface{})
+	requestObject, isRequestObject := request.(map[string]interface{})
+	if isObject && isRequestObject {
+		return jsonStringMatches(object, requestObject, mustBeEqual, 1)
+	}
+	if isObject {
+		return !mustBeEqual && len(object) == 0
+	}
+	return jsonFieldMatches(content, request, mustBeEqual, 0)
+}
+
+// matchMetadata compares the metadata of the call with the values of each key of the stub, trimmed and sorted.
+func matchMetadata(ctx context.Context, stubMetadata map[string][]string) bool {
+	if len(stubMetadata) == 0 {
 		return true
 	}
 	// read metadata from context
@@ -53,25 +454,13 @@ func matchMetadata(ctx context.Context, stub *Stub) bool {
 	if !ok {
 		return false
 	}
-	stubMetadata := getStubMetadata(stub)
 	// compare
 	for key, values := range stubMetadata {
-		contextMetadata := md.Get(key)
+		contextMetadata := append(make([]string, 0, len(values)), md.Get(key)...)
 		sort.Strings(contextMetadata)
-		sort.Strings(values)
 		if strings.Join(values, ",") != strings.Join(contextMetadata, ",") {
 			return false
 		}
 	}
 	return true
 }
-
-func getStubMetadata(stub *Stub) (stubMetadata map[string][]string) {
-	stubMetadata = make(map[string][]string, 0)
-	for key, values := range stub.Request.Metadata {
-		for _, value := range values {
-			stubMetadata[key] = append(stubMetadata[key], strings.TrimSpace(value))
-		}
-	}
-	return
-}
@@ -0,0 +1,282 @@
+package stub
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"strings"
+	"testing"
+)
+
+func TestStubsMatcher_Match_RecordsMatchCount(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	s := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request: &StubRequest{
+			Match:   "partial",
+			Content: `{"name":"John"}`,
+		},
+	}
+	store.Add(s)
+	matcher := NewStubsMatcher(store)
+
+	assert.Equal(t, 1, len(store.GetUnmatchedStubs()))
+	assert.Equal(t, s, matcher.Match(context.Background(), "/pkg.Service/Method", `{"name":"John","age":2}`))
+	assert.Nil(t, matcher.Match(context.Background(), "/pkg.Service/Method", `{"name":"Mary"}`))
+	assert.Equal(t, s, matcher.Match(context.Background(), "/pkg.Service/Method", `{"name":"John"}`))
+	assert.Equal(t, 2, store.GetMatchCount(s))
+	assert.Equal(t, 0, len(store.GetUnmatchedStubs()))
+
+	store.ResetMatchCounts()
+	assert.Equal(t, 0, store.GetMatchCount(s))
+}
+
+func TestStubsMatcher_Match_MockStubTakesPrecedenceOverForward(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	forward := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "forward",
+		Request: &StubRequest{
+			Match:   "partial",
+			Content: `{}`,
+		},
+		Forward: &StubForward{ServerAddress: "localhost:1234", Record: true, Replay: "exact"},
+	}
+	mock := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request: &StubRequest{
+			Match:   "exact",
+			Content: `{"name":"John"}`,
+		},
+	}
+	store.Add(forward)
+	store.Add(mock)
+	matcher := NewStubsMatcher(store)
+
+	assert.Equal(t, mock, matcher.Match(context.Background(), "/pkg.Service/Method", `{"name":"John"}`))
+	assert.Equal(t, forward, matcher.Match(context.Background(), "/pkg.Service/Method", `{"name":"Mary"}`))
+}
+
+func TestStubsMatcher_Match_PassthroughStubIsCounted(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	passthrough := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "passthrough",
+		Request: &StubRequest{
+			Match:   "partial",
+			Content: `{}`,
+		},
+		Forward: &StubForward{ServerAddress: "localhost:1234"},
+	}
+	store.Add(passthrough)
+	matcher := NewStubsMatcher(store)
+
+	assert.Equal(t, passthrough, matcher.Match(context.Background(), "/pkg.Service/Method", `{"name":"John"}`))
+	assert.Equal(t, 1, store.GetMatchCount(passthrough))
+}
+
+func TestStubsMatcher_Match_MapFields(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	partial := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request: &StubRequest{
+			Match:   "partial",
+			Content: `{"metadata":{"tenant":"a"}}`,
+		},
+	}
+	exact := &Stub{
+		FullMethod: "/pkg.Service/Other",
+		Type:       "mock",
+		Request: &StubRequest{
+			Match:   "exact",
+			Content: `{"metadata":{"tenant":"a"}}`,
+		},
+	}
+	store.Add(partial)
+	store.Add(exact)
+	matcher := NewStubsMatcher(store)
+
+	assert.Equal(t, partial, matcher.Match(context.Background(), "/pkg.Service/Method", `{"metadata":{"region":"eu","tenant":"a"}}`))
+	assert.Nil(t, matcher.Match(context.Background(), "/pkg.Service/Method", `{"metadata":{"tenant":"b"}}`))
+	assert.Equal(t, exact, matcher.Match(context.Background(), "/pkg.Service/Other", `{"metadata":{"tenant":"a"}}`))
+	assert.Nil(t, matcher.Match(context.Background(), "/pkg.Service/Other", `{"metadata":{"region":"eu","tenant":"a"}}`))
+}
+
+func TestStubsMatcher_Match_IndexFollowsTheStore(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store)
+	ctx := context.Background()
+	john := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "exact", Content: `{"name":"John"}`}}
+	assert.Nil(t, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"John"}`))
+
+	store.Add(john)
+	assert.Equal(t, john, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"John"}`))
+	updated := &Stub{FullMethod: "/pkg.Service/Method", Type: "forward", Request: john.Request}
+	store.Update(updated)
+	assert.Equal(t, updated, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"John"}`))
+	store.Delete(john)
+	assert.Nil(t, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"John"}`))
+
+	store.Add(john)
+	store.DeleteAll()
+	assert.Nil(t, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"John"}`))
+}
+
+func TestStubsMatcher_Match_ScalarContent(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store)
+	ctx := context.Background()
+	john := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "exact", Content: `"John"`}}
+	anyRequest := &Stub{FullMethod: "/pkg.Service/Other", Type: "mock", Request: &StubRequest{Match: "partial", Content: `{}`}}
+	assert.Nil(t, store.Add(john))
+	assert.Nil(t, store.Add(anyRequest))
+
+	assert.Equal(t, john, matcher.Match(ctx, "/pkg.Service/Method", `"John"`))
+	assert.Nil(t, matcher.Match(ctx, "/pkg.Service/Method", `"Mary"`))
+	assert.Nil(t, matcher.Match(ctx, "/pkg.Service/Method", `{"name":"John"}`))
+	assert.Equal(t, anyRequest, matcher.Match(ctx, "/pkg.Service/Other", `"Mary"`))
+}
+
+func TestStubsMatcher_Match_InvalidRequest(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store)
+	s := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "partial", Content: `{}`}}
+	assert.Nil(t, store.Add(s))
+
+	matched := matcher.Match(context.Background(), "/pkg.Service/Method", `{"name":`)
+	assert.Equal(t, "error", matched.Response.Type)
+	assert.Equal(t, uint32(codes.InvalidArgument), matched.Response.Error.Code)
+	assert.Equal(t, "the request to /pkg.Service/Method is not valid JSON: unexpected EOF", matched.Response.Error.Message)
+	assert.Equal(t, 0, store.GetMatchCount(s))
+}
+
+func TestStubsMatcher_Match_Metadata(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store)
+	s := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{
+		Match:    "partial",
+		Content:  `{}`,
+		Metadata: map[string][]string{"tenant": {" b ", "a"}},
+	}}
+	assert.Nil(t, store.Add(s))
+
+	assert.Nil(t, matcher.Match(context.Background(), "/pkg.Service/Method", `{}`))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("tenant", "b", "tenant", "a"))
+	assert.Equal(t, s, matcher.Match(ctx, "/pkg.Service/Method", `{}`))
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("tenant", "a"))
+	assert.Nil(t, matcher.Match(ctx, "/pkg.Service/Method", `{}`))
+}
+
+func TestStubsMatcher_Match_ExactStubTakesPrecedence(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store)
+	ctx := context.Background()
+	partial := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "partial", Content: `{"age":2}`}}
+	exact := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "exact", Content: `{"name":"John","age":2}`}}
+	forward := &Stub{FullMethod: "/pkg.Service/Method", Type: "forward", Request: &StubRequest{Match: "exact", Content: `{"name":"Mary","age":2}`}}
+	items := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "exact", Content: `{"items":[1,2]}`}}
+	for _, s := range []*Stub{partial, exact, forward, items} {
+		assert.Nil(t, store.Add(s))
+	}
+
+	assert.Equal(t, exact, matcher.Match(ctx, "/pkg.Service/Method", `{ "age": 2, "name": "John" }`))
+	assert.Equal(t, partial, matcher.Match(ctx, "/pkg.Service/Method", `{"age":2,"name":"Mary"}`))
+	assert.Equal(t, partial, matcher.Match(ctx, "/pkg.Service/Method", `{"age":2,"name":"Bob"}`))
+	assert.Equal(t, items, matcher.Match(ctx, "/pkg.Service/Method", `{"items":[2,1]}`))
+	assert.Equal(t, 1, store.GetMatchCount(exact))
+}
+
+// BenchmarkStubsMatcher_MatchExact matches calls against a store with 10000 exact stubs of 500 methods.
+func BenchmarkStubsMatcher_MatchExact(b *testing.B) {
+	store := NewInMemoryStubsStore()
+	for method := 0; method < 500; method++ {
+		for id := 0; id < 20; id++ {
+			store.Add(&Stub{
+				FullMethod: fmt.Sprintf("/pkg.Service/Method%d", method),
+				Type:       "mock",
+				Request:    &StubRequest{Match: "exact", Content: JsonString(fmt.Sprintf(`{"id":%d,"filter":{"active":true}}`, id))},
+			})
+		}
+	}
+	matcher := NewStubsMatcher(store, WithMatchCache(0))
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if matcher.Match(ctx, "/pkg.Service/Method250", `{"filter":{"active":true},"id":19}`) == nil {
+			b.Fatal("no stub matched")
+		}
+	}
+}
+
+// BenchmarkStubsMatcher_Match matches calls against a store with 10000 stubs of 500 methods.
+func BenchmarkStubsMatcher_Match(b *testing.B) {
+	store := NewInMemoryStubsStore()
+	for method := 0; method < 500; method++ {
+		for id := 0; id < 20; id++ {
+			store.Add(&Stub{
+				FullMethod: fmt.Sprintf("/pkg.Service/Method%d", method),
+				Type:       "mock",
+				Request:    &StubRequest{Match: "partial", Content: JsonString(fmt.Sprintf(`{"id":%d,"filter":{"active":true}}`, id))},
+			})
+		}
+	}
+	matcher := NewStubsMatcher(store, WithMatchCache(0))
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if matcher.Match(ctx, "/pkg.Service/Method250", `{"id":19,"filter":{"active":true},"page":2}`) == nil {
+			b.Fatal("no stub matched")
+		}
+	}
+}
+
+// BenchmarkStubsMatcher_MatchMethodStubs matches calls against 10000 partial stubs of the same method.
+func BenchmarkStubsMatcher_MatchMethodStubs(b *testing.B) {
+	store := NewInMemoryStubsStore()
+	for id := 0; id < 10000; id++ {
+		store.Add(&Stub{
+			FullMethod: "/pkg.Service/Method",
+			Type:       "mock",
+			Request:    &StubRequest{Match: "partial", Content: JsonString(fmt.Sprintf(`{"id":%d,"filter":{"active":true}}`, id))},
+		})
+	}
+	matcher := NewStubsMatcher(store, WithMatchCache(0))
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if matcher.Match(ctx, "/pkg.Service/Method", `{"id":9999,"filter":{"active":true},"page":2}`) == nil {
+			b.Fatal("no stub matched")
+		}
+	}
+}
+
+// BenchmarkStubsMatcher_MatchLargeRequest matches a request of a few MB against stubs of its method.
+func BenchmarkStubsMatcher_MatchLargeRequest(b *testing.B) {
+	store := NewInMemoryStubsStore()
+	for id := 0; id < 20; id++ {
+		store.Add(&Stub{
+			FullMethod: "/pkg.Service/Method",
+			Type:       "mock",
+			Request:    &StubRequest{Match: "partial", Content: JsonString(fmt.Sprintf(`{"id":%d}`, id))},
+		})
+	}
+	items := make([]string, 0, 20000)
+	for i := 0; i < 20000; i++ {
+		items = append(items, fmt.Sprintf(`{"sku":"SKU-%06d","quantity":%d,"description":"%s"}`, i, i%10, strings.Repeat("x", 64)))
+	}
+	request := fmt.Sprintf(`{"id":19,"items":[%s]}`, strings.Join(items, ","))
+	matcher := NewStubsMatcher(store, WithMatchCache(0))
+	ctx := context.Background()
+	b.SetBytes(int64(len(request)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if matcher.Match(ctx, "/pkg.Service/Method", request) == nil {
+			b.Fatal("no stub matched")
+		}
+	}
+}
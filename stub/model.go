@@ -2,11 +2,14 @@ package stub
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	log "github.com/sirupsen/logrus"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"reflect"
+	"sort"
+	"strings"
 )
 
 type JsonString string
@@ -35,16 +38,48 @@ func (j *StubType) UnmarshalJSON(data []byte) error {
 
 type Stub struct {
 	FullMethod string        `json:"fullMethod"`
-	Type       StubType      `json:"type"`     // mock | forward - default to mock to maintain backwards compatibility
+	Type       StubType      `json:"type"`     // mock | forward | passthrough - default to mock to maintain backwards compatibility
 	Request    *StubRequest  `json:"request"`  // Always required
 	Response   *StubResponse `json:"response"` // required if type = mock. Ignored otherwise.
-	Forward    *StubForward  `json:"forward"`  // required if type = forward. Ignored otherwise.
+	Forward    *StubForward  `json:"forward"`  // required if type = forward or passthrough. Ignored otherwise.
+	// Optional. Position of the stub in an ordered list of expected calls.
+	Sequence *StubSequence `json:"sequence,omitempty"`
+	// Optional. Documentation of the stub, shown in the listings and the reports and used to search the stubs. They are
+	// not compared with the calls.
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// IsForwarding returns true for the stub types that send the call to a real server.
+// Passthrough stubs forward the call unchanged and always record it.
+func (stub *Stub) IsForwarding() bool {
+	return stub.Type == "forward" || stub.Type == "passthrough"
+}
+
+// ID identifies the stub in logs and traces. It is derived from the method and the request, which are unique in the store,
+// so the same stub has the same ID across restarts.
+func (stub *Stub) ID() string {
+	hash := sha256.Sum256([]byte(stub.FullMethod + " " + stub.Request.String()))
+	return hex.EncodeToString(hash[:8])
 }
 
 type StubRequest struct {
-	Match    string              `json:"match"` // exact | partial
-	Content  JsonString          `json:"content"`
-	Metadata map[string][]string `json:"metadata"`
+	Match   string     `json:"match"` // exact | partial | custom
+	Content JsonString `json:"content"`
+	// Content in the protobuf binary format, encoded in base64, instead of JSON. It is converted to JSON when the stub is
+	// validated, see IsStubValid.
+	ContentBinary string              `json:"contentBinary,omitempty"`
+	Metadata      map[string][]string `json:"metadata"`
+	Stream        []JsonString        `json:"stream,omitempty"` // messages sent by the client in recorded client streaming calls
+	// How the empty arrays of the content of a partial stub match: absent (default), as a missing field, so they match
+	// any value, or empty, so they only match the calls without items. The empty arrays of the calls are always the same
+	// as missing fields, and so are the ones of the exact stubs.
+	EmptyArrays string `json:"emptyArrays,omitempty"` // absent | empty
+	// Restricts the fields compared to the ones of a google.protobuf.FieldMask
+	FieldMask *StubFieldMask `json:"fieldMask,omitempty"`
+	// Matcher registered with RegisterRequestMatcher that compares the calls when the matching type is custom
+	Custom *StubCustom `json:"custom,omitempty"`
 }
 
 func (s StubRequest) String() string {
@@ -53,14 +88,70 @@ func (s StubRequest) String() string {
 }
 
 type StubResponse struct {
-	Type    string         `json:"type"` // success | error
-	Content JsonString     `json:"content"`
-	Error   *ErrorResponse `json:"error"`
+	Type    string     `json:"type"` // success | error | custom | remote
+	Content JsonString `json:"content"`
+	// Content in the protobuf binary format, encoded in base64, instead of JSON. See StubRequest.ContentBinary.
+	ContentBinary string         `json:"contentBinary,omitempty"`
+	Error         *ErrorResponse `json:"error"`
+	Stream        []JsonString   `json:"stream,omitempty"` // messages sent by the server in recorded server streaming calls
+	Delay         string         `json:"delay,omitempty"`  // wait before responding, e.g. 500ms. The call fails with DEADLINE_EXCEEDED if the client deadline expires first.
+	// When true the response is only sent after the client deadline expires, so the call always fails with DEADLINE_EXCEEDED.
+	ExceedDeadline bool `json:"exceedDeadline,omitempty"`
+	// Maximum rate the response is sent at, e.g. 256KB/s, to simulate a slow network. The response is sent once the time
+	// its size takes at that rate has passed. See ParseBandwidth.
+	Bandwidth string `json:"bandwidth,omitempty"`
+	// How an error response is sent: trailers-only, the status alone without header, or headers-first, the header before
+	// the status. By default gRPC sends the status alone unless metadata was set in the header, e.g. a correlation ID.
+	ErrorHeaders string `json:"errorHeaders,omitempty"` // trailers-only | headers-first
+	// Metadata sent in the header and in the trailer of the response. The values, the message of the error and the string
+	// values of the content can be templates rendered for each call, e.g. {{.Metadata "x-request-id"}}, see renderTemplate.
+	Headers  map[string][]string `json:"headers,omitempty"`
+	Trailers map[string][]string `json:"trailers,omitempty"`
+	// Generator registered with RegisterResponseGenerator that creates the response when the response type is custom
+	Custom *StubCustom `json:"custom,omitempty"`
+	// Endpoint called to create the response when the response type is remote
+	Remote *StubRemote `json:"remote,omitempty"`
 }
 
 type StubForward struct {
-	ServerAddress string `json:"serverAddress"`
-	Record        bool   `json:"record"`
+	ServerAddress   string          `json:"serverAddress"`
+	ServerAddresses []string        `json:"serverAddresses"` // additional servers. Combined with serverAddress when both are provided.
+	Balancing       string          `json:"balancing"`       // roundrobin | failover - defaults to failover. Unavailable servers are skipped in both.
+	Record          bool            `json:"record"`
+	Replay          string          `json:"replay"` // exact | partial - when set, each recording is also added as a mock stub. Requires record.
+	TLS             *StubForwardTLS `json:"tls"`    // optional. Plaintext is used when not provided.
+	// Manipulation of the metadata received from the client before forwarding. Applied in the order: remove, override, add.
+	RemoveMetadata   []string            `json:"removeMetadata"`
+	OverrideMetadata map[string][]string `json:"overrideMetadata"`
+	AddMetadata      map[string][]string `json:"addMetadata"`
+	// Transformation applied to the response received from the server before returning it to the client. Optional.
+	Transform *StubForwardTransform `json:"transform"`
+	Timeout   string                `json:"timeout"` // timeout of each call to the server, e.g. 2s. The client deadline still applies.
+	Retry     *StubForwardRetry     `json:"retry"`   // optional. A single attempt is made when not provided.
+}
+
+type StubForwardRetry struct {
+	Attempts       uint32   `json:"attempts"`       // maximum number of attempts, including the first one
+	Backoff        string   `json:"backoff"`        // wait between attempts, e.g. 100ms
+	RetryableCodes []uint32 `json:"retryableCodes"` // gRPC status codes that are retried. Defaults to 14 (UNAVAILABLE).
+}
+
+type StubForwardTransform struct {
+	Content JsonString     `json:"content"` // fields that override the ones in the response. Nested objects are merged.
+	Delay   string         `json:"delay"`   // e.g. 500ms, 2s
+	Error   *ErrorResponse `json:"error"`   // replaces the response with the error
+	// Maximum rate the response, or each message of a stream, is sent at, e.g. 256KB/s. See ParseBandwidth.
+	Bandwidth string `json:"bandwidth"`
+}
+
+// StubForwardTLS holds the TLS settings used to connect to the server requests are forwarded to.
+// File paths are relative to the mock server.
+type StubForwardTLS struct {
+	CAFile             string `json:"caFile"`   // CA bundle used to verify the server. Uses the system pool when empty.
+	CertFile           string `json:"certFile"` // client certificate for mTLS. Requires keyFile.
+	KeyFile            string `json:"keyFile"`  // client key for mTLS. Requires certFile.
+	ServerName         string `json:"serverName"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
 }
 
 type ErrorResponse struct {
@@ -84,18 +175,25 @@ type ErrorDetailsSpec struct {
 	Type   string `json:"type"`
 }
 
+// Addresses returns all the servers requests can be forwarded to.
+func (f *StubForward) Addresses() []string {
+	addresses := make([]string, 0)
+	if f.ServerAddress != "" {
+		addresses = append(addresses, f.ServerAddress)
+	}
+	return append(addresses, f.ServerAddresses...)
+}
+
 func (j JsonString) String() string {
 	return string(j)
 }
 
 func (j *JsonString) UnmarshalJSON(data []byte) error {
 	buffer := new(bytes.Buffer)
-	err := json.Compact(buffer, data)
-	if err != nil {
-		log.Errorf("error compacting json: %s", string(data))
+	if err := json.Compact(buffer, data); err != nil {
+		return fmt.Errorf("invalid JSON %s: %v", string(data), err)
 	}
-	result := JsonString(buffer.String())
-	*j = result
+	*j = JsonString(buffer.String())
 	return nil
 }
 
@@ -107,86 +205,274 @@ func (j *JsonString) MarshalJSON() ([]byte, error) {
 	return []byte(val), nil
 }
 
+// Merge returns the JSON object with the fields in override replacing the existing ones. Nested objects are merged recursively.
+func (j JsonString) Merge(override JsonString) (JsonString, error) {
+	jsonMap := make(map[string]interface{})
+	overrideJsonMap := make(map[string]interface{})
+	if j != "" {
+		if err := json.Unmarshal([]byte(j), &jsonMap); err != nil {
+			return "", err
+		}
+	}
+	if err := json.Unmarshal([]byte(override), &overrideJsonMap); err != nil {
+		return "", err
+	}
+	mergeJsonMaps(jsonMap, overrideJsonMap)
+	data, err := json.Marshal(jsonMap)
+	if err != nil {
+		return "", err
+	}
+	return JsonString(data), nil
+}
+
+func mergeJsonMaps(jsonMap, overrideJsonMap map[string]interface{}) {
+	for key, overrideValue := range overrideJsonMap {
+		value, isObject := jsonMap[key].(map[string]interface{})
+		overrideObject, isOverrideObject := overrideValue.(map[string]interface{})
+		if isObject && isOverrideObject {
+			mergeJsonMaps(value, overrideObject)
+			continue
+		}
+		jsonMap[key] = overrideValue
+	}
+}
+
+// Matches tells whether other contains the fields of the JSON value. Invalid JSON never matches.
 func (j *JsonString) Matches(other JsonString) bool {
-	jsonMap := new(map[string]interface{})
-	otherJsonMap := new(map[string]interface{})
-	json.Unmarshal([]byte(*j), jsonMap)
-	json.Unmarshal([]byte(other), otherJsonMap)
-	return jsonStringMatches(*jsonMap, *otherJsonMap, false)
+	matches, err := j.compare(other, false)
+	return err == nil && matches
 }
 
+// Equals tells whether other has the same fields as the JSON value. Invalid JSON is never equal.
 func (j *JsonString) Equals(other JsonString) bool {
-	jsonMap := new(map[string]interface{})
-	otherJsonMap := new(map[string]interface{})
-	json.Unmarshal([]byte(*j), jsonMap)
-	json.Unmarshal([]byte(other), otherJsonMap)
-	return jsonStringMatches(*jsonMap, *otherJsonMap, true)
+	equals, err := j.compare(other, true)
+	return err == nil && equals
 }
 
-func jsonStringMatches(jsonMap, otherJsonMap map[string]interface{}, mustBeEqual bool) bool {
-	if mustBeEqual && len(jsonMap) != len(otherJsonMap) {
+func (j *JsonString) compare(other JsonString, mustBeEqual bool) (bool, error) {
+	content, err := parseJson(j.String())
+	if err != nil {
+		return false, err
+	}
+	otherContent, err := parseJson(other.String())
+	if err != nil {
+		return false, err
+	}
+	return jsonValuesMatch(prepareEmptyArrays(content, false), prepareEmptyArrays(otherContent, false), mustBeEqual), nil
+}
+
+// jsonStringMatches compares the objects at the depth given, the content being at depth 1. The objects deeper than
+// MaxContentDepth never match, so that the comparison of self-referential messages remains bounded.
+func jsonStringMatches(jsonMap, otherJsonMap map[string]interface{}, mustBeEqual bool, depth int) bool {
+	if depth > MaxContentDepth() || mustBeEqual && len(jsonMap) != len(otherJsonMap) {
 		return false
 	}
 	for key, value := range jsonMap {
 		otherValue, found := otherJsonMap[key]
-		if !found {
+		if _, isEmptyArray := value.(emptyArray); isEmptyArray && !found {
+			continue
+		}
+		if !found || !jsonFieldMatches(value, otherValue, mustBeEqual, depth) {
 			return false
 		}
-		valueType := fmt.Sprintf("%T", value)
-		otherValueType := fmt.Sprintf("%T", otherValue)
-		if valueType != otherValueType {
+	}
+	return true
+}
+
+// jsonFieldMatches compares values of the same JSON type. The numbers are normalized by parseJson, so that the same
+// number always has the same type and value. depth is the one of the object containing the values.
+func jsonFieldMatches(value, otherValue interface{}, mustBeEqual bool, depth int) bool {
+	switch value := value.(type) {
+	case map[string]interface{}: // object
+		otherObject, ok := otherValue.(map[string]interface{})
+		return ok && jsonStringMatches(value, otherObject, mustBeEqual, depth+1)
+	case []interface{}: // repeated field
+		otherItems, ok := otherValue.([]interface{})
+		return ok && jsonArraysMatch(value, otherItems, mustBeEqual, depth)
+	case emptyArray: // repeated field without items
+		otherItems, ok := otherValue.([]interface{})
+		_, isEmptyArray := otherValue.(emptyArray)
+		return isEmptyArray || ok && len(otherItems) == 0
+	}
+	return value == otherValue
+}
+
+// jsonArraysMatch compares repeated fields as multisets: each item of the stub must match a different item of the other
+// array, in any order. The items are first looked up by their canonical form, so that only the items without an equal
+// one, e.g. the objects of a partial stub, are compared with the remaining items.
+func jsonArraysMatch(items, otherItems []interface{}, mustBeEqual bool, depth int) bool {
+	if len(items) != len(otherItems) {
+		return false
+	}
+	remaining := make(map[string][]int, len(otherItems))
+	for i, otherItem := range otherItems {
+		canonical, err := canonicalJson(otherItem)
+		if err != nil {
 			return false
 		}
-		switch valueType {
-		case "map[string]interface {}": // object
-			if !jsonStringMatches(jsonMap[key].(map[string]interface{}), otherJsonMap[key].(map[string]interface{}), mustBeEqual) {
-				return false
-			}
+		remaining[canonical] = append(remaining[canonical], i)
+	}
+	used := make([]bool, len(otherItems))
+	unmatched := make([]interface{}, 0)
+	for _, item := range items {
+		canonical, err := canonicalJson(item)
+		if err != nil {
+			return false
+		}
+		if indexes := remaining[canonical]; len(indexes) > 0 {
+			used[indexes[0]] = true
+			remaining[canonical] = indexes[1:]
 			continue
-		case "[]interface {}": // repeated object
-			// naive implementation of comparison of repeated messages.
-			// TODO investigate a more performant way to compare
-			items := jsonMap[key].([]interface{})
-			otherItems := otherJsonMap[key].([]interface{})
-			if len(items) != len(otherItems) {
-				return false
-			}
-			for _, item := range items {
-				var found = false
-				for _, otherItem := range otherItems {
-					itemType := fmt.Sprintf("%T", item)
-					otherItemType := fmt.Sprintf("%T", otherItem)
-					if itemType != otherItemType {
-						// Not sure if they can be different
-						continue
-					}
-					switch itemType {
-					case "map[string]interface {}":
-						if jsonStringMatches(item.(map[string]interface{}), otherItem.(map[string]interface{}), mustBeEqual) {
-							found = true
-							break
-						}
-					default:
-						if item == otherItem {
-							found = true
-							break
-						}
-					}
+		}
+		unmatched = append(unmatched, item)
+	}
+	return matchRemainingItems(unmatched, otherItems, used, mustBeEqual, depth)
+}
 
+// matchRemainingItems matches the items without an equal item with the ones not used yet, each with a different one. An
+// item matching several other items can take the one another item needs, so the items are assigned by augmenting paths
+// (Kuhn's algorithm) instead of to the first item they match. The objects are only compared with the objects having the
+// same values in their scalar fields, found by the canonical form of these fields.
+func matchRemainingItems(items, otherItems []interface{}, used []bool, mustBeEqual bool, depth int) bool {
+	all := make([]int, len(otherItems))
+	for i := range otherItems {
+		all[i] = i
+	}
+	indexes := make(map[string]map[string][]int)
+	candidates := make([][]int, len(items))
+	for j, item := range items {
+		candidates[j] = all
+		if object, ok := item.(map[string]interface{}); ok {
+			if keys := scalarKeys(object); len(keys) > 0 {
+				joinedKeys := strings.Join(keys, "\x00")
+				byProjection, ok := indexes[joinedKeys]
+				if !ok {
+					byProjection = indexByScalarFields(otherItems, keys)
+					indexes[joinedKeys] = byProjection
 				}
-				if !found {
-					return false
-				}
+				projection, _ := scalarProjection(object, keys)
+				candidates[j] = byProjection[projection]
 			}
-			continue
 		}
-		if value != otherValue {
+	}
+	// the items are compared once, when an augmenting path first goes through them
+	matches := make(map[[2]int]bool)
+	itemMatches := func(j, i int) bool {
+		match, ok := matches[[2]int{j, i}]
+		if !ok {
+			match = jsonFieldMatches(items[j], otherItems[i], mustBeEqual, depth)
+			matches[[2]int{j, i}] = match
+		}
+		return match
+	}
+	// item of items assigned to each other item, -1 when none
+	assigned := make([]int, len(otherItems))
+	for i := range assigned {
+		assigned[i] = -1
+	}
+	// the other items are visited once per item being assigned, visited holds the last one that visited them
+	visited := make([]int, len(otherItems))
+	var augment func(j, round int) bool
+	augment = func(j, round int) bool {
+		for _, i := range candidates[j] {
+			if used[i] || visited[i] == round || !itemMatches(j, i) {
+				continue
+			}
+			visited[i] = round
+			if assigned[i] == -1 || augment(assigned[i], round) {
+				assigned[i] = j
+				return true
+			}
+		}
+		return false
+	}
+	for j := range items {
+		if !augment(j, j+1) {
 			return false
 		}
 	}
 	return true
 }
 
+// scalarKeys returns the sorted keys of the fields of the object that are neither objects nor arrays.
+func scalarKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key, value := range object {
+		switch value.(type) {
+		case map[string]interface{}, []interface{}, emptyArray:
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// scalarProjection returns the canonical form of the fields of the object with the keys, or false when one is missing.
+func scalarProjection(object map[string]interface{}, keys []string) (string, bool) {
+	projection := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		value, ok := object[key]
+		if !ok {
+			return "", false
+		}
+		projection[key] = value
+	}
+	canonical, err := canonicalJson(projection)
+	return canonical, err == nil
+}
+
+// indexByScalarFields returns the indexes of the objects of the items by the canonical form of their fields with the keys.
+func indexByScalarFields(items []interface{}, keys []string) map[string][]int {
+	index := make(map[string][]int)
+	for i, item := range items {
+		if object, ok := item.(map[string]interface{}); ok {
+			if projection, ok := scalarProjection(object, keys); ok {
+				index[projection] = append(index[projection], i)
+			}
+		}
+	}
+	return index
+}
+
+// StubVerification describes an expectation on how many times a stub was matched.
+type StubVerification struct {
+	FullMethod string       `json:"fullMethod"`
+	Request    *StubRequest `json:"request"`
+	Times      int          `json:"times"`
+	AtLeast    bool         `json:"atLeast"` // when true, Times is the minimum number of matches
+}
+
+type StubVerificationResult struct {
+	Verified   bool   `json:"verified"`
+	MatchCount int    `json:"matchCount"`
+	Message    string `json:"message"`
+}
+
+type StubUsage struct {
+	Stub       *Stub `json:"stub"`
+	MatchCount int   `json:"matchCount"`
+}
+
+func (v StubVerification) Verify(matchCount int) StubVerificationResult {
+	result := StubVerificationResult{
+		Verified:   matchCount == v.Times,
+		MatchCount: matchCount,
+	}
+	if v.AtLeast {
+		result.Verified = matchCount >= v.Times
+	}
+	if !result.Verified {
+		expectation := "exactly"
+		if v.AtLeast {
+			expectation = "at least"
+		}
+		result.Message = fmt.Sprintf("stub %s -> %s expected to be matched %s %d time(s) but was matched %d time(s)",
+			v.FullMethod, v.Request.String(), expectation, v.Times, matchCount)
+	}
+	return result
+}
+
 type InvalidStubResponse struct {
 	Errors  []string `json:"errors"`
 	Example Stub     `json:"example"`
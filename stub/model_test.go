@@ -1,7 +1,9 @@
 package stub
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -40,3 +42,161 @@ func TestJsonString_Equals_RightJsonStringContainsAllFieldsOfLeftJsonString(t *t
 	str2 := JsonString("{\"field1\":{\"subfieldd1\":\"value1\", \"subfield2\": 2}}")
 	assert.False(t, str1.Equals(str2))
 }
+
+func TestJsonString_Matches_RepeatedFieldsInAnyOrder(t *testing.T) {
+	str1 := JsonString(`{"ids":[3,1,2],"items":[{"name":"b"},{"name":"a"}],"tags":[["x","y"]]}`)
+	assert.True(t, str1.Equals(`{"ids":[1,2,3],"items":[{"name":"a"},{"name":"b"}],"tags":[["y","x"]]}`))
+	assert.True(t, str1.Matches(`{"ids":[1,2,3],"items":[{"name":"a","age":2},{"name":"b"}],"tags":[["y","x"]]}`))
+	assert.False(t, str1.Equals(`{"ids":[1,2,3],"items":[{"name":"a","age":2},{"name":"b"}],"tags":[["y","x"]]}`))
+	assert.False(t, str1.Matches(`{"ids":[1,2,4],"items":[{"name":"a"},{"name":"b"}],"tags":[["y","x"]]}`))
+}
+
+func TestJsonString_Matches_RepeatedFieldsAsMultisets(t *testing.T) {
+	str1 := JsonString(`{"ids":[1,1,2]}`)
+	assert.True(t, str1.Equals(`{"ids":[1,2,1]}`))
+	assert.False(t, str1.Equals(`{"ids":[1,2,2]}`))
+	str2 := JsonString(`{"items":[{"name":"a"},{"name":"a"}]}`)
+	assert.False(t, str2.Matches(`{"items":[{"name":"a","age":1},{"name":"b"}]}`))
+}
+
+func TestJsonString_Matches_RepeatedFieldsItemsWithSeveralMatches(t *testing.T) {
+	// the first item matches both items of the request, so it must leave the one the second item needs
+	str1 := JsonString(`{"items":[{"a":1},{"a":1,"b":{"x":1}}]}`)
+	assert.True(t, str1.Matches(`{"items":[{"a":1,"b":{"x":1,"y":2}},{"a":1,"b":{"z":3}}]}`))
+	assert.True(t, str1.Matches(`{"items":[{"a":1,"b":{"z":3}},{"a":1,"b":{"x":1,"y":2}}]}`))
+	assert.False(t, str1.Matches(`{"items":[{"a":1,"b":{"y":2}},{"a":1,"b":{"z":3}}]}`))
+}
+
+func TestJsonString_Equals_Numbers(t *testing.T) {
+	str1 := JsonString(`{"count":1,"ratio":0.5,"big":9007199254740993,"ids":[1,2]}`)
+	assert.True(t, str1.Equals(`{"count":1.0,"ratio":5e-1,"big":9007199254740993,"ids":[2e0,1]}`))
+	assert.False(t, str1.Equals(`{"count":1,"ratio":0.5,"big":9007199254740992,"ids":[1,2]}`))
+	assert.False(t, str1.Equals(`{"count":"1","ratio":0.5,"big":9007199254740993,"ids":[1,2]}`))
+	assert.False(t, str1.Equals(`{"count":1,"ratio":0.5,"big":9007199254740993,"ids":[1,2]} {}`))
+}
+
+func TestJsonString_Matches_InvalidJson(t *testing.T) {
+	str1 := JsonString(`{"name":`)
+	assert.False(t, str1.Matches(str1))
+	assert.False(t, str1.Equals(str1))
+	str2 := JsonString(`{}`)
+	assert.False(t, str2.Matches(str1))
+}
+
+func TestJsonString_UnmarshalJSON_InvalidJson(t *testing.T) {
+	var str JsonString
+	assert.EqualError(t, str.UnmarshalJSON([]byte(`{"name":`)), `invalid JSON {"name":: unexpected end of JSON input`)
+	assert.Nil(t, str.UnmarshalJSON([]byte(`{ "name": "John" }`)))
+	assert.Equal(t, JsonString(`{"name":"John"}`), str)
+}
+
+// BenchmarkJsonString_Matches_RepeatedField compares requests with 500 items in a repeated field.
+func BenchmarkJsonString_Matches_RepeatedField(b *testing.B) {
+	items, otherItems := make([]string, 0), make([]string, 0)
+	for i := 0; i < 500; i++ {
+		items = append(items, fmt.Sprintf(`{"id":%d}`, i))
+		otherItems = append(otherItems, fmt.Sprintf(`{"id":%d,"name":"item"}`, 499-i))
+	}
+	content, _ := parseJson(fmt.Sprintf(`{"items":[%s]}`, strings.Join(items, ",")))
+	request, _ := parseJson(fmt.Sprintf(`{"items":[%s]}`, strings.Join(otherItems, ",")))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !jsonValuesMatch(content, request, false) {
+			b.Fatal("the request doesn't match")
+		}
+	}
+}
+
+func TestJsonString_Merge_OverridesFieldsAndMergesNestedObjects(t *testing.T) {
+	str1 := JsonString("{\"field1\":{\"subfield1\":\"value1\", \"subfield2\": 2}, \"field2\": [1, 2], \"field3\": \"value3\"}")
+	str2 := JsonString("{\"field1\":{\"subfield2\": 3}, \"field2\": [], \"field4\": true}")
+	merged, err := str1.Merge(str2)
+	assert.Nil(t, err)
+	assert.True(t, merged.Equals(JsonString("{\"field1\":{\"subfield1\":\"value1\", \"subfield2\": 3}, \"field2\": [], \"field3\": \"value3\", \"field4\": true}")))
+}
+
+func TestJsonString_Merge_InvalidJson(t *testing.T) {
+	_, err := JsonString("{}").Merge(JsonString("not json"))
+	assert.Error(t, err)
+}
+
+func TestStub_IsValid_Passthrough(t *testing.T) {
+	s := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "passthrough",
+		Request:    &StubRequest{Match: "partial", Content: `{}`},
+		Forward:    &StubForward{ServerAddress: "localhost:1234"},
+	}
+	isValid, errMsgs := s.IsValid()
+	assert.True(t, isValid)
+	assert.Empty(t, errMsgs)
+
+	s.Forward.Replay = "exact"
+	s.Forward.AddMetadata = map[string][]string{"authorization": {"token"}}
+	isValid, errMsgs = s.IsValid()
+	assert.False(t, isValid)
+	assert.Contains(t, errMsgs, "Passthrough stubs can't replay or transform the response.")
+	assert.Contains(t, errMsgs, "Passthrough stubs can't change the metadata sent to the server.")
+}
+
+func TestStub_IsValid_ResponseDelay(t *testing.T) {
+	s := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &StubRequest{Match: "partial", Content: `{}`},
+		Response:   &StubResponse{Type: "success", Content: `{}`, Delay: "200ms"},
+	}
+	isValid, _ := s.IsValid()
+	assert.True(t, isValid)
+
+	s.Response.Delay = "soon"
+	isValid, errMsgs := s.IsValid()
+	assert.False(t, isValid)
+	assert.Contains(t, errMsgs, "Response delay 'soon' is not a valid duration.")
+}
+
+func TestStub_IsValid_Bandwidth(t *testing.T) {
+	s := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &StubRequest{Match: "partial", Content: `{}`},
+		Response:   &StubResponse{Type: "success", Content: `{}`, Bandwidth: "256KB/s"},
+	}
+	isValid, _ := s.IsValid()
+	assert.True(t, isValid)
+
+	s.Response.Bandwidth = "fast"
+	isValid, errMsgs := s.IsValid()
+	assert.False(t, isValid)
+	assert.Contains(t, errMsgs, "Response bandwidth 'fast' is not valid.")
+}
+
+func TestStub_IsValid_ErrorHeaders(t *testing.T) {
+	s := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &StubRequest{Match: "partial", Content: `{}`},
+		Response:   &StubResponse{Type: "error", Error: &ErrorResponse{Code: 5}, ErrorHeaders: "trailers-only"},
+	}
+	isValid, _ := s.IsValid()
+	assert.True(t, isValid)
+
+	s.Response.ErrorHeaders = "trailers"
+	isValid, errMsgs := s.IsValid()
+	assert.False(t, isValid)
+	assert.Contains(t, errMsgs, "Response error headers can only be either 'trailers-only' or 'headers-first'.")
+
+	s.Response = &StubResponse{Type: "success", Content: `{}`, ErrorHeaders: "headers-first"}
+	isValid, errMsgs = s.IsValid()
+	assert.False(t, isValid)
+	assert.Contains(t, errMsgs, "Response error headers can only be set when the response type is 'error'.")
+}
+
+func TestStub_ID(t *testing.T) {
+	s := &Stub{FullMethod: "/pkg.Service/Method", Request: &StubRequest{Match: "exact", Content: `{"name":"John"}`}}
+	same := &Stub{FullMethod: "/pkg.Service/Method", Type: "forward", Request: &StubRequest{Match: "exact", Content: `{"name":"John"}`}}
+	other := &Stub{FullMethod: "/pkg.Service/Method", Request: &StubRequest{Match: "exact", Content: `{"name":"Mary"}`}}
+	assert.Equal(t, 16, len(s.ID()))
+	assert.Equal(t, s.ID(), same.ID())
+	assert.NotEqual(t, s.ID(), other.ID())
+}
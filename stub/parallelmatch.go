@@ -0,0 +1,98 @@
+package stub
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultParallelMatchingThreshold is the number of stubs of a method from which the stubs matchers compare the calls with
+// them in parallel, see WithParallelMatching.
+const DefaultParallelMatchingThreshold = 256
+
+// WithParallelMatching compares the calls with the stubs of the methods with at least minCandidates stubs in parallel, by
+// chunks evaluated by a pool of GOMAXPROCS workers shared by the calls, so that the methods with hundreds of partial stubs
+// don't slow down the calls. The stub matched is the same as when the stubs are compared in order. The stubs are always
+// compared in order when minCandidates is 0.
+func WithParallelMatching(minCandidates int) MatcherOption {
+	return func(m *stubsMatcher) {
+		m.parallelThreshold = minCandidates
+	}
+}
+
+// newMatchWorkers returns the pool of workers of a stubs matcher, as a semaphore of the goroutines evaluating chunks.
+func newMatchWorkers() chan struct{} {
+	return make(chan struct{}, runtime.GOMAXPROCS(0))
+}
+
+// evaluate returns the first candidate matching the call, or else the last forwarding one matching it or forwardStub,
+// comparing the call with the candidates in parallel when there are enough of them.
+func (m *stubsMatcher) evaluate(candidates []matchCandidate, forwardStub *Stub, matches func(matchCandidate) bool) *Stub {
+	if m.parallelThreshold <= 0 || len(candidates) < m.parallelThreshold || cap(m.workers) < 2 {
+		return firstMatch(candidates, forwardStub, matches)
+	}
+	size := (len(candidates) + cap(m.workers) - 1) / cap(m.workers)
+	chunks := make([]chunkMatch, 0, cap(m.workers))
+	for start := 0; start < len(candidates); start += size {
+		end := start + size
+		if end > len(candidates) {
+			end = len(candidates)
+		}
+		chunks = append(chunks, chunkMatch{start: start, end: end, match: -1, forward: -1})
+	}
+	// index of the first candidate matched, so that the chunks after it stop early
+	first := int64(len(candidates))
+	var wg sync.WaitGroup
+	for i := range chunks {
+		select {
+		case m.workers <- struct{}{}:
+			wg.Add(1)
+			go func(chunk *chunkMatch) {
+				defer wg.Done()
+				defer func() { <-m.workers }()
+				chunk.evaluate(candidates, matches, &first)
+			}(&chunks[i])
+		default:
+			// the chunk is evaluated by the call when all the workers are busy
+			chunks[i].evaluate(candidates, matches, &first)
+		}
+	}
+	wg.Wait()
+
+	for _, chunk := range chunks {
+		if chunk.match >= 0 {
+			return candidates[chunk.match].stub
+		}
+	}
+	for i := len(chunks) - 1; i >= 0; i-- {
+		if chunks[i].forward >= 0 {
+			return candidates[chunks[i].forward].stub
+		}
+	}
+	return forwardStub
+}
+
+// chunkMatch holds the candidates of a chunk matching the call: the first one, and the last forwarding one.
+type chunkMatch struct {
+	start, end     int
+	match, forward int
+}
+
+func (c *chunkMatch) evaluate(candidates []matchCandidate, matches func(matchCandidate) bool, first *int64) {
+	for i := c.start; i < c.end && int64(i) < atomic.LoadInt64(first); i++ {
+		if !matches(candidates[i]) {
+			continue
+		}
+		if candidates[i].stub.IsForwarding() {
+			c.forward = i
+			continue
+		}
+		c.match = i
+		for current := atomic.LoadInt64(first); int64(i) < current; current = atomic.LoadInt64(first) {
+			if atomic.CompareAndSwapInt64(first, current, int64(i)) {
+				break
+			}
+		}
+		return
+	}
+}
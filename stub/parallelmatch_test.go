@@ -0,0 +1,85 @@
+package stub
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newTestCandidates(types ...StubType) []matchCandidate {
+	candidates := make([]matchCandidate, 0, len(types))
+	for i, stubType := range types {
+		candidates = append(candidates, matchCandidate{stub: &Stub{
+			FullMethod: "/pkg.Service/Method",
+			Type:       stubType,
+			Request:    &StubRequest{Match: "partial", Content: JsonString(fmt.Sprintf(`{"id":%d}`, i))},
+		}})
+	}
+	return candidates
+}
+
+func TestStubsMatcher_Evaluate_SameMatchAsInOrder(t *testing.T) {
+	matcher := NewStubsMatcher(NewInMemoryStubsStore(), WithParallelMatching(2)).(*stubsMatcher)
+	matcher.workers = make(chan struct{}, 4)
+	candidates := newTestCandidates("forward", "mock", "forward", "mock", "passthrough", "mock", "forward", "mock", "forward", "mock")
+	initial := &Stub{Type: "forward"}
+
+	for _, matching := range [][]int{{}, {0}, {0, 2, 8}, {7}, {3, 9}, {9}, {0, 1, 8}, {4, 6, 9}, {2, 4, 5, 6}} {
+		matches := func(candidate matchCandidate) bool {
+			for _, i := range matching {
+				if candidates[i].stub == candidate.stub {
+					return true
+				}
+			}
+			return false
+		}
+		for _, forwardStub := range []*Stub{nil, initial} {
+			assert.Equal(t, firstMatch(candidates, forwardStub, matches), matcher.evaluate(candidates, forwardStub, matches), "%v", matching)
+		}
+	}
+}
+
+func TestStubsMatcher_Match_Parallel(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store, WithMatchCache(0), WithParallelMatching(4)).(*stubsMatcher)
+	matcher.workers = make(chan struct{}, 4)
+	for id := 0; id < 100; id++ {
+		assert.Nil(t, store.Add(&Stub{
+			FullMethod: "/pkg.Service/Method",
+			Type:       "mock",
+			Request:    &StubRequest{Match: "partial", Content: JsonString(fmt.Sprintf(`{"id":%d}`, id))},
+		}))
+	}
+	ctx := context.Background()
+
+	for _, id := range []int{0, 42, 99} {
+		s := matcher.Match(ctx, "/pkg.Service/Method", fmt.Sprintf(`{"id":%d,"page":2}`, id))
+		assert.Equal(t, JsonString(fmt.Sprintf(`{"id":%d}`, id)), s.Request.Content)
+		assert.Equal(t, 1, store.GetMatchCount(s))
+	}
+	assert.Nil(t, matcher.Match(ctx, "/pkg.Service/Method", `{"id":100}`))
+}
+
+// BenchmarkStubsMatcher_MatchParallel matches calls against 1000 partial stubs of the same method in parallel.
+func BenchmarkStubsMatcher_MatchParallel(b *testing.B) {
+	store := NewInMemoryStubsStore()
+	for id := 0; id < 1000; id++ {
+		store.Add(&Stub{
+			FullMethod: "/pkg.Service/Method",
+			Type:       "mock",
+			Request:    &StubRequest{Match: "partial", Content: JsonString(fmt.Sprintf(`{"id":%d,"filter":{"active":true}}`, id))},
+		})
+	}
+	ctx := context.Background()
+	for _, threshold := range []int{0, DefaultParallelMatchingThreshold} {
+		matcher := NewStubsMatcher(store, WithMatchCache(0), WithParallelMatching(threshold))
+		b.Run(fmt.Sprintf("threshold=%d", threshold), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if matcher.Match(ctx, "/pkg.Service/Method", `{"id":999,"filter":{"active":true},"page":2}`) == nil {
+					b.Fatal("no stub matched")
+				}
+			}
+		})
+	}
+}
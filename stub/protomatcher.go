@@ -0,0 +1,177 @@
+package stub
+
+import (
+	"bytes"
+	"context"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"sync"
+)
+
+// WithProtoMatching compares the requests with the stubs as messages of the request type of their method, found in the
+// descriptors registered by the generated code, instead of as JSON objects. The presence of the fields, enums written with
+// their names or numbers, 64 bit integers and bytes then follow the protobuf semantics:
+//   - an exact stub matches the requests equal to its content, see proto.Equal. The repeated fields are in order.
+//   - a partial stub matches the requests with the fields set in its content. Messages are matched partially, repeated
+//     fields must have the same length with their items matched in order, and maps must contain the entries of the stub.
+//     An empty repeated field is not set, so its empty array only matches the calls without items when the stub sets
+//     StubRequest.EmptyArrays to empty.
+//
+// The methods without descriptors are matched as JSON.
+func WithProtoMatching() MatcherOption {
+	return func(m *stubsMatcher) {
+		m.protoMatching = true
+	}
+}
+
+// protoContent is the content of the request of a stub parsed in the request message of its method. It is parsed once,
+// on the first call compared with the stub, and shared by the copies of the candidate.
+type protoContent struct {
+	once    sync.Once
+	message protoreflect.Message
+	err     error
+}
+
+func (p *protoContent) parse(content JsonString, descriptor protoreflect.MessageDescriptor) (protoreflect.Message, error) {
+	p.once.Do(func() {
+		p.message, p.err = unmarshalProto(content.String(), descriptor)
+	})
+	return p.message, p.err
+}
+
+func unmarshalProto(data string, descriptor protoreflect.MessageDescriptor) (protoreflect.Message, error) {
+	message := dynamicpb.NewMessage(descriptor)
+	if data == "" {
+		return message, nil
+	}
+	if err := protojson.Unmarshal([]byte(data), message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// requestDescriptor returns the descriptor of the request message of the method, or nil when it is not registered.
+func (m *stubsMatcher) requestDescriptor(fullMethod string) protoreflect.MessageDescriptor {
+	if descriptor, ok := m.descriptors.Load(fullMethod); ok {
+		return descriptor.(protoreflect.MessageDescriptor)
+	}
+	method := methodDescriptor(fullMethod)
+	if method == nil {
+		return nil
+	}
+	m.descriptors.Store(fullMethod, method.Input())
+	return method.Input()
+}
+
+// methodDescriptor returns the descriptor of the method, or nil when its service is not registered in
+// protoregistry.GlobalFiles.
+func methodDescriptor(fullMethod string) protoreflect.MethodDescriptor {
+	service, method := splitFullMethod(fullMethod)
+	found, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil
+	}
+	serviceDescriptor, ok := found.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil
+	}
+	return serviceDescriptor.Methods().ByName(protoreflect.Name(method))
+}
+
+// matchProto returns the stub matching the request parsed in its message, with the same precedence as the JSON matching.
+// The stubs whose content can't be parsed in the message never match.
+func (m *stubsMatcher) matchProto(ctx context.Context, fullMethod string, candidates *methodCandidates, descriptor protoreflect.MessageDescriptor, requestJson string) *Stub {
+	request, err := unmarshalProto(requestJson, descriptor)
+	if err != nil {
+		return invalidRequestStub(fullMethod, err)
+	}
+	return m.resolve(ctx, fullMethod, candidates, protoCacheKey(request), func() *Stub {
+		return m.evaluate(candidates.sorted, nil, func(candidate matchCandidate) bool {
+			if candidate.stub.Request.Match == "custom" {
+				return matchCustom(ctx, candidate, requestJson)
+			}
+			content, err := candidate.message.parse(candidate.stub.Request.Content, descriptor)
+			if err != nil || !matchMetadata(ctx, candidate.metadata) {
+				return false
+			}
+			masked := request
+			if mask := candidate.protoRequestFieldMask(request); mask != nil {
+				content, masked = mask.applyProto(content), mask.applyProto(request)
+			}
+			switch candidate.stub.Request.Match {
+			case "exact":
+				return proto.Equal(content.Interface(), masked.Interface())
+			case "partial":
+				if candidate.stub.Request.EmptyArrays == "empty" && !protoEmptyArraysMatch(candidate.content, masked) {
+					return false
+				}
+				return protoMessageMatches(content, masked, 1)
+			}
+			return false
+		})
+	})
+}
+
+// protoCacheKey returns the request in the deterministic binary format, the same for equal messages, to look up the stub
+// resolved for it. The stub is resolved again when it can't be marshaled.
+func protoCacheKey(request protoreflect.Message) []byte {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(request.Interface())
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// protoMessageMatches tells whether the fields set in the message are set to matching values in the other message. As
+// with the JSON matching, the messages deeper than MaxContentDepth never match.
+func protoMessageMatches(message, other protoreflect.Message, depth int) bool {
+	if depth > MaxContentDepth() {
+		return false
+	}
+	matches := true
+	message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		matches = other.Has(field) && protoFieldMatches(field, value, other.Get(field), depth)
+		return matches
+	})
+	return matches
+}
+
+func protoFieldMatches(field protoreflect.FieldDescriptor, value, other protoreflect.Value, depth int) bool {
+	switch {
+	case field.IsList():
+		list, otherList := value.List(), other.List()
+		if list.Len() != otherList.Len() {
+			return false
+		}
+		for i := 0; i < list.Len(); i++ {
+			if !protoValueMatches(field, list.Get(i), otherList.Get(i), depth) {
+				return false
+			}
+		}
+		return true
+	case field.IsMap():
+		matches := true
+		otherMap := other.Map()
+		value.Map().Range(func(key protoreflect.MapKey, entry protoreflect.Value) bool {
+			matches = otherMap.Has(key) && protoValueMatches(field.MapValue(), entry, otherMap.Get(key), depth)
+			return matches
+		})
+		return matches
+	}
+	return protoValueMatches(field, value, other, depth)
+}
+
+// protoValueMatches compares single values of the field, e.g. items of a repeated field. depth is the one of the message
+// containing the field.
+func protoValueMatches(field protoreflect.FieldDescriptor, value, other protoreflect.Value, depth int) bool {
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return protoMessageMatches(value.Message(), other.Message(), depth+1)
+	case protoreflect.BytesKind:
+		return bytes.Equal(value.Bytes(), other.Bytes())
+	}
+	return value.Interface() == other.Interface()
+}
@@ -0,0 +1,105 @@
+package stub
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"sync"
+	"testing"
+)
+
+const protoMatchingMethod = "/pkg.protomatching.Service/Method"
+
+var registerProtoMatchingService sync.Once
+
+// registerProtoMatchingFile registers a service whose request has fields of the kinds compared differently as messages.
+func registerProtoMatchingFile(t *testing.T) {
+	registerProtoMatchingService.Do(func() {
+		field := func(name string, number int32, fieldType descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+			return newTestField(name, number, fieldType, false)
+		}
+		limit := newTestField("limit", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, true)
+		limit.OneofIndex = proto.Int32(0)
+		status := field("status", 3, descriptorpb.FieldDescriptorProto_TYPE_ENUM)
+		status.TypeName = proto.String(".google.protobuf.Syntax")
+		ids := field("ids", 5, descriptorpb.FieldDescriptorProto_TYPE_INT32)
+		ids.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		labels := field("labels", 6, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+		labels.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		labels.TypeName = proto.String(".pkg.protomatching.Request.LabelsEntry")
+		child := field("child", 7, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+		child.TypeName = proto.String(".pkg.protomatching.Request")
+		entry := &descriptorpb.DescriptorProto{
+			Name:    proto.String("LabelsEntry"),
+			Field:   []*descriptorpb.FieldDescriptorProto{field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING), field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING)},
+			Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+		}
+		file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+			Name:       proto.String("protomatching.proto"),
+			Package:    proto.String("pkg.protomatching"),
+			Syntax:     proto.String("proto3"),
+			Dependency: []string{"google/protobuf/type.proto"},
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("Request"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("total", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64), limit, status,
+					field("data", 4, descriptorpb.FieldDescriptorProto_TYPE_BYTES), ids, labels, child,
+				},
+				NestedType: []*descriptorpb.DescriptorProto{entry},
+				OneofDecl:  []*descriptorpb.OneofDescriptorProto{{Name: proto.String("_limit")}},
+			}},
+			Service: []*descriptorpb.ServiceDescriptorProto{{
+				Name: proto.String("Service"),
+				Method: []*descriptorpb.MethodDescriptorProto{{
+					Name:       proto.String("Method"),
+					InputType:  proto.String(".pkg.protomatching.Request"),
+					OutputType: proto.String(".pkg.protomatching.Request"),
+				}},
+			}},
+		}, protoregistry.GlobalFiles)
+		if err == nil {
+			err = protoregistry.GlobalFiles.RegisterFile(file)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestStubsMatcher_Match_ProtoMatching(t *testing.T) {
+	registerProtoMatchingFile(t)
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store, WithProtoMatching())
+	ctx := context.Background()
+	exact := &Stub{FullMethod: protoMatchingMethod, Type: "mock", Request: &StubRequest{Match: "exact", Content: `{"total":"10","status":"SYNTAX_PROTO3","data":"aGk="}`}}
+	partial := &Stub{FullMethod: protoMatchingMethod, Type: "mock", Request: &StubRequest{Match: "partial", Content: `{"limit":0,"ids":[1,2],"labels":{"tenant":"a"},"child":{"total":1}}`}}
+	assert.Nil(t, store.Add(exact))
+	assert.Nil(t, store.Add(partial))
+
+	// enums by number, 64 bit integers as numbers and bytes with the URL alphabet are the same values
+	assert.Equal(t, exact, matcher.Match(ctx, protoMatchingMethod, `{"total":10,"status":1,"data":"aGk"}`))
+	assert.Nil(t, matcher.Match(ctx, protoMatchingMethod, `{"total":"10","status":"SYNTAX_PROTO3","data":"aGk=","ids":[1]}`))
+
+	// the optional field set to its default value must be set in the request
+	request := `{"limit":0,"ids":[1,2],"labels":{"tenant":"a","region":"eu"},"child":{"total":"1","status":"SYNTAX_PROTO3"},"total":"5"}`
+	assert.Equal(t, partial, matcher.Match(ctx, protoMatchingMethod, request))
+	assert.Nil(t, matcher.Match(ctx, protoMatchingMethod, `{"ids":[1,2],"labels":{"tenant":"a"},"child":{"total":"1"}}`))
+	assert.Nil(t, matcher.Match(ctx, protoMatchingMethod, `{"limit":0,"ids":[2,1],"labels":{"tenant":"a"},"child":{"total":"1"}}`))
+	assert.Nil(t, matcher.Match(ctx, protoMatchingMethod, `{"limit":0,"ids":[1,2],"labels":{"tenant":"b"},"child":{"total":"1"}}`))
+	assert.Equal(t, 1, store.GetMatchCount(partial))
+
+	invalid := matcher.Match(ctx, protoMatchingMethod, `{"unknown":1}`)
+	assert.Equal(t, "error", invalid.Response.Type)
+}
+
+func TestStubsMatcher_Match_ProtoMatchingWithoutDescriptor(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store, WithProtoMatching())
+	s := &Stub{FullMethod: "/pkg.Unknown/Method", Type: "mock", Request: &StubRequest{Match: "partial", Content: `{"name":"John"}`}}
+	assert.Nil(t, store.Add(s))
+
+	assert.Equal(t, s, matcher.Match(context.Background(), "/pkg.Unknown/Method", `{"name":"John","age":2}`))
+}
@@ -0,0 +1,101 @@
+package stub
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+)
+
+// RecordingFilter controls which forwarded calls are recorded.
+// A call is recorded when it matches at least one of the include rules (or there are none) and none of the exclude rules.
+type RecordingFilter struct {
+	Include []RecordingRule `json:"include"`
+	Exclude []RecordingRule `json:"exclude"`
+}
+
+// RecordingRule matches a recording when all the conditions provided match.
+type RecordingRule struct {
+	FullMethod string            `json:"fullMethod"` // pattern as in path.Match, e.g. /carvalhorr.greeter.Greeter/*
+	Metadata   map[string]string `json:"metadata"`   // regular expressions that one of the values of each key must match
+	Codes      []uint32          `json:"codes"`      // gRPC status codes of the response. 0 (OK) for successful responses.
+}
+
+func (f *RecordingFilter) Validate() error {
+	for _, rule := range append(append([]RecordingRule{}, f.Include...), f.Exclude...) {
+		if _, err := path.Match(rule.FullMethod, ""); err != nil {
+			return fmt.Errorf("invalid fullMethod pattern '%s'", rule.FullMethod)
+		}
+		for key, expression := range rule.Metadata {
+			if _, err := regexp.Compile(expression); err != nil {
+				return fmt.Errorf("invalid regular expression '%s' for metadata '%s'", expression, key)
+			}
+		}
+	}
+	return nil
+}
+
+// Allows returns true if the recording must be kept.
+func (f *RecordingFilter) Allows(recording *Stub) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Include) > 0 && !matchesAnyRule(f.Include, recording) {
+		return false
+	}
+	return !matchesAnyRule(f.Exclude, recording)
+}
+
+func matchesAnyRule(rules []RecordingRule, recording *Stub) bool {
+	for _, rule := range rules {
+		if rule.matches(recording) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r RecordingRule) matches(recording *Stub) bool {
+	if r.FullMethod != "" {
+		if matched, _ := path.Match(r.FullMethod, recording.FullMethod); !matched {
+			return false
+		}
+	}
+	for key, expression := range r.Metadata {
+		if !metadataMatches(recording.Request.Metadata[key], expression) {
+			return false
+		}
+	}
+	if len(r.Codes) > 0 && !containsCode(r.Codes, responseCode(recording)) {
+		return false
+	}
+	return true
+}
+
+func metadataMatches(values []string, expression string) bool {
+	re, err := regexp.Compile(expression)
+	if err != nil {
+		return false
+	}
+	for _, value := range values {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func responseCode(recording *Stub) uint32 {
+	if recording.Response == nil || recording.Response.Error == nil {
+		return 0
+	}
+	return recording.Response.Error.Code
+}
+
+func containsCode(codes []uint32, code uint32) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package stub
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func createRecording(fullMethod string, metadata map[string][]string, code uint32) *Stub {
+	recording := &Stub{
+		FullMethod: fullMethod,
+		Request:    &StubRequest{Metadata: metadata},
+		Response:   &StubResponse{Type: "success"},
+	}
+	if code != 0 {
+		recording.Response = &StubResponse{Type: "error", Error: &ErrorResponse{Code: code}}
+	}
+	return recording
+}
+
+func TestRecordingFilter_Allows_NilFilterAllowsEverything(t *testing.T) {
+	var filter *RecordingFilter
+	assert.True(t, filter.Allows(createRecording("/pkg.Service/Method", nil, 0)))
+}
+
+func TestRecordingFilter_Allows_Include(t *testing.T) {
+	filter := &RecordingFilter{
+		Include: []RecordingRule{
+			{FullMethod: "/pkg.Service/Get*"},
+			{Metadata: map[string]string{"x-tenant": "^test-"}},
+		},
+	}
+	assert.True(t, filter.Allows(createRecording("/pkg.Service/GetOrder", nil, 0)))
+	assert.True(t, filter.Allows(createRecording("/pkg.Service/AddOrder", map[string][]string{"x-tenant": {"prod", "test-1"}}, 0)))
+	assert.False(t, filter.Allows(createRecording("/pkg.Service/AddOrder", map[string][]string{"x-tenant": {"prod"}}, 0)))
+	assert.False(t, filter.Allows(createRecording("/pkg.Other/GetOrder", nil, 0)))
+}
+
+func TestRecordingFilter_Allows_Exclude(t *testing.T) {
+	filter := &RecordingFilter{
+		Exclude: []RecordingRule{
+			{FullMethod: "/pkg.Service/*", Codes: []uint32{5, 14}},
+		},
+	}
+	assert.True(t, filter.Allows(createRecording("/pkg.Service/GetOrder", nil, 0)))
+	assert.False(t, filter.Allows(createRecording("/pkg.Service/GetOrder", nil, 5)))
+	assert.True(t, filter.Allows(createRecording("/pkg.Other/GetOrder", nil, 14)))
+}
+
+func TestRecordingFilter_Validate(t *testing.T) {
+	assert.Nil(t, (&RecordingFilter{Include: []RecordingRule{{FullMethod: "/pkg.*/*", Metadata: map[string]string{"key": "a|b"}}}}).Validate())
+	assert.EqualError(t, (&RecordingFilter{Exclude: []RecordingRule{{FullMethod: "/pkg.[/*"}}}).Validate(), "invalid fullMethod pattern '/pkg.[/*'")
+	assert.EqualError(t, (&RecordingFilter{Include: []RecordingRule{{Metadata: map[string]string{"key": "(a"}}}}).Validate(), "invalid regular expression '(a' for metadata 'key'")
+}
@@ -0,0 +1,214 @@
+package stub
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const defaultRedactionPlaceholder = "[REDACTED]"
+
+// RecordingRedaction replaces sensitive values in recorded calls with a placeholder before they are stored.
+type RecordingRedaction struct {
+	Fields      []string `json:"fields"`      // dot separated paths of fields in the request and response content using the JSON names, e.g. user.password
+	Metadata    []string `json:"metadata"`    // metadata keys whose values are redacted, e.g. authorization
+	Patterns    []string `json:"patterns"`    // regular expressions. Matching parts of string values in the content, metadata and error message are redacted.
+	Placeholder string   `json:"placeholder"` // defaults to [REDACTED]
+}
+
+func (r *RecordingRedaction) Validate() error {
+	for _, field := range r.Fields {
+		if field == "" || strings.HasPrefix(field, ".") || strings.HasSuffix(field, ".") || strings.Contains(field, "..") {
+			return fmt.Errorf("invalid field path '%s'", field)
+		}
+	}
+	for _, expression := range r.Patterns {
+		if _, err := regexp.Compile(expression); err != nil {
+			return fmt.Errorf("invalid regular expression '%s'", expression)
+		}
+	}
+	return nil
+}
+
+// Redact replaces the sensitive values of the recording in place.
+func (r *RecordingRedaction) Redact(recording *Stub) error {
+	if r == nil {
+		return nil
+	}
+	patterns, err := r.compilePatterns()
+	if err != nil {
+		return err
+	}
+	if recording.Request != nil {
+		if err := r.redactRequest(recording.Request, patterns); err != nil {
+			return err
+		}
+	}
+	if recording.Response != nil {
+		if err := r.redactResponse(recording.Response, patterns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RedactContent returns the JSON content with the sensitive values replaced, e.g. to log the bodies of the calls.
+func (r *RecordingRedaction) RedactContent(content JsonString) (JsonString, error) {
+	if r == nil {
+		return content, nil
+	}
+	patterns, err := r.compilePatterns()
+	if err != nil {
+		return content, err
+	}
+	return r.redactContent(content, patterns)
+}
+
+// RedactString replaces the parts of the value matching the patterns. Invalid patterns are ignored.
+func (r *RecordingRedaction) RedactString(value string) string {
+	if r == nil {
+		return value
+	}
+	patterns, _ := r.compilePatterns()
+	return r.redactString(value, patterns)
+}
+
+func (r *RecordingRedaction) compilePatterns() ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, 0, len(r.Patterns))
+	for _, expression := range r.Patterns {
+		re, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression '%s'", expression)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+func (r *RecordingRedaction) redactRequest(request *StubRequest, patterns []*regexp.Regexp) (err error) {
+	if request.Content, err = r.redactContent(request.Content, patterns); err != nil {
+		return err
+	}
+	for i, message := range request.Stream {
+		if request.Stream[i], err = r.redactContent(message, patterns); err != nil {
+			return err
+		}
+	}
+	if request.Metadata == nil {
+		return nil
+	}
+	// the metadata can be shared with the incoming call so a new map is created
+	redactedMetadata := make(map[string][]string, len(request.Metadata))
+	for key, values := range request.Metadata {
+		redactedValues := make([]string, 0, len(values))
+		for _, value := range values {
+			if r.isRedactedMetadataKey(key) {
+				redactedValues = append(redactedValues, r.placeholder())
+			} else {
+				redactedValues = append(redactedValues, r.redactString(value, patterns))
+			}
+		}
+		redactedMetadata[key] = redactedValues
+	}
+	request.Metadata = redactedMetadata
+	return nil
+}
+
+func (r *RecordingRedaction) redactResponse(response *StubResponse, patterns []*regexp.Regexp) (err error) {
+	if response.Content, err = r.redactContent(response.Content, patterns); err != nil {
+		return err
+	}
+	for i, message := range response.Stream {
+		if response.Stream[i], err = r.redactContent(message, patterns); err != nil {
+			return err
+		}
+	}
+	if response.Error != nil {
+		response.Error.Message = r.redactString(response.Error.Message, patterns)
+	}
+	return nil
+}
+
+func (r *RecordingRedaction) redactContent(content JsonString, patterns []*regexp.Regexp) (JsonString, error) {
+	if content == "" || (len(r.Fields) == 0 && len(patterns) == 0) {
+		return content, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return content, fmt.Errorf("could not redact content: %w", err)
+	}
+	for _, field := range r.Fields {
+		value = r.redactField(value, strings.Split(field, "."))
+	}
+	value = r.redactValue(value, patterns)
+	data, err := json.Marshal(value)
+	if err != nil {
+		return content, fmt.Errorf("could not redact content: %w", err)
+	}
+	return JsonString(data), nil
+}
+
+// redactField replaces the value at the path. Lists are traversed so that the path applies to every element.
+func (r *RecordingRedaction) redactField(value interface{}, path []string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		fieldValue, ok := v[path[0]]
+		if !ok {
+			return v
+		}
+		if len(path) == 1 {
+			v[path[0]] = r.placeholder()
+		} else {
+			v[path[0]] = r.redactField(fieldValue, path[1:])
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.redactField(item, path)
+		}
+		return v
+	}
+	return value
+}
+
+func (r *RecordingRedaction) redactValue(value interface{}, patterns []*regexp.Regexp) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, fieldValue := range v {
+			v[key] = r.redactValue(fieldValue, patterns)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.redactValue(item, patterns)
+		}
+		return v
+	case string:
+		return r.redactString(v, patterns)
+	}
+	return value
+}
+
+func (r *RecordingRedaction) redactString(value string, patterns []*regexp.Regexp) string {
+	for _, re := range patterns {
+		value = re.ReplaceAllString(value, r.placeholder())
+	}
+	return value
+}
+
+func (r *RecordingRedaction) isRedactedMetadataKey(key string) bool {
+	for _, redacted := range r.Metadata {
+		if strings.EqualFold(redacted, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RecordingRedaction) placeholder() string {
+	if r.Placeholder == "" {
+		return defaultRedactionPlaceholder
+	}
+	return r.Placeholder
+}
@@ -0,0 +1,66 @@
+package stub
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRecordingRedaction_Redact_NilRedactionKeepsRecording(t *testing.T) {
+	var redaction *RecordingRedaction
+	recording := &Stub{Request: &StubRequest{Content: `{"password":"secret"}`}}
+	assert.Nil(t, redaction.Redact(recording))
+	assert.Equal(t, JsonString(`{"password":"secret"}`), recording.Request.Content)
+}
+
+func TestRecordingRedaction_Redact_Fields(t *testing.T) {
+	redaction := &RecordingRedaction{Fields: []string{"user.password", "items.token"}}
+	recording := &Stub{
+		Request: &StubRequest{Content: `{"user":{"name":"John","password":"secret"},"items":[{"token":"a"},{"token":"b"}]}`},
+		Response: &StubResponse{
+			Content: `{"user":{"password":"secret"}}`,
+			Stream:  []JsonString{`{"items":[{"token":"c"}]}`},
+		},
+	}
+	assert.Nil(t, redaction.Redact(recording))
+	assert.True(t, recording.Request.Content.Equals(`{"user":{"name":"John","password":"[REDACTED]"},"items":[{"token":"[REDACTED]"},{"token":"[REDACTED]"}]}`))
+	assert.True(t, recording.Response.Content.Equals(`{"user":{"password":"[REDACTED]"}}`))
+	assert.True(t, recording.Response.Stream[0].Equals(`{"items":[{"token":"[REDACTED]"}]}`))
+}
+
+func TestRecordingRedaction_Redact_MetadataAndPatterns(t *testing.T) {
+	redaction := &RecordingRedaction{
+		Metadata:    []string{"Authorization"},
+		Patterns:    []string{`[a-z]+@example\.com`},
+		Placeholder: "***",
+	}
+	metadata := map[string][]string{"authorization": {"Bearer token"}, "x-user": {"john@example.com"}}
+	recording := &Stub{
+		Request:  &StubRequest{Content: `{"email":"john@example.com"}`, Metadata: metadata},
+		Response: &StubResponse{Content: `{}`, Error: &ErrorResponse{Message: "unknown user mary@example.com"}},
+	}
+	assert.Nil(t, redaction.Redact(recording))
+	assert.Equal(t, map[string][]string{"authorization": {"***"}, "x-user": {"***"}}, recording.Request.Metadata)
+	assert.Equal(t, "Bearer token", metadata["authorization"][0])
+	assert.True(t, recording.Request.Content.Equals(`{"email":"***"}`))
+	assert.Equal(t, "unknown user ***", recording.Response.Error.Message)
+}
+
+func TestRecordingRedaction_Validate(t *testing.T) {
+	assert.Nil(t, (&RecordingRedaction{Fields: []string{"user.password"}, Patterns: []string{"^Bearer "}}).Validate())
+	assert.EqualError(t, (&RecordingRedaction{Fields: []string{"user..password"}}).Validate(), "invalid field path 'user..password'")
+	assert.EqualError(t, (&RecordingRedaction{Patterns: []string{"("}}).Validate(), "invalid regular expression '('")
+}
+
+func TestRecordingRedaction_RedactContentAndString(t *testing.T) {
+	redaction := &RecordingRedaction{Fields: []string{"password"}, Patterns: []string{`\d{4}-\d{4}`}}
+	content, err := redaction.RedactContent(`{"password":"secret","card":"1234-5678"}`)
+	assert.Nil(t, err)
+	assert.True(t, content.Equals(`{"password":"[REDACTED]","card":"[REDACTED]"}`))
+	assert.Equal(t, "invalid card [REDACTED]", redaction.RedactString("invalid card 1234-5678"))
+
+	var nilRedaction *RecordingRedaction
+	content, err = nilRedaction.RedactContent(`{"password":"secret"}`)
+	assert.Nil(t, err)
+	assert.Equal(t, JsonString(`{"password":"secret"}`), content)
+	assert.Equal(t, "secret", nilRedaction.RedactString("secret"))
+}
@@ -0,0 +1,69 @@
+package stub
+
+import "sync"
+
+// RecordingsPage is a part of the recordings of a store, in the order they were added, so that the recordings can be
+// followed without reading all of them again, e.g. by protoc-gen-mock-ctl tail.
+type RecordingsPage struct {
+	Recordings []*Stub `json:"recordings"`
+	// Number of recordings in the store
+	Total int `json:"total"`
+}
+
+// RecordingsPager is implemented by the recordings stores keeping the order the recordings were added in.
+type RecordingsPager interface {
+	// GetRecordingsPage returns up to limit recordings after the first offset ones, all of them when limit is zero.
+	GetRecordingsPage(offset, limit int) RecordingsPage
+}
+
+// GetRecordingsPage returns up to limit recordings of the store after the first offset ones, all of them when limit is
+// zero. They are in the order they were added when the store implements RecordingsPager, and in the order of GetAllStubs
+// otherwise.
+func GetRecordingsPage(store RecordingsStore, offset, limit int) RecordingsPage {
+	if pager, ok := store.(RecordingsPager); ok {
+		return pager.GetRecordingsPage(offset, limit)
+	}
+	recordings := store.GetAllStubs()
+	start, end := pageBounds(len(recordings), offset, limit)
+	return RecordingsPage{Recordings: recordings[start:end], Total: len(recordings)}
+}
+
+// pageBounds returns the bounds of the page of up to limit items after the first offset ones, all of them when limit is
+// zero.
+func pageBounds(total, offset, limit int) (start, end int) {
+	if offset > total {
+		offset = total
+	}
+	if limit <= 0 || offset+limit > total {
+		return offset, total
+	}
+	return offset, offset + limit
+}
+
+// inMemoryRecordingsStore keeps the recordings in memory, with the order they were added in.
+type inMemoryRecordingsStore struct {
+	recordings *inMemoryStubsStore
+	added      []*Stub
+	mutex      sync.RWMutex
+}
+
+func (s *inMemoryRecordingsStore) Add(e *Stub) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.recordings.Add(e); err != nil {
+		return err
+	}
+	s.added = append(s.added, e)
+	return nil
+}
+
+func (s *inMemoryRecordingsStore) GetAllStubs() []*Stub {
+	return s.recordings.GetAllStubs()
+}
+
+func (s *inMemoryRecordingsStore) GetRecordingsPage(offset, limit int) RecordingsPage {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	start, end := pageBounds(len(s.added), offset, limit)
+	return RecordingsPage{Recordings: append([]*Stub{}, s.added[start:end]...), Total: len(s.added)}
+}
@@ -0,0 +1,28 @@
+package stub
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetRecordingsPage(t *testing.T) {
+	store := NewRecordingsStore()
+	recordings := []*Stub{newTestRecording(3, `{}`), newTestRecording(1, `{}`), newTestRecording(3, `{}`), newTestRecording(2, `{}`)}
+	for _, recording := range recordings {
+		assert.Nil(t, store.Add(recording))
+	}
+
+	// in the order they were added instead of the order of GetAllStubs
+	assert.Equal(t, []*Stub{recordings[1], recordings[3], recordings[0], recordings[2]}, store.GetAllStubs())
+	assert.Equal(t, RecordingsPage{Recordings: recordings, Total: 4}, GetRecordingsPage(store, 0, 0))
+	assert.Equal(t, RecordingsPage{Recordings: recordings[1:3], Total: 4}, GetRecordingsPage(store, 1, 2))
+	assert.Equal(t, RecordingsPage{Recordings: recordings[3:], Total: 4}, GetRecordingsPage(store, 3, 10))
+	assert.Equal(t, RecordingsPage{Recordings: []*Stub{}, Total: 4}, GetRecordingsPage(store, 4, 0))
+
+	// the stores without the order of the recordings are paged in the order of GetAllStubs
+	stubsStore := NewInMemoryStubsStore()
+	for _, recording := range recordings[:2] {
+		assert.Nil(t, stubsStore.Add(recording))
+	}
+	assert.Equal(t, RecordingsPage{Recordings: []*Stub{recordings[0]}, Total: 2}, GetRecordingsPage(stubsStore, 1, 0))
+}
@@ -0,0 +1,74 @@
+package stub
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// DefaultRemoteTimeout is the time the remote responders have to respond when the stub has no timeout.
+const DefaultRemoteTimeout = 5 * time.Second
+
+// StubRemote is the endpoint creating the responses of the stubs whose response type is remote, e.g. a service written
+// in any language. It receives the call in JSON: {"fullMethod": ..., "request": {...}, "metadata": {...}}, POSTed to
+// the HTTP URL or sent as a google.protobuf.Value to the gRPC method, and returns the content of the response in JSON.
+type StubRemote struct {
+	Url string `json:"url,omitempty"` // http or https URL
+	// Address of the gRPC server and full method called, e.g. /responder.Responder/Respond, whose request and response
+	// are google.protobuf.Value
+	Address string          `json:"address,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	TLS     *StubForwardTLS `json:"tls,omitempty"`     // optional, for the gRPC server. Plaintext is used when not provided.
+	Timeout string          `json:"timeout,omitempty"` // e.g. 500ms. Defaults to DefaultRemoteTimeout. The client deadline still applies.
+	// Response sent when the endpoint fails, times out or returns a content that is not valid. The call fails with
+	// UNAVAILABLE when not provided.
+	Fallback *StubResponse `json:"fallback,omitempty"`
+}
+
+// RemoteTimeout returns the time the remote responder has to respond.
+func (r *StubRemote) RemoteTimeout() time.Duration {
+	if timeout, err := time.ParseDuration(r.Timeout); err == nil {
+		return timeout
+	}
+	return DefaultRemoteTimeout
+}
+
+func (r *StubResponse) isValidRemote() (errMsgs []string) {
+	if r.Type != "remote" {
+		if r.Remote != nil {
+			errMsgs = append(errMsgs, "Response remote can only be set when the response type is 'remote'.")
+		}
+		return errMsgs
+	}
+	remote := r.Remote
+	if remote == nil {
+		return append(errMsgs, "Response remote is mandatory when the response type is 'remote'.")
+	}
+	if (remote.Url == "") == (remote.Address == "") {
+		errMsgs = append(errMsgs, "Response remote must have either url or address.")
+	}
+	if remote.Url != "" {
+		if parsed, err := url.Parse(remote.Url); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			errMsgs = append(errMsgs, fmt.Sprintf("Response remote url '%s' is not an HTTP URL.", remote.Url))
+		}
+	}
+	if remote.Address != "" && remote.Method == "" {
+		errMsgs = append(errMsgs, "Response remote method is mandatory when the address is set.")
+	}
+	if remote.Timeout != "" {
+		if _, err := time.ParseDuration(remote.Timeout); err != nil {
+			errMsgs = append(errMsgs, fmt.Sprintf("Response remote timeout '%s' is not a valid duration.", remote.Timeout))
+		}
+	}
+	if fallback := remote.Fallback; fallback != nil {
+		switch {
+		case fallback.Type != "success" && fallback.Type != "error":
+			errMsgs = append(errMsgs, "Response remote fallback type can only be either 'error' or 'success'.")
+		case fallback.Type == "success" && fallback.Content == "":
+			errMsgs = append(errMsgs, "Response remote fallback content is mandatory when its type is 'success'.")
+		case fallback.Type == "error" && fallback.Error == nil:
+			errMsgs = append(errMsgs, "Response remote fallback error is mandatory when its type is 'error'.")
+		}
+	}
+	return errMsgs
+}
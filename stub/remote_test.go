@@ -0,0 +1,50 @@
+package stub
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestStub_IsValid_Remote(t *testing.T) {
+	newStub := func(response *StubResponse) *Stub {
+		return &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "partial", Content: "{}"}, Response: response}
+	}
+	tests := []struct {
+		name   string
+		stub   *Stub
+		errors []string
+	}{
+		{"http", newStub(&StubResponse{Type: "remote", Remote: &StubRemote{Url: "http://localhost:8080/respond", Timeout: "1s",
+			Fallback: &StubResponse{Type: "success", Content: "{}"}}}), nil},
+		{"grpc", newStub(&StubResponse{Type: "remote", Remote: &StubRemote{Address: "localhost:9090", Method: "/responder.Responder/Respond"}}), nil},
+		{"missing remote", newStub(&StubResponse{Type: "remote"}),
+			[]string{"Response remote is mandatory when the response type is 'remote'."}},
+		{"remote of a success response", newStub(&StubResponse{Type: "success", Content: "{}", Remote: &StubRemote{Url: "http://localhost"}}),
+			[]string{"Response remote can only be set when the response type is 'remote'."}},
+		{"url and address", newStub(&StubResponse{Type: "remote", Remote: &StubRemote{Url: "http://localhost", Address: "localhost:9090", Method: "/a.B/C"}}),
+			[]string{"Response remote must have either url or address."}},
+		{"not an http url", newStub(&StubResponse{Type: "remote", Remote: &StubRemote{Url: "ftp://localhost"}}),
+			[]string{"Response remote url 'ftp://localhost' is not an HTTP URL."}},
+		{"missing method", newStub(&StubResponse{Type: "remote", Remote: &StubRemote{Address: "localhost:9090"}}),
+			[]string{"Response remote method is mandatory when the address is set."}},
+		{"invalid timeout", newStub(&StubResponse{Type: "remote", Remote: &StubRemote{Url: "http://localhost", Timeout: "soon"}}),
+			[]string{"Response remote timeout 'soon' is not a valid duration."}},
+		{"invalid fallback", newStub(&StubResponse{Type: "remote", Remote: &StubRemote{Url: "http://localhost", Fallback: &StubResponse{Type: "remote"}}}),
+			[]string{"Response remote fallback type can only be either 'error' or 'success'."}},
+		{"fallback without error", newStub(&StubResponse{Type: "remote", Remote: &StubRemote{Url: "http://localhost", Fallback: &StubResponse{Type: "error"}}}),
+			[]string{"Response remote fallback error is mandatory when its type is 'error'."}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			isValid, errors := test.stub.IsValid()
+			assert.Equal(t, len(test.errors) == 0, isValid)
+			assert.Equal(t, test.errors, errors)
+		})
+	}
+}
+
+func TestStubRemote_RemoteTimeout(t *testing.T) {
+	assert.Equal(t, DefaultRemoteTimeout, (&StubRemote{}).RemoteTimeout())
+	assert.Equal(t, 250*time.Millisecond, (&StubRemote{Timeout: "250ms"}).RemoteTimeout())
+}
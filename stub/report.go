@@ -0,0 +1,148 @@
+package stub
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// UnmatchedRequest is a call received without a matching stub.
+type UnmatchedRequest struct {
+	FullMethod string     `json:"fullMethod"`
+	Content    JsonString `json:"content"` // first message of the request
+	Time       time.Time  `json:"time"`
+}
+
+// Report lists the requests without a matching stub and the stubs never matched, e.g. at the end of a test session, to
+// find the stubs that no longer match what the clients send.
+type Report struct {
+	UnmatchedRequests []UnmatchedRequest `json:"unmatchedRequests"`
+	// All the stubs with the number of calls they matched
+	Stubs []StubUsage `json:"stubs"`
+}
+
+// NewReport creates the report of the stubs of the stores and the unmatched requests.
+func NewReport(unmatched []UnmatchedRequest, stores ...StubsStore) Report {
+	report := Report{UnmatchedRequests: append(make([]UnmatchedRequest, 0), unmatched...), Stubs: make([]StubUsage, 0)}
+	for _, store := range stores {
+		for _, s := range store.GetAllStubs() {
+			report.Stubs = append(report.Stubs, StubUsage{Stub: s, MatchCount: store.GetMatchCount(s)})
+		}
+	}
+	sort.SliceStable(report.Stubs, func(i, j int) bool {
+		if report.Stubs[i].Stub.FullMethod != report.Stubs[j].Stub.FullMethod {
+			return report.Stubs[i].Stub.FullMethod < report.Stubs[j].Stub.FullMethod
+		}
+		return report.Stubs[i].Stub.Request.String() < report.Stubs[j].Stub.Request.String()
+	})
+	return report
+}
+
+// UnusedStubs returns the stubs that were never matched.
+func (r Report) UnusedStubs() []*Stub {
+	unused := make([]*Stub, 0)
+	for _, usage := range r.Stubs {
+		if usage.MatchCount == 0 {
+			unused = append(unused, usage.Stub)
+		}
+	}
+	return unused
+}
+
+// Failed tells whether there are unmatched requests or unused stubs.
+func (r Report) Failed() bool {
+	return len(r.UnmatchedRequests) > 0 || len(r.UnusedStubs()) > 0
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+// JUnit returns the report in the JUnit XML format read by the CI servers. Each unmatched request is a failed test case
+// of the suite "unmatched requests", and each stub a test case of the suite "stubs" that fails when it was never matched.
+// The test cases are named after the service and the method of the call.
+func (r Report) JUnit() ([]byte, error) {
+	requests := junitTestSuite{Name: "unmatched requests", Cases: make([]junitTestCase, 0)}
+	for _, request := range r.UnmatchedRequests {
+		service, method := splitFullMethod(request.FullMethod)
+		requests.Cases = append(requests.Cases, junitTestCase{
+			ClassName: service,
+			Name:      method,
+			Failure: &junitFailure{
+				Message: fmt.Sprintf("no stub matched the request to %s", request.FullMethod),
+				Type:    "UnmatchedRequest",
+				Text:    request.Content.String(),
+			},
+		})
+	}
+	requests.Tests, requests.Failures = len(requests.Cases), len(requests.Cases)
+
+	stubs := junitTestSuite{Name: "stubs", Cases: make([]junitTestCase, 0)}
+	for _, usage := range r.Stubs {
+		service, method := splitFullMethod(usage.Stub.FullMethod)
+		testCase := junitTestCase{ClassName: service, Name: method}
+		if usage.Stub.Name != "" {
+			testCase.Name = fmt.Sprintf("%s %s", method, usage.Stub.Name)
+		} else if usage.Stub.Request != nil {
+			testCase.Name = fmt.Sprintf("%s %s", method, usage.Stub.Request.Content)
+		}
+		if usage.MatchCount == 0 {
+			data, err := json.MarshalIndent(usage.Stub, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			testCase.Failure = &junitFailure{Message: "the stub was never matched", Type: "UnusedStub", Text: string(data)}
+			stubs.Failures++
+		}
+		stubs.Cases = append(stubs.Cases, testCase)
+	}
+	stubs.Tests = len(stubs.Cases)
+
+	suites := junitTestSuites{
+		Name:     "protoc-gen-mock",
+		Tests:    requests.Tests + stubs.Tests,
+		Failures: requests.Failures + stubs.Failures,
+		Suites:   []junitTestSuite{requests, stubs},
+	}
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// splitFullMethod returns the service and the method of a full method, e.g. pkg.Service and Method for /pkg.Service/Method.
+func splitFullMethod(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	i := strings.LastIndex(fullMethod, "/")
+	if i < 0 {
+		return "", fullMethod
+	}
+	return fullMethod[:i], fullMethod[i+1:]
+}
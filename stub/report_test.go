@@ -0,0 +1,58 @@
+package stub
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestReport_JUnit(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	used := &Stub{FullMethod: "/pkg.Service/Hello", Type: "mock", Request: &StubRequest{Match: "exact", Content: `{"name":"John"}`}}
+	unused := &Stub{FullMethod: "/pkg.Service/Hello", Type: "mock", Request: &StubRequest{Match: "exact", Content: `{"name":"Mary"}`}}
+	store.Add(used)
+	store.Add(unused)
+	store.RecordMatch(used)
+	unmatched := []UnmatchedRequest{{FullMethod: "/pkg.Service/Bye", Content: `{"name":"<Ann>"}`, Time: time.Unix(0, 0)}}
+
+	report := NewReport(unmatched, store)
+	assert.True(t, report.Failed())
+	assert.Equal(t, []*Stub{unused}, report.UnusedStubs())
+	data, err := report.JUnit()
+	assert.Nil(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="protoc-gen-mock" tests="3" failures="2">
+  <testsuite name="unmatched requests" tests="1" failures="1">
+    <testcase classname="pkg.Service" name="Bye">
+      <failure message="no stub matched the request to /pkg.Service/Bye" type="UnmatchedRequest"><![CDATA[{"name":"<Ann>"}]]></failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="stubs" tests="2" failures="1">
+    <testcase classname="pkg.Service" name="Hello {&#34;name&#34;:&#34;John&#34;}"></testcase>
+    <testcase classname="pkg.Service" name="Hello {&#34;name&#34;:&#34;Mary&#34;}">
+      <failure message="the stub was never matched" type="UnusedStub"><![CDATA[{
+  "fullMethod": "/pkg.Service/Hello",
+  "type": "mock",
+  "request": {
+    "match": "exact",
+    "content": {
+      "name": "Mary"
+    },
+    "metadata": null
+  },
+  "response": null,
+  "forward": null
+}]]></failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, string(data))
+}
+
+func TestReport_NoFailures(t *testing.T) {
+	report := NewReport(nil, NewInMemoryStubsStore())
+	assert.False(t, report.Failed())
+	data, err := report.JUnit()
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `<testsuites name="protoc-gen-mock" tests="0" failures="0">`)
+}
@@ -1,6 +1,7 @@
 package stub
 
 import (
+	"context"
 	"fmt"
 	"github.com/golang/protobuf/jsonpb"
 	githubproto "github.com/golang/protobuf/proto"
@@ -10,7 +11,10 @@ import (
 	protojson22 "google.golang.org/protobuf/encoding/protojson"
 	proto22 "google.golang.org/protobuf/proto"
 	protoreflect22 "google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"reflect"
 	"strings"
+	"sync"
 )
 
 var errorEngine CustomErrorEngine
@@ -19,6 +23,8 @@ func SetErrorEngine(engine CustomErrorEngine) {
 	errorEngine = engine
 }
 
+// GetResponse returns the response of the stub as it is defined, without rendering its templates, e.g. to check that it
+// can be created.
 func GetResponse(stub *Stub, requestJson string, resp interface{}) (interface{}, error) {
 	if stub == nil {
 		return nil, nil
@@ -26,37 +32,120 @@ func GetResponse(stub *Stub, requestJson string, resp interface{}) (interface{},
 	if stub.Response.Type == "error" {
 		return createErrorResponse(errorEngine, stub.Response.Error)
 	}
-	resp, transformErr := jsonToResponse(stub.Response.Content.String(), resp)
+	return successResponse(context.Background(), stub, requestJson, resp, false)
+}
+
+// GetCallResponse returns the response of the stub to the call, with its templates rendered for the call, or the
+// response created by the custom generator of the stub.
+func GetCallResponse(ctx context.Context, stub *Stub, requestJson string, resp interface{}) (interface{}, error) {
+	if stub == nil {
+		return nil, nil
+	}
+	if stub.Response.Type == "error" {
+		stubError := stub.Response.Error
+		if message := renderValue(ctx, stub, "error message", stubError.Message, requestJson); message != stubError.Message {
+			rendered := *stubError
+			rendered.Message = message
+			stubError = &rendered
+		}
+		return createErrorResponse(errorEngine, stubError)
+	}
+	if stub.Response.Type == "custom" {
+		return customResponse(ctx, stub, requestJson, resp)
+	}
+	return successResponse(ctx, stub, requestJson, resp, true)
+}
+
+// successResponse loads the content of the response of the stub in resp. The content is rendered for the call when render
+// is true and it has templates, and parsed once otherwise.
+func successResponse(ctx context.Context, stub *Stub, requestJson string, resp interface{}, render bool) (interface{}, error) {
+	var transformErr error
+	if render && hasTemplates(stub.Response.Content.String()) {
+		resp, transformErr = jsonToResponse(renderContent(ctx, stub, requestJson), resp)
+	} else {
+		resp, transformErr = parsedResponses.unmarshal(stub, resp)
+	}
 	if transformErr != nil {
 		log.WithFields(log.Fields{"Error": transformErr.Error()}).
 			Errorf("Error handling request %s --> %s", stub.FullMethod, requestJson)
 
 		return nil, fmt.Errorf("could not unmarshal response")
 	}
-	log.WithFields(log.Fields{"response": resp}).
-		Infof("Found MOCK response for %s --> %s", stub.FullMethod, requestJson)
+	// the response is only formatted in debug as it can be large
+	if log.IsLevelEnabled(log.DebugLevel) {
+		log.WithFields(log.Fields{"response": resp}).
+			Debugf("Found MOCK response for %s --> %s", stub.FullMethod, requestJson)
+	} else {
+		log.Infof("Found MOCK response for %s", stub.FullMethod)
+	}
 	return resp, nil
 }
 
+// maxParsedResponses is the number of responses kept parsed. When it is reached the responses parsed are discarded.
+const maxParsedResponses = 1000
+
+var parsedResponses = &responsesCache{responses: make(map[*Stub]parsedResponse)}
+
+// parsedResponse is the content of the response of a stub parsed in a message, so that large responses are not parsed
+// from JSON for each call.
+type parsedResponse struct {
+	content JsonString
+	message proto22.Message
+}
+
+type responsesCache struct {
+	responses map[*Stub]parsedResponse
+	mutex     sync.RWMutex
+}
+
+// unmarshal loads the content of the response of the stub in resp. The messages of the protobuf API are copied from the
+// response parsed for a previous call when the content of the stub and the type of the message did not change.
+func (c *responsesCache) unmarshal(stub *Stub, resp interface{}) (interface{}, error) {
+	message, ok := resp.(proto22.Message)
+	if !ok {
+		return jsonToResponse(stub.Response.Content.String(), resp)
+	}
+	c.mutex.RLock()
+	parsed, ok := c.responses[stub]
+	c.mutex.RUnlock()
+	if !ok || parsed.content != stub.Response.Content || parsed.message.ProtoReflect().Descriptor() != message.ProtoReflect().Descriptor() {
+		parsed = parsedResponse{content: stub.Response.Content, message: message.ProtoReflect().New().Interface()}
+		if err := protojson22.Unmarshal([]byte(parsed.content), parsed.message); err != nil {
+			return nil, err
+		}
+		c.mutex.Lock()
+		if len(c.responses) >= maxParsedResponses {
+			c.responses = make(map[*Stub]parsedResponse)
+		}
+		c.responses[stub] = parsed
+		c.mutex.Unlock()
+	}
+	proto22.Reset(message)
+	proto22.Merge(message, parsed.message)
+	return resp, nil
+}
+
+// GetErrorResponse creates the gRPC error described in stubError, including its details.
+func GetErrorResponse(stubError *ErrorResponse) error {
+	_, err := createErrorResponse(errorEngine, stubError)
+	return err
+}
+
 func createErrorResponse(errorEngine CustomErrorEngine, stubError *ErrorResponse) (interface{}, error) {
 	st := status.New(codes.Code(stubError.Code), stubError.Message)
 	if stubError.Details != nil {
-		log.Debugf("Creating instance of base error from spec /%s/%s", stubError.Details.Spec.Import, stubError.Details.Spec.Type)
-		baseErrorType, err := errorEngine.GetNewInstance(stubError.Details.Spec)
-		if err != nil {
-			log.Errorf("Expansion of error response failed: %s", err.Error())
-			return nil, status.New(codes.Internal, "Expansion of error response failed").Err()
-		}
 		detailsMessages := make([]githubproto.Message, 0)
 		for _, errDetailValue := range stubError.Details.Values {
-			errorType := baseErrorType
-			if errDetailValue.SpecOverride != nil && errDetailValue.SpecOverride.Import != "" {
-				log.Debugf("Creating instance of error from spec /%s/%s", errDetailValue.SpecOverride.Import, errDetailValue.SpecOverride.Type)
-				errorType, err = errorEngine.GetNewInstance(errDetailValue.SpecOverride)
-				if err != nil {
-					log.Errorf("Expansion of error response failed: %s", err.Error())
-					return nil, status.New(codes.Internal, "Expansion of error response failed").Err()
-				}
+			// a new instance is created for each value as the messages are only marshalled once all of them are loaded
+			spec := stubError.Details.Spec
+			if errDetailValue.SpecOverride != nil && errDetailValue.SpecOverride.Type != "" {
+				spec = errDetailValue.SpecOverride
+			}
+			log.Debugf("Creating instance of error from spec /%s/%s", spec.Import, spec.Type)
+			errorType, err := newErrorDetailsInstance(errorEngine, spec)
+			if err != nil {
+				log.Errorf("Expansion of error response failed: %s", err.Error())
+				return nil, status.New(codes.Internal, "Expansion of error response failed").Err()
 			}
 			log.Debugf("Loading JSON into error: %s", errDetailValue.Value.String())
 			detailMessage, err := jsonToResponse(errDetailValue.Value.String(), errorType)
@@ -66,6 +155,7 @@ func createErrorResponse(errorEngine CustomErrorEngine, stubError *ErrorResponse
 			}
 			detailsMessages = append(detailsMessages, detailMessage.(githubproto.Message))
 		}
+		var err error
 		st, err = st.WithDetails(detailsMessages...)
 		if err != nil {
 			log.Errorf("Error creating error details: %s", err.Error())
@@ -75,6 +165,25 @@ func createErrorResponse(errorEngine CustomErrorEngine, stubError *ErrorResponse
 	return nil, st.Err()
 }
 
+// newErrorDetailsInstance creates the message of the error details. When the import is not provided the type is the full name
+// of a message linked in the mock server, e.g. google.rpc.RetryInfo, so that messages with the same name in different packages
+// are told apart. Otherwise the Go type is loaded by the error engine.
+func newErrorDetailsInstance(errorEngine CustomErrorEngine, spec *ErrorDetailsSpec) (interface{}, error) {
+	if spec.Import != "" {
+		instance, err := errorEngine.GetNewInstance(spec)
+		if err != nil {
+			return nil, err
+		}
+		// the engine returns the same instance for every call
+		return reflect.New(reflect.TypeOf(instance).Elem()).Interface(), nil
+	}
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect22.FullName(spec.Type))
+	if err != nil {
+		return nil, fmt.Errorf("error details type %s not found: %w", spec.Type, err)
+	}
+	return githubproto.MessageV1(messageType.New().Interface()), nil
+}
+
 func jsonToResponse(jsonString string, returnTypeInstance interface{}) (interface{}, error) {
 	var err error
 	if isCompatibleWithProtobug22(returnTypeInstance) {
@@ -0,0 +1,108 @@
+package stub
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/sourcecontextpb"
+	"google.golang.org/protobuf/types/known/typepb"
+	"strings"
+	"testing"
+)
+
+func TestGetResponse_AnyInContent(t *testing.T) {
+	s := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &StubRequest{Match: "exact", Content: `{}`},
+		Response: &StubResponse{
+			Type:    "success",
+			Content: `{"name":"option","value":{"@type":"type.googleapis.com/google.protobuf.SourceContext","fileName":"greeter.proto"}}`,
+		},
+	}
+	resp, err := GetResponse(s, "{}", new(typepb.Option))
+	assert.Nil(t, err)
+	detail := new(sourcecontextpb.SourceContext)
+	assert.Nil(t, resp.(*typepb.Option).GetValue().UnmarshalTo(detail))
+	assert.Equal(t, "greeter.proto", detail.GetFileName())
+}
+
+func TestGetErrorResponse_DetailsByFullName(t *testing.T) {
+	err := GetErrorResponse(&ErrorResponse{
+		Code:    uint32(codes.Unavailable),
+		Message: "try later",
+		Details: &ErrorDetails{
+			Spec: &ErrorDetailsSpec{Type: "google.rpc.RetryInfo"},
+			Values: []ErrorDetailsValue{
+				{Value: `{"retryDelay":"1s"}`},
+				{Value: `{"retryDelay":"2s"}`},
+				{SpecOverride: &ErrorDetailsSpec{Type: "google.rpc.ErrorInfo"}, Value: `{"reason":"QUOTA"}`},
+			},
+		},
+	})
+	st := status.Convert(err)
+	assert.Equal(t, codes.Unavailable, st.Code())
+	details := st.Details()
+	if assert.Equal(t, 3, len(details)) {
+		assert.Equal(t, int64(1), details[0].(*errdetails.RetryInfo).GetRetryDelay().GetSeconds())
+		assert.Equal(t, int64(2), details[1].(*errdetails.RetryInfo).GetRetryDelay().GetSeconds())
+		assert.Equal(t, "QUOTA", details[2].(*errdetails.ErrorInfo).GetReason())
+	}
+
+	err = GetErrorResponse(&ErrorResponse{
+		Code:    uint32(codes.Unavailable),
+		Details: &ErrorDetails{Spec: &ErrorDetailsSpec{Type: "pkg.Unknown"}, Values: []ErrorDetailsValue{{Value: `{}`}}},
+	})
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestGetResponse_ContentChanged(t *testing.T) {
+	s := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &StubRequest{Match: "exact", Content: `{}`},
+		Response:   &StubResponse{Type: "success", Content: `{"name":"first","options":[{"name":"deprecated"}]}`},
+	}
+	resp, err := GetResponse(s, "{}", new(typepb.Type))
+	assert.Nil(t, err)
+	assert.Equal(t, "first", resp.(*typepb.Type).GetName())
+	// the message returned is not shared with the next calls
+	resp.(*typepb.Type).Options[0].Name = "changed"
+	resp, err = GetResponse(s, "{}", new(typepb.Type))
+	assert.Nil(t, err)
+	assert.Equal(t, "deprecated", resp.(*typepb.Type).GetOptions()[0].GetName())
+
+	s.Response.Content = `{"name":"second"}`
+	resp, err = GetResponse(s, "{}", new(typepb.Type))
+	assert.Nil(t, err)
+	assert.Equal(t, "second", resp.(*typepb.Type).GetName())
+	assert.Empty(t, resp.(*typepb.Type).GetOptions())
+
+	resp, err = GetResponse(s, "{}", new(typepb.Option))
+	assert.Nil(t, err)
+	assert.Equal(t, "second", resp.(*typepb.Option).GetName())
+}
+
+// BenchmarkGetResponse_LargeResponse creates a response of a few MB for each call.
+func BenchmarkGetResponse_LargeResponse(b *testing.B) {
+	fields := make([]string, 0, 20000)
+	for i := 0; i < 20000; i++ {
+		fields = append(fields, fmt.Sprintf(`{"name":"field%d","number":%d,"typeUrl":"%s"}`, i, i+1, strings.Repeat("x", 64)))
+	}
+	content := fmt.Sprintf(`{"name":"large","fields":[%s]}`, strings.Join(fields, ","))
+	s := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &StubRequest{Match: "exact", Content: `{}`},
+		Response:   &StubResponse{Type: "success", Content: JsonString(content)},
+	}
+	b.SetBytes(int64(len(content)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := GetResponse(s, "{}", new(typepb.Type)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
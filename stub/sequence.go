@@ -0,0 +1,164 @@
+package stub
+
+import (
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"sort"
+)
+
+// StubSequence puts a stub in an ordered list of expected calls. The stubs of a sequence must be matched in the order of
+// their steps: a call matching a stub out of order fails with FAILED_PRECONDITION and is reported in the status of the
+// sequence, see SequenceStatus.
+type StubSequence struct {
+	Name string `json:"name"`
+	// Positions of the stub in the sequence, starting at 1. A stub expected several times, e.g. a poll, has several steps.
+	Steps []int `json:"steps"`
+}
+
+// SequenceStatus tells how far the calls went through a sequence.
+type SequenceStatus struct {
+	Name string `json:"name"`
+	// Number of steps of the sequence, the highest step of its stubs
+	Steps int `json:"steps"`
+	// Number of steps matched in order
+	Completed int `json:"completed"`
+	// Calls that matched a stub of the sequence out of order
+	Violations []string `json:"violations"`
+	// All the steps were matched in order and there were no calls out of order
+	Verified bool `json:"verified"`
+}
+
+// SequenceTracker is implemented by the stubs stores that enforce the order of the sequences.
+type SequenceTracker interface {
+	// ConsumeStep advances the sequence of the stub matched, or returns why the call is out of order
+	ConsumeStep(e *Stub) error
+	GetSequences() []SequenceStatus
+}
+
+type sequenceProgress struct {
+	completed  int
+	violations []string
+}
+
+func (s *inMemoryStubsStore) ConsumeStep(e *Stub) error {
+	if e.Sequence == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	progress, ok := s.Sequences[e.Sequence.Name]
+	if !ok {
+		progress = &sequenceProgress{}
+		s.Sequences[e.Sequence.Name] = progress
+	}
+	expected := progress.completed + 1
+	for _, step := range e.Sequence.Steps {
+		if step == expected {
+			progress.completed++
+			return nil
+		}
+	}
+	violation := fmt.Sprintf("call to %s -> %s at step %v of sequence %s is out of order: ", e.FullMethod, e.Request.Content, e.Sequence.Steps, e.Sequence.Name)
+	if next := s.stubForStep(e.Sequence.Name, expected); next != nil {
+		violation += fmt.Sprintf("expected step %d, %s -> %s", expected, next.FullMethod, next.Request.Content)
+	} else {
+		violation += fmt.Sprintf("the sequence already completed its %d steps", progress.completed)
+	}
+	progress.violations = append(progress.violations, violation)
+	return errors.New(violation)
+}
+
+// stubForStep returns the stub at the step of the sequence, or nil if there is none.
+func (s *inMemoryStubsStore) stubForStep(name string, step int) *Stub {
+	for _, e := range s.sequenceStubs(name) {
+		for _, stubStep := range e.Sequence.Steps {
+			if stubStep == step {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+func (s *inMemoryStubsStore) sequenceStubs(name string) []*Stub {
+	stubs := make([]*Stub, 0)
+	for _, requests := range s.Stubs {
+		for _, repeated := range requests {
+			if repeated[0].Sequence != nil && repeated[0].Sequence.Name == name {
+				stubs = append(stubs, repeated[0])
+			}
+		}
+	}
+	return stubs
+}
+
+// GetSequences returns the status of the sequences of the stubs in the store, sorted by name.
+func (s *inMemoryStubsStore) GetSequences() []SequenceStatus {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	steps := make(map[string]int)
+	for _, requests := range s.Stubs {
+		for _, repeated := range requests {
+			if sequence := repeated[0].Sequence; sequence != nil {
+				for _, step := range sequence.Steps {
+					if step > steps[sequence.Name] {
+						steps[sequence.Name] = step
+					}
+				}
+			}
+		}
+	}
+	statuses := make([]SequenceStatus, 0, len(steps))
+	for name, count := range steps {
+		status := SequenceStatus{Name: name, Steps: count, Violations: make([]string, 0)}
+		if progress, ok := s.Sequences[name]; ok {
+			status.Completed = progress.completed
+			status.Violations = append(status.Violations, progress.violations...)
+		}
+		status.Verified = status.Completed == status.Steps && len(status.Violations) == 0
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// outOfOrderStub returns the stub failing a call that matched the stub out of the order of its sequence.
+func outOfOrderStub(e *Stub, err error) *Stub {
+	return &Stub{
+		FullMethod: e.FullMethod,
+		Type:       "mock",
+		Request:    e.Request,
+		Response: &StubResponse{
+			Type:  "error",
+			Error: &ErrorResponse{Code: uint32(codes.FailedPrecondition), Message: err.Error()},
+		},
+	}
+}
+
+func (stub *Stub) isValidSequence() (isValid bool, errMsgs []string) {
+	if stub.Sequence == nil {
+		return true, nil
+	}
+	if stub.Sequence.Name == "" {
+		errMsgs = append(errMsgs, "Sequence name can't be empty.")
+	}
+	if len(stub.Sequence.Steps) == 0 {
+		errMsgs = append(errMsgs, "Sequence steps can't be empty.")
+	}
+	steps := make(map[int]bool)
+	for _, step := range stub.Sequence.Steps {
+		if step < 1 {
+			errMsgs = append(errMsgs, fmt.Sprintf("Sequence step %d is not valid. Steps start at 1.", step))
+		}
+		if steps[step] {
+			errMsgs = append(errMsgs, fmt.Sprintf("Sequence step %d is repeated.", step))
+		}
+		steps[step] = true
+	}
+	return len(errMsgs) == 0, errMsgs
+}
@@ -0,0 +1,61 @@
+package stub
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func sequenceStub(method, content string, steps ...int) *Stub {
+	return &Stub{
+		FullMethod: method,
+		Type:       "mock",
+		Request:    &StubRequest{Match: "exact", Content: JsonString(content)},
+		Response:   &StubResponse{Type: "success", Content: `{}`},
+		Sequence:   &StubSequence{Name: "checkout", Steps: steps},
+	}
+}
+
+func TestStubsMatcher_Match_Sequence(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	cart := sequenceStub("/shop.Shop/GetCart", `{"id":"1"}`, 1, 3)
+	pay := sequenceStub("/shop.Shop/Pay", `{"id":"1"}`, 2)
+	store.Add(cart)
+	store.Add(pay)
+	matcher := NewStubsMatcher(store)
+	ctx := context.Background()
+
+	assert.Equal(t, cart, matcher.Match(ctx, "/shop.Shop/GetCart", `{"id":"1"}`))
+	outOfOrder := matcher.Match(ctx, "/shop.Shop/GetCart", `{"id":"1"}`)
+	assert.Equal(t, "error", outOfOrder.Response.Type)
+	assert.Equal(t, uint32(9), outOfOrder.Response.Error.Code)
+	assert.Equal(t, `call to /shop.Shop/GetCart -> {"id":"1"} at step [1 3] of sequence checkout is out of order: expected step 2, /shop.Shop/Pay -> {"id":"1"}`,
+		outOfOrder.Response.Error.Message)
+	assert.Equal(t, 1, store.GetMatchCount(cart))
+	assert.Equal(t, []SequenceStatus{{Name: "checkout", Steps: 3, Completed: 1, Violations: []string{outOfOrder.Response.Error.Message}}},
+		store.(SequenceTracker).GetSequences())
+
+	store.ResetMatchCounts()
+	assert.Equal(t, cart, matcher.Match(ctx, "/shop.Shop/GetCart", `{"id":"1"}`))
+	assert.Equal(t, pay, matcher.Match(ctx, "/shop.Shop/Pay", `{"id":"1"}`))
+	assert.Equal(t, cart, matcher.Match(ctx, "/shop.Shop/GetCart", `{"id":"1"}`))
+	assert.Equal(t, []SequenceStatus{{Name: "checkout", Steps: 3, Completed: 3, Violations: []string{}, Verified: true}},
+		store.(SequenceTracker).GetSequences())
+
+	outOfOrder = matcher.Match(ctx, "/shop.Shop/Pay", `{"id":"1"}`)
+	assert.Equal(t, `call to /shop.Shop/Pay -> {"id":"1"} at step [2] of sequence checkout is out of order: the sequence already completed its 3 steps`,
+		outOfOrder.Response.Error.Message)
+	assert.False(t, store.(SequenceTracker).GetSequences()[0].Verified)
+}
+
+func TestStub_IsValid_Sequence(t *testing.T) {
+	s := sequenceStub("/shop.Shop/Pay", `{"id":"1"}`, 0, 2, 2)
+	s.Sequence.Name = ""
+	valid, errs := s.IsValid()
+	assert.False(t, valid)
+	assert.Equal(t, []string{"Sequence name can't be empty.", "Sequence step 0 is not valid. Steps start at 1.", "Sequence step 2 is repeated."}, errs)
+
+	s.Sequence = &StubSequence{Name: "checkout"}
+	_, errs = s.IsValid()
+	assert.Equal(t, []string{"Sequence steps can't be empty."}, errs)
+}
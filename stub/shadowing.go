@@ -0,0 +1,116 @@
+package stub
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FindShadowing returns why the stub, added or updated in the store, would never be matched or would make a stub of the
+// store never be matched: a stub matching all the calls of another one and tried before it shadows it. The stubs are
+// compared as the JSON matching does, using the candidates already parsed by the store. It is empty when there is no
+// conflict.
+func FindShadowing(store StubsStore, s *Stub) []string {
+	candidate, err := newMatchCandidate(s)
+	if err != nil {
+		return nil
+	}
+	if index, ok := store.(candidatesIndex); ok {
+		var warnings []string
+		index.visitCandidates(s.FullMethod, func(candidates *methodCandidates) {
+			warnings = findShadowing(candidates, candidate)
+		})
+		return warnings
+	}
+	return findShadowing(parseCandidates(store, s.FullMethod), candidate)
+}
+
+func findShadowing(candidates *methodCandidates, candidate matchCandidate) []string {
+	warnings := make([]string, 0)
+	if candidates == nil {
+		return warnings
+	}
+	for _, other := range shadowingCandidates(candidates, candidate) {
+		existing := other.stub
+		if other.request == candidate.request {
+			continue
+		}
+		switch {
+		case covers(other, candidate) && precedes(other, candidate):
+			warnings = append(warnings, fmt.Sprintf("the stub is never matched: the stub %s -> %s matches all its calls first", existing.FullMethod, existing.Request.Content))
+		case covers(candidate, other) && precedes(candidate, other):
+			warnings = append(warnings, fmt.Sprintf("the stub %s -> %s is never matched anymore: the stub matches all its calls first", existing.FullMethod, existing.Request.Content))
+		}
+	}
+	return warnings
+}
+
+// shadowingCandidates returns the stubs of the method that can shadow the candidate or be shadowed by it. An exact stub
+// only covers the exact stubs with the same content, so they are looked up by its canonical form and only the other stubs
+// are compared. The items of arrays can be in any order, so a content with arrays is compared with all the stubs.
+func shadowingCandidates(candidates *methodCandidates, candidate matchCandidate) []matchCandidate {
+	if candidate.canonical == "" || hasArrays(candidate.content) {
+		return candidates.sorted
+	}
+	others := make([]matchCandidate, 0, len(candidates.exact[candidate.canonical]))
+	others = append(others, candidates.exact[candidate.canonical]...)
+	for _, other := range candidates.sorted {
+		if other.canonical == "" {
+			others = append(others, other)
+		}
+	}
+	return others
+}
+
+// hasArrays tells whether the JSON value has an array at any depth.
+func hasArrays(value interface{}) bool {
+	switch value := value.(type) {
+	case []interface{}:
+		return true
+	case map[string]interface{}:
+		for _, field := range value {
+			if hasArrays(field) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// covers tells whether all the calls matched by the candidate other are matched by candidate.
+func covers(candidate, other matchCandidate) bool {
+	// the fields compared by the stubs with a field mask can depend on the calls, and the custom matchers are opaque
+	if candidate.stub.Request.FieldMask != nil || other.stub.Request.FieldMask != nil ||
+		candidate.stub.Request.Match == "custom" || other.stub.Request.Match == "custom" {
+		return false
+	}
+	for key, values := range candidate.metadata {
+		otherValues, ok := other.metadata[key]
+		if !ok || strings.Join(values, ",") != strings.Join(otherValues, ",") {
+			return false
+		}
+	}
+	switch candidate.stub.Request.Match {
+	case "exact":
+		return other.stub.Request.Match == "exact" && jsonValuesMatch(candidate.content, other.content, true)
+	case "partial":
+		return jsonValuesMatch(candidate.content, other.content, false) &&
+			(other.stub.Request.Match == "exact" || emptyArraysCovered(candidate.content, other.content))
+	}
+	return false
+}
+
+// precedes tells whether candidate is matched instead of other by the calls matched by both: mock stubs before forwarding
+// ones, the last forwarding stub in the order of the requests, and the exact mock stubs with the content of the call
+// before the first other mock stub in the order of the requests.
+func precedes(candidate, other matchCandidate) bool {
+	if candidate.stub.IsForwarding() != other.stub.IsForwarding() {
+		return !candidate.stub.IsForwarding()
+	}
+	if candidate.stub.IsForwarding() {
+		return candidate.request > other.request
+	}
+	if isExact, isOtherExact := candidate.canonical != "", other.canonical != ""; isExact != isOtherExact {
+		return isExact
+	}
+	return candidate.request < other.request
+}
@@ -0,0 +1,116 @@
+package stub
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newTestStub(stubType StubType, match string, content JsonString, md map[string][]string) *Stub {
+	return &Stub{FullMethod: "/pkg.Service/Method", Type: stubType, Request: &StubRequest{Match: match, Content: content, Metadata: md}}
+}
+
+func TestFindShadowing(t *testing.T) {
+	exactJohn := newTestStub("mock", "exact", `{"name":"John","age":2}`, nil)
+	partialJohn := newTestStub("mock", "partial", `{"name":"John"}`, nil)
+	partialAll := newTestStub("mock", "partial", `{}`, nil)
+	tenant := map[string][]string{"tenant": {"a"}}
+
+	for name, test := range map[string]struct {
+		existing []*Stub
+		stub     *Stub
+		warnings []string
+	}{
+		"exact stub overriding a partial one": {
+			existing: []*Stub{partialJohn, partialAll},
+			stub:     exactJohn,
+			warnings: []string{},
+		},
+		"partial stub after a broader one": {
+			existing: []*Stub{newTestStub("mock", "partial", `{"age":2}`, nil)},
+			stub:     newTestStub("mock", "partial", `{"name":"John","age":2}`, nil),
+			warnings: []string{`the stub is never matched: the stub /pkg.Service/Method -> {"age":2} matches all its calls first`},
+		},
+		"broader partial stub before a narrower one": {
+			existing: []*Stub{newTestStub("mock", "partial", `{"name":"John","age":2}`, nil)},
+			stub:     newTestStub("mock", "partial", `{"age":2}`, nil),
+			warnings: []string{`the stub /pkg.Service/Method -> {"name":"John","age":2} is never matched anymore: the stub matches all its calls first`},
+		},
+		"identical exact stubs": {
+			existing: []*Stub{exactJohn},
+			stub:     newTestStub("mock", "exact", `{"age":2,"name":"John"}`, nil),
+			warnings: []string{`the stub /pkg.Service/Method -> {"name":"John","age":2} is never matched anymore: the stub matches all its calls first`},
+		},
+		"stub without the metadata of another one": {
+			existing: []*Stub{newTestStub("mock", "exact", `{"name":"John","age":2}`, tenant)},
+			stub:     exactJohn,
+			warnings: []string{`the stub /pkg.Service/Method -> {"name":"John","age":2} is never matched anymore: the stub matches all its calls first`},
+		},
+		"stub with metadata": {
+			existing: []*Stub{partialJohn},
+			stub:     newTestStub("mock", "partial", `{"name":"John","age":2}`, tenant),
+			warnings: []string{},
+		},
+		"forward stub after a mock one": {
+			existing: []*Stub{partialAll},
+			stub:     newTestStub("forward", "partial", `{"name":"John"}`, nil),
+			warnings: []string{`the stub is never matched: the stub /pkg.Service/Method -> {} matches all its calls first`},
+		},
+		"mock stub before a broader forward one": {
+			existing: []*Stub{newTestStub("forward", "partial", `{}`, nil)},
+			stub:     partialJohn,
+			warnings: []string{},
+		},
+		"forward stubs matched last first": {
+			existing: []*Stub{newTestStub("forward", "partial", `{"name":"John"}`, nil)},
+			stub:     newTestStub("forward", "partial", `{}`, nil),
+			warnings: []string{`the stub /pkg.Service/Method -> {"name":"John"} is never matched anymore: the stub matches all its calls first`},
+		},
+		"stub with an empty array matching any value": {
+			existing: []*Stub{partialJohn},
+			stub:     newTestStub("mock", "partial", `{"name":"John","ids":[]}`, nil),
+			warnings: []string{`the stub /pkg.Service/Method -> {"name":"John"} is never matched anymore: the stub matches all its calls first`},
+		},
+		"stub matching the calls without items before a broader one": {
+			existing: []*Stub{partialJohn},
+			stub: &Stub{FullMethod: "/pkg.Service/Method", Type: "mock",
+				Request: &StubRequest{Match: "partial", Content: `{"name":"John","ids":[]}`, EmptyArrays: "empty"}},
+			warnings: []string{},
+		},
+		"exact stubs with the items of an array in another order": {
+			existing: []*Stub{newTestStub("mock", "exact", `{"ids":[1,2]}`, nil)},
+			stub:     newTestStub("mock", "exact", `{"ids":[2,1]}`, nil),
+			warnings: []string{`the stub is never matched: the stub /pkg.Service/Method -> {"ids":[1,2]} matches all its calls first`},
+		},
+		"same request": {
+			existing: []*Stub{partialJohn},
+			stub:     newTestStub("mock", "partial", `{"name":"John"}`, nil),
+			warnings: []string{},
+		},
+	} {
+		store := NewInMemoryStubsStore()
+		for _, s := range test.existing {
+			assert.Nil(t, store.Add(s), name)
+		}
+		assert.Equal(t, test.warnings, FindShadowing(store, test.stub), name)
+	}
+}
+
+// BenchmarkFindShadowing looks for the stubs shadowing an exact stub among 10000 exact stubs and 100 partial stubs of its
+// method.
+func BenchmarkFindShadowing(b *testing.B) {
+	store := NewInMemoryStubsStore()
+	for id := 0; id < 10000; id++ {
+		store.Add(newTestStub("mock", "exact", JsonString(fmt.Sprintf(`{"id":%d,"filter":{"active":true}}`, id)), nil))
+	}
+	for id := 0; id < 100; id++ {
+		store.Add(newTestStub("mock", "partial", JsonString(fmt.Sprintf(`{"id":%d,"page":2}`, id)), nil))
+	}
+	s := newTestStub("mock", "exact", `{"id":10000,"filter":{"active":true}}`, nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if warnings := FindShadowing(store, s); len(warnings) > 0 {
+			b.Fatal(warnings)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package stub
+
+import (
+	"encoding/gob"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// SpillStats tells how much of the responses of the recordings is kept in memory.
+type SpillStats struct {
+	// Size in bytes of the responses in memory
+	ResidentBytes int64 `json:"residentBytes"`
+	// Recordings whose response is in a file
+	Spilled int `json:"spilled"`
+	// Number of responses written to a file since the store was created
+	Evictions uint64 `json:"evictions"`
+}
+
+// SpillingRecordingsStore is a recordings store keeping the responses of the recordings in memory up to a budget. Above
+// it the responses of the oldest recordings are written to files and read back when the recordings are listed, so that
+// recording long sessions doesn't grow the memory unboundedly. The requests of the recordings are always kept in memory.
+// Only the recordings are written to files: the stubs, including the ones replaying the recordings, are kept in memory.
+// GetRecordingsPage only reads back the responses of the recordings of the page.
+type SpillingRecordingsStore struct {
+	recordings *inMemoryStubsStore
+	// recordings in the order they were added
+	added  []*Stub
+	dir    string
+	budget int64
+	// recordings with their response in memory, oldest first
+	resident []*Stub
+	// files of the responses written to disk, by recording
+	spilled map[*Stub]string
+	stats   SpillStats
+	mutex   sync.Mutex
+}
+
+// spilledResponse is the part of the response of a recording written to a file.
+type spilledResponse struct {
+	Content string
+	Stream  []string
+}
+
+// NewSpillingRecordingsStore creates a recordings store keeping up to budget bytes of responses in memory. The responses
+// above the budget are written to a new directory in dir, removed by Close.
+func NewSpillingRecordingsStore(dir string, budget int64) (*SpillingRecordingsStore, error) {
+	if budget <= 0 {
+		return nil, fmt.Errorf("invalid recordings memory budget %d", budget)
+	}
+	spillDir, err := os.MkdirTemp(dir, "recordings")
+	if err != nil {
+		return nil, fmt.Errorf("could not create the directory of the recordings: %w", err)
+	}
+	return &SpillingRecordingsStore{
+		recordings: newInMemoryStubsStore(true),
+		dir:        spillDir,
+		budget:     budget,
+		spilled:    make(map[*Stub]string),
+	}, nil
+}
+
+// Add stores a copy of the recording, so that its response can be released without changing the stub provided.
+func (s *SpillingRecordingsStore) Add(e *Stub) error {
+	recording := *e
+	if e.Response != nil {
+		response := *e.Response
+		recording.Response = &response
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.recordings.Add(&recording); err != nil {
+		return err
+	}
+	s.added = append(s.added, &recording)
+	if recording.Response == nil {
+		return nil
+	}
+	s.resident = append(s.resident, &recording)
+	s.stats.ResidentBytes += responseSize(recording.Response)
+	for s.stats.ResidentBytes > s.budget && len(s.resident) > 0 {
+		if err := s.spill(s.resident[0]); err != nil {
+			log.Errorf("Failed to write the response of the recording of %s: %v", s.resident[0].FullMethod, err)
+			break
+		}
+		s.resident = s.resident[1:]
+	}
+	return nil
+}
+
+// spill writes the response of the recording to a file and releases it.
+func (s *SpillingRecordingsStore) spill(recording *Stub) error {
+	path := filepath.Join(s.dir, fmt.Sprintf("%d.gob", s.stats.Evictions))
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	response := spilledResponse{Content: recording.Response.Content.String(), Stream: make([]string, 0, len(recording.Response.Stream))}
+	for _, message := range recording.Response.Stream {
+		response.Stream = append(response.Stream, message.String())
+	}
+	if err := gob.NewEncoder(file).Encode(response); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	s.stats.ResidentBytes -= responseSize(recording.Response)
+	released := *recording.Response
+	released.Content, released.Stream = "", nil
+	recording.Response = &released
+	s.spilled[recording] = path
+	s.stats.Spilled++
+	s.stats.Evictions++
+	return nil
+}
+
+// GetAllStubs returns the recordings with their responses, read back from the files for the ones written to disk.
+func (s *SpillingRecordingsStore) GetAllStubs() []*Stub {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	recordings := s.recordings.GetAllStubs()
+	for i, recording := range recordings {
+		recordings[i] = s.load(recording)
+	}
+	return recordings
+}
+
+// GetRecordingsPage returns up to limit recordings after the first offset ones, all of them when limit is zero, in the
+// order they were added. Only the responses of the recordings returned are read back from the files.
+func (s *SpillingRecordingsStore) GetRecordingsPage(offset, limit int) RecordingsPage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	start, end := pageBounds(len(s.added), offset, limit)
+	recordings := make([]*Stub, 0, end-start)
+	for _, recording := range s.added[start:end] {
+		recordings = append(recordings, s.load(recording))
+	}
+	return RecordingsPage{Recordings: recordings, Total: len(s.added)}
+}
+
+// load returns the recording with its response read back from its file when it was written to disk.
+func (s *SpillingRecordingsStore) load(recording *Stub) *Stub {
+	path, ok := s.spilled[recording]
+	if !ok {
+		return recording
+	}
+	loaded, err := loadSpilledResponse(path, recording.Response)
+	if err != nil {
+		log.Errorf("Failed to read the response of the recording of %s: %v", recording.FullMethod, err)
+		return recording
+	}
+	copied := *recording
+	copied.Response = loaded
+	return &copied
+}
+
+func loadSpilledResponse(path string, released *StubResponse) (*StubResponse, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var spilled spilledResponse
+	if err := gob.NewDecoder(file).Decode(&spilled); err != nil {
+		return nil, err
+	}
+	response := *released
+	response.Content = JsonString(spilled.Content)
+	for _, message := range spilled.Stream {
+		response.Stream = append(response.Stream, JsonString(message))
+	}
+	return &response, nil
+}
+
+// Stats returns how much of the responses of the recordings is kept in memory.
+func (s *SpillingRecordingsStore) Stats() SpillStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats
+}
+
+// Close removes the files of the responses.
+func (s *SpillingRecordingsStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return os.RemoveAll(s.dir)
+}
+
+func responseSize(response *StubResponse) int64 {
+	size := int64(len(response.Content))
+	for _, message := range response.Stream {
+		size += int64(len(message))
+	}
+	return size
+}
@@ -0,0 +1,81 @@
+package stub
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func newTestRecording(id int, response JsonString) *Stub {
+	return &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &StubRequest{Match: "exact", Content: JsonString(fmt.Sprintf(`{"id":%d}`, id))},
+		Response:   &StubResponse{Type: "success", Content: response, Stream: []JsonString{`{"part":1}`}},
+	}
+}
+
+func TestSpillingRecordingsStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewSpillingRecordingsStore(dir, 50)
+	assert.Nil(t, err)
+	recordings := []*Stub{
+		newTestRecording(1, `{"name":"first"}`),
+		newTestRecording(2, `{"name":"second"}`),
+		newTestRecording(3, `{"name":"third"}`),
+	}
+	for _, recording := range recordings {
+		assert.Nil(t, store.Add(recording))
+	}
+	// the responses are written to disk from the oldest recording, without changing the stubs added
+	assert.Equal(t, SpillStats{ResidentBytes: 26, Spilled: 2, Evictions: 2}, store.Stats())
+	assert.Equal(t, JsonString(`{"name":"first"}`), recordings[0].Response.Content)
+	assert.Equal(t, "", store.recordings.GetAllStubs()[0].Response.Content.String())
+
+	assert.Equal(t, recordings, store.GetAllStubs())
+	assert.Nil(t, store.Add(newTestRecording(1, "")))
+	assert.Equal(t, 4, len(store.GetAllStubs()))
+	assert.Equal(t, JsonString(""), store.GetAllStubs()[1].Response.Content)
+
+	assert.Nil(t, store.Close())
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestSpillingRecordingsStore_LargeResponse(t *testing.T) {
+	store, err := NewSpillingRecordingsStore(t.TempDir(), 10)
+	assert.Nil(t, err)
+	defer store.Close()
+	recording := newTestRecording(1, `{"name":"larger than the budget"}`)
+
+	assert.Nil(t, store.Add(recording))
+	assert.Equal(t, SpillStats{Spilled: 1, Evictions: 1}, store.Stats())
+	assert.Equal(t, []*Stub{recording}, store.GetAllStubs())
+}
+
+func TestNewSpillingRecordingsStore_InvalidBudget(t *testing.T) {
+	_, err := NewSpillingRecordingsStore(t.TempDir(), 0)
+	assert.EqualError(t, err, "invalid recordings memory budget 0")
+}
+
+func TestSpillingRecordingsStore_GetRecordingsPage(t *testing.T) {
+	store, err := NewSpillingRecordingsStore(t.TempDir(), 30)
+	assert.Nil(t, err)
+	defer store.Close()
+	recordings := []*Stub{
+		newTestRecording(3, `{"name":"first"}`),
+		newTestRecording(1, `{"name":"second"}`),
+		newTestRecording(2, `{"name":"third"}`),
+	}
+	for _, recording := range recordings {
+		assert.Nil(t, store.Add(recording))
+	}
+	assert.Equal(t, 2, store.Stats().Spilled)
+
+	// in the order they were added, with the responses of the page read back from the files
+	assert.Equal(t, RecordingsPage{Recordings: recordings[1:], Total: 3}, store.GetRecordingsPage(1, 0))
+	assert.Equal(t, RecordingsPage{Recordings: recordings[:1], Total: 3}, store.GetRecordingsPage(0, 1))
+	assert.Equal(t, RecordingsPage{Recordings: []*Stub{}, Total: 3}, store.GetRecordingsPage(5, 1))
+}
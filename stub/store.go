@@ -2,21 +2,31 @@ package stub
 
 import (
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 )
 
 func NewInMemoryStubsStore() StubsStore {
-	return &inMemoryStubsStore{
-		Stubs:         make(map[string]map[string][]*Stub, 0),
-		AllowRepeated: false,
-	}
+	return newInMemoryStubsStore(false)
 }
 
 func NewRecordingsStore() RecordingsStore {
-	return &inMemoryStubsStore{
+	return &inMemoryRecordingsStore{recordings: newInMemoryStubsStore(true)}
+}
+
+func newInMemoryStubsStore(allowRepeated bool) *inMemoryStubsStore {
+	store := &inMemoryStubsStore{
 		Stubs:         make(map[string]map[string][]*Stub, 0),
-		AllowRepeated: true,
+		MatchCounts:   make(map[string]map[string]int, 0),
+		Sequences:     make(map[string]*sequenceProgress),
+		AllowRepeated: allowRepeated,
+		index:         make(map[string]*methodCandidates),
+		stale:         make(map[string]bool),
+		templates:     newTemplateState(),
 	}
+	store.candidates.Store(&candidatesSnapshot{methods: make(map[string]*methodCandidates)})
+	return store
 }
 
 type StubsStore interface {
@@ -29,6 +39,10 @@ type StubsStore interface {
 	DeleteAll()
 	Delete(e *Stub) error
 	Exists(e *Stub) bool
+	RecordMatch(e *Stub)
+	GetMatchCount(e *Stub) int
+	GetUnmatchedStubs() []*Stub
+	ResetMatchCounts()
 }
 
 type RecordingsStore interface {
@@ -37,6 +51,9 @@ type RecordingsStore interface {
 }
 
 type inMemoryStubsStore struct {
+	// Number of changes of the candidates, read without locking the store to tell whether the snapshot is outdated. First
+	// field so that it is aligned for the atomic operations.
+	changes uint64
 	// Stores the stubs registered.
 	// First map's key is a full method name
 	// Second map's key is a gRPC request payload in JSON format
@@ -47,9 +64,29 @@ type inMemoryStubsStore struct {
 	// /full method name 2 ->
 	//               request 1 -> stub3
 	//               request 2 -> stub4
-	Stubs         map[string]map[string][]*Stub
+	Stubs map[string]map[string][]*Stub
+	// Number of times each stub was matched by an incoming request. Uses the same keys as Stubs.
+	MatchCounts map[string]map[string]int
+	// Progress of the sequences of the stubs, by name. See StubSequence.
+	Sequences     map[string]*sequenceProgress
 	AllowRepeated bool
-	mutex         sync.RWMutex
+	// First stub of each request of each method, sorted by request and parsed for matching. They are changed in place, with
+	// the lock held, when the stubs change.
+	index map[string]*methodCandidates
+	// Snapshot of index as a *candidatesSnapshot, so that the calls are matched without locking the store while stubs are
+	// added. It is replaced, never modified: the candidates of the methods in stale are copied from index by the first
+	// call matched after they changed, so that adding many stubs copies them once.
+	candidates atomic.Value
+	stale      map[string]bool
+	// State of the templates of the responses of the stubs, see WithTemplateState
+	templates *templateState
+	mutex     sync.RWMutex
+}
+
+// candidatesSnapshot holds the candidates of each method after the first changes of the store.
+type candidatesSnapshot struct {
+	methods map[string]*methodCandidates
+	changes uint64
 }
 
 func (s *inMemoryStubsStore) Add(e *Stub) error {
@@ -65,14 +102,24 @@ func (s *inMemoryStubsStore) Add(e *Stub) error {
 		return fmt.Errorf("stub already exist: %s -> %s", e.FullMethod, e.Request.String())
 	}
 
-	s.Stubs[e.FullMethod][e.Request.String()] = append(s.Stubs[e.FullMethod][e.Request.String()], e)
+	repeated := len(s.Stubs[e.FullMethod][e.Request.String()]) > 0
+	if repeated {
+		s.Stubs[e.FullMethod][e.Request.String()] = append(s.Stubs[e.FullMethod][e.Request.String()], e)
+		return nil
+	}
+	candidate, err := newMatchCandidate(e)
+	if err != nil {
+		return err
+	}
+	s.Stubs[e.FullMethod][e.Request.String()] = []*Stub{e}
+	s.setCandidate(candidate)
 
 	return nil
 }
 
 func (s *inMemoryStubsStore) GetStubsMapForMethod(method string) (stubs map[string]*Stub) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 
 	return s.getStubsMapForMethod(method)
 }
@@ -86,28 +133,32 @@ func (s *inMemoryStubsStore) getStubsMapForMethod(method string) (stubs map[stri
 }
 
 func (s *inMemoryStubsStore) GetStubsForMethod(method string) []*Stub {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 
 	return s.getStubsForMethod(method)
 }
 
+// getStubsForMethod returns the stubs of the method sorted by request so that the order doesn't change between calls.
 func (s *inMemoryStubsStore) getStubsForMethod(method string) []*Stub {
 	resp := make([]*Stub, 0)
-	for _, stubs := range s.Stubs[method] {
-		for _, e := range stubs {
-			resp = append(resp, e)
-		}
+	for _, req := range sortedKeys(s.Stubs[method]) {
+		resp = append(resp, s.Stubs[method][req]...)
 	}
 	return resp
 }
 
 func (s *inMemoryStubsStore) GetAllStubs() []*Stub {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 
+	methods := make([]string, 0, len(s.Stubs))
+	for method := range s.Stubs {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
 	allStubs := make([]*Stub, 0)
-	for methodName := range s.Stubs {
+	for _, methodName := range methods {
 		allStubs = append(allStubs, s.getStubsForMethod(methodName)...)
 	}
 
@@ -122,7 +173,12 @@ func (s *inMemoryStubsStore) Update(e *Stub) error {
 		return fmt.Errorf("stub does not exist: %s -> %s", e.FullMethod, e.Request.String())
 	}
 
+	candidate, err := newMatchCandidate(e)
+	if err != nil {
+		return err
+	}
 	s.Stubs[e.FullMethod][e.Request.String()][0] = e
+	s.setCandidate(candidate)
 
 	return nil
 }
@@ -136,13 +192,18 @@ func (s *inMemoryStubsStore) Delete(e *Stub) error {
 	}
 
 	delete(s.Stubs[e.FullMethod], e.Request.String())
+	delete(s.MatchCounts[e.FullMethod], e.Request.String())
+	if candidates := s.index[e.FullMethod]; candidates != nil {
+		candidates.remove(e.Request.String())
+		s.markStale(e.FullMethod)
+	}
 
 	return nil
 }
 
 func (s *inMemoryStubsStore) Exists(e *Stub) bool {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 
 	return s.exists(e)
 }
@@ -162,6 +223,73 @@ func (s *inMemoryStubsStore) DeleteAllForMethod(method string) {
 
 func (s *inMemoryStubsStore) deleteAllForMethod(method string) {
 	s.Stubs[method] = make(map[string][]*Stub)
+	delete(s.MatchCounts, method)
+	delete(s.index, method)
+	s.markStale(method)
+}
+
+// setCandidate adds or replaces the candidate of the stub in the candidates of its method, with the lock held.
+func (s *inMemoryStubsStore) setCandidate(candidate matchCandidate) {
+	method := candidate.stub.FullMethod
+	if s.index[method] == nil {
+		s.index[method] = newMethodCandidates(nil)
+	}
+	s.index[method].set(candidate)
+	s.markStale(method)
+}
+
+// markStale tells that the candidates of the method changed since the snapshot, with the lock held.
+func (s *inMemoryStubsStore) markStale(method string) {
+	s.stale[method] = true
+	atomic.AddUint64(&s.changes, 1)
+}
+
+func (s *inMemoryStubsStore) templateState() *templateState {
+	return s.templates
+}
+
+// matchCandidates returns the candidates of the method from the last snapshot, without locking the store unless the
+// stubs changed since.
+func (s *inMemoryStubsStore) matchCandidates(method string) *methodCandidates {
+	snapshot := s.candidates.Load().(*candidatesSnapshot)
+	if snapshot.changes != atomic.LoadUint64(&s.changes) {
+		snapshot = s.updateSnapshot()
+	}
+	return snapshot.methods[method]
+}
+
+// updateSnapshot replaces the snapshot with a copy where the candidates of the methods changed since are copied from the
+// index.
+func (s *inMemoryStubsStore) updateSnapshot() *candidatesSnapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	current := s.candidates.Load().(*candidatesSnapshot)
+	if len(s.stale) == 0 {
+		return current
+	}
+	methods := make(map[string]*methodCandidates, len(current.methods)+len(s.stale))
+	for method, candidates := range current.methods {
+		methods[method] = candidates
+	}
+	for method := range s.stale {
+		if candidates := s.index[method]; candidates != nil && len(candidates.sorted) > 0 {
+			methods[method] = candidates.clone()
+		} else {
+			delete(methods, method)
+		}
+	}
+	s.stale = make(map[string]bool)
+	snapshot := &candidatesSnapshot{methods: methods, changes: atomic.LoadUint64(&s.changes)}
+	s.candidates.Store(snapshot)
+	return snapshot
+}
+
+func (s *inMemoryStubsStore) visitCandidates(method string, visit func(*methodCandidates)) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	visit(s.index[method])
 }
 
 func (s *inMemoryStubsStore) DeleteAll() {
@@ -169,6 +297,74 @@ func (s *inMemoryStubsStore) DeleteAll() {
 	defer s.mutex.Unlock()
 
 	for method := range s.Stubs {
-		s.deleteAllForMethod(method)
+		s.Stubs[method] = make(map[string][]*Stub)
+	}
+	s.MatchCounts = make(map[string]map[string]int, 0)
+	s.Sequences = make(map[string]*sequenceProgress)
+	for method := range s.index {
+		s.markStale(method)
+	}
+	s.index = make(map[string]*methodCandidates)
+}
+
+func (s *inMemoryStubsStore) RecordMatch(e *Stub) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.exists(e) {
+		return
+	}
+	_, ok := s.MatchCounts[e.FullMethod]
+	if !ok {
+		s.MatchCounts[e.FullMethod] = make(map[string]int, 0)
+	}
+	s.MatchCounts[e.FullMethod][e.Request.String()]++
+}
+
+func (s *inMemoryStubsStore) GetMatchCount(e *Stub) int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.MatchCounts[e.FullMethod][e.Request.String()]
+}
+
+func (s *inMemoryStubsStore) GetUnmatchedStubs() []*Stub {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	unmatched := make([]*Stub, 0)
+	for method, stubsPerRequest := range s.Stubs {
+		for req, stubs := range stubsPerRequest {
+			if s.MatchCounts[method][req] == 0 {
+				unmatched = append(unmatched, stubs...)
+			}
+		}
+	}
+	sort.SliceStable(unmatched, func(i, j int) bool {
+		if unmatched[i].FullMethod != unmatched[j].FullMethod {
+			return unmatched[i].FullMethod < unmatched[j].FullMethod
+		}
+		return unmatched[i].Request.String() < unmatched[j].Request.String()
+	})
+	return unmatched
+}
+
+// ResetMatchCounts also resets the progress of the sequences and the state of the templates of the stubs of the store.
+func (s *inMemoryStubsStore) ResetMatchCounts() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.templates.reset()
+
+	s.MatchCounts = make(map[string]map[string]int, 0)
+	s.Sequences = make(map[string]*sequenceProgress)
+}
+
+// sortedKeys returns the requests of the stubs in a stable order.
+func sortedKeys(stubsPerRequest map[string][]*Stub) []string {
+	keys := make([]string, 0, len(stubsPerRequest))
+	for key := range stubsPerRequest {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+	return keys
 }
@@ -0,0 +1,129 @@
+package stub
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestInMemoryStubsStore_GetAllStubs_StableOrder(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	for _, content := range []JsonString{`{"name":"c"}`, `{"name":"a"}`, `{"name":"b"}`} {
+		store.Add(&Stub{FullMethod: "/pkg.Service/B", Request: &StubRequest{Match: "exact", Content: content}})
+		store.Add(&Stub{FullMethod: "/pkg.Service/A", Request: &StubRequest{Match: "exact", Content: content}})
+	}
+
+	for i := 0; i < 10; i++ {
+		stubs := store.GetAllStubs()
+		assert.Equal(t, 6, len(stubs))
+		assert.Equal(t, "/pkg.Service/A", stubs[0].FullMethod)
+		assert.Equal(t, JsonString(`{"name":"a"}`), stubs[0].Request.Content)
+		assert.Equal(t, JsonString(`{"name":"c"}`), stubs[2].Request.Content)
+		assert.Equal(t, "/pkg.Service/B", stubs[3].FullMethod)
+		assert.Equal(t, stubs, store.GetUnmatchedStubs())
+	}
+}
+
+func TestInMemoryStubsStore_Add_InvalidContent(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	invalid := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "exact", Content: `{"name":`}}
+
+	assert.EqualError(t, store.Add(invalid), "invalid request content of the stub for /pkg.Service/Method: unexpected EOF")
+	assert.False(t, store.Exists(invalid))
+
+	_, errMsgs := invalid.IsValid()
+	assert.Contains(t, errMsgs, "Request content is not valid JSON: unexpected EOF.")
+}
+
+// TestInMemoryStubsStore_Concurrency changes the stubs while calls are matched. Run with -race to detect data races.
+func TestInMemoryStubsStore_Concurrency(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	matcher := NewStubsMatcher(store)
+	always := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "exact", Content: `{"id":0}`}}
+	store.Add(always)
+
+	var wg sync.WaitGroup
+	for writer := 1; writer <= 4; writer++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				s := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "exact", Content: JsonString(fmt.Sprintf(`{"id":%d}`, writer*1000+i))}}
+				store.Add(s)
+				store.Update(s)
+				store.Delete(s)
+			}
+		}(writer)
+	}
+	for reader := 0; reader < 4; reader++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				assert.Equal(t, always, matcher.Match(context.Background(), "/pkg.Service/Method", `{"id":0}`))
+				store.GetAllStubs()
+				store.GetUnmatchedStubs()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 800, store.GetMatchCount(always))
+	assert.Equal(t, []*Stub{always}, store.GetAllStubs())
+}
+
+// TestInMemoryStubsStore_Candidates checks that the candidates updated as the stubs change are the ones parsed from all the
+// stubs of the method.
+func TestInMemoryStubsStore_Candidates(t *testing.T) {
+	store := newInMemoryStubsStore(false)
+	stubs := make([]*Stub, 0)
+	for i := 0; i < 30; i++ {
+		s := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "exact", Content: JsonString(fmt.Sprintf(`{"id":%d}`, i%7))}}
+		switch i % 3 {
+		case 1:
+			s.Request.Match = "partial"
+		case 2:
+			s.Request.Metadata = map[string][]string{fmt.Sprintf("key%d", i%4): {"a"}}
+		}
+		if store.Add(s) == nil {
+			stubs = append(stubs, s)
+		}
+	}
+	for i, s := range stubs {
+		switch i % 3 {
+		case 0:
+			assert.Nil(t, store.Delete(s))
+		case 1:
+			assert.Nil(t, store.Update(&Stub{FullMethod: s.FullMethod, Type: "forward", Request: s.Request}))
+		}
+	}
+
+	expected := parseCandidates(store, "/pkg.Service/Method")
+	actual := store.matchCandidates("/pkg.Service/Method")
+	assert.Equal(t, expected.sorted, actual.sorted)
+	assert.Equal(t, expected.exact, actual.exact)
+	assert.Equal(t, expected.metadataKeys, actual.metadataKeys)
+	assert.Equal(t, expected.custom, actual.custom)
+
+	store.DeleteAll()
+	assert.Nil(t, store.matchCandidates("/pkg.Service/Method"))
+}
+
+// BenchmarkInMemoryStubsStore_Add adds 10000 stubs of the same method, matching a call after each 1000.
+func BenchmarkInMemoryStubsStore_Add(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		store := NewInMemoryStubsStore()
+		matcher := NewStubsMatcher(store, WithMatchCache(0))
+		for id := 0; id < 10000; id++ {
+			store.Add(&Stub{
+				FullMethod: "/pkg.Service/Method",
+				Type:       "mock",
+				Request:    &StubRequest{Match: "exact", Content: JsonString(fmt.Sprintf(`{"id":%d,"filter":{"active":true}}`, id))},
+			})
+			if id%1000 == 999 && matcher.Match(context.Background(), "/pkg.Service/Method", fmt.Sprintf(`{"id":%d,"filter":{"active":true}}`, id)) == nil {
+				b.Fatal("no stub matched")
+			}
+		}
+	}
+}
@@ -3,8 +3,10 @@ package stub
 import (
 	"bytes"
 	"fmt"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
 )
 
 func CreateStubExample(req proto.Message) string {
@@ -33,9 +35,14 @@ func generateJSONForField(fields protoreflect.FieldDescriptors, writer *bytes.Bu
 		if !field.HasJSONName() {
 			continue
 		}
+		oneOf := field.ContainingOneof()
+		if oneOf != nil && oneOf.IsSynthetic() {
+			// proto3 optional fields are in a synthetic oneof but are written as regular fields
+			oneOf = nil
+		}
 		if !first {
-			if !isOneOf && field.ContainingOneof() != nil {
-				oneOfName := string(field.ContainingOneof().Name())
+			if !isOneOf && oneOf != nil {
+				oneOfName := string(oneOf.Name())
 				if stack[oneOfName] {
 					continue
 				}
@@ -43,27 +50,22 @@ func generateJSONForField(fields protoreflect.FieldDescriptors, writer *bytes.Bu
 			writer.WriteString(", ")
 		}
 		first = false
-		if !isOneOf && field.ContainingOneof() != nil {
-			generateJSONForoneOf(field.ContainingOneof(), writer, stack)
+		if !isOneOf && oneOf != nil {
+			generateJSONForoneOf(oneOf, writer, stack)
 			first = false
 			continue
 		}
 		writer.WriteString(fmt.Sprintf("\"%s\": ", field.JSONName()))
+		if field.IsMap() {
+			writer.WriteString(fmt.Sprintf("{\"%s\": ", getMapKeyExample(field.MapKey())))
+			generateJSONForValue(field.MapValue(), writer, stack)
+			writer.WriteString("}")
+			continue
+		}
 		if field.Cardinality() == protoreflect.Repeated {
 			writer.WriteString(" [")
 		}
-		switch field.Kind() {
-		case protoreflect.MessageKind:
-			generateJSONForType(field.Message(), writer, stack)
-		case protoreflect.StringKind:
-			writer.WriteString("\"\"")
-		case protoreflect.BoolKind:
-			writer.WriteString(" true")
-		case protoreflect.EnumKind:
-			writer.WriteString(fmt.Sprintf("\"%s\"", field.Enum().Values()))
-		default:
-			writer.WriteString(" 0")
-		}
+		generateJSONForValue(field, writer, stack)
 		if field.Cardinality() == protoreflect.Repeated {
 			writer.WriteString("]")
 		}
@@ -71,6 +73,36 @@ func generateJSONForField(fields protoreflect.FieldDescriptors, writer *bytes.Bu
 	return writer
 }
 
+func generateJSONForValue(field protoreflect.FieldDescriptor, writer *bytes.Buffer, stack map[string]bool) {
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if example, ok := wellKnownTypeExamples[field.Message().FullName()]; ok {
+			writer.WriteString(example)
+			break
+		}
+		generateJSONForType(field.Message(), writer, stack)
+	case protoreflect.StringKind:
+		writer.WriteString("\"\"")
+	case protoreflect.BoolKind:
+		writer.WriteString(" true")
+	case protoreflect.EnumKind:
+		writer.WriteString(fmt.Sprintf("\"%s\"", field.Enum().Values()))
+	default:
+		writer.WriteString(" 0")
+	}
+}
+
+// getMapKeyExample returns an example of a map key, which is always a string in JSON.
+func getMapKeyExample(key protoreflect.FieldDescriptor) string {
+	switch key.Kind() {
+	case protoreflect.StringKind:
+		return "key"
+	case protoreflect.BoolKind:
+		return "true"
+	}
+	return "0"
+}
+
 func generateJSONForoneOf(t protoreflect.OneofDescriptor, writer *bytes.Buffer, stack map[string]bool) *bytes.Buffer {
 	typeName := string(t.Name())
 	if stack[typeName] {
@@ -91,3 +123,110 @@ func getEnumValues(enum EnumType) []string {
 	}
 	return values
 }
+
+// CreateStubContent returns the JSON of the message with every field populated with a sample value, which can be used as
+// the content of a stub. Only the first field of each oneof is set, recursive messages are populated once and the messages
+// are not nested deeper than MaxContentDepth.
+func CreateStubContent(t protoreflect.MessageDescriptor) (string, error) {
+	message := dynamicpb.NewMessage(t)
+	if err := populateMessage(message, make(map[protoreflect.FullName]bool)); err != nil {
+		return "", err
+	}
+	data, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func populateMessage(message protoreflect.Message, stack map[protoreflect.FullName]bool) error {
+	t := message.Descriptor()
+	if example, ok := wellKnownTypeExamples[t.FullName()]; ok {
+		if t.FullName() == anyFullName {
+			return nil // the type of the message packed is not known
+		}
+		return protojson.Unmarshal([]byte(example), message.Interface())
+	}
+	if stack[t.FullName()] {
+		return nil
+	}
+	stack[t.FullName()] = true
+	defer delete(stack, t.FullName())
+	for i := 0; i < t.Fields().Len(); i++ {
+		field := t.Fields().Get(i)
+		if oneOf := field.ContainingOneof(); oneOf != nil && !oneOf.IsSynthetic() && oneOf.Fields().Get(0) != field {
+			continue
+		}
+		valueField := field
+		if field.IsMap() {
+			valueField = field.MapValue()
+		}
+		if valueField.Message() != nil && len(stack) >= MaxContentDepth() {
+			// the stubs can't be nested deeper
+			continue
+		}
+		switch {
+		case field.IsMap():
+			value, err := createSampleValue(message.NewField(field).Map().NewValue(), field.MapValue(), stack)
+			if err != nil {
+				return err
+			}
+			message.Mutable(field).Map().Set(createSampleMapKey(field.MapKey()), value)
+		case field.IsList():
+			value, err := createSampleValue(message.NewField(field).List().NewElement(), field, stack)
+			if err != nil {
+				return err
+			}
+			message.Mutable(field).List().Append(value)
+		default:
+			value, err := createSampleValue(message.NewField(field), field, stack)
+			if err != nil {
+				return err
+			}
+			message.Set(field, value)
+		}
+	}
+	return nil
+}
+
+// createSampleValue returns a sample value for the field. newValue is the empty value, used for messages.
+func createSampleValue(newValue protoreflect.Value, field protoreflect.FieldDescriptor, stack map[protoreflect.FullName]bool) (protoreflect.Value, error) {
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		err := populateMessage(newValue.Message(), stack)
+		return newValue, err
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(true), nil
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(string(field.Name())), nil
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte(field.Name())), nil
+	case protoreflect.EnumKind:
+		values := field.Enum().Values()
+		return protoreflect.ValueOfEnum(values.Get(values.Len() - 1).Number()), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(1), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(1), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(1), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(1), nil
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(1.5), nil
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(1.5), nil
+	}
+	return newValue, nil
+}
+
+func createSampleMapKey(key protoreflect.FieldDescriptor) protoreflect.MapKey {
+	switch key.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString("key").MapKey()
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(true).MapKey()
+	}
+	value, _ := createSampleValue(protoreflect.Value{}, key, nil)
+	return value.MapKey()
+}
@@ -0,0 +1,26 @@
+package stub
+
+import (
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/typepb"
+	"testing"
+)
+
+func TestCreateStubContent_PopulatesAllFields(t *testing.T) {
+	content, err := CreateStubContent(new(errdetails.ErrorInfo).ProtoReflect().Descriptor())
+	assert.Nil(t, err)
+	example := JsonString(content)
+	assert.True(t, example.Equals(`{"reason":"reason","domain":"domain","metadata":{"key":"value"}}`), content)
+}
+
+func TestCreateStubContent_CanBeUnmarshalled(t *testing.T) {
+	content, err := CreateStubContent(new(typepb.Type).ProtoReflect().Descriptor())
+	assert.Nil(t, err)
+	message := new(typepb.Type)
+	assert.Nil(t, protojson.Unmarshal([]byte(content), message))
+	assert.Equal(t, "name", message.GetName())
+	assert.Equal(t, 1, len(message.GetFields()))
+	assert.Equal(t, typepb.Syntax_SYNTAX_EDITIONS, message.GetSyntax())
+}
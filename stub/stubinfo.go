@@ -0,0 +1,67 @@
+package stub
+
+import "strings"
+
+// StubFilter selects the stubs by their documentation, see Stub.Name.
+type StubFilter struct {
+	// The stubs must have all the tags
+	Tags []string
+	// Text found, ignoring the case, in the name, the description or the tags of the stubs
+	Query string
+}
+
+// Matches tells whether the stub is selected by the filter. An empty filter selects all the stubs.
+func (f StubFilter) Matches(s *Stub) bool {
+	for _, tag := range f.Tags {
+		if !s.HasTag(tag) {
+			return false
+		}
+	}
+	if f.Query == "" {
+		return true
+	}
+	query := strings.ToLower(f.Query)
+	if strings.Contains(strings.ToLower(s.Name), query) || strings.Contains(strings.ToLower(s.Description), query) {
+		return true
+	}
+	for _, tag := range s.Tags {
+		if strings.Contains(strings.ToLower(tag), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the stubs selected by the filter.
+func (f StubFilter) Filter(stubs []*Stub) []*Stub {
+	if len(f.Tags) == 0 && f.Query == "" {
+		return stubs
+	}
+	filtered := make([]*Stub, 0, len(stubs))
+	for _, s := range stubs {
+		if f.Matches(s) {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
+
+// HasTag tells whether the stub has the tag.
+func (stub *Stub) HasTag(tag string) bool {
+	for _, t := range stub.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (stub *Stub) isValidInfo() (isValid bool, errMsgs []string) {
+	for _, tag := range stub.Tags {
+		if strings.TrimSpace(tag) == "" {
+			errMsgs = append(errMsgs, "Stub tags can't be empty.")
+			break
+		}
+	}
+	return len(errMsgs) == 0, errMsgs
+}
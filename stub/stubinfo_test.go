@@ -0,0 +1,40 @@
+package stub
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStubFilter_Filter(t *testing.T) {
+	login := &Stub{FullMethod: "/pkg.Auth/Login", Name: "Login of John", Description: "Valid credentials", Tags: []string{"auth", "happy-path"}}
+	locked := &Stub{FullMethod: "/pkg.Auth/Login", Name: "Locked account", Tags: []string{"auth", "error"}}
+	anonymous := &Stub{FullMethod: "/pkg.Auth/Logout"}
+	stubs := []*Stub{login, locked, anonymous}
+
+	assert.Equal(t, stubs, StubFilter{}.Filter(stubs))
+	assert.Equal(t, []*Stub{login, locked}, StubFilter{Tags: []string{"auth"}}.Filter(stubs))
+	assert.Equal(t, []*Stub{locked}, StubFilter{Tags: []string{"auth", "error"}}.Filter(stubs))
+	assert.Equal(t, []*Stub{login}, StubFilter{Query: "CREDENTIALS"}.Filter(stubs))
+	assert.Equal(t, []*Stub{login}, StubFilter{Query: "happy"}.Filter(stubs))
+	assert.Equal(t, []*Stub{locked}, StubFilter{Tags: []string{"auth"}, Query: "locked"}.Filter(stubs))
+	assert.Empty(t, StubFilter{Tags: []string{"missing"}}.Filter(stubs))
+}
+
+func TestStub_IsValid_Tags(t *testing.T) {
+	s := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "partial", Content: `{}`},
+		Response: &StubResponse{Type: "success", Content: `{}`}, Name: "any call", Tags: []string{"smoke", " "}}
+
+	isValid, errors := s.IsValid()
+	assert.False(t, isValid)
+	assert.Equal(t, []string{"Stub tags can't be empty."}, errors)
+}
+
+func TestReport_JUnit_StubName(t *testing.T) {
+	store := NewInMemoryStubsStore()
+	assert.Nil(t, store.Add(&Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Name: "any call",
+		Request: &StubRequest{Match: "partial", Content: `{}`}, Response: &StubResponse{Type: "success", Content: `{}`}}))
+
+	data, err := NewReport(nil, store).JUnit()
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `name="Method any call"`)
+}
@@ -0,0 +1,205 @@
+package stub
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"sync"
+	"text/template"
+)
+
+// initialScenarioState is the state of the scenarios that were never set.
+const initialScenarioState = "started"
+
+// templateState is the data shared by the templates of the stubs of a store so that the calls of a flow can share data,
+// e.g. the ID created by a call returned by the next ones. It is reset with the match counts of the store, see
+// StubsStore.ResetMatchCounts, so that the stores, e.g. the ones of the service sets, don't share it. The responses
+// rendered without the state of a store, see WithTemplateState, use defaultTemplateState.
+type templateState struct {
+	variables map[string]string
+	counters  map[string]int
+	scenarios map[string]string
+	mutex     sync.Mutex
+	// templates parsed with the functions of the state, by text
+	templates      map[string]*template.Template
+	templatesMutex sync.RWMutex
+}
+
+func newTemplateState() *templateState {
+	state := &templateState{templates: make(map[string]*template.Template)}
+	state.reset()
+	return state
+}
+
+var defaultTemplateState = newTemplateState()
+
+// templateStateStore is implemented by the stores keeping the state of the templates of their stubs.
+type templateStateStore interface {
+	templateState() *templateState
+}
+
+type templateStateKey struct{}
+
+// WithTemplateState returns the context of a call whose stub was found by the matcher, so that the templates of the
+// response share the state of the store of the matcher, and are reset with its match counts. The templates of the
+// responses rendered with another context share the state reset by ResetTemplateState.
+func WithTemplateState(ctx context.Context, matcher StubsMatcher) context.Context {
+	m, ok := matcher.(*stubsMatcher)
+	if !ok {
+		return ctx
+	}
+	store, ok := m.StubsStore.(templateStateStore)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, templateStateKey{}, store.templateState())
+}
+
+// storeTemplateState returns the template state of the store, or defaultTemplateState when it does not keep one.
+func storeTemplateState(store StubsStore) *templateState {
+	if store, ok := store.(templateStateStore); ok {
+		return store.templateState()
+	}
+	return defaultTemplateState
+}
+
+// Scenarios returns the states of the scenarios of the templates of the stubs of the store, by name. The scenarios that
+// were never set are in the initial state, started, and not returned.
+func Scenarios(store StubsStore) map[string]string {
+	state := storeTemplateState(store)
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	scenarios := make(map[string]string, len(state.scenarios))
+	for name, scenarioState := range state.scenarios {
+		scenarios[name] = scenarioState
+	}
+	return scenarios
+}
+
+// SetScenario changes the state of the scenario of the templates of the stubs of the store, e.g. to start a test from a
+// step of a flow without calling the steps before it.
+func SetScenario(store StubsStore, name, state string) {
+	storeTemplateState(store).setScenarioState(name, state)
+}
+
+// templateStateOf returns the template state of the call, see WithTemplateState.
+func templateStateOf(ctx context.Context) *templateState {
+	if state, ok := ctx.Value(templateStateKey{}).(*templateState); ok {
+		return state
+	}
+	return defaultTemplateState
+}
+
+// funcs returns the functions available to the templates of the responses, in addition to the methods of
+// callTemplateData. The functions changing the state return an empty string so that they render nothing.
+func (s *templateState) funcs() template.FuncMap {
+	return template.FuncMap{
+		"set":          s.setVariable,
+		"get":          s.getVariable,
+		"counter":      s.incrementCounter,
+		"scenario":     s.scenarioState,
+		"setScenario":  s.setScenarioState,
+		"randomInt":    randomInt,
+		"randomString": randomString,
+		"uuid":         randomUUID,
+		"now":          templateNow,
+		"nowAdd":       templateNowAdd,
+		"nowUnix":      templateNowUnix,
+	}
+}
+
+// ResetTemplateState deletes the variables, the counters and the states of the scenarios of the templates rendered
+// without the state of a store, see WithTemplateState. The ones of a store are reset with its match counts.
+func ResetTemplateState() {
+	defaultTemplateState.reset()
+}
+
+func (s *templateState) reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.variables = make(map[string]string)
+	s.counters = make(map[string]int)
+	s.scenarios = make(map[string]string)
+}
+
+// setVariable stores the value under the name, e.g. {{set "orderId" (.Field "id")}}.
+func (s *templateState) setVariable(name string, value interface{}) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.variables[name] = fmt.Sprint(value)
+	return ""
+}
+
+// getVariable returns the value stored under the name, or an empty string when none was.
+func (s *templateState) getVariable(name string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.variables[name]
+}
+
+// incrementCounter increments the counter and returns its value, 1 the first time it is rendered.
+func (s *templateState) incrementCounter(name string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.counters[name]++
+	return s.counters[name]
+}
+
+// scenarioState returns the state of the scenario, initialScenarioState until it is set.
+func (s *templateState) scenarioState(name string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if state, ok := s.scenarios[name]; ok {
+		return state
+	}
+	return initialScenarioState
+}
+
+func (s *templateState) setScenarioState(name, state string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.scenarios[name] = state
+	return ""
+}
+
+// randomInt returns a random integer between min and max included.
+func randomInt(min, max int) (int, error) {
+	if max < min {
+		return 0, fmt.Errorf("randomInt: max %d is lower than min %d", max, min)
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)-int64(min)+1))
+	if err != nil {
+		return 0, err
+	}
+	return min + int(n.Int64()), nil
+}
+
+const randomStringLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// randomString returns a random string of length letters and digits.
+func randomString(length int) (string, error) {
+	if length < 0 {
+		return "", fmt.Errorf("randomString: length %d is negative", length)
+	}
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(randomStringLetters))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = randomStringLetters[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// randomUUID returns a random UUID, version 4.
+func randomUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
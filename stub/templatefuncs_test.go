@@ -0,0 +1,119 @@
+package stub
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestGetCallResponse_TemplateVariables(t *testing.T) {
+	ResetTemplateState()
+	create := &Stub{FullMethod: "/pkg.Service/Create", Type: "mock", Request: &StubRequest{Match: "partial", Content: `{}`},
+		Response: &StubResponse{Type: "success", Content: `{"id":"{{set \"orderId\" (.Field \"name\")}}{{get \"orderId\"}}","call":"{{counter \"create\"}}"}`}}
+	get := &Stub{FullMethod: "/pkg.Service/Get", Type: "mock", Request: &StubRequest{Match: "partial", Content: `{}`},
+		Response: &StubResponse{Type: "success", Content: `"{{get \"orderId\"}}"`}}
+
+	resp, err := GetCallResponse(context.Background(), create, `{"name":"order-1"}`, &structpb.Struct{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"id": "order-1", "call": "1"}, resp.(*structpb.Struct).AsMap())
+
+	resp, err = GetCallResponse(context.Background(), get, `{}`, &wrapperspb.StringValue{})
+	assert.Nil(t, err)
+	assert.Equal(t, "order-1", resp.(*wrapperspb.StringValue).Value)
+
+	resp, err = GetCallResponse(context.Background(), create, `{"name":"order-2"}`, &structpb.Struct{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"id": "order-2", "call": "2"}, resp.(*structpb.Struct).AsMap())
+
+	// the static response is not rendered
+	resp, err = GetResponse(get, `{}`, &wrapperspb.StringValue{})
+	assert.Nil(t, err)
+	assert.Equal(t, `{{get "orderId"}}`, resp.(*wrapperspb.StringValue).Value)
+
+	ResetTemplateState()
+	resp, err = GetCallResponse(context.Background(), get, `{}`, &wrapperspb.StringValue{})
+	assert.Nil(t, err)
+	assert.Equal(t, "", resp.(*wrapperspb.StringValue).Value)
+}
+
+func TestWithTemplateState(t *testing.T) {
+	ResetTemplateState()
+	counter := &Stub{FullMethod: "/pkg.Service/Count", Type: "mock", Request: &StubRequest{Match: "partial", Content: `{}`},
+		Response: &StubResponse{Type: "success", Content: `"{{counter \"calls\"}}"`}}
+	store, otherStore := NewInMemoryStubsStore(), NewInMemoryStubsStore()
+	ctx := WithTemplateState(context.Background(), NewStubsMatcher(store))
+	otherCtx := WithTemplateState(context.Background(), NewStubsMatcher(otherStore))
+	count := func(ctx context.Context) string {
+		resp, err := GetCallResponse(ctx, counter, `{}`, &wrapperspb.StringValue{})
+		assert.Nil(t, err)
+		return resp.(*wrapperspb.StringValue).Value
+	}
+
+	assert.Equal(t, "1", count(ctx))
+	assert.Equal(t, "2", count(ctx))
+	assert.Equal(t, "1", count(otherCtx))
+	assert.Equal(t, "1", count(context.Background()))
+
+	otherStore.ResetMatchCounts()
+	ResetTemplateState()
+	assert.Equal(t, "3", count(ctx))
+	store.ResetMatchCounts()
+	assert.Equal(t, "1", count(ctx))
+	assert.Equal(t, "1", count(otherCtx))
+}
+
+func TestRenderTemplate_Functions(t *testing.T) {
+	ResetTemplateState()
+	render := func(text string) string {
+		rendered, err := renderTemplate(context.Background(), text, `{}`)
+		assert.Nil(t, err, text)
+		return rendered
+	}
+
+	assert.Equal(t, "started", render(`{{scenario "checkout"}}`))
+	assert.Equal(t, "", render(`{{setScenario "checkout" "paid"}}`))
+	assert.Equal(t, "paid", render(`{{scenario "checkout"}}`))
+	assert.Equal(t, "1 2", render(`{{counter "a"}} {{counter "a"}}`))
+	assert.Equal(t, "1", render(`{{counter "b"}}`))
+	assert.Equal(t, "7", render(`{{randomInt 7 7}}`))
+	n, err := strconv.Atoi(render(`{{randomInt 1 6}}`))
+	assert.Nil(t, err)
+	assert.True(t, n >= 1 && n <= 6)
+	assert.Regexp(t, regexp.MustCompile(`^[a-zA-Z0-9]{12}$`), render(`{{randomString 12}}`))
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), render(`{{uuid}}`))
+
+	_, err = renderTemplate(context.Background(), `{{randomInt 2 1}}`, `{}`)
+	assert.NotNil(t, err)
+}
+
+func TestStub_IsValid_ContentTemplates(t *testing.T) {
+	s := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "partial", Content: `{}`},
+		Response: &StubResponse{Type: "success", Content: `{"id":"{{get \"id\"}}","name":"{{unknown}}"}`}}
+
+	isValid, errors := s.IsValid()
+	assert.False(t, isValid)
+	assert.Equal(t, []string{`Response content '{{unknown}}' is not a valid template: template: response:1: function "unknown" not defined.`}, errors)
+}
+
+func TestSetScenario(t *testing.T) {
+	scenario := &Stub{FullMethod: "/pkg.Service/Pay", Type: "mock", Request: &StubRequest{Match: "partial", Content: `{}`},
+		Response: &StubResponse{Type: "success", Content: `"{{scenario \"checkout\"}}"`}}
+	store, otherStore := NewInMemoryStubsStore(), NewInMemoryStubsStore()
+	ctx := WithTemplateState(context.Background(), NewStubsMatcher(store))
+
+	assert.Equal(t, map[string]string{}, Scenarios(store))
+	SetScenario(store, "checkout", "paid")
+	assert.Equal(t, map[string]string{"checkout": "paid"}, Scenarios(store))
+	assert.Equal(t, map[string]string{}, Scenarios(otherStore))
+
+	resp, err := GetCallResponse(ctx, scenario, `{}`, &wrapperspb.StringValue{})
+	assert.Nil(t, err)
+	assert.Equal(t, "paid", resp.(*wrapperspb.StringValue).Value)
+
+	store.ResetMatchCounts()
+	assert.Equal(t, map[string]string{}, Scenarios(store))
+}
@@ -0,0 +1,240 @@
+package stub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/metadata"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// maxParsedTemplates is the number of templates kept parsed. When it is reached the templates parsed are discarded.
+const maxParsedTemplates = 1000
+
+// callTemplateData is the call a value of a response is rendered for. The templates call its methods, e.g.
+// {{.Metadata "x-request-id"}} or {{.Field "user.name"}}.
+type callTemplateData struct {
+	ctx         context.Context
+	requestJson string
+	request     interface{}
+	parsed      bool
+}
+
+// Metadata returns the first value of the key in the metadata of the call, or an empty string when it is not set.
+func (d *callTemplateData) Metadata(key string) string {
+	md, _ := metadata.FromIncomingContext(d.ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Field returns the value of a field of the request by its path of JSON names, with the index of the items of the
+// repeated fields, e.g. items.0.name. An empty path is the whole request, e.g. the value of a wrapper type like
+// google.protobuf.StringValue. Objects and arrays are returned in JSON, and missing fields as an empty string.
+func (d *callTemplateData) Field(path string) string {
+	if !d.parsed {
+		d.request, _ = parseJson(d.requestJson)
+		d.parsed = true
+	}
+	value := d.request
+	segments := strings.Split(path, ".")
+	if path == "" {
+		segments = nil
+	}
+	for _, segment := range segments {
+		switch current := value.(type) {
+		case map[string]interface{}:
+			value = current[segment]
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(current) {
+				return ""
+			}
+			value = current[i]
+		default:
+			return ""
+		}
+	}
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(value)
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
+
+// renderTemplate returns the value of the response rendered for the call, with the template state of the call, see
+// WithTemplateState. Values without template actions are returned as is.
+func renderTemplate(ctx context.Context, text, requestJson string) (string, error) {
+	if !hasTemplates(text) {
+		return text, nil
+	}
+	t, err := templateStateOf(ctx).parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, &callTemplateData{ctx: ctx, requestJson: requestJson}); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// HasTemplates tells whether the content has template actions, rendered for each call. Its values may then only have the
+// type of their field once rendered, e.g. a counter in a number field.
+func (j JsonString) HasTemplates() bool {
+	return hasTemplates(j.String())
+}
+
+// hasTemplates tells whether the text has template actions.
+func hasTemplates(text string) bool {
+	return strings.Contains(text, "{{")
+}
+
+// parseTemplate parses the text, e.g. to check that it is valid, with the functions of defaultTemplateState.
+func parseTemplate(text string) (*template.Template, error) {
+	return defaultTemplateState.parse(text)
+}
+
+// parse returns the template of the text with the functions of the state, parsed once.
+func (s *templateState) parse(text string) (*template.Template, error) {
+	s.templatesMutex.RLock()
+	t, ok := s.templates[text]
+	s.templatesMutex.RUnlock()
+	if ok {
+		return t, nil
+	}
+	t, err := template.New("response").Funcs(s.funcs()).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	s.templatesMutex.Lock()
+	defer s.templatesMutex.Unlock()
+	if len(s.templates) >= maxParsedTemplates {
+		s.templates = make(map[string]*template.Template)
+	}
+	s.templates[text] = t
+	return t, nil
+}
+
+// renderValue returns the value of the response of the stub rendered for the call, or the value as is when it can't be
+// rendered, e.g. when the template calls an unknown method.
+func renderValue(ctx context.Context, s *Stub, name, value, requestJson string) string {
+	rendered, err := renderTemplate(ctx, value, requestJson)
+	if err != nil {
+		log.Errorf("Failed to render the %s of the response of the stub for %s: %s", name, s.FullMethod, err)
+		return value
+	}
+	return rendered
+}
+
+// renderContent returns the content of the response of the stub with the templates of its string values rendered for the
+// call, e.g. {"id": "{{get `orderId`}}"}. The content is returned as is when it can't be parsed.
+func renderContent(ctx context.Context, s *Stub, requestJson string) string {
+	content, err := parseJson(s.Response.Content.String())
+	if err != nil {
+		return s.Response.Content.String()
+	}
+	content = mapJsonStrings(content, func(value string) string {
+		return renderValue(ctx, s, "content", value, requestJson)
+	})
+	data, err := json.Marshal(content)
+	if err != nil {
+		return s.Response.Content.String()
+	}
+	return string(data)
+}
+
+// mapJsonStrings replaces the string values with template actions of the JSON value with the ones returned by render.
+func mapJsonStrings(value interface{}, render func(string) string) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			value[key] = mapJsonStrings(field, render)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = mapJsonStrings(item, render)
+		}
+	case string:
+		if hasTemplates(value) {
+			return render(value)
+		}
+	}
+	return value
+}
+
+// ResponseMetadata returns the header and the trailer of the response of the stub, with their values rendered for the
+// call.
+func ResponseMetadata(ctx context.Context, s *Stub, requestJson string) (header, trailer metadata.MD) {
+	if s.Response == nil {
+		return nil, nil
+	}
+	return renderMetadata(ctx, s, s.Response.Headers, requestJson), renderMetadata(ctx, s, s.Response.Trailers, requestJson)
+}
+
+func renderMetadata(ctx context.Context, s *Stub, values map[string][]string, requestJson string) metadata.MD {
+	md := metadata.MD{}
+	for key, keyValues := range values {
+		for _, value := range keyValues {
+			md.Append(key, renderValue(ctx, s, "metadata "+key, value, requestJson))
+		}
+	}
+	return md
+}
+
+// isValidTemplates checks that the values of the response with template actions can be parsed, and that the metadata
+// keys of the response are not reserved by gRPC.
+func (r *StubResponse) isValidTemplates() (errMsgs []string) {
+	errMsgs = append(errMsgs, metadataErrors("header", r.Headers)...)
+	errMsgs = append(errMsgs, metadataErrors("trailer", r.Trailers)...)
+	if r.Error != nil && hasTemplates(r.Error.Message) {
+		if _, err := parseTemplate(r.Error.Message); err != nil {
+			errMsgs = append(errMsgs, fmt.Sprintf("Response error message is not a valid template: %v.", err))
+		}
+	}
+	if r.Type == "success" && hasTemplates(r.Content.String()) {
+		if content, err := parseJson(r.Content.String()); err == nil {
+			mapJsonStrings(content, func(value string) string {
+				if _, err := parseTemplate(value); err != nil {
+					errMsgs = append(errMsgs, fmt.Sprintf("Response content '%s' is not a valid template: %v.", value, err))
+				}
+				return value
+			})
+		}
+	}
+	return errMsgs
+}
+
+func metadataErrors(name string, values map[string][]string) (errMsgs []string) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lower := strings.ToLower(key)
+		if key == "" || strings.HasPrefix(lower, "grpc-") || strings.HasPrefix(lower, ":") || strings.HasSuffix(lower, "-bin") {
+			errMsgs = append(errMsgs, fmt.Sprintf("Response %s '%s' can't be set. Reserved and binary keys are not supported.", name, key))
+		}
+		for _, value := range values[key] {
+			if !hasTemplates(value) {
+				continue
+			}
+			if _, err := parseTemplate(value); err != nil {
+				errMsgs = append(errMsgs, fmt.Sprintf("Response %s '%s' is not a valid template: %v.", name, key, err))
+			}
+		}
+	}
+	return errMsgs
+}
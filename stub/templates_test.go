@@ -0,0 +1,62 @@
+package stub
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "abc"))
+	request := `{"user":{"name":"John","age":2,"tags":["a","b"]},"items":[{"id":"1"}]}`
+
+	for text, expected := range map[string]string{
+		"plain":                        "plain",
+		`{{.Metadata "x-request-id"}}`: "abc",
+		"{{.Metadata `missing`}}":      "",
+		`{{.Field "user.name"}}-{{.Field "user.age"}}`: "John-2",
+		`{{.Field "user.tags"}}`:                       `["a","b"]`,
+		`{{.Field "items.0.id"}}`:                      "1",
+		`{{.Field "items.1.id"}}`:                      "",
+		`{{.Field "user.name.first"}}`:                 "",
+		`{{.Field ""}}`:                                `{"items":[{"id":"1"}],"user":{"age":2,"name":"John","tags":["a","b"]}}`,
+	} {
+		rendered, err := renderTemplate(ctx, text, request)
+		assert.Nil(t, err, text)
+		assert.Equal(t, expected, rendered, text)
+	}
+
+	_, err := renderTemplate(ctx, `{{.Unknown}}`, request)
+	assert.Error(t, err)
+}
+
+func TestGetCallResponse_ErrorMessageTemplate(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "abc"))
+	s := &Stub{FullMethod: "/pkg.Service/Method", Type: "mock", Request: &StubRequest{Match: "partial", Content: `{}`},
+		Response: &StubResponse{Type: "error", Error: &ErrorResponse{Code: 5, Message: `{{.Field "name"}} not found ({{.Metadata "x-request-id"}})`}}}
+
+	_, err := GetCallResponse(ctx, s, `{"name":"John"}`, nil)
+	assert.EqualError(t, err, "rpc error: code = NotFound desc = John not found (abc)")
+	assert.Equal(t, `{{.Field "name"}} not found ({{.Metadata "x-request-id"}})`, s.Response.Error.Message)
+}
+
+func TestStub_IsValid_ResponseTemplates(t *testing.T) {
+	s := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &StubRequest{Match: "partial", Content: `{}`},
+		Response: &StubResponse{Type: "success", Content: `{}`,
+			Headers: map[string][]string{"x-request-id": {`{{.Metadata "x-request-id"}}`}}, Trailers: map[string][]string{"x-count": {"1"}}},
+	}
+	isValid, errMsgs := s.IsValid()
+	assert.True(t, isValid, errMsgs)
+
+	s.Response.Headers = map[string][]string{"grpc-status": {"0"}, "x-id": {"{{.Metadata"}}
+	isValid, errMsgs = s.IsValid()
+	assert.False(t, isValid)
+	assert.Equal(t, []string{
+		"Response header 'grpc-status' can't be set. Reserved and binary keys are not supported.",
+		"Response header 'x-id' is not a valid template: template: response:1: unclosed action.",
+	}, errMsgs)
+}
@@ -4,6 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"google.golang.org/protobuf/reflect/protoreflect"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type StubsValidator interface {
@@ -30,29 +34,107 @@ func (c compositeStubsValidator) IsValid(stub *Stub) (isValid bool, errorMessage
 	return true, nil
 }
 
+// IsStubValid checks the stub against the descriptors of the request and the response of its method. The binary content of
+// the stub is converted to JSON first.
 func IsStubValid(stub *Stub, request, response protoreflect.MessageDescriptor) (isValid bool, errorMessages []string) {
 	valid, errorMessages := stub.IsValid()
 	if !valid {
 		return valid, errorMessages
 	}
+	if errorMessages = stub.convertBinaryContent(request, response); len(errorMessages) > 0 {
+		return false, errorMessages
+	}
+	if errorMessages = stub.streamingForwardErrors(); len(errorMessages) > 0 {
+		return false, errorMessages
+	}
 	reqValid, reqErrorMessages := stub.Request.Content.isJsonValid(request, "request.content")
 	respValid := true
 	respErrorMessages := make([]string, 0)
 	if stub.Type == "mock" && stub.Response.Type == "success" {
 		respValid, respErrorMessages = stub.Response.Content.isJsonValid(response, "response.content")
 	}
+	if stub.Type == "mock" && stub.Response.Type == "remote" && stub.Response.Remote.Fallback != nil && stub.Response.Remote.Fallback.Type == "success" {
+		respValid, respErrorMessages = stub.Response.Remote.Fallback.Content.isJsonValid(response, "response.remote.fallback.content")
+	}
+	if stub.Type == "forward" && stub.Forward.Transform != nil && stub.Forward.Transform.Content != "" {
+		respValid, respErrorMessages = stub.Forward.Transform.Content.isJsonValid(response, "forward.transform.content")
+	}
+	if stub.Request.Match == "partial" {
+		defaultErrorMessages := stub.Request.Content.defaultValuedFields(request, "request.content")
+		reqValid = reqValid && len(defaultErrorMessages) == 0
+		reqErrorMessages = append(reqErrorMessages, defaultErrorMessages...)
+	}
+	if stub.Request.FieldMask != nil {
+		fieldMaskErrorMessages := stub.Request.FieldMask.fieldMaskErrors(request)
+		reqValid = reqValid && len(fieldMaskErrorMessages) == 0
+		reqErrorMessages = append(reqErrorMessages, fieldMaskErrorMessages...)
+	}
 	errorMessages = append(errorMessages, reqErrorMessages...)
 	errorMessages = append(errorMessages, respErrorMessages...)
 	return reqValid && respValid, errorMessages
 }
 
+// defaultValuedFields reports the fields without presence set to their default value. Requests never contain them as
+// they are not distinguishable from unset fields, so a partial match would ignore them and match any value.
+// proto3 optional fields keep their presence and can be matched against the default value.
+func (j JsonString) defaultValuedFields(t protoreflect.MessageDescriptor, baseName string) []string {
+	jsonResult := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(string(j)), &jsonResult); err != nil {
+		return nil
+	}
+	return defaultValuedFields(t, jsonResult, baseName)
+}
+
+func defaultValuedFields(t protoreflect.MessageDescriptor, json map[string]interface{}, baseName string) (errorMessages []string) {
+	for jsonName, fieldValue := range json {
+		field := t.Fields().ByJSONName(jsonName)
+		if field == nil || field.Cardinality() == protoreflect.Repeated {
+			continue
+		}
+		if field.Kind() == protoreflect.MessageKind || field.Kind() == protoreflect.GroupKind {
+			if value, ok := fieldValue.(map[string]interface{}); ok && !isWellKnownType(field.Message()) {
+				errorMessages = append(errorMessages, defaultValuedFields(field.Message(), value, baseName+"."+jsonName)...)
+			}
+			continue
+		}
+		if field.HasPresence() || !isDefaultJsonValue(field, fieldValue) {
+			continue
+		}
+		errorMessages = append(errorMessages, fmt.Sprintf("Field '%s.%s' is set to its default value, which can't be distinguished from not set in a partial match. Remove it or declare the field optional.", baseName, jsonName))
+	}
+	return errorMessages
+}
+
+func isDefaultJsonValue(field protoreflect.FieldDescriptor, value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case string:
+		switch field.Kind() {
+		case protoreflect.StringKind, protoreflect.BytesKind:
+			return v == ""
+		case protoreflect.EnumKind:
+			enumValue := field.Enum().Values().ByName(protoreflect.Name(v))
+			return enumValue != nil && enumValue.Number() == 0
+		}
+		// 64 bit integers and special floating point values are strings in JSON
+		return v == "0"
+	}
+	return false
+}
+
+// isJsonValid checks the JSON value against the descriptor of the message, as protojson would parse it: the names of the
+// fields, the types of their values and the names of the enum values.
 func (j JsonString) isJsonValid(t protoreflect.MessageDescriptor, baseName string) (isValid bool, errorMessages []string) {
-	jsonResult := new(map[string]interface{})
-	err := json.Unmarshal([]byte(string(j)), jsonResult)
+	var jsonResult interface{}
+	err := json.Unmarshal([]byte(string(j)), &jsonResult)
 	if err != nil {
-		return false, []string{fmt.Sprintf("%s: invalid JSON", baseName)}
+		return false, []string{fmt.Sprintf("%s: invalid JSON: %v", baseName, err)}
 	}
-	return isJsonValid(t, *jsonResult, baseName)
+	errorMessages = isMessageJsonValid(t, jsonResult, baseName)
+	return len(errorMessages) == 0, errorMessages
 }
 
 func isJsonValid(t protoreflect.MessageDescriptor, json map[string]interface{}, baseName string) (isValid bool, errorMessages []string) {
@@ -60,50 +142,260 @@ func isJsonValid(t protoreflect.MessageDescriptor, json map[string]interface{},
 	reverseFields := make(map[string]protoreflect.FieldDescriptor, 0)
 	for i := 0; i < t.Fields().Len(); i++ {
 		field := t.Fields().Get(i)
-		if !field.HasJSONName() {
-			continue
+		// protojson also reads the fields by their name in the proto file
+		reverseFields[string(field.Name())] = field
+		if field.HasJSONName() {
+			reverseFields[field.JSONName()] = field
 		}
-
-		jsonTag := field.JSONName()
-		reverseFields[jsonTag] = field
 	}
 	for jsonName, fieldValue := range json {
 		field, ok := reverseFields[jsonName]
 		if !ok {
-			errorMessages = append(errorMessages, fmt.Sprintf("Field '%s.%s' does not exist", baseName, jsonName))
+			message := fmt.Sprintf("Field '%s.%s' does not exist", baseName, jsonName)
+			if suggestion := closestFieldName(t, jsonName); suggestion != "" {
+				message += fmt.Sprintf(". Did you mean '%s'?", suggestion)
+			}
+			errorMessages = append(errorMessages, message)
 			continue
 		}
 		if fieldValue == nil {
 			continue
 		}
+		name := baseName + "." + jsonName
 		switch {
-		case field.Kind() == protoreflect.StringKind:
-			switch fieldValue.(type) {
-			case string:
-			default:
-				errorMessages = append(errorMessages, fmt.Sprintf("Field '%s.%s' is expected to be a string.", baseName, jsonName))
+		case field.IsMap():
+			errorMessages = append(errorMessages, isMapJsonValid(field, fieldValue, name)...)
+		case field.IsList():
+			list, isList := fieldValue.([]interface{})
+			if !isList {
+				errorMessages = append(errorMessages, fmt.Sprintf("Field '%s' is expected to be an array.", name))
+				continue
 			}
-		case field.Kind() == protoreflect.MessageKind:
-			_, subTypeErrorMessages := isJsonValid(field.Message(), fieldValue.(map[string]interface{}), baseName+"."+jsonName)
-			errorMessages = append(errorMessages, subTypeErrorMessages...)
-		case field.Kind() == protoreflect.EnumKind:
-			found := false
-			for i := 0; i < field.Enum().Values().Len(); i++ {
-				value := field.Enum().Values().Get(i)
-				if value == fieldValue {
-					found = true
-					break // TODO make sure break is leaving the for loop
-				}
-			}
-			if !found {
-				// TODO implement the correct names
-				errorMessages = append(errorMessages, fmt.Sprintf("Value '%s' is not valid for field '%s.%s'. Possible values are '%s'.", fieldValue, baseName, jsonName, field.Enum().ReservedNames()))
+			for i, value := range list {
+				errorMessages = append(errorMessages, isValueJsonValid(field, value, fmt.Sprintf("%s[%d]", name, i))...)
 			}
+		default:
+			errorMessages = append(errorMessages, isValueJsonValid(field, fieldValue, name)...)
 		}
 	}
 	return len(errorMessages) == 0, errorMessages
 }
 
+// isValueJsonValid checks a single value of the field, e.g. an item of a repeated field, in the JSON form of its kind. The
+// strings with template actions, e.g. a counter in a number field, are only known once rendered for a call, so their type
+// is not checked.
+func isValueJsonValid(field protoreflect.FieldDescriptor, value interface{}, name string) []string {
+	if text, isString := value.(string); isString && hasTemplates(text) {
+		return nil
+	}
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return isMessageJsonValid(field.Message(), value, name)
+	case protoreflect.EnumKind:
+		return isEnumJsonValid(field.Enum(), value, name)
+	case protoreflect.StringKind:
+		if _, isString := value.(string); !isString {
+			return []string{fmt.Sprintf("Field '%s' is expected to be a string.", name)}
+		}
+	case protoreflect.BytesKind:
+		if !isBytesJsonValid(value) {
+			return []string{fmt.Sprintf("Field '%s' is expected to be a base64 encoded string.", name)}
+		}
+	case protoreflect.BoolKind:
+		if _, isBool := value.(bool); !isBool {
+			return []string{fmt.Sprintf("Field '%s' is expected to be a boolean.", name)}
+		}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		if !isFloatJsonValid(value) {
+			return []string{fmt.Sprintf("Field '%s' is expected to be a number.", name)}
+		}
+	default:
+		if !isIntegerJsonValid(field.Kind(), value) {
+			return []string{fmt.Sprintf("Field '%s' is expected to be a %s integer.", name, integerKindNames[field.Kind()])}
+		}
+	}
+	return nil
+}
+
+// isEnumJsonValid checks an enum value, written with its name or its number.
+func isEnumJsonValid(enum protoreflect.EnumDescriptor, value interface{}, name string) []string {
+	switch v := value.(type) {
+	case string:
+		if enum.Values().ByName(protoreflect.Name(v)) != nil {
+			return nil
+		}
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt32 && v <= math.MaxInt32 {
+			return nil
+		}
+	}
+	names := make([]string, 0, enum.Values().Len())
+	for i := 0; i < enum.Values().Len(); i++ {
+		names = append(names, fmt.Sprintf("'%s'", enum.Values().Get(i).Name()))
+	}
+	return []string{fmt.Sprintf("Value '%v' is not valid for field '%s'. Possible values are %s.", value, name, strings.Join(names, ", "))}
+}
+
+var integerKindNames = map[protoreflect.Kind]string{
+	protoreflect.Int32Kind:    "32 bit",
+	protoreflect.Sint32Kind:   "32 bit",
+	protoreflect.Sfixed32Kind: "32 bit",
+	protoreflect.Uint32Kind:   "32 bit unsigned",
+	protoreflect.Fixed32Kind:  "32 bit unsigned",
+	protoreflect.Int64Kind:    "64 bit",
+	protoreflect.Sint64Kind:   "64 bit",
+	protoreflect.Sfixed64Kind: "64 bit",
+	protoreflect.Uint64Kind:   "64 bit unsigned",
+	protoreflect.Fixed64Kind:  "64 bit unsigned",
+}
+
+// isIntegerJsonValid checks an integer, written as a JSON number or as a string, in the range of its kind.
+func isIntegerJsonValid(kind protoreflect.Kind, value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return isMapKeyValid(kind, v)
+	case float64:
+		if v != math.Trunc(v) {
+			return false
+		}
+		switch integerKindNames[kind] {
+		case "32 bit":
+			return v >= math.MinInt32 && v <= math.MaxInt32
+		case "32 bit unsigned":
+			return v >= 0 && v <= math.MaxUint32
+		case "64 bit":
+			return v >= math.MinInt64 && v < math.MaxInt64
+		case "64 bit unsigned":
+			return v >= 0 && v < math.MaxUint64
+		}
+	}
+	return false
+}
+
+// isFloatJsonValid checks a floating point number, written as a JSON number or as a string, e.g. "1.5" or "NaN".
+func isFloatJsonValid(value interface{}) bool {
+	switch v := value.(type) {
+	case float64:
+		return true
+	case string:
+		switch v {
+		case "NaN", "Infinity", "-Infinity":
+			return true
+		}
+		_, err := strconv.ParseFloat(v, 64)
+		return err == nil
+	}
+	return false
+}
+
+// isBytesJsonValid checks a bytes value, written in base64 with the standard or the URL alphabet, padded or not.
+func isBytesJsonValid(value interface{}) bool {
+	v, isString := value.(string)
+	if !isString {
+		return false
+	}
+	_, err := decodeBase64(v)
+	return err == nil
+}
+
+// closestFieldName returns the name of the field of the message the unknown name is a typo of, or "" if none is close.
+func closestFieldName(t protoreflect.MessageDescriptor, unknown string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(unknown, "_", ""))
+	closest, closestDistance := "", 3
+	for i := 0; i < t.Fields().Len(); i++ {
+		jsonName := t.Fields().Get(i).JSONName()
+		if strings.ToLower(jsonName) == normalized {
+			return jsonName
+		}
+		if distance := editDistance(strings.ToLower(jsonName), normalized); distance < closestDistance && distance <= len(jsonName)/2 {
+			closest, closestDistance = jsonName, distance
+		}
+	}
+	return closest
+}
+
+// editDistance returns the number of characters to insert, delete or replace to change a into b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// isMapJsonValid checks a map field, written as a JSON object with the keys as strings, e.g. {"1": "one"} for a map<int32, string>.
+func isMapJsonValid(field protoreflect.FieldDescriptor, value interface{}, name string) (errorMessages []string) {
+	entries, isObject := value.(map[string]interface{})
+	if !isObject {
+		return []string{fmt.Sprintf("Field '%s' is expected to be an object.", name)}
+	}
+	keyField, valueField := field.MapKey(), field.MapValue()
+	for key, entryValue := range entries {
+		if !isMapKeyValid(keyField.Kind(), key) {
+			errorMessages = append(errorMessages, fmt.Sprintf("Key '%s' of field '%s' is not a valid %s.", key, name, keyField.Kind()))
+			continue
+		}
+		if entryValue == nil {
+			continue
+		}
+		errorMessages = append(errorMessages, isValueJsonValid(valueField, entryValue, name+"."+key)...)
+	}
+	return errorMessages
+}
+
+func isMapKeyValid(kind protoreflect.Kind, key string) bool {
+	var err error
+	switch kind {
+	case protoreflect.BoolKind:
+		_, err = strconv.ParseBool(key)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		_, err = strconv.ParseInt(key, 10, 32)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		_, err = strconv.ParseInt(key, 10, 64)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		_, err = strconv.ParseUint(key, 10, 32)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		_, err = strconv.ParseUint(key, 10, 64)
+	}
+	return err == nil
+}
+
+func isMessageJsonValid(t protoreflect.MessageDescriptor, value interface{}, name string) []string {
+	if t.FullName() == anyFullName {
+		return isAnyJsonValid(value, name)
+	}
+	if isWellKnownType(t) {
+		if !isWellKnownTypeJsonValid(t, value) {
+			return []string{fmt.Sprintf("Field '%s' is not a valid %s in its JSON form.", name, t.FullName())}
+		}
+		return nil
+	}
+	object, isObject := value.(map[string]interface{})
+	if !isObject {
+		return []string{fmt.Sprintf("Field '%s' is expected to be an object.", name)}
+	}
+	_, errorMessages := isJsonValid(t, object, name)
+	return errorMessages
+}
+
 func (stub *Stub) IsValid() (isValid bool, errMsgs []string) {
 	if stub.FullMethod == "" {
 		errMsgs = append(errMsgs, "Method can't be empty.")
@@ -113,9 +405,9 @@ func (stub *Stub) IsValid() (isValid bool, errMsgs []string) {
 	isValid = isValid && requestValid
 	errMsgs = append(errMsgs, requestErrMsgs...)
 
-	// Response type can be either 'mock' or 'forward'
-	if stub.Type != "mock" && stub.Type != "forward" {
-		errMsgs = append(errMsgs, "Stub type must be either 'mock' or 'forward'")
+	// Response type can be either 'mock', 'forward' or 'passthrough'
+	if stub.Type != "mock" && stub.Type != "forward" && stub.Type != "passthrough" {
+		errMsgs = append(errMsgs, "Stub type must be either 'mock', 'forward' or 'passthrough'")
 	}
 
 	// Validate response
@@ -128,6 +420,21 @@ func (stub *Stub) IsValid() (isValid bool, errMsgs []string) {
 	isValid = isValid && forwardValid
 	errMsgs = append(errMsgs, forwardErrMsgs...)
 
+	_, sequenceErrMsgs := stub.isValidSequence()
+	errMsgs = append(errMsgs, sequenceErrMsgs...)
+
+	_, fieldMaskErrMsgs := stub.isValidFieldMask()
+	errMsgs = append(errMsgs, fieldMaskErrMsgs...)
+
+	_, customErrMsgs := stub.isValidCustom()
+	errMsgs = append(errMsgs, customErrMsgs...)
+
+	_, infoErrMsgs := stub.isValidInfo()
+	errMsgs = append(errMsgs, infoErrMsgs...)
+
+	_, binaryErrMsgs := stub.isValidBinaryContent()
+	errMsgs = append(errMsgs, binaryErrMsgs...)
+
 	return len(errMsgs) == 0, errMsgs
 }
 
@@ -135,11 +442,18 @@ func (stub *Stub) isValidRequest() (isValid bool, errMsgs []string) {
 	if stub.Request == nil {
 		errMsgs = append(errMsgs, "Request can't be empty.")
 	}
-	if stub.Request.Content == "" {
+	if stub.Request.Content == "" && stub.Request.ContentBinary == "" {
 		errMsgs = append(errMsgs, "Request content can't be empty.")
+	} else if content, err := parseJson(stub.Request.Content.String()); err != nil {
+		errMsgs = append(errMsgs, fmt.Sprintf("Request content is not valid JSON: %v.", err))
+	} else if isContentDepthExceeded(content, 0) {
+		errMsgs = append(errMsgs, fmt.Sprintf("Request content is nested deeper than the maximum depth of %d.", MaxContentDepth()))
+	}
+	if stub.Request.Match != "exact" && stub.Request.Match != "partial" && stub.Request.Match != "custom" {
+		errMsgs = append(errMsgs, "Request matching type can only be either 'exact', 'partial' or 'custom'.")
 	}
-	if stub.Request.Match != "exact" && stub.Request.Match != "partial" {
-		errMsgs = append(errMsgs, "Request matching type can only be either 'exact' or 'partial'.")
+	if stub.Request.EmptyArrays != "" && stub.Request.EmptyArrays != "absent" && stub.Request.EmptyArrays != "empty" {
+		errMsgs = append(errMsgs, "Request empty arrays can only be either 'absent' or 'empty'.")
 	}
 	return len(errMsgs) == 0, errMsgs
 }
@@ -157,34 +471,119 @@ func (stub *Stub) isValidResponse() (isValid bool, errMsgs []string) {
 		errMsgs = append(errMsgs, "Response can't be empty when stub's type is 'mock'.")
 		return false, errMsgs
 	}
-	if stub.Response.Type != "error" && stub.Response.Type != "success" {
-		errMsgs = append(errMsgs, "Response type can only be either 'error' or 'success'.")
+	switch stub.Response.Type {
+	case "error", "success", "custom", "remote":
+	default:
+		errMsgs = append(errMsgs, "Response type can only be either 'error', 'success', 'custom' or 'remote'.")
 	}
-	if stub.Response.Type == "success" && stub.Response.Content == "" {
+	if stub.Response.Type == "success" && stub.Response.Content == "" && stub.Response.ContentBinary == "" {
 		errMsgs = append(errMsgs, "Response content is mandatory when the response type is 'success'.")
 	}
+	if content, err := parseJson(stub.Response.Content.String()); err == nil && isContentDepthExceeded(content, 0) {
+		errMsgs = append(errMsgs, fmt.Sprintf("Response content is nested deeper than the maximum depth of %d.", MaxContentDepth()))
+	}
 	if stub.Response.Type == "error" && stub.Response.Error == nil {
 		errMsgs = append(errMsgs, "Response error is mandatory when the response type ir 'error'.")
 	}
+	if stub.Response.Delay != "" {
+		if _, err := time.ParseDuration(stub.Response.Delay); err != nil {
+			errMsgs = append(errMsgs, fmt.Sprintf("Response delay '%s' is not a valid duration.", stub.Response.Delay))
+		}
+	}
+	if stub.Response.Bandwidth != "" {
+		if _, err := ParseBandwidth(stub.Response.Bandwidth); err != nil {
+			errMsgs = append(errMsgs, fmt.Sprintf("Response bandwidth '%s' is not valid.", stub.Response.Bandwidth))
+		}
+	}
+	errMsgs = append(errMsgs, stub.Response.isValidTemplates()...)
+	errMsgs = append(errMsgs, stub.Response.isValidRemote()...)
+	if stub.Response.ErrorHeaders != "" {
+		if stub.Response.ErrorHeaders != "trailers-only" && stub.Response.ErrorHeaders != "headers-first" {
+			errMsgs = append(errMsgs, "Response error headers can only be either 'trailers-only' or 'headers-first'.")
+		} else if stub.Response.Type != "error" {
+			errMsgs = append(errMsgs, "Response error headers can only be set when the response type is 'error'.")
+		}
+	}
 	return len(errMsgs) == 0, errMsgs
 }
 
+// streamingForwardErrors reports the settings of the forward stubs that the streaming methods don't support: their
+// messages are relayed as they arrive, so they are neither transformed, except for the bandwidth, nor replayed. The
+// methods not registered in protoregistry.GlobalFiles are not checked.
+func (stub *Stub) streamingForwardErrors() (errMsgs []string) {
+	if !stub.IsForwarding() || stub.Forward == nil {
+		return nil
+	}
+	method := methodDescriptor(stub.FullMethod)
+	if method == nil || !method.IsStreamingClient() && !method.IsStreamingServer() {
+		return nil
+	}
+	if stub.Forward.Replay != "" {
+		errMsgs = append(errMsgs, "Forward replay is not supported by the streaming methods.")
+	}
+	if transform := stub.Forward.Transform; transform != nil && (transform.Content != "" || transform.Error != nil || transform.Delay != "") {
+		errMsgs = append(errMsgs, "Forward transform content, error and delay are not supported by the streaming methods, only bandwidth.")
+	}
+	return errMsgs
+}
+
 func (stub *Stub) isValidForward() (isValid bool, errMsgs []string) {
-	if stub.Type != "forward" {
+	if !stub.IsForwarding() {
 		return true, nil
 	}
 
 	if stub.Response != nil {
-		errMsgs = append(errMsgs, "Stub must not contain a response definition if it's type is 'forward'")
+		errMsgs = append(errMsgs, fmt.Sprintf("Stub must not contain a response definition if it's type is '%s'", stub.Type))
 	}
 
 	if stub.Forward == nil {
-		errMsgs = append(errMsgs, "Stub must not contain a forward definition if it's type is 'forward'")
+		errMsgs = append(errMsgs, fmt.Sprintf("Stub must contain a forward definition if it's type is '%s'", stub.Type))
 		return false, errMsgs
 	}
 
-	if stub.Forward.ServerAddress == "" {
+	if stub.Type == "passthrough" {
+		if stub.Forward.Replay != "" || stub.Forward.Transform != nil {
+			errMsgs = append(errMsgs, "Passthrough stubs can't replay or transform the response.")
+		}
+		if len(stub.Forward.RemoveMetadata) > 0 || len(stub.Forward.OverrideMetadata) > 0 || len(stub.Forward.AddMetadata) > 0 {
+			errMsgs = append(errMsgs, "Passthrough stubs can't change the metadata sent to the server.")
+		}
+	}
+
+	if len(stub.Forward.Addresses()) == 0 {
 		errMsgs = append(errMsgs, "You must provide a server address for forwarding stub types.")
 	}
+	if stub.Forward.Balancing != "" && stub.Forward.Balancing != "roundrobin" && stub.Forward.Balancing != "failover" {
+		errMsgs = append(errMsgs, "Forward balancing can only be either 'roundrobin' or 'failover'.")
+	}
+	if stub.Forward.Replay != "" && stub.Forward.Replay != "exact" && stub.Forward.Replay != "partial" {
+		errMsgs = append(errMsgs, "Forward replay can only be either 'exact' or 'partial'.")
+	}
+	if stub.Forward.Replay != "" && !stub.Forward.Record {
+		errMsgs = append(errMsgs, "Forward replay requires record to be enabled.")
+	}
+	if stub.Forward.TLS != nil && (stub.Forward.TLS.CertFile == "") != (stub.Forward.TLS.KeyFile == "") {
+		errMsgs = append(errMsgs, "Forward TLS certFile and keyFile must be provided together.")
+	}
+	if stub.Forward.Timeout != "" {
+		if _, err := time.ParseDuration(stub.Forward.Timeout); err != nil {
+			errMsgs = append(errMsgs, fmt.Sprintf("Forward timeout '%s' is not a valid duration.", stub.Forward.Timeout))
+		}
+	}
+	if stub.Forward.Retry != nil && stub.Forward.Retry.Backoff != "" {
+		if _, err := time.ParseDuration(stub.Forward.Retry.Backoff); err != nil {
+			errMsgs = append(errMsgs, fmt.Sprintf("Forward retry backoff '%s' is not a valid duration.", stub.Forward.Retry.Backoff))
+		}
+	}
+	if stub.Forward.Transform != nil && stub.Forward.Transform.Delay != "" {
+		if _, err := time.ParseDuration(stub.Forward.Transform.Delay); err != nil {
+			errMsgs = append(errMsgs, fmt.Sprintf("Forward transform delay '%s' is not a valid duration.", stub.Forward.Transform.Delay))
+		}
+	}
+	if stub.Forward.Transform != nil && stub.Forward.Transform.Bandwidth != "" {
+		if _, err := ParseBandwidth(stub.Forward.Transform.Bandwidth); err != nil {
+			errMsgs = append(errMsgs, fmt.Sprintf("Forward transform bandwidth '%s' is not valid.", stub.Forward.Transform.Bandwidth))
+		}
+	}
 	return len(errMsgs) == 0, errMsgs
 }
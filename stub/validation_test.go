@@ -0,0 +1,346 @@
+package stub
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	_ "google.golang.org/protobuf/types/known/anypb"
+	_ "google.golang.org/protobuf/types/known/durationpb"
+	_ "google.golang.org/protobuf/types/known/sourcecontextpb"
+	_ "google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+	_ "google.golang.org/protobuf/types/known/typepb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"strings"
+	"testing"
+)
+
+// newTestMessageDescriptor creates the descriptor of a proto3 message with the fields provided.
+func newTestMessageDescriptor(t *testing.T, fields ...*descriptorpb.FieldDescriptorProto) protoreflect.MessageDescriptor {
+	message := &descriptorpb.DescriptorProto{Name: proto.String("Message"), Field: fields}
+	for _, field := range fields {
+		if field.GetProto3Optional() {
+			field.OneofIndex = proto.Int32(int32(len(message.OneofDecl)))
+			message.OneofDecl = append(message.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String("_" + field.GetName())})
+		}
+	}
+	dependencies := make([]string, 0)
+	for _, field := range fields {
+		if field.GetTypeName() == "" {
+			continue
+		}
+		descriptor, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(strings.TrimPrefix(field.GetTypeName(), ".")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dependencies = append(dependencies, descriptor.ParentFile().Path())
+	}
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String("test.proto"),
+		Package:     proto.String("pkg"),
+		Syntax:      proto.String("proto3"),
+		Dependency:  dependencies,
+		MessageType: []*descriptorpb.DescriptorProto{message},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file.Messages().Get(0)
+}
+
+func newTestField(name string, number int32, fieldType descriptorpb.FieldDescriptorProto_Type, optional bool) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:           proto.String(name),
+		JsonName:       proto.String(name),
+		Number:         proto.Int32(number),
+		Label:          descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:           fieldType.Enum(),
+		Proto3Optional: proto.Bool(optional),
+	}
+}
+
+// newTestMessageField creates a field of a message type registered in the global registry, e.g. .google.protobuf.Duration.
+func newTestMessageField(name string, number int32, typeName string) *descriptorpb.FieldDescriptorProto {
+	field := newTestField(name, number, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, false)
+	field.TypeName = proto.String(typeName)
+	return field
+}
+
+func TestIsStubValid_DefaultValuesInPartialMatch(t *testing.T) {
+	descriptor := newTestMessageDescriptor(t,
+		newTestField("count", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, false),
+		newTestField("limit", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, true),
+		newTestField("name", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING, false),
+	)
+	s := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &StubRequest{Match: "partial", Content: `{"limit":0,"name":"John"}`},
+		Response:   &StubResponse{Type: "success", Content: `{}`},
+	}
+	isValid, errMsgs := IsStubValid(s, descriptor, descriptor)
+	assert.True(t, isValid)
+	assert.Empty(t, errMsgs)
+
+	s.Request.Content = `{"count":0,"name":""}`
+	isValid, errMsgs = IsStubValid(s, descriptor, descriptor)
+	assert.False(t, isValid)
+	assert.Equal(t, 2, len(errMsgs))
+
+	s.Request.Match = "exact"
+	isValid, _ = IsStubValid(s, descriptor, descriptor)
+	assert.True(t, isValid)
+}
+
+func TestCreateStubExample_OptionalFieldsAreNotOneOfs(t *testing.T) {
+	descriptor := newTestMessageDescriptor(t,
+		newTestField("count", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, false),
+		newTestField("limit", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, true),
+	)
+	example := JsonString(generateJSONForType(descriptor, &bytes.Buffer{}, make(map[string]bool)).String())
+	assert.True(t, example.Equals(`{"count":0,"limit":0}`), example.String())
+}
+
+func TestIsStubValid_WellKnownTypesInCanonicalForm(t *testing.T) {
+	descriptor := newTestMessageDescriptor(t,
+		newTestMessageField("timeout", 1, ".google.protobuf.Duration"),
+		newTestMessageField("createdAt", 2, ".google.protobuf.Timestamp"),
+		newTestMessageField("attributes", 3, ".google.protobuf.Struct"),
+		newTestMessageField("total", 4, ".google.protobuf.Int64Value"),
+	)
+	s := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &StubRequest{Match: "exact", Content: `{"timeout":"3.5s","createdAt":"2020-01-01T10:00:00Z","attributes":{"any":["value",1]},"total":"10"}`},
+		Response:   &StubResponse{Type: "success", Content: `{"timeout":"1s"}`},
+	}
+	isValid, errMsgs := IsStubValid(s, descriptor, descriptor)
+	assert.True(t, isValid)
+	assert.Empty(t, errMsgs)
+
+	s.Request.Content = `{"timeout":{"seconds":3},"createdAt":"yesterday"}`
+	isValid, errMsgs = IsStubValid(s, descriptor, descriptor)
+	assert.False(t, isValid)
+	assert.Contains(t, errMsgs, "Field 'request.content.timeout' is not a valid google.protobuf.Duration in its JSON form.")
+	assert.Contains(t, errMsgs, "Field 'request.content.createdAt' is not a valid google.protobuf.Timestamp in its JSON form.")
+}
+
+func TestCreateStubExample_WellKnownTypesInCanonicalForm(t *testing.T) {
+	descriptor := newTestMessageDescriptor(t,
+		newTestMessageField("timeout", 1, ".google.protobuf.Duration"),
+		newTestMessageField("createdAt", 2, ".google.protobuf.Timestamp"),
+	)
+	example := JsonString(generateJSONForType(descriptor, &bytes.Buffer{}, make(map[string]bool)).String())
+	assert.True(t, example.Equals(`{"timeout":"0s","createdAt":"1970-01-01T00:00:00Z"}`), example.String())
+}
+
+func TestIsStubValid_AnyWithTypeAndUnpackedContent(t *testing.T) {
+	descriptor := newTestMessageDescriptor(t, newTestMessageField("detail", 1, ".google.protobuf.Any"))
+	s := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &StubRequest{Match: "exact", Content: `{}`},
+		Response:   &StubResponse{Type: "success", Content: `{"detail":{"@type":"type.googleapis.com/google.protobuf.SourceContext","fileName":"greeter.proto"}}`},
+	}
+	isValid, errMsgs := IsStubValid(s, descriptor, descriptor)
+	assert.True(t, isValid)
+	assert.Empty(t, errMsgs)
+
+	s.Response.Content = `{"detail":{"@type":"type.googleapis.com/google.protobuf.Duration","value":"1s"}}`
+	isValid, _ = IsStubValid(s, descriptor, descriptor)
+	assert.True(t, isValid)
+
+	s.Response.Content = `{"detail":{"@type":"type.googleapis.com/google.protobuf.SourceContext","name":"greeter.proto"}}`
+	isValid, errMsgs = IsStubValid(s, descriptor, descriptor)
+	assert.False(t, isValid)
+	assert.Contains(t, errMsgs, "Field 'response.content.detail.name' does not exist")
+
+	s.Response.Content = `{"detail":{"@type":"type.googleapis.com/pkg.Unknown"}}`
+	isValid, errMsgs = IsStubValid(s, descriptor, descriptor)
+	assert.False(t, isValid)
+	assert.Contains(t, errMsgs, "Type 'type.googleapis.com/pkg.Unknown' of field 'response.content.detail' is unknown.")
+}
+
+func TestIsStubValid_MapFields(t *testing.T) {
+	descriptor := new(errdetails.ErrorInfo).ProtoReflect().Descriptor()
+	s := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &StubRequest{Match: "partial", Content: `{"metadata":{"tenant":"a"}}`},
+		Response:   &StubResponse{Type: "success", Content: `{"reason":"QUOTA","metadata":{"tenant":"a","region":"eu"}}`},
+	}
+	isValid, errMsgs := IsStubValid(s, descriptor, descriptor)
+	assert.True(t, isValid)
+	assert.Empty(t, errMsgs)
+
+	s.Request.Content = `{"metadata":[{"key":"tenant","value":"a"}]}`
+	s.Response.Content = `{"metadata":{"tenant":1}}`
+	isValid, errMsgs = IsStubValid(s, descriptor, descriptor)
+	assert.False(t, isValid)
+	assert.Contains(t, errMsgs, "Field 'request.content.metadata' is expected to be an object.")
+	assert.Contains(t, errMsgs, "Field 'response.content.metadata.tenant' is expected to be a string.")
+}
+
+func TestIsStubValid_FieldTypes(t *testing.T) {
+	status := newTestField("status", 7, descriptorpb.FieldDescriptorProto_TYPE_ENUM, false)
+	status.TypeName = proto.String(".google.protobuf.Syntax")
+	ids := newTestField("ids", 8, descriptorpb.FieldDescriptorProto_TYPE_INT64, false)
+	ids.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	userId := newTestField("user_id", 9, descriptorpb.FieldDescriptorProto_TYPE_STRING, false)
+	userId.JsonName = proto.String("userId")
+	descriptor := newTestMessageDescriptor(t,
+		newTestField("count", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, false),
+		newTestField("total", 2, descriptorpb.FieldDescriptorProto_TYPE_UINT64, false),
+		newTestField("ratio", 3, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, false),
+		newTestField("active", 4, descriptorpb.FieldDescriptorProto_TYPE_BOOL, false),
+		newTestField("data", 5, descriptorpb.FieldDescriptorProto_TYPE_BYTES, false),
+		newTestField("name", 6, descriptorpb.FieldDescriptorProto_TYPE_STRING, false),
+		status, ids, userId,
+	)
+	s := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &StubRequest{Match: "exact", Content: `{"count":1,"total":"18446744073709551615","ratio":"NaN","active":true,"data":"aGVsbG8=","status":"SYNTAX_PROTO3","ids":[1,"2"],"user_id":"a"}`},
+		Response:   &StubResponse{Type: "success", Content: `{"ratio":0.5,"status":1,"userId":"a"}`},
+	}
+	isValid, errMsgs := IsStubValid(s, descriptor, descriptor)
+	assert.True(t, isValid)
+	assert.Empty(t, errMsgs)
+
+	s.Request.Content = `{"count":1.5,"total":-1,"ratio":"half","active":"yes","data":"not base64!","status":"PROTO3","ids":1,"nmae":"John","userid":"a"}`
+	isValid, errMsgs = IsStubValid(s, descriptor, descriptor)
+	assert.False(t, isValid)
+	assert.ElementsMatch(t, []string{
+		"Field 'request.content.count' is expected to be a 32 bit integer.",
+		"Field 'request.content.total' is expected to be a 64 bit unsigned integer.",
+		"Field 'request.content.ratio' is expected to be a number.",
+		"Field 'request.content.active' is expected to be a boolean.",
+		"Field 'request.content.data' is expected to be a base64 encoded string.",
+		"Value 'PROTO3' is not valid for field 'request.content.status'. Possible values are 'SYNTAX_PROTO2', 'SYNTAX_PROTO3', 'SYNTAX_EDITIONS'.",
+		"Field 'request.content.ids' is expected to be an array.",
+		"Field 'request.content.nmae' does not exist. Did you mean 'name'?",
+		"Field 'request.content.userid' does not exist. Did you mean 'userId'?",
+	}, errMsgs)
+
+	s.Request.Content = `{"ids":[1,"x"]}`
+	_, errMsgs = IsStubValid(s, descriptor, descriptor)
+	assert.Equal(t, []string{"Field 'request.content.ids[1]' is expected to be a 64 bit integer."}, errMsgs)
+}
+
+func TestIsStubValid_TemplatesInTypedFields(t *testing.T) {
+	descriptor := newTestMessageDescriptor(t,
+		newTestField("count", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64, false),
+		newTestField("active", 2, descriptorpb.FieldDescriptorProto_TYPE_BOOL, false),
+	)
+	s := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &StubRequest{Match: "exact", Content: `{"count":1}`},
+		Response:   &StubResponse{Type: "success", Content: "{\"count\":\"{{counter `x`}}\",\"active\":\"{{scenario `s`}}\"}"},
+	}
+	isValid, errMsgs := IsStubValid(s, descriptor, descriptor)
+	assert.True(t, isValid)
+	assert.Empty(t, errMsgs)
+
+	s.Response.Content = `{"count":"x"}`
+	_, errMsgs = IsStubValid(s, descriptor, descriptor)
+	assert.Equal(t, []string{"Field 'response.content.count' is expected to be a 64 bit integer."}, errMsgs)
+}
+
+func TestIsStubValid_StreamingForward(t *testing.T) {
+	request := new(grpc_health_v1.HealthCheckRequest).ProtoReflect().Descriptor()
+	response := new(grpc_health_v1.HealthCheckResponse).ProtoReflect().Descriptor()
+	s := &Stub{
+		FullMethod: "/grpc.health.v1.Health/Watch",
+		Type:       "forward",
+		Request:    &StubRequest{Match: "partial", Content: `{}`},
+		Forward: &StubForward{ServerAddress: "localhost:50051", Record: true, Replay: "exact",
+			Transform: &StubForwardTransform{Content: `{"status":"SERVING"}`, Bandwidth: "1KB/s"}},
+	}
+	isValid, errMsgs := IsStubValid(s, request, response)
+	assert.False(t, isValid)
+	assert.Equal(t, []string{
+		"Forward replay is not supported by the streaming methods.",
+		"Forward transform content, error and delay are not supported by the streaming methods, only bandwidth.",
+	}, errMsgs)
+
+	s.Forward.Replay, s.Forward.Transform.Content = "", ""
+	isValid, errMsgs = IsStubValid(s, request, response)
+	assert.True(t, isValid)
+	assert.Empty(t, errMsgs)
+
+	// the unary methods support them
+	s.FullMethod, s.Forward.Replay, s.Forward.Transform.Delay = "/grpc.health.v1.Health/Check", "exact", "1s"
+	isValid, errMsgs = IsStubValid(s, request, response)
+	assert.True(t, isValid)
+	assert.Empty(t, errMsgs)
+}
+
+func TestIsStubValid_WrapperContent(t *testing.T) {
+	descriptor := new(wrapperspb.StringValue).ProtoReflect().Descriptor()
+	s := &Stub{
+		FullMethod: "/pkg.Service/Method",
+		Type:       "mock",
+		Request:    &StubRequest{Match: "exact", Content: `"John"`},
+		Response:   &StubResponse{Type: "success", Content: `"Hello John"`},
+	}
+	isValid, errMsgs := IsStubValid(s, descriptor, descriptor)
+	assert.True(t, isValid)
+	assert.Empty(t, errMsgs)
+
+	s.Request.Content = `{"name":"John"}`
+	_, errMsgs = IsStubValid(s, descriptor, descriptor)
+	assert.Equal(t, []string{"Field 'request.content' is not a valid google.protobuf.StringValue in its JSON form."}, errMsgs)
+}
+
+func TestCreateStubExample_MapFields(t *testing.T) {
+	example := JsonString(CreateStubExample(new(errdetails.ErrorInfo)))
+	assert.True(t, example.Equals(`{"reason":"","domain":"","metadata":{"key":""}}`), example.String())
+}
+
+func TestIsStubValid_Editions(t *testing.T) {
+	limit := newTestField("limit", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, false)
+	limit.Options = &descriptorpb.FieldOptions{Features: &descriptorpb.FeatureSet{FieldPresence: descriptorpb.FeatureSet_IMPLICIT.Enum()}}
+	child := newTestField("child", 3, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, false)
+	child.TypeName = proto.String(".pkg.editions.Message")
+	child.Options = &descriptorpb.FieldOptions{Features: &descriptorpb.FeatureSet{MessageEncoding: descriptorpb.FeatureSet_DELIMITED.Enum()}}
+	fields := []*descriptorpb.FieldDescriptorProto{newTestField("count", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, false), limit, child}
+	for _, field := range fields {
+		field.Proto3Optional = nil
+	}
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String("editions.proto"),
+		Package:     proto.String("pkg.editions"),
+		Syntax:      proto.String("editions"),
+		Edition:     descriptorpb.Edition_EDITION_2023.Enum(),
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Message"), Field: fields}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	descriptor := file.Messages().Get(0)
+	s := &Stub{
+		FullMethod: "/pkg.editions.Service/Method",
+		Type:       "mock",
+		Request:    &StubRequest{Match: "partial", Content: `{"count":0,"child":{"limit":1}}`},
+		Response:   &StubResponse{Type: "success", Content: `{"child":{"child":{"count":1}}}`},
+	}
+	isValid, errMsgs := IsStubValid(s, descriptor, descriptor)
+	assert.True(t, isValid)
+	assert.Empty(t, errMsgs)
+
+	s.Request.Content = `{"limit":0,"child":{"name":"John"}}`
+	isValid, errMsgs = IsStubValid(s, descriptor, descriptor)
+	assert.False(t, isValid)
+	assert.Contains(t, errMsgs, "Field 'request.content.child.name' does not exist")
+	assert.Equal(t, 2, len(errMsgs))
+
+	example := JsonString(generateJSONForType(descriptor, &bytes.Buffer{}, make(map[string]bool)).String())
+	assert.True(t, example.Equals(`{"count":0,"limit":0,"child":{}}`), example.String())
+}
@@ -0,0 +1,75 @@
+package stub
+
+import (
+	"encoding/json"
+	"fmt"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const anyFullName = protoreflect.FullName("google.protobuf.Any")
+
+// Examples of the canonical JSON form of the well-known types, which protojson uses instead of the JSON object of their fields.
+var wellKnownTypeExamples = map[protoreflect.FullName]string{
+	"google.protobuf.Timestamp":   `"1970-01-01T00:00:00Z"`,
+	"google.protobuf.Duration":    `"0s"`,
+	"google.protobuf.FieldMask":   `"field1,field2.subfield"`,
+	"google.protobuf.Struct":      `{}`,
+	"google.protobuf.Value":       `null`,
+	"google.protobuf.ListValue":   `[]`,
+	"google.protobuf.Empty":       `{}`,
+	"google.protobuf.Any":         `{"@type": "type.googleapis.com/google.protobuf.Empty"}`,
+	"google.protobuf.DoubleValue": `0`,
+	"google.protobuf.FloatValue":  `0`,
+	"google.protobuf.Int64Value":  `"0"`,
+	"google.protobuf.UInt64Value": `"0"`,
+	"google.protobuf.Int32Value":  `0`,
+	"google.protobuf.UInt32Value": `0`,
+	"google.protobuf.BoolValue":   `true`,
+	"google.protobuf.StringValue": `""`,
+	"google.protobuf.BytesValue":  `""`,
+}
+
+// isWellKnownType returns true for the messages with a canonical JSON form different from the JSON object of their fields.
+func isWellKnownType(t protoreflect.MessageDescriptor) bool {
+	_, ok := wellKnownTypeExamples[t.FullName()]
+	return ok
+}
+
+// isWellKnownTypeJsonValid checks that the value is in the canonical JSON form of the well-known type, e.g. "3.5s" for a Duration.
+func isWellKnownTypeJsonValid(t protoreflect.MessageDescriptor, value interface{}) bool {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	return protojson.Unmarshal(data, dynamicpb.NewMessage(t)) == nil
+}
+
+// isAnyJsonValid checks an Any written as the JSON of the message packed in it, with the type URL in @type,
+// e.g. {"@type": "type.googleapis.com/pkg.Message", "name": "John"}. Well-known types are packed in value.
+// The type is resolved from the messages registered by the generated code.
+func isAnyJsonValid(value interface{}, name string) []string {
+	object, isObject := value.(map[string]interface{})
+	typeURL, _ := object["@type"].(string)
+	if !isObject || typeURL == "" {
+		return []string{fmt.Sprintf("Field '%s' is expected to be an object with the type URL of the message in '@type'.", name)}
+	}
+	messageType, err := protoregistry.GlobalTypes.FindMessageByURL(typeURL)
+	if err != nil {
+		return []string{fmt.Sprintf("Type '%s' of field '%s' is unknown.", typeURL, name)}
+	}
+	t := messageType.Descriptor()
+	if isWellKnownType(t) {
+		return isMessageJsonValid(t, object["value"], name+".value")
+	}
+	content := make(map[string]interface{}, len(object))
+	for key, fieldValue := range object {
+		if key != "@type" {
+			content[key] = fieldValue
+		}
+	}
+	_, errorMessages := isJsonValid(t, content, name)
+	return errorMessages
+}
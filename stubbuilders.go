@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/compiler/protogen"
+	"path"
+	"strings"
+)
+
+const timePackage = protogen.GoImportPath("time")
+
+// GenerateStubBuilderFiles generates, for each service, a package with typed builders of the stubs of its methods,
+// e.g. greetermock.Hello().WithRequest(&greeter.Request{...}).RespondWith(&greeter.Response{...}). The services with only
+// streaming methods have no builders.
+func GenerateStubBuilderFiles(gen *protogen.Plugin, file *protogen.File, filter generationFilter) {
+	m := mockServicesGenerator{
+		gen:    gen,
+		file:   file,
+		filter: filter,
+	}
+	for _, service := range m.services() {
+		methods := make([]*protogen.Method, 0)
+		for _, method := range m.methods(service) {
+			if method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer() {
+				continue // streaming calls can only be forwarded
+			}
+			methods = append(methods, method)
+		}
+		if len(methods) == 0 {
+			continue
+		}
+		packageName := getStubBuilderPackageName(service)
+		filename := path.Join(path.Dir(file.GeneratedFilenamePrefix), packageName, path.Base(file.GeneratedFilenamePrefix)+".stubs.pb.go")
+		importPath := protogen.GoImportPath(path.Join(string(file.GoImportPath), packageName))
+		m.g = gen.NewGeneratedFile(filename, importPath)
+		m.genGeneratedComment()
+		m.g.P()
+		m.g.P("// Package ", packageName, " provides typed builders of the stubs for the ", service.Desc.FullName(), " service.")
+		m.g.P("package ", packageName)
+		m.g.P()
+		for _, method := range methods {
+			m.genStubBuilder(service, method)
+		}
+	}
+}
+
+func (m mockServicesGenerator) genStubBuilder(service *protogen.Service, method *protogen.Method) {
+	builderName := method.GoName + "Stub"
+	fullMethod := fmt.Sprintf("/%s/%s", service.Desc.FullName(), method.GoName)
+	m.g.P("// ", builderName, " builds stubs for ", fullMethod, ".")
+	m.g.P("type ", builderName, " struct {")
+	m.g.P("builder *", stubPackage.Ident("StubBuilder"))
+	m.g.P("}")
+	m.g.P()
+	m.g.P("// ", method.GoName, " starts a stub for ", fullMethod, " matching any request.")
+	m.g.P("func ", method.GoName, "() *", builderName, " {")
+	m.g.P("return &", builderName, "{builder: ", stubPackage.Ident("NewStubBuilder"), "(", m.getFullMethodName(service, method), ")}")
+	m.g.P("}")
+	m.g.P()
+	m.g.P("// WithRequest only matches requests equal to the one provided.")
+	m.g.P("func (s *", builderName, ") WithRequest(request *", method.Input.GoIdent, ") *", builderName, " {")
+	m.g.P("s.builder.WithRequest(request)")
+	m.g.P("return s")
+	m.g.P("}")
+	m.g.P()
+	m.g.P("// WithPartialRequest matches the requests containing the fields set in the one provided.")
+	m.g.P("func (s *", builderName, ") WithPartialRequest(request *", method.Input.GoIdent, ") *", builderName, " {")
+	m.g.P("s.builder.WithPartialRequest(request)")
+	m.g.P("return s")
+	m.g.P("}")
+	m.g.P()
+	m.g.P("// WithMetadata only matches requests with the metadata provided.")
+	m.g.P("func (s *", builderName, ") WithMetadata(key string, values ...string) *", builderName, " {")
+	m.g.P("s.builder.WithMetadata(key, values...)")
+	m.g.P("return s")
+	m.g.P("}")
+	m.g.P()
+	m.g.P("func (s *", builderName, ") RespondWith(response *", method.Output.GoIdent, ") *", builderName, " {")
+	m.g.P("s.builder.RespondWith(response)")
+	m.g.P("return s")
+	m.g.P("}")
+	m.g.P()
+	m.g.P("func (s *", builderName, ") RespondWithError(code ", codesPackage.Ident("Code"), ", message string) *", builderName, " {")
+	m.g.P("s.builder.RespondWithError(code, message)")
+	m.g.P("return s")
+	m.g.P("}")
+	m.g.P()
+	m.g.P("// WithDelay waits before responding. The client deadline is respected.")
+	m.g.P("func (s *", builderName, ") WithDelay(delay ", timePackage.Ident("Duration"), ") *", builderName, " {")
+	m.g.P("s.builder.WithDelay(delay)")
+	m.g.P("return s")
+	m.g.P("}")
+	m.g.P()
+	m.g.P("func (s *", builderName, ") Build() (*", stubPackage.Ident("Stub"), ", error) {")
+	m.g.P("return s.builder.Build()")
+	m.g.P("}")
+	m.g.P()
+	m.g.P("// JSON returns the stub in the format accepted by the REST API.")
+	m.g.P("func (s *", builderName, ") JSON() ([]byte, error) {")
+	m.g.P("return s.builder.JSON()")
+	m.g.P("}")
+	m.g.P()
+}
+
+func getStubBuilderPackageName(service *protogen.Service) string {
+	return strings.ToLower(service.GoName) + "mock"
+}
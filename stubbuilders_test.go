@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"testing"
+)
+
+func newTestPlugin(t *testing.T) *protogen.Plugin {
+	method := func(name string, streaming bool) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:            proto.String(name),
+			InputType:       proto.String(".greeter.Request"),
+			OutputType:      proto.String(".greeter.Response"),
+			ServerStreaming: proto.Bool(streaming),
+		}
+	}
+	message := func(name string) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{Name: proto.String(name)}
+	}
+	gen, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"greeter.proto"},
+		ProtoFile: []*descriptorpb.FileDescriptorProto{{
+			Name:        proto.String("greeter.proto"),
+			Package:     proto.String("greeter"),
+			Syntax:      proto.String("proto3"),
+			Options:     &descriptorpb.FileOptions{GoPackage: proto.String("example.com/greeter")},
+			MessageType: []*descriptorpb.DescriptorProto{message("Request"), message("Response")},
+			Service: []*descriptorpb.ServiceDescriptorProto{
+				{Name: proto.String("Greeter"), Method: []*descriptorpb.MethodDescriptorProto{method("Hello", false), method("HelloStream", true)}},
+				{Name: proto.String("Streamer"), Method: []*descriptorpb.MethodDescriptorProto{method("Stream", true)}},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gen
+}
+
+func TestGenerateStubBuilderFiles_SkipsStreamingOnlyServices(t *testing.T) {
+	gen := newTestPlugin(t)
+	GenerateStubBuilderFiles(gen, gen.Files[0], generationFilter{services: namesFlag{}, excludedMethods: namesFlag{}})
+
+	files := gen.Response().GetFile()
+	assert.Equal(t, 1, len(files))
+	assert.Equal(t, "example.com/greeter/greetermock/greeter.stubs.pb.go", files[0].GetName())
+	assert.Contains(t, files[0].GetContent(), "func Hello() *HelloStub")
+	assert.NotContains(t, files[0].GetContent(), "HelloStream")
+}
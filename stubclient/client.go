@@ -0,0 +1,263 @@
+// Package stubclient is a client of the REST API of the mock server, to manage the stubs from the tests:
+//
+//	client := stubclient.New("http://localhost:1068")
+//	s, _ := greetermock.Hello().WithRequest(req).RespondWith(resp).Build()
+//	if err := client.CreateStub(ctx, s); err != nil {
+//		t.Fatal(err)
+//	}
+//	defer client.Reset(ctx)
+//
+// The calls are retried when the mock server can't be reached or is unavailable, e.g. while it starts.
+package stubclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetries      = 3
+	defaultRetryBackoff = 100 * time.Millisecond
+)
+
+// Client calls the REST API of a mock server. Use New to create it.
+type Client struct {
+	baseURL      string
+	httpClient   *http.Client
+	retries      int
+	retryBackoff time.Duration
+}
+
+// Option configures the Client.
+type Option func(*Client)
+
+// WithHTTPClient sends the requests with the HTTP client, e.g. to set a timeout or a transport.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries sets how many times a call is retried and how long to wait before the first retry. The wait doubles on each
+// retry. The calls are not retried when retries is zero.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.retryBackoff = backoff
+	}
+}
+
+// New creates the client of the REST API at baseURL, e.g. http://localhost:1068, or http://localhost:1068/sets/orders
+// for a service set.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		httpClient:   http.DefaultClient,
+		retries:      defaultRetries,
+		retryBackoff: defaultRetryBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error is returned when the mock server rejects a call.
+type Error struct {
+	StatusCode int
+	Message    string
+	// Validation errors of the stub, when it is not valid
+	Errors []string
+}
+
+func (e *Error) Error() string {
+	if len(e.Errors) > 0 {
+		return fmt.Sprintf("status %d: %s", e.StatusCode, strings.Join(e.Errors, " "))
+	}
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Message)
+}
+
+// CreateStub adds the stub. It fails if a stub for the same request already exists.
+func (c *Client) CreateStub(ctx context.Context, s *stub.Stub) error {
+	return c.call(ctx, http.MethodPost, "/stubs", s, nil)
+}
+
+// UpdateStub replaces the stub for the same request.
+func (c *Client) UpdateStub(ctx context.Context, s *stub.Stub) error {
+	return c.call(ctx, http.MethodPut, "/stubs", s, nil)
+}
+
+// ListStubs returns the stubs of the method, or all the stubs when fullMethod is empty.
+func (c *Client) ListStubs(ctx context.Context, fullMethod string) ([]*stub.Stub, error) {
+	path := "/stubs"
+	if fullMethod != "" {
+		path += "?method=" + url.QueryEscape(fullMethod)
+	}
+	stubs := make([]*stub.Stub, 0)
+	return stubs, c.call(ctx, http.MethodGet, path, nil, &stubs)
+}
+
+// DeleteStub deletes the stub for the same method and request.
+func (c *Client) DeleteStub(ctx context.Context, s *stub.Stub) error {
+	return c.call(ctx, http.MethodDelete, "/stubs", s, nil)
+}
+
+// Verify checks how many times the stub for the request was matched. The result tells whether it matches the verification.
+func (c *Client) Verify(ctx context.Context, verification stub.StubVerification) (stub.StubVerificationResult, error) {
+	var result stub.StubVerificationResult
+	return result, c.call(ctx, http.MethodPost, "/verifications", verification, &result)
+}
+
+// StubsUsage returns how many times the stubs of the method, or all the stubs when fullMethod is empty, were matched.
+func (c *Client) StubsUsage(ctx context.Context, fullMethod string) ([]stub.StubUsage, error) {
+	path := "/verifications"
+	if fullMethod != "" {
+		path += "?method=" + url.QueryEscape(fullMethod)
+	}
+	usage := make([]stub.StubUsage, 0)
+	return usage, c.call(ctx, http.MethodGet, path, nil, &usage)
+}
+
+// UnmatchedStubs returns the stubs that were never matched.
+func (c *Client) UnmatchedStubs(ctx context.Context) ([]*stub.Stub, error) {
+	stubs := make([]*stub.Stub, 0)
+	return stubs, c.call(ctx, http.MethodGet, "/verifications/unmatched", nil, &stubs)
+}
+
+// Sequences returns the status of the sequences of the stubs, or of the one named when name is not empty.
+func (c *Client) Sequences(ctx context.Context, name string) ([]stub.SequenceStatus, error) {
+	path := "/verifications/sequences"
+	if name != "" {
+		path += "?name=" + url.QueryEscape(name)
+	}
+	sequences := make([]stub.SequenceStatus, 0)
+	return sequences, c.call(ctx, http.MethodGet, path, nil, &sequences)
+}
+
+// Reset deletes all the stubs and the counts of the verifications, so that the mock server can be reused by the next test.
+func (c *Client) Reset(ctx context.Context) error {
+	if err := c.call(ctx, http.MethodDelete, "/stubs", nil, nil); err != nil {
+		return err
+	}
+	return c.call(ctx, http.MethodDelete, "/verifications", nil, nil)
+}
+
+// ExportRecordings returns the calls recorded by the mock server as stubs, e.g. to save them in stub files.
+func (c *Client) ExportRecordings(ctx context.Context) ([]*stub.Stub, error) {
+	stubs := make([]*stub.Stub, 0)
+	return stubs, c.call(ctx, http.MethodGet, "/recordings", nil, &stubs)
+}
+
+// RecordingsPage returns up to limit recordings after the first offset ones, all of them when limit is zero, in the order
+// they were recorded, e.g. to get the new recordings without exporting all of them again.
+func (c *Client) RecordingsPage(ctx context.Context, offset, limit int) (stub.RecordingsPage, error) {
+	page := stub.RecordingsPage{}
+	path := fmt.Sprintf("/recordings/page?offset=%d&limit=%d", offset, limit)
+	return page, c.call(ctx, http.MethodGet, path, nil, &page)
+}
+
+// Chaos returns the configuration of the errors and delays injected in the calls.
+func (c *Client) Chaos(ctx context.Context) (grpchandler.ChaosConfig, error) {
+	var config grpchandler.ChaosConfig
+	return config, c.call(ctx, http.MethodGet, "/admin/chaos", nil, &config)
+}
+
+// SetChaos replaces the configuration of the errors and delays injected in the calls and returns the one applied.
+func (c *Client) SetChaos(ctx context.Context, config grpchandler.ChaosConfig) (grpchandler.ChaosConfig, error) {
+	var applied grpchandler.ChaosConfig
+	return applied, c.call(ctx, http.MethodPut, "/admin/chaos", config, &applied)
+}
+
+// DisableChaos stops injecting errors and delays in the calls.
+func (c *Client) DisableChaos(ctx context.Context) error {
+	return c.call(ctx, http.MethodDelete, "/admin/chaos", nil, nil)
+}
+
+// Scenarios returns the states of the scenarios of the templates of the stubs, by name. The scenarios that were never set
+// are not returned.
+func (c *Client) Scenarios(ctx context.Context) (map[string]string, error) {
+	scenarios := make(map[string]string)
+	return scenarios, c.call(ctx, http.MethodGet, "/scenarios", nil, &scenarios)
+}
+
+// SetScenario changes the state of the scenario of the templates of the stubs, e.g. to start a test from a step of a flow.
+func (c *Client) SetScenario(ctx context.Context, name, state string) error {
+	return c.call(ctx, http.MethodPut, "/scenarios/"+url.PathEscape(name), map[string]string{"state": state}, nil)
+}
+
+// call sends the request with the body in JSON, retrying it while the server can't be reached or is unavailable, and
+// decodes the response into result when it is not nil.
+func (c *Client) call(ctx context.Context, method, path string, body interface{}, result interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := c.send(ctx, method, path, data, result)
+		if !retry || attempt >= c.retries {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// send sends the request once and returns whether it can be retried when it fails.
+func (c *Client) send(ctx context.Context, method, path string, data []byte, result interface{}) (bool, error) {
+	request, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer response.Body.Close()
+	responseData, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return true, fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return retryable(response.StatusCode), newError(response.StatusCode, responseData)
+	}
+	if result == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal(responseData, result); err != nil {
+		return false, fmt.Errorf("invalid response of %s %s: %w", method, path, err)
+	}
+	return false, nil
+}
+
+func retryable(statusCode int) bool {
+	return statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable || statusCode == http.StatusGatewayTimeout
+}
+
+func newError(statusCode int, data []byte) *Error {
+	e := &Error{StatusCode: statusCode, Message: strings.TrimSpace(string(data))}
+	invalidStub := new(stub.InvalidStubResponse)
+	if err := json.Unmarshal(data, invalidStub); err == nil {
+		e.Errors = invalidStub.Errors
+	}
+	return e
+}
@@ -0,0 +1,199 @@
+package stubclient
+
+import (
+	"context"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type recordedRequest struct {
+	method string
+	uri    string
+	body   string
+}
+
+// newTestServer returns a server recording the requests and responding with the responses, in order.
+func newTestServer(t *testing.T, responses ...func(writer http.ResponseWriter)) (*httptest.Server, *[]recordedRequest) {
+	requests := make([]recordedRequest, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+		requests = append(requests, recordedRequest{method: request.Method, uri: request.URL.RequestURI(), body: string(body)})
+		if len(requests) > len(responses) {
+			writer.Write([]byte("OK"))
+			return
+		}
+		responses[len(requests)-1](writer)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func respond(code int, body string) func(writer http.ResponseWriter) {
+	return func(writer http.ResponseWriter) {
+		writer.WriteHeader(code)
+		writer.Write([]byte(body))
+	}
+}
+
+func TestClient_Stubs(t *testing.T) {
+	stubs := `[{"fullMethod":"/greeter.Greeter/Hello","type":"mock","request":{"match":"exact","content":{"name":"John"}},"response":{"type":"success","content":{"greeting":"Hello"}}}]`
+	server, requests := newTestServer(t, respond(200, "OK"), respond(200, "OK"), respond(200, stubs), respond(200, "[]"), respond(200, "OK"))
+	client := New(server.URL + "/")
+	ctx := context.Background()
+	s := &stub.Stub{FullMethod: "/greeter.Greeter/Hello", Type: "mock", Request: &stub.StubRequest{Match: "exact", Content: `{"name":"John"}`}}
+
+	assert.Nil(t, client.CreateStub(ctx, s))
+	assert.Nil(t, client.UpdateStub(ctx, s))
+	listed, err := client.ListStubs(ctx, "/greeter.Greeter/Hello")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(listed))
+	assert.Equal(t, stub.JsonString(`{"greeting":"Hello"}`), listed[0].Response.Content)
+	listed, err = client.ListStubs(ctx, "")
+	assert.Nil(t, err)
+	assert.Empty(t, listed)
+	assert.Nil(t, client.DeleteStub(ctx, s))
+
+	body := `{"fullMethod":"/greeter.Greeter/Hello","type":"mock","request":{"match":"exact","content":{"name":"John"},"metadata":null},"response":null,"forward":null}`
+	assert.Equal(t, []recordedRequest{
+		{method: http.MethodPost, uri: "/stubs", body: body},
+		{method: http.MethodPut, uri: "/stubs", body: body},
+		{method: http.MethodGet, uri: "/stubs?method=%2Fgreeter.Greeter%2FHello"},
+		{method: http.MethodGet, uri: "/stubs"},
+		{method: http.MethodDelete, uri: "/stubs", body: body},
+	}, *requests)
+}
+
+func TestClient_VerifyAndReset(t *testing.T) {
+	server, requests := newTestServer(t,
+		respond(200, `{"verified":false,"matchCount":0,"message":"not matched"}`),
+		respond(200, `[]`),
+		respond(200, `[{"stub":{"fullMethod":"/greeter.Greeter/Hello"},"matchCount":2}]`),
+		respond(200, `[{"fullMethod":"/greeter.Greeter/Hello"}]`))
+	client := New(server.URL)
+	ctx := context.Background()
+
+	result, err := client.Verify(ctx, stub.StubVerification{FullMethod: "/greeter.Greeter/Hello", Times: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, stub.StubVerificationResult{Message: "not matched"}, result)
+	unmatched, err := client.UnmatchedStubs(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, unmatched)
+	usage, err := client.StubsUsage(ctx, "/greeter.Greeter/Hello")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, usage[0].MatchCount)
+	recordings, err := client.ExportRecordings(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "/greeter.Greeter/Hello", recordings[0].FullMethod)
+	assert.Nil(t, client.Reset(ctx))
+
+	assert.Equal(t, []string{"POST /verifications", "GET /verifications/unmatched",
+		"GET /verifications?method=%2Fgreeter.Greeter%2FHello", "GET /recordings", "DELETE /stubs", "DELETE /verifications"},
+		requestLines(*requests))
+}
+
+func TestClient_Sequences(t *testing.T) {
+	server, requests := newTestServer(t, respond(200, `[{"name":"checkout","steps":2,"completed":1,"violations":[],"verified":false}]`))
+	client := New(server.URL)
+
+	sequences, err := client.Sequences(context.Background(), "checkout")
+	assert.Nil(t, err)
+	assert.Equal(t, []stub.SequenceStatus{{Name: "checkout", Steps: 2, Completed: 1, Violations: []string{}}}, sequences)
+	assert.Equal(t, []string{"GET /verifications/sequences?name=checkout"}, requestLines(*requests))
+}
+
+func requestLines(requests []recordedRequest) []string {
+	lines := make([]string, 0, len(requests))
+	for _, r := range requests {
+		lines = append(lines, r.method+" "+r.uri)
+	}
+	return lines
+}
+
+func TestClient_Errors(t *testing.T) {
+	server, requests := newTestServer(t,
+		respond(409, "Stub already exists"),
+		respond(400, `{"errors":["Request is empty."],"example":{}}`))
+	client := New(server.URL)
+	ctx := context.Background()
+
+	err := client.CreateStub(ctx, &stub.Stub{})
+	assert.EqualError(t, err, "status 409: Stub already exists")
+	assert.Equal(t, 409, err.(*Error).StatusCode)
+	assert.EqualError(t, client.CreateStub(ctx, &stub.Stub{}), "status 400: Request is empty.")
+	assert.Equal(t, 2, len(*requests), "client errors are not retried")
+}
+
+func TestClient_Retries(t *testing.T) {
+	server, requests := newTestServer(t, respond(503, "starting"), respond(502, ""), respond(200, "OK"))
+	client := New(server.URL, WithRetries(2, time.Millisecond))
+	assert.Nil(t, client.Reset(context.Background()))
+	assert.Equal(t, 4, len(*requests))
+
+	server, requests = newTestServer(t, respond(503, "starting"), respond(503, "starting"))
+	client = New(server.URL, WithRetries(1, time.Millisecond))
+	assert.EqualError(t, client.Reset(context.Background()), "status 503: starting")
+	assert.Equal(t, 2, len(*requests))
+
+	client = New("http://127.0.0.1:1", WithRetries(5, time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Error(t, client.Reset(ctx))
+	assert.True(t, time.Since(start) < time.Second, "the retries stop when the context is done")
+}
+
+func TestClient_Chaos(t *testing.T) {
+	server, requests := newTestServer(t,
+		respond(200, `{"enabled":false,"percentage":0}`),
+		respond(200, `{"enabled":true,"percentage":10,"errorCodes":[14]}`))
+	client := New(server.URL)
+	ctx := context.Background()
+
+	config, err := client.Chaos(ctx)
+	assert.Nil(t, err)
+	assert.False(t, config.Enabled)
+	config, err = client.SetChaos(ctx, grpchandler.ChaosConfig{Enabled: true, Percentage: 10, ErrorCodes: []uint32{14}})
+	assert.Nil(t, err)
+	assert.Equal(t, grpchandler.ChaosConfig{Enabled: true, Percentage: 10, ErrorCodes: []uint32{14}}, config)
+	assert.Nil(t, client.DisableChaos(ctx))
+
+	assert.Equal(t, []recordedRequest{
+		{method: http.MethodGet, uri: "/admin/chaos"},
+		{method: http.MethodPut, uri: "/admin/chaos", body: `{"enabled":true,"percentage":10,"errorCodes":[14]}`},
+		{method: http.MethodDelete, uri: "/admin/chaos"},
+	}, *requests)
+}
+
+func TestClient_Scenarios(t *testing.T) {
+	server, requests := newTestServer(t,
+		respond(200, `{"name":"checkout","state":"paid"}`),
+		respond(200, `{"checkout":"paid"}`))
+	client := New(server.URL)
+	ctx := context.Background()
+
+	assert.Nil(t, client.SetScenario(ctx, "checkout", "paid"))
+	scenarios, err := client.Scenarios(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"checkout": "paid"}, scenarios)
+
+	assert.Equal(t, []recordedRequest{
+		{method: http.MethodPut, uri: "/scenarios/checkout", body: `{"state":"paid"}`},
+		{method: http.MethodGet, uri: "/scenarios"},
+	}, *requests)
+}
+
+func TestClient_RecordingsPage(t *testing.T) {
+	server, requests := newTestServer(t, respond(200, `{"recordings":[],"total":3}`))
+	client := New(server.URL)
+
+	page, err := client.RecordingsPage(context.Background(), 3, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, stub.RecordingsPage{Recordings: []*stub.Stub{}, Total: 3}, page)
+	assert.Equal(t, []recordedRequest{{method: http.MethodGet, uri: "/recordings/page?offset=3&limit=100"}}, *requests)
+}
@@ -0,0 +1,161 @@
+// Package stubgen implements the stubgen command generated with the stubgen parameter of the plugin. It scaffolds the stubs of
+// the methods of the mock services with the request and response prefilled from the descriptors.
+package stubgen
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const usage = `Usage:
+  stubgen list                    lists the methods of the mock services
+  stubgen new <method> [flags]    writes a stub of the method, e.g. stubgen new my.pkg.OrderService/GetOrder --error NOT_FOUND
+
+Flags of new:
+  --error <code>       gRPC status code returned, by name or number. A success response is returned when not provided
+  --message <message>  message of the error
+  --match <match>      exact or partial (default exact)
+  --forward <address>  forward the calls to the server instead of mocking them
+  -o <file>            file the stub is written to (default the standard output)
+`
+
+// Main runs the command with the arguments of the program and exits with status 1 when it fails.
+func Main(service grpchandler.MockService) {
+	if err := Run(os.Args[1:], os.Stdout, service); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Run runs the command with the arguments, without the program name, writing the results to out.
+func Run(args []string, out io.Writer, service grpchandler.MockService) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "list":
+		methods := service.GetSupportedMethods()
+		sort.Strings(methods)
+		for _, method := range methods {
+			fmt.Fprintln(out, method)
+		}
+		return nil
+	case "new":
+		return newStub(args[1:], out, service)
+	case "help", "-h", "--help":
+		_, err := fmt.Fprint(out, usage)
+		return err
+	}
+	return fmt.Errorf("unknown command '%s'\n\n%s", args[0], usage)
+}
+
+func newStub(args []string, out io.Writer, service grpchandler.MockService) error {
+	flags := flag.NewFlagSet("new", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	errorCode := flags.String("error", "", "")
+	message := flags.String("message", "", "")
+	match := flags.String("match", "exact", "")
+	forward := flags.String("forward", "", "")
+	output := flags.String("o", "", "")
+	// the method can be before or after the flags
+	method := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		method, args = args[0], args[1:]
+	}
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%s\n\n%s", err.Error(), usage)
+	}
+	if method == "" && flags.NArg() > 0 {
+		method = flags.Arg(0)
+	}
+	if method == "" {
+		return fmt.Errorf("the method is required\n\n%s", usage)
+	}
+	s, err := createStub(service, toFullMethod(method), *errorCode, *message, *match, *forward)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if *output != "" {
+		return ioutil.WriteFile(*output, data, 0644)
+	}
+	_, err = out.Write(data)
+	return err
+}
+
+// toFullMethod accepts the method as /pkg.Service/Method, pkg.Service/Method or pkg.Service.Method.
+func toFullMethod(method string) string {
+	method = strings.TrimPrefix(method, "/")
+	if !strings.Contains(method, "/") {
+		if i := strings.LastIndex(method, "."); i >= 0 {
+			method = method[:i] + "/" + method[i+1:]
+		}
+	}
+	return "/" + method
+}
+
+func createStub(service grpchandler.MockService, fullMethod, errorCode, message, match, forward string) (*stub.Stub, error) {
+	request := service.GetRequestInstance(fullMethod)
+	if request == nil {
+		return nil, fmt.Errorf("method %s not found. Use stubgen list to see the methods", fullMethod)
+	}
+	requestContent, err := stub.CreateStubContent(proto.MessageReflect(request).Descriptor())
+	if err != nil {
+		return nil, err
+	}
+	s := &stub.Stub{
+		FullMethod: fullMethod,
+		Type:       "mock",
+		Request: &stub.StubRequest{
+			Match:    match,
+			Content:  stub.JsonString(requestContent),
+			Metadata: make(map[string][]string),
+		},
+	}
+	switch {
+	case forward != "":
+		s.Type = "forward"
+		s.Forward = &stub.StubForward{ServerAddress: forward}
+	case errorCode != "":
+		code, err := parseCode(errorCode)
+		if err != nil {
+			return nil, err
+		}
+		s.Response = &stub.StubResponse{Type: "error", Error: &stub.ErrorResponse{Code: uint32(code), Message: message}}
+	default:
+		responseContent, err := stub.CreateStubContent(proto.MessageReflect(service.GetResponseInstance(fullMethod)).Descriptor())
+		if err != nil {
+			return nil, err
+		}
+		s.Response = &stub.StubResponse{Type: "success", Content: stub.JsonString(responseContent)}
+	}
+	return s, nil
+}
+
+// parseCode returns the status code by name, e.g. NOT_FOUND or not_found, or by number.
+func parseCode(value string) (codes.Code, error) {
+	var code codes.Code
+	if _, err := strconv.ParseUint(value, 10, 32); err != nil {
+		value = strconv.Quote(strings.ToUpper(value))
+	}
+	if err := code.UnmarshalJSON([]byte(value)); err != nil {
+		return code, fmt.Errorf("invalid status code '%s'", strings.Trim(value, `"`))
+	}
+	return code, nil
+}
@@ -0,0 +1,86 @@
+package stubgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/carvalhorr/protoc-gen-mock/grpchandler"
+	"github.com/carvalhorr/protoc-gen-mock/stub"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"testing"
+)
+
+const testMethod = "/pkg.Service/Method"
+
+type fakeMockService struct {
+	grpchandler.MockService
+}
+
+func (fakeMockService) GetSupportedMethods() []string {
+	return []string{testMethod, "/pkg.Other/Method"}
+}
+
+func (fakeMockService) GetRequestInstance(methodName string) proto.Message {
+	if methodName != testMethod {
+		return nil
+	}
+	return new(errdetails.ErrorInfo)
+}
+
+func (fakeMockService) GetResponseInstance(methodName string) proto.Message {
+	return new(errdetails.RetryInfo)
+}
+
+func runStubgen(t *testing.T, args ...string) *stub.Stub {
+	out := new(bytes.Buffer)
+	assert.Nil(t, Run(args, out, fakeMockService{}))
+	s := new(stub.Stub)
+	assert.Nil(t, json.Unmarshal(out.Bytes(), s))
+	return s
+}
+
+func TestRun_List(t *testing.T) {
+	out := new(bytes.Buffer)
+	assert.Nil(t, Run([]string{"list"}, out, fakeMockService{}))
+	assert.Equal(t, "/pkg.Other/Method\n/pkg.Service/Method\n", out.String())
+}
+
+func TestRun_NewSuccess(t *testing.T) {
+	s := runStubgen(t, "new", "pkg.Service/Method")
+	assert.Equal(t, testMethod, s.FullMethod)
+	assert.Equal(t, "exact", s.Request.Match)
+	assert.True(t, s.Request.Content.Equals(`{"reason":"reason","domain":"domain","metadata":{"key":"value"}}`), s.Request.Content.String())
+	assert.Equal(t, "success", s.Response.Type)
+	assert.True(t, s.Response.Content.Equals(`{"retryDelay":"0s"}`), s.Response.Content.String())
+	isValid, errMsgs := s.IsValid()
+	assert.True(t, isValid, errMsgs)
+}
+
+func TestRun_NewError(t *testing.T) {
+	s := runStubgen(t, "new", "pkg.Service.Method", "--error", "not_found", "--message", "order not found", "--match", "partial")
+	assert.Equal(t, testMethod, s.FullMethod)
+	assert.Equal(t, "partial", s.Request.Match)
+	assert.Equal(t, "error", s.Response.Type)
+	assert.Equal(t, uint32(codes.NotFound), s.Response.Error.Code)
+	assert.Equal(t, "order not found", s.Response.Error.Message)
+
+	s = runStubgen(t, "new", "--error", "5", testMethod)
+	assert.Equal(t, uint32(codes.NotFound), s.Response.Error.Code)
+}
+
+func TestRun_NewForward(t *testing.T) {
+	s := runStubgen(t, "new", testMethod, "--forward", "localhost:50051")
+	assert.Equal(t, stub.StubType("forward"), s.Type)
+	assert.Equal(t, "localhost:50051", s.Forward.ServerAddress)
+}
+
+func TestRun_Errors(t *testing.T) {
+	out := new(bytes.Buffer)
+	assert.EqualError(t, Run([]string{"new", "pkg.Service/Unknown"}, out, fakeMockService{}), "method /pkg.Service/Unknown not found. Use stubgen list to see the methods")
+	assert.EqualError(t, Run([]string{"new", testMethod, "--error", "WRONG"}, out, fakeMockService{}), "invalid status code 'WRONG'")
+	assert.Error(t, Run([]string{"delete"}, out, fakeMockService{}))
+	assert.Error(t, Run([]string{}, out, fakeMockService{}))
+	assert.Empty(t, out.String())
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"path"
+)
+
+type jsonSchema map[string]interface{}
+
+// customSchema is the schema of the custom matcher of the requests and of the custom generator of the responses.
+var customSchema = jsonSchema{
+	"type":       "object",
+	"required":   []string{"name"},
+	"properties": jsonSchema{"name": jsonSchema{"type": "string"}, "options": jsonSchema{}},
+}
+
+// GenerateStubSchemaFiles generates, for each service, a JSON Schema of the stubs of its methods so that stub files can be
+// validated by editors and CI before they are loaded in the mock server.
+func GenerateStubSchemaFiles(gen *protogen.Plugin, file *protogen.File, filter generationFilter) error {
+	m := mockServicesGenerator{
+		gen:    gen,
+		file:   file,
+		filter: filter,
+	}
+	for _, service := range m.services() {
+		data, err := json.MarshalIndent(m.createStubSchema(service), "", "  ")
+		if err != nil {
+			return fmt.Errorf("could not create the stub schema of %s: %w", service.Desc.FullName(), err)
+		}
+		filename := path.Join(path.Dir(file.GeneratedFilenamePrefix), "stubs", string(service.Desc.FullName())+".schema.json")
+		g := gen.NewGeneratedFile(filename, file.GoImportPath)
+		g.P(string(data))
+	}
+	return nil
+}
+
+func (m mockServicesGenerator) createStubSchema(service *protogen.Service) jsonSchema {
+	definitions := jsonSchema{}
+	fullMethods := make([]string, 0)
+	methodSchemas := make([]jsonSchema, 0)
+	for _, method := range m.methods(service) {
+		fullMethod := fmt.Sprintf("/%s/%s", service.Desc.FullName(), method.GoName)
+		fullMethods = append(fullMethods, fullMethod)
+		methodSchemas = append(methodSchemas, jsonSchema{
+			"properties": jsonSchema{
+				"fullMethod": jsonSchema{"const": fullMethod},
+				"request": jsonSchema{
+					"properties": jsonSchema{"content": createMessageSchema(method.Input.Desc, definitions)},
+				},
+				"response": jsonSchema{
+					"properties": jsonSchema{"content": createMessageSchema(method.Output.Desc, definitions)},
+				},
+			},
+		})
+	}
+	return jsonSchema{
+		"$schema":  "http://json-schema.org/draft-07/schema#",
+		"title":    fmt.Sprintf("Stub of the %s service", service.Desc.FullName()),
+		"type":     "object",
+		"required": []string{"fullMethod", "request"},
+		"properties": jsonSchema{
+			"fullMethod":  jsonSchema{"enum": fullMethods},
+			"type":        jsonSchema{"enum": []string{"mock", "forward", "passthrough"}},
+			"name":        jsonSchema{"type": "string"},
+			"description": jsonSchema{"type": "string"},
+			"tags":        jsonSchema{"type": "array", "items": jsonSchema{"type": "string", "minLength": 1}},
+			"request": jsonSchema{
+				"type":     "object",
+				"required": []string{"match"},
+				"anyOf":    []jsonSchema{{"required": []string{"content"}}, {"required": []string{"contentBinary"}}},
+				"properties": jsonSchema{
+					"match":         jsonSchema{"enum": []string{"exact", "partial", "custom"}},
+					"contentBinary": jsonSchema{"type": "string", "contentEncoding": "base64"},
+					"metadata":      jsonSchema{"type": "object", "additionalProperties": jsonSchema{"type": "array", "items": jsonSchema{"type": "string"}}},
+					"emptyArrays":   jsonSchema{"enum": []string{"absent", "empty"}},
+					"fieldMask": jsonSchema{
+						"type":       "object",
+						"properties": jsonSchema{"paths": jsonSchema{"type": "string"}, "field": jsonSchema{"type": "string"}},
+					},
+					"custom": customSchema,
+				},
+			},
+			"response": jsonSchema{
+				"type":     "object",
+				"required": []string{"type"},
+				"properties": jsonSchema{
+					"type":           jsonSchema{"enum": []string{"success", "error", "custom", "remote"}},
+					"contentBinary":  jsonSchema{"type": "string", "contentEncoding": "base64"},
+					"error":          jsonSchema{"type": "object", "required": []string{"code"}},
+					"delay":          jsonSchema{"type": "string"},
+					"exceedDeadline": jsonSchema{"type": "boolean"},
+					"bandwidth":      jsonSchema{"type": "string"},
+					"errorHeaders":   jsonSchema{"enum": []string{"trailers-only", "headers-first"}},
+					"headers":        jsonSchema{"type": "object", "additionalProperties": jsonSchema{"type": "array", "items": jsonSchema{"type": "string"}}},
+					"trailers":       jsonSchema{"type": "object", "additionalProperties": jsonSchema{"type": "array", "items": jsonSchema{"type": "string"}}},
+					"custom":         customSchema,
+					"remote": jsonSchema{
+						"type": "object",
+						"properties": jsonSchema{
+							"url":      jsonSchema{"type": "string"},
+							"address":  jsonSchema{"type": "string"},
+							"method":   jsonSchema{"type": "string"},
+							"tls":      jsonSchema{"type": "object"},
+							"timeout":  jsonSchema{"type": "string"},
+							"fallback": jsonSchema{"type": "object", "required": []string{"type"}},
+						},
+					},
+				},
+			},
+			"forward": jsonSchema{"type": "object", "properties": jsonSchema{"serverAddress": jsonSchema{"type": "string"}}},
+			"sequence": jsonSchema{
+				"type":     "object",
+				"required": []string{"name", "steps"},
+				"properties": jsonSchema{
+					"name":  jsonSchema{"type": "string"},
+					"steps": jsonSchema{"type": "array", "items": jsonSchema{"type": "integer", "minimum": 1}},
+				},
+			},
+		},
+		"oneOf":       methodSchemas,
+		"definitions": definitions,
+	}
+}
+
+// createMessageSchema returns the schema of the protojson form of the message. The messages are added to the definitions
+// and referenced so that recursive messages are supported.
+func createMessageSchema(t protoreflect.MessageDescriptor, definitions jsonSchema) jsonSchema {
+	if schema := createWellKnownTypeSchema(t); schema != nil {
+		return schema
+	}
+	name := string(t.FullName())
+	if _, ok := definitions[name]; !ok {
+		properties := jsonSchema{}
+		definitions[name] = jsonSchema{"type": "object", "properties": properties, "additionalProperties": false}
+		for i := 0; i < t.Fields().Len(); i++ {
+			field := t.Fields().Get(i)
+			properties[field.JSONName()] = createFieldSchema(field, definitions)
+		}
+	}
+	return jsonSchema{"$ref": "#/definitions/" + name}
+}
+
+func createFieldSchema(field protoreflect.FieldDescriptor, definitions jsonSchema) jsonSchema {
+	switch {
+	case field.IsMap():
+		return jsonSchema{"type": "object", "additionalProperties": createValueSchema(field.MapValue(), definitions)}
+	case field.IsList():
+		return jsonSchema{"type": "array", "items": createValueSchema(field, definitions)}
+	}
+	return createValueSchema(field, definitions)
+}
+
+func createValueSchema(field protoreflect.FieldDescriptor, definitions jsonSchema) jsonSchema {
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return createMessageSchema(field.Message(), definitions)
+	case protoreflect.EnumKind:
+		names := make([]string, 0, field.Enum().Values().Len())
+		for i := 0; i < field.Enum().Values().Len(); i++ {
+			names = append(names, string(field.Enum().Values().Get(i).Name()))
+		}
+		return jsonSchema{"anyOf": []jsonSchema{{"enum": names}, {"type": "integer"}}}
+	}
+	return createScalarSchema(field.Kind())
+}
+
+func createScalarSchema(kind protoreflect.Kind) jsonSchema {
+	switch kind {
+	case protoreflect.BoolKind:
+		return jsonSchema{"type": "boolean"}
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		return jsonSchema{"type": "string"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind, protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return jsonSchema{"type": "integer"}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		// NaN and Infinity are strings
+		return jsonSchema{"type": []string{"number", "string"}}
+	}
+	// 64 bit integers are strings in protojson but numbers are also accepted
+	return jsonSchema{"type": []string{"integer", "string"}}
+}
+
+// createWellKnownTypeSchema returns the schema of the canonical JSON form of the well-known types or nil for other messages.
+func createWellKnownTypeSchema(t protoreflect.MessageDescriptor) jsonSchema {
+	switch t.FullName() {
+	case "google.protobuf.Timestamp":
+		return jsonSchema{"type": "string", "format": "date-time"}
+	case "google.protobuf.Duration":
+		return jsonSchema{"type": "string", "pattern": "^-?[0-9]+(\\.[0-9]+)?s$"}
+	case "google.protobuf.FieldMask":
+		return jsonSchema{"type": "string"}
+	case "google.protobuf.Struct", "google.protobuf.Empty":
+		return jsonSchema{"type": "object"}
+	case "google.protobuf.ListValue":
+		return jsonSchema{"type": "array"}
+	case "google.protobuf.Value":
+		return jsonSchema{}
+	case "google.protobuf.Any":
+		return jsonSchema{"type": "object", "required": []string{"@type"}, "properties": jsonSchema{"@type": jsonSchema{"type": "string"}}}
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue", "google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value", "google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		return createScalarSchema(t.Fields().ByName("value").Kind())
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// GenerateTestServerFile generates, for each service, New<Service>TestServer starting its mock service in the test
+// process. It is kept out of the .mock.pb.go file so that the mock services don't depend on the testing packages.
+func GenerateTestServerFile(gen *protogen.Plugin, file *protogen.File, filter generationFilter) {
+	m := mockServicesGenerator{
+		gen:    gen,
+		file:   file,
+		filter: filter,
+	}
+	if len(m.services()) == 0 {
+		return
+	}
+	m.g = gen.NewGeneratedFile(file.GeneratedFilenamePrefix+".mocktest.pb.go", file.GoImportPath)
+	m.genHeader(string(file.GoPackageName))
+	for _, service := range m.services() {
+		testServerName := "New" + service.GoName + "TestServer"
+		m.g.P("// ", testServerName, " starts the ", service.GoName, " mock service in process. It is stopped when the test finishes.")
+		m.g.P("func ", testServerName, "(t ", testingPackage.Ident("TB"), ") *", mocktestPackage.Ident("TestServer"), " {")
+		m.g.P("return ", mocktestPackage.Ident("NewTestServer"), "(t, New", m.getMockServiceName(service), ")")
+		m.g.P("}")
+		m.g.P()
+	}
+}
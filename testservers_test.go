@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGenerateTestServerFile(t *testing.T) {
+	gen := newTestPlugin(t)
+	filter := generationFilter{services: namesFlag{}, excludedMethods: namesFlag{}}
+	GenerateTestServerFile(gen, gen.Files[0], filter)
+	GenerateFile(gen, gen.Files[0], filter)
+
+	files := gen.Response().GetFile()
+	assert.Equal(t, 2, len(files))
+	assert.Equal(t, "example.com/greeter/greeter.mocktest.pb.go", files[0].GetName())
+	assert.Contains(t, files[0].GetContent(), "func NewGreeterTestServer(t testing.TB) *mocktest.TestServer {")
+	assert.Contains(t, files[0].GetContent(), "func NewStreamerTestServer(t testing.TB) *mocktest.TestServer {")
+	// the mock services don't depend on the test harness
+	assert.NotContains(t, files[1].GetContent(), `"testing"`)
+	assert.NotContains(t, files[1].GetContent(), "mocktest")
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// GenerateTSClientFile generates mock-admin-client.ts with a TypeScript client of the REST API of the mock server, typed with
+// the requests and responses of the methods, so that stubs can be added from frontend test harnesses. It uses fetch and has
+// no dependencies. With the module parameter the file is generated in the root of the module.
+func GenerateTSClientFile(gen *protogen.Plugin, files []*protogen.File, filter generationFilter) {
+	if len(files) == 0 {
+		return
+	}
+	g := gen.NewGeneratedFile(path.Join(moduleParam(gen), "mock-admin-client.ts"), protogen.GoImportPath("main"))
+	g.P("// Code generated by protoc-gen-mock. DO NOT EDIT.")
+	g.P("// versions:")
+	g.P("// \tprotoc-gen-mock ", version)
+	g.P()
+	types := &tsTypes{definitions: make(map[protoreflect.FullName]string)}
+	methods := make([]string, 0)
+	m := mockServicesGenerator{gen: gen, filter: filter}
+	for _, file := range files {
+		m.file = file
+		for _, service := range m.services() {
+			for _, method := range m.methods(service) {
+				methods = append(methods, fmt.Sprintf("  %s: { request: %s; response: %s };",
+					strconv.Quote(fmt.Sprintf("/%s/%s", service.Desc.FullName(), method.GoName)),
+					types.messageType(method.Input.Desc), types.messageType(method.Output.Desc)))
+			}
+		}
+	}
+	for _, name := range types.order {
+		g.P(types.definitions[name])
+		g.P()
+	}
+	g.P("/** Requests and responses of the mocked methods, by full method name. */")
+	g.P("export interface Methods {")
+	for _, method := range methods {
+		g.P(method)
+	}
+	g.P("}")
+	g.P()
+	g.P("export type FullMethod = keyof Methods;")
+	g.P()
+	g.P(tsClientSource)
+}
+
+// tsTypes keeps the interfaces of the messages in the order they are first used.
+type tsTypes struct {
+	definitions map[protoreflect.FullName]string
+	order       []protoreflect.FullName
+}
+
+// messageType returns the type of the protojson form of the message, adding its interface to the definitions.
+func (t *tsTypes) messageType(message protoreflect.MessageDescriptor) string {
+	if wellKnownType := tsWellKnownType(message); wellKnownType != "" {
+		return wellKnownType
+	}
+	name := strings.ReplaceAll(string(message.FullName()), ".", "_")
+	if _, ok := t.definitions[message.FullName()]; ok {
+		return name
+	}
+	// added before the fields so that recursive messages are supported
+	t.definitions[message.FullName()] = ""
+	t.order = append(t.order, message.FullName())
+	lines := []string{fmt.Sprintf("/** %s */", message.FullName()), fmt.Sprintf("export interface %s {", name)}
+	for i := 0; i < message.Fields().Len(); i++ {
+		field := message.Fields().Get(i)
+		lines = append(lines, fmt.Sprintf("  %s?: %s;", strconv.Quote(field.JSONName()), t.fieldType(field)))
+	}
+	t.definitions[message.FullName()] = strings.Join(append(lines, "}"), "\n")
+	return name
+}
+
+func (t *tsTypes) fieldType(field protoreflect.FieldDescriptor) string {
+	switch {
+	case field.IsMap():
+		return fmt.Sprintf("{ [key: string]: %s }", t.valueType(field.MapValue()))
+	case field.IsList():
+		return fmt.Sprintf("Array<%s>", t.valueType(field))
+	}
+	return t.valueType(field)
+}
+
+func (t *tsTypes) valueType(field protoreflect.FieldDescriptor) string {
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return t.messageType(field.Message())
+	case protoreflect.EnumKind:
+		names := make([]string, 0, field.Enum().Values().Len()+1)
+		for i := 0; i < field.Enum().Values().Len(); i++ {
+			names = append(names, strconv.Quote(string(field.Enum().Values().Get(i).Name())))
+		}
+		return strings.Join(append(names, "number"), " | ")
+	}
+	return tsScalarType(field.Kind())
+}
+
+func tsScalarType(kind protoreflect.Kind) string {
+	switch kind {
+	case protoreflect.BoolKind:
+		return "boolean"
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		return "string"
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind, protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return "number"
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		// NaN and Infinity are strings
+		return "number | string"
+	}
+	// 64 bit integers are strings in protojson but numbers are also accepted
+	return "string | number"
+}
+
+// tsWellKnownType returns the type of the canonical JSON form of the well-known types or an empty string for other messages.
+func tsWellKnownType(message protoreflect.MessageDescriptor) string {
+	switch message.FullName() {
+	case "google.protobuf.Timestamp", "google.protobuf.Duration", "google.protobuf.FieldMask":
+		return "string"
+	case "google.protobuf.Struct":
+		return "{ [key: string]: unknown }"
+	case "google.protobuf.Empty":
+		return "Record<string, never>"
+	case "google.protobuf.ListValue":
+		return "Array<unknown>"
+	case "google.protobuf.Value":
+		return "unknown"
+	case "google.protobuf.Any":
+		return `{ "@type": string; [key: string]: unknown }`
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue", "google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value", "google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		return tsScalarType(message.Fields().ByName("value").Kind())
+	}
+	return ""
+}
+
+// tsClientSource is the part of the client that does not depend on the services. The types mirror the ones in the stub package.
+const tsClientSource = `export interface StubRequest<T> {
+  match: "exact" | "partial" | "custom";
+  content?: T;
+  /** The content in the protobuf binary format, encoded in base64, instead of content. */
+  contentBinary?: string;
+  metadata?: { [key: string]: string[] };
+  emptyArrays?: "absent" | "empty";
+  fieldMask?: { paths?: string; field?: string };
+  custom?: StubCustom;
+}
+
+export interface StubCustom {
+  name: string;
+  options?: unknown;
+}
+
+export interface ErrorResponse {
+  code: number;
+  message?: string;
+  details?: {
+    spec?: { import?: string; type: string };
+    values: Array<{ specOverride?: { import?: string; type: string }; value: unknown }>;
+  };
+}
+
+export interface StubResponse<T> {
+  type: "success" | "error" | "custom" | "remote";
+  content?: T;
+  contentBinary?: string;
+  error?: ErrorResponse;
+  delay?: string;
+  exceedDeadline?: boolean;
+  bandwidth?: string;
+  errorHeaders?: "trailers-only" | "headers-first";
+  headers?: { [key: string]: string[] };
+  trailers?: { [key: string]: string[] };
+  custom?: StubCustom;
+  remote?: StubRemote<T>;
+}
+
+export interface StubRemote<T> {
+  url?: string;
+  address?: string;
+  method?: string;
+  tls?: { caFile?: string; certFile?: string; keyFile?: string; serverName?: string; insecureSkipVerify?: boolean };
+  timeout?: string;
+  fallback?: StubResponse<T>;
+}
+
+export interface StubForward {
+  serverAddress?: string;
+  serverAddresses?: string[];
+  balancing?: "failover" | "roundrobin";
+  record?: boolean;
+  replay?: "exact" | "partial";
+  [key: string]: unknown;
+}
+
+export type Stub<M extends FullMethod = FullMethod> = M extends FullMethod ? {
+  fullMethod: M;
+  type?: "mock" | "forward" | "passthrough";
+  request: StubRequest<Methods[M]["request"]>;
+  response?: StubResponse<Methods[M]["response"]>;
+  forward?: StubForward;
+  sequence?: { name: string; steps: number[] };
+  name?: string;
+  description?: string;
+  tags?: string[];
+} : never;
+
+export interface StubVerification<M extends FullMethod = FullMethod> {
+  fullMethod: M;
+  request: StubRequest<Methods[M]["request"]>;
+  times: number;
+  atLeast?: boolean;
+}
+
+export interface StubVerificationResult {
+  verified: boolean;
+  matchCount: number;
+  message: string;
+}
+
+export interface StubUsage {
+  stub: Stub;
+  matchCount: number;
+}
+
+export interface ClockState {
+  now: string;
+  frozen: boolean;
+  offset?: string;
+}
+
+/** Error thrown when the mock server rejects a call, with the HTTP status and the message returned. */
+export class MockAdminError extends Error {
+  constructor(readonly status: number, message: string) {
+    super(message);
+  }
+}
+
+/** Client of the REST API of the mock server, listening on port 1068 by default. */
+export class MockAdminClient {
+  constructor(private readonly baseUrl: string = "http://127.0.0.1:1068", private readonly fetchFn: typeof fetch = fetch) {}
+
+  addStub<M extends FullMethod>(stub: Stub<M>): Promise<void> {
+    return this.call("POST", "/stubs", stub).then(() => undefined);
+  }
+
+  updateStub<M extends FullMethod>(stub: Stub<M>): Promise<void> {
+    return this.call("PUT", "/stubs", stub).then(() => undefined);
+  }
+
+  getStubs(method?: FullMethod): Promise<Stub[]> {
+    return this.call("GET", "/stubs" + methodQuery(method)).then(JSON.parse);
+  }
+
+  /** Returns the stubs with all the tags whose name, description or tags contain the query, ignoring the case. */
+  searchStubs(filter: { method?: FullMethod; tags?: string[]; query?: string }): Promise<Stub[]> {
+    const params = new URLSearchParams();
+    if (filter.method !== undefined) params.append("method", filter.method);
+    (filter.tags ?? []).forEach((tag) => params.append("tag", tag));
+    if (filter.query !== undefined) params.append("q", filter.query);
+    return this.call("GET", "/stubs?" + params.toString()).then(JSON.parse);
+  }
+
+  deleteStub<M extends FullMethod>(stub: Stub<M>): Promise<void> {
+    return this.call("DELETE", "/stubs", stub).then(() => undefined);
+  }
+
+  /** Deletes the stubs of the method, or all the stubs when no method is provided. */
+  deleteStubs(method?: FullMethod): Promise<void> {
+    return this.call("DELETE", "/stubs" + methodQuery(method)).then(() => undefined);
+  }
+
+  getExamples(): Promise<Stub[]> {
+    return this.call("GET", "/examples").then(JSON.parse);
+  }
+
+  verify<M extends FullMethod>(verification: StubVerification<M>): Promise<StubVerificationResult> {
+    return this.call("POST", "/verifications", verification).then(JSON.parse);
+  }
+
+  getStubsUsage(method?: FullMethod): Promise<StubUsage[]> {
+    return this.call("GET", "/verifications" + methodQuery(method)).then(JSON.parse);
+  }
+
+  getUnmatchedStubs(): Promise<Stub[]> {
+    return this.call("GET", "/verifications/unmatched").then(JSON.parse);
+  }
+
+  resetVerifications(): Promise<void> {
+    return this.call("DELETE", "/verifications").then(() => undefined);
+  }
+
+  getClock(): Promise<ClockState> {
+    return this.call("GET", "/admin/clock").then(JSON.parse);
+  }
+
+  /** Freezes the clock of the templates at the time, in RFC 3339, or shifts it from the real time by the offset, e.g. "-24h". */
+  setClock(clock: { time?: string; offset?: string }): Promise<ClockState> {
+    return this.call("PUT", "/admin/clock", clock).then(JSON.parse);
+  }
+
+  resetClock(): Promise<void> {
+    return this.call("DELETE", "/admin/clock").then(() => undefined);
+  }
+
+  private async call(method: string, path: string, body?: unknown): Promise<string> {
+    const response = await this.fetchFn(this.baseUrl + path, {
+      method,
+      headers: body === undefined ? undefined : { "Content-Type": "application/json" },
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    const text = await response.text();
+    if (!response.ok) {
+      throw new MockAdminError(response.status, text);
+    }
+    return text;
+  }
+}
+
+function methodQuery(method?: FullMethod): string {
+  return method === undefined ? "" : "?method=" + encodeURIComponent(method);
+}`